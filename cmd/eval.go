@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/eval"
+	"github.com/spf13/cobra"
+)
+
+// evalOptions are the flags of the eval command.
+type evalOptions struct {
+	BenchBinary string
+	BenchArgs   []string
+	TasksDir    string
+	TaskPattern string
+	Models      []string
+	Kubeconfig  string
+	KindCluster string
+	KeepCluster bool
+	OutputDir   string
+}
+
+// buildEvalCommand builds the eval command, running the tasks of k8s-ai-bench
+// against models and reporting how they compare.
+func buildEvalCommand() *cobra.Command {
+	opt := evalOptions{
+		BenchBinary: filepath.Join(".build", "bin", "k8s-ai-bench"),
+		TasksDir:    filepath.Join(".build", "k8s-ai-bench-src", "tasks"),
+		OutputDir:   filepath.Join(".build", "k8s-ai-bench"),
+	}
+
+	cmd := &cobra.Command{
+		Use:   "eval",
+		Short: "Evaluate models on the Kubernetes tasks of k8s-ai-bench",
+		Long:  "eval runs the tasks of k8s-ai-bench against each model with this kubectl-ai binary, and writes a report comparing the models. The agent runs with --skip-permissions, so the tasks run against a kind cluster or an explicit test kubeconfig, never the default one.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEval(cmd, opt)
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&opt.BenchBinary, "bench-bin", opt.BenchBinary, "k8s-ai-bench binary, built by dev/ci/periodics/build-k8s-ai-bench.sh")
+	f.StringArrayVar(&opt.BenchArgs, "bench-arg", opt.BenchArgs, "extra argument of k8s-ai-bench run, e.g. --bench-arg=--enable-tool-use-shim (can be repeated)")
+	f.StringVar(&opt.TasksDir, "tasks-dir", opt.TasksDir, "directory of the tasks of k8s-ai-bench")
+	f.StringVar(&opt.TaskPattern, "task-pattern", opt.TaskPattern, "regular expression selecting the tasks to run by name")
+	f.StringSliceVar(&opt.Models, "models", opt.Models, "models to evaluate, as provider/model, e.g. gemini/gemini-2.5-pro,openai/gpt-4.1")
+	f.StringVar(&opt.Kubeconfig, "kubeconfig", opt.Kubeconfig, "kubeconfig of the test cluster the tasks run against, it can't be the default kubeconfig")
+	f.StringVar(&opt.KindCluster, "kind-cluster", opt.KindCluster, "run the tasks against this kind cluster, created if it doesn't exist")
+	f.BoolVar(&opt.KeepCluster, "keep-cluster", opt.KeepCluster, "keep the kind cluster created for the run")
+	f.StringVar(&opt.OutputDir, "output-dir", opt.OutputDir, "directory receiving the results of k8s-ai-bench and the report")
+	return cmd
+}
+
+func runEval(cmd *cobra.Command, opt evalOptions) error {
+	if len(opt.Models) == 0 {
+		return fmt.Errorf("no model to evaluate, use --models")
+	}
+	var models []eval.Model
+	for _, s := range opt.Models {
+		model, err := eval.ParseModel(s)
+		if err != nil {
+			return err
+		}
+		models = append(models, model)
+	}
+	// The agent evaluated is this binary
+	agentBinary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding the kubectl-ai binary: %w", err)
+	}
+
+	report, err := eval.Run(cmd.Context(), eval.Config{
+		BenchBinary: opt.BenchBinary,
+		BenchArgs:   opt.BenchArgs,
+		TasksDir:    opt.TasksDir,
+		TaskPattern: opt.TaskPattern,
+		Models:      models,
+		AgentBinary: agentBinary,
+		Kubeconfig:  opt.Kubeconfig,
+		KindCluster: opt.KindCluster,
+		KeepCluster: opt.KeepCluster,
+		OutputDir:   opt.OutputDir,
+	})
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(report)
+	if err != nil {
+		return fmt.Errorf("reading report: %w", err)
+	}
+	_, err = cmd.OutOrStdout().Write(b)
+	return err
+}
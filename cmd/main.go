@@ -23,17 +23,22 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/compression"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/notify"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/retrieval"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/ui"
@@ -59,6 +64,9 @@ func BuildRootCommand(opt *Options) (*cobra.Command, error) {
 		Long:  "kubectl-ai is a command-line tool that allows you to interact with your Kubernetes cluster using natural language queries. It leverages large language models to understand your intent and translate it into kubectl",
 		Args:  cobra.MaximumNArgs(1), // Only one positional arg is allowed.
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opt.applyProfile(cmd.Flags()); err != nil {
+				return err
+			}
 			return RunRootCommand(cmd.Context(), *opt, args)
 		},
 	}
@@ -71,6 +79,7 @@ func BuildRootCommand(opt *Options) (*cobra.Command, error) {
 			os.Exit(0)
 		},
 	})
+	rootCmd.AddCommand(buildEvalCommand())
 
 	if err := opt.bindCLIFlags(rootCmd.Flags()); err != nil {
 		return nil, err
@@ -84,6 +93,8 @@ type Options struct {
 	// SkipPermissions is a flag to skip asking for confirmation before executing kubectl commands
 	// that modifies resources in the cluster.
 	SkipPermissions bool `json:"skipPermissions,omitempty"`
+	// ReadOnly restricts tools to operations that don't modify resources.
+	ReadOnly bool `json:"readOnly,omitempty"`
 	// EnableToolUseShim is a flag to enable tool use shim.
 	// TODO(droot): figure out a better way to discover if the model supports tool use
 	// and set this automatically.
@@ -96,6 +107,11 @@ type Options struct {
 	// ExternalTools enables discovery and exposure of external MCP tools (only works with --mcp-server)
 	ExternalTools bool `json:"externalTools,omitempty"`
 	MaxIterations int  `json:"maxIterations,omitempty"`
+	// MaxContinuations bounds the continuations of responses cut off at the output token limit of the model.
+	MaxContinuations int `json:"maxContinuations,omitempty"`
+	// MaxQueryDuration and MaxQueryTokens limit the time and tokens spent on a query, zero means no limit.
+	MaxQueryDuration time.Duration `json:"maxQueryDuration,omitempty"`
+	MaxQueryTokens   int64         `json:"maxQueryTokens,omitempty"`
 	// MCPServerMode is the mode of the MCP server. only works with --mcp-server.
 	MCPServerMode string `json:"mcpServerMode,omitempty"`
 	// Set the HTTP endpoint port for the MCP server when using HTTP transports like streamable-http.
@@ -110,13 +126,57 @@ type Options struct {
 	RemoveWorkDir          bool     `json:"removeWorkDir,omitempty"`
 	ToolConfigPaths        []string `json:"toolConfigPaths,omitempty"`
 
+	// TraceMaxSizeMB and TraceMaxAge rotate the trace file, keeping
+	// TraceMaxBackups rotated files. Zero means no limit.
+	TraceMaxSizeMB  int           `json:"traceMaxSizeMB,omitempty"`
+	TraceMaxAge     time.Duration `json:"traceMaxAge,omitempty"`
+	TraceMaxBackups int           `json:"traceMaxBackups,omitempty"`
+	// TraceOTLPEndpoint, if set, also exports the trace as OTLP logs to this
+	// OTLP/HTTP collector, with TraceOTLPHeaders.
+	TraceOTLPEndpoint string            `json:"traceOTLPEndpoint,omitempty"`
+	TraceOTLPHeaders  map[string]string `json:"traceOTLPHeaders,omitempty"`
+
+	// SystemPromptPath is a template that extends or replaces the system
+	// prompt, skipped if it doesn't exist.
+	SystemPromptPath string `json:"systemPromptPath,omitempty"`
+	// PromptVars are made available to prompt templates as {{.Vars.<name>}},
+	// e.g. the naming conventions of the organization.
+	PromptVars map[string]string `json:"promptVars,omitempty"`
+
 	// UIType is the type of user interface to use.
 	UIType ui.Type `json:"uiType,omitempty"`
 	// UIListenAddress is the address to listen for the web UI.
 	UIListenAddress string `json:"uiListenAddress,omitempty"`
+	// UIBaseURL is the URL users reach the web UI at, for the links of
+	// notifications. It defaults to http://<UIListenAddress>.
+	UIBaseURL string `json:"uiBaseURL,omitempty"`
+	// NotifyWebhooks are told when a headless run (--quiet or the web UI) is
+	// done with a query or waits for approval. Each is a URL, optionally
+	// prefixed with its format: json:, slack: or teams:.
+	NotifyWebhooks []string `json:"notifyWebhooks,omitempty"`
+	// MaxAgents is the maximum number of session agents the web UI keeps alive, zero means no limit.
+	MaxAgents int `json:"maxAgents,omitempty"`
+	// AgentIdleTTL is how long the web UI keeps an idle session agent alive, zero means forever.
+	AgentIdleTTL time.Duration `json:"agentIdleTTL,omitempty"`
+
+	// Endpoint is the URL of the LLM provider's API, overriding its environment variable (e.g. OPENAI_ENDPOINT).
+	Endpoint string `json:"endpoint,omitempty"`
+	// Profile selects one of Profiles.
+	Profile string `json:"profile,omitempty"`
+	// Profiles are named sets of provider and sandbox settings.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
 
 	// SkipVerifySSL is a flag to skip verifying the SSL certificate of the LLM provider.
 	SkipVerifySSL bool `json:"skipVerifySSL,omitempty"`
+	// Proxy is the proxy URL to use for requests to the LLM provider.
+	// If not set, HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honored.
+	Proxy string `json:"proxy,omitempty"`
+	// CABundle is a PEM file with additional CA certificates to trust for the LLM provider.
+	// Can also be set with the KUBECTL_AI_CA_BUNDLE environment variable.
+	CABundle string `json:"caBundle,omitempty"`
+	// ClientCert and ClientKey are a PEM certificate and key used for mutual TLS with the LLM provider.
+	ClientCert string `json:"clientCert,omitempty"`
+	ClientKey  string `json:"clientKey,omitempty"`
 
 	// Session management options
 	ResumeSession  string `json:"resumeSession,omitempty"`
@@ -135,6 +195,142 @@ type Options struct {
 
 	// SandboxImage is the container image to use for the sandbox
 	SandboxImage string `json:"sandboxImage,omitempty"`
+
+	// SandboxUnrestricted runs the k8s sandbox pod without the restricted security context
+	// (non-root, read-only root filesystem, RuntimeDefault seccomp profile, no capabilities).
+	SandboxUnrestricted bool `json:"sandboxUnrestricted,omitempty"`
+
+	// SandboxRuntimeClass is the RuntimeClass of the k8s sandbox pod, e.g. "gvisor" or "kata".
+	SandboxRuntimeClass string `json:"sandboxRuntimeClass,omitempty"`
+
+	// Contexts lists the kubeconfig contexts the agent may switch between using the switch_context tool.
+	Contexts []tools.KubeContext `json:"contexts,omitempty"`
+	// PrometheusURL enables the promql_query tool, querying metrics from this Prometheus endpoint.
+	PrometheusURL string `json:"prometheusURL,omitempty"`
+	// KyvernoTools enables the tools inspecting Kyverno policies and policy reports.
+	KyvernoTools bool `json:"kyvernoTools,omitempty"`
+	// SchemaLookup enables the schema_lookup tool and lists the custom
+	// resources of the cluster in the system prompt.
+	SchemaLookup bool `json:"schemaLookup,omitempty"`
+	// SchemaCacheTTL is how long the API resources and CRD schemas are reused.
+	SchemaCacheTTL time.Duration `json:"schemaCacheTTL,omitempty"`
+	// Delegation enables the delegate tool, which runs focused investigations in read-only sub-agents.
+	Delegation bool `json:"delegation,omitempty"`
+	// SubAgentMaxIterations bounds the tool loop of sub-agents.
+	SubAgentMaxIterations int `json:"subAgentMaxIterations,omitempty"`
+
+	// MaxToolOutputBytes is the size above which tool outputs are truncated before being sent to the LLM.
+	// Zero disables truncation.
+	MaxToolOutputBytes int `json:"maxToolOutputBytes,omitempty"`
+	// PruneToolOutputsAfter is the number of queries after which large tool
+	// outputs are replaced in the history sent to the LLM with a summary.
+	// Zero disables pruning.
+	PruneToolOutputsAfter int `json:"pruneToolOutputsAfter,omitempty"`
+	// PruneToolOutputBytes is the size above which tool outputs are pruned.
+	PruneToolOutputBytes int `json:"pruneToolOutputBytes,omitempty"`
+	// HistorySummarizers maps message types, or "default", to the summarizer
+	// of their large messages when a history is replayed to the LLM: llm,
+	// extractive or none. Empty disables history compression.
+	HistorySummarizers map[string]string `json:"historySummarizers,omitempty"`
+	// SummarizerModel is the model of the llm summarizers, preferably a cheap
+	// one. Without one, they make extractive summaries.
+	SummarizerModel string `json:"summarizerModel,omitempty"`
+	// HistoryMaxBytes is the size above which the oldest messages of a
+	// history replayed to the LLM are dropped. Zero means no limit.
+	HistoryMaxBytes int `json:"historyMaxBytes,omitempty"`
+	// ToolVerbosity is how much documentation of the tools is sent to the LLM:
+	// full, compact or minimal.
+	ToolVerbosity string `json:"toolVerbosity,omitempty"`
+	// ToolTimeout bounds how long a tool call may run. Zero means no limit.
+	ToolTimeout time.Duration `json:"toolTimeout,omitempty"`
+	// ToolTimeouts overrides ToolTimeout for individual tools, e.g. {"bash": "10m"}.
+	ToolTimeouts map[string]string `json:"toolTimeouts,omitempty"`
+	// MaxExecOutputBytes is the maximum size of stdout and stderr kept from a command.
+	// Output beyond it is discarded as it is produced. Zero means no limit.
+	MaxExecOutputBytes int `json:"maxExecOutputBytes,omitempty"`
+	// RedactSecrets masks secrets and credentials in tool outputs and user input sent to the LLM.
+	RedactSecrets bool `json:"redactSecrets,omitempty"`
+	// RedactPatterns are regular expressions of additional content to mask.
+	RedactPatterns []string `json:"redactPatterns,omitempty"`
+	// ContentFilters are webhooks (http(s) URLs) or shell commands transforming
+	// tool outputs and user input before they are sent to the LLM.
+	ContentFilters []string `json:"contentFilters,omitempty"`
+	// ModelRoutingRules is a YAML file of rules picking the model of each
+	// query, e.g. a small model for summaries and a large one for fixes.
+	ModelRoutingRules string `json:"modelRoutingRules,omitempty"`
+	// FallbackModel replaces a model the provider doesn't know. If empty, the
+	// available model whose name is the closest is used.
+	FallbackModel string `json:"fallbackModel,omitempty"`
+	// Watch evaluates the query over and over, every WatchInterval and on
+	// changes of WatchResources, and notifies when the answer changes.
+	Watch          bool          `json:"watch,omitempty"`
+	WatchInterval  time.Duration `json:"watchInterval,omitempty"`
+	WatchResources []string      `json:"watchResources,omitempty"`
+	// WatchWebhooks are told about the changes, in the format of
+	// NotifyWebhooks.
+	WatchWebhooks []string `json:"watchWebhooks,omitempty"`
+	// WatchDesktopNotify shows the changes as desktop notifications.
+	WatchDesktopNotify bool `json:"watchDesktopNotify,omitempty"`
+	// WatchExitOnChange stops watching at the first change, with exit code 3.
+	WatchExitOnChange bool `json:"watchExitOnChange,omitempty"`
+
+	// RunbooksDir is a directory of YAML runbooks, sequences of prompts run
+	// with `run <runbook>`, skipped if it doesn't exist.
+	RunbooksDir string `json:"runbooksDir,omitempty"`
+	// UserProfilePath is the YAML file of the user's long-term profile, facts
+	// the user accepted that are included in the system prompt of every
+	// session. Empty, the default, disables it. It is shared by all the
+	// sessions, so it can't be used with the web UI.
+	UserProfilePath string `json:"userProfilePath,omitempty"`
+	// DocsDir is a directory of Markdown runbooks and docs, indexed so that
+	// the excerpts relevant to each query are sent with it. Empty disables it.
+	DocsDir string `json:"docsDir,omitempty"`
+	// DocsIndexPath is where the embeddings of the docs are stored, so that
+	// only the docs that changed are embedded again.
+	DocsIndexPath string `json:"docsIndexPath,omitempty"`
+	// DocsTopK is the maximum number of excerpts of the docs sent with a query.
+	DocsTopK int `json:"docsTopK,omitempty"`
+	// DocsMinScore is the minimum similarity of the excerpts sent to the query.
+	DocsMinScore float64 `json:"docsMinScore,omitempty"`
+	// EmbeddingModel is the model computing the embeddings of the docs, the
+	// default embedding model of the provider if empty.
+	EmbeddingModel string `json:"embeddingModel,omitempty"`
+	// MaxParallelToolCalls is the maximum number of read-only tool calls from one LLM turn run concurrently.
+	MaxParallelToolCalls int `json:"maxParallelToolCalls,omitempty"`
+	// AutoNameSessions names sessions using the LLM after the first couple of exchanges.
+	AutoNameSessions bool `json:"autoNameSessions,omitempty"`
+	// CompletionCacheTTL is how long single-prompt completions (e.g. session
+	// names) are cached and reused. Zero disables the cache.
+	CompletionCacheTTL time.Duration `json:"completionCacheTTL,omitempty"`
+	// CompletionCacheSize is the maximum number of cached completions.
+	CompletionCacheSize int `json:"completionCacheSize,omitempty"`
+	// Seed makes the providers supporting it sample deterministically, e.g. for
+	// reproducible evaluation runs. Zero leaves sampling random.
+	Seed int64 `json:"seed,omitempty"`
+	// SearchGrounding registers the web_search tool, answering questions
+	// with completions grounded with web search results, for the providers
+	// supporting it (Gemini, Vertex AI). The chat itself can't be grounded, as
+	// it defines tools.
+	SearchGrounding bool `json:"searchGrounding,omitempty"`
+	// MaxStreamLineBytes is the maximum size of a line of the responses
+	// streamed by LLM providers. Zero means gollm.DefaultMaxStreamLineBytes.
+	MaxStreamLineBytes int `json:"maxStreamLineBytes,omitempty"`
+	// AWSProfile, AWSRegion, AWSRoleARN and AWSRoleExternalID configure the
+	// credentials of the bedrock provider instead of the default AWS
+	// credential chain, e.g. to use an AWS SSO profile or assume a role.
+	AWSProfile        string `json:"awsProfile,omitempty"`
+	AWSRegion         string `json:"awsRegion,omitempty"`
+	AWSRoleARN        string `json:"awsRoleARN,omitempty"`
+	AWSRoleExternalID string `json:"awsRoleExternalID,omitempty"`
+
+	// ClusterContext injects a summary of the cluster into the system prompt at session start.
+	ClusterContext bool `json:"clusterContext,omitempty"`
+	// ClusterContextTTL is how long the cluster summary is reused before it is refreshed.
+	ClusterContextTTL time.Duration `json:"clusterContextTTL,omitempty"`
+
+	// Pricing overrides the built-in per-model pricing used for cost estimates,
+	// e.g. for private or negotiated pricing. Keys are model IDs.
+	Pricing map[string]gollm.ModelPricing `json:"pricing,omitempty"`
 }
 
 var defaultToolConfigPaths = []string{
@@ -142,6 +338,12 @@ var defaultToolConfigPaths = []string{
 	filepath.Join("{HOME}", ".config", "kubectl-ai", "tools.yaml"),
 }
 
+var defaultSystemPromptPath = filepath.Join("{HOME}", ".config", "kubectl-ai", "systemprompt.tmpl")
+
+var defaultRunbooksDir = filepath.Join("{HOME}", ".config", "kubectl-ai", "runbooks")
+
+var defaultDocsIndexPath = filepath.Join("{HOME}", ".cache", "kubectl-ai", "docs-index.json")
+
 var defaultConfigPaths = []string{
 	filepath.Join("{CONFIG}", "kubectl-ai", "config.yaml"),
 	filepath.Join("{HOME}", ".config", "kubectl-ai", "config.yaml"),
@@ -152,6 +354,7 @@ func (o *Options) InitDefaults() {
 	o.ModelID = "gemini-2.5-pro"
 	// by default, confirm before executing kubectl commands that modify resources in the cluster.
 	o.SkipPermissions = false
+	o.ReadOnly = false
 	o.MCPServer = false
 	o.MCPClient = false
 	// by default, external tools are disabled (only works with --mcp-server)
@@ -162,16 +365,28 @@ func (o *Options) InitDefaults() {
 	o.Quiet = false
 	o.MCPServer = false
 	o.MaxIterations = 20
+	o.MaxContinuations = 3
 	o.KubeConfigPath = ""
 	o.PromptTemplateFilePath = ""
 	o.ExtraPromptPaths = []string{}
+	o.SystemPromptPath = defaultSystemPromptPath
+	o.RunbooksDir = defaultRunbooksDir
+	o.DocsIndexPath = defaultDocsIndexPath
+	o.DocsTopK = retrieval.DefaultTopK
+	o.DocsMinScore = 0.3
+	o.WatchInterval = 5 * time.Minute
+	o.PromptVars = map[string]string{}
 	o.TracePath = filepath.Join(os.TempDir(), "kubectl-ai-trace.txt")
+	o.TraceMaxBackups = 3
+	o.TraceOTLPHeaders = map[string]string{}
 	o.RemoveWorkDir = false
 	o.ToolConfigPaths = defaultToolConfigPaths
 	// Default to terminal UI
 	o.UIType = ui.UITypeTerminal
 	// Default UI listen address for HTML UI
 	o.UIListenAddress = "localhost:8888"
+	o.MaxAgents = 20
+	o.AgentIdleTTL = 30 * time.Minute
 	// Default to not skipping SSL verification
 	o.SkipVerifySSL = false
 	// Default MCP server mode is stdio
@@ -188,6 +403,20 @@ func (o *Options) InitDefaults() {
 	// By default, hide tool outputs
 	o.ShowToolOutput = false
 
+	o.MaxToolOutputBytes = tools.DefaultMaxOutputBytes
+	o.ToolTimeout = 5 * time.Minute
+	o.MaxExecOutputBytes = 10 * 1024 * 1024
+	o.RedactSecrets = true
+	o.MaxParallelToolCalls = 4
+	o.SubAgentMaxIterations = 10
+	o.AutoNameSessions = false
+	o.CompletionCacheSize = 256
+
+	// Cluster context is opt-in, as collecting it runs extra kubectl commands
+	o.ClusterContext = false
+	o.ClusterContextTTL = 10 * time.Minute
+	o.SchemaCacheTTL = tools.DefaultSchemaCacheTTL
+
 	o.Sandbox = ""
 	o.SandboxImage = "bitnami/kubectl:latest"
 }
@@ -259,6 +488,9 @@ func main() {
 		if errors.Is(err, context.Canceled) {
 			os.Exit(0)
 		}
+		if errors.Is(err, ui.ErrWatchChanged) {
+			os.Exit(watchChangedExitCode)
+		}
 		os.Exit(1)
 	}
 }
@@ -304,16 +536,70 @@ func run(ctx context.Context) error {
 	return nil
 }
 
+// newRecorder creates the recorder of the trace: a file, rotated if limits
+// are set, and an OTLP exporter if an endpoint is set.
+func (opt *Options) newRecorder() (journal.Recorder, error) {
+	var recorders journal.MultiRecorder
+	if opt.TracePath != "" {
+		var fileRecorder journal.Recorder
+		var err error
+		if opt.TraceMaxSizeMB > 0 || opt.TraceMaxAge > 0 {
+			fileRecorder, err = journal.NewRotatingFileRecorder(opt.TracePath, journal.RotationOptions{
+				MaxSize:    int64(opt.TraceMaxSizeMB) << 20,
+				MaxAge:     opt.TraceMaxAge,
+				MaxBackups: opt.TraceMaxBackups,
+			})
+		} else {
+			fileRecorder, err = journal.NewFileRecorder(opt.TracePath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("creating trace recorder: %w", err)
+		}
+		recorders = append(recorders, fileRecorder)
+	}
+	if opt.TraceOTLPEndpoint != "" {
+		otlpRecorder, err := journal.NewOTLPRecorder(journal.OTLPOptions{
+			Endpoint: opt.TraceOTLPEndpoint,
+			Headers:  opt.TraceOTLPHeaders,
+		})
+		if err != nil {
+			recorders.Close()
+			return nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
+		}
+		recorders = append(recorders, otlpRecorder)
+	}
+
+	switch len(recorders) {
+	case 0:
+		// Ensure we always have a recorder, to avoid nil checks
+		return &journal.LogRecorder{}, nil
+	case 1:
+		return recorders[0], nil
+	}
+	return recorders, nil
+}
+
 func (opt *Options) bindCLIFlags(f *pflag.FlagSet) error {
 	f.IntVar(&opt.MaxIterations, "max-iterations", opt.MaxIterations, "maximum number of iterations agent will try before giving up")
+	f.IntVar(&opt.MaxContinuations, "max-continuations", opt.MaxContinuations, "maximum number of times a response cut off at the output token limit of the model is continued (0 to disable)")
+	f.DurationVar(&opt.MaxQueryDuration, "max-query-duration", opt.MaxQueryDuration, "maximum time spent on a query before the agent summarizes its progress and asks whether to continue (0 for no limit)")
+	f.Int64Var(&opt.MaxQueryTokens, "max-query-tokens", opt.MaxQueryTokens, "maximum LLM tokens used for a query before the agent summarizes its progress and asks whether to continue (0 for no limit)")
 	f.StringVar(&opt.KubeConfigPath, "kubeconfig", opt.KubeConfigPath, "path to kubeconfig file")
 	f.StringVar(&opt.PromptTemplateFilePath, "prompt-template-file-path", opt.PromptTemplateFilePath, "path to custom prompt template file")
 	f.StringArrayVar(&opt.ExtraPromptPaths, "extra-prompt-paths", opt.ExtraPromptPaths, "extra prompt template paths")
+	f.StringVar(&opt.SystemPromptPath, "system-prompt-path", opt.SystemPromptPath, "path to a template that extends (with {{template \"default\" .}}) or replaces the system prompt; ignored if it doesn't exist")
+	f.StringToStringVar(&opt.PromptVars, "prompt-var", opt.PromptVars, "variables for prompt templates, available as {{.Vars.<name>}}, e.g. team=payments")
 	f.StringVar(&opt.TracePath, "trace-path", opt.TracePath, "path to the trace file")
+	f.IntVar(&opt.TraceMaxSizeMB, "trace-max-size-mb", opt.TraceMaxSizeMB, "rotate the trace file when it reaches this size in megabytes (0 for no limit)")
+	f.DurationVar(&opt.TraceMaxAge, "trace-max-age", opt.TraceMaxAge, "rotate the trace file when it gets older than this, e.g. 24h (0 for no limit)")
+	f.IntVar(&opt.TraceMaxBackups, "trace-max-backups", opt.TraceMaxBackups, "number of rotated trace files to keep")
+	f.StringVar(&opt.TraceOTLPEndpoint, "trace-otlp-endpoint", opt.TraceOTLPEndpoint, "also export the trace as OTLP logs to this OTLP/HTTP collector, e.g. http://localhost:4318")
+	f.StringToStringVar(&opt.TraceOTLPHeaders, "trace-otlp-header", opt.TraceOTLPHeaders, "headers of the OTLP export requests, e.g. Authorization=\"Bearer ...\"")
 	f.BoolVar(&opt.RemoveWorkDir, "remove-workdir", opt.RemoveWorkDir, "remove the temporary working directory after execution")
 
 	f.StringVar(&opt.ProviderID, "llm-provider", opt.ProviderID, "language model provider")
 	f.StringVar(&opt.ModelID, "model", opt.ModelID, "language model e.g. gemini-2.0-flash-thinking-exp-01-21, gemini-2.0-flash")
+	f.BoolVar(&opt.ReadOnly, "read-only", opt.ReadOnly, "only allow operations that don't modify resources (kubectl get, describe, logs and top); other tool calls are rejected")
 	f.BoolVar(&opt.SkipPermissions, "skip-permissions", opt.SkipPermissions, "(dangerous) skip asking for confirmation before executing kubectl commands that modify resources")
 	f.BoolVar(&opt.MCPServer, "mcp-server", opt.MCPServer, "run in MCP server mode")
 	f.BoolVar(&opt.ExternalTools, "external-tools", opt.ExternalTools, "in MCP server mode, discover and expose external MCP tools")
@@ -324,24 +610,216 @@ func (opt *Options) bindCLIFlags(f *pflag.FlagSet) error {
 	f.BoolVar(&opt.EnableToolUseShim, "enable-tool-use-shim", opt.EnableToolUseShim, "enable tool use shim")
 	f.BoolVar(&opt.Quiet, "quiet", opt.Quiet, "run in non-interactive mode, requires a query to be provided as a positional argument")
 
-	f.Var(&opt.UIType, "ui-type", "user interface type to use. Supported values: terminal, web, tui.")
+	f.Var(&opt.UIType, "ui-type", "user interface type to use. Supported values: terminal, web, tui, plain (line based, for slow connections).")
 	f.StringVar(&opt.UIListenAddress, "ui-listen-address", opt.UIListenAddress, "address to listen for the HTML UI.")
+	f.StringVar(&opt.UIBaseURL, "ui-base-url", opt.UIBaseURL, "URL users reach the HTML UI at, for the links of notifications (default http://<ui-listen-address>)")
+	f.StringArrayVar(&opt.NotifyWebhooks, "notify-webhook", opt.NotifyWebhooks, "webhook told when a headless run (--quiet or the HTML UI) is done with a query or waits for approval; a URL, optionally prefixed with its format: json: (default), slack: or teams:")
+	f.IntVar(&opt.MaxAgents, "max-agents", opt.MaxAgents, "maximum number of session agents the HTML UI keeps alive; the least recently used idle one is shut down to start another (0 for no limit)")
+	f.DurationVar(&opt.AgentIdleTTL, "agent-idle-ttl", opt.AgentIdleTTL, "shut down session agents of the HTML UI idle for longer than this, they are restarted from the saved session when needed (0 to keep them)")
+	f.StringVar(&opt.Endpoint, "llm-endpoint", opt.Endpoint, "URL of the LLM provider's API, e.g. an OpenAI compatible server (overrides OPENAI_ENDPOINT, OLLAMA_HOST, etc.)")
+	f.StringVar(&opt.Profile, "profile", opt.Profile, "name of a profile of provider and sandbox settings from the configuration file")
 	f.BoolVar(&opt.SkipVerifySSL, "skip-verify-ssl", opt.SkipVerifySSL, "skip verifying the SSL certificate of the LLM provider")
+	f.StringVar(&opt.Proxy, "proxy", opt.Proxy, "proxy URL to use for requests to the LLM provider (defaults to HTTPS_PROXY/HTTP_PROXY)")
+	f.StringVar(&opt.CABundle, "ca-bundle", opt.CABundle, "path to a PEM file with additional CA certificates to trust for the LLM provider")
+	f.StringVar(&opt.ClientCert, "client-cert", opt.ClientCert, "path to a PEM client certificate for mutual TLS with the LLM provider")
+	f.StringVar(&opt.ClientKey, "client-key", opt.ClientKey, "path to the PEM private key for --client-cert")
 	f.BoolVar(&opt.ShowToolOutput, "show-tool-output", opt.ShowToolOutput, "show tool output in the terminal UI")
 
+	f.StringVar(&opt.PrometheusURL, "prometheus-url", opt.PrometheusURL, "Prometheus endpoint to query metrics from with the promql_query tool, e.g. http://prometheus.monitoring:9090 (disabled if empty)")
+	f.BoolVar(&opt.KyvernoTools, "kyverno-tools", opt.KyvernoTools, "enable tools to list Kyverno policies, read policy reports and explain policy violations")
+	f.BoolVar(&opt.SchemaLookup, "schema-lookup", opt.SchemaLookup, "enable the schema_lookup tool describing the fields of resource types, including CRDs, and list the custom resources of the cluster in the system prompt")
+	f.DurationVar(&opt.SchemaCacheTTL, "schema-cache-ttl", opt.SchemaCacheTTL, "how long the API resources and CRD schemas of the cluster are cached")
+	f.IntVar(&opt.MaxToolOutputBytes, "max-tool-output-bytes", opt.MaxToolOutputBytes, "truncate tool outputs larger than this many bytes before sending them to the LLM (0 disables truncation)")
+	f.IntVar(&opt.PruneToolOutputsAfter, "prune-tool-outputs-after", opt.PruneToolOutputsAfter, "replace the large tool outputs of the queries before the last N in the history sent to the LLM with a one-line summary; the session keeps them in full (0 disables pruning)")
+	f.IntVar(&opt.PruneToolOutputBytes, "prune-tool-output-bytes", opt.PruneToolOutputBytes, "size above which old tool outputs are pruned, see --prune-tool-outputs-after (0 for 4096)")
+	f.StringToStringVar(&opt.HistorySummarizers, "history-summarizers", opt.HistorySummarizers, "summarizers of the large messages of histories replayed to the LLM, per message type or default: llm, extractive or none, e.g. tool-call-response=llm,default=extractive")
+	f.IntVar(&opt.HistoryMaxBytes, "history-max-bytes", opt.HistoryMaxBytes, "size above which the oldest messages of a history replayed to the LLM are dropped, once the large ones are summarized (0 means no limit)")
+	f.StringVar(&opt.SummarizerModel, "summarizer-model", opt.SummarizerModel, "model of the llm history summarizers; without one, they keep the first, last and error lines of messages")
+	f.StringVar(&opt.ToolVerbosity, "tool-verbosity", opt.ToolVerbosity, "documentation of the tools sent with every request: full, compact (parameters shared by several tools documented once) or minimal (first sentence of the tool descriptions only), to save tokens")
+	f.DurationVar(&opt.ToolTimeout, "tool-timeout", opt.ToolTimeout, "maximum time a tool call may run before it is stopped (0 disables the limit)")
+	f.StringToStringVar(&opt.ToolTimeouts, "tool-timeouts", opt.ToolTimeouts, "per-tool timeouts overriding --tool-timeout, e.g. bash=10m,kubectl=2m")
+	f.IntVar(&opt.MaxExecOutputBytes, "max-exec-output-bytes", opt.MaxExecOutputBytes, "maximum bytes of stdout and stderr kept from a command; the rest is discarded with a truncation marker (0 disables the limit)")
+	f.BoolVar(&opt.RedactSecrets, "redact-secrets", opt.RedactSecrets, "mask the data of Kubernetes Secrets, tokens and other credentials in tool outputs and user input before sending them to the LLM")
+	f.StringArrayVar(&opt.RedactPatterns, "redact-pattern", opt.RedactPatterns, "regular expression of additional content to mask; if it has a capture group, only the group is masked")
+	f.StringArrayVar(&opt.ContentFilters, "content-filter", opt.ContentFilters, "webhook URL or shell command transforming tool outputs and user input before they are sent to the LLM, e.g. to strip pod IPs; content that fails to be filtered is not sent")
+	f.StringVar(&opt.ModelRoutingRules, "model-routing-rules", opt.ModelRoutingRules, "path to a YAML file of rules picking the model of each query from its intent and length, e.g. a small model for summaries and a large one for fixes")
+	f.StringVar(&opt.FallbackModel, "fallback-model", opt.FallbackModel, "model used if the provider doesn't know the configured model; if empty, the available model whose name is the closest is used")
+	f.BoolVar(&opt.Watch, "watch", opt.Watch, "evaluate the query over and over, every --watch-interval and on changes of --watch-resource, and notify when the answer changes")
+	f.DurationVar(&opt.WatchInterval, "watch-interval", opt.WatchInterval, "time between evaluations of the watched query, 0 to only evaluate on resource changes")
+	f.StringArrayVar(&opt.WatchResources, "watch-resource", opt.WatchResources, "resource whose changes trigger an evaluation of the watched query, e.g. pods or deployments.apps")
+	f.StringArrayVar(&opt.WatchWebhooks, "watch-webhook", opt.WatchWebhooks, "webhook the changes of the watched answer are posted to; a URL, optionally prefixed with its format: json: (default), slack: or teams:")
+	f.BoolVar(&opt.WatchDesktopNotify, "watch-desktop-notify", opt.WatchDesktopNotify, "show the changes of the watched answer as desktop notifications")
+	f.BoolVar(&opt.WatchExitOnChange, "watch-exit-on-change", opt.WatchExitOnChange, fmt.Sprintf("stop watching when the answer changes, with exit code %d", watchChangedExitCode))
+	f.StringVar(&opt.RunbooksDir, "runbooks-dir", opt.RunbooksDir, "directory of YAML runbooks, named sequences of prompts run with `run <runbook>`; ignored if it doesn't exist")
+	f.StringVar(&opt.UserProfilePath, "user-profile-path", opt.UserProfilePath, "path of the long-term user profile, facts about your environment proposed by the agent and accepted with `profile accept`, included in every session, e.g. ~/.config/kubectl-ai/profile.yaml; disabled by default, and not available with the web UI")
+	f.StringVar(&opt.DocsDir, "docs-dir", opt.DocsDir, "directory of Markdown runbooks and docs; the excerpts relevant to each query are retrieved with embeddings and sent with it, and cited in the answer")
+	f.StringVar(&opt.DocsIndexPath, "docs-index-path", opt.DocsIndexPath, "path of the index of the embeddings of --docs-dir, updated at startup")
+	f.IntVar(&opt.DocsTopK, "docs-top-k", opt.DocsTopK, "maximum number of excerpts of --docs-dir sent with a query")
+	f.Float64Var(&opt.DocsMinScore, "docs-min-score", opt.DocsMinScore, "minimum similarity (between -1 and 1) of the excerpts of --docs-dir sent with a query")
+	f.StringVar(&opt.EmbeddingModel, "embedding-model", opt.EmbeddingModel, "model computing the embeddings of --docs-dir; defaults to the embedding model of the provider")
+	f.BoolVar(&opt.Delegation, "delegation", opt.Delegation, "enable a delegate tool that runs focused investigations (e.g. networking, storage) in parallel read-only sub-agents")
+	f.IntVar(&opt.SubAgentMaxIterations, "sub-agent-max-iterations", opt.SubAgentMaxIterations, "maximum number of iterations of a sub-agent started by the delegate tool")
+	f.IntVar(&opt.MaxParallelToolCalls, "max-parallel-tool-calls", opt.MaxParallelToolCalls, "maximum number of read-only tool calls requested in a single turn to run concurrently (1 runs them serially); calls that may modify resources always run alone")
+	f.BoolVar(&opt.AutoNameSessions, "auto-name-sessions", opt.AutoNameSessions, "name sessions using the LLM after the first couple of exchanges")
+	f.DurationVar(&opt.CompletionCacheTTL, "completion-cache-ttl", opt.CompletionCacheTTL, "cache single-prompt completions (e.g. session names) for this long, to avoid paying for identical requests (0 disables the cache)")
+	f.IntVar(&opt.CompletionCacheSize, "completion-cache-size", opt.CompletionCacheSize, "maximum number of cached completions")
+	f.Int64Var(&opt.Seed, "seed", opt.Seed, "seed for deterministic sampling, for the providers supporting it (Gemini, Vertex AI, OpenAI, Azure OpenAI, Grok, Ollama, llama.cpp and Cohere models on Bedrock); 0 leaves sampling random")
+	f.IntVar(&opt.MaxStreamLineBytes, "max-stream-line-bytes", opt.MaxStreamLineBytes, "maximum size of a line of the responses streamed by the LLM provider, e.g. an event with large tool call arguments (0 for 16 MiB, at most 512 KB with Ollama)")
+	f.StringVar(&opt.AWSProfile, "aws-profile", opt.AWSProfile, "AWS shared config profile of the bedrock provider, e.g. an AWS SSO profile (overrides BEDROCK_AWS_PROFILE)")
+	f.StringVar(&opt.AWSRegion, "aws-region", opt.AWSRegion, "AWS region of the bedrock provider (overrides BEDROCK_AWS_REGION)")
+	f.StringVar(&opt.AWSRoleARN, "aws-role-arn", opt.AWSRoleARN, "ARN of an AWS role the bedrock provider assumes (overrides BEDROCK_ROLE_ARN); use --llm-endpoint for VPC endpoints")
+	f.StringVar(&opt.AWSRoleExternalID, "aws-role-external-id", opt.AWSRoleExternalID, "external ID required to assume --aws-role-arn (overrides BEDROCK_ROLE_EXTERNAL_ID)")
+	f.BoolVar(&opt.SearchGrounding, "search-grounding", opt.SearchGrounding, "add a web_search tool answering questions with Google Search, for the providers supporting it (Gemini, Vertex AI)")
+	f.BoolVar(&opt.ClusterContext, "cluster-context", opt.ClusterContext, "inject a summary of the cluster (version, nodes, namespaces, CRDs) into the system prompt")
+	f.DurationVar(&opt.ClusterContextTTL, "cluster-context-ttl", opt.ClusterContextTTL, "how long the cluster summary is reused before it is refreshed")
+
 	f.StringVar(&opt.Sandbox, "sandbox", opt.Sandbox, "execute tools in a sandbox environment (k8s, seatbelt)")
 	f.StringVar(&opt.SandboxImage, "sandbox-image", opt.SandboxImage, "container image to use for the sandbox")
+	f.BoolVar(&opt.SandboxUnrestricted, "sandbox-unrestricted", opt.SandboxUnrestricted, "run the k8s sandbox pod without the restricted security context (non-root, read-only root filesystem, no capabilities)")
+	f.StringVar(&opt.SandboxRuntimeClass, "sandbox-runtime-class", opt.SandboxRuntimeClass, "RuntimeClass of the k8s sandbox pod, e.g. gvisor or kata")
 
 	f.StringVar(&opt.ResumeSession, "resume-session", opt.ResumeSession, "ID of session to resume (use 'latest' for the most recent session)")
 	f.BoolVar(&opt.ListSessions, "list-sessions", opt.ListSessions, "list all available sessions")
 	f.StringVar(&opt.DeleteSession, "delete-session", opt.DeleteSession, "delete a session by ID")
 	f.BoolVar(&opt.NewSession, "new-session", opt.NewSession, "start a new persistent session")
 	f.StringVar(&opt.SessionBackend, "session-backend", opt.SessionBackend,
-		"session backend to use (memory or filesystem)")
+		"session backend to use (memory, filesystem, or an object storage URL such as gs://bucket/prefix or s3://bucket/prefix)")
 
 	return nil
 }
 
+// llmClientOptions returns the gollm options for connecting to provider. The
+// endpoint only applies to the configured provider.
+func (opt *Options) llmClientOptions(provider string) []gollm.Option {
+	var opts []gollm.Option
+	if opt.Endpoint != "" && provider == opt.ProviderID {
+		opts = append(opts, gollm.WithEndpoint(opt.Endpoint))
+	}
+	if opt.SkipVerifySSL {
+		opts = append(opts, gollm.WithSkipVerifySSL())
+	}
+	if opt.Proxy != "" {
+		opts = append(opts, gollm.WithProxy(opt.Proxy))
+	}
+	if opt.CABundle != "" {
+		opts = append(opts, gollm.WithCABundle(opt.CABundle))
+	}
+	if opt.ClientCert != "" || opt.ClientKey != "" {
+		opts = append(opts, gollm.WithClientCertificate(opt.ClientCert, opt.ClientKey))
+	}
+	if opt.Seed != 0 {
+		opts = append(opts, gollm.WithSeed(opt.Seed))
+	}
+	if opt.SearchGrounding {
+		opts = append(opts, gollm.WithSearchGrounding())
+	}
+	if opt.MaxStreamLineBytes > 0 {
+		opts = append(opts, gollm.WithMaxStreamLineBytes(opt.MaxStreamLineBytes))
+	}
+	if opt.AWSProfile != "" || opt.AWSRegion != "" || opt.AWSRoleARN != "" || opt.AWSRoleExternalID != "" {
+		opts = append(opts, gollm.WithAWSConfig(gollm.AWSConfig{
+			Profile:    opt.AWSProfile,
+			Region:     opt.AWSRegion,
+			RoleARN:    opt.AWSRoleARN,
+			ExternalID: opt.AWSRoleExternalID,
+		}))
+	}
+	return opts
+}
+
+// parseToolTimeouts parses the per-tool timeouts, e.g. {"bash": "10m"}.
+func (opt *Options) parseToolTimeouts() (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(opt.ToolTimeouts))
+	for name, value := range opt.ToolTimeouts {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q for tool %q: %w", value, name, err)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// expandPathPlaceholders replaces {CONFIG} and {HOME} in path with the user
+// config and home directories.
+func expandPathPlaceholders(path string) (string, error) {
+	if strings.Contains(path, "{CONFIG}") {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("getting user config directory: %w", err)
+		}
+		path = strings.ReplaceAll(path, "{CONFIG}", configDir)
+	}
+	if strings.Contains(path, "{HOME}") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("getting user home directory: %w", err)
+		}
+		path = strings.ReplaceAll(path, "{HOME}", homeDir)
+	}
+	return path, nil
+}
+
+// loadDocsIndex indexes the docs of DocsDir, embedding the docs that changed
+// since the index was saved. It returns nil if DocsDir is not set, or if the
+// provider doesn't compute embeddings.
+func (opt *Options) loadDocsIndex(ctx context.Context, newLLM func(context.Context, string) (gollm.Client, error)) (*retrieval.Index, error) {
+	if opt.DocsDir == "" {
+		return nil, nil
+	}
+	docsDir, err := expandPathPlaceholders(opt.DocsDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving docs directory: %w", err)
+	}
+	indexPath, err := expandPathPlaceholders(opt.DocsIndexPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolving docs index path: %w", err)
+	}
+	previous, err := retrieval.LoadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newLLM(ctx, opt.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("creating llm client: %w", err)
+	}
+	defer client.Close()
+
+	indexer := &retrieval.Indexer{
+		Client:   client,
+		Model:    opt.EmbeddingModel,
+		Embedder: opt.ProviderID + "/" + opt.EmbeddingModel,
+	}
+	index, err := indexer.Index(ctx, docsDir, previous)
+	if errors.Is(err, gollm.ErrEmbeddingsUnsupported) {
+		klog.Warningf("Not retrieving docs of %s: %v", docsDir, err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Save(indexPath); err != nil {
+		return nil, err
+	}
+	klog.Infof("Indexed %d chunks of %d docs of %s", len(index.Chunks), len(index.Files), docsDir)
+	return index, nil
+}
+
+// docsRetriever returns the retriever of the excerpts of index relevant to
+// queries, or nil if there is no index.
+func (opt *Options) docsRetriever(client gollm.Client, index *retrieval.Index) *retrieval.Retriever {
+	if index == nil {
+		return nil
+	}
+	return &retrieval.Retriever{
+		Client:   client,
+		Index:    index,
+		Model:    opt.EmbeddingModel,
+		TopK:     opt.DocsTopK,
+		MinScore: opt.DocsMinScore,
+	}
+}
+
 func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	var err error
 
@@ -355,6 +833,10 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	if opt.ExternalTools && !opt.MCPServer {
 		return fmt.Errorf("--external-tools can only be used with --mcp-server")
 	}
+	// The profile would be shared by all the users of the web UI
+	if opt.UserProfilePath != "" && opt.UIType == ui.UITypeWeb {
+		return fmt.Errorf("--user-profile-path can't be used with --ui-type web, the profile would be shared by all its users")
+	}
 
 	// resolve kubeconfig path with priority: flag/env > KUBECONFIG > default path
 	if err = resolveKubeConfigPath(&opt); err != nil {
@@ -394,22 +876,26 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		return fmt.Errorf("failed to resolve query input %w", err)
 	}
 
+	var watchQuery string
+	if opt.Watch {
+		if queryFromCmd == "" {
+			return fmt.Errorf("--watch requires a query, e.g. kubectl-ai --watch \"are any pods crashlooping?\"")
+		}
+		if opt.WatchInterval <= 0 && len(opt.WatchResources) == 0 {
+			return fmt.Errorf("--watch requires a --watch-interval or a --watch-resource")
+		}
+		// The watch UI asks the query, over and over
+		watchQuery, queryFromCmd = queryFromCmd, ""
+		opt.Quiet = false
+	}
+
 	klog.Info("Application started", "pid", os.Getpid())
 
-	var recorder journal.Recorder
-	if opt.TracePath != "" {
-		var fileRecorder journal.Recorder
-		fileRecorder, err = journal.NewFileRecorder(opt.TracePath)
-		if err != nil {
-			return fmt.Errorf("creating trace recorder: %w", err)
-		}
-		defer fileRecorder.Close()
-		recorder = fileRecorder
-	} else {
-		// Ensure we always have a recorder, to avoid nil checks
-		recorder = &journal.LogRecorder{}
-		defer recorder.Close()
+	recorder, err := opt.newRecorder()
+	if err != nil {
+		return err
 	}
+	defer recorder.Close()
 
 	// Initialize session management
 	var session *api.Session
@@ -420,38 +906,179 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 		return fmt.Errorf("failed to create session manager: %w", err)
 	}
 
+	toolTimeouts, err := opt.parseToolTimeouts()
+	if err != nil {
+		return err
+	}
+	toolVerbosity, err := gollm.ParseToolVerbosity(opt.ToolVerbosity)
+	if err != nil {
+		return err
+	}
+
+	historyCompression := compression.Config{
+		Summarizers:     opt.HistorySummarizers,
+		Model:           opt.SummarizerModel,
+		MaxHistoryBytes: opt.HistoryMaxBytes,
+	}
+	if err := historyCompression.Validate(); err != nil {
+		return fmt.Errorf("invalid history summarizers: %w", err)
+	}
+
+	contentFilters, err := tools.NewContentFilters(opt.ContentFilters)
+	if err != nil {
+		return fmt.Errorf("creating content filters: %w", err)
+	}
+
+	systemPromptPath, err := expandPathPlaceholders(opt.SystemPromptPath)
+	if err != nil {
+		return fmt.Errorf("resolving system prompt path: %w", err)
+	}
+
+	userProfilePath, err := expandPathPlaceholders(opt.UserProfilePath)
+	if err != nil {
+		return fmt.Errorf("resolving user profile path: %w", err)
+	}
+
+	var modelRouter *agent.RoutingRules
+	if opt.ModelRoutingRules != "" {
+		path, err := expandPathPlaceholders(opt.ModelRoutingRules)
+		if err != nil {
+			return fmt.Errorf("resolving model routing rules path: %w", err)
+		}
+		modelRouter, err = agent.LoadRoutingRules(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	runbooksDir, err := expandPathPlaceholders(opt.RunbooksDir)
+	if err != nil {
+		return fmt.Errorf("resolving runbooks directory: %w", err)
+	}
+	runbooks, err := agent.LoadRunbooks(runbooksDir)
+	if err != nil {
+		return err
+	}
+
+	// Notify about headless runs, the user isn't watching the terminal
+	var notifier notify.Notifier
+	var uiBaseURL string
+	if opt.Quiet || opt.UIType == ui.UITypeWeb {
+		webhooks, err := newWebhooks(opt.NotifyWebhooks)
+		if err != nil {
+			return err
+		}
+		if len(webhooks) > 0 {
+			notifier = webhooks
+		}
+		if opt.UIType == ui.UITypeWeb {
+			uiBaseURL = opt.uiBaseURL()
+		}
+	}
+
+	// Share the completion cache between agents
+	var completionCache *gollm.CompletionCache
+	if opt.CompletionCacheTTL > 0 {
+		completionCache = gollm.NewCompletionCache(opt.CompletionCacheTTL, opt.CompletionCacheSize)
+	}
+
+	newLLM := func(ctx context.Context, provider string) (gollm.Client, error) {
+		// Record all LLM HTTP traffic (with credentials redacted) to the trace
+		clientOpts := append(opt.llmClientOptions(provider), gollm.WithRecorder(recorder))
+		if completionCache != nil {
+			clientOpts = append(clientOpts, gollm.WithCompletionCache(completionCache))
+		}
+		return gollm.NewClient(ctx, provider, clientOpts...)
+	}
+
+	docsIndex, err := opt.loadDocsIndex(ctx, newLLM)
+	if err != nil {
+		return err
+	}
+
+	// Share the API resources and schemas between agents
+	var schemaCache *tools.SchemaCache
+	if opt.SchemaLookup {
+		schemaCache = tools.NewSchemaCache(opt.SchemaCacheTTL)
+	}
+
 	// Build agentFactory for new agents
 	agentFactory := func(ctx context.Context) (*agent.Agent, error) {
-		var client gollm.Client
-		var err error
-		if opt.SkipVerifySSL {
-			client, err = gollm.NewClient(ctx, opt.ProviderID, gollm.WithSkipVerifySSL())
-		} else {
-			client, err = gollm.NewClient(ctx, opt.ProviderID)
-		}
+		client, err := newLLM(ctx, opt.ProviderID)
 		if err != nil {
 			return nil, fmt.Errorf("creating llm client: %w", err)
 		}
+		var historyCompressor *compression.Compressor
+		if len(opt.HistorySummarizers) > 0 || opt.HistoryMaxBytes > 0 {
+			historyCompressor, err = compression.NewCompressor(client, historyCompression)
+			if err != nil {
+				return nil, err
+			}
+		}
 
 		return &agent.Agent{
-			Model:              opt.ModelID,
-			Provider:           opt.ProviderID,
-			Kubeconfig:         opt.KubeConfigPath,
-			LLM:                client,
-			MaxIterations:      opt.MaxIterations,
-			PromptTemplateFile: opt.PromptTemplateFilePath,
-			ExtraPromptPaths:   opt.ExtraPromptPaths,
-			Tools:              tools.Default(),
-			Recorder:           recorder,
-			RemoveWorkDir:      opt.RemoveWorkDir,
-			SkipPermissions:    opt.SkipPermissions,
-			EnableToolUseShim:  opt.EnableToolUseShim,
-			MCPClientEnabled:   opt.MCPClient,
-			Sandbox:            opt.Sandbox,
-			SandboxImage:       opt.SandboxImage,
-			SessionBackend:     opt.SessionBackend,
-			RunOnce:            opt.Quiet,
-			InitialQuery:       queryFromCmd,
+			Model:                 opt.ModelID,
+			Provider:              opt.ProviderID,
+			Kubeconfig:            opt.KubeConfigPath,
+			LLM:                   client,
+			NewLLM:                newLLM,
+			MaxIterations:         opt.MaxIterations,
+			MaxContinuations:      opt.MaxContinuations,
+			MaxQueryDuration:      opt.MaxQueryDuration,
+			MaxQueryTokens:        opt.MaxQueryTokens,
+			PromptTemplateFile:    opt.PromptTemplateFilePath,
+			ExtraPromptPaths:      opt.ExtraPromptPaths,
+			SystemPromptFile:      systemPromptPath,
+			PromptVars:            opt.PromptVars,
+			Tools:                 tools.Default(),
+			Recorder:              recorder,
+			TracePath:             opt.TracePath,
+			Version:               version,
+			CostEstimator:         gollm.NewCostEstimator(opt.Pricing),
+			RemoveWorkDir:         opt.RemoveWorkDir,
+			SkipPermissions:       opt.SkipPermissions,
+			ReadOnly:              opt.ReadOnly,
+			EnableToolUseShim:     opt.EnableToolUseShim,
+			MCPClientEnabled:      opt.MCPClient,
+			Contexts:              opt.Contexts,
+			PrometheusURL:         opt.PrometheusURL,
+			EnableKyvernoTools:    opt.KyvernoTools,
+			EnableSchemaLookup:    opt.SchemaLookup,
+			SchemaCache:           schemaCache,
+			EnableDelegation:      opt.Delegation,
+			EnableWebSearch:       opt.SearchGrounding,
+			SubAgentIterations:    opt.SubAgentMaxIterations,
+			MaxToolOutputBytes:    opt.MaxToolOutputBytes,
+			ToolVerbosity:         toolVerbosity,
+			PruneToolOutputsAfter: opt.PruneToolOutputsAfter,
+			PruneToolOutputBytes:  opt.PruneToolOutputBytes,
+			HistoryCompressor:     historyCompressor,
+			Retriever:             opt.docsRetriever(client, docsIndex),
+			ToolTimeout:           opt.ToolTimeout,
+			ToolTimeouts:          toolTimeouts,
+			MaxExecOutputBytes:    opt.MaxExecOutputBytes,
+			StreamToolOutput:      opt.UIType == ui.UITypeWeb || opt.UIType == ui.UITypeTUI,
+			DisableLocalFiles:     opt.UIType == ui.UITypeWeb,
+			RedactSecrets:         opt.RedactSecrets,
+			RedactPatterns:        opt.RedactPatterns,
+			ContentFilters:        contentFilters,
+			ModelRouter:           modelRouter,
+			FallbackModel:         opt.FallbackModel,
+			Runbooks:              runbooks,
+			UserProfilePath:       userProfilePath,
+			Notifier:              notifier,
+			UIBaseURL:             uiBaseURL,
+			MaxParallelToolCalls:  opt.MaxParallelToolCalls,
+			AutoNameSessions:      opt.AutoNameSessions,
+			EnableClusterContext:  opt.ClusterContext,
+			ClusterContextTTL:     opt.ClusterContextTTL,
+			Sandbox:               opt.Sandbox,
+			SandboxImage:          opt.SandboxImage,
+			SandboxUnrestricted:   opt.SandboxUnrestricted,
+			SandboxRuntimeClass:   opt.SandboxRuntimeClass,
+			SessionBackend:        opt.SessionBackend,
+			RunOnce:               opt.Quiet,
+			InitialQuery:          queryFromCmd,
 		}, nil
 	}
 
@@ -510,26 +1137,43 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	}
 
 	var userInterface ui.UI
-	switch opt.UIType {
-	case ui.UITypeTerminal:
+	switch {
+	case opt.Watch:
+		watchOpts, err := opt.watchOptions(watchQuery)
+		if err != nil {
+			return err
+		}
+		userInterface = ui.NewWatchUI(defaultAgent, watchOpts)
+	case opt.UIType == ui.UITypeTerminal:
 		// since stdin is already consumed, we use TTY for taking input from user
 		useTTYForInput := hasInputData
 		userInterface, err = ui.NewTerminalUI(defaultAgent, useTTYForInput, opt.ShowToolOutput, recorder)
 		if err != nil {
 			return fmt.Errorf("creating terminal UI: %w", err)
 		}
-	case ui.UITypeWeb:
+	case opt.UIType == ui.UITypeWeb:
+		agentManager.SetLimits(opt.MaxAgents, opt.AgentIdleTTL)
 		userInterface, err = html.NewHTMLUserInterface(agentManager, sessionManager, opt.ModelID, opt.ProviderID, opt.UIListenAddress, recorder)
 		if err != nil {
 			return fmt.Errorf("creating web UI: %w", err)
 		}
-	case ui.UITypeTUI:
+	case opt.UIType == ui.UITypeTUI:
 		userInterface = ui.NewTUI(defaultAgent)
+	case opt.UIType == ui.UITypePlain:
+		userInterface = ui.NewPlainUI(defaultAgent, hasInputData, opt.ShowToolOutput)
 	default:
 		return fmt.Errorf("ui-type mode %q is not known", opt.UIType)
 	}
 
 	err = userInterface.Run(ctx)
+	if ctx.Err() != nil {
+		// Interrupted by a signal: stop the agents, saving their sessions,
+		// before telling the user how to pick up where they left off
+		agentManager.Close()
+		if opt.SessionBackend != "memory" && opt.UIType != ui.UITypeWeb {
+			fmt.Fprintf(os.Stderr, "Session saved. Resume it with: %s\n", resumeCommand(opt, defaultAgent.Session.ID))
+		}
+	}
 	if err != nil && !errors.Is(err, context.Canceled) {
 		return fmt.Errorf("running UI: %w", err)
 	}
@@ -537,6 +1181,68 @@ func RunRootCommand(ctx context.Context, opt Options, args []string) error {
 	return nil
 }
 
+// watchChangedExitCode is the exit code when --watch-exit-on-change stops
+// watching.
+const watchChangedExitCode = 3
+
+// watchOptions returns the options of the watch UI evaluating query.
+func (opt *Options) watchOptions(query string) (ui.WatchOptions, error) {
+	watchOpts := ui.WatchOptions{
+		Query:        query,
+		Interval:     opt.WatchInterval,
+		Resources:    opt.WatchResources,
+		ExitOnChange: opt.WatchExitOnChange,
+	}
+	notifiers, err := newWebhooks(opt.WatchWebhooks)
+	if err != nil {
+		return watchOpts, err
+	}
+	if opt.WatchDesktopNotify {
+		notifiers = append(notifiers, notify.Desktop{})
+	}
+	if len(notifiers) > 0 {
+		watchOpts.Notifier = notifiers
+	}
+	return watchOpts, nil
+}
+
+// newWebhooks parses webhooks given as [format:]URL.
+func newWebhooks(specs []string) (notify.Multi, error) {
+	var webhooks notify.Multi
+	for _, spec := range specs {
+		webhook, err := notify.ParseWebhook(spec)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// uiBaseURL returns the URL users reach the web UI at.
+func (opt *Options) uiBaseURL() string {
+	if opt.UIBaseURL != "" {
+		return opt.UIBaseURL
+	}
+	host, port, err := net.SplitHostPort(opt.UIListenAddress)
+	if err != nil {
+		return "http://" + opt.UIListenAddress
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// resumeCommand returns the command resuming the session with the given ID.
+func resumeCommand(opt Options, sessionID string) string {
+	command := "kubectl-ai --resume-session " + sessionID
+	if opt.SessionBackend != "filesystem" {
+		command += " --session-backend " + opt.SessionBackend
+	}
+	return command
+}
+
 func handleCustomTools(toolConfigPaths []string) error {
 	// resolve tool config paths, and then load and register custom tools from config files and dirs
 	for _, path := range toolConfigPaths {
@@ -698,6 +1404,7 @@ func startMCPServer(ctx context.Context, opt Options) error {
 	if err != nil {
 		return fmt.Errorf("creating mcp server: %w", err)
 	}
+	mcpServer.readOnly = opt.ReadOnly
 	return mcpServer.Serve(ctx)
 }
 
@@ -719,16 +1426,17 @@ func handleListSessions(opt Options) error {
 	}
 
 	fmt.Println("Available sessions:")
-	fmt.Println("ID\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider")
-	fmt.Println("--\t\t-------\t\t\t-------------\t\t-----\t\t--------")
+	fmt.Println("ID\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider\tTags")
+	fmt.Println("--\t\t-------\t\t\t-------------\t\t-----\t\t--------\t----")
 
 	for _, session := range sessionList {
-		fmt.Printf("%s\t%s\t%s\t%s\t%s\n",
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n",
 			session.ID,
 			session.CreatedAt.Format("2006-01-02 15:04:05"),
 			session.LastModified.Format("2006-01-02 15:04:05"),
 			session.ModelID,
-			session.ProviderID)
+			session.ProviderID,
+			strings.Join(session.Tags, ","))
 	}
 
 	return nil
@@ -34,6 +34,7 @@ type kubectlMCPServer struct {
 	mcpManager    *mcp.Manager // Add MCP manager for external tool calls
 	mcpServerMode string       // Server mode (e.g., "streamable-http", "stdio")
 	httpPort      int          // Port for HTTP-based server modes
+	readOnly      bool         // Reject built-in tool calls that could modify resources
 }
 
 func newKubectlMCPServer(ctx context.Context, kubectlConfig string, tools tools.Tools, workDir string, exposeExternalTools bool, serverMode string, httpPort int) (*kubectlMCPServer, error) {
@@ -222,6 +223,20 @@ func (s *kubectlMCPServer) handleBuiltinToolCall(ctx context.Context, request mc
 		}, nil
 	}
 
+	if s.readOnly {
+		if err := tools.CheckReadOnly(tool, args); err != nil {
+			return &mcpgo.CallToolResult{
+				IsError: true,
+				Content: []mcpgo.Content{
+					mcpgo.TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("server is running in read-only mode: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
 	// Execute the built-in tool
 	result, err := tool.Run(ctx, args)
 	if err != nil {
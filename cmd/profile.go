@@ -0,0 +1,75 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Profile is a named set of LLM provider and sandbox settings, defined in the
+// configuration file and selected with --profile. Unset fields keep the
+// values of the top-level configuration.
+type Profile struct {
+	ProviderID    string `json:"llmProvider,omitempty"`
+	ModelID       string `json:"model,omitempty"`
+	Endpoint      string `json:"endpoint,omitempty"`
+	SkipVerifySSL *bool  `json:"skipVerifySSL,omitempty"`
+
+	Sandbox             string `json:"sandbox,omitempty"`
+	SandboxImage        string `json:"sandboxImage,omitempty"`
+	SandboxUnrestricted *bool  `json:"sandboxUnrestricted,omitempty"`
+	SandboxRuntimeClass string `json:"sandboxRuntimeClass,omitempty"`
+}
+
+// applyProfile applies the settings of the selected profile, if any. Settings
+// given with command line flags take precedence over the profile.
+func (opt *Options) applyProfile(flags *pflag.FlagSet) error {
+	if opt.Profile == "" {
+		return nil
+	}
+	profile, ok := opt.Profiles[opt.Profile]
+	if !ok {
+		var names []string
+		for name := range opt.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("profile %q is not defined in the configuration file, available profiles: [%s]", opt.Profile, strings.Join(names, ", "))
+	}
+
+	setString := func(flag string, dst *string, value string) {
+		if value != "" && !flags.Changed(flag) {
+			*dst = value
+		}
+	}
+	setBool := func(flag string, dst *bool, value *bool) {
+		if value != nil && !flags.Changed(flag) {
+			*dst = *value
+		}
+	}
+	setString("llm-provider", &opt.ProviderID, profile.ProviderID)
+	setString("model", &opt.ModelID, profile.ModelID)
+	setString("llm-endpoint", &opt.Endpoint, profile.Endpoint)
+	setBool("skip-verify-ssl", &opt.SkipVerifySSL, profile.SkipVerifySSL)
+	setString("sandbox", &opt.Sandbox, profile.Sandbox)
+	setString("sandbox-image", &opt.SandboxImage, profile.SandboxImage)
+	setBool("sandbox-unrestricted", &opt.SandboxUnrestricted, profile.SandboxUnrestricted)
+	setString("sandbox-runtime-class", &opt.SandboxRuntimeClass, profile.SandboxRuntimeClass)
+	return nil
+}
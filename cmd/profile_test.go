@@ -0,0 +1,114 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const profilesConfig = `
+model: gemini-2.5-pro
+profile: local
+profiles:
+  local:
+    llmProvider: ollama
+    model: qwen3:8b
+    endpoint: http://gpu-box:11434
+  prod:
+    llmProvider: vertexai
+    sandbox: k8s
+    sandboxUnrestricted: false
+    skipVerifySSL: true
+`
+
+func TestApplyProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    func(*Options) string
+		wantErr string
+	}{
+		{
+			name: "default profile from the configuration",
+			want: func(o *Options) string {
+				if o.ProviderID != "ollama" || o.ModelID != "qwen3:8b" || o.Endpoint != "http://gpu-box:11434" {
+					return "expected the settings of the local profile"
+				}
+				return ""
+			},
+		},
+		{
+			name: "profile selected with the flag",
+			args: []string{"--profile=prod"},
+			want: func(o *Options) string {
+				if o.ProviderID != "vertexai" || o.Sandbox != "k8s" || !o.SkipVerifySSL {
+					return "expected the settings of the prod profile"
+				}
+				if o.ModelID != "gemini-2.5-pro" {
+					return "expected the model of the top-level configuration to be kept"
+				}
+				return ""
+			},
+		},
+		{
+			name: "flags take precedence",
+			args: []string{"--model=llama3"},
+			want: func(o *Options) string {
+				if o.ModelID != "llama3" || o.ProviderID != "ollama" {
+					return "expected the model flag to override the profile"
+				}
+				return ""
+			},
+		},
+		{
+			name:    "unknown profile",
+			args:    []string{"--profile=staging"},
+			wantErr: `profile "staging" is not defined in the configuration file, available profiles: [local, prod]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opt Options
+			opt.InitDefaults()
+			if err := opt.LoadConfiguration([]byte(profilesConfig)); err != nil {
+				t.Fatalf("LoadConfiguration() error = %v", err)
+			}
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			if err := opt.bindCLIFlags(flags); err != nil {
+				t.Fatalf("bindCLIFlags() error = %v", err)
+			}
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatalf("parsing flags: %v", err)
+			}
+
+			err := opt.applyProfile(flags)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyProfile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyProfile() error = %v", err)
+			}
+			if msg := tt.want(&opt); msg != "" {
+				t.Error(msg)
+			}
+		})
+	}
+}
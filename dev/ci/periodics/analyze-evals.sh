@@ -15,15 +15,7 @@ if [[ -z "${OUTPUT_DIR:-}" ]]; then
 fi
 
 BINDIR="${REPO_ROOT}/.build/bin"
-mkdir -p "${BINDIR}"
-
-K8S_AI_BENCH_SRC="${REPO_ROOT}/.build/k8s-ai-bench-src"
-rm -rf "${K8S_AI_BENCH_SRC}"
-git clone https://github.com/gke-labs/k8s-ai-bench "${K8S_AI_BENCH_SRC}"
-cd "${K8S_AI_BENCH_SRC}"
-GOWORK=off go build -o "${BINDIR}/k8s-ai-bench" .
-
-cd "${REPO_ROOT}"
+"${REPO_ROOT}/dev/ci/periodics/build-k8s-ai-bench.sh"
 
 # Pass --show-failures flag to the analyze command if it's set
 ANALYZE_ARGS=""
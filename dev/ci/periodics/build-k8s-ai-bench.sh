@@ -0,0 +1,22 @@
+#!/bin/bash
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+set -x
+
+# Builds k8s-ai-bench in .build/bin, and leaves its tasks in
+# .build/k8s-ai-bench-src/tasks.
+
+REPO_ROOT="$(git rev-parse --show-toplevel)"
+cd ${REPO_ROOT}
+
+BINDIR="${REPO_ROOT}/.build/bin"
+mkdir -p "${BINDIR}"
+
+K8S_AI_BENCH_SRC="${REPO_ROOT}/.build/k8s-ai-bench-src"
+rm -rf "${K8S_AI_BENCH_SRC}"
+git clone https://github.com/gke-labs/k8s-ai-bench "${K8S_AI_BENCH_SRC}"
+cd "${K8S_AI_BENCH_SRC}"
+GOWORK=off go build -o "${BINDIR}/k8s-ai-bench" .
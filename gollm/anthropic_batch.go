@@ -0,0 +1,317 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	anthropicDefaultEndpoint  = "https://api.anthropic.com"
+	anthropicAPIVersion       = "2023-06-01"
+	anthropicDefaultModel     = "claude-sonnet-4-20250514"
+	anthropicDefaultMaxTokens = 4096
+)
+
+// AnthropicBatchClient is a BatchClient for the Message Batches API of
+// Anthropic. It is configured with ANTHROPIC_API_KEY, and ANTHROPIC_BASE_URL
+// unless ClientOptions.Endpoint is set.
+type AnthropicBatchClient struct {
+	baseURL    *url.URL
+	apiKey     string
+	httpClient *http.Client
+	// maxLineBytes is the maximum size of a line of batch results.
+	maxLineBytes int
+}
+
+var _ BatchClient = &AnthropicBatchClient{}
+
+// NewAnthropicBatchClient creates a new client for the Message Batches API.
+func NewAnthropicBatchClient(ctx context.Context, opts ClientOptions) (*AnthropicBatchClient, error) {
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("ANTHROPIC_API_KEY environment variable not set")
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("ANTHROPIC_BASE_URL")
+	}
+	if endpoint == "" {
+		endpoint = anthropicDefaultEndpoint
+	}
+	baseURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint %q: %w", endpoint, err)
+	}
+
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+	return &AnthropicBatchClient{
+		baseURL:      baseURL,
+		apiKey:       apiKey,
+		httpClient:   httpClient,
+		maxLineBytes: opts.MaxStreamLineBytes,
+	}, nil
+}
+
+func (c *AnthropicBatchClient) Close() error {
+	return nil
+}
+
+type anthropicBatchRequest struct {
+	CustomID string                 `json:"custom_id"`
+	Params   anthropicMessageParams `json:"params"`
+}
+
+type anthropicMessageParams struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicBatch struct {
+	ID               string `json:"id"`
+	ProcessingStatus string `json:"processing_status"`
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EndedAt    *time.Time `json:"ended_at"`
+	ResultsURL string     `json:"results_url"`
+}
+
+func (b *anthropicBatch) job() *BatchJob {
+	job := &BatchJob{
+		ID:         b.ID,
+		Status:     BatchStatus(b.ProcessingStatus),
+		Processing: b.RequestCounts.Processing,
+		Succeeded:  b.RequestCounts.Succeeded,
+		Failed:     b.RequestCounts.Errored + b.RequestCounts.Canceled + b.RequestCounts.Expired,
+		CreatedAt:  b.CreatedAt,
+	}
+	if b.EndedAt != nil {
+		job.EndedAt = *b.EndedAt
+	}
+	return job
+}
+
+// anthropicBatchResult is a line of the results of a batch.
+type anthropicBatchResult struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string `json:"type"`
+		Message struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+			Usage struct {
+				InputTokens  int64 `json:"input_tokens"`
+				OutputTokens int64 `json:"output_tokens"`
+			} `json:"usage"`
+		} `json:"message"`
+		Error struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"error"`
+	} `json:"result"`
+}
+
+func (r *anthropicBatchResult) result() *BatchResult {
+	result := &BatchResult{ID: r.CustomID}
+	switch r.Result.Type {
+	case "succeeded":
+		var text strings.Builder
+		for _, block := range r.Result.Message.Content {
+			if block.Type == "text" {
+				text.WriteString(block.Text)
+			}
+		}
+		result.Text = text.String()
+		usage := r.Result.Message.Usage
+		result.Usage = &Usage{
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+			TotalTokens:  usage.InputTokens + usage.OutputTokens,
+		}
+	case "errored":
+		result.Err = fmt.Errorf("request errored: %s: %s", r.Result.Error.Error.Type, r.Result.Error.Error.Message)
+	default:
+		// canceled or expired
+		result.Err = fmt.Errorf("request %s", r.Result.Type)
+	}
+	return result
+}
+
+// SubmitBatch implements BatchClient.
+func (c *AnthropicBatchClient) SubmitBatch(ctx context.Context, requests []*BatchRequest) (*BatchJob, error) {
+	if len(requests) == 0 {
+		return nil, errors.New("no requests provided")
+	}
+	body := struct {
+		Requests []anthropicBatchRequest `json:"requests"`
+	}{}
+	for i, request := range requests {
+		id := request.ID
+		if id == "" {
+			id = fmt.Sprintf("request-%d", i+1)
+		}
+		params := anthropicMessageParams{
+			Model:     request.Model,
+			MaxTokens: request.MaxTokens,
+			System:    request.SystemPrompt,
+			Messages:  []anthropicMessage{{Role: "user", Content: request.Prompt}},
+		}
+		if params.Model == "" {
+			params.Model = anthropicDefaultModel
+		}
+		if params.MaxTokens == 0 {
+			params.MaxTokens = anthropicDefaultMaxTokens
+		}
+		body.Requests = append(body.Requests, anthropicBatchRequest{CustomID: id, Params: params})
+	}
+
+	var batch anthropicBatch
+	if err := c.doRequest(ctx, http.MethodPost, c.baseURL.JoinPath("v1/messages/batches").String(), body, &batch); err != nil {
+		return nil, fmt.Errorf("submitting batch: %w", err)
+	}
+	klog.Infof("submitted batch %s of %d requests", batch.ID, len(requests))
+	return batch.job(), nil
+}
+
+// GetBatch implements BatchClient.
+func (c *AnthropicBatchClient) GetBatch(ctx context.Context, id string) (*BatchJob, error) {
+	batch, err := c.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return batch.job(), nil
+}
+
+func (c *AnthropicBatchClient) getBatch(ctx context.Context, id string) (*anthropicBatch, error) {
+	var batch anthropicBatch
+	if err := c.doRequest(ctx, http.MethodGet, c.baseURL.JoinPath("v1/messages/batches", id).String(), nil, &batch); err != nil {
+		return nil, fmt.Errorf("getting batch %s: %w", id, err)
+	}
+	return &batch, nil
+}
+
+// BatchResults implements BatchClient.
+func (c *AnthropicBatchClient) BatchResults(ctx context.Context, id string) ([]*BatchResult, error) {
+	batch, err := c.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.ProcessingStatus != string(BatchStatusEnded) || batch.ResultsURL == "" {
+		return nil, fmt.Errorf("batch %s has not ended, it is %s", id, batch.ProcessingStatus)
+	}
+
+	httpResponse, err := c.send(ctx, http.MethodGet, batch.ResultsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting results of batch %s: %w", id, err)
+	}
+	defer httpResponse.Body.Close()
+
+	// Results are JSON lines, one per request, in no particular order
+	var results []*BatchResult
+	lines := newJSONLReader(httpResponse.Body, c.maxLineBytes)
+	for {
+		var r anthropicBatchResult
+		err := lines.next(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading results of batch %s: %w", id, err)
+		}
+		results = append(results, r.result())
+	}
+	return results, nil
+}
+
+func (c *AnthropicBatchClient) doRequest(ctx context.Context, httpMethod, u string, req any, response any) error {
+	var body io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("building json body: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	httpResponse, err := c.send(ctx, httpMethod, u, body)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if err := json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
+		return fmt.Errorf("unmarshalling json response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request to the API, and returns the response if it succeeded.
+func (c *AnthropicBatchClient) send(ctx context.Context, httpMethod, u string, body io.Reader) (*http.Response, error) {
+	klog.V(2).Infof("sending %s request to %v", httpMethod, u)
+	httpRequest, err := http.NewRequestWithContext(ctx, httpMethod, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("building http request: %w", err)
+	}
+	httpRequest.Header.Set("x-api-key", c.apiKey)
+	httpRequest.Header.Set("anthropic-version", anthropicAPIVersion)
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("performing http request: %w", err)
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		defer httpResponse.Body.Close()
+		b, _ := io.ReadAll(httpResponse.Body)
+		return nil, &APIError{
+			StatusCode: httpResponse.StatusCode,
+			Message:    strings.TrimSpace(string(b)),
+		}
+	}
+	return httpResponse, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnthropicBatchClient(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+
+	polls := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			http.Error(w, `{"type":"error","error":{"type":"authentication_error"}}`, http.StatusUnauthorized)
+			return
+		}
+		batch := func(status string) string {
+			return fmt.Sprintf(`{"id":"msgbatch_1","processing_status":%q,"request_counts":{"processing":%d,"succeeded":1,"errored":1,"canceled":0,"expired":1},"created_at":"2025-06-01T10:00:00Z","results_url":%q}`,
+				status, map[bool]int{true: 3}[status == "in_progress"], server.URL+"/v1/messages/batches/msgbatch_1/results")
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/batches":
+			var body struct {
+				Requests []anthropicBatchRequest `json:"requests"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			if len(body.Requests) != 3 || body.Requests[0].CustomID != "summary-a" || body.Requests[1].CustomID != "request-2" {
+				t.Errorf("unexpected requests %+v", body.Requests)
+			}
+			if p := body.Requests[1].Params; p.Model != anthropicDefaultModel || p.MaxTokens != anthropicDefaultMaxTokens || p.System != "Summarize." || p.Messages[0].Content != "session b" {
+				t.Errorf("unexpected params %+v", p)
+			}
+			fmt.Fprint(w, batch("in_progress"))
+		case r.URL.Path == "/v1/messages/batches/msgbatch_1":
+			polls++
+			if polls < 2 {
+				fmt.Fprint(w, batch("in_progress"))
+			} else {
+				fmt.Fprint(w, batch("ended"))
+			}
+		case r.URL.Path == "/v1/messages/batches/msgbatch_1/results":
+			fmt.Fprintln(w, `{"custom_id":"request-2","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"bad model"}}}}`)
+			fmt.Fprintln(w, `{"custom_id":"summary-a","result":{"type":"succeeded","message":{"content":[{"type":"text","text":"All pods "},{"type":"text","text":"are running."}],"usage":{"input_tokens":10,"output_tokens":5}}}}`)
+			fmt.Fprintln(w, `{"custom_id":"request-3","result":{"type":"expired"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := NewAnthropicBatchClient(ctx, ClientOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	job, err := client.SubmitBatch(ctx, []*BatchRequest{
+		{ID: "summary-a", Model: "claude-3-5-haiku-latest", Prompt: "session a", MaxTokens: 100},
+		{SystemPrompt: "Summarize.", Prompt: "session b"},
+		{Prompt: "session c"},
+	})
+	if err != nil {
+		t.Fatalf("submitting batch: %v", err)
+	}
+	if job.ID != "msgbatch_1" || job.Status != BatchStatusInProgress || job.Processing != 3 {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	if _, err := client.BatchResults(ctx, job.ID); err == nil {
+		t.Errorf("expected an error for the results of a batch in progress")
+	}
+	job, err = WaitForBatch(ctx, client, job.ID, time.Millisecond)
+	if err != nil {
+		t.Fatalf("waiting for batch: %v", err)
+	}
+	if job.Status != BatchStatusEnded || job.Succeeded != 1 || job.Failed != 2 {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	results, err := client.BatchResults(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("getting results: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if r := results[1]; r.ID != "summary-a" || r.Text != "All pods are running." || r.Err != nil || r.Usage.TotalTokens != 15 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r := results[0]; r.ID != "request-2" || r.Err == nil || !strings.Contains(r.Err.Error(), "bad model") {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if r := results[2]; r.Err == nil || !strings.Contains(r.Err.Error(), "expired") {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestAnthropicBatchClient_Errors(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if _, err := NewAnthropicBatchClient(context.Background(), ClientOptions{}); err == nil {
+		t.Errorf("expected an error without an API key")
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	client, err := NewAnthropicBatchClient(context.Background(), ClientOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	_, err = client.GetBatch(context.Background(), "msgbatch_1")
+	var quotaErr *QuotaError
+	if !errors.As(ClassifyError(err), &quotaErr) {
+		t.Errorf("expected a quota error, got %v", err)
+	}
+}
@@ -18,6 +18,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
 	"os"
 	"slices"
 	"strings"
@@ -27,6 +30,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/cognitiveservices/armcognitiveservices"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
@@ -51,34 +55,79 @@ func azureOpenAIFactory(ctx context.Context, opts ClientOptions) (Client, error)
 type AzureOpenAIClient struct {
 	client   *azopenai.Client
 	endpoint string
+	seed     *int64
+	// credential authenticates the client, nil with an API key.
+	credential azcore.TokenCredential
+	// deployments maps model names to the names of their deployments.
+	deployments map[string]string
+	// apiKey and httpClient are used for the requests the SDK doesn't
+	// support, e.g. listing the deployments.
+	apiKey     string
+	httpClient *http.Client
+	// skipARM disables listing the deployments through Azure Resource
+	// Manager, which requires reading every subscription.
+	skipARM bool
 }
 
 var _ Client = &AzureOpenAIClient{}
 
 // NewAzureOpenAIClient creates a new Azure OpenAI client.
 // Supports ClientOptions and SkipVerifySSL for custom HTTP transport.
+//
+// Besides AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_API_KEY, it is configured
+// with the environment variables:
+//   - AZURE_OPENAI_API_VERSION, the API version to use instead of the one of
+//     the SDK, e.g. one supported by an older deployment.
+//   - AZURE_OPENAI_CLIENT_ID, the client ID of a user-assigned managed
+//     identity to authenticate with, instead of DefaultAzureCredential.
+//   - AZURE_OPENAI_DEPLOYMENTS, comma-separated model=deployment pairs naming
+//     the deployments of models, e.g. "gpt-4o=prod-gpt4o". Models without a
+//     deployment are used as deployment names. When set, these models are the
+//     ones listed by ListModels.
+//   - AZURE_OPENAI_SKIP_ARM, set to "1" or "true" so that ListModels doesn't
+//     fall back to Azure Resource Manager.
 func NewAzureOpenAIClient(ctx context.Context, opts ClientOptions) (*AzureOpenAIClient, error) {
 	azureOpenAIEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
 	if opts.URL != nil && opts.URL.Host != "" {
 		opts.URL.Scheme = "https"
 		azureOpenAIEndpoint = opts.URL.String()
 	}
+	if opts.Endpoint != "" {
+		azureOpenAIEndpoint = opts.Endpoint
+	}
 	if azureOpenAIEndpoint == "" {
 		return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT environment variable not set")
 	}
+	deployments, err := parseAzureOpenAIDeployments(os.Getenv("AZURE_OPENAI_DEPLOYMENTS"))
+	if err != nil {
+		return nil, err
+	}
 	azureOpenAIClient := AzureOpenAIClient{
-		endpoint: azureOpenAIEndpoint,
+		endpoint:    azureOpenAIEndpoint,
+		seed:        opts.Seed,
+		deployments: deployments,
 	}
 
-	// Create a custom HTTP client (supports SkipVerifySSL)
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	// Create a custom HTTP client (supports SkipVerifySSL, proxies and custom CAs)
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
 
 	azureOpenAIKey := os.Getenv("AZURE_OPENAI_API_KEY")
+	azureOpenAIClient.apiKey = azureOpenAIKey
+	azureOpenAIClient.httpClient = httpClient
+	if v := os.Getenv("AZURE_OPENAI_SKIP_ARM"); v == "1" || strings.ToLower(v) == "true" {
+		azureOpenAIClient.skipARM = true
+	}
 	clientOpts := &azopenai.ClientOptions{
 		ClientOptions: azcore.ClientOptions{
 			Transport: httpClient,
 		},
 	}
+	if apiVersion := os.Getenv("AZURE_OPENAI_API_VERSION"); apiVersion != "" {
+		clientOpts.PerRetryPolicies = append(clientOpts.PerRetryPolicies, azureAPIVersionPolicy(apiVersion))
+	}
 	if azureOpenAIKey != "" {
 		keyCredential := azcore.NewKeyCredential(azureOpenAIKey)
 		client, err := azopenai.NewClientWithKeyCredential(azureOpenAIEndpoint, keyCredential, clientOpts)
@@ -87,30 +136,92 @@ func NewAzureOpenAIClient(ctx context.Context, opts ClientOptions) (*AzureOpenAI
 		}
 		azureOpenAIClient.client = client
 	} else {
-		credential, err := azidentity.NewDefaultAzureCredential(nil)
+		credential, err := azureOpenAICredential(os.Getenv("AZURE_OPENAI_CLIENT_ID"))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get credential: %w", err)
+			return nil, err
 		}
 		client, err := azopenai.NewClient(azureOpenAIEndpoint, credential, clientOpts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create azure openai client: %w", err)
 		}
 		azureOpenAIClient.client = client
+		azureOpenAIClient.credential = credential
 	}
 
 	return &azureOpenAIClient, nil
 }
 
+// azureOpenAICredential returns the user-assigned managed identity of
+// clientID, or DefaultAzureCredential if clientID is empty.
+func azureOpenAICredential(clientID string) (azcore.TokenCredential, error) {
+	if clientID != "" {
+		credential, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ID: azidentity.ClientID(clientID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get managed identity credential for client ID %q: %w", clientID, err)
+		}
+		return credential, nil
+	}
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return credential, nil
+}
+
+// parseAzureOpenAIDeployments parses comma-separated model=deployment pairs.
+func parseAzureOpenAIDeployments(s string) (map[string]string, error) {
+	deployments := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		model, deployment, ok := strings.Cut(pair, "=")
+		model, deployment = strings.TrimSpace(model), strings.TrimSpace(deployment)
+		if !ok || model == "" || deployment == "" {
+			return nil, fmt.Errorf("invalid AZURE_OPENAI_DEPLOYMENTS entry %q, expected model=deployment", pair)
+		}
+		deployments[model] = deployment
+	}
+	return deployments, nil
+}
+
+// deploymentName returns the name of the deployment of model.
+func (c *AzureOpenAIClient) deploymentName(model string) string {
+	if deployment, ok := c.deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// azureAPIVersionPolicy sets the api-version of the requests, overriding the
+// version of the SDK. It must run after the policies of the SDK, which set
+// the version on every try.
+type azureAPIVersionPolicy string
+
+func (p azureAPIVersionPolicy) Do(req *policy.Request) (*http.Response, error) {
+	u := req.Raw().URL
+	q := u.Query()
+	if q.Has("api-version") {
+		q.Set("api-version", string(p))
+		u.RawQuery = q.Encode()
+	}
+	return req.Next()
+}
+
 func (c *AzureOpenAIClient) Close() error {
 	return nil
 }
 
 func (c *AzureOpenAIClient) GenerateCompletion(ctx context.Context, request *CompletionRequest) (CompletionResponse, error) {
+	deployment := c.deploymentName(request.Model)
 	req := azopenai.ChatCompletionsOptions{
 		Messages: []azopenai.ChatRequestMessageClassification{
 			&azopenai.ChatRequestUserMessage{Content: azopenai.NewChatRequestUserMessageContent(request.Prompt)},
 		},
-		DeploymentName: &request.Model,
+		DeploymentName: &deployment,
 	}
 
 	resp, err := c.client.GetChatCompletions(ctx, req, nil)
@@ -125,10 +236,87 @@ func (c *AzureOpenAIClient) GenerateCompletion(ctx context.Context, request *Com
 	return &AzureOpenAICompletionResponse{response: *resp.Choices[0].Message.Content}, nil
 }
 
+// ListModels lists the models configured in AZURE_OPENAI_DEPLOYMENTS, or else
+// the deployments of the endpoint. Deployments are listed with the API of the
+// endpoint, falling back to Azure Resource Manager if it fails, e.g. on
+// resources that no longer support it.
 func (c *AzureOpenAIClient) ListModels(ctx context.Context) ([]string, error) {
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if len(c.deployments) > 0 {
+		models := slices.Collect(maps.Keys(c.deployments))
+		slices.Sort(models)
+		return models, nil
+	}
+
+	deployments, err := c.listDeployments(ctx)
+	if err == nil {
+		return deployments, nil
+	}
+	if c.skipARM {
+		return nil, err
+	}
+	klog.V(1).Infof("listing the deployments of %s failed, falling back to Azure Resource Manager: %v", c.endpoint, err)
+	deployments, armErr := c.listDeploymentsARM(ctx)
+	if armErr != nil {
+		return nil, fmt.Errorf("%w; listing them through Azure Resource Manager: %w", err, armErr)
+	}
+	return deployments, nil
+}
+
+// azureDeploymentsAPIVersion is the latest API version listing deployments.
+const azureDeploymentsAPIVersion = "2023-03-15-preview"
+
+// listDeployments lists the deployments with the API of the endpoint, which
+// only requires access to the resource.
+func (c *AzureOpenAIClient) listDeployments(ctx context.Context) ([]string, error) {
+	u := strings.TrimSuffix(c.endpoint, "/") + "/openai/deployments?api-version=" + azureDeploymentsAPIVersion
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get credential: %w", err)
+		return nil, fmt.Errorf("building http request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("api-key", c.apiKey)
+	} else {
+		token, err := c.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://cognitiveservices.azure.com/.default"}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing deployments: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("listing deployments: %s", strings.TrimSpace(string(b)))}
+	}
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("unmarshalling deployments: %w", err)
+	}
+	var names []string
+	for _, deployment := range list.Data {
+		names = append(names, deployment.ID)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// listDeploymentsARM lists the deployments through Azure Resource Manager,
+// looking for the account of the endpoint in every subscription.
+func (c *AzureOpenAIClient) listDeploymentsARM(ctx context.Context) ([]string, error) {
+	cred := c.credential
+	if cred == nil {
+		var err error
+		if cred, err = azidentity.NewDefaultAzureCredential(nil); err != nil {
+			return nil, fmt.Errorf("failed to get credential: %w", err)
+		}
 	}
 
 	subClient, err := armsubscription.NewSubscriptionsClient(cred, nil)
@@ -138,7 +326,7 @@ func (c *AzureOpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 
 	subPager := subClient.NewListPager(nil)
 	for subPager.More() {
-		subResp, err := subPager.NextPage(context.Background())
+		subResp, err := subPager.NextPage(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get subscriptions page: %w", err)
 		}
@@ -151,7 +339,7 @@ func (c *AzureOpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 
 			accountPager := accountClient.NewListPager(nil)
 			for accountPager.More() {
-				accountResp, err := accountPager.NextPage(context.Background())
+				accountResp, err := accountPager.NextPage(ctx)
 				if err != nil {
 					return nil, fmt.Errorf("failed to to get accounts page: %w", err)
 				}
@@ -179,7 +367,7 @@ func (c *AzureOpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 					var modelNames []string
 					deploymentPager := deploymentClient.NewListPager(resourceID.ResourceGroupName, *account.Name, nil)
 					for deploymentPager.More() {
-						deploymentResp, err := deploymentPager.NextPage(context.Background())
+						deploymentResp, err := deploymentPager.NextPage(ctx)
 						if err != nil {
 							return nil, fmt.Errorf("failed to get deployments page: %w", err)
 						}
@@ -199,6 +387,12 @@ func (c *AzureOpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 	return nil, nil
 }
 
+// GenerateCompletionStreaming returns the completion as a single chunk, as
+// completions are not streamed yet.
+func (c *AzureOpenAIClient) GenerateCompletionStreaming(ctx context.Context, request *CompletionRequest) (CompletionResponseIterator, error) {
+	return StreamCompletion(ctx, c, request)
+}
+
 func (c *AzureOpenAIClient) SetResponseSchema(schema *Schema) error {
 	return nil
 }
@@ -206,7 +400,8 @@ func (c *AzureOpenAIClient) SetResponseSchema(schema *Schema) error {
 func (c *AzureOpenAIClient) StartChat(systemPrompt string, model string) Chat {
 	return &AzureOpenAIChat{
 		client: c.client,
-		model:  model,
+		model:  c.deploymentName(model),
+		seed:   c.seed,
 		history: []azopenai.ChatRequestMessageClassification{
 			&azopenai.ChatRequestSystemMessage{Content: azopenai.NewChatRequestSystemMessageContent(systemPrompt)},
 		},
@@ -230,6 +425,10 @@ type AzureOpenAIChat struct {
 	model   string
 	history []azopenai.ChatRequestMessageClassification
 	tools   []azopenai.ChatCompletionsToolDefinitionClassification
+
+	toolChoice    *azopenai.ChatCompletionsToolChoice
+	stopSequences []string
+	seed          *int64
 }
 
 func (c *AzureOpenAIChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
@@ -245,15 +444,37 @@ func (c *AzureOpenAIChat) Send(ctx context.Context, contents ...any) (ChatRespon
 				Content: azopenai.NewChatRequestUserMessageContent(fmt.Sprintf("Function call result: %s", v.Result)),
 			}
 			c.history = append(c.history, &message)
+		case ImageContent:
+			url := v.DataURL()
+			message := azopenai.ChatRequestUserMessage{
+				Content: azopenai.NewChatRequestUserMessageContent([]azopenai.ChatCompletionRequestMessageContentPartClassification{
+					&azopenai.ChatCompletionRequestMessageContentPartImage{
+						ImageURL: &azopenai.ChatCompletionRequestMessageContentPartImageURL{URL: &url},
+					},
+				}),
+			}
+			c.history = append(c.history, &message)
+		case ProviderRawContent:
+			message, err := rawContentValue[azopenai.ChatRequestMessageClassification](v, "azopenai")
+			if err != nil {
+				return nil, err
+			}
+			c.history = append(c.history, message)
 		default:
 			return nil, fmt.Errorf("unsupported content type: %T", v)
 		}
 	}
+	history, repairs := repairAzureOpenAIHistory(c.history)
+	reportHistoryRepairs(ctx, "Azure OpenAI", repairs)
+	c.history = history
 
 	resp, err := c.client.GetChatCompletions(ctx, azopenai.ChatCompletionsOptions{
 		DeploymentName: &c.model,
 		Messages:       c.history,
 		Tools:          c.tools,
+		ToolChoice:     c.toolChoice,
+		Stop:           c.stopSequences,
+		Seed:           c.seed,
 	}, nil)
 	if err != nil {
 		return nil, err
@@ -271,7 +492,20 @@ func (c *AzureOpenAIChat) IsRetryableError(err error) bool {
 }
 
 func (c *AzureOpenAIChat) Initialize(messages []*api.Message) error {
-	klog.Warning("chat history persistence is not supported for provider 'azopenai', using in-memory chat history")
+	var history []azopenai.ChatRequestMessageClassification
+	if len(c.history) > 0 {
+		if system, ok := c.history[0].(*azopenai.ChatRequestSystemMessage); ok {
+			history = append(history, system)
+		}
+	}
+	for _, turn := range api.NormalizeHistory(messages) {
+		if turn.Role == api.HistoryRoleModel {
+			history = append(history, &azopenai.ChatRequestAssistantMessage{Content: azopenai.NewChatRequestAssistantMessageContent(turn.Text)})
+		} else {
+			history = append(history, &azopenai.ChatRequestUserMessage{Content: azopenai.NewChatRequestUserMessageContent(turn.Text)})
+		}
+	}
+	c.history = history
 	return nil
 }
 
@@ -305,6 +539,22 @@ func (r *AzureOpenAIChatResponse) UsageMetadata() any {
 	return r.azureOpenAIResponse.Usage
 }
 
+var _ ResponseMetadata = &AzureOpenAIChatResponse{}
+
+func (r *AzureOpenAIChatResponse) StopReason() StopReason {
+	if len(r.azureOpenAIResponse.Choices) == 0 || r.azureOpenAIResponse.Choices[0].FinishReason == nil {
+		return StopReasonUnknown
+	}
+	return openAIStopReason(string(*r.azureOpenAIResponse.Choices[0].FinishReason))
+}
+
+func (r *AzureOpenAIChatResponse) ModelVersion() string {
+	if r.azureOpenAIResponse.Model == nil {
+		return ""
+	}
+	return *r.azureOpenAIResponse.Model
+}
+
 func (r *AzureOpenAIChatResponse) Candidates() []Candidate {
 	var candidates []Candidate
 	for _, candidate := range r.azureOpenAIResponse.Choices {
@@ -377,14 +627,16 @@ func (p *AzureOpenAIPart) AsText() (string, bool) {
 func (p *AzureOpenAIPart) AsFunctionCalls() ([]FunctionCall, bool) {
 	if p.functionCall != nil {
 		argumentsObj := map[string]any{}
-		err := json.Unmarshal([]byte(*p.functionCall.Arguments), &argumentsObj)
-		if err != nil {
-			return nil, false
+		var parseError string
+		if err := json.Unmarshal([]byte(*p.functionCall.Arguments), &argumentsObj); err != nil {
+			argumentsObj = map[string]any{}
+			parseError = err.Error()
 		}
 		functionCalls := []FunctionCall{
 			{
-				Name:      *p.functionCall.Name,
-				Arguments: argumentsObj,
+				Name:       *p.functionCall.Name,
+				Arguments:  argumentsObj,
+				ParseError: parseError,
 			},
 		}
 		return functionCalls, true
@@ -401,6 +653,30 @@ func (c *AzureOpenAIChat) SetFunctionDefinitions(functionDefinitions []*Function
 	return nil
 }
 
+// SetToolChoice sets the tool choice of the next requests. The Azure OpenAI SDK
+// can't require a call to any tool, only to a given one.
+func (c *AzureOpenAIChat) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	switch choice.Mode {
+	case ToolChoiceAny:
+		return fmt.Errorf("tool choice %q is not supported by Azure OpenAI", choice.Mode)
+	case ToolChoiceNone:
+		c.toolChoice = azopenai.ChatCompletionsToolChoiceNone
+	case ToolChoiceTool:
+		c.toolChoice = azopenai.NewChatCompletionsToolChoice(azopenai.ChatCompletionsToolChoiceFunction{Name: choice.Tool})
+	default:
+		c.toolChoice = nil
+	}
+	return nil
+}
+
+func (c *AzureOpenAIChat) SetStopSequences(stopSequences []string) error {
+	c.stopSequences = stopSequences
+	return nil
+}
+
 func fnDefToAzureOpenAITool(fnDef *FunctionDefinition) *azopenai.ChatCompletionsFunctionToolDefinitionFunction {
 	properties := make(map[string]any)
 	for paramName, param := range fnDef.Parameters.Properties {
@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAzureOpenAIClient_Configuration(t *testing.T) {
+	var gotPath, gotVersion string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("AZURE_OPENAI_API_KEY", "test-key")
+	t.Setenv("AZURE_OPENAI_API_VERSION", "2024-06-01")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENTS", "gpt-4o=prod-gpt4o, gpt-4.1 = prod-41")
+	client, err := NewAzureOpenAIClient(context.Background(), ClientOptions{Endpoint: server.URL, SkipVerifySSL: true})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	for model, deployment := range map[string]string{"gpt-4o": "prod-gpt4o", "my-deployment": "my-deployment"} {
+		response, err := client.GenerateCompletion(context.Background(), &CompletionRequest{Model: model, Prompt: "hi"})
+		if err != nil {
+			t.Fatalf("generating completion: %v", err)
+		}
+		if response.Response() != "ok" {
+			t.Errorf("unexpected response %q", response.Response())
+		}
+		if want := "/openai/deployments/" + deployment + "/chat/completions"; gotPath != want {
+			t.Errorf("model %s was sent to %s, want %s", model, gotPath, want)
+		}
+		if gotVersion != "2024-06-01" {
+			t.Errorf("api-version is %q, want 2024-06-01", gotVersion)
+		}
+	}
+}
+
+func TestParseAzureOpenAIDeployments(t *testing.T) {
+	got, err := parseAzureOpenAIDeployments(" gpt-4o=prod-gpt4o,,gpt-4.1=prod-41 ")
+	if err != nil {
+		t.Fatalf("parsing deployments: %v", err)
+	}
+	if want := map[string]string{"gpt-4o": "prod-gpt4o", "gpt-4.1": "prod-41"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, invalid := range []string{"gpt-4o", "=prod", "gpt-4o="} {
+		if _, err := parseAzureOpenAIDeployments(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestAzureOpenAIClient_ListModels(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments" || r.Header.Get("api-key") != "test-key" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"data":[{"id":"prod-41","model":"gpt-4.1"},{"id":"prod-gpt4o","model":"gpt-4o"}],"object":"list"}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("AZURE_OPENAI_API_KEY", "test-key")
+	t.Setenv("AZURE_OPENAI_SKIP_ARM", "true")
+	client, err := NewAzureOpenAIClient(context.Background(), ClientOptions{Endpoint: server.URL, SkipVerifySSL: true})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	models, err := client.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("listing models: %v", err)
+	}
+	if want := []string{"prod-41", "prod-gpt4o"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("got models %v, want %v", models, want)
+	}
+
+	// Azure Resource Manager is not tried when skipped
+	status = http.StatusNotFound
+	if _, err := client.ListModels(context.Background()); err == nil {
+		t.Errorf("expected an error when the deployments can't be listed")
+	}
+
+	// Configured deployments are listed without any request
+	t.Setenv("AZURE_OPENAI_DEPLOYMENTS", "gpt-4o=prod-gpt4o,gpt-4.1=prod-41")
+	client, err = NewAzureOpenAIClient(context.Background(), ClientOptions{Endpoint: server.URL, SkipVerifySSL: true})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	models, err = client.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("listing models: %v", err)
+	}
+	if want := []string{"gpt-4.1", "gpt-4o"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("got models %v, want %v", models, want)
+	}
+}
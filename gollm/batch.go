@@ -0,0 +1,102 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// BatchClient processes requests asynchronously in batches, which providers
+// bill at a discount but may take up to a day to complete. It suits
+// non-interactive workloads, e.g. evaluations or summarizing many sessions.
+type BatchClient interface {
+	io.Closer
+
+	// SubmitBatch submits requests to be processed as a batch.
+	SubmitBatch(ctx context.Context, requests []*BatchRequest) (*BatchJob, error)
+
+	// GetBatch returns the current state of a batch.
+	GetBatch(ctx context.Context, id string) (*BatchJob, error)
+
+	// BatchResults returns the results of a batch that ended.
+	BatchResults(ctx context.Context, id string) ([]*BatchResult, error)
+}
+
+// BatchRequest is a single-turn request of a batch.
+type BatchRequest struct {
+	// ID identifies the request in the results of the batch. Requests
+	// without one are numbered, "request-1" being the first.
+	ID           string `json:"id,omitempty"`
+	Model        string `json:"model,omitempty"`
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+	Prompt       string `json:"prompt"`
+	// MaxTokens is the maximum number of tokens of the response, a default
+	// of the provider if zero.
+	MaxTokens int `json:"maxTokens,omitempty"`
+}
+
+// BatchStatus is the processing status of a batch.
+type BatchStatus string
+
+const (
+	BatchStatusInProgress BatchStatus = "in_progress"
+	BatchStatusCanceling  BatchStatus = "canceling"
+	BatchStatusEnded      BatchStatus = "ended"
+)
+
+// BatchJob is the state of a submitted batch.
+type BatchJob struct {
+	ID     string      `json:"id"`
+	Status BatchStatus `json:"status"`
+	// Processing, Succeeded and Failed count the requests of the batch.
+	// Failed requests errored, were canceled or expired.
+	Processing int       `json:"processing"`
+	Succeeded  int       `json:"succeeded"`
+	Failed     int       `json:"failed"`
+	CreatedAt  time.Time `json:"createdAt"`
+	EndedAt    time.Time `json:"endedAt,omitzero"`
+}
+
+// BatchResult is the outcome of a request of a batch: the response text, or
+// the error if it failed.
+type BatchResult struct {
+	ID    string
+	Text  string
+	Usage *Usage
+	Err   error
+}
+
+// WaitForBatch polls a batch every interval until it ended, and returns its
+// final state.
+func WaitForBatch(ctx context.Context, client BatchClient, id string, interval time.Duration) (*BatchJob, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := client.GetBatch(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Status == BatchStatusEnded {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -19,16 +19,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"k8s.io/klog/v2"
 )
 
@@ -47,18 +51,32 @@ func newBedrockClientFactory(ctx context.Context, opts ClientOptions) (Client, e
 // BedrockClient implements the gollm.Client interface for AWS Bedrock models
 type BedrockClient struct {
 	client *bedrockruntime.Client
+	seed   *int64
 }
 
 // Ensure BedrockClient implements the Client interface
 var _ Client = &BedrockClient{}
 
-// NewBedrockClient creates a new client for interacting with AWS Bedrock models
+// NewBedrockClient creates a new client for interacting with AWS Bedrock models.
+// Credentials come from the default credential chain, unless opts.AWS or the
+// BEDROCK_AWS_PROFILE, BEDROCK_AWS_REGION, BEDROCK_ROLE_ARN,
+// BEDROCK_ROLE_EXTERNAL_ID and BEDROCK_ENDPOINT environment variables
+// configure them.
 func NewBedrockClient(ctx context.Context, opts ClientOptions) (*BedrockClient, error) {
+	awsConfig := bedrockAWSConfig(opts)
+
 	// Load AWS config with timeout protection
 	configCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	cfg, err := config.LoadDefaultConfig(configCtx)
+	var loadOptions []func(*config.LoadOptions) error
+	if awsConfig.Profile != "" {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(awsConfig.Profile))
+	}
+	if awsConfig.Region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(awsConfig.Region))
+	}
+	cfg, err := config.LoadDefaultConfig(configCtx, loadOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -68,11 +86,82 @@ func NewBedrockClient(ctx context.Context, opts ClientOptions) (*BedrockClient,
 		cfg.Region = "us-east-1"
 	}
 
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+	cfg.HTTPClient = httpClient
+
+	if awsConfig.RoleARN != "" {
+		// Assume the role with the credentials loaded above, refreshing
+		// them before they expire
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), awsConfig.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "kubectl-ai"
+			if awsConfig.ExternalID != "" {
+				o.ExternalID = aws.String(awsConfig.ExternalID)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	client := bedrockruntime.NewFromConfig(cfg, func(o *bedrockruntime.Options) {
+		if awsConfig.Endpoint != "" {
+			o.BaseEndpoint = aws.String(awsConfig.Endpoint)
+		}
+	})
 	return &BedrockClient{
-		client: bedrockruntime.NewFromConfig(cfg),
+		client: client,
+		seed:   opts.Seed,
 	}, nil
 }
 
+// bedrockAWSConfig returns the AWS configuration of opts, completed with the
+// environment. opts.Endpoint, e.g. from --llm-endpoint, overrides the
+// endpoint of opts.AWS.
+func bedrockAWSConfig(opts ClientOptions) AWSConfig {
+	awsConfig := opts.AWS
+	if opts.Endpoint != "" {
+		awsConfig.Endpoint = opts.Endpoint
+	}
+	for _, field := range []struct {
+		value *string
+		env   string
+	}{
+		{&awsConfig.Profile, "BEDROCK_AWS_PROFILE"},
+		{&awsConfig.Region, "BEDROCK_AWS_REGION"},
+		{&awsConfig.RoleARN, "BEDROCK_ROLE_ARN"},
+		{&awsConfig.ExternalID, "BEDROCK_ROLE_EXTERNAL_ID"},
+		{&awsConfig.Endpoint, "BEDROCK_ENDPOINT"},
+	} {
+		if *field.value == "" {
+			*field.value = os.Getenv(field.env)
+		}
+	}
+	return awsConfig
+}
+
+// bedrockModelSupportsSeed reports whether model takes a seed, Cohere's
+// Command R models do.
+func bedrockModelSupportsSeed(model string) bool {
+	return strings.Contains(model, "cohere.command-r")
+}
+
+// bedrockModelSupportsPromptCaching reports whether model caches the prompt
+// prefixes marked with cache points, Anthropic's Claude and Amazon's Nova
+// models do.
+func bedrockModelSupportsPromptCaching(model string) bool {
+	for _, family := range []string{"claude-3-5-haiku", "claude-3-7-sonnet", "claude-sonnet-4", "claude-opus-4", "amazon.nova"} {
+		if strings.Contains(model, family) {
+			return true
+		}
+	}
+	return false
+}
+
+// bedrockCachePoint marks the end of a prompt prefix to cache, e.g. the tool
+// definitions resent with every request.
+var bedrockCachePoint = types.CachePointBlock{Type: types.CachePointTypeDefault}
+
 // Close cleans up any resources used by the client
 func (c *BedrockClient) Close() error {
 	return nil
@@ -96,12 +185,21 @@ func (c *BedrockClient) StartChat(systemPrompt, model string) Chat {
 		enhancedPrompt += "Note the comma after the \"thought\" field! Malformed JSON will cause failures."
 	}
 
-	return &bedrockChat{
+	chat := &bedrockChat{
 		client:       c,
 		systemPrompt: enhancedPrompt,
 		model:        selectedModel,
 		messages:     []types.Message{},
 	}
+	if c.seed != nil {
+		// The Converse API has no seed, only some models take one
+		if bedrockModelSupportsSeed(selectedModel) {
+			chat.additionalFields = document.NewLazyDocument(map[string]any{"seed": *c.seed})
+		} else {
+			klog.Warningf("Bedrock model %s has no seed, sampling is not deterministic", selectedModel)
+		}
+	}
+	return chat
 }
 
 // GenerateCompletion generates a single completion for the given request
@@ -118,6 +216,12 @@ func (c *BedrockClient) GenerateCompletion(ctx context.Context, req *CompletionR
 	}, nil
 }
 
+// GenerateCompletionStreaming returns the completion of GenerateCompletion as a
+// single chunk
+func (c *BedrockClient) GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error) {
+	return StreamCompletion(ctx, c, req)
+}
+
 // SetResponseSchema sets the response schema for the client (not supported by Bedrock)
 func (c *BedrockClient) SetResponseSchema(schema *Schema) error {
 	return fmt.Errorf("response schema not supported by Bedrock")
@@ -131,6 +235,75 @@ func (c *BedrockClient) ListModels(ctx context.Context) ([]string, error) {
 	}, nil
 }
 
+// bedrockDefaultEmbeddingModel is the default model of Embed.
+const bedrockDefaultEmbeddingModel = "amazon.titan-embed-text-v2:0"
+
+var _ Embedder = &BedrockClient{}
+
+// Embed computes embeddings with the Amazon Titan or Cohere embedding models.
+// Titan models embed a single text per request, so texts are sent one by one.
+func (c *BedrockClient) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = bedrockDefaultEmbeddingModel
+	}
+	response := &EmbeddingResponse{Model: model, Usage: &Usage{}}
+	if strings.Contains(model, "cohere.embed") {
+		inputType := "search_document"
+		if req.Task == EmbeddingTaskQuery {
+			inputType = "search_query"
+		}
+		var output struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		if err := c.invokeModel(ctx, model, map[string]any{"texts": req.Texts, "input_type": inputType}, &output); err != nil {
+			return nil, err
+		}
+		response.Embeddings = output.Embeddings
+		response.Usage = nil
+		return response, nil
+	}
+	for _, text := range req.Texts {
+		body := map[string]any{"inputText": text}
+		if req.Dimensions > 0 {
+			body["dimensions"] = req.Dimensions
+		}
+		var output struct {
+			Embedding           []float32 `json:"embedding"`
+			InputTextTokenCount int64     `json:"inputTextTokenCount"`
+		}
+		if err := c.invokeModel(ctx, model, body, &output); err != nil {
+			return nil, err
+		}
+		response.Embeddings = append(response.Embeddings, output.Embedding)
+		response.Usage.InputTokens += output.InputTextTokenCount
+		response.Usage.TotalTokens += output.InputTextTokenCount
+	}
+	return response, nil
+}
+
+// invokeModel invokes model with the JSON of body, and decodes its output
+// into output.
+func (c *BedrockClient) invokeModel(ctx context.Context, model string, body any, output any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(model),
+		Body:        b,
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("invoking %s: %w", model, err)
+	}
+	if err := json.Unmarshal(res.Body, output); err != nil {
+		return fmt.Errorf("decoding the output of %s: %w", model, err)
+	}
+	return nil
+}
+
 // bedrockChat implements the Chat interface for Bedrock conversations
 type bedrockChat struct {
 	client       *BedrockClient
@@ -139,52 +312,29 @@ type bedrockChat struct {
 	messages     []types.Message
 	toolConfig   *types.ToolConfiguration
 	functionDefs []*FunctionDefinition
+
+	toolChoice    types.ToolChoice
+	stopSequences []string
+	// additionalFields are the model specific fields of the requests, e.g. the
+	// seed of the models supporting it
+	additionalFields document.Interface
 }
 
 func (cs *bedrockChat) Initialize(history []*api.Message) error {
-	cs.messages = make([]types.Message, 0, len(history))
-
-	for _, msg := range history {
-		// Convert api.Message to types.Message
-		var role types.ConversationRole
-		switch msg.Source {
-		case api.MessageSourceUser:
-			role = types.ConversationRoleUser
-		case api.MessageSourceModel, api.MessageSourceAgent:
+	turns := api.NormalizeHistory(history)
+	cs.messages = make([]types.Message, 0, len(turns))
+	for _, turn := range turns {
+		role := types.ConversationRoleUser
+		if turn.Role == api.HistoryRoleModel {
 			role = types.ConversationRoleAssistant
-		default:
-			// Skip unknown message sources
-			continue
-		}
-
-		// Convert payload to string content
-		var content string
-		if msg.Type == api.MessageTypeText && msg.Payload != nil {
-			if textPayload, ok := msg.Payload.(string); ok {
-				content = textPayload
-			} else {
-				// Try to convert other types to string
-				content = fmt.Sprintf("%v", msg.Payload)
-			}
-		} else {
-			// Skip non-text messages for now
-			continue
 		}
-
-		if content == "" {
-			continue
-		}
-
-		bedrockMsg := types.Message{
+		cs.messages = append(cs.messages, types.Message{
 			Role: role,
 			Content: []types.ContentBlock{
-				&types.ContentBlockMemberText{Value: content},
+				&types.ContentBlockMemberText{Value: turn.Text},
 			},
-		}
-
-		cs.messages = append(cs.messages, bedrockMsg)
+		})
 	}
-
 	return nil
 }
 
@@ -195,34 +345,38 @@ func (c *bedrockChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 	}
 
 	// Process and append contents to conversation history
+	requestStart := len(c.messages)
 	if err := c.addContentsToHistory(contents); err != nil {
 		return nil, err
 	}
+	unrepaired := c.messages
+	messages, repairs := repairBedrockMessages(c.messages)
+	reportHistoryRepairs(ctx, "Bedrock", repairs)
+	c.messages = messages
 
 	// Prepare the request
 	input := &bedrockruntime.ConverseInput{
 		ModelId:  aws.String(c.model),
 		Messages: c.messages,
 		InferenceConfig: &types.InferenceConfiguration{
-			MaxTokens: aws.Int32(4096),
+			MaxTokens:     aws.Int32(4096),
+			StopSequences: c.stopSequences,
 		},
 	}
 
-	// Add system prompt if provided
-	if c.systemPrompt != "" {
-		input.System = []types.SystemContentBlock{
-			&types.SystemContentBlockMemberText{Value: c.systemPrompt},
-		}
-	}
+	input.System = c.systemBlocks()
 
 	// Add tool configuration if functions are defined
 	if c.toolConfig != nil {
 		input.ToolConfig = c.toolConfig
 	}
+	input.AdditionalModelRequestFields = c.additionalFields
 
 	// Call the Bedrock Converse API
 	output, err := c.client.client.Converse(ctx, input)
 	if err != nil {
+		// Remove the failed request, so that it can be sent again
+		c.messages = unrepaired[:requestStart]
 		return nil, fmt.Errorf("bedrock converse error: %w", err)
 	}
 
@@ -249,34 +403,38 @@ func (c *bedrockChat) SendStreaming(ctx context.Context, contents ...any) (ChatR
 	}
 
 	// Process and append contents to conversation history
+	requestStart := len(c.messages)
 	if err := c.addContentsToHistory(contents); err != nil {
 		return nil, err
 	}
+	unrepaired := c.messages
+	messages, repairs := repairBedrockMessages(c.messages)
+	reportHistoryRepairs(ctx, "Bedrock", repairs)
+	c.messages = messages
 
 	// Prepare the streaming request
 	input := &bedrockruntime.ConverseStreamInput{
 		ModelId:  aws.String(c.model),
 		Messages: c.messages,
 		InferenceConfig: &types.InferenceConfiguration{
-			MaxTokens: aws.Int32(4096),
+			MaxTokens:     aws.Int32(4096),
+			StopSequences: c.stopSequences,
 		},
 	}
 
-	// Add system prompt if provided
-	if c.systemPrompt != "" {
-		input.System = []types.SystemContentBlock{
-			&types.SystemContentBlockMemberText{Value: c.systemPrompt},
-		}
-	}
+	input.System = c.systemBlocks()
 
 	// Add tool configuration if functions are defined
 	if c.toolConfig != nil {
 		input.ToolConfig = c.toolConfig
 	}
+	input.AdditionalModelRequestFields = c.additionalFields
 
 	// Start the streaming request
 	output, err := c.client.client.ConverseStream(ctx, input)
 	if err != nil {
+		// Remove the failed request, so that it can be sent again
+		c.messages = unrepaired[:requestStart]
 		return nil, fmt.Errorf("bedrock stream error: %w", err)
 	}
 
@@ -288,137 +446,183 @@ func (c *bedrockChat) SendStreaming(ctx context.Context, contents ...any) (ChatR
 			}
 		}()
 
-		var assistantMessage types.Message
-		assistantMessage.Role = types.ConversationRoleAssistant
-		var fullContent strings.Builder
+		state := newBedrockStreamState(c.model)
+		stream := output.GetStream()
+		for event := range stream.Events() {
+			if response := state.handleEvent(event); response != nil {
+				if !yield(response, nil) {
+					return
+				}
+			}
+		}
+		streamErr := stream.Err()
+		if streamErr == nil {
+			// Tool calls whose input was cut off are returned with a parse
+			// error, so that the model is asked to send them again
+			for _, response := range state.finish() {
+				if !yield(response, nil) {
+					return
+				}
+			}
+		}
 
-		// Tool state tracking for streaming
-		type partialTool struct {
-			id    string
-			name  string
-			input strings.Builder
+		// Update conversation history with the full response
+		if message := state.message(); len(message.Content) > 0 {
+			c.messages = append(c.messages, message)
+		} else if streamErr != nil {
+			// Remove the failed request, so that it can be sent again
+			c.messages = unrepaired[:requestStart]
 		}
-		partialTools := make(map[int32]*partialTool)
-		var completedTools []types.ToolUseBlock
 
-		// Process streaming events
-		stream := output.GetStream()
-		for event := range stream.Events() {
-			switch v := event.(type) {
-			case *types.ConverseStreamOutputMemberContentBlockDelta:
-				// Handle text deltas
-				if textDelta, ok := v.Value.Delta.(*types.ContentBlockDeltaMemberText); ok {
-					fullContent.WriteString(textDelta.Value)
-
-					response := &bedrockStreamResponse{
-						content: textDelta.Value,
-						model:   c.model,
-						done:    false,
-					}
+		if streamErr != nil {
+			yield(nil, fmt.Errorf("stream error: %w", streamErr))
+		}
+	}, nil
+}
 
-					if !yield(response, nil) {
-						return
-					}
-				}
+// bedrockStreamState accumulates the events of a ConverseStream response.
+// Content blocks are tracked by their index, so the assistant message added
+// to the history keeps the order of its text and tool use blocks.
+type bedrockStreamState struct {
+	model  string
+	blocks map[int32]*bedrockStreamBlock
+	// stopReason is set by the message stop event, which comes before the
+	// metadata event ending the stream.
+	stopReason types.StopReason
+}
 
-				// Handle tool input deltas
-				if toolDelta, ok := v.Value.Delta.(*types.ContentBlockDeltaMemberToolUse); ok {
-					idx := aws.ToInt32(v.Value.ContentBlockIndex)
-					if partial, exists := partialTools[idx]; exists {
-						deltaInput := aws.ToString(toolDelta.Value.Input)
-						partial.input.WriteString(deltaInput)
-					}
-				}
+// bedrockStreamBlock is a content block of a streamed response.
+type bedrockStreamBlock struct {
+	text strings.Builder
 
-			case *types.ConverseStreamOutputMemberContentBlockStart:
-				// Handle content block start (for tool calls)
-				if v.Value.Start != nil {
-					if toolStart, ok := v.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
-						// Store partial tool for input accumulation
-						idx := aws.ToInt32(v.Value.ContentBlockIndex)
-						partialTools[idx] = &partialTool{
-							id:   aws.ToString(toolStart.Value.ToolUseId),
-							name: aws.ToString(toolStart.Value.Name),
-						}
-					}
-				}
+	// isTool is set for tool use blocks, whose input is streamed as JSON.
+	isTool bool
+	toolID string
+	name   string
+	input  strings.Builder
+	// toolUse is set once the block is complete.
+	toolUse *types.ToolUseBlock
+}
 
-			case *types.ConverseStreamOutputMemberContentBlockStop:
-				// Handle content block stop (tool completion)
-				idx := aws.ToInt32(v.Value.ContentBlockIndex)
-				if partial, exists := partialTools[idx]; exists {
-					// Parse the JSON to extract arguments for function call
-					inputJSON := partial.input.String()
-
-					var args map[string]any
-					if inputJSON != "" {
-						if err := json.Unmarshal([]byte(inputJSON), &args); err != nil {
-							args = make(map[string]any)
-						}
-					} else {
-						args = make(map[string]any)
-					}
+func newBedrockStreamState(model string) *bedrockStreamState {
+	return &bedrockStreamState{model: model, blocks: make(map[int32]*bedrockStreamBlock)}
+}
 
-					// Create ToolUseBlock for conversation history
-					// Use the accumulated JSON string to create proper Input document
-					toolUse := types.ToolUseBlock{
-						ToolUseId: aws.String(partial.id),
-						Name:      aws.String(partial.name),
-						Input:     document.NewLazyDocument(args),
-					}
-					completedTools = append(completedTools, toolUse)
-
-					// Yield tool immediately with parsed arguments
-					response := &bedrockStreamResponse{
-						content:       "",
-						model:         c.model,
-						done:          false,
-						toolUses:      []types.ToolUseBlock{toolUse},
-						streamingArgs: map[int]map[string]any{0: args},
-					}
-					if !yield(response, nil) {
-						return
-					}
+func (s *bedrockStreamState) block(index *int32) *bedrockStreamBlock {
+	idx := aws.ToInt32(index)
+	block, ok := s.blocks[idx]
+	if !ok {
+		block = &bedrockStreamBlock{}
+		s.blocks[idx] = block
+	}
+	return block
+}
 
-					delete(partialTools, idx)
-				}
+// handleEvent records event, and returns the response to yield for it, if any.
+func (s *bedrockStreamState) handleEvent(event types.ConverseStreamOutput) *bedrockStreamResponse {
+	switch v := event.(type) {
+	case *types.ConverseStreamOutputMemberContentBlockStart:
+		if toolStart, ok := v.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+			block := s.block(v.Value.ContentBlockIndex)
+			block.isTool = true
+			block.toolID = aws.ToString(toolStart.Value.ToolUseId)
+			block.name = aws.ToString(toolStart.Value.Name)
+		}
 
-			case *types.ConverseStreamOutputMemberMetadata:
-				// Handle final usage metadata
-				if v.Value.Usage != nil {
-					finalResponse := &bedrockStreamResponse{
-						content: "",
-						usage:   v.Value.Usage,
-						model:   c.model,
-						done:    true,
-					}
-					yield(finalResponse, nil)
-				}
+	case *types.ConverseStreamOutputMemberContentBlockDelta:
+		switch delta := v.Value.Delta.(type) {
+		case *types.ContentBlockDeltaMemberText:
+			s.block(v.Value.ContentBlockIndex).text.WriteString(delta.Value)
+			return &bedrockStreamResponse{content: delta.Value, model: s.model}
+		case *types.ContentBlockDeltaMemberToolUse:
+			block := s.block(v.Value.ContentBlockIndex)
+			if !block.isTool {
+				klog.V(2).Infof("Ignoring tool input for content block %d without a tool use start", aws.ToInt32(v.Value.ContentBlockIndex))
+				return nil
 			}
+			block.input.WriteString(aws.ToString(delta.Value.Input))
 		}
 
-		// Update conversation history with the full response
-		if fullContent.Len() > 0 {
-			assistantMessage.Content = append(assistantMessage.Content,
-				&types.ContentBlockMemberText{Value: fullContent.String()})
+	case *types.ConverseStreamOutputMemberContentBlockStop:
+		block, ok := s.blocks[aws.ToInt32(v.Value.ContentBlockIndex)]
+		if ok && block.isTool && block.toolUse == nil {
+			return s.finishTool(block, "")
 		}
 
-		// Include completed tools in conversation history
-		for _, tool := range completedTools {
-			assistantMessage.Content = append(assistantMessage.Content,
-				&types.ContentBlockMemberToolUse{Value: tool})
+	case *types.ConverseStreamOutputMemberMessageStop:
+		s.stopReason = v.Value.StopReason
+
+	case *types.ConverseStreamOutputMemberMetadata:
+		if v.Value.Usage != nil || s.stopReason != "" {
+			return &bedrockStreamResponse{usage: v.Value.Usage, model: s.model, done: true, stopReason: s.stopReason}
 		}
+	}
+	return nil
+}
 
-		// Only add to history if there's content or tools
-		if len(assistantMessage.Content) > 0 {
-			c.messages = append(c.messages, assistantMessage)
+// finishTool completes a tool use block, and returns the response with its
+// call. The call has a parse error if its input is not valid JSON.
+func (s *bedrockStreamState) finishTool(block *bedrockStreamBlock, parseError string) *bedrockStreamResponse {
+	args := make(map[string]any)
+	if inputJSON := block.input.String(); inputJSON != "" && parseError == "" {
+		if err := json.Unmarshal([]byte(inputJSON), &args); err != nil {
+			args = make(map[string]any)
+			parseError = err.Error()
 		}
+	}
+	block.toolUse = &types.ToolUseBlock{
+		ToolUseId: aws.String(block.toolID),
+		Name:      aws.String(block.name),
+		Input:     document.NewLazyDocument(args),
+	}
+	return &bedrockStreamResponse{
+		model:         s.model,
+		toolUses:      []types.ToolUseBlock{*block.toolUse},
+		streamingArgs: map[int]map[string]any{0: args},
+		parseErrors:   map[int]string{0: parseError},
+	}
+}
 
-		// Check for stream errors
-		if err := stream.Err(); err != nil {
-			yield(nil, fmt.Errorf("stream error: %w", err))
+// finish completes the tool use blocks that were not stopped, because the
+// stream ended early, and returns the responses with their calls.
+func (s *bedrockStreamState) finish() []*bedrockStreamResponse {
+	var responses []*bedrockStreamResponse
+	for _, idx := range s.indexes() {
+		if block := s.blocks[idx]; block.isTool && block.toolUse == nil {
+			responses = append(responses, s.finishTool(block, "the response ended before the tool input was complete"))
 		}
-	}, nil
+	}
+	return responses
+}
+
+// message returns the assistant message with the streamed content blocks, in
+// order. Tool use blocks that were not completed are left out.
+func (s *bedrockStreamState) message() types.Message {
+	message := types.Message{Role: types.ConversationRoleAssistant}
+	for _, idx := range s.indexes() {
+		block := s.blocks[idx]
+		switch {
+		case block.toolUse != nil:
+			message.Content = append(message.Content, &types.ContentBlockMemberToolUse{Value: *block.toolUse})
+		case !block.isTool && block.text.Len() > 0:
+			message.Content = append(message.Content, &types.ContentBlockMemberText{Value: block.text.String()})
+		}
+	}
+	return message
+}
+
+func (s *bedrockStreamState) indexes() []int32 {
+	indexes := slices.Collect(maps.Keys(s.blocks))
+	slices.Sort(indexes)
+	return indexes
+}
+
+// bedrockImageFormats maps image MIME types to the formats supported by the Converse API.
+var bedrockImageFormats = map[string]types.ImageFormat{
+	"image/png":  types.ImageFormatPng,
+	"image/jpeg": types.ImageFormatJpeg,
+	"image/gif":  types.ImageFormatGif,
+	"image/webp": types.ImageFormatWebp,
 }
 
 // addContentsToHistory processes and appends user messages to chat history
@@ -461,6 +665,21 @@ func (c *bedrockChat) addContentsToHistory(contents []any) error {
 				Status: status,
 			}
 			contentBlocks = append(contentBlocks, &types.ContentBlockMemberToolResult{Value: toolResult})
+		case ImageContent:
+			format, ok := bedrockImageFormats[c.MIMEType]
+			if !ok {
+				return fmt.Errorf("unsupported image type %q, expected PNG, JPEG, GIF or WebP", c.MIMEType)
+			}
+			contentBlocks = append(contentBlocks, &types.ContentBlockMemberImage{Value: types.ImageBlock{
+				Format: format,
+				Source: &types.ImageSourceMemberBytes{Value: c.Data},
+			}})
+		case ProviderRawContent:
+			block, err := rawContentValue[types.ContentBlock](c, "bedrock")
+			if err != nil {
+				return err
+			}
+			contentBlocks = append(contentBlocks, block)
 		default:
 			return fmt.Errorf("unhandled content type: %T", content)
 		}
@@ -477,6 +696,92 @@ func (c *bedrockChat) addContentsToHistory(contents []any) error {
 	return nil
 }
 
+// DropToolOutputs implements ToolOutputDropper.
+func (c *bedrockChat) DropToolOutputs(count int) int {
+	var results []*types.ContentBlockMemberToolResult
+	for _, message := range c.messages {
+		for _, block := range message.Content {
+			result, ok := block.(*types.ContentBlockMemberToolResult)
+			if !ok || len(result.Value.Content) == 1 && isDroppedBedrockToolOutput(result.Value.Content[0]) {
+				continue
+			}
+			results = append(results, result)
+		}
+	}
+	dropped := min(count, len(results))
+	for _, result := range results[:dropped] {
+		result.Value.Content = []types.ToolResultContentBlock{
+			&types.ToolResultContentBlockMemberText{Value: DroppedToolOutput},
+		}
+	}
+	return dropped
+}
+
+// PruneToolOutputs implements ToolOutputPruner.
+func (c *bedrockChat) PruneToolOutputs(keep int, prune func(output string) (string, bool)) int {
+	var results []*types.ContentBlockMemberToolResult
+	for _, message := range c.messages {
+		for _, block := range message.Content {
+			if result, ok := block.(*types.ContentBlockMemberToolResult); ok {
+				results = append(results, result)
+			}
+		}
+	}
+	pruned := 0
+	for _, result := range results[:max(len(results)-keep, 0)] {
+		output, ok := bedrockToolOutput(result.Value.Content)
+		if !ok {
+			continue
+		}
+		if replacement, ok := prune(output); ok {
+			result.Value.Content = []types.ToolResultContentBlock{
+				&types.ToolResultContentBlockMemberText{Value: replacement},
+			}
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// bedrockToolOutput returns the content of a tool result as JSON, or its
+// text, and false if it has other content, e.g. images.
+func bedrockToolOutput(content []types.ToolResultContentBlock) (string, bool) {
+	if len(content) != 1 {
+		return "", false
+	}
+	switch block := content[0].(type) {
+	case *types.ToolResultContentBlockMemberText:
+		return block.Value, true
+	case *types.ToolResultContentBlockMemberJson:
+		output, err := block.Value.MarshalSmithyDocument()
+		if err != nil {
+			return "", false
+		}
+		return string(output), true
+	}
+	return "", false
+}
+
+func isDroppedBedrockToolOutput(block types.ToolResultContentBlock) bool {
+	text, ok := block.(*types.ToolResultContentBlockMemberText)
+	return ok && text.Value == DroppedToolOutput
+}
+
+// systemBlocks returns the system prompt of the requests, cached with the
+// tool definitions preceding it if the model supports prompt caching.
+func (c *bedrockChat) systemBlocks() []types.SystemContentBlock {
+	if c.systemPrompt == "" {
+		return nil
+	}
+	blocks := []types.SystemContentBlock{
+		&types.SystemContentBlockMemberText{Value: c.systemPrompt},
+	}
+	if bedrockModelSupportsPromptCaching(c.model) {
+		blocks = append(blocks, &types.SystemContentBlockMemberCachePoint{Value: bedrockCachePoint})
+	}
+	return blocks
+}
+
 // SetFunctionDefinitions configures the available functions for tool use
 func (c *bedrockChat) SetFunctionDefinitions(functions []*FunctionDefinition) error {
 	c.functionDefs = functions
@@ -511,14 +816,43 @@ func (c *bedrockChat) SetFunctionDefinitions(functions []*FunctionDefinition) er
 
 		tools = append(tools, &types.ToolMemberToolSpec{Value: toolSpec})
 	}
+	if bedrockModelSupportsPromptCaching(c.model) {
+		tools = append(tools, &types.ToolMemberCachePoint{Value: bedrockCachePoint})
+	}
 
 	c.toolConfig = &types.ToolConfiguration{
-		Tools: tools,
-		ToolChoice: &types.ToolChoiceMemberAny{
-			Value: types.AnyToolChoice{},
-		},
+		Tools:      tools,
+		ToolChoice: c.toolChoice,
+	}
+
+	return nil
+}
+
+// SetToolChoice sets the toolChoice of the next requests. Bedrock can't
+// prevent tool calls when tools are defined.
+func (c *bedrockChat) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	switch choice.Mode {
+	case ToolChoiceAny:
+		c.toolChoice = &types.ToolChoiceMemberAny{Value: types.AnyToolChoice{}}
+	case ToolChoiceNone:
+		return fmt.Errorf("tool choice %q is not supported by Bedrock", choice.Mode)
+	case ToolChoiceTool:
+		c.toolChoice = &types.ToolChoiceMemberTool{Value: types.SpecificToolChoice{Name: aws.String(choice.Tool)}}
+	default:
+		c.toolChoice = &types.ToolChoiceMemberAuto{Value: types.AutoToolChoice{}}
+	}
+	if c.toolConfig != nil {
+		c.toolConfig.ToolChoice = c.toolChoice
 	}
+	return nil
+}
 
+// SetStopSequences sets the stop sequences of the next requests.
+func (c *bedrockChat) SetStopSequences(stopSequences []string) error {
+	c.stopSequences = stopSequences
 	return nil
 }
 
@@ -558,14 +892,51 @@ func (r *bedrockResponse) Candidates() []Candidate {
 	return []Candidate{}
 }
 
+var _ ResponseMetadata = (*bedrockResponse)(nil)
+
+// StopReason returns why the model stopped
+func (r *bedrockResponse) StopReason() StopReason {
+	if r.output == nil {
+		return StopReasonUnknown
+	}
+	return bedrockStopReason(r.output.StopReason)
+}
+
+// ModelVersion returns the model of the request, as Bedrock doesn't report
+// the model that served it
+func (r *bedrockResponse) ModelVersion() string {
+	return r.model
+}
+
+// bedrockStopReason maps the stop reasons of Bedrock to StopReason
+func bedrockStopReason(reason types.StopReason) StopReason {
+	switch reason {
+	case "":
+		return StopReasonUnknown
+	case types.StopReasonEndTurn:
+		return StopReasonEnd
+	case types.StopReasonToolUse:
+		return StopReasonToolCalls
+	case types.StopReasonMaxTokens:
+		return StopReasonMaxTokens
+	case types.StopReasonStopSequence:
+		return StopReasonStopSequence
+	case types.StopReasonGuardrailIntervened, types.StopReasonContentFiltered:
+		return StopReasonContentFilter
+	}
+	return StopReasonOther
+}
+
 // bedrockStreamResponse implements ChatResponse for streaming responses
 type bedrockStreamResponse struct {
 	content       string
 	usage         *types.TokenUsage
 	model         string
 	done          bool
+	stopReason    types.StopReason
 	toolUses      []types.ToolUseBlock
 	streamingArgs map[int]map[string]any
+	parseErrors   map[int]string
 }
 
 // UsageMetadata returns the usage metadata from the streaming response
@@ -573,9 +944,21 @@ func (r *bedrockStreamResponse) UsageMetadata() any {
 	return r.usage
 }
 
+var _ ResponseMetadata = (*bedrockStreamResponse)(nil)
+
+// StopReason returns why the model stopped, in the last response of the stream
+func (r *bedrockStreamResponse) StopReason() StopReason {
+	return bedrockStopReason(r.stopReason)
+}
+
+// ModelVersion returns the model of the request
+func (r *bedrockStreamResponse) ModelVersion() string {
+	return r.model
+}
+
 // Candidates returns the candidate responses for streaming
 func (r *bedrockStreamResponse) Candidates() []Candidate {
-	if r.content == "" && r.usage == nil && len(r.toolUses) == 0 {
+	if r.content == "" && r.usage == nil && len(r.toolUses) == 0 && r.stopReason == "" {
 		return []Candidate{}
 	}
 
@@ -584,6 +967,7 @@ func (r *bedrockStreamResponse) Candidates() []Candidate {
 		model:         r.model,
 		toolUses:      r.toolUses,
 		streamingArgs: r.streamingArgs,
+		parseErrors:   r.parseErrors,
 	}
 	return []Candidate{candidate}
 }
@@ -633,6 +1017,7 @@ type bedrockStreamCandidate struct {
 	model         string
 	toolUses      []types.ToolUseBlock
 	streamingArgs map[int]map[string]any
+	parseErrors   map[int]string
 }
 
 // String returns a string representation of the streaming candidate
@@ -656,8 +1041,9 @@ func (c *bedrockStreamCandidate) Parts() []Part {
 			args = c.streamingArgs[i]
 		}
 		parts = append(parts, &bedrockToolPart{
-			toolUse: &toolUse,
-			args:    args,
+			toolUse:    &toolUse,
+			args:       args,
+			parseError: c.parseErrors[i],
 		})
 	}
 
@@ -683,6 +1069,8 @@ func (p *bedrockTextPart) AsFunctionCalls() ([]FunctionCall, bool) {
 type bedrockToolPart struct {
 	toolUse *types.ToolUseBlock
 	args    map[string]any // For streaming case when Input can't be unmarshaled
+	// parseError is set when the streamed input was not valid JSON
+	parseError string
 }
 
 // AsText returns empty string since this is a tool part
@@ -712,9 +1100,10 @@ func (p *bedrockToolPart) AsFunctionCalls() ([]FunctionCall, bool) {
 	}
 
 	funcCall := FunctionCall{
-		ID:        aws.ToString(p.toolUse.ToolUseId),
-		Name:      aws.ToString(p.toolUse.Name),
-		Arguments: args,
+		ID:         aws.ToString(p.toolUse.ToolUseId),
+		Name:       aws.ToString(p.toolUse.Name),
+		Arguments:  args,
+		ParseError: p.parseError,
 	}
 
 	return []FunctionCall{funcCall}, true
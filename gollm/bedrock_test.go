@@ -0,0 +1,387 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func textDelta(index int32, text string) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+		ContentBlockIndex: aws.Int32(index),
+		Delta:             &types.ContentBlockDeltaMemberText{Value: text},
+	}}
+}
+
+func toolStart(index int32, id, name string) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockStart{Value: types.ContentBlockStartEvent{
+		ContentBlockIndex: aws.Int32(index),
+		Start: &types.ContentBlockStartMemberToolUse{Value: types.ToolUseBlockStart{
+			ToolUseId: aws.String(id),
+			Name:      aws.String(name),
+		}},
+	}}
+}
+
+func toolDelta(index int32, input string) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockDelta{Value: types.ContentBlockDeltaEvent{
+		ContentBlockIndex: aws.Int32(index),
+		Delta:             &types.ContentBlockDeltaMemberToolUse{Value: types.ToolUseBlockDelta{Input: aws.String(input)}},
+	}}
+}
+
+func blockStop(index int32) types.ConverseStreamOutput {
+	return &types.ConverseStreamOutputMemberContentBlockStop{Value: types.ContentBlockStopEvent{
+		ContentBlockIndex: aws.Int32(index),
+	}}
+}
+
+// streamCalls returns the function calls and text of responses.
+func streamCalls(t *testing.T, responses []*bedrockStreamResponse) ([]FunctionCall, string) {
+	t.Helper()
+	var calls []FunctionCall
+	var text string
+	for _, response := range responses {
+		for _, candidate := range response.Candidates() {
+			for _, part := range candidate.Parts() {
+				if s, ok := part.AsText(); ok {
+					text += s
+				}
+				if c, ok := part.AsFunctionCalls(); ok {
+					calls = append(calls, c...)
+				}
+			}
+		}
+	}
+	return calls, text
+}
+
+func TestBedrockStreamState(t *testing.T) {
+	state := newBedrockStreamState("claude")
+	events := []types.ConverseStreamOutput{
+		textDelta(0, "Let me "),
+		textDelta(0, "check."),
+		blockStop(0),
+		toolStart(1, "call-1", "kubectl"),
+		toolStart(2, "call-2", "kubectl"),
+		// Deltas of the parallel calls are interleaved
+		toolDelta(1, `{"command":`),
+		toolDelta(2, `{"command":"kubectl get nodes"`),
+		toolDelta(1, `"kubectl get pods"}`),
+		toolDelta(2, `}`),
+		blockStop(2),
+		blockStop(1),
+		&types.ConverseStreamOutputMemberMessageStop{Value: types.MessageStopEvent{StopReason: types.StopReasonToolUse}},
+		&types.ConverseStreamOutputMemberMetadata{Value: types.ConverseStreamMetadataEvent{
+			Usage: &types.TokenUsage{InputTokens: aws.Int32(10), OutputTokens: aws.Int32(5), TotalTokens: aws.Int32(15)},
+		}},
+	}
+	var responses []*bedrockStreamResponse
+	for _, event := range events {
+		if response := state.handleEvent(event); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	responses = append(responses, state.finish()...)
+
+	calls, text := streamCalls(t, responses)
+	if text != "Let me check." {
+		t.Errorf("expected the streamed text, got %q", text)
+	}
+	want := []FunctionCall{
+		{ID: "call-2", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get nodes"}},
+		{ID: "call-1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected the calls in the order they completed, got %+v", calls)
+	}
+
+	last := responses[len(responses)-1]
+	if usage, ok := last.UsageMetadata().(*types.TokenUsage); !ok || aws.ToInt32(usage.TotalTokens) != 15 || !last.done {
+		t.Errorf("expected the usage of the metadata event, got %+v", last.UsageMetadata())
+	}
+	if got := ResponseStopReason(last); got != StopReasonToolCalls {
+		t.Errorf("expected the stop reason of the message stop event, got %q", got)
+	}
+
+	message := state.message()
+	if len(message.Content) != 3 {
+		t.Fatalf("expected 3 content blocks in the history, got %d", len(message.Content))
+	}
+	if block, ok := message.Content[0].(*types.ContentBlockMemberText); !ok || block.Value != "Let me check." {
+		t.Errorf("expected the text block first, got %#v", message.Content[0])
+	}
+	for i, id := range []string{"call-1", "call-2"} {
+		block, ok := message.Content[i+1].(*types.ContentBlockMemberToolUse)
+		if !ok || aws.ToString(block.Value.ToolUseId) != id {
+			t.Errorf("expected tool use %s at block %d, got %#v", id, i+1, message.Content[i+1])
+		}
+	}
+}
+
+func TestBedrockStreamState_IncompleteToolInput(t *testing.T) {
+	state := newBedrockStreamState("claude")
+	for _, event := range []types.ConverseStreamOutput{
+		toolStart(0, "call-1", "kubectl"),
+		toolDelta(0, `{"command":"kubectl get`),
+		// Input for a block that never started is ignored
+		toolDelta(3, `{}`),
+	} {
+		if response := state.handleEvent(event); response != nil {
+			t.Fatalf("unexpected response before the tool block stopped: %+v", response)
+		}
+	}
+
+	calls, _ := streamCalls(t, state.finish())
+	if len(calls) != 1 || calls[0].ID != "call-1" || calls[0].ParseError == "" {
+		t.Fatalf("expected the cut off call with a parse error, got %+v", calls)
+	}
+	if len(state.message().Content) != 1 {
+		t.Errorf("expected the cut off call in the history, got %+v", state.message().Content)
+	}
+}
+
+func TestBedrockStreamState_InvalidToolInput(t *testing.T) {
+	state := newBedrockStreamState("claude")
+	state.handleEvent(toolStart(0, "call-1", "kubectl"))
+	state.handleEvent(toolDelta(0, `{"command":`))
+	calls, _ := streamCalls(t, []*bedrockStreamResponse{state.handleEvent(blockStop(0))})
+	if len(calls) != 1 || calls[0].ParseError == "" || len(calls[0].Arguments) != 0 {
+		t.Errorf("expected a call with a parse error and no arguments, got %+v", calls)
+	}
+	if responses := state.finish(); len(responses) != 0 {
+		t.Errorf("expected no calls left to finish, got %d", len(responses))
+	}
+}
+
+func TestBedrockChat_DropToolOutputs(t *testing.T) {
+	chat := &bedrockChat{}
+	for _, id := range []string{"call-1", "call-2"} {
+		if err := chat.addContentsToHistory([]any{FunctionCallResult{ID: id, Name: "kubectl", Result: map[string]any{"stdout": "output of " + id}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dropped := chat.DropToolOutputs(1); dropped != 1 {
+		t.Fatalf("expected 1 output dropped, got %d", dropped)
+	}
+	for i, wantDropped := range []bool{true, false} {
+		result := chat.messages[i].Content[0].(*types.ContentBlockMemberToolResult)
+		if got := isDroppedBedrockToolOutput(result.Value.Content[0]); got != wantDropped {
+			t.Errorf("tool result %d: expected dropped=%v, got %+v", i, wantDropped, result.Value.Content)
+		}
+	}
+	if dropped := chat.DropToolOutputs(2); dropped != 1 {
+		t.Errorf("expected only the remaining output to be dropped, got %d", dropped)
+	}
+}
+
+func TestBedrockChat_RawContent(t *testing.T) {
+	chat := &bedrockChat{}
+	guard := &types.ContentBlockMemberGuardContent{Value: &types.GuardrailConverseContentBlockMemberText{
+		Value: types.GuardrailConverseTextBlock{Text: aws.String("check this")},
+	}}
+	err := chat.addContentsToHistory([]any{"summarize the pod", ProviderRawContent{Provider: "bedrock", Value: guard}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chat.messages[0].Content[1]; got != types.ContentBlock(guard) {
+		t.Errorf("expected the raw content block to be sent as is, got %#v", got)
+	}
+
+	err = chat.addContentsToHistory([]any{ProviderRawContent{Provider: "gemini", Value: map[string]any{"fileData": "gs://bucket/doc.pdf"}}})
+	if !errors.Is(err, ErrUnsupportedRawContent) {
+		t.Errorf("expected ErrUnsupportedRawContent for raw content of another provider, got %v", err)
+	}
+	if len(chat.messages) != 1 {
+		t.Errorf("rejected content was added to the history")
+	}
+}
+
+func TestBedrockChat_SetToolChoice(t *testing.T) {
+	chat := &bedrockChat{}
+	if err := chat.SetToolChoice(ToolChoice{Mode: ToolChoiceTool, Tool: "kubectl"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SetFunctionDefinitions([]*FunctionDefinition{{Name: "kubectl", Parameters: &Schema{Type: TypeObject}}}); err != nil {
+		t.Fatal(err)
+	}
+	want := &types.ToolChoiceMemberTool{Value: types.SpecificToolChoice{Name: aws.String("kubectl")}}
+	if got := chat.toolConfig.ToolChoice; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the tool choice to apply to the tools defined afterwards, got %#v", got)
+	}
+
+	if err := chat.SetToolChoice(ToolChoice{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := chat.toolConfig.ToolChoice.(*types.ToolChoiceMemberAuto); !ok {
+		t.Errorf("expected auto tool choice, got %#v", chat.toolConfig.ToolChoice)
+	}
+	if err := chat.SetToolChoice(ToolChoice{Mode: ToolChoiceNone}); err == nil {
+		t.Error("expected an error, Bedrock can't prevent tool calls")
+	}
+}
+
+func TestBedrockModelSupportsSeed(t *testing.T) {
+	for model, want := range map[string]bool{
+		"cohere.command-r-plus-v1:0":                 true,
+		"us.anthropic.claude-sonnet-4-20250514-v1:0": false,
+		"amazon.nova-pro-v1:0":                       false,
+	} {
+		if got := bedrockModelSupportsSeed(model); got != want {
+			t.Errorf("bedrockModelSupportsSeed(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
+
+func TestBedrockChat_PromptCaching(t *testing.T) {
+	functions := []*FunctionDefinition{{Name: "kubectl", Parameters: &Schema{Type: TypeObject}}}
+	for model, want := range map[string]bool{
+		"us.anthropic.claude-sonnet-4-20250514-v1:0": true,
+		"amazon.nova-pro-v1:0":                       true,
+		"cohere.command-r-plus-v1:0":                 false,
+	} {
+		chat := &bedrockChat{model: model, systemPrompt: "You are a Kubernetes assistant."}
+		if err := chat.SetFunctionDefinitions(functions); err != nil {
+			t.Fatal(err)
+		}
+		tools := chat.toolConfig.Tools
+		_, toolsCached := tools[len(tools)-1].(*types.ToolMemberCachePoint)
+		system := chat.systemBlocks()
+		_, systemCached := system[len(system)-1].(*types.SystemContentBlockMemberCachePoint)
+		if toolsCached != want || systemCached != want {
+			t.Errorf("%s: expected cache points %v, got %v for the tools and %v for the system prompt", model, want, toolsCached, systemCached)
+		}
+	}
+}
+
+func TestBedrockAWSConfig(t *testing.T) {
+	t.Setenv("BEDROCK_AWS_PROFILE", "sso-dev")
+	t.Setenv("BEDROCK_ROLE_ARN", "arn:aws:iam::123456789012:role/env")
+	t.Setenv("BEDROCK_ENDPOINT", "https://vpce-env.bedrock-runtime.us-east-1.vpce.amazonaws.com")
+	got := bedrockAWSConfig(ClientOptions{
+		AWS:      AWSConfig{RoleARN: "arn:aws:iam::123456789012:role/bedrock", Region: "eu-west-1"},
+		Endpoint: "https://vpce-flag.bedrock-runtime.eu-west-1.vpce.amazonaws.com",
+	})
+	want := AWSConfig{
+		Profile:  "sso-dev",
+		Region:   "eu-west-1",
+		RoleARN:  "arn:aws:iam::123456789012:role/bedrock",
+		Endpoint: "https://vpce-flag.bedrock-runtime.eu-west-1.vpce.amazonaws.com",
+	}
+	if got != want {
+		t.Errorf("bedrockAWSConfig() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewBedrockClient_AssumesRole(t *testing.T) {
+	var assumedRole, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if form, _ := url.ParseQuery(string(body)); form.Get("Action") == "AssumeRole" {
+			assumedRole = form.Get("RoleArn")
+			w.Header().Set("Content-Type", "text/xml")
+			io.WriteString(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult>
+<Credentials><AccessKeyId>ASSUMEDKEY</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials>
+<AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/bedrock/kubectl-ai</Arn><AssumedRoleId>AROA:kubectl-ai</AssumedRoleId></AssumedRoleUser>
+</AssumeRoleResult></AssumeRoleResponse>`)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"output":{"message":{"role":"assistant","content":[{"text":"hello"}]}},"stopReason":"end_turn","usage":{"inputTokens":1,"outputTokens":1,"totalTokens":2}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "BASEKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("AWS_ENDPOINT_URL_STS", server.URL)
+	client, err := NewBedrockClient(context.Background(), ClientOptions{
+		AWS: AWSConfig{
+			Region:   "eu-west-1",
+			RoleARN:  "arn:aws:iam::123456789012:role/bedrock",
+			Endpoint: server.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.GenerateCompletion(context.Background(), &CompletionRequest{Model: "us.anthropic.claude-sonnet-4-20250514-v1:0", Prompt: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Response() != "hello" {
+		t.Errorf("response = %q, want hello", response.Response())
+	}
+	if assumedRole != "arn:aws:iam::123456789012:role/bedrock" {
+		t.Errorf("assumed role %q", assumedRole)
+	}
+	if !strings.Contains(authorization, "Credential=ASSUMEDKEY/") || !strings.Contains(authorization, "/eu-west-1/bedrock/") {
+		t.Errorf("request wasn't signed with the assumed role in eu-west-1: %q", authorization)
+	}
+}
+
+func TestBedrockClient_Embed(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var body struct {
+			InputText  string `json:"inputText"`
+			Dimensions int    `json:"dimensions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"embedding":[%d,%d],"inputTextTokenCount":%d}`, len(body.InputText), body.Dimensions, len(body.InputText))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "KEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	client, err := NewBedrockClient(context.Background(), ClientOptions{AWS: AWSConfig{Region: "us-east-1", Endpoint: server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := Embed(context.Background(), client, &EmbeddingRequest{Texts: []string{"pods", "nodes!"}, Dimensions: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]float32{{4, 256}, {6, 256}}; !reflect.DeepEqual(response.Embeddings, want) {
+		t.Errorf("embeddings = %v, want %v", response.Embeddings, want)
+	}
+	if response.Usage.InputTokens != 10 {
+		t.Errorf("usage = %+v, want 10 input tokens", response.Usage)
+	}
+	if len(paths) != 2 || paths[0] != "/model/amazon.titan-embed-text-v2:0/invoke" {
+		t.Errorf("expected a request per text to the Titan model, got %v", paths)
+	}
+}
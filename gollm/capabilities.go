@@ -0,0 +1,84 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"strings"
+)
+
+// ModelCapabilities describes what a model supports.
+type ModelCapabilities struct {
+	// ContextWindow is the maximum number of tokens of a request, including
+	// the system prompt, the history and the tool definitions.
+	ContextWindow int64 `json:"contextWindow"`
+}
+
+// defaultCapabilities holds the advertised capabilities of commonly used
+// models. Keys are matched against model IDs, the longest matching key wins.
+var defaultCapabilities = map[string]ModelCapabilities{
+	// Gemini
+	"gemini-2.5-pro":        {ContextWindow: 1_048_576},
+	"gemini-2.5-flash":      {ContextWindow: 1_048_576},
+	"gemini-2.5-flash-lite": {ContextWindow: 1_048_576},
+	"gemini-2.0-flash":      {ContextWindow: 1_048_576},
+	"gemini-2.0-flash-lite": {ContextWindow: 1_048_576},
+	"gemma-3":               {ContextWindow: 131_072},
+
+	// OpenAI
+	"gpt-4o":  {ContextWindow: 128_000},
+	"gpt-4.1": {ContextWindow: 1_047_576},
+	"o4-mini": {ContextWindow: 200_000},
+
+	// xAI
+	"grok-3": {ContextWindow: 131_072},
+	"grok-4": {ContextWindow: 256_000},
+
+	// Mistral
+	"mistral-large":  {ContextWindow: 131_072},
+	"mistral-medium": {ContextWindow: 131_072},
+	"mistral-small":  {ContextWindow: 131_072},
+	"codestral":      {ContextWindow: 262_144},
+
+	// Anthropic (via Bedrock)
+	"claude-sonnet-4":   {ContextWindow: 200_000},
+	"claude-opus-4":     {ContextWindow: 200_000},
+	"claude-3-7-sonnet": {ContextWindow: 200_000},
+	"claude-3-5-sonnet": {ContextWindow: 200_000},
+	"claude-3-5-haiku":  {ContextWindow: 200_000},
+
+	// Open models, e.g. served by Ollama or llama.cpp
+	"llama3.1": {ContextWindow: 131_072},
+	"qwen3":    {ContextWindow: 40_960},
+}
+
+// CapabilitiesFor returns the advertised capabilities of model. An exact
+// match is preferred, otherwise the longest key contained in the model ID is
+// used. It returns false if the model is unknown.
+func CapabilitiesFor(model string) (ModelCapabilities, bool) {
+	model = strings.ToLower(model)
+	if c, ok := defaultCapabilities[model]; ok {
+		return c, true
+	}
+	var best string
+	for k := range defaultCapabilities {
+		if strings.Contains(model, k) && len(k) > len(best) {
+			best = k
+		}
+	}
+	if best == "" {
+		return ModelCapabilities{}, false
+	}
+	return defaultCapabilities[best], true
+}
@@ -0,0 +1,38 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import "testing"
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int64
+	}{
+		{"gemini-2.5-pro", 1_048_576},
+		{"gpt-4o-2024-08-06", 128_000},
+		{"us.anthropic.claude-sonnet-4-20250514-v1:0", 200_000},
+		{"GPT-4.1-mini", 1_047_576},
+	}
+	for _, tt := range tests {
+		c, ok := CapabilitiesFor(tt.model)
+		if !ok || c.ContextWindow != tt.want {
+			t.Errorf("CapabilitiesFor(%q) = %+v, %v, want a context window of %d", tt.model, c, ok, tt.want)
+		}
+	}
+	if _, ok := CapabilitiesFor("my-fine-tune"); ok {
+		t.Error("expected an unknown model to have no capabilities")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// CompletionCache caches the responses of GenerateCompletion, keyed by
+// provider, model and prompt, so that identical requests (e.g. for session
+// names) are not paid for repeatedly. It is safe for concurrent use, and can
+// be shared by several clients.
+type CompletionCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu sync.Mutex
+	// entries is ordered from the most to the least recently used.
+	entries *list.List
+	index   map[string]*list.Element
+	now     func() time.Time
+}
+
+type completionCacheEntry struct {
+	key      string
+	response string
+	expires  time.Time
+}
+
+// NewCompletionCache creates a cache keeping at most maxEntries responses for
+// ttl. A zero ttl keeps responses until they are evicted, and a zero
+// maxEntries doesn't limit the number of responses.
+func NewCompletionCache(ttl time.Duration, maxEntries int) *CompletionCache {
+	return &CompletionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		index:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func completionCacheKey(provider string, req *CompletionRequest) string {
+	h := sha256.New()
+	for _, s := range []string{provider, req.Model, req.Prompt} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *CompletionCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.index[key]
+	if !ok {
+		return "", false
+	}
+	entry := element.Value.(*completionCacheEntry)
+	if !entry.expires.IsZero() && c.now().After(entry.expires) {
+		c.entries.Remove(element)
+		delete(c.index, key)
+		return "", false
+	}
+	c.entries.MoveToFront(element)
+	return entry.response, true
+}
+
+func (c *CompletionCache) put(key string, response string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+	if element, ok := c.index[key]; ok {
+		entry := element.Value.(*completionCacheEntry)
+		entry.response = response
+		entry.expires = expires
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.index[key] = c.entries.PushFront(&completionCacheEntry{key: key, response: response, expires: expires})
+	for c.maxEntries > 0 && c.entries.Len() > c.maxEntries {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.index, oldest.Value.(*completionCacheEntry).key)
+	}
+}
+
+// Len returns the number of cached responses, including expired ones not
+// evicted yet.
+func (c *CompletionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+// cachingClient answers GenerateCompletion from a CompletionCache when it
+// can. Cached responses have no usage, as they cost nothing.
+type cachingClient struct {
+	Client
+	provider string
+	cache    *CompletionCache
+}
+
+// withCompletionCache returns client caching its completions in cache, or
+// client itself if cache is nil.
+func withCompletionCache(client Client, provider string, cache *CompletionCache) Client {
+	if cache == nil {
+		return client
+	}
+	return &cachingClient{Client: client, provider: provider, cache: cache}
+}
+
+// Embed computes embeddings with the client, without caching them.
+func (c *cachingClient) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	return Embed(ctx, c.Client, req)
+}
+
+func (c *cachingClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	key := completionCacheKey(c.provider, req)
+	if response, ok := c.cache.get(key); ok {
+		klog.V(2).Infof("Using cached completion for model %q", req.Model)
+		return &cachedCompletionResponse{response: response}, nil
+	}
+	response, err := c.Client.GenerateCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.put(key, response.Response())
+	return response, nil
+}
+
+// GenerateCompletionStreaming returns a cached response as a single chunk.
+// Streamed responses are cached once the stream completes.
+func (c *cachingClient) GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error) {
+	key := completionCacheKey(c.provider, req)
+	if response, ok := c.cache.get(key); ok {
+		klog.V(2).Infof("Using cached completion for model %q", req.Model)
+		return func(yield func(CompletionResponse, error) bool) {
+			yield(&cachedCompletionResponse{response: response}, nil)
+		}, nil
+	}
+	stream, err := c.Client.GenerateCompletionStreaming(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(CompletionResponse, error) bool) {
+		var response strings.Builder
+		for chunk, err := range stream {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			response.WriteString(chunk.Response())
+			if !yield(chunk, nil) {
+				return
+			}
+		}
+		c.cache.put(key, response.String())
+	}, nil
+}
+
+type cachedCompletionResponse struct {
+	response string
+}
+
+func (r *cachedCompletionResponse) Response() string {
+	return r.response
+}
+
+func (r *cachedCompletionResponse) UsageMetadata() any {
+	return nil
+}
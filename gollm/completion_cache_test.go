@@ -0,0 +1,167 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingClient answers completions with the prompt and the number of calls.
+type countingClient struct {
+	Client
+	calls int
+	err   error
+}
+
+func (c *countingClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &cachedCompletionResponse{response: fmt.Sprintf("%s #%d", req.Prompt, c.calls)}, nil
+}
+
+// GenerateCompletionStreaming streams the completion word by word.
+func (c *countingClient) GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error) {
+	response, err := c.GenerateCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(CompletionResponse, error) bool) {
+		for _, word := range strings.SplitAfter(response.Response(), " ") {
+			if !yield(&cachedCompletionResponse{response: word}, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+func TestCompletionCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewCompletionCache(time.Hour, 2)
+	cache.now = func() time.Time { return now }
+
+	inner := &countingClient{}
+	client := withCompletionCache(inner, "gemini", cache)
+	complete := func(model, prompt string) string {
+		t.Helper()
+		response, err := client.GenerateCompletion(ctx, &CompletionRequest{Model: model, Prompt: prompt})
+		if err != nil {
+			t.Fatalf("GenerateCompletion() error = %v", err)
+		}
+		return response.Response()
+	}
+
+	if got := complete("flash", "name"); got != "name #1" {
+		t.Errorf("first completion = %q", got)
+	}
+	if got := complete("flash", "name"); got != "name #1" {
+		t.Errorf("expected the cached completion, got %q", got)
+	}
+	if got := complete("pro", "name"); got != "name #2" {
+		t.Errorf("expected another model not to use the cache, got %q", got)
+	}
+	other := withCompletionCache(inner, "openai", cache)
+	if response, _ := other.GenerateCompletion(ctx, &CompletionRequest{Model: "flash", Prompt: "name"}); response.Response() != "name #3" {
+		t.Errorf("expected another provider not to use the cache, got %q", response.Response())
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected the cache to be limited to 2 entries, got %d", cache.Len())
+	}
+	// The least recently used entry, gemini/flash, was evicted
+	if got := complete("flash", "name"); got != "name #4" {
+		t.Errorf("expected the evicted completion to be generated again, got %q", got)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if got := complete("flash", "name"); got != "name #5" {
+		t.Errorf("expected the expired completion to be generated again, got %q", got)
+	}
+
+	inner.err = fmt.Errorf("quota exceeded")
+	if _, err := client.GenerateCompletion(ctx, &CompletionRequest{Model: "flash", Prompt: "failing"}); err == nil {
+		t.Fatal("expected the error of the client")
+	}
+	inner.err = nil
+	if got := complete("flash", "failing"); got != "failing #7" {
+		t.Errorf("expected errors not to be cached, got %q", got)
+	}
+}
+
+func TestCachedCompletionHasNoUsage(t *testing.T) {
+	cache := NewCompletionCache(0, 0)
+	client := withCompletionCache(&countingClient{}, "gemini", cache)
+	req := &CompletionRequest{Model: "flash", Prompt: "name"}
+	if _, err := client.GenerateCompletion(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.GenerateCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage := response.UsageMetadata(); usage != nil {
+		t.Errorf("expected no usage for a cached completion, got %v", usage)
+	}
+	if withCompletionCache(client, "gemini", nil) != client {
+		t.Error("expected no wrapping without a cache")
+	}
+}
+
+func TestCompletionCacheStreaming(t *testing.T) {
+	ctx := context.Background()
+	client := withCompletionCache(&countingClient{}, "gemini", NewCompletionCache(0, 0))
+	stream := func(prompt string, maxChunks int) (string, int) {
+		t.Helper()
+		chunks, err := client.GenerateCompletionStreaming(ctx, &CompletionRequest{Model: "flash", Prompt: prompt})
+		if err != nil {
+			t.Fatalf("GenerateCompletionStreaming() error = %v", err)
+		}
+		var response strings.Builder
+		n := 0
+		for chunk, err := range chunks {
+			if err != nil {
+				t.Fatalf("stream error = %v", err)
+			}
+			response.WriteString(chunk.Response())
+			if n++; n == maxChunks {
+				break
+			}
+		}
+		return response.String(), n
+	}
+
+	if got, n := stream("session name", -1); got != "session name #1" || n != 3 {
+		t.Errorf("first completion = %q in %d chunks", got, n)
+	}
+	if got, n := stream("session name", -1); got != "session name #1" || n != 1 {
+		t.Errorf("expected the cached completion in a single chunk, got %q in %d chunks", got, n)
+	}
+	if response, _ := client.GenerateCompletion(ctx, &CompletionRequest{Model: "flash", Prompt: "session name"}); response.Response() != "session name #1" {
+		t.Errorf("expected the streamed completion to be cached for GenerateCompletion, got %q", response.Response())
+	}
+
+	// Streams stopped early are not cached
+	if got, _ := stream("docs", 1); got != "docs " {
+		t.Errorf("first chunk = %q", got)
+	}
+	if got, _ := stream("docs", -1); got != "docs #3" {
+		t.Errorf("expected the interrupted completion to be generated again, got %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"strings"
+)
+
+// ModelPricing is the price of a model in USD per million tokens.
+type ModelPricing struct {
+	InputPerMillion  float64 `json:"inputPerMillion"`
+	OutputPerMillion float64 `json:"outputPerMillion"`
+}
+
+// defaultPricing holds public list prices for commonly used models.
+// Keys are matched against model IDs, the longest matching key wins.
+// Prices change frequently, so these are only estimates; use overrides
+// for private or negotiated pricing.
+var defaultPricing = map[string]ModelPricing{
+	// Gemini
+	"gemini-2.5-pro":        {InputPerMillion: 1.25, OutputPerMillion: 10.00},
+	"gemini-2.5-flash":      {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	"gemini-2.5-flash-lite": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.0-flash":      {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.0-flash-lite": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+
+	// OpenAI
+	"gpt-4o":       {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+	"gpt-4o-mini":  {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	"gpt-4.1":      {InputPerMillion: 2.00, OutputPerMillion: 8.00},
+	"gpt-4.1-mini": {InputPerMillion: 0.40, OutputPerMillion: 1.60},
+	"gpt-4.1-nano": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"o4-mini":      {InputPerMillion: 1.10, OutputPerMillion: 4.40},
+
+	// xAI
+	"grok-3":      {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"grok-3-mini": {InputPerMillion: 0.30, OutputPerMillion: 0.50},
+
+	// Mistral
+	"mistral-large":  {InputPerMillion: 2.00, OutputPerMillion: 6.00},
+	"mistral-medium": {InputPerMillion: 0.40, OutputPerMillion: 2.00},
+	"mistral-small":  {InputPerMillion: 0.10, OutputPerMillion: 0.30},
+	"codestral":      {InputPerMillion: 0.30, OutputPerMillion: 0.90},
+
+	// Anthropic (via Bedrock)
+	"claude-sonnet-4":   {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"claude-3-7-sonnet": {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"claude-3-5-sonnet": {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+	"claude-3-5-haiku":  {InputPerMillion: 0.80, OutputPerMillion: 4.00},
+}
+
+// CostEstimator converts token usage into estimated dollar costs.
+type CostEstimator struct {
+	pricing map[string]ModelPricing
+}
+
+// NewCostEstimator returns a CostEstimator using the default pricing table,
+// with any entries in overrides taking precedence.
+func NewCostEstimator(overrides map[string]ModelPricing) *CostEstimator {
+	pricing := make(map[string]ModelPricing, len(defaultPricing)+len(overrides))
+	for k, v := range defaultPricing {
+		pricing[k] = v
+	}
+	for k, v := range overrides {
+		pricing[strings.ToLower(k)] = v
+	}
+	return &CostEstimator{pricing: pricing}
+}
+
+// PricingFor returns the pricing for the given model.
+// An exact match is preferred, otherwise the longest key contained in the model ID is used.
+func (e *CostEstimator) PricingFor(model string) (ModelPricing, bool) {
+	model = strings.ToLower(model)
+	if p, ok := e.pricing[model]; ok {
+		return p, true
+	}
+	var best string
+	for k := range e.pricing {
+		if strings.Contains(model, k) && len(k) > len(best) {
+			best = k
+		}
+	}
+	if best == "" {
+		return ModelPricing{}, false
+	}
+	return e.pricing[best], true
+}
+
+// Estimate returns the estimated cost in USD of the given usage for model.
+// It returns false if no pricing is known for the model.
+func (e *CostEstimator) Estimate(model string, usage Usage) (float64, bool) {
+	p, ok := e.PricingFor(model)
+	if !ok {
+		return 0, false
+	}
+	cost := float64(usage.InputTokens)*p.InputPerMillion/1_000_000 +
+		float64(usage.OutputTokens)*p.OutputPerMillion/1_000_000
+	return cost, true
+}
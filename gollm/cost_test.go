@@ -0,0 +1,100 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+func TestCostEstimator_Estimate(t *testing.T) {
+	estimator := NewCostEstimator(map[string]ModelPricing{
+		"my-private-model": {InputPerMillion: 1, OutputPerMillion: 2},
+		"gpt-4o":           {InputPerMillion: 1, OutputPerMillion: 1},
+	})
+	usage := Usage{InputTokens: 1_000_000, OutputTokens: 500_000}
+
+	tests := []struct {
+		model string
+		want  float64
+		known bool
+	}{
+		{model: "gemini-2.5-pro", want: 1.25 + 5, known: true},
+		{model: "gemini-2.5-flash-lite", want: 0.10 + 0.20, known: true},
+		{model: "gpt-4o", want: 1.5, known: true},
+		{model: "gpt-4o-mini-2024-07-18", want: 0.15 + 0.30, known: true},
+		{model: "us.anthropic.claude-sonnet-4-20250514-v1:0", want: 3 + 7.5, known: true},
+		{model: "My-Private-Model", want: 2, known: true},
+		{model: "unknown-model", known: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, ok := estimator.Estimate(tt.model, usage)
+			if ok != tt.known {
+				t.Fatalf("Estimate(%q) known = %v, want %v", tt.model, ok, tt.known)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Estimate(%q) = %v, want %v", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata any
+		want     *Usage
+	}{
+		{
+			name:     "gemini",
+			metadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 5, TotalTokenCount: 15},
+			want:     &Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		},
+		{
+			name:     "openai",
+			metadata: openai.CompletionUsage{PromptTokens: 7, CompletionTokens: 3},
+			want:     &Usage{InputTokens: 7, OutputTokens: 3, TotalTokens: 10},
+		},
+		{
+			name:     "nil",
+			metadata: nil,
+		},
+		{
+			name:     "unknown type",
+			metadata: "tokens",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NormalizeUsage(tt.metadata)
+			if tt.want == nil {
+				if ok {
+					t.Fatalf("expected no usage, got %+v", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("expected usage, got none")
+			}
+			if *got != *tt.want {
+				t.Errorf("got %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
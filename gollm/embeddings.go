@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// EmbeddingTask is what embeddings are computed for. Some models compute
+// different vectors for the documents searched and the queries searching them.
+type EmbeddingTask string
+
+const (
+	// EmbeddingTaskUnspecified lets the model compute general purpose
+	// embeddings.
+	EmbeddingTaskUnspecified EmbeddingTask = ""
+	// EmbeddingTaskDocument is for the documents of a search, e.g. the
+	// chunks of runbooks indexed for retrieval.
+	EmbeddingTaskDocument EmbeddingTask = "document"
+	// EmbeddingTaskQuery is for the queries of a search.
+	EmbeddingTaskQuery EmbeddingTask = "query"
+)
+
+// EmbeddingRequest is a request to compute the embeddings of texts.
+type EmbeddingRequest struct {
+	// Model is the embedding model, the default model of the provider if
+	// empty.
+	Model string
+	Texts []string
+	Task  EmbeddingTask
+	// Dimensions is the size of the vectors, for the models that support
+	// several. Zero is the default size of the model.
+	Dimensions int
+}
+
+// EmbeddingResponse holds the embeddings of the texts of a request, in order.
+type EmbeddingResponse struct {
+	Embeddings [][]float32
+	// Model is the model that computed the embeddings.
+	Model string
+	// Usage is the usage of the request, if the provider reports it.
+	Usage *Usage
+}
+
+// Embedder is implemented by the clients that compute embeddings.
+type Embedder interface {
+	// Embed computes the embeddings of the texts of req.
+	Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// ErrEmbeddingsUnsupported is wrapped by the errors of Embed for clients that
+// don't compute embeddings.
+var ErrEmbeddingsUnsupported = errors.New("embeddings are not supported")
+
+// Embed computes the embeddings of the texts of req with client, if it
+// supports it, see Embedder.
+func Embed(ctx context.Context, client Client, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	embedder, ok := client.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w by %T", ErrEmbeddingsUnsupported, client)
+	}
+	if len(req.Texts) == 0 {
+		return &EmbeddingResponse{Model: req.Model}, nil
+	}
+	response, err := embedder.Embed(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Embeddings) != len(req.Texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(response.Embeddings), len(req.Texts))
+	}
+	return response, nil
+}
+
+// CosineSimilarity returns the cosine similarity of a and b, between -1 and
+// 1, or 0 if their sizes differ or one of them is zero.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
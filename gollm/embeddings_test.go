@@ -0,0 +1,113 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestEmbed_Mock(t *testing.T) {
+	// Embeddings go through the wrappers of NewClient
+	client := newTestMockClient(t)
+	docs := []string{
+		"Pods in CrashLoopBackOff: check the logs of the previous container.",
+		"Nodes NotReady: check the kubelet and the network of the node.",
+	}
+	response, err := Embed(context.Background(), client, &EmbeddingRequest{Texts: docs, Task: EmbeddingTaskDocument})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := Embed(context.Background(), client, &EmbeddingRequest{Texts: []string{"why is my pod in CrashLoopBackOff?"}, Task: EmbeddingTaskQuery})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := CosineSimilarity(query.Embeddings[0], response.Embeddings[0])
+	nodes := CosineSimilarity(query.Embeddings[0], response.Embeddings[1])
+	if pods <= nodes {
+		t.Errorf("expected the query to be closer to the pods runbook, got %f and %f", pods, nodes)
+	}
+}
+
+func TestEmbed_Unsupported(t *testing.T) {
+	client := &classifyingClient{Client: struct{ Client }{}}
+	_, err := Embed(context.Background(), client, &EmbeddingRequest{Texts: []string{"hello"}})
+	if !errors.Is(err, ErrEmbeddingsUnsupported) {
+		t.Errorf("err = %v, want ErrEmbeddingsUnsupported", err)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float64
+	}{
+		{a: []float32{1, 0}, b: []float32{2, 0}, want: 1},
+		{a: []float32{1, 0}, b: []float32{0, 1}, want: 0},
+		{a: []float32{1, 0}, b: []float32{-1, 0}, want: -1},
+		{a: []float32{1, 0}, b: []float32{0, 0}, want: 0},
+		{a: []float32{1, 0}, b: []float32{1}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := CosineSimilarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("CosineSimilarity(%v, %v) = %f, want %f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAIClient_Embed(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Header().Set("Content-Type", "application/json")
+		// The embeddings are matched to the texts by index
+		io.WriteString(w, `{"object":"list","model":"mistral-embed","data":[
+{"object":"embedding","index":1,"embedding":[0.5,0.25]},
+{"object":"embedding","index":0,"embedding":[1,0]}],
+"usage":{"prompt_tokens":7,"total_tokens":7}}`)
+	}))
+	defer server.Close()
+
+	client := &OpenAIClient{
+		client:         openai.NewClient(option.WithAPIKey("key"), option.WithBaseURL(server.URL)),
+		embeddingModel: "mistral-embed",
+	}
+	response, err := Embed(context.Background(), client, &EmbeddingRequest{Texts: []string{"a", "b"}, Dimensions: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request["model"] != "mistral-embed" || request["dimensions"] != float64(2) || !reflect.DeepEqual(request["input"], []any{"a", "b"}) {
+		t.Errorf("unexpected request %v", request)
+	}
+	if want := [][]float32{{1, 0}, {0.5, 0.25}}; !reflect.DeepEqual(response.Embeddings, want) {
+		t.Errorf("embeddings = %v, want %v", response.Embeddings, want)
+	}
+	if response.Model != "mistral-embed" || response.Usage.InputTokens != 7 {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
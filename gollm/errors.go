@@ -0,0 +1,300 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	ollamaapi "github.com/ollama/ollama/api"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// categorizedError is the implementation shared by the error categories.
+type categorizedError struct {
+	description string
+	Err         error
+}
+
+func (e categorizedError) Error() string {
+	return e.description + ": " + e.Err.Error()
+}
+
+func (e categorizedError) Unwrap() error {
+	return e.Err
+}
+
+// AuthError is returned when the provider rejects the credentials, or denies
+// access to the model. Re-authenticating may help.
+type AuthError struct{ categorizedError }
+
+// QuotaError is returned when a quota or rate limit of the provider is exceeded.
+type QuotaError struct{ categorizedError }
+
+// ContextLengthError is returned when the request doesn't fit in the context
+// window of the model. Compressing the history may help.
+type ContextLengthError struct{ categorizedError }
+
+// ContentFilteredError is returned when the provider's content filter blocks
+// the request or the response.
+type ContentFilteredError struct{ categorizedError }
+
+// ModelNotFoundError is returned when the provider doesn't know the model, or
+// it isn't deployed. Another model may be used instead, see ClosestModel.
+type ModelNotFoundError struct{ categorizedError }
+
+// TransientError is returned when the provider is temporarily unavailable.
+// The request can be retried.
+type TransientError struct{ categorizedError }
+
+// providerError is what the errors of the providers' SDKs have in common.
+type providerError struct {
+	statusCode int
+	code       string
+	message    string
+}
+
+// asProviderError extracts the status code, error code and message of the
+// error of a provider's SDK in the chain of err.
+func asProviderError(err error) (*providerError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return &providerError{statusCode: apiErr.StatusCode, message: apiErr.Message}, true
+	}
+	// Gemini and Vertex AI
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return &providerError{statusCode: geminiErr.Code, code: geminiErr.Status, message: geminiErr.Message}, true
+	}
+	// OpenAI and compatible servers, e.g. Grok
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		code := openaiErr.Code
+		if code == "" {
+			code = openaiErr.Type
+		}
+		return &providerError{statusCode: openaiErr.StatusCode, code: code, message: openaiErr.Message}, true
+	}
+	// Azure OpenAI
+	var azureErr *azcore.ResponseError
+	if errors.As(err, &azureErr) {
+		return &providerError{statusCode: azureErr.StatusCode, code: azureErr.ErrorCode, message: azureErr.Error()}, true
+	}
+	// Ollama
+	var ollamaErr ollamaapi.StatusError
+	if errors.As(err, &ollamaErr) {
+		return &providerError{statusCode: ollamaErr.StatusCode, message: ollamaErr.ErrorMessage}, true
+	}
+	// Bedrock, the AWS SDK errors implement smithy.APIError
+	var awsErr interface {
+		ErrorCode() string
+		ErrorMessage() string
+	}
+	if errors.As(err, &awsErr) {
+		e := &providerError{code: awsErr.ErrorCode(), message: awsErr.ErrorMessage()}
+		var httpErr interface{ HTTPStatusCode() int }
+		if errors.As(err, &httpErr) {
+			e.statusCode = httpErr.HTTPStatusCode()
+		}
+		return e, true
+	}
+	return nil, false
+}
+
+var (
+	// contextLengthMessages are the messages of the providers when the
+	// request is too long: a max_tokens setting that is too large for the
+	// model is a different error, and isn't fixed by shortening the history.
+	contextLengthMessages = []string{
+		"maximum context length",                      // OpenAI and compatible servers
+		"exceeds the maximum number of tokens",        // Gemini and Vertex AI
+		"prompt is too long",                          // Anthropic
+		"input is too long", "too long for the model", // Bedrock
+		"exceeds the context window", "input length exceeds the context length", // Ollama, llama.cpp
+	}
+	contentFilteredMessages = []string{
+		"content filter", "content_filter", "content management policy", "responsibleai",
+		"safety", "blocked",
+	}
+	modelNotFoundMessages = []string{
+		"does not exist or you do not have access to it", // OpenAI
+		"is not found for api version",                   // Gemini
+		"model identifier is invalid",                    // Bedrock
+		"not found, try pulling it first",                // Ollama
+		"model not found", "unknown model",
+	}
+	contextLengthCodes = []string{"context_length_exceeded", "string_above_max_length"}
+	modelNotFoundCodes = []string{"model_not_found", "DeploymentNotFound"}
+	authCodes          = []string{"invalid_api_key", "UNAUTHENTICATED", "PERMISSION_DENIED", "AccessDeniedException", "UnrecognizedClientException", "ExpiredTokenException"}
+	quotaCodes         = []string{"insufficient_quota", "rate_limit_exceeded", "RESOURCE_EXHAUSTED", "ThrottlingException", "ServiceQuotaExceededException"}
+	transientCodes     = []string{"UNAVAILABLE", "INTERNAL", "DEADLINE_EXCEEDED", "server_error", "ServiceUnavailableException", "InternalServerException", "ModelNotReadyException", "ModelTimeoutException"}
+)
+
+func containsAny(s string, substrings []string) bool {
+	s = strings.ToLower(s)
+	for _, substring := range substrings {
+		if strings.Contains(s, strings.ToLower(substring)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyError wraps err in the error type of its category, e.g.
+// *ContextLengthError, based on the error returned by the provider. Errors
+// that don't fit a category, or are already classified, are returned as is.
+func ClassifyError(err error) error {
+	if err == nil || errorCategory(err) != "" {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	e, ok := asProviderError(err)
+	if !ok {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return &TransientError{categorizedError{"the LLM provider timed out", err}}
+		}
+		return err
+	}
+
+	text := e.code + " " + e.message
+	switch {
+	case slices.Contains(contextLengthCodes, e.code) || containsAny(e.message, contextLengthMessages) && (e.statusCode == 0 || e.statusCode == http.StatusBadRequest || e.statusCode == http.StatusRequestEntityTooLarge):
+		return &ContextLengthError{categorizedError{"the request exceeds the context window of the model", err}}
+	case containsAny(text, contentFilteredMessages) && (e.statusCode == 0 || e.statusCode == http.StatusBadRequest):
+		return &ContentFilteredError{categorizedError{"the request was blocked by the content filter of the provider", err}}
+	case slices.Contains(modelNotFoundCodes, e.code) || containsAny(e.message, modelNotFoundMessages) && (e.statusCode == 0 || e.statusCode == http.StatusBadRequest || e.statusCode == http.StatusNotFound):
+		return &ModelNotFoundError{categorizedError{"the model was not found", err}}
+	case e.statusCode == http.StatusUnauthorized || e.statusCode == http.StatusForbidden || slices.Contains(authCodes, e.code):
+		return &AuthError{categorizedError{"the LLM provider rejected the credentials", err}}
+	case e.statusCode == http.StatusTooManyRequests || slices.Contains(quotaCodes, e.code):
+		return &QuotaError{categorizedError{"the quota or rate limit of the LLM provider was exceeded", err}}
+	case e.statusCode == http.StatusRequestTimeout || e.statusCode == http.StatusConflict || e.statusCode >= 500 || slices.Contains(transientCodes, e.code):
+		return &TransientError{categorizedError{"the LLM provider is temporarily unavailable", err}}
+	}
+	return err
+}
+
+// errorCategory returns the category of err, or "" if it isn't classified.
+func errorCategory(err error) string {
+	var (
+		authErr      *AuthError
+		quotaErr     *QuotaError
+		contextErr   *ContextLengthError
+		filteredErr  *ContentFilteredError
+		notFoundErr  *ModelNotFoundError
+		transientErr *TransientError
+	)
+	switch {
+	case errors.As(err, &authErr):
+		return "auth"
+	case errors.As(err, &quotaErr):
+		return "quota"
+	case errors.As(err, &contextErr):
+		return "context_length"
+	case errors.As(err, &filteredErr):
+		return "content_filtered"
+	case errors.As(err, &notFoundErr):
+		return "model_not_found"
+	case errors.As(err, &transientErr):
+		return "transient"
+	}
+	return ""
+}
+
+// classifyingClient classifies the errors of a client with ClassifyError.
+type classifyingClient struct {
+	Client
+}
+
+func (c *classifyingClient) StartChat(systemPrompt, model string) Chat {
+	return &classifyingChat{Chat: c.Client.StartChat(systemPrompt, model)}
+}
+
+func (c *classifyingClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	response, err := c.Client.GenerateCompletion(ctx, req)
+	return response, ClassifyError(err)
+}
+
+func (c *classifyingClient) GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error) {
+	stream, err := c.Client.GenerateCompletionStreaming(ctx, req)
+	if err != nil {
+		return nil, ClassifyError(err)
+	}
+	return func(yield func(CompletionResponse, error) bool) {
+		for response, err := range stream {
+			if !yield(response, ClassifyError(err)) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (c *classifyingClient) ListModels(ctx context.Context) ([]string, error) {
+	models, err := c.Client.ListModels(ctx)
+	return models, ClassifyError(err)
+}
+
+// Embed classifies the errors of the embeddings of the client, if it computes
+// embeddings.
+func (c *classifyingClient) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	response, err := Embed(ctx, c.Client, req)
+	return response, ClassifyError(err)
+}
+
+type classifyingChat struct {
+	Chat
+}
+
+func (c *classifyingChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	response, err := c.Chat.Send(ctx, contents...)
+	return response, ClassifyError(err)
+}
+
+func (c *classifyingChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	stream, err := c.Chat.SendStreaming(ctx, contents...)
+	if err != nil {
+		return nil, ClassifyError(err)
+	}
+	return func(yield func(ChatResponse, error) bool) {
+		for response, err := range stream {
+			if !yield(response, ClassifyError(err)) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (c *classifyingChat) Initialize(messages []*api.Message) error {
+	return c.Chat.Initialize(messages)
+}
+
+func (c *classifyingChat) DropToolOutputs(count int) int {
+	return DropToolOutputs(c.Chat, count)
+}
+
+func (c *classifyingChat) PruneToolOutputs(keep int, prune func(output string) (string, bool)) int {
+	return PruneToolOutputs(c.Chat, keep, prune)
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// awsError implements the smithy.APIError interface of the AWS SDK errors.
+type awsError struct {
+	code, message string
+}
+
+func (e *awsError) Error() string        { return e.code + ": " + e.message }
+func (e *awsError) ErrorCode() string    { return e.code }
+func (e *awsError) ErrorMessage() string { return e.message }
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unauthorized", &APIError{StatusCode: 401, Message: "invalid api key"}, "auth"},
+		{"gemini quota", genai.APIError{Code: 429, Status: "RESOURCE_EXHAUSTED", Message: "quota exceeded"}, "quota"},
+		{"gemini context length", genai.APIError{Code: 400, Message: "The input token count exceeds the maximum number of tokens allowed"}, "context_length"},
+		{"openai context length", &APIError{StatusCode: 400, Message: "This model's maximum context length is 128000 tokens"}, "context_length"},
+		{"openai context length code", &openai.Error{Code: "context_length_exceeded", Message: "Please reduce the length of the messages.", StatusCode: 400, Request: httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil), Response: &http.Response{StatusCode: 400}}, "context_length"},
+		{"max tokens too large", &APIError{StatusCode: 400, Message: "max_tokens is too large: 200000. This model supports at most 8192 completion tokens"}, ""},
+		{"token limit of the output", &APIError{StatusCode: 400, Message: "max_tokens exceeds the token limit of the model"}, ""},
+		{"content filter", &APIError{StatusCode: 400, Message: "The response was filtered due to the prompt triggering the content management policy"}, "content_filtered"},
+		{"unavailable", fmt.Errorf("sending: %w", &APIError{StatusCode: 503, Message: "overloaded"}), "transient"},
+		{"bedrock throttling", &awsError{"ThrottlingException", "Too many requests"}, "quota"},
+		{"bedrock access denied", &awsError{"AccessDeniedException", "no access to the model"}, "auth"},
+		{"bedrock input too long", &awsError{"ValidationException", "Input is too long for requested model"}, "context_length"},
+		{"openai model not found", &APIError{StatusCode: 404, Message: "The model `gpt-5-turbo` does not exist or you do not have access to it."}, "model_not_found"},
+		{"gemini model not found", genai.APIError{Code: 404, Status: "NOT_FOUND", Message: "models/gemini-9 is not found for API version v1beta"}, "model_not_found"},
+		{"bedrock invalid model", &awsError{"ValidationException", "The provided model identifier is invalid."}, "model_not_found"},
+		{"ollama model not found", &APIError{StatusCode: 404, Message: `model "llama9" not found, try pulling it first`}, "model_not_found"},
+		{"not found endpoint", &APIError{StatusCode: 404, Message: "404 page not found, check the model server endpoint"}, ""},
+		{"not found deployment resource", &APIError{StatusCode: 404, Message: "deployment web of the model registry was not found"}, ""},
+		{"bad request", &APIError{StatusCode: 400, Message: "invalid tool schema"}, ""},
+		{"unknown", errors.New("something went wrong"), ""},
+		{"canceled", context.Canceled, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ClassifyError(tt.err)
+			if got := errorCategory(err); got != tt.want {
+				t.Errorf("expected category %q, got %q for %v", tt.want, got, err)
+			}
+			if !strings.Contains(err.Error(), tt.err.Error()) {
+				t.Errorf("expected the classified error to include the original error, got %v", err)
+			}
+			if again := ClassifyError(err); again != err {
+				t.Errorf("expected a classified error to be returned as is, got %v", again)
+			}
+		})
+	}
+}
+
+func TestDefaultIsRetryableError_Categories(t *testing.T) {
+	if !DefaultIsRetryableError(&QuotaError{categorizedError{"quota", errors.New("slow down")}}) {
+		t.Error("expected quota errors to be retryable")
+	}
+	if !DefaultIsRetryableError(ClassifyError(&APIError{StatusCode: 503, Message: "overloaded"})) {
+		t.Error("expected server errors to be retryable")
+	}
+	if DefaultIsRetryableError(&ContextLengthError{categorizedError{"context length", &APIError{StatusCode: 500}}}) {
+		t.Error("expected context length errors not to be retryable")
+	}
+}
+
+// failingChat fails to send, or fails in the middle of the stream.
+type failingChat struct {
+	Chat
+	err error
+}
+
+func (c *failingChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	return nil, c.err
+}
+
+func (c *failingChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	return func(yield func(ChatResponse, error) bool) {
+		if !yield(&mockChatResponse{text: "partial"}, nil) {
+			return
+		}
+		yield(nil, c.err)
+	}, nil
+}
+
+func TestClassifyingChat(t *testing.T) {
+	chat := &classifyingChat{Chat: &failingChat{err: &APIError{StatusCode: 403, Message: "forbidden"}}}
+
+	var authErr *AuthError
+	if _, err := chat.Send(context.Background(), "hi"); !errors.As(err, &authErr) {
+		t.Errorf("expected an auth error from Send, got %v", err)
+	}
+
+	stream, err := chat.SendStreaming(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("SendStreaming() error = %v", err)
+	}
+	var errs []error
+	for _, err := range stream {
+		errs = append(errs, err)
+	}
+	if len(errs) != 2 || errs[0] != nil || !errors.As(errs[1], &authErr) {
+		t.Errorf("expected an auth error at the end of the stream, got %v", errs)
+	}
+}
@@ -17,6 +17,7 @@ package gollm
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"maps"
@@ -31,6 +32,8 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"golang.org/x/net/http/httpproxy"
 
 	"k8s.io/klog/v2"
 )
@@ -52,9 +55,50 @@ func (r *registry) listProviders() []string {
 	return providers
 }
 
+// ListProviders returns the IDs of the registered providers.
+func ListProviders() []string {
+	return globalRegistry.listProviders()
+}
+
 type ClientOptions struct {
 	URL           *url.URL
 	SkipVerifySSL bool
+	// ProxyURL is the proxy to use for all requests. If empty, HTTPS_PROXY,
+	// HTTP_PROXY and NO_PROXY from the environment are used.
+	ProxyURL string
+	// CABundlePath is a PEM file with additional CA certificates to trust,
+	// e.g. for TLS-intercepting proxies.
+	CABundlePath string
+	// ClientCertPath and ClientKeyPath are a PEM certificate and key
+	// presented for mutual TLS.
+	ClientCertPath string
+	ClientKeyPath  string
+	// Endpoint is the URL of the provider's API, overriding the provider's
+	// environment variable for it (e.g. OPENAI_ENDPOINT or OLLAMA_HOST).
+	Endpoint string
+	// Recorder, if set, records every HTTP request and response made by the
+	// client. Otherwise the recorder in the request context is used.
+	Recorder journal.Recorder
+	// CompletionCache, if set, caches the responses of GenerateCompletion.
+	CompletionCache *CompletionCache
+	// Seed, if set, is passed to the providers supporting deterministic
+	// sampling, to make responses reproducible.
+	Seed *int64
+	// ResponseSchema, if set, constrains the responses of the providers
+	// supporting it to match the schema, see Client.SetResponseSchema.
+	ResponseSchema *Schema
+	// SearchGrounding grounds the responses of the providers supporting it,
+	// e.g. Gemini, with web search results.
+	SearchGrounding bool
+	// MaxStreamLineBytes is the maximum size of a line of streamed
+	// responses, e.g. of an event with large tool call arguments.
+	// Responses with larger lines fail with ErrStreamLineTooLong. Defaults
+	// to DefaultMaxStreamLineBytes. Ollama caps it to
+	// OllamaMaxStreamLineBytes.
+	MaxStreamLineBytes int
+	// AWS configures the credentials, region and endpoint of the AWS
+	// providers, e.g. Bedrock, instead of the default credential chain.
+	AWS AWSConfig
 	// Extend with more options as needed
 }
 
@@ -68,6 +112,107 @@ func WithSkipVerifySSL() Option {
 	}
 }
 
+// WithProxy sends all requests through the given proxy URL.
+// Hosts listed in NO_PROXY still bypass the proxy.
+func WithProxy(proxyURL string) Option {
+	return func(o *ClientOptions) {
+		o.ProxyURL = proxyURL
+	}
+}
+
+// WithCABundle adds the CA certificates in the given PEM file to the trusted roots.
+func WithCABundle(path string) Option {
+	return func(o *ClientOptions) {
+		o.CABundlePath = path
+	}
+}
+
+// WithRecorder records all HTTP requests and responses made by the client,
+// with credentials redacted, to recorder.
+func WithRecorder(recorder journal.Recorder) Option {
+	return func(o *ClientOptions) {
+		o.Recorder = recorder
+	}
+}
+
+// WithClientCertificate presents the given PEM certificate and key for mutual TLS.
+func WithClientCertificate(certPath, keyPath string) Option {
+	return func(o *ClientOptions) {
+		o.ClientCertPath = certPath
+		o.ClientKeyPath = keyPath
+	}
+}
+
+// AWSConfig configures how the AWS providers authenticate, see WithAWSConfig.
+// Empty fields keep the behavior of the default credential chain.
+type AWSConfig struct {
+	// Profile is the shared config profile to use, e.g. an AWS SSO profile.
+	Profile string
+	// Region is the region of the API.
+	Region string
+	// RoleARN is a role to assume with the credentials of the profile.
+	RoleARN string
+	// ExternalID is the external ID required to assume RoleARN, if any.
+	ExternalID string
+	// Endpoint is the URL of the API, e.g. a VPC endpoint.
+	Endpoint string
+}
+
+// WithAWSConfig configures the credentials, region and endpoint of the AWS
+// providers.
+func WithAWSConfig(config AWSConfig) Option {
+	return func(o *ClientOptions) {
+		o.AWS = config
+	}
+}
+
+// WithMaxStreamLineBytes sets the maximum size of a line of streamed
+// responses.
+func WithMaxStreamLineBytes(maxBytes int) Option {
+	return func(o *ClientOptions) {
+		o.MaxStreamLineBytes = maxBytes
+	}
+}
+
+// WithEndpoint sends requests to the provider's API at endpoint, e.g. an
+// OpenAI compatible server or a remote ollama.
+func WithEndpoint(endpoint string) Option {
+	return func(o *ClientOptions) {
+		o.Endpoint = endpoint
+	}
+}
+
+// WithCompletionCache caches the responses of GenerateCompletion in cache.
+func WithCompletionCache(cache *CompletionCache) Option {
+	return func(o *ClientOptions) {
+		o.CompletionCache = cache
+	}
+}
+
+// WithSeed makes the providers supporting it sample deterministically from
+// seed. Responses are then mostly reproducible, which helps evaluations.
+func WithSeed(seed int64) Option {
+	return func(o *ClientOptions) {
+		o.Seed = &seed
+	}
+}
+
+// WithResponseSchema constrains the responses of the client to match schema,
+// like calling SetResponseSchema on it.
+func WithResponseSchema(schema *Schema) Option {
+	return func(o *ClientOptions) {
+		o.ResponseSchema = schema
+	}
+}
+
+// WithSearchGrounding grounds the responses of the providers supporting it
+// with web search results, e.g. Google Search for Gemini and Vertex AI.
+func WithSearchGrounding() Option {
+	return func(o *ClientOptions) {
+		o.SearchGrounding = true
+	}
+}
+
 type FactoryFunc func(ctx context.Context, opts ClientOptions) (Client, error)
 
 func RegisterProvider(id string, factoryFunc FactoryFunc) error {
@@ -117,17 +262,31 @@ func (r *registry) NewClient(ctx context.Context, providerID string, opts ...Opt
 	if v := os.Getenv("LLM_SKIP_VERIFY_SSL"); v == "1" || strings.ToLower(v) == "true" {
 		clientOpts.SkipVerifySSL = true
 	}
+	clientOpts.CABundlePath = os.Getenv("KUBECTL_AI_CA_BUNDLE")
+	clientOpts.ClientCertPath = os.Getenv("KUBECTL_AI_CLIENT_CERT")
+	clientOpts.ClientKeyPath = os.Getenv("KUBECTL_AI_CLIENT_KEY")
 	for _, opt := range opts {
 		opt(&clientOpts)
 	}
 
-	return factoryFunc(ctx, clientOpts)
+	client, err := factoryFunc(ctx, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	if clientOpts.ResponseSchema != nil {
+		if err := client.SetResponseSchema(clientOpts.ResponseSchema); err != nil {
+			return nil, fmt.Errorf("setting response schema: %w", err)
+		}
+	}
+	client = &classifyingClient{Client: client}
+	return withCompletionCache(client, u.Scheme, clientOpts.CompletionCache), nil
 }
 
 /*
 NewClient builds a Client based on the LLM_CLIENT environment variable or the provided providerID.
 If providerID is not empty, it overrides the value from LLM_CLIENT.
-Supports Option parameters and the LLM_SKIP_VERIFY_SSL environment variable.
+Supports Option parameters and the LLM_SKIP_VERIFY_SSL, KUBECTL_AI_CA_BUNDLE,
+KUBECTL_AI_CLIENT_CERT and KUBECTL_AI_CLIENT_KEY environment variables.
 */
 func NewClient(ctx context.Context, providerID string, opts ...Option) (Client, error) {
 	if providerID == "" {
@@ -170,6 +329,13 @@ func DefaultIsRetryableError(err error) bool {
 		return false
 	}
 
+	switch errorCategory(err) {
+	case "transient", "quota":
+		return true
+	case "auth", "context_length", "content_filtered", "model_not_found":
+		return false
+	}
+
 	var apiErr *APIError
 	if errors.As(err, &apiErr) {
 		switch apiErr.StatusCode {
@@ -193,20 +359,67 @@ func DefaultIsRetryableError(err error) bool {
 	return false
 }
 
-// createCustomHTTPClient returns an *http.Client that optionally skips SSL certificate verification.
+// createCustomHTTPClient returns an *http.Client configured from opts: proxy,
+// additional CA certificates, client certificates and optionally skipping SSL
+// certificate verification. Requests and responses are recorded to the journal,
+// and streamed responses fail on lines larger than opts.MaxStreamLineBytes.
 // This is shared by all providers that need custom HTTP transport.
-func createCustomHTTPClient(skipVerify bool) *http.Client {
+func createCustomHTTPClient(opts ClientOptions) (*http.Client, error) {
+	transport, err := createCustomTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   180 * time.Second,
+	}
+	return withJournaling(client, opts.Recorder), nil
+}
+
+// createCustomTransport returns the transport of createCustomHTTPClient,
+// without the journaling, for clients that add their own layers on top of it
+// (e.g. authentication).
+func createCustomTransport(opts ClientOptions) (http.RoundTripper, error) {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.Proxy = http.ProxyFromEnvironment
-	if skipVerify {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-
+	if opts.ProxyURL != "" {
+		proxyConfig := httpproxy.FromEnvironment()
+		proxyConfig.HTTPProxy = opts.ProxyURL
+		proxyConfig.HTTPSProxy = opts.ProxyURL
+		proxyFunc := proxyConfig.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
 	}
 
-	return &http.Client{
-		Transport: transport,
-		Timeout:   180 * time.Second,
+	tlsConfig := &tls.Config{}
+	if opts.SkipVerifySSL {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if opts.CABundlePath != "" {
+		pem, err := os.ReadFile(opts.CABundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle %q: %w", opts.CABundlePath, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %q", opts.CABundlePath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.ClientCertPath != "" || opts.ClientKeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertPath, opts.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &streamLineTransport{base: transport, maxBytes: opts.MaxStreamLineBytes}, nil
 }
 
 // RetryConfig holds the configuration for the retry mechanism (same as before)
@@ -342,6 +555,14 @@ func (rc *retryChat[C]) SetFunctionDefinitions(functionDefinitions []*FunctionDe
 	return rc.underlying.SetFunctionDefinitions(functionDefinitions)
 }
 
+func (rc *retryChat[C]) SetToolChoice(choice ToolChoice) error {
+	return rc.underlying.SetToolChoice(choice)
+}
+
+func (rc *retryChat[C]) SetStopSequences(stopSequences []string) error {
+	return rc.underlying.SetStopSequences(stopSequences)
+}
+
 func (rc *retryChat[C]) IsRetryableError(err error) bool {
 	return rc.underlying.IsRetryableError(err)
 }
@@ -349,3 +570,11 @@ func (rc *retryChat[C]) IsRetryableError(err error) bool {
 func (rc *retryChat[C]) Initialize(messages []*api.Message) error {
 	return rc.underlying.Initialize(messages)
 }
+
+func (rc *retryChat[C]) DropToolOutputs(count int) int {
+	return DropToolOutputs(rc.underlying, count)
+}
+
+func (rc *retryChat[C]) PruneToolOutputs(keep int, prune func(output string) (string, bool)) int {
+	return PruneToolOutputs(rc.underlying, keep, prune)
+}
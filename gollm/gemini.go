@@ -26,7 +26,10 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
 	"google.golang.org/genai"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
@@ -44,9 +47,10 @@ func init() {
 }
 
 // geminiFactory is the provider factory function for Gemini.
-// Supports ClientOptions for consistency, but skipVerifySSL is not used.
 func geminiFactory(ctx context.Context, opts ClientOptions) (Client, error) {
-	opt := GeminiAPIClientOptions{}
+	opt := GeminiAPIClientOptions{
+		ClientOptions: opts,
+	}
 	return NewGeminiAPIClient(ctx, opt)
 }
 
@@ -54,6 +58,9 @@ func geminiFactory(ctx context.Context, opts ClientOptions) (Client, error) {
 type GeminiAPIClientOptions struct {
 	// API Key for GenAI. Required for BackendGeminiAPI.
 	APIKey string
+
+	// ClientOptions configures the HTTP transport (SSL verification, proxy, CA bundle).
+	ClientOptions ClientOptions
 }
 
 // NewGeminiAPIClient builds a client for the Gemini API.
@@ -65,9 +72,10 @@ func NewGeminiAPIClient(ctx context.Context, opt GeminiAPIClientOptions) (*Googl
 	if apiKey == "" {
 		return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set")
 	}
-	skipVerifySSL := false
-	httpClient := createCustomHTTPClient(skipVerifySSL)
-	httpClient = withJournaling(httpClient)
+	httpClient, err := createCustomHTTPClient(opt.ClientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
 	cc := &genai.ClientConfig{
 		APIKey:     apiKey,
 		Backend:    genai.BackendGeminiAPI,
@@ -80,7 +88,9 @@ func NewGeminiAPIClient(ctx context.Context, opt GeminiAPIClientOptions) (*Googl
 	}
 
 	return &GoogleAIClient{
-		client: client,
+		client:          client,
+		seed:            opt.ClientOptions.Seed,
+		searchGrounding: opt.ClientOptions.SearchGrounding,
 	}, nil
 }
 
@@ -90,13 +100,54 @@ type VertexAIClientOptions struct {
 	Project string
 	// GCP Location/Region for Vertex AI. Required for BackendVertexAI. See https://cloud.google.com/vertex-ai/docs/general/locations
 	Location string
+
+	// ClientOptions configures the HTTP transport (SSL verification, proxy, CA bundle).
+	ClientOptions ClientOptions
 }
 
 // vertexaiViaGeminiFactory is the provider factory function for VertexAI via Gemini.
-// Supports ClientOptions for consistency, but skipVerifySSL is not used.
 func vertexaiViaGeminiFactory(ctx context.Context, opts ClientOptions) (Client, error) {
-	opt := VertexAIClientOptions{}
-	return NewVertexAIClient(ctx, opt)
+	opt := VertexAIClientOptions{ClientOptions: opts}
+	client, err := NewVertexAIClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	client.seed = opts.Seed
+	client.searchGrounding = opts.SearchGrounding
+	return client, nil
+}
+
+// newVertexAIHTTPClient returns an HTTP client authenticated with the
+// application default credentials, like the one genai builds by default, but
+// using the transport configured by opts. Like the other providers' clients,
+// it records requests and responses to the journal.
+func newVertexAIHTTPClient(ctx context.Context, opts ClientOptions) (*http.Client, error) {
+	transport, err := createCustomTransport(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP transport: %w", err)
+	}
+	creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("finding default credentials: %w", err)
+	}
+	quotaProjectID, err := creds.QuotaProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting quota project ID: %w", err)
+	}
+	client, err := httptransport.NewClient(&httptransport.Options{
+		Credentials: creds,
+		Headers: http.Header{
+			"X-Goog-User-Project": []string{quotaProjectID},
+		},
+		BaseRoundTripper: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+	client.Timeout = 180 * time.Second
+	return withJournaling(client, opts.Recorder), nil
 }
 
 // findDefaultGCPProject gets the default GCP project ID from gcloud
@@ -175,6 +226,12 @@ func NewVertexAIClient(ctx context.Context, opt VertexAIClientOptions) (*GoogleA
 		cc.Location = location
 	}
 
+	httpClient, err := newVertexAIHTTPClient(ctx, opt.ClientOptions)
+	if err != nil {
+		return nil, err
+	}
+	cc.HTTPClient = httpClient
+
 	client, err := genai.NewClient(ctx, cc)
 
 	if err != nil {
@@ -193,6 +250,14 @@ type GoogleAIClient struct {
 
 	// responseSchema will constrain the output to match the given schema
 	responseSchema *genai.Schema
+
+	// seed makes the sampling of chats deterministic, if set
+	seed *int64
+
+	// searchGrounding grounds responses with Google Search. Gemini can't
+	// combine it with a response schema or function calling, so it is left
+	// out of requests having either.
+	searchGrounding bool
 }
 
 var _ Client = &GoogleAIClient{}
@@ -208,6 +273,46 @@ func (c *GoogleAIClient) ListModels(ctx context.Context) (modelNames []string, e
 	return modelNames, nil
 }
 
+// geminiDefaultEmbeddingModel is the default model of Embed.
+const geminiDefaultEmbeddingModel = "gemini-embedding-001"
+
+var _ Embedder = &GoogleAIClient{}
+
+// Embed computes embeddings with the Gemini or Vertex AI API.
+func (c *GoogleAIClient) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = geminiDefaultEmbeddingModel
+	}
+	contents := make([]*genai.Content, len(req.Texts))
+	for i, text := range req.Texts {
+		contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+	}
+	config := &genai.EmbedContentConfig{}
+	switch req.Task {
+	case EmbeddingTaskDocument:
+		config.TaskType = "RETRIEVAL_DOCUMENT"
+	case EmbeddingTaskQuery:
+		config.TaskType = "RETRIEVAL_QUERY"
+	}
+	if req.Dimensions > 0 {
+		config.OutputDimensionality = genai.Ptr(int32(req.Dimensions))
+	}
+	res, err := c.client.Models.EmbedContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, fmt.Errorf("computing embeddings with Gemini: %w", err)
+	}
+	response := &EmbeddingResponse{Model: model}
+	for _, embedding := range res.Embeddings {
+		if embedding == nil {
+			response.Embeddings = append(response.Embeddings, nil)
+			continue
+		}
+		response.Embeddings = append(response.Embeddings, embedding.Values)
+	}
+	return response, nil
+}
+
 // Close frees the resources used by the client.
 func (c *GoogleAIClient) Close() error {
 	return nil
@@ -233,6 +338,41 @@ func (c *GoogleAIClient) SetResponseSchema(responseSchema *Schema) error {
 func (c *GoogleAIClient) GenerateCompletion(ctx context.Context, request *CompletionRequest) (CompletionResponse, error) {
 	log := klog.FromContext(ctx)
 
+	config, content := c.completionRequest(request)
+
+	log.Info("sending GenerateContent request to gemini", "content", content)
+	result, err := c.client.Models.GenerateContent(ctx, request.Model, content, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeminiCompletionResponse{geminiResponse: result, text: result.Text()}, nil
+}
+
+// GenerateCompletionStreaming is the streaming version of GenerateCompletion.
+func (c *GoogleAIClient) GenerateCompletionStreaming(ctx context.Context, request *CompletionRequest) (CompletionResponseIterator, error) {
+	log := klog.FromContext(ctx)
+
+	config, content := c.completionRequest(request)
+
+	log.Info("sending GenerateContentStream request to gemini", "content", content)
+	stream := c.client.Models.GenerateContentStream(ctx, request.Model, content, config)
+
+	return func(yield func(CompletionResponse, error) bool) {
+		for result, err := range stream {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(&GeminiCompletionResponse{geminiResponse: result, text: result.Text()}, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// completionRequest builds the config and content of a completion request.
+func (c *GoogleAIClient) completionRequest(request *CompletionRequest) (*genai.GenerateContentConfig, []*genai.Content) {
 	var config *genai.GenerateContentConfig
 
 	if c.responseSchema != nil {
@@ -240,19 +380,14 @@ func (c *GoogleAIClient) GenerateCompletion(ctx context.Context, request *Comple
 			ResponseSchema:   c.responseSchema,
 			ResponseMIMEType: "application/json",
 		}
+	} else if c.searchGrounding {
+		config = &genai.GenerateContentConfig{Tools: []*genai.Tool{googleSearchTool}}
 	}
 
 	content := []*genai.Content{
 		{Role: "user", Parts: []*genai.Part{{Text: request.Prompt}}},
 	}
-
-	log.Info("sending GenerateContent request to gemini", "content", content)
-	result, err := c.client.Models.GenerateContent(ctx, request.Model, content, config)
-	if err != nil {
-		return nil, err
-	}
-
-	return &GeminiCompletionResponse{geminiResponse: result, text: result.Text()}, nil
+	return config, content
 }
 
 // StartChat starts a new chat with the model.
@@ -294,6 +429,12 @@ func (c *GoogleAIClient) StartChat(systemPrompt string, model string) Chat {
 	if c.responseSchema != nil {
 		chat.genConfig.ResponseSchema = c.responseSchema
 		chat.genConfig.ResponseMIMEType = "application/json"
+	} else if c.searchGrounding {
+		chat.searchGrounding = true
+		chat.genConfig.Tools = []*genai.Tool{googleSearchTool}
+	}
+	if c.seed != nil {
+		chat.genConfig.Seed = genai.Ptr(int32(*c.seed))
 	}
 	return chat
 }
@@ -305,8 +446,15 @@ type GeminiChat struct {
 	client    *genai.Client
 	history   []*genai.Content
 	genConfig *genai.GenerateContentConfig
+
+	// searchGrounding grounds responses with Google Search while no
+	// functions are defined.
+	searchGrounding bool
 }
 
+// googleSearchTool grounds the responses of Gemini with Google Search.
+var googleSearchTool = &genai.Tool{GoogleSearch: &genai.GoogleSearch{}}
+
 // SetFunctionDefinitions sets the function definitions for the chat.
 // This allows the LLM to call user-defined functions.
 func (c *GeminiChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
@@ -330,6 +478,39 @@ func (c *GeminiChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefin
 			FunctionDeclarations: genaiFunctionDeclarations,
 		},
 	}
+	if len(genaiFunctionDeclarations) == 0 {
+		c.genConfig.Tools = nil
+		if c.searchGrounding {
+			c.genConfig.Tools = []*genai.Tool{googleSearchTool}
+		}
+	} else if c.searchGrounding {
+		klog.Warning("Gemini can't combine Google Search grounding with function calling, disabling grounding while functions are defined")
+	}
+	return nil
+}
+
+// SetToolChoice sets the function calling mode of the chat.
+func (c *GeminiChat) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	config := &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeAuto}
+	switch choice.Mode {
+	case ToolChoiceAny:
+		config.Mode = genai.FunctionCallingConfigModeAny
+	case ToolChoiceNone:
+		config.Mode = genai.FunctionCallingConfigModeNone
+	case ToolChoiceTool:
+		config.Mode = genai.FunctionCallingConfigModeAny
+		config.AllowedFunctionNames = []string{choice.Tool}
+	}
+	c.genConfig.ToolConfig = &genai.ToolConfig{FunctionCallingConfig: config}
+	return nil
+}
+
+// SetStopSequences sets the stop sequences of the chat.
+func (c *GeminiChat) SetStopSequences(stopSequences []string) error {
+	c.genConfig.StopSequences = stopSequences
 	return nil
 }
 
@@ -391,6 +572,14 @@ func (c *GeminiChat) partsToGemini(contents ...any) ([]*genai.Part, error) {
 					Response: v.Result,
 				},
 			})
+		case ImageContent:
+			parts = append(parts, genai.NewPartFromBytes(v.Data, v.MIMEType))
+		case ProviderRawContent:
+			part, err := rawContentValue[*genai.Part](v, "gemini")
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unexpected type of content: %T", content)
 		}
@@ -414,9 +603,14 @@ func (c *GeminiChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 		Parts: parts,
 	}
 
-	c.history = append(c.history, genaiContent)
+	unrepaired := append(c.history, genaiContent)
+	history, repairs := repairGeminiHistory(unrepaired)
+	reportHistoryRepairs(ctx, "Gemini", repairs)
+	c.history = history
 	result, err := c.client.Models.GenerateContent(ctx, c.model, c.history, c.genConfig)
 	if err != nil {
+		// Remove the failed request, so that it can be sent again
+		c.history = unrepaired[:len(unrepaired)-1]
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 	if result == nil || len(result.Candidates) == 0 {
@@ -442,7 +636,11 @@ func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 		Parts: parts,
 	}
 
-	c.history = append(c.history, genaiContent)
+	unrepaired := append(c.history, genaiContent)
+	history, repairs := repairGeminiHistory(unrepaired)
+	reportHistoryRepairs(ctx, "Gemini", repairs)
+	c.history = history
+	requestEnd := len(c.history)
 	stream := c.client.Models.GenerateContentStream(ctx, c.model, c.history, c.genConfig)
 
 	return func(yield func(ChatResponse, error) bool) {
@@ -455,6 +653,10 @@ func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 			}
 
 			if err != nil {
+				if len(c.history) == requestEnd {
+					// Remove the failed request, so that it can be sent again
+					c.history = unrepaired[:len(unrepaired)-1]
+				}
 				// Always check for and yield an error first.
 				yield(nil, err)
 				return
@@ -489,38 +691,59 @@ func (c *GeminiChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 	}, nil
 }
 
-func (c *GeminiChat) Initialize(messages []*api.Message) error {
-	klog.Info("Initializing gemini chat")
-	c.history = make([]*genai.Content, 0, len(messages))
-	for _, msg := range messages {
-		content, err := c.messageToContent(msg)
-		if err != nil {
-			continue
+// DropToolOutputs implements ToolOutputDropper.
+func (c *GeminiChat) DropToolOutputs(count int) int {
+	var responses []*genai.FunctionResponse
+	for _, content := range c.history {
+		for _, part := range content.Parts {
+			if part.FunctionResponse != nil && part.FunctionResponse.Response["result"] != DroppedToolOutput {
+				responses = append(responses, part.FunctionResponse)
+			}
 		}
-		c.history = append(c.history, content)
 	}
-	return nil
+	dropped := min(count, len(responses))
+	for _, response := range responses[:dropped] {
+		response.Response = map[string]any{"result": DroppedToolOutput}
+	}
+	return dropped
 }
 
-func (c *GeminiChat) messageToContent(msg *api.Message) (*genai.Content, error) {
-	var role string
-	switch msg.Source {
-	case api.MessageSourceUser:
-		role = "user"
-	case api.MessageSourceModel:
-		role = "model"
-	case api.MessageSourceAgent:
-		role = "user" // Treat agent messages as user messages for Gemini history
-	default:
-		return nil, fmt.Errorf("unknown message source: %s", msg.Source)
+// PruneToolOutputs implements ToolOutputPruner.
+func (c *GeminiChat) PruneToolOutputs(keep int, prune func(output string) (string, bool)) int {
+	var responses []*genai.FunctionResponse
+	for _, content := range c.history {
+		for _, part := range content.Parts {
+			if part.FunctionResponse != nil {
+				responses = append(responses, part.FunctionResponse)
+			}
+		}
 	}
-
-	parts, err := c.partsToGemini(msg.Payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert message payload to parts: %w", err)
+	pruned := 0
+	for _, response := range responses[:max(len(responses)-keep, 0)] {
+		output, err := json.Marshal(response.Response)
+		if err != nil {
+			continue
+		}
+		if replacement, ok := prune(string(output)); ok {
+			response.Response = map[string]any{"result": replacement}
+			pruned++
+		}
 	}
+	return pruned
+}
 
-	return &genai.Content{Role: role, Parts: parts}, nil
+func (c *GeminiChat) Initialize(messages []*api.Message) error {
+	klog.Info("Initializing gemini chat")
+	turns := api.NormalizeHistory(messages)
+	c.history = make([]*genai.Content, 0, len(turns))
+	for _, turn := range turns {
+		role := "user"
+		if turn.Role == api.HistoryRoleModel {
+			role = "model"
+		}
+		c.history = append(c.history, &genai.Content{Role: role, Parts: []*genai.Part{{Text: turn.Text}}})
+	}
+	return nil
 }
 
 // GeminiChatResponse is a response from the Gemini API.
@@ -548,6 +771,41 @@ func (r *GeminiChatResponse) UsageMetadata() any {
 	return r.geminiResponse.UsageMetadata
 }
 
+var _ ResponseMetadata = &GeminiChatResponse{}
+
+// StopReason returns why the first candidate finished.
+func (r *GeminiChatResponse) StopReason() StopReason {
+	if len(r.geminiResponse.Candidates) == 0 || r.geminiResponse.Candidates[0] == nil {
+		return StopReasonUnknown
+	}
+	candidate := r.geminiResponse.Candidates[0]
+	switch candidate.FinishReason {
+	case "", genai.FinishReasonUnspecified:
+		return StopReasonUnknown
+	case genai.FinishReasonStop:
+		// Gemini doesn't finish tool calls with a reason of their own
+		if candidate.Content != nil {
+			for _, part := range candidate.Content.Parts {
+				if part != nil && part.FunctionCall != nil {
+					return StopReasonToolCalls
+				}
+			}
+		}
+		return StopReasonEnd
+	case genai.FinishReasonMaxTokens:
+		return StopReasonMaxTokens
+	case genai.FinishReasonSafety, genai.FinishReasonRecitation, genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent, genai.FinishReasonSPII, genai.FinishReasonImageSafety:
+		return StopReasonContentFilter
+	}
+	return StopReasonOther
+}
+
+// ModelVersion returns the version of the model that served the response.
+func (r *GeminiChatResponse) ModelVersion() string {
+	return r.geminiResponse.ModelVersion
+}
+
 // Candidates returns the candidates for the response.
 func (r *GeminiChatResponse) Candidates() []Candidate {
 	var candidates []Candidate
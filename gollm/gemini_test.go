@@ -0,0 +1,146 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestGeminiChat_DropToolOutputs(t *testing.T) {
+	chat := &GeminiChat{}
+	for _, content := range [][]any{
+		{"why is my pod failing?"},
+		{FunctionCallResult{ID: "call-1", Name: "kubectl", Result: map[string]any{"stdout": "pod list"}}},
+		{
+			FunctionCallResult{ID: "call-2", Name: "kubectl", Result: map[string]any{"stdout": "pod logs"}},
+			FunctionCallResult{ID: "call-3", Name: "kubectl", Result: map[string]any{"stdout": "pod events"}},
+		},
+	} {
+		parts, err := chat.partsToGemini(content...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chat.history = append(chat.history, &genai.Content{Role: "user", Parts: parts})
+	}
+
+	if dropped := chat.DropToolOutputs(2); dropped != 2 {
+		t.Errorf("expected 2 outputs dropped, got %d", dropped)
+	}
+	responses := []*genai.FunctionResponse{
+		chat.history[1].Parts[0].FunctionResponse,
+		chat.history[2].Parts[0].FunctionResponse,
+		chat.history[2].Parts[1].FunctionResponse,
+	}
+	for i, want := range []any{DroppedToolOutput, DroppedToolOutput, nil} {
+		if got := responses[i].Response["result"]; got != want {
+			t.Errorf("response %d: expected result %v, got %v", i, want, responses[i].Response)
+		}
+	}
+	if responses[2].Response["stdout"] != "pod events" || responses[0].ID != "call-1" {
+		t.Errorf("expected the newest output and the call IDs to be kept, got %+v", responses)
+	}
+}
+
+func TestGeminiChat_SetToolChoice(t *testing.T) {
+	chat := &GeminiChat{genConfig: &genai.GenerateContentConfig{}}
+	if err := chat.SetToolChoice(ToolChoice{Mode: ToolChoiceTool, Tool: "kubectl"}); err != nil {
+		t.Fatal(err)
+	}
+	config := chat.genConfig.ToolConfig.FunctionCallingConfig
+	if config.Mode != genai.FunctionCallingConfigModeAny || len(config.AllowedFunctionNames) != 1 || config.AllowedFunctionNames[0] != "kubectl" {
+		t.Errorf("unexpected function calling config %+v", config)
+	}
+
+	if err := chat.SetStopSequences([]string{"DONE"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := chat.genConfig.StopSequences; len(got) != 1 || got[0] != "DONE" {
+		t.Errorf("unexpected stop sequences %v", got)
+	}
+}
+
+func TestGeminiChat_SearchGrounding(t *testing.T) {
+	client := &GoogleAIClient{searchGrounding: true}
+	chat := client.StartChat("", "gemini-2.5-pro").(*GeminiChat)
+	if tools := chat.genConfig.Tools; len(tools) != 1 || tools[0].GoogleSearch == nil {
+		t.Fatalf("expected the Google Search tool, got %+v", tools)
+	}
+
+	functions := []*FunctionDefinition{{Name: "kubectl", Parameters: &Schema{Type: TypeObject}}}
+	if err := chat.SetFunctionDefinitions(functions); err != nil {
+		t.Fatal(err)
+	}
+	if tools := chat.genConfig.Tools; len(tools) != 1 || tools[0].GoogleSearch != nil || len(tools[0].FunctionDeclarations) != 1 {
+		t.Errorf("expected only the function declarations, got %+v", tools)
+	}
+
+	if err := chat.SetFunctionDefinitions(nil); err != nil {
+		t.Fatal(err)
+	}
+	if tools := chat.genConfig.Tools; len(tools) != 1 || tools[0].GoogleSearch == nil {
+		t.Errorf("expected grounding to be restored, got %+v", tools)
+	}
+
+	client = &GoogleAIClient{searchGrounding: true, responseSchema: &genai.Schema{Type: genai.TypeObject}}
+	chat = client.StartChat("", "gemini-2.5-pro").(*GeminiChat)
+	if chat.genConfig.Tools != nil || chat.genConfig.ResponseSchema == nil {
+		t.Errorf("expected the response schema to take precedence over grounding, got %+v", chat.genConfig)
+	}
+}
+
+func TestGeminiChatResponse_StopReason(t *testing.T) {
+	tests := []struct {
+		candidate *genai.Candidate
+		want      StopReason
+	}{
+		{candidate: &genai.Candidate{}, want: StopReasonUnknown},
+		{candidate: &genai.Candidate{FinishReason: genai.FinishReasonStop}, want: StopReasonEnd},
+		{candidate: &genai.Candidate{FinishReason: genai.FinishReasonMaxTokens}, want: StopReasonMaxTokens},
+		{candidate: &genai.Candidate{FinishReason: genai.FinishReasonSafety}, want: StopReasonContentFilter},
+		{candidate: &genai.Candidate{FinishReason: genai.FinishReasonMalformedFunctionCall}, want: StopReasonOther},
+		{
+			candidate: &genai.Candidate{
+				FinishReason: genai.FinishReasonStop,
+				Content:      genai.NewContentFromFunctionCall("kubectl", nil, genai.RoleModel),
+			},
+			want: StopReasonToolCalls,
+		},
+	}
+	for _, tt := range tests {
+		response := &GeminiChatResponse{geminiResponse: &genai.GenerateContentResponse{
+			Candidates:   []*genai.Candidate{tt.candidate},
+			ModelVersion: "gemini-2.5-pro-001",
+		}}
+		if got := ResponseStopReason(response); got != tt.want {
+			t.Errorf("finish reason %q: got %q, want %q", tt.candidate.FinishReason, got, tt.want)
+		}
+		if got := ResponseModelVersion(response); got != "gemini-2.5-pro-001" {
+			t.Errorf("got model version %q", got)
+		}
+	}
+}
+
+func TestGeminiChat_ImageContent(t *testing.T) {
+	chat := &GeminiChat{}
+	parts, err := chat.partsToGemini(ImageContent{MIMEType: "image/png", Data: []byte("png")}, "what does this panel show?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].InlineData == nil || parts[0].InlineData.MIMEType != "image/png" || string(parts[0].InlineData.Data) != "png" {
+		t.Errorf("expected the image as inline data, got %+v", parts)
+	}
+}
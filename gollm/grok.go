@@ -44,6 +44,7 @@ func newGrokClientFactory(ctx context.Context, opts ClientOptions) (Client, erro
 // GrokClient implements the gollm.Client interface for X.AI's Grok model.
 type GrokClient struct {
 	client openai.Client
+	seed   *int64
 }
 
 // Ensure GrokClient implements the Client interface.
@@ -61,20 +62,27 @@ func NewGrokClient(ctx context.Context, opts ClientOptions) (*GrokClient, error)
 	endpoint := "https://api.x.ai/v1"
 
 	// Allow endpoint override
-	customEndpoint := os.Getenv("GROK_ENDPOINT")
+	customEndpoint := opts.Endpoint
+	if customEndpoint == "" {
+		customEndpoint = os.Getenv("GROK_ENDPOINT")
+	}
 	if customEndpoint != "" {
 		endpoint = customEndpoint
 		klog.Infof("Using custom Grok endpoint: %s", endpoint)
 	}
 
 	// Use the OpenAI client with custom base URL and custom HTTP client
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
 	return &GrokClient{
 		client: openai.NewClient(
 			option.WithAPIKey(apiKey),
 			option.WithBaseURL(endpoint),
 			option.WithHTTPClient(httpClient),
 		),
+		seed: opts.Seed,
 	}, nil
 }
 
@@ -103,6 +111,7 @@ func (c *GrokClient) StartChat(systemPrompt, model string) Chat {
 		client:  c.client,
 		history: history,
 		model:   model,
+		seed:    c.seed,
 	}
 }
 
@@ -153,6 +162,11 @@ func (c *GrokClient) GenerateCompletion(ctx context.Context, req *CompletionRequ
 	return resp, nil
 }
 
+// GenerateCompletionStreaming returns the completion as a single chunk.
+func (c *GrokClient) GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error) {
+	return StreamCompletion(ctx, c, req)
+}
+
 // SetResponseSchema is not implemented yet for Grok.
 func (c *GrokClient) SetResponseSchema(schema *Schema) error {
 	klog.Warning("GrokClient.SetResponseSchema is not implemented yet")
@@ -174,6 +188,9 @@ type grokChatSession struct {
 	model               string
 	functionDefinitions []*FunctionDefinition            // Stored in gollm format
 	tools               []openai.ChatCompletionToolParam // Stored in OpenAI format
+	toolChoice          ToolChoice
+	stopSequences       []string
+	seed                *int64
 }
 
 // Ensure grokChatSession implements the Chat interface.
@@ -212,6 +229,21 @@ func (cs *grokChatSession) SetFunctionDefinitions(defs []*FunctionDefinition) er
 	return nil
 }
 
+// SetToolChoice sets the tool_choice of the next requests.
+func (cs *grokChatSession) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	cs.toolChoice = choice
+	return nil
+}
+
+// SetStopSequences sets the stop sequences of the next requests.
+func (cs *grokChatSession) SetStopSequences(stopSequences []string) error {
+	cs.stopSequences = stopSequences
+	return nil
+}
+
 // Send sends the user message(s), appends to history, and gets the LLM response.
 func (cs *grokChatSession) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	klog.V(1).InfoS("grokChatSession.Send called", "model", cs.model, "history_len", len(cs.history))
@@ -231,12 +263,21 @@ func (cs *grokChatSession) Send(ctx context.Context, contents ...any) (ChatRespo
 				return nil, fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
 			}
 			cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
+		case ProviderRawContent:
+			message, err := rawContentValue[openai.ChatCompletionMessageParamUnion](c, "grok")
+			if err != nil {
+				return nil, err
+			}
+			cs.history = append(cs.history, message)
 		default:
 			// TODO: Handle other content types if necessary?
 			klog.Warningf("Unhandled content type in Send: %T", content)
 			return nil, fmt.Errorf("unhandled content type: %T", content)
 		}
 	}
+	history, repairs := repairOpenAIHistory(cs.history)
+	reportHistoryRepairs(ctx, "Grok", repairs)
+	cs.history = history
 
 	// Prepare the API request
 	chatReq := openai.ChatCompletionNewParams{
@@ -245,7 +286,13 @@ func (cs *grokChatSession) Send(ctx context.Context, contents ...any) (ChatRespo
 	}
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
-		// chatReq.ToolChoice = openai.ToolChoiceAuto // Or specify if needed
+		chatReq.ToolChoice = openAIToolChoice(cs.toolChoice)
+	}
+	if len(cs.stopSequences) > 0 {
+		chatReq.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: cs.stopSequences}
+	}
+	if cs.seed != nil {
+		chatReq.Seed = openai.Int(*cs.seed)
 	}
 
 	// Call the Grok API
@@ -295,11 +342,20 @@ func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (
 				return nil, fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
 			}
 			cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
+		case ProviderRawContent:
+			message, err := rawContentValue[openai.ChatCompletionMessageParamUnion](c, "grok")
+			if err != nil {
+				return nil, err
+			}
+			cs.history = append(cs.history, message)
 		default:
 			klog.Warningf("Unhandled content type in SendStreaming: %T", content)
 			return nil, fmt.Errorf("unhandled content type: %T", content)
 		}
 	}
+	history, repairs := repairOpenAIHistory(cs.history)
+	reportHistoryRepairs(ctx, "Grok", repairs)
+	cs.history = history
 
 	// Prepare the API request
 	chatReq := openai.ChatCompletionNewParams{
@@ -308,6 +364,13 @@ func (cs *grokChatSession) SendStreaming(ctx context.Context, contents ...any) (
 	}
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
+		chatReq.ToolChoice = openAIToolChoice(cs.toolChoice)
+	}
+	if len(cs.stopSequences) > 0 {
+		chatReq.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: cs.stopSequences}
+	}
+	if cs.seed != nil {
+		chatReq.Seed = openai.Int(*cs.seed)
 	}
 
 	// Start the Grok streaming request
@@ -381,7 +444,7 @@ func (cs *grokChatSession) IsRetryableError(err error) bool {
 }
 
 func (cs *grokChatSession) Initialize(messages []*api.Message) error {
-	klog.Warning("chat history persistence is not supported for provider 'grok', using in-memory chat history")
+	cs.history = openAIHistory(cs.history, messages)
 	return nil
 }
 
@@ -412,6 +475,22 @@ func (r *grokChatResponse) Candidates() []Candidate {
 	return candidates
 }
 
+var _ ResponseMetadata = (*grokChatResponse)(nil)
+
+func (r *grokChatResponse) StopReason() StopReason {
+	if r.grokCompletion == nil || len(r.grokCompletion.Choices) == 0 {
+		return StopReasonUnknown
+	}
+	return openAIStopReason(r.grokCompletion.Choices[0].FinishReason)
+}
+
+func (r *grokChatResponse) ModelVersion() string {
+	if r.grokCompletion == nil {
+		return ""
+	}
+	return r.grokCompletion.Model
+}
+
 type grokCandidate struct {
 	grokChoice *openai.ChatCompletionChoice
 }
@@ -473,13 +552,16 @@ func (p *grokPart) AsFunctionCalls() ([]FunctionCall, bool) {
 			continue
 		}
 		var args map[string]any
-		// Attempt to unmarshal arguments, ignore error for now if it fails
-		_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		var parseError string
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil && tc.Function.Arguments != "" {
+			parseError = err.Error()
+		}
 
 		gollmCalls[i] = FunctionCall{
-			ID:        tc.ID,
-			Name:      tc.Function.Name,
-			Arguments: args,
+			ID:         tc.ID,
+			Name:       tc.Function.Name,
+			Arguments:  args,
+			ParseError: parseError,
 		}
 	}
 	return gollmCalls, true
@@ -516,6 +598,21 @@ func (r *grokChatStreamResponse) Candidates() []Candidate {
 	return candidates
 }
 
+var _ ResponseMetadata = (*grokChatStreamResponse)(nil)
+
+// StopReason returns why the stream finished, in its last chunk.
+func (r *grokChatStreamResponse) StopReason() StopReason {
+	if len(r.streamChunk.Choices) == 0 {
+		return StopReasonUnknown
+	}
+	return openAIStopReason(r.streamChunk.Choices[0].FinishReason)
+}
+
+// ModelVersion returns the model that served the stream.
+func (r *grokChatStreamResponse) ModelVersion() string {
+	return r.streamChunk.Model
+}
+
 // grokStreamCandidate adapts a streaming chunk choice to the Candidate interface.
 type grokStreamCandidate struct {
 	streamChoice openai.ChatCompletionChunkChoice
@@ -612,12 +709,14 @@ func (p *grokStreamPart) AsFunctionCalls() ([]FunctionCall, bool) {
 		}
 
 		var args map[string]any
+		var parseError string
 		// Attempt to unmarshal arguments if present
 		if tc.Function.Arguments != "" {
 			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
 				klog.V(2).Infof("Error unmarshaling function arguments: %v", err)
 				// Continue with empty args if unmarshal fails
 				args = make(map[string]any)
+				parseError = err.Error()
 			}
 		} else {
 			// Initialize empty args map if no arguments provided
@@ -625,9 +724,10 @@ func (p *grokStreamPart) AsFunctionCalls() ([]FunctionCall, bool) {
 		}
 
 		completeCalls = append(completeCalls, FunctionCall{
-			ID:        tc.ID,
-			Name:      tc.Function.Name,
-			Arguments: args,
+			ID:         tc.ID,
+			Name:       tc.Function.Name,
+			Arguments:  args,
+			ParseError: parseError,
 		})
 	}
 
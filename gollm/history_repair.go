@@ -0,0 +1,448 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	ollama "github.com/ollama/ollama/api"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"google.golang.org/genai"
+	"k8s.io/klog/v2"
+)
+
+// The functions of this file repair the histories of chats before they are
+// sent, so that a history broken e.g. by an interrupted tool loop or a resumed
+// session doesn't make every following request fail with a 400: every tool
+// call gets a result, results without a call and empty messages are dropped,
+// and roles alternate where the provider requires it. They don't modify the
+// history they are given, so that chats can still remove a failed request
+// from it, and return it as is when it needs no repair.
+
+// MissingToolResult is the result given to tool calls that have none in the
+// history.
+const MissingToolResult = "No result was recorded for this call, it may not have run."
+
+type historyRepairReporterKey struct{}
+
+// HistoryRepairReporter is called with the repairs a chat made to its
+// history before sending it.
+type HistoryRepairReporter func(provider string, repairs []string)
+
+// WithHistoryRepairReporter returns a context that makes the chats it is
+// passed to report the repairs they make to their history, e.g. so that they
+// can be shown to the user, in addition to logging them.
+func WithHistoryRepairReporter(ctx context.Context, report HistoryRepairReporter) context.Context {
+	return context.WithValue(ctx, historyRepairReporterKey{}, report)
+}
+
+// reportHistoryRepairs logs the repairs made to the history of a chat, and
+// passes them to the reporter of ctx, if any.
+func reportHistoryRepairs(ctx context.Context, provider string, repairs []string) {
+	if len(repairs) == 0 {
+		return
+	}
+	for _, repair := range repairs {
+		klog.Warningf("repaired the %s chat history: %s", provider, repair)
+	}
+	if report, ok := ctx.Value(historyRepairReporterKey{}).(HistoryRepairReporter); ok {
+		report(provider, repairs)
+	}
+}
+
+// repairOpenAIHistory repairs a chat completions history: the tool calls of
+// an assistant message must be answered by the tool messages that follow it.
+func repairOpenAIHistory(history []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, []string) {
+	var repaired []openai.ChatCompletionMessageParamUnion
+	var repairs []string
+	var pending []string
+	answerPending := func() {
+		for _, id := range pending {
+			repaired = append(repaired, openai.ToolMessage(MissingToolResult, id))
+			repairs = append(repairs, fmt.Sprintf("added a result for tool call %q", id))
+		}
+		pending = nil
+	}
+	for _, msg := range history {
+		switch {
+		case msg.OfTool != nil:
+			i := slices.Index(pending, msg.OfTool.ToolCallID)
+			if i < 0 {
+				repairs = append(repairs, fmt.Sprintf("dropped the result of unknown tool call %q", msg.OfTool.ToolCallID))
+				continue
+			}
+			pending = slices.Delete(pending, i, i+1)
+		case msg.OfAssistant != nil:
+			answerPending()
+			content := msg.OfAssistant.Content
+			if content.OfString.Value == "" && len(content.OfArrayOfContentParts) == 0 && len(msg.OfAssistant.ToolCalls) == 0 && msg.OfAssistant.Refusal.Value == "" {
+				repairs = append(repairs, "dropped an empty assistant message")
+				continue
+			}
+			for _, call := range msg.OfAssistant.ToolCalls {
+				pending = append(pending, call.ID)
+			}
+		default:
+			answerPending()
+		}
+		repaired = append(repaired, msg)
+	}
+	answerPending()
+
+	if len(repairs) == 0 {
+		return history, nil
+	}
+	return repaired, repairs
+}
+
+// repairAzureOpenAIHistory repairs an Azure OpenAI history like
+// repairOpenAIHistory.
+func repairAzureOpenAIHistory(history []azopenai.ChatRequestMessageClassification) ([]azopenai.ChatRequestMessageClassification, []string) {
+	var repaired []azopenai.ChatRequestMessageClassification
+	var repairs []string
+	var pending []string
+	answerPending := func() {
+		for _, id := range pending {
+			repaired = append(repaired, &azopenai.ChatRequestToolMessage{
+				Content:    azopenai.NewChatRequestToolMessageContent(MissingToolResult),
+				ToolCallID: ptrTo(id),
+			})
+			repairs = append(repairs, fmt.Sprintf("added a result for tool call %q", id))
+		}
+		pending = nil
+	}
+	for _, msg := range history {
+		switch m := msg.(type) {
+		case *azopenai.ChatRequestToolMessage:
+			id := ""
+			if m.ToolCallID != nil {
+				id = *m.ToolCallID
+			}
+			i := slices.Index(pending, id)
+			if i < 0 {
+				repairs = append(repairs, fmt.Sprintf("dropped the result of unknown tool call %q", id))
+				continue
+			}
+			pending = slices.Delete(pending, i, i+1)
+		case *azopenai.ChatRequestAssistantMessage:
+			answerPending()
+			if m.Content == nil && len(m.ToolCalls) == 0 && m.FunctionCall == nil && m.Refusal == nil {
+				repairs = append(repairs, "dropped an empty assistant message")
+				continue
+			}
+			for _, call := range m.ToolCalls {
+				if c := call.GetChatCompletionsToolCall(); c != nil && c.ID != nil {
+					pending = append(pending, *c.ID)
+				}
+			}
+		default:
+			answerPending()
+		}
+		repaired = append(repaired, msg)
+	}
+	answerPending()
+
+	if len(repairs) == 0 {
+		return history, nil
+	}
+	return repaired, repairs
+}
+
+// repairOllamaHistory repairs an Ollama history: assistant messages must not
+// be empty, and their tool calls must be answered before the next one. Ollama tool calls have no IDs, and their results are sent as user or
+// tool messages, so any message answers them.
+func repairOllamaHistory(history []ollama.Message) ([]ollama.Message, []string) {
+	var repaired []ollama.Message
+	var repairs []string
+	var pending []string
+	answerPending := func() {
+		for _, name := range pending {
+			repaired = append(repaired, ollama.Message{Role: "tool", Content: MissingToolResult})
+			repairs = append(repairs, fmt.Sprintf("added a result for the call of %s", name))
+		}
+		pending = nil
+	}
+	for _, msg := range history {
+		if msg.Role == "assistant" {
+			answerPending()
+			if msg.Content == "" && len(msg.ToolCalls) == 0 {
+				repairs = append(repairs, "dropped an empty assistant message")
+				continue
+			}
+			for _, call := range msg.ToolCalls {
+				pending = append(pending, call.Function.Name)
+			}
+		} else {
+			pending = nil
+		}
+		repaired = append(repaired, msg)
+	}
+	answerPending()
+
+	if len(repairs) == 0 {
+		return history, nil
+	}
+	return repaired, repairs
+}
+
+// repairLlamaCppHistory repairs a llama.cpp history: assistant messages must
+// not be empty, and each tool call of an assistant message must be answered
+// by one of the tool messages that follow it, in order, as they have no IDs.
+func repairLlamaCppHistory(history []llamacppChatMessage) ([]llamacppChatMessage, []string) {
+	var repaired []llamacppChatMessage
+	var repairs []string
+	var pending []string
+	answerPending := func() {
+		for _, name := range pending {
+			repaired = append(repaired, llamacppChatMessage{Role: "tool", Content: ptrTo(MissingToolResult)})
+			repairs = append(repairs, fmt.Sprintf("added a result for the call of %s", name))
+		}
+		pending = nil
+	}
+	for _, msg := range history {
+		switch msg.Role {
+		case "tool":
+			if len(pending) == 0 {
+				repairs = append(repairs, "dropped a tool result without a call")
+				continue
+			}
+			pending = pending[1:]
+		case "assistant":
+			answerPending()
+			if (msg.Content == nil || *msg.Content == "") && len(msg.ToolCalls) == 0 {
+				repairs = append(repairs, "dropped an empty assistant message")
+				continue
+			}
+			for _, call := range msg.ToolCalls {
+				pending = append(pending, call.Function.Name)
+			}
+		default:
+			answerPending()
+		}
+		repaired = append(repaired, msg)
+	}
+	answerPending()
+
+	if len(repairs) == 0 {
+		return history, nil
+	}
+	return repaired, repairs
+}
+
+// repairOpenAIResponsesHistory repairs a responses API history: function
+// calls must be answered by function call outputs before the next message.
+func repairOpenAIResponsesHistory(history responses.ResponseInputParam) (responses.ResponseInputParam, []string) {
+	var repaired responses.ResponseInputParam
+	var repairs []string
+	var pending []string
+	answerPending := func() {
+		for _, id := range pending {
+			repaired = append(repaired, responses.ResponseInputItemParamOfFunctionCallOutput(id, MissingToolResult))
+			repairs = append(repairs, fmt.Sprintf("added an output for function call %q", id))
+		}
+		pending = nil
+	}
+	for _, item := range history {
+		switch {
+		case item.OfFunctionCall != nil:
+			pending = append(pending, item.OfFunctionCall.CallID)
+		case item.OfFunctionCallOutput != nil:
+			i := slices.Index(pending, item.OfFunctionCallOutput.CallID)
+			if i < 0 {
+				repairs = append(repairs, fmt.Sprintf("dropped the output of unknown function call %q", item.OfFunctionCallOutput.CallID))
+				continue
+			}
+			pending = slices.Delete(pending, i, i+1)
+		case item.OfReasoning != nil:
+			// Reasoning precedes the calls it led to
+		default:
+			answerPending()
+		}
+		repaired = append(repaired, item)
+	}
+	answerPending()
+
+	if len(repairs) == 0 {
+		return history, nil
+	}
+	return repaired, repairs
+}
+
+// repairBedrockMessages repairs a Converse API history: it must start with a
+// user message, roles must alternate, messages must not be empty, and the
+// tool uses of an assistant message must be answered by tool results in the
+// next user message.
+func repairBedrockMessages(messages []types.Message) ([]types.Message, []string) {
+	var repaired []types.Message
+	var repairs []string
+	var pending []string
+	for _, message := range messages {
+		var content []types.ContentBlock
+		var uses []string
+		for _, block := range message.Content {
+			switch b := block.(type) {
+			case *types.ContentBlockMemberText:
+				if b.Value == "" {
+					repairs = append(repairs, "dropped an empty text block")
+					continue
+				}
+			case *types.ContentBlockMemberToolUse:
+				uses = append(uses, aws.ToString(b.Value.ToolUseId))
+			case *types.ContentBlockMemberToolResult:
+				id := aws.ToString(b.Value.ToolUseId)
+				i := slices.Index(pending, id)
+				if message.Role != types.ConversationRoleUser || i < 0 {
+					repairs = append(repairs, fmt.Sprintf("dropped the result of unknown tool use %q", id))
+					continue
+				}
+				pending = slices.Delete(pending, i, i+1)
+			}
+			content = append(content, block)
+		}
+
+		if message.Role == types.ConversationRoleUser && len(pending) > 0 {
+			// Results come first in the message answering the tool uses
+			var results []types.ContentBlock
+			for _, id := range pending {
+				results = append(results, bedrockMissingToolResult(id))
+				repairs = append(repairs, fmt.Sprintf("added a result for tool use %q", id))
+			}
+			content = append(results, content...)
+			pending = nil
+		}
+		if len(content) == 0 {
+			repairs = append(repairs, fmt.Sprintf("dropped an empty %s message", message.Role))
+			continue
+		}
+
+		switch n := len(repaired); {
+		case n == 0 && message.Role != types.ConversationRoleUser:
+			repaired = append(repaired, types.Message{
+				Role:    types.ConversationRoleUser,
+				Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: "Continue the conversation."}},
+			})
+			repairs = append(repairs, "added a user message to start the conversation")
+		case n > 0 && repaired[n-1].Role == message.Role:
+			repaired[n-1].Content = append(slices.Clip(repaired[n-1].Content), content...)
+			repairs = append(repairs, fmt.Sprintf("merged consecutive %s messages", message.Role))
+			pending = append(pending, uses...)
+			continue
+		}
+		if message.Role == types.ConversationRoleAssistant && len(pending) > 0 {
+			// The tool uses of the previous assistant message were not answered
+			repaired = append(repaired, types.Message{Role: types.ConversationRoleUser})
+			for _, id := range pending {
+				repaired[len(repaired)-1].Content = append(repaired[len(repaired)-1].Content, bedrockMissingToolResult(id))
+				repairs = append(repairs, fmt.Sprintf("added a result for tool use %q", id))
+			}
+			pending = nil
+		}
+		repaired = append(repaired, types.Message{Role: message.Role, Content: content})
+		pending = append(pending, uses...)
+	}
+
+	if len(repairs) == 0 {
+		return messages, nil
+	}
+	return repaired, repairs
+}
+
+func bedrockMissingToolResult(id string) types.ContentBlock {
+	return &types.ContentBlockMemberToolResult{Value: types.ToolResultBlock{
+		ToolUseId: aws.String(id),
+		Content:   []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: MissingToolResult}},
+		Status:    types.ToolResultStatusError,
+	}}
+}
+
+// repairGeminiHistory repairs a Gemini history: contents must not be empty,
+// and the function calls of the model must be answered by function responses
+// in the next user content.
+func repairGeminiHistory(history []*genai.Content) ([]*genai.Content, []string) {
+	var repaired []*genai.Content
+	var repairs []string
+	var pending []*genai.FunctionCall
+	answerPending := func(parts []*genai.Part) []*genai.Part {
+		var responses []*genai.Part
+		for _, call := range pending {
+			responses = append(responses, &genai.Part{FunctionResponse: &genai.FunctionResponse{
+				ID:       call.ID,
+				Name:     call.Name,
+				Response: map[string]any{"error": MissingToolResult},
+			}})
+			repairs = append(repairs, fmt.Sprintf("added a response to the call of %s", call.Name))
+		}
+		pending = nil
+		return append(responses, parts...)
+	}
+	for _, content := range history {
+		if content == nil {
+			repairs = append(repairs, "dropped an empty content")
+			continue
+		}
+		var parts []*genai.Part
+		var calls []*genai.FunctionCall
+		changed := false
+		for _, part := range content.Parts {
+			switch {
+			case part == nil:
+				changed = true
+				continue
+			case part.FunctionCall != nil:
+				calls = append(calls, part.FunctionCall)
+			case part.FunctionResponse != nil:
+				i := slices.IndexFunc(pending, func(call *genai.FunctionCall) bool {
+					return call.Name == part.FunctionResponse.Name && call.ID == part.FunctionResponse.ID
+				})
+				if content.Role != "user" || i < 0 {
+					repairs = append(repairs, fmt.Sprintf("dropped the response to an unknown call of %s", part.FunctionResponse.Name))
+					changed = true
+					continue
+				}
+				pending = slices.Delete(pending, i, i+1)
+			}
+			parts = append(parts, part)
+		}
+
+		if len(pending) > 0 {
+			if content.Role == "user" {
+				parts = answerPending(parts)
+				changed = true
+			} else {
+				repaired = append(repaired, &genai.Content{Role: "user", Parts: answerPending(nil)})
+			}
+		}
+		if len(parts) == 0 {
+			repairs = append(repairs, fmt.Sprintf("dropped an empty %s content", content.Role))
+			continue
+		}
+		if changed {
+			content = &genai.Content{Role: content.Role, Parts: parts}
+		}
+		repaired = append(repaired, content)
+		pending = append(pending, calls...)
+	}
+
+	if len(repairs) == 0 {
+		return history, nil
+	}
+	return repaired, repairs
+}
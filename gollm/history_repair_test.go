@@ -0,0 +1,300 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	ollama "github.com/ollama/ollama/api"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"google.golang.org/genai"
+)
+
+func openAIAssistantToolCalls(ids ...string) openai.ChatCompletionMessageParamUnion {
+	var calls []openai.ChatCompletionMessageToolCallParam
+	for _, id := range ids {
+		calls = append(calls, openai.ChatCompletionMessageToolCallParam{
+			ID:       id,
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "kubectl", Arguments: "{}"},
+		})
+	}
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &openai.ChatCompletionAssistantMessageParam{ToolCalls: calls}}
+}
+
+func TestRepairOpenAIHistory(t *testing.T) {
+	valid := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("be helpful"),
+		openai.UserMessage("list pods"),
+		openAIAssistantToolCalls("a", "b"),
+		openai.ToolMessage("{}", "b"),
+		openai.ToolMessage("{}", "a"),
+		openai.AssistantMessage("done"),
+	}
+	if got, repairs := repairOpenAIHistory(valid); len(repairs) != 0 || &got[0] != &valid[0] {
+		t.Errorf("a valid history was repaired: %v", repairs)
+	}
+
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("list pods"),
+		openAIAssistantToolCalls("a", "b"),
+		openai.ToolMessage("{}", "a"),
+		openai.ToolMessage("{}", "z"),
+		openai.AssistantMessage(""),
+		openai.UserMessage("and now?"),
+		openAIAssistantToolCalls("c"),
+	}
+	got, repairs := repairOpenAIHistory(history)
+	if len(repairs) != 4 {
+		t.Errorf("unexpected repairs %q", repairs)
+	}
+	var shape []string
+	for _, msg := range got {
+		switch {
+		case msg.OfTool != nil:
+			shape = append(shape, "tool:"+msg.OfTool.ToolCallID)
+		case msg.OfAssistant != nil:
+			shape = append(shape, "assistant")
+		case msg.OfUser != nil:
+			shape = append(shape, "user")
+		}
+	}
+	want := []string{"user", "assistant", "tool:a", "tool:b", "user", "assistant", "tool:c"}
+	if len(shape) != len(want) {
+		t.Fatalf("repaired history is %v, want %v", shape, want)
+	}
+	for i := range want {
+		if shape[i] != want[i] {
+			t.Fatalf("repaired history is %v, want %v", shape, want)
+		}
+	}
+	if len(history) != 7 || history[3].OfTool.ToolCallID != "z" {
+		t.Errorf("the history was modified")
+	}
+}
+
+func TestRepairOpenAIResponsesHistory(t *testing.T) {
+	history := responses.ResponseInputParam{
+		responses.ResponseInputItemParamOfMessage("list pods", responses.EasyInputMessageRoleUser),
+		responses.ResponseInputItemParamOfFunctionCall("{}", "a", "kubectl"),
+		responses.ResponseInputItemParamOfFunctionCall("{}", "b", "kubectl"),
+		responses.ResponseInputItemParamOfFunctionCallOutput("b", "{}"),
+		responses.ResponseInputItemParamOfFunctionCallOutput("z", "{}"),
+		responses.ResponseInputItemParamOfMessage("and now?", responses.EasyInputMessageRoleUser),
+	}
+	got, repairs := repairOpenAIResponsesHistory(history)
+	if len(repairs) != 2 || len(got) != 6 {
+		t.Fatalf("unexpected repairs %q of history of %d items", repairs, len(got))
+	}
+	if got[4].OfFunctionCallOutput == nil || got[4].OfFunctionCallOutput.CallID != "a" || got[5].OfMessage == nil {
+		t.Errorf("missing output was not added before the next message: %+v", got[4])
+	}
+}
+
+func TestRepairBedrockMessages(t *testing.T) {
+	text := func(s string) types.ContentBlock { return &types.ContentBlockMemberText{Value: s} }
+	use := func(id string) types.ContentBlock {
+		return &types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{ToolUseId: aws.String(id), Name: aws.String("kubectl")}}
+	}
+	result := func(id string) types.ContentBlock {
+		return &types.ContentBlockMemberToolResult{Value: types.ToolResultBlock{ToolUseId: aws.String(id)}}
+	}
+
+	valid := []types.Message{
+		{Role: types.ConversationRoleUser, Content: []types.ContentBlock{text("list pods")}},
+		{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{use("a")}},
+		{Role: types.ConversationRoleUser, Content: []types.ContentBlock{result("a")}},
+	}
+	if _, repairs := repairBedrockMessages(valid); len(repairs) != 0 {
+		t.Errorf("a valid history was repaired: %v", repairs)
+	}
+
+	messages := []types.Message{
+		{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{text("hello")}},
+		{Role: types.ConversationRoleUser, Content: []types.ContentBlock{text("list pods")}},
+		{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{text(""), use("a")}},
+		{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{use("b")}},
+		{Role: types.ConversationRoleUser, Content: []types.ContentBlock{result("b"), result("z")}},
+		{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{use("c")}},
+		{Role: types.ConversationRoleAssistant, Content: []types.ContentBlock{text("")}},
+		{Role: types.ConversationRoleUser, Content: []types.ContentBlock{text("and now?")}},
+	}
+	got, _ := repairBedrockMessages(messages)
+
+	// user (added), assistant, user, assistant (merged), user, assistant, user
+	wantRoles := []types.ConversationRole{"user", "assistant", "user", "assistant", "user", "assistant", "user"}
+	if len(got) != len(wantRoles) {
+		t.Fatalf("got %d messages, want %d: %+v", len(got), len(wantRoles), got)
+	}
+	for i, message := range got {
+		if message.Role != wantRoles[i] {
+			t.Errorf("message %d is from %s, want %s", i, message.Role, wantRoles[i])
+		}
+	}
+	if n := len(got[3].Content); n != 2 {
+		t.Errorf("merged assistant message has %d blocks, want 2", n)
+	}
+	results := map[string]types.ToolResultStatus{}
+	for _, block := range append(got[4].Content, got[6].Content...) {
+		if r, ok := block.(*types.ContentBlockMemberToolResult); ok {
+			results[aws.ToString(r.Value.ToolUseId)] = r.Value.Status
+		}
+	}
+	if len(results) != 3 || results["a"] != types.ToolResultStatusError || results["b"] != "" || results["c"] != types.ToolResultStatusError {
+		t.Errorf("unexpected tool results %v", results)
+	}
+	if len(messages[2].Content) != 2 || len(messages[4].Content) != 2 {
+		t.Errorf("the history was modified")
+	}
+}
+
+func TestRepairGeminiHistory(t *testing.T) {
+	call := func(name string) *genai.Part { return &genai.Part{FunctionCall: &genai.FunctionCall{Name: name}} }
+	response := func(name string) *genai.Part {
+		return &genai.Part{FunctionResponse: &genai.FunctionResponse{Name: name, Response: map[string]any{}}}
+	}
+
+	history := []*genai.Content{
+		{Role: "user", Parts: []*genai.Part{{Text: "list pods"}}},
+		{Role: "model", Parts: []*genai.Part{call("kubectl"), call("bash")}},
+		{Role: "user", Parts: []*genai.Part{response("bash"), response("promql")}},
+		{Role: "model", Parts: nil},
+		{Role: "model", Parts: []*genai.Part{call("kubectl")}},
+		{Role: "model", Parts: []*genai.Part{{Text: "done"}}},
+	}
+	got, repairs := repairGeminiHistory(history)
+	if len(repairs) != 4 {
+		t.Errorf("unexpected repairs %q", repairs)
+	}
+	if len(got) != 6 {
+		t.Fatalf("got %d contents, want 6", len(got))
+	}
+	var names []string
+	for _, part := range got[2].Parts {
+		names = append(names, part.FunctionResponse.Name)
+	}
+	if len(names) != 2 || names[0] != "kubectl" || names[1] != "bash" {
+		t.Errorf("responses are %v, want [kubectl bash]", names)
+	}
+	if got[4].Role != "user" || got[4].Parts[0].FunctionResponse.Response["error"] != MissingToolResult {
+		t.Errorf("missing response was not added before the next model content: %+v", got[4])
+	}
+	if len(history[2].Parts) != 2 {
+		t.Errorf("the history was modified")
+	}
+}
+
+func TestRepairAzureOpenAIHistory(t *testing.T) {
+	assistant := func(ids ...string) azopenai.ChatRequestMessageClassification {
+		msg := &azopenai.ChatRequestAssistantMessage{}
+		for _, id := range ids {
+			msg.ToolCalls = append(msg.ToolCalls, &azopenai.ChatCompletionsFunctionToolCall{
+				ID:       ptrTo(id),
+				Function: &azopenai.FunctionCall{Name: ptrTo("kubectl"), Arguments: ptrTo("{}")},
+			})
+		}
+		return msg
+	}
+	tool := func(id string) azopenai.ChatRequestMessageClassification {
+		return &azopenai.ChatRequestToolMessage{Content: azopenai.NewChatRequestToolMessageContent("{}"), ToolCallID: ptrTo(id)}
+	}
+	user := &azopenai.ChatRequestUserMessage{Content: azopenai.NewChatRequestUserMessageContent("list pods")}
+
+	valid := []azopenai.ChatRequestMessageClassification{user, assistant("a"), tool("a")}
+	if _, repairs := repairAzureOpenAIHistory(valid); len(repairs) != 0 {
+		t.Errorf("a valid history was repaired: %v", repairs)
+	}
+
+	history := []azopenai.ChatRequestMessageClassification{
+		user, assistant("a", "b"), tool("b"), tool("z"), assistant(), user,
+	}
+	got, repairs := repairAzureOpenAIHistory(history)
+	if len(repairs) != 3 || len(got) != 5 {
+		t.Fatalf("unexpected repairs %q of history of %d messages", repairs, len(got))
+	}
+	if added, ok := got[3].(*azopenai.ChatRequestToolMessage); !ok || *added.ToolCallID != "a" {
+		t.Errorf("missing result was not added before the next message: %+v", got[3])
+	}
+}
+
+func TestRepairOllamaHistory(t *testing.T) {
+	call := ollama.ToolCall{Function: ollama.ToolCallFunction{Name: "kubectl"}}
+	valid := []ollama.Message{
+		{Role: "system"},
+		{Role: "user", Content: "list pods"},
+		{Role: "assistant", ToolCalls: []ollama.ToolCall{call}},
+		{Role: "user", Content: "Function call result: {}"},
+	}
+	if _, repairs := repairOllamaHistory(valid); len(repairs) != 0 {
+		t.Errorf("a valid history was repaired: %v", repairs)
+	}
+
+	history := []ollama.Message{
+		{Role: "user", Content: "list pods"},
+		{Role: "assistant", ToolCalls: []ollama.ToolCall{call}},
+		{Role: "assistant"},
+		{Role: "assistant", Content: "done"},
+	}
+	got, repairs := repairOllamaHistory(history)
+	if len(repairs) != 2 || len(got) != 4 {
+		t.Fatalf("unexpected repairs %q of history of %d messages", repairs, len(got))
+	}
+	if got[2].Role != "tool" || got[2].Content != MissingToolResult {
+		t.Errorf("missing result was not added before the next message: %+v", got[2])
+	}
+}
+
+func TestRepairLlamaCppHistory(t *testing.T) {
+	assistant := func(names ...string) llamacppChatMessage {
+		msg := llamacppChatMessage{Role: "assistant"}
+		for _, name := range names {
+			msg.ToolCalls = append(msg.ToolCalls, llamacppToolCall{Type: "function", Function: llamacppFunctionCall{Name: name}})
+		}
+		return msg
+	}
+	tool := llamacppChatMessage{Role: "tool", Content: ptrTo("{}")}
+	user := llamacppChatMessage{Role: "user", Content: ptrTo("list pods")}
+
+	history := []llamacppChatMessage{user, assistant("kubectl", "bash"), tool, user, tool, assistant()}
+	got, repairs := repairLlamaCppHistory(history)
+	if len(repairs) != 3 || len(got) != 5 {
+		t.Fatalf("unexpected repairs %q of history of %d messages", repairs, len(got))
+	}
+	if got[3].Role != "tool" || *got[3].Content != MissingToolResult || got[4].Role != "user" {
+		t.Errorf("missing result was not added before the next message: %+v", got)
+	}
+}
+
+func TestReportHistoryRepairs(t *testing.T) {
+	var reported []string
+	ctx := WithHistoryRepairReporter(context.Background(), func(provider string, repairs []string) {
+		reported = append(reported, provider)
+		reported = append(reported, repairs...)
+	})
+	reportHistoryRepairs(ctx, "Ollama", nil)
+	if len(reported) != 0 {
+		t.Errorf("expected no report without repairs, got %v", reported)
+	}
+	reportHistoryRepairs(ctx, "Ollama", []string{"dropped an empty assistant message"})
+	if len(reported) != 2 || reported[0] != "Ollama" {
+		t.Errorf("unexpected report %v", reported)
+	}
+	// Contexts without a reporter only log
+	reportHistoryRepairs(context.Background(), "Ollama", []string{"dropped an empty assistant message"})
+}
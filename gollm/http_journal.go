@@ -16,32 +16,65 @@ package gollm
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
 
 	"k8s.io/klog/v2"
 )
 
+// sensitiveHeaders are redacted before requests and responses are recorded.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":             true,
+	"Proxy-Authorization":       true,
+	"Api-Key":                   true,
+	"X-Api-Key":                 true,
+	"X-Goog-Api-Key":            true,
+	"Ocp-Apim-Subscription-Key": true,
+	"X-Amz-Security-Token":      true,
+	"Cookie":                    true,
+	"Set-Cookie":                true,
+}
+
+// sensitiveQueryParams are redacted from request URLs before they are recorded.
+var sensitiveQueryParams = []string{"key", "api_key", "api-key"}
+
+const redacted = "REDACTED"
+
 // journalingRoundTripper wraps an existing http.RoundTripper to record requests and responses.
 type journalingRoundTripper struct {
 	next http.RoundTripper // The actual transport that does the network call
+
+	// recorder is used if set, otherwise the recorder is taken from the request context.
+	recorder journal.Recorder
+}
+
+func (jrt *journalingRoundTripper) recorderFor(req *http.Request) journal.Recorder {
+	if jrt.recorder != nil {
+		return jrt.recorder
+	}
+	return journal.RecorderFromContext(req.Context())
 }
 
 // RoundTrip satisfies the http.RoundTripper interface. It intercepts an HTTP request,
 // logs it, passes it to the next handler, and then logs the response.
-// It includes special handling to correctly parse and summarize streaming responses.
+// Credentials are redacted from the recorded request and response.
+// Streaming responses are passed through as they are read and recorded once fully consumed.
 func (jrt *journalingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	recorder := journal.RecorderFromContext(req.Context())
+	recorder := jrt.recorderFor(req)
 
 	// Log the outgoing request.
 	reqBytes, err := httputil.DumpRequestOut(req, true)
 	if err == nil {
 		err = recorder.Write(req.Context(), &journal.Event{
 			Action:  journal.ActionHTTPRequest,
-			Payload: map[string]any{"request": string(reqBytes)},
+			Payload: &journal.LLMRequest{Request: redactDump(string(reqBytes))},
 		})
 		if err != nil {
 			klog.Errorf("Error writing outgoing request to journal: %v", err)
@@ -53,7 +86,7 @@ func (jrt *journalingRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 	if err != nil {
 		writeErr := recorder.Write(req.Context(), &journal.Event{
 			Action:  journal.ActionHTTPError,
-			Payload: map[string]any{"error": "http transport failed", "detail": err.Error()},
+			Payload: &journal.LLMResponse{Error: "http transport failed", Detail: err.Error()},
 		})
 		if writeErr != nil {
 			klog.Errorf("Error writing RoundTripper error to journal: %v", writeErr)
@@ -62,43 +95,126 @@ func (jrt *journalingRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 		return nil, err
 	}
 
-	// Read the entire response body so we can log it and then pass it along.
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		// handle error
-		klog.Errorf("Error reading response body (for logging): %v", err)
-		return nil, err
+	resp.Body = &journalingBody{
+		ReadCloser: resp.Body,
+		ctx:        req.Context(),
+		recorder:   recorder,
+		status:     resp.Status,
+		headers:    redactHeaders(resp.Header),
 	}
-	resp.Body.Close() // Close the original body
+	return resp, nil
+}
+
+// journalingBody records the response body once it has been fully read or closed,
+// so that streaming responses reach the client without being buffered first.
+type journalingBody struct {
+	io.ReadCloser
+
+	ctx      context.Context
+	recorder journal.Recorder
+	status   string
+	headers  http.Header
+
+	buf  bytes.Buffer
+	once sync.Once
+}
 
-	// Default payload is the raw body, for non-streaming responses.
-	logPayload := map[string]any{
-		"status":  resp.Status,
-		"headers": resp.Header,
-		"body":    string(bodyBytes),
+func (b *journalingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.record()
 	}
+	return n, err
+}
 
-	// Write the final event to the journal.
-	err = recorder.Write(req.Context(), &journal.Event{
-		Action:  journal.ActionHTTPResponse,
-		Payload: logPayload,
+func (b *journalingBody) Close() error {
+	b.record()
+	return b.ReadCloser.Close()
+}
+
+func (b *journalingBody) record() {
+	b.once.Do(func() {
+		err := b.recorder.Write(b.ctx, &journal.Event{
+			Action: journal.ActionHTTPResponse,
+			Payload: &journal.LLMResponse{
+				Status:  b.status,
+				Headers: b.headers,
+				Body:    b.buf.String(),
+			},
+		})
+		if err != nil {
+			// Log the error and continue
+			klog.Errorf("Error writing to journal: %v", err)
+		}
 	})
-	if err != nil {
-		// Log the error and continue
-		klog.Errorf("Error writing to journal: %v", err)
+}
+
+// redactHeaders returns a copy of h with sensitive header values replaced.
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for name := range out {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = []string{redacted}
+		}
 	}
+	return out
+}
 
-	// IMPORTANT: Return the original, untouched body to the client.
-	resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	return resp, nil
+// redactDump redacts credentials from the output of httputil.DumpRequestOut.
+func redactDump(dump string) string {
+	head, body, found := strings.Cut(dump, "\r\n\r\n")
+	lines := strings.Split(head, "\r\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = redactRequestLine(line)
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if ok && sensitiveHeaders[http.CanonicalHeaderKey(strings.TrimSpace(name))] {
+			lines[i] = name + ": " + redacted
+		}
+	}
+	head = strings.Join(lines, "\r\n")
+	if !found {
+		return head
+	}
+	return head + "\r\n\r\n" + body
+}
+
+// redactRequestLine redacts sensitive query parameters from a request line like "GET /path?key=abc HTTP/1.1".
+func redactRequestLine(line string) string {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		return line
+	}
+	u, err := url.ParseRequestURI(parts[1])
+	if err != nil || u.RawQuery == "" {
+		return line
+	}
+	q := u.Query()
+	changed := false
+	for _, param := range sensitiveQueryParams {
+		if q.Has(param) {
+			q.Set(param, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return line
+	}
+	u.RawQuery = q.Encode()
+	return parts[0] + " " + u.RequestURI() + " " + parts[2]
 }
 
 // withJournaling is a decorator function that wraps an http.Client's transport
-// with the journalingRoundTripper, but only if a recorder is found in the context.
-func withJournaling(client *http.Client) *http.Client {
+// with the journalingRoundTripper. Events are written to recorder if set, or to
+// the recorder found in the request context.
+func withJournaling(client *http.Client, recorder journal.Recorder) *http.Client {
 	// wrap the transport
 	client.Transport = &journalingRoundTripper{
-		next: client.Transport,
+		next:     client.Transport,
+		recorder: recorder,
 	}
 
 	return client
@@ -0,0 +1,86 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+)
+
+type memoryRecorder struct {
+	mu     sync.Mutex
+	events []*journal.Event
+}
+
+func (r *memoryRecorder) Write(ctx context.Context, event *journal.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *memoryRecorder) Close() error {
+	return nil
+}
+
+func TestJournalingRoundTripper_RedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	recorder := &memoryRecorder{}
+	client := withJournaling(&http.Client{Transport: http.DefaultTransport}, recorder)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/models?key=secret-query-key&alt=sse", strings.NewReader(`{"prompt":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("X-Goog-Api-Key", "secret-goog-key")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"ok":true}` {
+		t.Errorf("unexpected response body %q", body)
+	}
+
+	if len(recorder.events) != 2 {
+		t.Fatalf("expected request and response events, got %d", len(recorder.events))
+	}
+	request, _ := recorder.events[0].GetString("request")
+	for _, secret := range []string{"secret-token", "secret-goog-key", "secret-query-key"} {
+		if strings.Contains(request, secret) {
+			t.Errorf("recorded request contains %q:\n%s", secret, request)
+		}
+	}
+	if !strings.Contains(request, `{"prompt":"hi"}`) || !strings.Contains(request, "alt=sse") {
+		t.Errorf("recorded request is missing non-sensitive content:\n%s", request)
+	}
+	if got, _ := recorder.events[1].GetString("body"); got != `{"ok":true}` {
+		t.Errorf("expected recorded response body, got %q", got)
+	}
+}
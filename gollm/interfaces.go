@@ -16,10 +16,13 @@ package gollm
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"iter"
+	"reflect"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 )
@@ -34,6 +37,11 @@ type Client interface {
 	// GenerateCompletion generates a single completion for a given prompt.
 	GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error)
 
+	// GenerateCompletionStreaming is the streaming version of
+	// GenerateCompletion. Providers that can't stream completions return the
+	// whole completion at once, see StreamCompletion.
+	GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error)
+
 	// SetResponseSchema constrains LLM responses to match the provided schema.
 	// Calling with nil will clear the current schema.
 	SetResponseSchema(schema *Schema) error
@@ -57,6 +65,14 @@ type Chat interface {
 	// for function calling.
 	SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error
 
+	// SetToolChoice controls whether the LLM calls tools in the next responses,
+	// e.g. to force a tool call when one is required. The LLM decides by default.
+	SetToolChoice(choice ToolChoice) error
+
+	// SetStopSequences sets the sequences that stop the generation of the next
+	// responses. Calling with nil clears them.
+	SetStopSequences(stopSequences []string) error
+
 	// IsRetryableError returns true if the error is retryable.
 	IsRetryableError(error) bool
 
@@ -64,6 +80,87 @@ type Chat interface {
 	Initialize(messages []*api.Message) error
 }
 
+// ToolChoiceMode is how the LLM chooses to call tools, see ToolChoice.
+type ToolChoiceMode string
+
+const (
+	// ToolChoiceAuto lets the LLM decide whether to call tools.
+	ToolChoiceAuto ToolChoiceMode = "auto"
+	// ToolChoiceAny forces the LLM to call at least one tool.
+	ToolChoiceAny ToolChoiceMode = "any"
+	// ToolChoiceNone prevents the LLM from calling tools.
+	ToolChoiceNone ToolChoiceMode = "none"
+	// ToolChoiceTool forces the LLM to call the tool named by ToolChoice.Tool.
+	ToolChoiceTool ToolChoiceMode = "tool"
+)
+
+// ToolChoice controls the tool calls of the LLM. The zero value is
+// ToolChoiceAuto.
+type ToolChoice struct {
+	Mode ToolChoiceMode `json:"mode,omitempty"`
+	// Tool is the name of the tool to call, with ToolChoiceTool.
+	Tool string `json:"tool,omitempty"`
+}
+
+func (c ToolChoice) validate() error {
+	switch c.Mode {
+	case "", ToolChoiceAuto, ToolChoiceAny, ToolChoiceNone:
+		return nil
+	case ToolChoiceTool:
+		if c.Tool == "" {
+			return fmt.Errorf("tool choice %q requires the name of the tool", c.Mode)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown tool choice %q, expected auto, any, none or tool", c.Mode)
+}
+
+// ToolOutputDropper is implemented by chats that can shrink their history by
+// dropping the outputs of earlier tool calls, e.g. to recover from a
+// ContextLengthError. Chats implementing it remove the contents of a failed
+// request from their history, so that the request can be sent again.
+type ToolOutputDropper interface {
+	// DropToolOutputs replaces the outputs of the oldest count tool calls in
+	// the history that weren't dropped yet with a note that they were dropped.
+	// It returns the number of outputs dropped, fewer than count once the
+	// history has no other outputs.
+	DropToolOutputs(count int) int
+}
+
+// DroppedToolOutput replaces the tool outputs dropped from the history.
+const DroppedToolOutput = "The output was dropped from the conversation history to fit in the context window of the model."
+
+// DropToolOutputs drops the outputs of the oldest count tool calls in the
+// history of chat, if chat supports it. It returns the number of outputs
+// dropped.
+func DropToolOutputs(chat Chat, count int) int {
+	if dropper, ok := chat.(ToolOutputDropper); ok {
+		return dropper.DropToolOutputs(count)
+	}
+	return 0
+}
+
+// ToolOutputPruner is implemented by chats that can replace the outputs of
+// earlier tool calls in their history, e.g. to keep large outputs out of the
+// following requests.
+type ToolOutputPruner interface {
+	// PruneToolOutputs replaces the outputs of the tool calls in the history,
+	// but the last keep of them, for which prune returns a replacement. prune
+	// is given the output as sent to the LLM, as JSON. It returns the number
+	// of outputs replaced.
+	PruneToolOutputs(keep int, prune func(output string) (string, bool)) int
+}
+
+// PruneToolOutputs prunes the outputs of the tool calls in the history of
+// chat but the last keep ones, if chat supports it, see ToolOutputPruner. It
+// returns the number of outputs replaced.
+func PruneToolOutputs(chat Chat, keep int, prune func(output string) (string, bool)) int {
+	if pruner, ok := chat.(ToolOutputPruner); ok {
+		return pruner.PruneToolOutputs(keep, prune)
+	}
+	return 0
+}
+
 // CompletionRequest is a request to generate a completion for a given prompt.
 type CompletionRequest struct {
 	Model  string `json:"model,omitempty"`
@@ -76,12 +173,32 @@ type CompletionResponse interface {
 	UsageMetadata() any
 }
 
+// CompletionResponseIterator is a streaming completion response from the LLM.
+// Each response holds the next chunk of the completion.
+type CompletionResponseIterator iter.Seq2[CompletionResponse, error]
+
+// StreamCompletion adapts GenerateCompletion to GenerateCompletionStreaming,
+// for the clients that can't stream completions: the completion is returned
+// as a single chunk.
+func StreamCompletion(ctx context.Context, client Client, req *CompletionRequest) (CompletionResponseIterator, error) {
+	response, err := client.GenerateCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(CompletionResponse, error) bool) {
+		yield(response, nil)
+	}, nil
+}
+
 // FunctionCall is a function call to a language model.
 // The LLM will reply with a FunctionCall to a user-defined function, and we will send the results back.
 type FunctionCall struct {
 	ID        string         `json:"id,omitempty"`
 	Name      string         `json:"name,omitempty"`
 	Arguments map[string]any `json:"arguments,omitempty"`
+	// ParseError is set when the arguments sent by the LLM were not valid
+	// JSON. Arguments is empty in that case.
+	ParseError string `json:"parseError,omitempty"`
 }
 
 // FunctionDefinition is a user-defined function that can be called by the LLM.
@@ -136,6 +253,57 @@ type FunctionCallResult struct {
 	Result map[string]any `json:"result,omitempty"`
 }
 
+// ImageContent is an image sent to the LLM as part of a user message, e.g. a
+// screenshot of a dashboard. Only some providers support images.
+type ImageContent struct {
+	// MIMEType is the media type of the image, e.g. "image/png".
+	MIMEType string `json:"mimeType"`
+	Data     []byte `json:"data"`
+}
+
+// DataURL returns the image as a base64 data URL.
+func (i ImageContent) DataURL() string {
+	return "data:" + i.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// ProviderRawContent is content in the native format of an LLM provider,
+// passed to it as is, for the blocks gollm has no type of its own for, e.g.
+// Bedrock guard content or Gemini file data. Value is a value of the type of
+// the provider's SDK:
+//   - gemini and vertexai: *genai.Part
+//   - openai, and the providers using its SDK: openai.ChatCompletionMessageParamUnion,
+//     or responses.ResponseInputItemUnionParam with the Responses API
+//   - azopenai: azopenai.ChatRequestMessageClassification
+//   - bedrock: types.ContentBlock
+//   - ollama: api.Message
+//
+// Providers reject other values, and providers without raw content, with an
+// error wrapping ErrUnsupportedRawContent.
+type ProviderRawContent struct {
+	// Provider is the provider the content is meant for, e.g. "bedrock".
+	Provider string
+	Value    any
+}
+
+// ErrUnsupportedRawContent is wrapped by the errors of the providers given
+// ProviderRawContent they can't send.
+var ErrUnsupportedRawContent = errors.New("unsupported raw content")
+
+// rawContentValue returns the value of raw, if it is a T, the type of the
+// content of provider.
+func rawContentValue[T any](raw ProviderRawContent, provider string) (T, error) {
+	value, ok := raw.Value.(T)
+	if !ok {
+		return value, fmt.Errorf("%w: %s takes raw content of type %v, got %T meant for %q", ErrUnsupportedRawContent, provider, reflect.TypeFor[T](), raw.Value, raw.Provider)
+	}
+	return value, nil
+}
+
+// rejectRawContent returns the error of the providers without raw content.
+func rejectRawContent(raw ProviderRawContent, provider string) error {
+	return fmt.Errorf("%w: %s takes no raw content, got %T meant for %q", ErrUnsupportedRawContent, provider, raw.Value, raw.Provider)
+}
+
 // ChatResponse is a generic chat response from the LLM.
 type ChatResponse interface {
 	UsageMetadata() any
@@ -148,6 +316,80 @@ type ChatResponse interface {
 // ChatResponseIterator is a streaming chat response from the LLM.
 type ChatResponseIterator iter.Seq2[ChatResponse, error]
 
+// StopReason is why the LLM stopped generating a response, normalized across
+// providers.
+type StopReason string
+
+const (
+	// StopReasonUnknown is reported when the provider doesn't say why it
+	// stopped, e.g. for the chunks of a stream but the last.
+	StopReasonUnknown StopReason = ""
+	// StopReasonEnd is the natural end of the response.
+	StopReasonEnd StopReason = "end"
+	// StopReasonToolCalls is the end of a response calling tools.
+	StopReasonToolCalls StopReason = "tool_calls"
+	// StopReasonMaxTokens is a response cut off at the maximum number of
+	// output tokens, which may be continued.
+	StopReasonMaxTokens StopReason = "max_tokens"
+	// StopReasonStopSequence is a response ended by one of the stop
+	// sequences of the chat. Providers following the OpenAI API report
+	// StopReasonEnd instead.
+	StopReasonStopSequence StopReason = "stop_sequence"
+	// StopReasonContentFilter is a response stopped by a safety or content
+	// filter of the provider.
+	StopReasonContentFilter StopReason = "content_filter"
+	// StopReasonOther is any other reason reported by the provider.
+	StopReasonOther StopReason = "other"
+)
+
+// ResponseMetadata is implemented by the chat responses that report why the
+// LLM stopped and the model that served them.
+type ResponseMetadata interface {
+	// StopReason is why the LLM stopped. In streams, only the last chunks
+	// report it.
+	StopReason() StopReason
+	// ModelVersion is the model that served the response, as reported by the
+	// provider, e.g. the dated version of the model alias of the chat. It is
+	// empty if unknown.
+	ModelVersion() string
+}
+
+// ResponseStopReason returns why the LLM stopped generating response, if the
+// response reports it, see ResponseMetadata.
+func ResponseStopReason(response ChatResponse) StopReason {
+	if metadata, ok := response.(ResponseMetadata); ok {
+		return metadata.StopReason()
+	}
+	return StopReasonUnknown
+}
+
+// ResponseModelVersion returns the model that served response, if the
+// response reports it, see ResponseMetadata.
+func ResponseModelVersion(response ChatResponse) string {
+	if metadata, ok := response.(ResponseMetadata); ok {
+		return metadata.ModelVersion()
+	}
+	return ""
+}
+
+// openAIStopReason maps the finish reasons of the OpenAI API, also used by
+// compatible providers, to StopReason.
+func openAIStopReason(finishReason string) StopReason {
+	switch finishReason {
+	case "":
+		return StopReasonUnknown
+	case "stop":
+		return StopReasonEnd
+	case "length":
+		return StopReasonMaxTokens
+	case "tool_calls", "function_call":
+		return StopReasonToolCalls
+	case "content_filter":
+		return StopReasonContentFilter
+	}
+	return StopReasonOther
+}
+
 // Candidate is one of a set of candidate response from the LLM.
 type Candidate interface {
 	// String returns a string representation of the candidate.
@@ -45,6 +45,7 @@ type LlamaCppClient struct {
 	baseURL        *url.URL
 	httpClient     *http.Client
 	responseSchema *llamacppSchema
+	seed           *int64
 }
 
 type LlamaCppChat struct {
@@ -52,6 +53,9 @@ type LlamaCppChat struct {
 	model   string
 	history []llamacppChatMessage
 	tools   []llamacppTool
+
+	toolChoice    string
+	stopSequences []string
 }
 
 var _ Client = &LlamaCppClient{}
@@ -59,7 +63,10 @@ var _ Client = &LlamaCppClient{}
 // NewLlamaCppClient creates a new client for llama.cpp.
 // Supports custom HTTP client and skipVerifySSL via ClientOptions.
 func NewLlamaCppClient(ctx context.Context, opts ClientOptions) (*LlamaCppClient, error) {
-	host := os.Getenv("LLAMACPP_HOST")
+	host := opts.Endpoint
+	if host == "" {
+		host = os.Getenv("LLAMACPP_HOST")
+	}
 	if host == "" {
 		host = "http://127.0.0.1:8080/"
 	}
@@ -70,11 +77,15 @@ func NewLlamaCppClient(ctx context.Context, opts ClientOptions) (*LlamaCppClient
 	}
 	klog.Infof("using llama.cpp with base url %v", baseURL.String())
 
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
 
 	return &LlamaCppClient{
 		baseURL:    baseURL,
 		httpClient: httpClient,
+		seed:       opts.Seed,
 	}, nil
 }
 
@@ -156,6 +167,11 @@ func (c *LlamaCppClient) ListModels(ctx context.Context) ([]string, error) {
 	return nil, fmt.Errorf("model switching not supported by llama.cpp")
 }
 
+// GenerateCompletionStreaming falls back to GenerateCompletion.
+func (c *LlamaCppClient) GenerateCompletionStreaming(ctx context.Context, request *CompletionRequest) (CompletionResponseIterator, error) {
+	return StreamCompletion(ctx, c, request)
+}
+
 func (c *LlamaCppClient) SetResponseSchema(responseSchema *Schema) error {
 	llamaSchema := toLlamacppSchema(responseSchema)
 	c.responseSchema = llamaSchema
@@ -209,16 +225,26 @@ func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 				Content: ptrTo(string(resultJSON)),
 			}
 			c.history = append(c.history, message)
+		case ProviderRawContent:
+			return nil, rejectRawContent(v, "llamacpp")
 		default:
 			return nil, fmt.Errorf("unsupported content type: %T", v)
 		}
 	}
+	history, repairs := repairLlamaCppHistory(c.history)
+	reportHistoryRepairs(ctx, "llama.cpp", repairs)
+	c.history = history
 
 	req := &llamacppChatRequest{
 		Model:    c.model,
 		Messages: c.history,
 		// Stream:   ptrTo(false),
 		Tools: c.tools,
+		Stop:  c.stopSequences,
+		Seed:  c.client.seed,
+	}
+	if len(c.tools) > 0 {
+		req.ToolChoice = c.toolChoice
 	}
 
 	var llmacppResponse *LlamaCppChatResponse
@@ -251,9 +277,10 @@ func (c *LlamaCppChat) Send(ctx context.Context, contents ...any) (ChatResponse,
 				if toolCall.Function.Arguments != "" {
 					arguments := make(map[string]any)
 					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
-						return nil, fmt.Errorf("parsing function call arguments: %w", err)
+						functionCall.ParseError = err.Error()
+					} else {
+						functionCall.Arguments = arguments
 					}
-					functionCall.Arguments = arguments
 				}
 				functionCalls = append(functionCalls, functionCall)
 			}
@@ -296,7 +323,18 @@ func (c *LlamaCppChat) IsRetryableError(err error) bool {
 }
 
 func (c *LlamaCppChat) Initialize(messages []*api.Message) error {
-	klog.Warning("chat history persistence is not supported for provider 'llamacpp', using in-memory chat history")
+	var history []llamacppChatMessage
+	if len(c.history) > 0 && c.history[0].Role == "system" {
+		history = append(history, c.history[0])
+	}
+	for _, turn := range api.NormalizeHistory(messages) {
+		role := "user"
+		if turn.Role == api.HistoryRoleModel {
+			role = "assistant"
+		}
+		history = append(history, llamacppChatMessage{Role: role, Content: ptrTo(turn.Text)})
+	}
+	c.history = history
 	return nil
 }
 
@@ -337,6 +375,19 @@ func (r *LlamaCppChatResponse) UsageMetadata() any {
 	return nil
 }
 
+var _ ResponseMetadata = &LlamaCppChatResponse{}
+
+func (r *LlamaCppChatResponse) StopReason() StopReason {
+	if len(r.LlamaCppResponse.Choices) == 0 {
+		return StopReasonUnknown
+	}
+	return openAIStopReason(r.LlamaCppResponse.Choices[0].FinishReason)
+}
+
+func (r *LlamaCppChatResponse) ModelVersion() string {
+	return r.LlamaCppResponse.Model
+}
+
 func (r *LlamaCppChatResponse) Candidates() []Candidate {
 	var cads []Candidate
 	for _, candidate := range r.candidates {
@@ -389,6 +440,30 @@ func (c *LlamaCppChat) SetFunctionDefinitions(functionDefinitions []*FunctionDef
 	return nil
 }
 
+// SetToolChoice sets the tool_choice of the next requests. The llama.cpp server
+// can't be forced to call a given tool.
+func (c *LlamaCppChat) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	switch choice.Mode {
+	case ToolChoiceAny:
+		c.toolChoice = "required"
+	case ToolChoiceNone:
+		c.toolChoice = "none"
+	case ToolChoiceTool:
+		return fmt.Errorf("tool choice %q is not supported by llama.cpp", choice.Mode)
+	default:
+		c.toolChoice = ""
+	}
+	return nil
+}
+
+func (c *LlamaCppChat) SetStopSequences(stopSequences []string) error {
+	c.stopSequences = stopSequences
+	return nil
+}
+
 func toLlamacppTool(fnDef *FunctionDefinition) llamacppTool {
 	function := &llamacppFunction{
 		Description: fnDef.Description,
@@ -485,6 +560,10 @@ type llamacppChatRequest struct {
 	Model    string                `json:"model,omitempty"`
 	Messages []llamacppChatMessage `json:"messages,omitempty"`
 	Tools    []llamacppTool        `json:"tools,omitempty"`
+	// ToolChoice is auto, none or required
+	ToolChoice string   `json:"tool_choice,omitempty"`
+	Stop       []string `json:"stop,omitempty"`
+	Seed       *int64   `json:"seed,omitempty"`
 }
 
 type llamacppChatResponse struct {
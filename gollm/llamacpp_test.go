@@ -0,0 +1,63 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLlamaCppChat_GenerationOptions(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(ctx, "llamacpp", WithEndpoint(server.URL), WithSeed(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := client.StartChat("You are a helpful assistant.", "")
+	if err := chat.SetFunctionDefinitions([]*FunctionDefinition{{Name: "kubectl", Parameters: &Schema{Type: TypeObject}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SetToolChoice(ToolChoice{Mode: ToolChoiceAny}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat.SetStopSequences([]string{"DONE"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chat.Send(ctx, "list the pods"); err != nil {
+		t.Fatal(err)
+	}
+
+	if request["seed"] != float64(42) {
+		t.Errorf("expected seed 42, got %v", request["seed"])
+	}
+	if request["tool_choice"] != "required" {
+		t.Errorf("expected tool_choice required, got %v", request["tool_choice"])
+	}
+	if stop, _ := request["stop"].([]any); len(stop) != 1 || stop[0] != "DONE" {
+		t.Errorf("expected stop sequences [DONE], got %v", request["stop"])
+	}
+}
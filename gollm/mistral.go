@@ -0,0 +1,187 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	openai "github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"k8s.io/klog/v2"
+)
+
+func init() {
+	if err := RegisterProvider("mistral", newMistralClientFactory); err != nil {
+		klog.Fatalf("Failed to register mistral provider: %v", err)
+	}
+}
+
+// newMistralClientFactory is the factory function for creating Mistral clients with options.
+func newMistralClientFactory(ctx context.Context, opts ClientOptions) (Client, error) {
+	return NewMistralClient(ctx, opts)
+}
+
+const mistralDefaultModel = "mistral-large-latest"
+
+// MistralClient implements the gollm.Client interface for Mistral AI. The
+// chat completions API of Mistral is compatible with the one of OpenAI, but
+// for the seed parameter and the JSON schemas of tools.
+type MistralClient struct {
+	*OpenAIClient
+}
+
+var _ Client = &MistralClient{}
+
+// NewMistralClient creates a new client for Mistral AI, configured with
+// MISTRAL_API_KEY, and MISTRAL_ENDPOINT unless ClientOptions.Endpoint is set.
+func NewMistralClient(ctx context.Context, opts ClientOptions) (*MistralClient, error) {
+	apiKey := os.Getenv("MISTRAL_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("MISTRAL_API_KEY environment variable not set")
+	}
+
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("MISTRAL_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = "https://api.mistral.ai/v1"
+	} else {
+		klog.Infof("Using custom Mistral endpoint: %s", endpoint)
+	}
+
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+	return &MistralClient{
+		OpenAIClient: &OpenAIClient{
+			client: openai.NewClient(
+				option.WithAPIKey(apiKey),
+				option.WithBaseURL(endpoint),
+				option.WithHTTPClient(httpClient),
+			),
+			seed:           opts.Seed,
+			embeddingModel: "mistral-embed",
+		},
+	}, nil
+}
+
+// StartChat starts a new chat session.
+func (c *MistralClient) StartChat(systemPrompt, model string) Chat {
+	if model == "" {
+		model = mistralDefaultModel
+	}
+	klog.V(1).Infof("Starting new Mistral chat session with model: %s", model)
+
+	history := []openai.ChatCompletionMessageParamUnion{}
+	if systemPrompt != "" {
+		history = append(history, openai.SystemMessage(systemPrompt))
+	}
+	chat := &openAIChatSession{
+		client:         c.client,
+		history:        history,
+		model:          model,
+		toolParameters: mistralToolParameters,
+		provider:       "Mistral",
+	}
+	if c.seed != nil {
+		// Mistral names the seed random_seed
+		chat.requestOptions = append(chat.requestOptions, option.WithJSONSet("random_seed", *c.seed))
+	}
+	return chat
+}
+
+// SetResponseSchema is not implemented yet for Mistral.
+func (c *MistralClient) SetResponseSchema(schema *Schema) error {
+	klog.Warning("MistralClient.SetResponseSchema is not implemented yet")
+	return nil
+}
+
+// ListModels lists the models available with the API key.
+func (c *MistralClient) ListModels(ctx context.Context) ([]string, error) {
+	res, err := c.client.Models.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing models from Mistral: %w", err)
+	}
+	modelIDs := make([]string, 0, len(res.Data))
+	for _, model := range res.Data {
+		modelIDs = append(modelIDs, model.ID)
+	}
+	slices.Sort(modelIDs)
+	return modelIDs, nil
+}
+
+// mistralToolParameters converts the parameters of a function to the JSON
+// schema flavor of Mistral: unlike OpenAI it keeps integers, but objects must
+// list their properties, arrays their items, and required properties must
+// exist.
+func mistralToolParameters(schema *Schema) (openai.FunctionParameters, error) {
+	return mistralSchema(schema, "parameters")
+}
+
+func mistralSchema(schema *Schema, path string) (map[string]any, error) {
+	if schema == nil {
+		return map[string]any{"type": TypeObject, "properties": map[string]any{}}, nil
+	}
+
+	out := map[string]any{}
+	if schema.Description != "" {
+		out["description"] = schema.Description
+	}
+	switch schema.Type {
+	case TypeObject, "":
+		out["type"] = TypeObject
+		properties := map[string]any{}
+		for name, property := range schema.Properties {
+			converted, err := mistralSchema(property, path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			properties[name] = converted
+		}
+		out["properties"] = properties
+		var required []string
+		for _, name := range schema.Required {
+			if _, ok := schema.Properties[name]; !ok {
+				klog.Warningf("dropping required property %q missing from %s", name, path)
+				continue
+			}
+			required = append(required, name)
+		}
+		if len(required) > 0 {
+			out["required"] = required
+		}
+	case TypeArray:
+		out["type"] = TypeArray
+		items, err := mistralSchema(schema.Items, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		if schema.Items == nil {
+			items = map[string]any{"type": TypeString}
+		}
+		out["items"] = items
+	case TypeString, TypeNumber, TypeInteger, TypeBoolean:
+		out["type"] = schema.Type
+	default:
+		return nil, fmt.Errorf("unsupported type %q of %s", schema.Type, path)
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMistralSchema(t *testing.T) {
+	schema := &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"command":  {Type: TypeString, Description: "The command to run"},
+			"replicas": {Type: TypeInteger},
+			"labels":   {Type: TypeArray},
+		},
+		Required: []string{"command", "missing"},
+	}
+	got, err := mistralToolParameters(schema)
+	if err != nil {
+		t.Fatalf("converting schema: %v", err)
+	}
+	want := map[string]any{
+		"type": TypeObject,
+		"properties": map[string]any{
+			"command":  map[string]any{"type": TypeString, "description": "The command to run"},
+			"replicas": map[string]any{"type": TypeInteger},
+			"labels":   map[string]any{"type": TypeArray, "items": map[string]any{"type": TypeString}},
+		},
+		"required": []string{"command"},
+	}
+	if !reflect.DeepEqual(map[string]any(got), want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := mistralToolParameters(&Schema{Type: TypeObject, Properties: map[string]*Schema{"x": {Type: "date"}}}); err == nil {
+		t.Errorf("expected an error for an unsupported type")
+	}
+}
+
+func TestMistralChat(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.NotFound(w, r)
+			return
+		}
+		var request map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, request)
+
+		if request["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"id":"2","object":"chat.completion.chunk","model":"mistral-small-latest","choices":[{"index":0,"delta":{"role":"assistant","content":"","tool_calls":[{"id":"D681PevKs","index":0,"type":"function","function":{"name":"kubectl","arguments":"{\"command\":\"kubectl get pods\"}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"mistral-small-latest","choices":[{"index":0,"message":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("MISTRAL_API_KEY", "test-key")
+	seed := int64(42)
+	client, err := NewMistralClient(context.Background(), ClientOptions{Endpoint: server.URL, Seed: &seed})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	chat := client.StartChat("You are helpful.", "mistral-small-latest")
+	if err := chat.SetFunctionDefinitions([]*FunctionDefinition{{
+		Name:       "kubectl",
+		Parameters: &Schema{Type: TypeObject, Properties: map[string]*Schema{"command": {Type: TypeString}, "tail": {Type: TypeInteger}}},
+	}}); err != nil {
+		t.Fatalf("setting functions: %v", err)
+	}
+
+	response, err := chat.Send(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+	if text, _ := response.Candidates()[0].Parts()[0].AsText(); text != "Hello!" {
+		t.Errorf("unexpected response %q", text)
+	}
+	if requests[0]["random_seed"] != float64(42) || requests[0]["seed"] != nil {
+		t.Errorf("expected random_seed and no seed in %v", requests[0])
+	}
+	tools := requests[0]["tools"].([]any)
+	parameters := tools[0].(map[string]any)["function"].(map[string]any)["parameters"].(map[string]any)
+	if tail := parameters["properties"].(map[string]any)["tail"].(map[string]any); tail["type"] != "integer" {
+		t.Errorf("integer parameter was sent as %v", tail)
+	}
+
+	iterator, err := chat.SendStreaming(context.Background(), "list pods")
+	if err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+	var calls []FunctionCall
+	for response, err := range iterator {
+		if err != nil {
+			t.Fatalf("streaming: %v", err)
+		}
+		if response == nil {
+			continue
+		}
+		for _, candidate := range response.Candidates() {
+			for _, part := range candidate.Parts() {
+				if c, ok := part.AsFunctionCalls(); ok {
+					calls = append(calls, c...)
+				}
+			}
+		}
+	}
+	if len(calls) != 1 || calls[0].ID != "D681PevKs" || calls[0].Arguments["command"] != "kubectl get pods" {
+		t.Errorf("unexpected function calls %+v", calls)
+	}
+}
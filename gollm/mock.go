@@ -0,0 +1,364 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	if err := RegisterProvider("mock", mockFactory); err != nil {
+		klog.Fatalf("Failed to register mock provider: %v", err)
+	}
+}
+
+// MockFixture scripts the responses of the mock provider, which answers
+// without calling an LLM, e.g. to run the agent in CI or to reproduce a bug in
+// the tool loop deterministically.
+type MockFixture struct {
+	// Models are the models listed by the client.
+	Models []string `json:"models,omitempty"`
+	// Responses are the responses to chat messages, in order.
+	Responses []MockResponse `json:"responses"`
+	// Completions are the responses to GenerateCompletion, in order.
+	Completions []MockResponse `json:"completions,omitempty"`
+}
+
+// MockResponse is a scripted response of the mock provider.
+type MockResponse struct {
+	// Expect, if set, must be contained in the message the response answers,
+	// so that a script that no longer matches the conversation fails.
+	Expect    string         `json:"expect,omitempty"`
+	Text      string         `json:"text,omitempty"`
+	ToolCalls []MockToolCall `json:"toolCalls,omitempty"`
+	// Error, if set, is returned instead of a response.
+	Error string `json:"error,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+	// StopReason is why the response stopped, e.g. max_tokens to script a
+	// truncated response. Defaults to end, or tool_calls with tool calls.
+	StopReason StopReason `json:"stopReason,omitempty"`
+}
+
+// MockToolCall is a scripted function call.
+type MockToolCall struct {
+	ID        string         `json:"id,omitempty"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// LoadMockFixture reads a fixture from a YAML or JSON file.
+func LoadMockFixture(path string) (*MockFixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading mock fixture: %w", err)
+	}
+	var fixture MockFixture
+	if err := yaml.UnmarshalStrict(b, &fixture); err != nil {
+		return nil, fmt.Errorf("parsing mock fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// mockFactory creates a mock client. The fixture is read from the endpoint
+// option, the provider URL (mock:///path/to/fixture.yaml) or the
+// MOCK_LLM_FIXTURE environment variable.
+func mockFactory(ctx context.Context, opts ClientOptions) (Client, error) {
+	path := opts.Endpoint
+	if path == "" && opts.URL != nil {
+		path = opts.URL.Host + opts.URL.Path
+	}
+	if path == "" {
+		path = os.Getenv("MOCK_LLM_FIXTURE")
+	}
+	if path == "" {
+		return nil, fmt.Errorf("the mock provider needs a fixture file: set MOCK_LLM_FIXTURE or use mock:///path/to/fixture.yaml")
+	}
+	fixture, err := LoadMockFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMockClient(fixture), nil
+}
+
+// MockClient is a Client answering with the scripted responses of a fixture.
+// The responses are shared by all the chats of the client.
+type MockClient struct {
+	fixture *MockFixture
+
+	mu          sync.Mutex
+	responses   int
+	completions int
+	calls       int
+}
+
+var _ Client = &MockClient{}
+
+// NewMockClient creates a client answering with the responses of fixture.
+func NewMockClient(fixture *MockFixture) *MockClient {
+	return &MockClient{fixture: fixture}
+}
+
+func (c *MockClient) Close() error {
+	return nil
+}
+
+func (c *MockClient) StartChat(systemPrompt, model string) Chat {
+	return &mockChat{client: c}
+}
+
+func (c *MockClient) GenerateCompletion(ctx context.Context, req *CompletionRequest) (CompletionResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	response, err := next(c.fixture.Completions, &c.completions, "completion", req.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	return &mockCompletionResponse{response: response}, nil
+}
+
+// GenerateCompletionStreaming returns the next completion of the fixture as a
+// single chunk.
+func (c *MockClient) GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error) {
+	return StreamCompletion(ctx, c, req)
+}
+
+func (c *MockClient) SetResponseSchema(schema *Schema) error {
+	return nil
+}
+
+func (c *MockClient) ListModels(ctx context.Context) ([]string, error) {
+	if len(c.fixture.Models) == 0 {
+		return []string{"mock"}, nil
+	}
+	return c.fixture.Models, nil
+}
+
+// nextResponse returns the chat response to message.
+func (c *MockClient) nextResponse(message string) (*mockChatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	response, err := next(c.fixture.Responses, &c.responses, "response", message)
+	if err != nil {
+		return nil, err
+	}
+	r := &mockChatResponse{text: response.Text, usage: response.Usage, stopReason: response.StopReason}
+	for _, call := range response.ToolCalls {
+		c.calls++
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", c.calls)
+		}
+		args := call.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		r.calls = append(r.calls, FunctionCall{ID: id, Name: call.Name, Arguments: args})
+	}
+	return r, nil
+}
+
+// next returns the i-th of responses and advances i, checking that it answers
+// message.
+func next(responses []MockResponse, i *int, kind string, message string) (*MockResponse, error) {
+	if *i >= len(responses) {
+		return nil, fmt.Errorf("mock: the fixture has no %s left after %d", kind, len(responses))
+	}
+	response := &responses[*i]
+	*i++
+	if response.Expect != "" && !strings.Contains(message, response.Expect) {
+		return nil, fmt.Errorf("mock: %s %d expects a message containing %q, got %q", kind, *i, response.Expect, message)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("mock: %s", response.Error)
+	}
+	return response, nil
+}
+
+type mockChat struct {
+	client *MockClient
+}
+
+func (c *mockChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	return c.client.nextResponse(mockMessage(contents))
+}
+
+func (c *mockChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	response, err := c.Send(ctx, contents...)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(ChatResponse, error) bool) {
+		yield(response, nil)
+	}, nil
+}
+
+func (c *mockChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	return nil
+}
+
+// SetToolChoice is ignored, the fixture decides the tool calls.
+func (c *mockChat) SetToolChoice(choice ToolChoice) error {
+	return choice.validate()
+}
+
+func (c *mockChat) SetStopSequences(stopSequences []string) error {
+	return nil
+}
+
+func (c *mockChat) IsRetryableError(err error) bool {
+	return false
+}
+
+func (c *mockChat) Initialize(messages []*api.Message) error {
+	return nil
+}
+
+// mockMessage renders the contents of a message as text, for matching it
+// against the expectation of a response.
+func mockMessage(contents []any) string {
+	var parts []string
+	for _, content := range contents {
+		switch v := content.(type) {
+		case string:
+			parts = append(parts, v)
+		case FunctionCallResult:
+			b, _ := json.Marshal(v)
+			parts = append(parts, string(b))
+		case ImageContent:
+			parts = append(parts, "[image "+v.MIMEType+"]")
+		default:
+			parts = append(parts, fmt.Sprintf("%v", v))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+type mockChatResponse struct {
+	text       string
+	calls      []FunctionCall
+	usage      *Usage
+	stopReason StopReason
+}
+
+var _ ResponseMetadata = &mockChatResponse{}
+
+func (r *mockChatResponse) StopReason() StopReason {
+	switch {
+	case r.stopReason != StopReasonUnknown:
+		return r.stopReason
+	case len(r.calls) > 0:
+		return StopReasonToolCalls
+	}
+	return StopReasonEnd
+}
+
+func (r *mockChatResponse) ModelVersion() string {
+	return "mock"
+}
+
+func (r *mockChatResponse) UsageMetadata() any {
+	if r.usage == nil {
+		return nil
+	}
+	return r.usage
+}
+
+func (r *mockChatResponse) Candidates() []Candidate {
+	return []Candidate{r}
+}
+
+func (r *mockChatResponse) String() string {
+	return r.text
+}
+
+func (r *mockChatResponse) Parts() []Part {
+	var parts []Part
+	if r.text != "" {
+		parts = append(parts, mockPart{text: r.text})
+	}
+	if len(r.calls) > 0 {
+		parts = append(parts, mockPart{calls: r.calls})
+	}
+	return parts
+}
+
+type mockPart struct {
+	text  string
+	calls []FunctionCall
+}
+
+func (p mockPart) AsText() (string, bool) {
+	return p.text, p.calls == nil
+}
+
+func (p mockPart) AsFunctionCalls() ([]FunctionCall, bool) {
+	return p.calls, p.calls != nil
+}
+
+type mockCompletionResponse struct {
+	response *MockResponse
+}
+
+func (r *mockCompletionResponse) Response() string {
+	return r.response.Text
+}
+
+func (r *mockCompletionResponse) UsageMetadata() any {
+	if r.response.Usage == nil {
+		return nil
+	}
+	return r.response.Usage
+}
+
+// mockEmbeddingDimensions is the default size of the embeddings of the mock
+// provider.
+const mockEmbeddingDimensions = 256
+
+var _ Embedder = &MockClient{}
+
+// Embed computes deterministic embeddings without an LLM, hashing the words
+// of the texts, so that texts sharing words are similar. They are good enough
+// to test retrieval.
+func (c *MockClient) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	dimensions := req.Dimensions
+	if dimensions <= 0 {
+		dimensions = mockEmbeddingDimensions
+	}
+	response := &EmbeddingResponse{Model: "mock"}
+	for _, text := range req.Texts {
+		vector := make([]float32, dimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%uint32(dimensions)]++
+		}
+		response.Embeddings = append(response.Embeddings, vector)
+	}
+	return response, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mockFixture = `
+models: [mock-pro]
+responses:
+- expect: why is my pod failing
+  text: Let me look at the pods.
+  toolCalls:
+  - name: kubectl
+    arguments: {command: kubectl get pods}
+  usage: {inputTokens: 100, outputTokens: 20}
+- expect: CrashLoopBackOff
+  text: The pod is crashing, its image doesn't exist.
+completions:
+- text: Failing pod investigation
+`
+
+func newTestMockClient(t *testing.T) Client {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	if err := os.WriteFile(path, []byte(mockFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(context.Background(), "mock://"+path)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func collectParts(t *testing.T, response ChatResponse) (string, []FunctionCall) {
+	t.Helper()
+	var text string
+	var calls []FunctionCall
+	for _, part := range response.Candidates()[0].Parts() {
+		if s, ok := part.AsText(); ok {
+			text += s
+		}
+		if c, ok := part.AsFunctionCalls(); ok {
+			calls = append(calls, c...)
+		}
+	}
+	return text, calls
+}
+
+func TestMockClient(t *testing.T) {
+	ctx := context.Background()
+	client := newTestMockClient(t)
+	chat := client.StartChat("system prompt", "mock-pro")
+
+	iter, err := chat.SendStreaming(ctx, "why is my pod failing?")
+	if err != nil {
+		t.Fatalf("SendStreaming() error = %v", err)
+	}
+	var responses []ChatResponse
+	for response, err := range iter {
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		responses = append(responses, response)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("expected 1 streamed response, got %d", len(responses))
+	}
+	text, calls := collectParts(t, responses[0])
+	if text != "Let me look at the pods." {
+		t.Errorf("unexpected text %q", text)
+	}
+	want := []FunctionCall{{ID: "call_1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+	if usage, ok := NormalizeUsage(responses[0].UsageMetadata()); !ok || usage.TotalTokens != 120 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if got := ResponseStopReason(responses[0]); got != StopReasonToolCalls {
+		t.Errorf("unexpected stop reason %q", got)
+	}
+
+	response, err := chat.Send(ctx, FunctionCallResult{ID: "call_1", Name: "kubectl", Result: map[string]any{"stdout": "web-1 0/1 CrashLoopBackOff"}})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if text, _ := collectParts(t, response); !strings.Contains(text, "crashing") {
+		t.Errorf("unexpected text %q", text)
+	}
+	if got := ResponseStopReason(response); got != StopReasonEnd {
+		t.Errorf("unexpected stop reason %q", got)
+	}
+
+	if _, err := chat.Send(ctx, "thanks"); err == nil || !strings.Contains(err.Error(), "no response left") {
+		t.Errorf("expected an error once the script is exhausted, got %v", err)
+	}
+
+	completion, err := client.GenerateCompletion(ctx, &CompletionRequest{Prompt: "name this session"})
+	if err != nil || completion.Response() != "Failing pod investigation" {
+		t.Errorf("unexpected completion %v, %v", completion, err)
+	}
+
+	models, err := client.ListModels(ctx)
+	if err != nil || !reflect.DeepEqual(models, []string{"mock-pro"}) {
+		t.Errorf("unexpected models %v, %v", models, err)
+	}
+}
+
+func TestMockClient_UnexpectedMessage(t *testing.T) {
+	chat := newTestMockClient(t).StartChat("", "mock-pro")
+	_, err := chat.Send(context.Background(), "list the nodes")
+	if err == nil || !strings.Contains(err.Error(), `expects a message containing "why is my pod failing"`) {
+		t.Errorf("expected an error for a message not matching the script, got %v", err)
+	}
+}
+
+func TestMockClient_NoFixture(t *testing.T) {
+	t.Setenv("MOCK_LLM_FIXTURE", "")
+	if _, err := NewClient(context.Background(), "mock"); err == nil || !strings.Contains(err.Error(), "fixture") {
+		t.Errorf("expected an error without a fixture, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"strings"
+)
+
+// nonChatModelMarkers are parts of the names of models that don't chat, e.g.
+// text-embedding-3-small, gemini-2.5-flash-preview-tts or whisper-1.
+var nonChatModelMarkers = []string{
+	"embed", "tts", "whisper", "transcribe", "audio", "realtime",
+	"dall-e", "imagen", "image-generation", "veo", "moderation", "aqa",
+}
+
+// isChatModel reports whether the name of model is that of a chat model.
+func isChatModel(model string) bool {
+	name := normalizeModelName(model)
+	for _, marker := range nonChatModelMarkers {
+		if strings.Contains(name, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClosestModel returns the chat model of models whose name is the closest to
+// model, e.g. to replace a model the provider doesn't know. The model sharing
+// the longest prefix with model wins, so that it stays in the same family,
+// ties going to the smallest edit distance. Models that don't chat, e.g.
+// embedding or speech models, are left out. It returns "" if no model is
+// left.
+func ClosestModel(model string, models []string) string {
+	name := normalizeModelName(model)
+	closest, closestDistance, closestPrefix := "", 0, 0
+	for _, candidate := range models {
+		if !isChatModel(candidate) {
+			continue
+		}
+		candidateName := normalizeModelName(candidate)
+		distance := editDistance(name, candidateName)
+		prefix := commonPrefixLength(name, candidateName)
+		if closest == "" || prefix > closestPrefix || prefix == closestPrefix && distance < closestDistance {
+			closest, closestDistance, closestPrefix = candidate, distance, prefix
+		}
+	}
+	return closest
+}
+
+// normalizeModelName strips the resource prefix of model names, e.g.
+// "models/gemini-2.5-pro", and ignores case.
+func normalizeModelName(model string) string {
+	return strings.ToLower(strings.TrimPrefix(model, "models/"))
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func commonPrefixLength(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
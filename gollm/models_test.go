@@ -0,0 +1,47 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import "testing"
+
+func TestClosestModel(t *testing.T) {
+	models := []string{
+		"models/gemini-2.5-pro", "models/gemini-2.5-flash", "models/gemini-2.0-flash", "gpt-4.1",
+		"models/gemini-2.5-flash-preview-tts", "models/gemini-embedding-001", "gpt-4o-transcribe", "whisper-1",
+	}
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"gemini-2.5-flash-preview-05-20", "models/gemini-2.5-flash"},
+		{"gemini-1.5-pro", "models/gemini-2.5-pro"},
+		{"Gemini-2.0-Flash-001", "models/gemini-2.0-flash"},
+		{"gpt-4.1-2025", "gpt-4.1"},
+		{"gemini-2.5-flash-preview-tts-2", "models/gemini-2.5-flash"},
+		{"gemini-embedding-2", "models/gemini-2.5-pro"},
+		{"gpt-4o-transcribe-2", "gpt-4.1"},
+	}
+	for _, tt := range tests {
+		if got := ClosestModel(tt.model, models); got != tt.want {
+			t.Errorf("ClosestModel(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+	if got := ClosestModel("gemini-2.5-pro", nil); got != "" {
+		t.Errorf("expected no model without candidates, got %q", got)
+	}
+	if got := ClosestModel("text-embedding-3-small", []string{"text-embedding-3-large"}); got != "" {
+		t.Errorf("expected no model without chat models, got %q", got)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/ollama/ollama/api"
 	"github.com/ollama/ollama/envconfig"
@@ -44,6 +45,7 @@ const (
 
 type OllamaClient struct {
 	client *api.Client
+	seed   *int64
 }
 
 type OllamaChat struct {
@@ -51,19 +53,47 @@ type OllamaChat struct {
 	model   string
 	history []api.Message
 	tools   []api.Tool
+
+	// noTools is set with ToolChoiceNone, tools are not sent then
+	noTools       bool
+	stopSequences []string
+	seed          *int64
 }
 
 var _ Client = &OllamaClient{}
 
+// OllamaMaxStreamLineBytes is the largest line the Ollama SDK reads: its
+// scanner has a 512 KB buffer, newline included, whatever MaxStreamLineBytes
+// is.
+const OllamaMaxStreamLineBytes = 512*1000 - 1
+
 // NewOllamaClient creates a new client for Ollama.
 // Supports custom HTTP client and skipVerifySSL via ClientOptions if the SDK supports it.
 func NewOllamaClient(ctx context.Context, opts ClientOptions) (*OllamaClient, error) {
 	// Create custom HTTP client with SSL verification option from client options
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
-	client := api.NewClient(envconfig.Host(), httpClient)
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+	// The SDK reads all the responses line by line, and silently stops at a
+	// line it can't read: such lines fail with ErrStreamLineTooLong instead,
+	// recorded by withStreamLineError.
+	maxBytes := opts.MaxStreamLineBytes
+	if maxBytes <= 0 || maxBytes > OllamaMaxStreamLineBytes {
+		maxBytes = OllamaMaxStreamLineBytes
+	}
+	httpClient.Transport = &streamLineTransport{base: httpClient.Transport, maxBytes: maxBytes, allResponses: true}
+	host := envconfig.Host()
+	if opts.Endpoint != "" {
+		if host, err = url.Parse(opts.Endpoint); err != nil {
+			return nil, fmt.Errorf("parsing endpoint %q: %w", opts.Endpoint, err)
+		}
+	}
+	client := api.NewClient(host, httpClient)
 
 	return &OllamaClient{
 		client: client,
+		seed:   opts.Seed,
 	}, nil
 }
 
@@ -85,7 +115,11 @@ func (c *OllamaClient) GenerateCompletion(ctx context.Context, request *Completi
 		return nil
 	}
 
+	ctx, streamErr := withStreamLineError(ctx)
 	err := c.client.Generate(ctx, req, respFunc)
+	if err == nil {
+		err = streamErr()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +141,12 @@ func (c *OllamaClient) ListModels(ctx context.Context) ([]string, error) {
 	return models, nil
 }
 
+// GenerateCompletionStreaming falls back to GenerateCompletion, returning the
+// completion as a single chunk.
+func (c *OllamaClient) GenerateCompletionStreaming(ctx context.Context, request *CompletionRequest) (CompletionResponseIterator, error) {
+	return StreamCompletion(ctx, c, request)
+}
+
 func (c *OllamaClient) SetResponseSchema(schema *Schema) error {
 	return nil
 }
@@ -115,6 +155,7 @@ func (c *OllamaClient) StartChat(systemPrompt, model string) Chat {
 	return &OllamaChat{
 		client: c.client,
 		model:  model,
+		seed:   c.seed,
 		history: []api.Message{
 			{
 				Role:    "system",
@@ -152,10 +193,19 @@ func (c *OllamaChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 				Content: fmt.Sprintf("Function call result: %s", v.Result),
 			}
 			c.history = append(c.history, message)
+		case ProviderRawContent:
+			message, err := rawContentValue[api.Message](v, "ollama")
+			if err != nil {
+				return nil, err
+			}
+			c.history = append(c.history, message)
 		default:
 			return nil, fmt.Errorf("unsupported content type: %T", v)
 		}
 	}
+	history, repairs := repairOllamaHistory(c.history)
+	reportHistoryRepairs(ctx, "Ollama", repairs)
+	c.history = history
 
 	req := &api.ChatRequest{
 		Model:    c.model,
@@ -164,6 +214,16 @@ func (c *OllamaChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 		Stream: new(bool),
 		Tools:  c.tools,
 	}
+	if c.noTools {
+		req.Tools = nil
+	}
+	req.Options = map[string]any{}
+	if len(c.stopSequences) > 0 {
+		req.Options["stop"] = c.stopSequences
+	}
+	if c.seed != nil {
+		req.Options["seed"] = *c.seed
+	}
 
 	var ollamaResponse *OllamaChatResponse
 
@@ -186,7 +246,11 @@ func (c *OllamaChat) Send(ctx context.Context, contents ...any) (ChatResponse, e
 		return nil
 	}
 
+	ctx, streamErr := withStreamLineError(ctx)
 	err := c.client.Chat(ctx, req, respFunc)
+	if err == nil {
+		err = streamErr()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -210,7 +274,18 @@ func (c *OllamaChat) SendStreaming(ctx context.Context, contents ...any) (ChatRe
 }
 
 func (c *OllamaChat) Initialize(messages []*kctlApi.Message) error {
-	klog.Warning("chat history persistence is not supported for provider 'ollama', using in-memory chat history")
+	var history []api.Message
+	if len(c.history) > 0 && c.history[0].Role == "system" {
+		history = append(history, c.history[0])
+	}
+	for _, turn := range kctlApi.NormalizeHistory(messages) {
+		role := "user"
+		if turn.Role == kctlApi.HistoryRoleModel {
+			role = "assistant"
+		}
+		history = append(history, api.Message{Role: role, Content: turn.Text})
+	}
+	c.history = history
 	return nil
 }
 
@@ -236,6 +311,27 @@ func (r *OllamaChatResponse) UsageMetadata() any {
 	return nil
 }
 
+var _ ResponseMetadata = &OllamaChatResponse{}
+
+func (r *OllamaChatResponse) StopReason() StopReason {
+	switch r.ollamaResponse.DoneReason {
+	case "":
+		return StopReasonUnknown
+	case "stop":
+		if len(r.ollamaResponse.Message.ToolCalls) > 0 {
+			return StopReasonToolCalls
+		}
+		return StopReasonEnd
+	case "length":
+		return StopReasonMaxTokens
+	}
+	return StopReasonOther
+}
+
+func (r *OllamaChatResponse) ModelVersion() string {
+	return r.ollamaResponse.Model
+}
+
 func (r *OllamaChatResponse) Candidates() []Candidate {
 	var cads []Candidate
 	for _, candidate := range r.candidates {
@@ -298,6 +394,25 @@ func (c *OllamaChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefin
 	return nil
 }
 
+// SetToolChoice sets whether tools are sent to the model. Ollama has no tool
+// choice, the model can't be forced to call a tool.
+func (c *OllamaChat) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	switch choice.Mode {
+	case ToolChoiceAny, ToolChoiceTool:
+		return fmt.Errorf("tool choice %q is not supported by Ollama", choice.Mode)
+	}
+	c.noTools = choice.Mode == ToolChoiceNone
+	return nil
+}
+
+func (c *OllamaChat) SetStopSequences(stopSequences []string) error {
+	c.stopSequences = stopSequences
+	return nil
+}
+
 func fnDefToOllamaTool(fnDef *FunctionDefinition) api.Tool {
 	tool := api.Tool{
 		Type: "function",
@@ -0,0 +1,48 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaStreamLineTooLong(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ollama answers requests that aren't streamed with JSON
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\"response\":%q,\"done\":true}\n", strings.Repeat("x", OllamaMaxStreamLineBytes))
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(context.Background(), ClientOptions{Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GenerateCompletion(context.Background(), &CompletionRequest{Model: "llama3", Prompt: "hi"})
+	if !errors.Is(err, ErrStreamLineTooLong) {
+		t.Errorf("err = %v, want ErrStreamLineTooLong", err)
+	}
+
+	chat := client.StartChat("", "llama3")
+	if _, err := chat.Send(context.Background(), "hi"); !errors.Is(err, ErrStreamLineTooLong) {
+		t.Errorf("err = %v, want ErrStreamLineTooLong", err)
+	}
+}
@@ -74,8 +74,15 @@ func init() {
 // OpenAIClient implements the gollm.Client interface for OpenAI models.
 type OpenAIClient struct {
 	client openai.Client
+	seed   *int64
+	// embeddingModel is the default model of Embed, for OpenAI-compatible
+	// providers. Defaults to openAIDefaultEmbeddingModel.
+	embeddingModel string
 }
 
+// openAIDefaultEmbeddingModel is the default model of Embed.
+const openAIDefaultEmbeddingModel = openai.EmbeddingModelTextEmbedding3Small
+
 // Ensure OpenAIClient implements the Client interface.
 var _ Client = &OpenAIClient{}
 
@@ -92,7 +99,10 @@ func NewOpenAIClient(ctx context.Context, opts ClientOptions) (*OpenAIClient, er
 	options := []option.RequestOption{option.WithAPIKey(apiKey)}
 
 	// Check for custom endpoint or API base URL
-	baseURL := openAIEndpoint
+	baseURL := opts.Endpoint
+	if baseURL == "" {
+		baseURL = openAIEndpoint
+	}
 	if baseURL == "" {
 		baseURL = openAIAPIBase
 	}
@@ -102,13 +112,16 @@ func NewOpenAIClient(ctx context.Context, opts ClientOptions) (*OpenAIClient, er
 		options = append(options, option.WithBaseURL(baseURL))
 	}
 
-	// Support custom HTTP client (e.g., skip SSL verification)
-	httpClient := createCustomHTTPClient(opts.SkipVerifySSL)
-	httpClient = withJournaling(httpClient)
+	// Support custom HTTP client (e.g., skip SSL verification, proxies and custom CAs)
+	httpClient, err := createCustomHTTPClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
 	options = append(options, option.WithHTTPClient(httpClient))
 
 	return &OpenAIClient{
 		client: openai.NewClient(options...),
+		seed:   opts.Seed,
 	}, nil
 }
 
@@ -126,6 +139,9 @@ func (c *OpenAIClient) StartChat(systemPrompt, model string) Chat {
 	klog.V(1).Infof("Starting new OpenAI chat session with model: %s", selectedModel)
 
 	if openAIUseResponsesAPI {
+		if c.seed != nil {
+			klog.Warning("the OpenAI responses API has no seed, sampling is not deterministic")
+		}
 		// Initialize history with system prompt if provided
 		history := responses.ResponseInputParam{}
 		if systemPrompt != "" {
@@ -167,6 +183,7 @@ func (c *OpenAIClient) StartChat(systemPrompt, model string) Chat {
 		client:  c.client,
 		history: history,
 		model:   selectedModel,
+		seed:    c.seed,
 		// functionDefinitions and tools will be set later via SetFunctionDefinitions
 	}
 }
@@ -216,6 +233,36 @@ func (c *OpenAIClient) GenerateCompletion(ctx context.Context, req *CompletionRe
 	return resp, nil
 }
 
+// GenerateCompletionStreaming is the streaming version of GenerateCompletion.
+func (c *OpenAIClient) GenerateCompletionStreaming(ctx context.Context, req *CompletionRequest) (CompletionResponseIterator, error) {
+	klog.Infof("OpenAI GenerateCompletionStreaming called with model: %s", req.Model)
+	klog.V(1).Infof("Prompt:\n%s", req.Prompt)
+
+	stream := c.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
+		Model: openai.ChatModel(req.Model),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage(req.Prompt),
+		},
+	})
+
+	return func(yield func(CompletionResponse, error) bool) {
+		defer stream.Close()
+
+		for stream.Next() {
+			chunk := stream.Current()
+			if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+				continue
+			}
+			if !yield(&simpleCompletionResponse{content: chunk.Choices[0].Delta.Content}, nil) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil {
+			yield(nil, fmt.Errorf("OpenAI streaming error: %w", err))
+		}
+	}, nil
+}
+
 // SetResponseSchema is not implemented yet.
 func (c *OpenAIClient) SetResponseSchema(schema *Schema) error {
 	klog.Warning("OpenAIClient.SetResponseSchema is not implemented yet")
@@ -239,6 +286,48 @@ func (c *OpenAIClient) ListModels(ctx context.Context) ([]string, error) {
 	return modelIDs, nil
 }
 
+var _ Embedder = &OpenAIClient{}
+
+// Embed computes embeddings with the embeddings endpoint, also served by
+// OpenAI-compatible providers.
+func (c *OpenAIClient) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = c.embeddingModel
+	}
+	if model == "" {
+		model = openAIDefaultEmbeddingModel
+	}
+	params := openai.EmbeddingNewParams{
+		Model:          model,
+		Input:          openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: req.Texts},
+		EncodingFormat: openai.EmbeddingNewParamsEncodingFormatFloat,
+	}
+	if req.Dimensions > 0 {
+		params.Dimensions = openai.Int(int64(req.Dimensions))
+	}
+	res, err := c.client.Embeddings.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("computing embeddings with OpenAI: %w", err)
+	}
+	response := &EmbeddingResponse{
+		Embeddings: make([][]float32, len(req.Texts)),
+		Model:      res.Model,
+		Usage:      &Usage{InputTokens: res.Usage.PromptTokens, TotalTokens: res.Usage.TotalTokens},
+	}
+	for _, data := range res.Data {
+		if data.Index < 0 || int(data.Index) >= len(req.Texts) {
+			return nil, fmt.Errorf("embedding for text %d of %d", data.Index, len(req.Texts))
+		}
+		embedding := make([]float32, len(data.Embedding))
+		for i, v := range data.Embedding {
+			embedding[i] = float32(v)
+		}
+		response.Embeddings[data.Index] = embedding
+	}
+	return response, nil
+}
+
 // Chat Session Implementation
 
 type openAIChatSession struct {
@@ -247,6 +336,27 @@ type openAIChatSession struct {
 	model               string
 	functionDefinitions []*FunctionDefinition            // Stored in gollm format
 	tools               []openai.ChatCompletionToolParam // Stored in OpenAI format
+	toolChoice          ToolChoice
+	stopSequences       []string
+	seed                *int64
+
+	// requestOptions are added to every request, e.g. for the parameters of
+	// OpenAI-compatible providers.
+	requestOptions []option.RequestOption
+	// toolParameters converts the parameters of functions, for providers
+	// with their own JSON schema flavor. The OpenAI conversion is used if nil.
+	toolParameters func(*Schema) (openai.FunctionParameters, error)
+	// provider names the provider in the reports of history repairs, OpenAI
+	// if empty.
+	provider string
+}
+
+// providerName returns the name of the provider of the chat.
+func (cs *openAIChatSession) providerName() string {
+	if cs.provider == "" {
+		return "OpenAI"
+	}
+	return cs.provider
 }
 
 // Ensure openAIChatSession implements the Chat interface.
@@ -262,7 +372,13 @@ func (cs *openAIChatSession) SetFunctionDefinitions(defs []*FunctionDefinition)
 			klog.Infof("Processing function definition: %s", gollmDef.Name)
 
 			// Process function parameters
-			params, err := cs.convertFunctionParameters(gollmDef)
+			var params openai.FunctionParameters
+			var err error
+			if cs.toolParameters != nil && gollmDef.Parameters != nil {
+				params, err = cs.toolParameters(gollmDef.Parameters)
+			} else {
+				params, err = cs.convertFunctionParameters(gollmDef)
+			}
 			if err != nil {
 				return fmt.Errorf("failed to process parameters for function %s: %w", gollmDef.Name, err)
 			}
@@ -280,14 +396,49 @@ func (cs *openAIChatSession) SetFunctionDefinitions(defs []*FunctionDefinition)
 	return nil
 }
 
+// SetToolChoice sets the tool_choice of the next requests.
+func (cs *openAIChatSession) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	cs.toolChoice = choice
+	return nil
+}
+
+// SetStopSequences sets the stop sequences of the next requests.
+func (cs *openAIChatSession) SetStopSequences(stopSequences []string) error {
+	cs.stopSequences = stopSequences
+	return nil
+}
+
+// openAIToolChoice converts choice to the tool_choice of the chat completions
+// API, also used by the OpenAI-compatible providers.
+func openAIToolChoice(choice ToolChoice) openai.ChatCompletionToolChoiceOptionUnionParam {
+	switch choice.Mode {
+	case ToolChoiceAny:
+		return openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(string(openai.ChatCompletionToolChoiceOptionAutoRequired))}
+	case ToolChoiceNone:
+		return openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(string(openai.ChatCompletionToolChoiceOptionAutoNone))}
+	case ToolChoiceTool:
+		return openai.ChatCompletionToolChoiceOptionParamOfChatCompletionNamedToolChoice(openai.ChatCompletionNamedToolChoiceFunctionParam{Name: choice.Tool})
+	}
+	// The API defaults to auto
+	return openai.ChatCompletionToolChoiceOptionUnionParam{}
+}
+
 // Send sends the user message(s), appends to history, and gets the LLM response.
 func (cs *openAIChatSession) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	klog.V(1).InfoS("openAIChatSession.Send called", "model", cs.model, "history_len", len(cs.history))
 
 	// Process and append messages to history
+	requestStart := len(cs.history)
 	if err := cs.addContentsToHistory(contents); err != nil {
 		return nil, err
 	}
+	unrepaired := cs.history
+	history, repairs := repairOpenAIHistory(cs.history)
+	reportHistoryRepairs(ctx, cs.providerName(), repairs)
+	cs.history = history
 
 	// Prepare and send API request
 	chatReq := openai.ChatCompletionNewParams{
@@ -296,14 +447,23 @@ func (cs *openAIChatSession) Send(ctx context.Context, contents ...any) (ChatRes
 	}
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
+		chatReq.ToolChoice = openAIToolChoice(cs.toolChoice)
+	}
+	if len(cs.stopSequences) > 0 {
+		chatReq.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: cs.stopSequences}
+	}
+	if cs.seed != nil {
+		chatReq.Seed = openai.Int(*cs.seed)
 	}
 
 	// Call the OpenAI API
 	klog.V(1).InfoS("Sending request to OpenAI Chat API", "model", cs.model, "messages", len(chatReq.Messages), "tools", len(chatReq.Tools))
-	completion, err := cs.client.Chat.Completions.New(ctx, chatReq)
+	completion, err := cs.client.Chat.Completions.New(ctx, chatReq, cs.requestOptions...)
 	if err != nil {
 		// TODO: Check if error is retryable using cs.IsRetryableError
 		klog.Errorf("OpenAI ChatCompletion API error: %v", err)
+		// Remove the failed request, so that it can be sent again
+		cs.history = unrepaired[:requestStart]
 		return nil, fmt.Errorf("OpenAI chat completion failed: %w", err)
 	}
 	klog.V(1).InfoS("Received response from OpenAI Chat API", "id", completion.ID, "choices", len(completion.Choices))
@@ -333,9 +493,14 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 	klog.V(1).InfoS("Starting OpenAI streaming request", "model", cs.model)
 
 	// Process and append messages to history
+	requestStart := len(cs.history)
 	if err := cs.addContentsToHistory(contents); err != nil {
 		return nil, err
 	}
+	unrepaired := cs.history
+	history, repairs := repairOpenAIHistory(cs.history)
+	reportHistoryRepairs(ctx, cs.providerName(), repairs)
+	cs.history = history
 
 	// Prepare and send API request
 	chatReq := openai.ChatCompletionNewParams{
@@ -344,6 +509,13 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 	}
 	if len(cs.tools) > 0 {
 		chatReq.Tools = cs.tools
+		chatReq.ToolChoice = openAIToolChoice(cs.toolChoice)
+	}
+	if len(cs.stopSequences) > 0 {
+		chatReq.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: cs.stopSequences}
+	}
+	if cs.seed != nil {
+		chatReq.Seed = openai.Int(*cs.seed)
 	}
 
 	// Start the OpenAI streaming request
@@ -352,7 +524,7 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 		"messageCount", len(chatReq.Messages),
 		"toolCount", len(chatReq.Tools))
 
-	stream := cs.client.Chat.Completions.NewStreaming(ctx, chatReq)
+	stream := cs.client.Chat.Completions.NewStreaming(ctx, chatReq, cs.requestOptions...)
 
 	// Create an accumulator to track the full response
 	acc := openai.ChatCompletionAccumulator{}
@@ -424,8 +596,9 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 				toolCalls:   currentToolCalls,
 			}
 
-			// Only yield if there's actual content or tool calls to report
-			if streamResponse.content != "" || len(streamResponse.toolCalls) > 0 {
+			// Only yield if there's actual content or tool calls to report, or
+			// the reason the stream finished
+			if streamResponse.content != "" || len(streamResponse.toolCalls) > 0 || streamResponse.StopReason() != StopReasonUnknown {
 				if !yield(streamResponse, nil) {
 					return
 				}
@@ -435,10 +608,30 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 		// Check for errors after streaming completes
 		if err := stream.Err(); err != nil {
 			klog.Errorf("Error in OpenAI streaming: %v", err)
+			if lastResponseChunk == nil {
+				// Remove the failed request, so that it can be sent again
+				cs.history = unrepaired[:requestStart]
+			}
 			yield(nil, fmt.Errorf("OpenAI streaming error: %w", err))
 			return
 		}
 
+		// Some OpenAI-compatible servers, e.g. Mistral, end the stream with
+		// the chunk of the last tool call, which is never reported finished
+		var unfinishedToolCalls []openai.ChatCompletionMessageToolCall
+		if len(acc.Choices) > 0 {
+			for _, call := range acc.Choices[0].Message.ToolCalls[min(len(currentToolCalls), len(acc.Choices[0].Message.ToolCalls)):] {
+				unfinishedToolCalls = append(unfinishedToolCalls, openai.ChatCompletionMessageToolCall{
+					ID: call.ID,
+					Function: openai.ChatCompletionMessageToolCallFunction{
+						Name:      call.Function.Name,
+						Arguments: call.Function.Arguments,
+					},
+				})
+			}
+			currentToolCalls = append(currentToolCalls, unfinishedToolCalls...)
+		}
+
 		// Update conversation history with the complete message
 		if lastResponseChunk != nil {
 			completeMessage := openai.ChatCompletionMessage{
@@ -453,9 +646,51 @@ func (cs *openAIChatSession) SendStreaming(ctx context.Context, contents ...any)
 				"content_present", completeMessage.Content != "",
 				"tool_calls", len(completeMessage.ToolCalls))
 		}
+
+		if len(unfinishedToolCalls) > 0 && lastResponseChunk != nil {
+			yield(&openAIChatStreamResponse{
+				streamChunk: lastResponseChunk.streamChunk,
+				accumulator: acc,
+				toolCalls:   unfinishedToolCalls,
+			}, nil)
+		}
 	}, nil
 }
 
+// DropToolOutputs implements ToolOutputDropper.
+func (cs *openAIChatSession) DropToolOutputs(count int) int {
+	var outputs []int
+	for i, msg := range cs.history {
+		if msg.OfTool != nil && msg.OfTool.Content.OfString.Value != DroppedToolOutput {
+			outputs = append(outputs, i)
+		}
+	}
+	dropped := min(count, len(outputs))
+	for _, i := range outputs[:dropped] {
+		cs.history[i] = openai.ToolMessage(DroppedToolOutput, cs.history[i].OfTool.ToolCallID)
+	}
+	return dropped
+}
+
+// PruneToolOutputs implements ToolOutputPruner.
+func (cs *openAIChatSession) PruneToolOutputs(keep int, prune func(output string) (string, bool)) int {
+	var outputs []int
+	for i, msg := range cs.history {
+		if msg.OfTool != nil {
+			outputs = append(outputs, i)
+		}
+	}
+	pruned := 0
+	for _, i := range outputs[:max(len(outputs)-keep, 0)] {
+		tool := cs.history[i].OfTool
+		if replacement, ok := prune(tool.Content.OfString.Value); ok {
+			cs.history[i] = openai.ToolMessage(replacement, tool.ToolCallID)
+			pruned++
+		}
+	}
+	return pruned
+}
+
 // IsRetryableError determines if an error from the OpenAI API should be retried.
 func (cs *openAIChatSession) IsRetryableError(err error) bool {
 	if err == nil {
@@ -465,10 +700,27 @@ func (cs *openAIChatSession) IsRetryableError(err error) bool {
 }
 
 func (cs *openAIChatSession) Initialize(messages []*api.Message) error {
-	klog.Warning("chat history persistence is not supported for provider 'openai', using in-memory chat history")
+	cs.history = openAIHistory(cs.history, messages)
 	return nil
 }
 
+// openAIHistory returns the chat history of messages, keeping the system
+// prompt of history.
+func openAIHistory(history []openai.ChatCompletionMessageParamUnion, messages []*api.Message) []openai.ChatCompletionMessageParamUnion {
+	var initialized []openai.ChatCompletionMessageParamUnion
+	if len(history) > 0 && history[0].OfSystem != nil {
+		initialized = append(initialized, history[0])
+	}
+	for _, turn := range api.NormalizeHistory(messages) {
+		if turn.Role == api.HistoryRoleModel {
+			initialized = append(initialized, openai.AssistantMessage(turn.Text))
+		} else {
+			initialized = append(initialized, openai.UserMessage(turn.Text))
+		}
+	}
+	return initialized
+}
+
 // Helper structs for ChatResponse interface
 
 type openAIChatResponse struct {
@@ -496,6 +748,22 @@ func (r *openAIChatResponse) Candidates() []Candidate {
 	return candidates
 }
 
+var _ ResponseMetadata = (*openAIChatResponse)(nil)
+
+func (r *openAIChatResponse) StopReason() StopReason {
+	if r.openaiCompletion == nil || len(r.openaiCompletion.Choices) == 0 {
+		return StopReasonUnknown
+	}
+	return openAIStopReason(r.openaiCompletion.Choices[0].FinishReason)
+}
+
+func (r *openAIChatResponse) ModelVersion() string {
+	if r.openaiCompletion == nil {
+		return ""
+	}
+	return r.openaiCompletion.Model
+}
+
 type openAICandidate struct {
 	openaiChoice *openai.ChatCompletionChoice
 }
@@ -601,6 +869,19 @@ func (c *openAIStreamCandidate) Parts() []Part {
 	return parts
 }
 
+var _ ResponseMetadata = (*openAIChatStreamResponse)(nil)
+
+func (r *openAIChatStreamResponse) StopReason() StopReason {
+	if len(r.streamChunk.Choices) == 0 {
+		return StopReasonUnknown
+	}
+	return openAIStopReason(r.streamChunk.Choices[0].FinishReason)
+}
+
+func (r *openAIChatStreamResponse) ModelVersion() string {
+	return r.streamChunk.Model
+}
+
 // Add UsageMetadata implementation
 func (r *openAIChatStreamResponse) UsageMetadata() any {
 	if r.accumulator.Usage.TotalTokens > 0 {
@@ -823,6 +1104,16 @@ func (cs *openAIChatSession) addContentsToHistory(contents []any) error {
 				return fmt.Errorf("failed to marshal function call result %q: %w", c.Name, err)
 			}
 			cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
+		case ImageContent:
+			cs.history = append(cs.history, openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: c.DataURL()}),
+			}))
+		case ProviderRawContent:
+			message, err := rawContentValue[openai.ChatCompletionMessageParamUnion](c, "openai")
+			if err != nil {
+				return err
+			}
+			cs.history = append(cs.history, message)
 		default:
 			klog.Warningf("Unhandled content type: %T", content)
 			return fmt.Errorf("unhandled content type: %T", content)
@@ -847,19 +1138,22 @@ func convertToolCallsToFunctionCalls(toolCalls []openai.ChatCompletionMessageToo
 
 		// Parse function arguments with error handling
 		var args map[string]any
+		var parseError string
 		if tc.Function.Arguments != "" {
 			if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
 				klog.V(2).Infof("Error unmarshalling function arguments for %s: %v", tc.Function.Name, err)
 				args = make(map[string]any)
+				parseError = err.Error()
 			}
 		} else {
 			args = make(map[string]any)
 		}
 
 		calls = append(calls, FunctionCall{
-			ID:        tc.ID,
-			Name:      tc.Function.Name,
-			Arguments: args,
+			ID:         tc.ID,
+			Name:       tc.Function.Name,
+			Arguments:  args,
+			ParseError: parseError,
 		})
 	}
 	return calls, len(calls) > 0
@@ -69,6 +69,32 @@ func (cs *openAIResponseChatSession) SetFunctionDefinitions(defs []*FunctionDefi
 	return nil
 }
 
+// SetToolChoice sets the tool_choice of the next requests.
+func (cs *openAIResponseChatSession) SetToolChoice(choice ToolChoice) error {
+	if err := choice.validate(); err != nil {
+		return err
+	}
+	cs.params.ToolChoice = responses.ResponseNewParamsToolChoiceUnion{}
+	switch choice.Mode {
+	case ToolChoiceAny:
+		cs.params.ToolChoice.OfToolChoiceMode = openai.Opt(responses.ToolChoiceOptionsRequired)
+	case ToolChoiceNone:
+		cs.params.ToolChoice.OfToolChoiceMode = openai.Opt(responses.ToolChoiceOptionsNone)
+	case ToolChoiceTool:
+		cs.params.ToolChoice.OfFunctionTool = &responses.ToolChoiceFunctionParam{Name: choice.Tool}
+	}
+	return nil
+}
+
+// SetStopSequences fails if stopSequences is not empty, as the responses API
+// has no stop sequences.
+func (cs *openAIResponseChatSession) SetStopSequences(stopSequences []string) error {
+	if len(stopSequences) > 0 {
+		return fmt.Errorf("stop sequences are not supported by the OpenAI responses API")
+	}
+	return nil
+}
+
 // Send sends the user message(s), appends to history, and gets the LLM response.
 func (cs *openAIResponseChatSession) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
 	klog.V(1).InfoS("openAIChatSession.Send called", "model", cs.model, "history_len", len(cs.history))
@@ -85,6 +111,10 @@ func (cs *openAIResponseChatSession) SendStreaming(ctx context.Context, contents
 	if err := cs.addContentsToHistory(contents); err != nil {
 		return nil, err
 	}
+	history, repairs := repairOpenAIResponsesHistory(cs.history)
+	reportHistoryRepairs(ctx, "OpenAI responses", repairs)
+	cs.history = history
+
 	// Prepare and send API request
 	cs.params.Input = responses.ResponseNewParamsInputUnion{
 		OfInputItemList: cs.history,
@@ -145,7 +175,25 @@ func (cs *openAIResponseChatSession) IsRetryableError(err error) bool {
 }
 
 func (cs *openAIResponseChatSession) Initialize(messages []*api.Message) error {
-	klog.Warning("chat history persistence is not supported for provider 'openai', using in-memory chat history")
+	var history responses.ResponseInputParam
+	if len(cs.history) > 0 && cs.history[0].OfMessage != nil && cs.history[0].OfMessage.Role == responses.EasyInputMessageRoleSystem {
+		history = append(history, cs.history[0])
+	}
+	for _, turn := range api.NormalizeHistory(messages) {
+		role := responses.EasyInputMessageRoleUser
+		if turn.Role == api.HistoryRoleModel {
+			role = responses.EasyInputMessageRoleAssistant
+		}
+		history = append(history, responses.ResponseInputItemUnionParam{
+			OfMessage: &responses.EasyInputMessageParam{
+				Content: responses.EasyInputMessageContentUnionParam{
+					OfString: openai.String(turn.Text),
+				},
+				Role: role,
+			},
+		})
+	}
+	cs.history = history
 	return nil
 }
 
@@ -179,6 +227,41 @@ func (r *openAIResponseChatResponse) Candidates() []Candidate {
 	return candidates
 }
 
+var _ ResponseMetadata = (*openAIResponseChatResponse)(nil)
+
+func (r *openAIResponseChatResponse) StopReason() StopReason {
+	if r.resp == nil {
+		return StopReasonUnknown
+	}
+	switch r.resp.Status {
+	case responses.ResponseStatusCompleted:
+		for _, output := range r.resp.Output {
+			if _, ok := output.AsAny().(responses.ResponseFunctionToolCall); ok {
+				return StopReasonToolCalls
+			}
+		}
+		return StopReasonEnd
+	case responses.ResponseStatusIncomplete:
+		switch r.resp.IncompleteDetails.Reason {
+		case "max_output_tokens":
+			return StopReasonMaxTokens
+		case "content_filter":
+			return StopReasonContentFilter
+		}
+		return StopReasonOther
+	case "":
+		return StopReasonUnknown
+	}
+	return StopReasonOther
+}
+
+func (r *openAIResponseChatResponse) ModelVersion() string {
+	if r.resp == nil {
+		return ""
+	}
+	return string(r.resp.Model)
+}
+
 type openAIResponseCandidate struct {
 	candidate *responses.ResponseOutputItemUnion
 }
@@ -306,6 +389,19 @@ func (cs *openAIResponseChatSession) addContentsToHistory(contents []any) error
 			}
 			// cs.history = append(cs.history, openai.ToolMessage(string(resultJSON), c.ID))
 			cs.history = append(cs.history, responses.ResponseInputItemParamOfFunctionCallOutput(c.ID, string(resultJSON)))
+		case ImageContent:
+			image := responses.ResponseInputContentParamOfInputImage(responses.ResponseInputImageDetailAuto)
+			image.OfInputImage.ImageURL = openai.String(c.DataURL())
+			cs.history = append(cs.history, responses.ResponseInputItemParamOfMessage(
+				responses.ResponseInputMessageContentListParam{image},
+				responses.EasyInputMessageRoleUser,
+			))
+		case ProviderRawContent:
+			item, err := rawContentValue[responses.ResponseInputItemUnionParam](c, "openai")
+			if err != nil {
+				return err
+			}
+			cs.history = append(cs.history, item)
 		default:
 			klog.Warningf("Unhandled content type: %T", content)
 			return fmt.Errorf("unhandled content type: %T", content)
@@ -326,18 +422,21 @@ func convertResponseToolCallToFunctionCall(responseToolCall responses.ResponseFu
 	fc.Name = responseToolCall.Name
 	// Parse function arguments with error handling
 	var args map[string]any
+	var parseError string
 	if responseToolCall.Arguments != "" {
 		if err := json.Unmarshal([]byte(responseToolCall.Arguments), &args); err != nil {
 			klog.V(2).Infof("Error unmarshalling function arguments for %s: %v", fc.Name, err)
 			args = make(map[string]any)
+			parseError = err.Error()
 		}
 	} else {
 		args = make(map[string]any)
 	}
 
 	return FunctionCall{
-		ID:        responseToolCall.CallID,
-		Name:      responseToolCall.Name,
-		Arguments: args,
+		ID:         responseToolCall.CallID,
+		Name:       responseToolCall.Name,
+		Arguments:  args,
+		ParseError: parseError,
 	}, nil
 }
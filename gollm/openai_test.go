@@ -16,8 +16,10 @@ package gollm
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	"github.com/openai/openai-go"
 )
 
@@ -513,6 +515,9 @@ func TestConvertToolCallsToFunctionCalls(t *testing.T) {
 				if len(calls[0].Arguments) != 0 {
 					t.Errorf("expected empty arguments due to parse error, got %v", calls[0].Arguments)
 				}
+				if calls[0].ParseError == "" {
+					t.Errorf("expected the parse error to be reported")
+				}
 			},
 		},
 		{
@@ -604,3 +609,164 @@ func TestConvertToolCallsToFunctionCalls(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenAIChat_DropToolOutputs(t *testing.T) {
+	session := &openAIChatSession{history: []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("why is my pod failing?"),
+		openai.ToolMessage(`{"stdout":"pod list"}`, "call-1"),
+		openai.ToolMessage(`{"stdout":"pod logs"}`, "call-2"),
+		openai.ToolMessage(`{"stdout":"pod events"}`, "call-3"),
+	}}
+	// The wrappers of the chat forward the call
+	chat := NewRetryChat(NewValidatingChat(&classifyingChat{Chat: session}, 1), DefaultRetryConfig)
+
+	if dropped := DropToolOutputs(chat, 2); dropped != 2 {
+		t.Errorf("expected 2 outputs dropped, got %d", dropped)
+	}
+	for i, want := range []string{DroppedToolOutput, DroppedToolOutput, `{"stdout":"pod events"}`} {
+		msg := session.history[i+1].OfTool
+		if msg.Content.OfString.Value != want || msg.ToolCallID != fmt.Sprintf("call-%d", i+1) {
+			t.Errorf("unexpected tool message %d: %+v", i+1, msg)
+		}
+	}
+	if dropped := DropToolOutputs(chat, 5); dropped != 1 {
+		t.Errorf("expected the dropped outputs not to be counted again, got %d", dropped)
+	}
+	if dropped := DropToolOutputs(&mockChat{}, 0); dropped != 0 {
+		t.Errorf("expected chats without support to drop nothing, got %d", dropped)
+	}
+}
+
+func TestOpenAIChat_PruneToolOutputs(t *testing.T) {
+	session := &openAIChatSession{history: []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("why is my pod failing?"),
+		openai.ToolMessage(`{"stdout":"a very long pod list"}`, "call-1"),
+		openai.ToolMessage(`{"stdout":"ok"}`, "call-2"),
+		openai.ToolMessage(`{"stdout":"a very long pod log"}`, "call-3"),
+	}}
+	chat := NewRetryChat(NewValidatingChat(&classifyingChat{Chat: session}, 1), DefaultRetryConfig)
+
+	pruneLong := func(output string) (string, bool) {
+		if len(output) < 20 {
+			return "", false
+		}
+		return "pruned", true
+	}
+	if pruned := PruneToolOutputs(chat, 1, pruneLong); pruned != 1 {
+		t.Errorf("expected 1 output pruned, got %d", pruned)
+	}
+	for i, want := range []string{"pruned", `{"stdout":"ok"}`, `{"stdout":"a very long pod log"}`} {
+		msg := session.history[i+1].OfTool
+		if msg.Content.OfString.Value != want || msg.ToolCallID != fmt.Sprintf("call-%d", i+1) {
+			t.Errorf("unexpected tool message %d: %+v", i+1, msg)
+		}
+	}
+}
+
+func TestOpenAIChat_Initialize(t *testing.T) {
+	session := &openAIChatSession{history: []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You are kubectl-ai."),
+		openai.UserMessage("a message of the previous session"),
+	}}
+	err := session.Initialize([]*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "why is my pod failing?"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get pods"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: map[string]any{"stdout": "pod list"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.history) != 4 || session.history[0].OfSystem == nil || session.history[2].OfAssistant == nil {
+		t.Fatalf("unexpected history %+v", session.history)
+	}
+	if got := session.history[3].OfUser.Content.OfString.Value; got != `Tool result: {"stdout":"pod list"}` {
+		t.Errorf("unexpected tool result %q", got)
+	}
+
+	if err := session.Initialize(nil); err != nil || len(session.history) != 1 {
+		t.Errorf("expected only the system prompt to be kept, got %+v", session.history)
+	}
+}
+
+func TestOpenAIToolChoice(t *testing.T) {
+	tests := []struct {
+		choice ToolChoice
+		want   string
+	}{
+		{choice: ToolChoice{}, want: `{"messages":[],"model":"gpt-4o"}`},
+		{choice: ToolChoice{Mode: ToolChoiceAny}, want: `{"messages":[],"model":"gpt-4o","tool_choice":"required"}`},
+		{choice: ToolChoice{Mode: ToolChoiceNone}, want: `{"messages":[],"model":"gpt-4o","tool_choice":"none"}`},
+		{choice: ToolChoice{Mode: ToolChoiceTool, Tool: "kubectl"}, want: `{"messages":[],"model":"gpt-4o","tool_choice":{"function":{"name":"kubectl"},"type":"function"}}`},
+	}
+	for _, tt := range tests {
+		req := openai.ChatCompletionNewParams{
+			Model:      "gpt-4o",
+			Messages:   []openai.ChatCompletionMessageParamUnion{},
+			ToolChoice: openAIToolChoice(tt.choice),
+		}
+		b, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("tool choice %+v: got %s, want %s", tt.choice, b, tt.want)
+		}
+	}
+
+	chat := &openAIChatSession{}
+	if err := chat.SetToolChoice(ToolChoice{Mode: ToolChoiceTool}); err == nil {
+		t.Error("expected an error for a tool choice without a tool")
+	}
+	if err := chat.SetToolChoice(ToolChoice{Mode: "required"}); err == nil {
+		t.Error("expected an error for an unknown tool choice")
+	}
+}
+
+func TestOpenAIChatResponse_StopReason(t *testing.T) {
+	tests := []struct {
+		finishReason string
+		want         StopReason
+	}{
+		{finishReason: "", want: StopReasonUnknown},
+		{finishReason: "stop", want: StopReasonEnd},
+		{finishReason: "length", want: StopReasonMaxTokens},
+		{finishReason: "tool_calls", want: StopReasonToolCalls},
+		{finishReason: "content_filter", want: StopReasonContentFilter},
+		{finishReason: "eos", want: StopReasonOther},
+	}
+	for _, tt := range tests {
+		response := &openAIChatResponse{openaiCompletion: &openai.ChatCompletion{
+			Model:   "gpt-4o-2024-08-06",
+			Choices: []openai.ChatCompletionChoice{{FinishReason: tt.finishReason}},
+		}}
+		if got := ResponseStopReason(response); got != tt.want {
+			t.Errorf("finish reason %q: got %q, want %q", tt.finishReason, got, tt.want)
+		}
+		if got := ResponseModelVersion(response); got != "gpt-4o-2024-08-06" {
+			t.Errorf("got model version %q", got)
+		}
+	}
+	if got := ResponseStopReason(&openAIChatStreamResponse{}); got != StopReasonUnknown {
+		t.Errorf("expected no stop reason for a chunk without choices, got %q", got)
+	}
+}
+
+func TestOpenAIChat_ImageContent(t *testing.T) {
+	session := &openAIChatSession{}
+	if err := session.addContentsToHistory([]any{ImageContent{MIMEType: "image/png", Data: []byte("png")}}); err != nil {
+		t.Fatal(err)
+	}
+	parts := session.history[0].OfUser.Content.OfArrayOfContentParts
+	if len(parts) != 1 || parts[0].OfImageURL == nil || parts[0].OfImageURL.ImageURL.URL != "data:image/png;base64,cG5n" {
+		t.Errorf("expected the image as a data URL, got %+v", session.history[0])
+	}
+
+	responseSession := &openAIResponseChatSession{}
+	if err := responseSession.addContentsToHistory([]any{ImageContent{MIMEType: "image/png", Data: []byte("png")}}); err != nil {
+		t.Fatal(err)
+	}
+	content := responseSession.history[0].OfMessage.Content.OfInputItemContentList
+	if len(content) != 1 || content[0].OfInputImage == nil || content[0].OfInputImage.ImageURL.Value != "data:image/png;base64,cG5n" {
+		t.Errorf("expected the image as a data URL, got %+v", responseSession.history[0])
+	}
+}
@@ -15,7 +15,10 @@
 package gollm
 
 import (
+	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strings"
 
 	"k8s.io/klog/v2"
@@ -68,3 +71,119 @@ func BuildSchemaFor(t reflect.Type) *Schema {
 
 	return out
 }
+
+// ToolVerbosity is how much documentation of the tools is sent to the LLM.
+type ToolVerbosity string
+
+const (
+	// ToolVerbosityFull sends the function definitions as they are.
+	ToolVerbosityFull ToolVerbosity = "full"
+	// ToolVerbosityCompact documents the parameters shared by several
+	// functions once, the others referring to it.
+	ToolVerbosityCompact ToolVerbosity = "compact"
+	// ToolVerbosityMinimal keeps the first sentence of the descriptions of
+	// the functions, and no description of their parameters.
+	ToolVerbosityMinimal ToolVerbosity = "minimal"
+)
+
+// ParseToolVerbosity parses a ToolVerbosity, "" being ToolVerbosityFull.
+func ParseToolVerbosity(s string) (ToolVerbosity, error) {
+	switch v := ToolVerbosity(s); v {
+	case "":
+		return ToolVerbosityFull, nil
+	case ToolVerbosityFull, ToolVerbosityCompact, ToolVerbosityMinimal:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid tool verbosity %q, expected full, compact or minimal", s)
+}
+
+// MinimizeFunctionDefinitions returns copies of definitions stripped of the
+// documentation verbosity leaves out, to save the tokens they take in every
+// request. The definitions are left unchanged.
+func MinimizeFunctionDefinitions(definitions []*FunctionDefinition, verbosity ToolVerbosity) []*FunctionDefinition {
+	if verbosity == ToolVerbosityFull || verbosity == "" {
+		return definitions
+	}
+
+	// documentedBy is the first function documenting each parameter
+	// description, others refer to it
+	documentedBy := map[string]string{}
+	minimized := make([]*FunctionDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		m := &FunctionDefinition{
+			Name:        definition.Name,
+			Description: definition.Description,
+			Parameters:  definition.Parameters.clone(),
+		}
+		switch verbosity {
+		case ToolVerbosityMinimal:
+			m.Description = firstSentence(m.Description)
+			m.Parameters.stripDescriptions()
+		case ToolVerbosityCompact:
+			if m.Parameters != nil {
+				// Iterate in a stable order so that the definitions, and
+				// the caches of the providers, don't change between requests
+				for _, name := range slices.Sorted(maps.Keys(m.Parameters.Properties)) {
+					property := m.Parameters.Properties[name]
+					if len(property.Description) < minSharedDescriptionLength {
+						continue
+					}
+					key := name + "\x00" + property.Description
+					if function, ok := documentedBy[key]; ok {
+						property.Description = fmt.Sprintf("Same as %s of %s.", name, function)
+					} else {
+						documentedBy[key] = definition.Name
+					}
+				}
+			}
+		}
+		minimized = append(minimized, m)
+	}
+	return minimized
+}
+
+// minSharedDescriptionLength is the length below which shared parameter
+// descriptions are repeated, referring to another function would not be
+// shorter.
+const minSharedDescriptionLength = 40
+
+// clone returns a deep copy of s.
+func (s *Schema) clone() *Schema {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Items = s.Items.clone()
+	c.Required = slices.Clone(s.Required)
+	if s.Properties != nil {
+		c.Properties = make(map[string]*Schema, len(s.Properties))
+		for name, property := range s.Properties {
+			c.Properties[name] = property.clone()
+		}
+	}
+	return &c
+}
+
+// stripDescriptions removes the descriptions of s and its nested schemas.
+func (s *Schema) stripDescriptions() {
+	if s == nil {
+		return
+	}
+	s.Description = ""
+	s.Items.stripDescriptions()
+	for _, property := range s.Properties {
+		property.stripDescriptions()
+	}
+}
+
+// firstSentence returns the first sentence or line of s.
+func firstSentence(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	if i := strings.Index(s, ". "); i >= 0 {
+		s = s[:i+1]
+	}
+	return s
+}
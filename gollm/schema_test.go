@@ -0,0 +1,72 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import "testing"
+
+func TestMinimizeFunctionDefinitions(t *testing.T) {
+	const modifies = "Whether the command modifies a kubernetes resource: yes, no or unknown."
+	newDefinitions := func() []*FunctionDefinition {
+		return []*FunctionDefinition{
+			{
+				Name:        "bash",
+				Description: "Executes a bash command. Use it for pipes and loops.",
+				Parameters: &Schema{Type: TypeObject, Properties: map[string]*Schema{
+					"command":           {Type: TypeString, Description: "The bash command to execute."},
+					"modifies_resource": {Type: TypeString, Description: modifies},
+				}},
+			},
+			{
+				Name:        "kubectl",
+				Description: "Executes a kubectl command.\nPrefer it to bash.",
+				Parameters: &Schema{Type: TypeObject, Properties: map[string]*Schema{
+					"command":           {Type: TypeString, Description: "The kubectl command to execute."},
+					"modifies_resource": {Type: TypeString, Description: modifies},
+				}},
+			},
+		}
+	}
+
+	definitions := newDefinitions()
+	compact := MinimizeFunctionDefinitions(definitions, ToolVerbosityCompact)
+	if got := compact[0].Parameters.Properties["modifies_resource"].Description; got != modifies {
+		t.Errorf("expected the first function to document the shared parameter, got %q", got)
+	}
+	if got := compact[1].Parameters.Properties["modifies_resource"].Description; got != "Same as modifies_resource of bash." {
+		t.Errorf("expected a reference to the shared parameter, got %q", got)
+	}
+	if got := compact[1].Parameters.Properties["command"].Description; got != "The kubectl command to execute." {
+		t.Errorf("expected distinct parameters to be kept, got %q", got)
+	}
+
+	minimal := MinimizeFunctionDefinitions(definitions, ToolVerbosityMinimal)
+	if minimal[0].Description != "Executes a bash command." || minimal[1].Description != "Executes a kubectl command." {
+		t.Errorf("expected the first sentence of the descriptions, got %q and %q", minimal[0].Description, minimal[1].Description)
+	}
+	if got := minimal[0].Parameters.Properties["command"].Description; got != "" {
+		t.Errorf("expected no parameter description, got %q", got)
+	}
+
+	// The definitions of the tools are left unchanged
+	want := newDefinitions()
+	for i := range definitions {
+		if definitions[i].Description != want[i].Description || definitions[i].Parameters.Properties["modifies_resource"].Description != modifies {
+			t.Errorf("expected definition %s to be unchanged, got %+v", definitions[i].Name, definitions[i])
+		}
+	}
+	if full := MinimizeFunctionDefinitions(definitions, ToolVerbosityFull); &full[0] != &definitions[0] {
+		t.Error("expected the full definitions to be returned as is")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+)
+
+// The readers of this file parse the streams of the providers calling their
+// HTTP APIs directly, as JSON lines. Unlike bufio.Scanner, they read lines of
+// any size up to a configurable limit, and fail with ErrStreamLineTooLong
+// beyond it instead of stopping silently. The streams
+// the provider SDKs parse themselves are checked against the same limit by
+// streamLineTransport, so that they fail with the same clear error rather
+// than the limit of the scanner of the SDK.
+
+// DefaultMaxStreamLineBytes is the default maximum size of a line of a
+// stream, large enough for big tool call arguments.
+const DefaultMaxStreamLineBytes = 16 << 20
+
+// ErrStreamLineTooLong is returned when a line of a stream is larger than the
+// maximum size of the reader.
+var ErrStreamLineTooLong = errors.New("stream line too long")
+
+// lineReader reads the lines of a stream, whatever the chunks it arrives in.
+type lineReader struct {
+	r        *bufio.Reader
+	maxBytes int
+	line     []byte
+}
+
+func newLineReader(r io.Reader, maxBytes int) *lineReader {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxStreamLineBytes
+	}
+	return &lineReader{r: bufio.NewReader(r), maxBytes: maxBytes}
+}
+
+// next returns the next line without its line ending, or io.EOF at the end of
+// the stream. A last line without line ending is returned too. The line is
+// only valid until the next call.
+func (l *lineReader) next() ([]byte, error) {
+	l.line = l.line[:0]
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		l.line = append(l.line, chunk...)
+		if len(bytes.TrimRight(l.line, "\r\n")) > l.maxBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrStreamLineTooLong, l.maxBytes)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF:
+			if len(l.line) == 0 {
+				return nil, io.EOF
+			}
+		case err != nil:
+			return nil, err
+		}
+		return bytes.TrimRight(l.line, "\r\n"), nil
+	}
+}
+
+// jsonlReader reads a stream of JSON values, one per line.
+type jsonlReader struct {
+	lines *lineReader
+}
+
+// newJSONLReader reads the JSON lines of r, failing on lines larger than
+// maxBytes, DefaultMaxStreamLineBytes if zero.
+func newJSONLReader(r io.Reader, maxBytes int) *jsonlReader {
+	return &jsonlReader{lines: newLineReader(r, maxBytes)}
+}
+
+// next decodes the next line into v, skipping blank lines, or returns io.EOF
+// at the end of the stream.
+func (j *jsonlReader) next(v any) error {
+	for {
+		line, err := j.lines.next()
+		if err != nil {
+			return err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(line, v); err != nil {
+			return fmt.Errorf("decoding stream line: %w", err)
+		}
+		return nil
+	}
+}
+
+// streamingContentTypes are the content types of the streamed responses
+// checked by streamLineTransport.
+var streamingContentTypes = []string{"text/event-stream", "application/x-ndjson", "application/jsonl"}
+
+// streamLineTransport fails the reads of the streamed responses of base when
+// a line is larger than maxBytes.
+type streamLineTransport struct {
+	base     http.RoundTripper
+	maxBytes int
+	// allResponses limits the lines of all the responses, for SDKs that read
+	// every response line by line.
+	allResponses bool
+}
+
+func (t *streamLineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if t.allResponses || slices.Contains(streamingContentTypes, contentType) {
+		maxBytes := t.maxBytes
+		if maxBytes <= 0 {
+			maxBytes = DefaultMaxStreamLineBytes
+		}
+		report, _ := req.Context().Value(streamLineErrorKey{}).(*error)
+		resp.Body = &lineLimitedBody{ReadCloser: resp.Body, maxBytes: maxBytes, report: report}
+	}
+	return resp, nil
+}
+
+type streamLineErrorKey struct{}
+
+// withStreamLineError returns a context whose requests record the
+// ErrStreamLineTooLong of their responses, and a function returning it, for
+// SDKs that stop reading a response on a read error without returning it.
+func withStreamLineError(ctx context.Context) (context.Context, func() error) {
+	var err error
+	return context.WithValue(ctx, streamLineErrorKey{}, &err), func() error { return err }
+}
+
+// lineLimitedBody fails with ErrStreamLineTooLong once a line of its
+// content is larger than maxBytes.
+type lineLimitedBody struct {
+	io.ReadCloser
+	maxBytes int
+	// lineBytes is the size of the line read so far.
+	lineBytes int
+	err       error
+	// report, if set, records err.
+	report *error
+}
+
+func (b *lineLimitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	for chunk := p[:n]; len(chunk) > 0; {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			b.lineBytes += len(chunk)
+			break
+		}
+		b.lineBytes += i
+		if b.lineBytes > b.maxBytes {
+			break
+		}
+		b.lineBytes = 0
+		chunk = chunk[i+1:]
+	}
+	if b.lineBytes > b.maxBytes {
+		b.err = fmt.Errorf("%w: a line of the response is larger than %d bytes", ErrStreamLineTooLong, b.maxBytes)
+		if b.report != nil {
+			*b.report = b.err
+		}
+		return 0, b.err
+	}
+	return n, err
+}
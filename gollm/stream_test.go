@@ -0,0 +1,152 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// chunkedReader returns the content of s in chunks of size bytes, like a
+// network stream.
+type chunkedReader struct {
+	s    string
+	size int
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if r.s == "" {
+		return 0, io.EOF
+	}
+	n := copy(p, r.s[:min(r.size, len(r.s))])
+	r.s = r.s[n:]
+	return n, nil
+}
+
+func TestJSONLReader(t *testing.T) {
+	stream := "{\"n\":1}\n\n  {\"n\":2}\r\n{\"n\":3}"
+	reader := newJSONLReader(iotest.OneByteReader(strings.NewReader(stream)), 0)
+	var got []int
+	for {
+		var v struct{ N int }
+		err := reader.next(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.N)
+	}
+	if !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("got %v, want [1 2 3]", got)
+	}
+
+	var v any
+	if err := newJSONLReader(strings.NewReader("{\"n\":\n"), 0).next(&v); err == nil {
+		t.Errorf("expected an error for a truncated line")
+	}
+}
+
+func TestStreamLineTooLong(t *testing.T) {
+	line := `"` + strings.Repeat("x", 10000) + "\"\r\n"
+	var v string
+	if err := newJSONLReader(strings.NewReader(line), 10002).next(&v); err != nil {
+		t.Errorf("line at the limit: %v", err)
+	}
+	err := newJSONLReader(&chunkedReader{s: line, size: 7}, 10001).next(&v)
+	if !errors.Is(err, ErrStreamLineTooLong) {
+		t.Errorf("err = %v, want ErrStreamLineTooLong", err)
+	}
+}
+
+func TestStreamLineTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		for range 3 {
+			fmt.Fprintf(w, "data: %s\n\n", strings.Repeat("x", 200))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	client, err := createCustomHTTPClient(ClientOptions{MaxStreamLineBytes: 206})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(contentType string) error {
+		resp, err := client.Get(server.URL + "?type=" + contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		return err
+	}
+	if err := read("application/json"); err != nil {
+		t.Errorf("a response that isn't streamed was limited: %v", err)
+	}
+	if err := read("text/event-stream;charset=utf-8"); err != nil {
+		t.Errorf("lines at the limit: %v", err)
+	}
+
+	client, err = createCustomHTTPClient(ClientOptions{MaxStreamLineBytes: 205})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := read("text/event-stream"); !errors.Is(err, ErrStreamLineTooLong) {
+		t.Errorf("err = %v, want ErrStreamLineTooLong", err)
+	}
+}
+
+func FuzzJSONLReader(f *testing.F) {
+	f.Add("{\"a\":1}\n[2]\n\"3\"\n", 2)
+	f.Add("\r\n\r\n{}", 1)
+	f.Fuzz(func(t *testing.T, stream string, size int) {
+		if size <= 0 {
+			size = 1
+		}
+		read := func(r io.Reader) ([]any, error) {
+			reader := newJSONLReader(r, 1024)
+			var values []any
+			for {
+				var v any
+				err := reader.next(&v)
+				if err == io.EOF {
+					return values, nil
+				}
+				if err != nil {
+					return values, err
+				}
+				values = append(values, v)
+			}
+		}
+		whole, errWhole := read(strings.NewReader(stream))
+		chunked, errChunked := read(&chunkedReader{s: stream, size: size})
+		if (errWhole == nil) != (errChunked == nil) {
+			t.Fatalf("errors differ: %v and %v", errWhole, errChunked)
+		}
+		if !reflect.DeepEqual(whole, chunked) {
+			t.Errorf("values read in chunks of %d bytes differ: %v and %v", size, whole, chunked)
+		}
+	})
+}
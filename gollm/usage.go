@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/ai/azopenai"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// Usage is a provider-agnostic view of the token usage of a single LLM request.
+type Usage struct {
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+	TotalTokens  int64 `json:"totalTokens"`
+}
+
+// NormalizeUsage converts the value returned by ChatResponse.UsageMetadata
+// into a Usage. It returns false if the metadata is missing or of an unknown type.
+func NormalizeUsage(metadata any) (*Usage, bool) {
+	var u Usage
+	switch m := metadata.(type) {
+	case *Usage:
+		if m == nil {
+			return nil, false
+		}
+		u = *m
+	case Usage:
+		u = m
+	case *genai.GenerateContentResponseUsageMetadata:
+		if m == nil {
+			return nil, false
+		}
+		u.InputTokens = int64(m.PromptTokenCount)
+		u.OutputTokens = int64(m.CandidatesTokenCount) + int64(m.ThoughtsTokenCount)
+		u.TotalTokens = int64(m.TotalTokenCount)
+	case openai.CompletionUsage:
+		u.InputTokens = m.PromptTokens
+		u.OutputTokens = m.CompletionTokens
+		u.TotalTokens = m.TotalTokens
+	case *types.TokenUsage:
+		if m == nil {
+			return nil, false
+		}
+		u.InputTokens = int64(derefInt32(m.InputTokens))
+		u.OutputTokens = int64(derefInt32(m.OutputTokens))
+		u.TotalTokens = int64(derefInt32(m.TotalTokens))
+	case *azopenai.CompletionsUsage:
+		if m == nil {
+			return nil, false
+		}
+		u.InputTokens = int64(derefInt32(m.PromptTokens))
+		u.OutputTokens = int64(derefInt32(m.CompletionTokens))
+		u.TotalTokens = int64(derefInt32(m.TotalTokens))
+	default:
+		return nil, false
+	}
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.InputTokens + u.OutputTokens
+	}
+	return &u, true
+}
+
+// Add accumulates other into u.
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+func derefInt32(v *int32) int32 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
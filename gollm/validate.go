@@ -0,0 +1,227 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// ResponseValidator checks a response from the LLM before it is returned to
+// the caller. Returning an error rejects the response, and the LLM is asked
+// to regenerate it, with the error as the reason.
+type ResponseValidator func(ctx context.Context, response *ValidatedResponse) error
+
+// ValidatedResponse is the content of a response being validated. For
+// streaming responses, it is the content of the whole stream, whose text has
+// already reached the caller, see NewValidatingChat.
+type ValidatedResponse struct {
+	Text          string
+	FunctionCalls []FunctionCall
+}
+
+// ErrResponseRejected is returned (wrapped) when the LLM keeps sending
+// responses that don't pass validation.
+var ErrResponseRejected = errors.New("response rejected")
+
+// ValidateFunctionCallArguments rejects responses with function calls whose
+// arguments are not valid JSON, typically because they were truncated.
+func ValidateFunctionCallArguments(ctx context.Context, response *ValidatedResponse) error {
+	for _, call := range response.FunctionCalls {
+		if call.ParseError != "" {
+			return fmt.Errorf("the arguments of the call to %q are not valid JSON: %s", call.Name, call.ParseError)
+		}
+	}
+	return nil
+}
+
+// validatingChat is a decorator that runs validators on every response, and
+// asks the LLM to regenerate the responses they reject.
+type validatingChat struct {
+	underlying       Chat
+	validators       []ResponseValidator
+	maxRegenerations int
+}
+
+// NewValidatingChat creates a Chat that runs validators on every response of
+// underlying, asking the LLM up to maxRegenerations times to regenerate
+// rejected responses. The text of streaming responses is passed on as it
+// arrives; only the chunks with function calls are held back until the stream
+// ends and the response is validated, so that rejected calls never reach the
+// caller.
+func NewValidatingChat(underlying Chat, maxRegenerations int, validators ...ResponseValidator) Chat {
+	return &validatingChat{
+		underlying:       underlying,
+		validators:       validators,
+		maxRegenerations: maxRegenerations,
+	}
+}
+
+func (vc *validatingChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	for attempt := 0; ; attempt++ {
+		response, err := vc.underlying.Send(ctx, contents...)
+		if err != nil {
+			return nil, err
+		}
+		validated := &ValidatedResponse{}
+		if candidates := response.Candidates(); len(candidates) > 0 {
+			validated.add(candidates[0])
+		}
+		err = vc.validate(ctx, validated)
+		if err == nil {
+			return response, nil
+		}
+		if attempt >= vc.maxRegenerations {
+			return nil, fmt.Errorf("%w after %d attempts: %w", ErrResponseRejected, attempt+1, err)
+		}
+		klog.FromContext(ctx).Info("Asking the LLM to regenerate a rejected response", "attempt", attempt+1, "reason", err)
+		contents = regenerationRequest(validated, err)
+	}
+}
+
+func (vc *validatingChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	stream, err := vc.underlying.SendStreaming(ctx, contents...)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(ChatResponse, error) bool) {
+		for attempt := 0; ; attempt++ {
+			// Chunks with function calls are held back until the response is validated
+			var held []ChatResponse
+			validated := &ValidatedResponse{}
+			for response, err := range stream {
+				if err != nil {
+					for _, response := range held {
+						if !yield(response, nil) {
+							return
+						}
+					}
+					yield(nil, err)
+					return
+				}
+				if response == nil {
+					continue
+				}
+				candidates := response.Candidates()
+				if len(candidates) == 0 {
+					if !yield(response, nil) {
+						return
+					}
+					continue
+				}
+				calls := len(validated.FunctionCalls)
+				validated.add(candidates[0])
+				if len(validated.FunctionCalls) > calls || len(held) > 0 {
+					held = append(held, response)
+				} else if !yield(response, nil) {
+					return
+				}
+			}
+
+			err := vc.validate(ctx, validated)
+			if err == nil {
+				for _, response := range held {
+					if !yield(response, nil) {
+						return
+					}
+				}
+				return
+			}
+			if attempt >= vc.maxRegenerations {
+				yield(nil, fmt.Errorf("%w after %d attempts: %w", ErrResponseRejected, attempt+1, err))
+				return
+			}
+			klog.FromContext(ctx).Info("Asking the LLM to regenerate a rejected response", "attempt", attempt+1, "reason", err)
+			stream, err = vc.underlying.SendStreaming(ctx, regenerationRequest(validated, err)...)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}, nil
+}
+
+func (vc *validatingChat) SetFunctionDefinitions(functionDefinitions []*FunctionDefinition) error {
+	return vc.underlying.SetFunctionDefinitions(functionDefinitions)
+}
+
+func (vc *validatingChat) SetToolChoice(choice ToolChoice) error {
+	return vc.underlying.SetToolChoice(choice)
+}
+
+func (vc *validatingChat) SetStopSequences(stopSequences []string) error {
+	return vc.underlying.SetStopSequences(stopSequences)
+}
+
+func (vc *validatingChat) IsRetryableError(err error) bool {
+	return vc.underlying.IsRetryableError(err)
+}
+
+func (vc *validatingChat) Initialize(messages []*api.Message) error {
+	return vc.underlying.Initialize(messages)
+}
+
+func (vc *validatingChat) DropToolOutputs(count int) int {
+	return DropToolOutputs(vc.underlying, count)
+}
+
+func (vc *validatingChat) PruneToolOutputs(keep int, prune func(output string) (string, bool)) int {
+	return PruneToolOutputs(vc.underlying, keep, prune)
+}
+
+// validate runs the validators in order, returning the first rejection.
+func (vc *validatingChat) validate(ctx context.Context, response *ValidatedResponse) error {
+	for _, validator := range vc.validators {
+		if err := validator(ctx, response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add appends the content of candidate to the response.
+func (r *ValidatedResponse) add(candidate Candidate) {
+	for _, part := range candidate.Parts() {
+		if text, ok := part.AsText(); ok {
+			r.Text += text
+		}
+		if calls, ok := part.AsFunctionCalls(); ok {
+			r.FunctionCalls = append(r.FunctionCalls, calls...)
+		}
+	}
+}
+
+// regenerationRequest builds the message asking the LLM to regenerate a
+// rejected response. The function calls of the rejected response are answered
+// first, as providers require a result for every call.
+func regenerationRequest(rejected *ValidatedResponse, reason error) []any {
+	message := fmt.Sprintf("Your previous response was rejected: %v. Send a corrected response.", reason)
+	var contents []any
+	for _, call := range rejected.FunctionCalls {
+		contents = append(contents, FunctionCallResult{
+			ID:   call.ID,
+			Name: call.Name,
+			Result: map[string]any{
+				"error":  "Not run, the response was rejected: " + reason.Error(),
+				"status": "rejected",
+			},
+		})
+	}
+	return append(contents, message)
+}
@@ -0,0 +1,203 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gollm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// scriptedChat returns its responses in order, recording what it was sent.
+type scriptedChat struct {
+	responses []*fakeResponse
+	sent      [][]any
+}
+
+func (c *scriptedChat) next(contents []any) *fakeResponse {
+	c.sent = append(c.sent, contents)
+	response := c.responses[0]
+	c.responses = c.responses[1:]
+	return response
+}
+
+func (c *scriptedChat) Send(ctx context.Context, contents ...any) (ChatResponse, error) {
+	return c.next(contents), nil
+}
+
+func (c *scriptedChat) SendStreaming(ctx context.Context, contents ...any) (ChatResponseIterator, error) {
+	response := c.next(contents)
+	return func(yield func(ChatResponse, error) bool) {
+		// Stream each part as a separate chunk
+		for _, part := range response.parts {
+			if !yield(&fakeResponse{parts: []Part{part}}, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (c *scriptedChat) SetFunctionDefinitions([]*FunctionDefinition) error { return nil }
+func (c *scriptedChat) SetToolChoice(ToolChoice) error                     { return nil }
+func (c *scriptedChat) SetStopSequences([]string) error                    { return nil }
+func (c *scriptedChat) IsRetryableError(error) bool                        { return false }
+func (c *scriptedChat) Initialize([]*api.Message) error                    { return nil }
+
+type fakeResponse struct {
+	parts []Part
+}
+
+func (r *fakeResponse) UsageMetadata() any      { return nil }
+func (r *fakeResponse) Candidates() []Candidate { return []Candidate{r} }
+func (r *fakeResponse) String() string {
+	var text string
+	for _, part := range r.parts {
+		s, _ := part.AsText()
+		text += s
+	}
+	return text
+}
+func (r *fakeResponse) Parts() []Part { return r.parts }
+
+type fakePart struct {
+	text  string
+	calls []FunctionCall
+}
+
+func (p *fakePart) AsText() (string, bool)                  { return p.text, p.calls == nil }
+func (p *fakePart) AsFunctionCalls() ([]FunctionCall, bool) { return p.calls, p.calls != nil }
+
+func textResponse(texts ...string) *fakeResponse {
+	r := &fakeResponse{}
+	for _, text := range texts {
+		r.parts = append(r.parts, &fakePart{text: text})
+	}
+	return r
+}
+
+func rejectText(forbidden string) ResponseValidator {
+	return func(ctx context.Context, response *ValidatedResponse) error {
+		if strings.Contains(response.Text, forbidden) {
+			return fmt.Errorf("response contains %q", forbidden)
+		}
+		return nil
+	}
+}
+
+func TestValidatingChat_Send(t *testing.T) {
+	underlying := &scriptedChat{responses: []*fakeResponse{
+		textResponse("kubectl delete ns prod"),
+		textResponse("kubectl get ns"),
+	}}
+	chat := NewValidatingChat(underlying, 2, rejectText("delete"))
+
+	response, err := chat.Send(context.Background(), "clean up")
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if got := response.Candidates()[0].String(); got != "kubectl get ns" {
+		t.Errorf("expected the regenerated response, got %s", got)
+	}
+	if len(underlying.sent) != 2 || !strings.Contains(fmt.Sprint(underlying.sent[1]), "rejected") {
+		t.Errorf("expected a regeneration request to be sent, got %v", underlying.sent)
+	}
+}
+
+func TestValidatingChat_SendStreaming(t *testing.T) {
+	deleteCall := FunctionCall{ID: "1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl delete ns prod"}}
+	getCall := FunctionCall{ID: "2", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get ns"}}
+	underlying := &scriptedChat{responses: []*fakeResponse{
+		{parts: []Part{&fakePart{text: "Cleaning "}, &fakePart{text: "up. "}, &fakePart{calls: []FunctionCall{deleteCall}}}},
+		{parts: []Part{&fakePart{text: "Listing first. "}, &fakePart{calls: []FunctionCall{getCall}}}},
+	}}
+	rejectDelete := func(ctx context.Context, response *ValidatedResponse) error {
+		for _, call := range response.FunctionCalls {
+			if strings.Contains(fmt.Sprint(call.Arguments["command"]), "delete") {
+				return fmt.Errorf("deleting is not allowed")
+			}
+		}
+		return nil
+	}
+	chat := NewValidatingChat(underlying, 2, rejectDelete)
+
+	stream, err := chat.SendStreaming(context.Background(), "clean up")
+	if err != nil {
+		t.Fatalf("SendStreaming returned error: %v", err)
+	}
+	var text string
+	var calls []FunctionCall
+	for response, err := range stream {
+		if err != nil {
+			t.Fatalf("stream returned error: %v", err)
+		}
+		part := response.Candidates()[0].Parts()[0]
+		if c, ok := part.AsFunctionCalls(); ok {
+			calls = append(calls, c...)
+		} else {
+			s, _ := part.AsText()
+			text += s
+		}
+	}
+	// The text is streamed as it arrives, the rejected call is held back
+	if text != "Cleaning up. Listing first. " {
+		t.Errorf("expected the text of both responses to be streamed, got %q", text)
+	}
+	if len(calls) != 1 || calls[0].ID != "2" {
+		t.Errorf("expected only the regenerated call to be streamed, got %+v", calls)
+	}
+}
+
+func TestValidatingChat_SendStreaming_GivesUp(t *testing.T) {
+	call := FunctionCall{ID: "1", Name: "kubectl", ParseError: "unexpected end of JSON input"}
+	underlying := &scriptedChat{responses: []*fakeResponse{
+		{parts: []Part{&fakePart{calls: []FunctionCall{call}}}},
+	}}
+	chat := NewValidatingChat(underlying, 0, ValidateFunctionCallArguments)
+
+	stream, err := chat.SendStreaming(context.Background(), "list pods")
+	if err != nil {
+		t.Fatalf("SendStreaming returned error: %v", err)
+	}
+	for response, err := range stream {
+		if response != nil {
+			t.Errorf("expected the rejected call not to be streamed, got %v", response)
+		}
+		if !errors.Is(err, ErrResponseRejected) {
+			t.Errorf("expected ErrResponseRejected, got %v", err)
+		}
+	}
+}
+
+func TestValidatingChat_GivesUp(t *testing.T) {
+	call := FunctionCall{ID: "1", Name: "kubectl", ParseError: "unexpected end of JSON input"}
+	underlying := &scriptedChat{responses: []*fakeResponse{
+		{parts: []Part{&fakePart{calls: []FunctionCall{call}}}},
+		{parts: []Part{&fakePart{calls: []FunctionCall{call}}}},
+	}}
+	chat := NewValidatingChat(underlying, 1, ValidateFunctionCallArguments)
+
+	_, err := chat.Send(context.Background(), "list pods")
+	if !errors.Is(err, ErrResponseRejected) {
+		t.Fatalf("expected ErrResponseRejected, got %v", err)
+	}
+	// The rejected call must be answered before asking for a new response
+	if result, ok := underlying.sent[1][0].(FunctionCallResult); !ok || result.ID != "1" {
+		t.Errorf("expected the rejected call to be answered, got %v", underlying.sent[1])
+	}
+}
@@ -71,6 +71,21 @@ func (mr *MockClientMockRecorder) GenerateCompletion(ctx, req any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateCompletion", reflect.TypeOf((*MockClient)(nil).GenerateCompletion), ctx, req)
 }
 
+// GenerateCompletionStreaming mocks base method.
+func (m *MockClient) GenerateCompletionStreaming(ctx context.Context, req *gollm.CompletionRequest) (gollm.CompletionResponseIterator, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateCompletionStreaming", ctx, req)
+	ret0, _ := ret[0].(gollm.CompletionResponseIterator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateCompletionStreaming indicates an expected call of GenerateCompletionStreaming.
+func (mr *MockClientMockRecorder) GenerateCompletionStreaming(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateCompletionStreaming", reflect.TypeOf((*MockClient)(nil).GenerateCompletionStreaming), ctx, req)
+}
+
 // ListModels mocks base method.
 func (m *MockClient) ListModels(ctx context.Context) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -219,3 +234,31 @@ func (mr *MockChatMockRecorder) SetFunctionDefinitions(functionDefinitions any)
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFunctionDefinitions", reflect.TypeOf((*MockChat)(nil).SetFunctionDefinitions), functionDefinitions)
 }
+
+// SetStopSequences mocks base method.
+func (m *MockChat) SetStopSequences(stopSequences []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStopSequences", stopSequences)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStopSequences indicates an expected call of SetStopSequences.
+func (mr *MockChatMockRecorder) SetStopSequences(stopSequences any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStopSequences", reflect.TypeOf((*MockChat)(nil).SetStopSequences), stopSequences)
+}
+
+// SetToolChoice mocks base method.
+func (m *MockChat) SetToolChoice(choice gollm.ToolChoice) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetToolChoice", choice)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetToolChoice indicates an expected call of SetToolChoice.
+func (mr *MockChatMockRecorder) SetToolChoice(choice any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetToolChoice", reflect.TypeOf((*MockChat)(nil).SetToolChoice), choice)
+}
@@ -0,0 +1,157 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+const (
+	// maxAttachmentBytes is the largest file that can be attached.
+	maxAttachmentBytes = 1024 * 1024
+	// attachmentChunkBytes is the size of the parts large attachments are split into.
+	attachmentChunkBytes = 32 * 1024
+)
+
+// parseAttachQuery parses queries of the form "attach <path>". The path must
+// contain a dot or a slash, so that questions such as "attach the volume to
+// web-0" or "attach pvc" are sent to the LLM as they are.
+func parseAttachQuery(query string) (path string, ok bool) {
+	fields := strings.Fields(query)
+	if len(fields) != 2 || fields[0] != "attach" || !strings.ContainsAny(fields[1], "./") {
+		return "", false
+	}
+	return fields[1], true
+}
+
+// loadAttachment reads the file at path.
+func loadAttachment(path string) (api.Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return api.Attachment{}, fmt.Errorf("reading attachment: %w", err)
+	}
+	if info.IsDir() {
+		return api.Attachment{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxAttachmentBytes {
+		return api.Attachment{}, fmt.Errorf("%s is too large (%d bytes), attachments can be at most %d bytes", path, info.Size(), maxAttachmentBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return api.Attachment{}, fmt.Errorf("reading attachment: %w", err)
+	}
+	source := path
+	if abs, err := filepath.Abs(path); err == nil {
+		source = abs
+	}
+	return api.Attachment{
+		Name:    filepath.Base(path),
+		Source:  source,
+		Size:    len(data),
+		Content: string(data),
+	}, nil
+}
+
+// validateAttachment checks that attachment is text and not too large to send.
+func validateAttachment(attachment api.Attachment) error {
+	if len(attachment.Content) > maxAttachmentBytes {
+		return fmt.Errorf("%s is too large (%d bytes), attachments can be at most %d bytes", attachment.Name, len(attachment.Content), maxAttachmentBytes)
+	}
+	if !utf8.ValidString(attachment.Content) || strings.ContainsRune(attachment.Content, 0) {
+		return fmt.Errorf("%s is not a text file", attachment.Name)
+	}
+	return nil
+}
+
+// chunkAttachment splits the content of attachment into parts of at most
+// attachmentChunkBytes, breaking at line boundaries where possible. Each part
+// starts with where it came from, so the LLM can tell the parts apart and
+// refer back to them.
+func chunkAttachment(attachment api.Attachment) []string {
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+	}
+	for line := range strings.SplitAfterSeq(attachment.Content, "\n") {
+		if current.Len()+len(line) > attachmentChunkBytes {
+			flush()
+		}
+		for len(line) > attachmentChunkBytes {
+			chunks = append(chunks, line[:attachmentChunkBytes])
+			line = line[attachmentChunkBytes:]
+		}
+		current.WriteString(line)
+	}
+	flush()
+	if len(chunks) == 0 {
+		chunks = []string{""}
+	}
+
+	source := attachment.Source
+	if source == "" {
+		source = attachment.Name
+	}
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = fmt.Sprintf("Attached file %q (source: %s, part %d of %d):\n```\n%s\n```", attachment.Name, source, i+1, len(chunks), strings.TrimRight(chunk, "\n"))
+	}
+	return parts
+}
+
+// attachFiles records the attachments in the session, without their content,
+// and queues their content to be sent with the next query.
+func (c *Agent) attachFiles(ctx context.Context, attachments []api.Attachment) error {
+	for _, attachment := range attachments {
+		if err := validateAttachment(attachment); err != nil {
+			return err
+		}
+	}
+	// Filter all the parts first, so that nothing is attached if one fails
+	parts := make([][]string, len(attachments))
+	for i, attachment := range attachments {
+		for _, part := range chunkAttachment(attachment) {
+			filtered, err := c.filterContent(ctx, tools.ContentKindAttachment, part)
+			if err != nil {
+				return fmt.Errorf("the attachment %q was not sent: %w", attachment.Name, err)
+			}
+			parts[i] = append(parts[i], filtered)
+		}
+	}
+	for i, attachment := range attachments {
+		for _, part := range parts[i] {
+			c.pendingAttachments = append(c.pendingAttachments, part)
+		}
+		c.addMessage(api.MessageSourceUser, api.MessageTypeAttachment, api.Attachment{
+			Name:   attachment.Name,
+			Source: attachment.Source,
+			Size:   attachment.Size,
+			Chunks: len(parts[i]),
+		})
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "The attached files will be included with your next question.")
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestParseAttachQuery(t *testing.T) {
+	if path, ok := parseAttachQuery("attach  ./deploy.yaml "); !ok || path != "./deploy.yaml" {
+		t.Errorf("parseAttachQuery() = (%q, %v), want (./deploy.yaml, true)", path, ok)
+	}
+	for _, query := range []string{"attachments of the pod", "attach the volume to web-0", "attach pvc"} {
+		if _, ok := parseAttachQuery(query); ok {
+			t.Errorf("parseAttachQuery(%q) matched a regular query", query)
+		}
+	}
+}
+
+func TestLoadAttachment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deploy.yaml")
+	if err := os.WriteFile(path, []byte("kind: Deployment\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	attachment, err := loadAttachment(path)
+	if err != nil {
+		t.Fatalf("loadAttachment(%q) returned error: %v", path, err)
+	}
+	if attachment.Name != "deploy.yaml" || attachment.Source != path || attachment.Size != 17 {
+		t.Errorf("loadAttachment(%q) = %+v", path, attachment)
+	}
+
+	if _, err := loadAttachment(dir); err == nil {
+		t.Errorf("loadAttachment(%q) returned no error for a directory", dir)
+	}
+}
+
+func TestValidateAttachment(t *testing.T) {
+	if err := validateAttachment(api.Attachment{Name: "app.log", Content: "started\n"}); err != nil {
+		t.Errorf("validateAttachment() returned error for a text file: %v", err)
+	}
+	if err := validateAttachment(api.Attachment{Name: "app.bin", Content: "\x7fELF\x00\x01"}); err == nil {
+		t.Errorf("validateAttachment() returned no error for a binary file")
+	}
+}
+
+func TestChunkAttachment(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	attachment := api.Attachment{
+		Name:    "app.log",
+		Source:  "/var/log/app.log",
+		Content: strings.Repeat(line, 2*attachmentChunkBytes/len(line)+1),
+	}
+
+	parts := chunkAttachment(attachment)
+	if len(parts) != 3 {
+		t.Fatalf("chunkAttachment() returned %d parts, want 3", len(parts))
+	}
+	var content strings.Builder
+	for i, part := range parts {
+		header, body, _ := strings.Cut(part, "\n```\n")
+		if !strings.Contains(header, "/var/log/app.log") || !strings.Contains(header, "part "+string(rune('1'+i))+" of 3") {
+			t.Errorf("part %d has header %q", i+1, header)
+		}
+		content.WriteString(strings.TrimSuffix(body, "\n```") + "\n")
+	}
+	if content.String() != attachment.Content {
+		t.Errorf("chunks don't add up to the original content")
+	}
+}
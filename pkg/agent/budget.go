@@ -0,0 +1,154 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// trackContext estimates the context tokens of the next request from the
+// usage of the last one, against the context window of the model, and warns
+// the user once the conversation nears the window.
+func (c *Agent) trackContext(metadata any) {
+	usage, ok := gollm.NormalizeUsage(metadata)
+	if !ok {
+		return
+	}
+	capabilities, _ := gollm.CapabilitiesFor(c.Model)
+
+	c.sessionMu.Lock()
+	u := &c.Session.Usage
+	u.ContextTokens = usage.InputTokens + usage.OutputTokens
+	u.ContextWindow = capabilities.ContextWindow
+	used, known := u.ContextUsed()
+	c.sessionMu.Unlock()
+
+	if !known || used < api.ContextWarningThreshold {
+		c.contextWarned = false
+		return
+	}
+	if c.contextWarned {
+		return
+	}
+	c.contextWarned = true
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText,
+		fmt.Sprintf("The conversation uses %.0f%% of the context window of `%s`. Once it is full, the output of earlier tool calls will be dropped from the history sent to the model; use `clear` to start over.", used*100, c.Model))
+}
+
+// startQueryBudget resets the iteration, time and token limits for a new query.
+func (c *Agent) startQueryBudget() {
+	c.currIteration = 0
+	c.queryStarted = time.Now()
+	c.sessionMu.Lock()
+	c.queryStartTokens = c.Session.Usage.TotalTokens
+	c.sessionMu.Unlock()
+}
+
+// queryLimitReached returns why the current query must stop, or "" if it is
+// within its iteration, time and token limits.
+func (c *Agent) queryLimitReached() string {
+	if c.currIteration >= c.MaxIterations {
+		return fmt.Sprintf("the maximum of %d iterations was reached", c.MaxIterations)
+	}
+	if c.MaxQueryDuration > 0 && !c.queryStarted.IsZero() {
+		if elapsed := time.Since(c.queryStarted); elapsed >= c.MaxQueryDuration {
+			return fmt.Sprintf("the time limit of %s was reached", c.MaxQueryDuration)
+		}
+	}
+	if c.MaxQueryTokens > 0 {
+		c.sessionMu.Lock()
+		used := c.Session.Usage.TotalTokens - c.queryStartTokens
+		c.sessionMu.Unlock()
+		if used >= c.MaxQueryTokens {
+			return fmt.Sprintf("the budget of %d tokens was reached (%d used)", c.MaxQueryTokens, used)
+		}
+	}
+	return ""
+}
+
+// maxSummaryToolCalls is how many tool calls are listed in the summary of
+// the progress of a query that reached a limit.
+const maxSummaryToolCalls = 10
+
+// handleQueryLimit stops a query that reached a limit. The user is shown the
+// progress of the query and asked whether to continue.
+func (c *Agent) handleQueryLimit(reason string) {
+	klog.Infof("Stopping query: %s", reason)
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+
+	summary := c.progressSummary()
+	if c.RunOnce {
+		c.setAgentState(api.AgentStateDone)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Stopped: %s.\n\n%s", reason, summary))
+		return
+	}
+	c.limitChoicePending = true
+	c.setAgentState(api.AgentStateWaitingForInput)
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeContinueRequest, &api.ContinueRequest{
+		Reason:  reason,
+		Summary: summary,
+	})
+}
+
+// progressSummary lists the tool calls run since the query started, or was
+// last continued. It is built from the session rather than asked of the LLM,
+// so that a query over its budget doesn't spend more of it.
+func (c *Agent) progressSummary() string {
+	var calls []string
+	for _, msg := range c.Session.AllMessages() {
+		if msg.Type != api.MessageTypeToolCallRequest || msg.Timestamp.Before(c.queryStarted) {
+			continue
+		}
+		if description, ok := msg.Payload.(string); ok {
+			calls = append(calls, description)
+		}
+	}
+	if len(calls) == 0 {
+		return "No tool calls were run."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tool calls run so far (%d):\n", len(calls))
+	if len(calls) > maxSummaryToolCalls {
+		fmt.Fprintf(&b, "- … %d earlier calls\n", len(calls)-maxSummaryToolCalls)
+		calls = calls[len(calls)-maxSummaryToolCalls:]
+	}
+	for _, call := range calls {
+		fmt.Fprintf(&b, "- `%s`\n", truncateText(strings.ReplaceAll(call, "\n", " "), 100))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// handleLimitChoice resumes or ends a query that reached a limit, depending
+// on the user's choice.
+func (c *Agent) handleLimitChoice(choice *api.ContinueResponse) {
+	c.limitChoicePending = false
+	if choice.Continue {
+		c.startQueryBudget()
+		c.currChatContent = append(c.currChatContent, "Continue working on the task.")
+		c.setAgentState(api.AgentStateRunning)
+		return
+	}
+	// Keep the results of the last tool calls for the next query
+	c.interruptedToolResults = c.currChatContent
+	c.currChatContent = []any{}
+	c.setAgentState(api.AgentStateDone)
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestTrackContext(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	a := &Agent{
+		Model:   "gpt-4o",
+		Output:  make(chan any, 10),
+		Session: &api.Session{ChatMessageStore: store},
+	}
+
+	a.trackContext(&gollm.Usage{InputTokens: 50_000, OutputTokens: 1_000})
+	if u := a.Session.Usage; u.ContextTokens != 51_000 || u.ContextWindow != 128_000 {
+		t.Errorf("unexpected context usage %+v", u)
+	}
+	if len(store.ChatMessages()) != 0 {
+		t.Errorf("expected no warning below the threshold, got %+v", store.ChatMessages())
+	}
+
+	// The user is warned once while the conversation nears the window
+	a.trackContext(&gollm.Usage{InputTokens: 110_000, OutputTokens: 2_000})
+	a.trackContext(&gollm.Usage{InputTokens: 115_000, OutputTokens: 2_000})
+	messages := store.ChatMessages()
+	if len(messages) != 1 || !strings.Contains(messages[0].Payload.(string), "88% of the context window of `gpt-4o`") {
+		t.Errorf("expected a single warning, got %+v", messages)
+	}
+
+	a.Model = "my-fine-tune"
+	a.trackContext(&gollm.Usage{InputTokens: 200_000})
+	if used, known := a.Session.Usage.ContextUsed(); known || used != 0 {
+		t.Errorf("expected the context window of an unknown model to be unknown, got %v", used)
+	}
+}
+
+func TestQueryLimitReached(t *testing.T) {
+	a := &Agent{
+		MaxIterations:    5,
+		MaxQueryDuration: time.Minute,
+		MaxQueryTokens:   1000,
+		Session:          &api.Session{Usage: api.SessionUsage{TotalTokens: 500}},
+	}
+	a.startQueryBudget()
+	if reason := a.queryLimitReached(); reason != "" {
+		t.Fatalf("expected a new query to be within its limits, got %q", reason)
+	}
+
+	a.Session.Usage.TotalTokens = 1499
+	if reason := a.queryLimitReached(); reason != "" {
+		t.Errorf("expected tokens used before the query not to count, got %q", reason)
+	}
+	a.Session.Usage.TotalTokens = 1500
+	if reason := a.queryLimitReached(); !strings.Contains(reason, "budget of 1000 tokens") {
+		t.Errorf("expected the token budget to be reached, got %q", reason)
+	}
+
+	a.startQueryBudget()
+	a.queryStarted = time.Now().Add(-2 * time.Minute)
+	if reason := a.queryLimitReached(); !strings.Contains(reason, "time limit of 1m0s") {
+		t.Errorf("expected the time limit to be reached, got %q", reason)
+	}
+
+	a.startQueryBudget()
+	a.currIteration = 5
+	if reason := a.queryLimitReached(); !strings.Contains(reason, "maximum of 5 iterations") {
+		t.Errorf("expected the iteration limit to be reached, got %q", reason)
+	}
+}
+
+func TestHandleQueryLimit(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	a := &Agent{
+		MaxIterations: 5,
+		Output:        make(chan any, 20),
+		Session:       &api.Session{ChatMessageStore: store},
+	}
+	a.startQueryBudget()
+	for i := range maxSummaryToolCalls + 2 {
+		a.addMessage(api.MessageSourceModel, api.MessageTypeToolCallRequest, fmt.Sprintf("kubectl get pods -n ns-%d", i))
+	}
+
+	a.handleQueryLimit("the maximum of 5 iterations was reached")
+	if a.AgentState() != api.AgentStateWaitingForInput || !a.limitChoicePending {
+		t.Errorf("expected the user to be asked whether to continue, got state %s", a.AgentState())
+	}
+	messages := store.ChatMessages()
+	last := messages[len(messages)-1]
+	req, ok := last.Payload.(*api.ContinueRequest)
+	if last.Type != api.MessageTypeContinueRequest || !ok {
+		t.Fatalf("expected a continue request, got %+v", last)
+	}
+	if !strings.Contains(req.Summary, "(12)") || !strings.Contains(req.Summary, "2 earlier calls") ||
+		strings.Contains(req.Summary, "ns-1`") || !strings.Contains(req.Summary, "`kubectl get pods -n ns-11`") {
+		t.Errorf("expected the last %d of 12 tool calls to be listed, got %q", maxSummaryToolCalls, req.Summary)
+	}
+}
+
+func TestHandleLimitChoice(t *testing.T) {
+	a := &Agent{
+		MaxIterations:      5,
+		Session:            &api.Session{},
+		currIteration:      5,
+		limitChoicePending: true,
+		currChatContent:    []any{"tool call result"},
+	}
+	a.handleLimitChoice(&api.ContinueResponse{Continue: true})
+	if a.AgentState() != api.AgentStateRunning || a.currIteration != 0 || a.limitChoicePending {
+		t.Errorf("expected continuing to resume the query with a new budget, got state %s, iteration %d", a.AgentState(), a.currIteration)
+	}
+	if len(a.currChatContent) != 2 {
+		t.Errorf("expected the tool call result and a request to continue, got %v", a.currChatContent)
+	}
+
+	a.currChatContent = []any{"tool call result"}
+	a.handleLimitChoice(&api.ContinueResponse{Continue: false})
+	if a.AgentState() != api.AgentStateDone {
+		t.Errorf("expected stopping to end the query, got state %s", a.AgentState())
+	}
+	if len(a.interruptedToolResults) != 1 {
+		t.Errorf("expected the tool call result to be kept for the next query, got %v", a.interruptedToolResults)
+	}
+}
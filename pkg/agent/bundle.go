@@ -0,0 +1,263 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"sigs.k8s.io/yaml"
+)
+
+// sensitiveEnvRegex matches the names of the environment variables whose
+// values are left out of bundles, whatever they look like.
+var sensitiveEnvRegex = regexp.MustCompile(`(?i)(KEY|TOKEN|SECRET|PASSW|CREDENTIAL|AUTH|COOKIE|SESSION|PRIVATE|CERT)`)
+
+// bundleInfo is the info.json file of a diagnostic bundle.
+type bundleInfo struct {
+	Version     string           `json:"version,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	OS          string           `json:"os"`
+	Arch        string           `json:"arch"`
+	GoVersion   string           `json:"goVersion"`
+	Provider    string           `json:"provider"`
+	Model       string           `json:"model"`
+	SessionID   string           `json:"sessionID"`
+	SessionName string           `json:"sessionName,omitempty"`
+	KubeContext string           `json:"kubeContext,omitempty"`
+	Sandbox     string           `json:"sandbox,omitempty"`
+	Usage       api.SessionUsage `json:"usage"`
+	Messages    int              `json:"messages"`
+}
+
+// bundleMessage is a message of the transcript of a diagnostic bundle.
+type bundleMessage struct {
+	Timestamp   time.Time         `json:"timestamp"`
+	Source      api.MessageSource `json:"source"`
+	Type        api.MessageType   `json:"type"`
+	KubeContext string            `json:"kubeContext,omitempty"`
+	Payload     any               `json:"payload,omitempty"`
+}
+
+// WriteBundle writes a diagnostic bundle of the current session to w, as a
+// gzipped tarball, for attaching to support tickets. It holds:
+//   - info.json: the versions, provider, model and usage of the session
+//   - transcript.json: the messages of the session
+//   - commands.sh: the commands run, see WriteShellScript
+//   - journal.yaml: the events of the session in the trace, if it is written
+//     to a file
+//   - environment.txt: the environment variables, with secrets left out
+//
+// Secrets are masked in all of them, with the patterns of secret redaction
+// whether it is enabled or not. It returns the names of the files written.
+func (c *Agent) WriteBundle(w io.Writer) ([]string, error) {
+	redactor := c.redactor
+	if redactor == nil {
+		var err error
+		if redactor, err = tools.NewRedactor(c.RedactPatterns); err != nil {
+			return nil, err
+		}
+	}
+
+	c.sessionMu.Lock()
+	session := c.Session
+	provider, model := c.Provider, c.Model
+	c.sessionMu.Unlock()
+	messages := session.AllMessages()
+
+	type bundleFile struct {
+		name    string
+		content string
+	}
+	var files []bundleFile
+	addJSON := func(name string, v any) error {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", name, err)
+		}
+		files = append(files, bundleFile{name, string(b) + "\n"})
+		return nil
+	}
+
+	info := bundleInfo{
+		Version:     c.Version,
+		CreatedAt:   time.Now(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		GoVersion:   runtime.Version(),
+		Provider:    provider,
+		Model:       model,
+		SessionID:   session.ID,
+		SessionName: session.Name,
+		KubeContext: c.currentKubeContext(),
+		Sandbox:     c.Sandbox,
+		Usage:       session.Usage,
+		Messages:    len(messages),
+	}
+	if err := addJSON("info.json", info); err != nil {
+		return nil, err
+	}
+
+	transcript := make([]bundleMessage, 0, len(messages))
+	for _, msg := range messages {
+		transcript = append(transcript, bundleMessage{
+			Timestamp:   msg.Timestamp,
+			Source:      msg.Source,
+			Type:        msg.Type,
+			KubeContext: msg.KubeContext,
+			Payload:     msg.Payload,
+		})
+	}
+	if err := addJSON("transcript.json", transcript); err != nil {
+		return nil, err
+	}
+
+	var script strings.Builder
+	if _, err := WriteShellScript(&script, session); err != nil {
+		return nil, err
+	}
+	files = append(files, bundleFile{"commands.sh", script.String()})
+
+	if c.TracePath != "" {
+		journal, err := sessionJournal(c.TracePath, session.ID)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("reading trace: %w", err)
+		}
+		if err == nil {
+			files = append(files, bundleFile{"journal.yaml", journal})
+		}
+	}
+
+	files = append(files, bundleFile{"environment.txt", sanitizedEnvironment(os.Environ())})
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	names := make([]string, len(files))
+	for i, file := range files {
+		content := redactor.RedactString(file.content)
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0o644,
+			Size:    int64(len(content)),
+			ModTime: info.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			return nil, err
+		}
+		names[i] = file.name
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// sessionJournal returns the events of the trace at path recorded for the
+// session sessionID. The trace is shared by all the sessions of the process,
+// e.g. those of the users of the web UI.
+func sessionJournal(path, sessionID string) (string, error) {
+	events, err := journal.ParseEventsFromFile(path)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, event := range events {
+		if id, _ := event.GetString("sessionID"); event.SessionID != sessionID && id != sessionID {
+			continue
+		}
+		b, err := yaml.Marshal(event)
+		if err != nil {
+			return "", fmt.Errorf("encoding event: %w", err)
+		}
+		sb.Write(b)
+		sb.WriteString("\n---\n\n")
+	}
+	return sb.String(), nil
+}
+
+// sanitizedEnvironment lists the environment variables env, sorted, with the
+// values of the variables whose names suggest secrets left out.
+func sanitizedEnvironment(env []string) string {
+	env = append([]string{}, env...)
+	sort.Strings(env)
+	var sb strings.Builder
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		if sensitiveEnvRegex.MatchString(name) && value != "" {
+			value = tools.RedactedValue
+		}
+		fmt.Fprintf(&sb, "%s=%s\n", name, value)
+	}
+	return sb.String()
+}
+
+// isBundleCommand reports whether the fields of a query are the bundle
+// command: bundle, optionally followed by the path of a .tar.gz or .tgz file.
+func isBundleCommand(fields []string) bool {
+	switch {
+	case len(fields) == 1:
+		return fields[0] == "bundle"
+	case len(fields) == 2:
+		return fields[0] == "bundle" && (strings.HasSuffix(fields[1], ".tar.gz") || strings.HasSuffix(fields[1], ".tgz"))
+	}
+	return false
+}
+
+// exportBundle writes a diagnostic bundle of the current session to path, by
+// default kubectl-ai-bundle-<session ID>.tar.gz in the working directory. It
+// doesn't overwrite existing files.
+func (c *Agent) exportBundle(path string) (string, error) {
+	if path == "" {
+		c.sessionMu.Lock()
+		path = "kubectl-ai-bundle-" + c.Session.ID + ".tar.gz"
+		c.sessionMu.Unlock()
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Sprintf("%s already exists, choose another path: bundle <path>.tar.gz", path), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("creating bundle: %w", err)
+	}
+	names, err := c.WriteBundle(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("writing bundle: %w", err)
+	}
+	return fmt.Sprintf("Wrote the diagnostic bundle %s (%s), with secrets redacted. Review it before attaching it to a support ticket.", path, strings.Join(names, ", ")), nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestWriteBundle(t *testing.T) {
+	const token = "ghp_" + "abcdefghijklmnopqrstuvwxyz0123456789"
+	t.Setenv("KUBECTL_AI_TEST_API_KEY", "not-a-known-pattern")
+	t.Setenv("KUBECTL_AI_TEST_HOME", "/home/dev")
+
+	// The trace holds the events of all the sessions
+	tracePath := filepath.Join(t.TempDir(), "trace.txt")
+	recorder, err := journal.NewFileRecorder(tracePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := journal.ContextWithSession(context.Background(), "20250807-510872")
+	otherCtx := journal.ContextWithSession(context.Background(), "20250807-999999")
+	for _, write := range []struct {
+		ctx   context.Context
+		event *journal.Event
+	}{
+		{ctx, &journal.Event{Action: journal.ActionHTTPRequest, Payload: &journal.LLMRequest{Request: "Authorization " + token}}},
+		{otherCtx, &journal.Event{Action: journal.ActionHTTPRequest, Payload: &journal.LLMRequest{Request: "other session"}}},
+		{context.Background(), &journal.Event{Action: journal.ActionFeedback, Payload: &journal.Feedback{SessionID: "20250807-510872", Rating: "up"}}},
+	} {
+		if err := recorder.Write(write.ctx, write.event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recorder.Close()
+	store := sessions.NewInMemoryChatStore()
+	for _, msg := range []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "why is web failing?"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get secret web -o yaml"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stdout: "token: " + token}},
+	} {
+		if err := store.AddChatMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := &Agent{
+		Provider:  "gemini",
+		Model:     "gemini-2.5-pro",
+		Version:   "v1.2.3",
+		TracePath: tracePath,
+		Session:   &api.Session{ID: "20250807-510872", ChatMessageStore: store},
+	}
+
+	var b bytes.Buffer
+	names, err := a.WriteBundle(&b)
+	if err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	if got := strings.Join(names, ","); got != "info.json,transcript.json,commands.sh,journal.yaml,environment.txt" {
+		t.Errorf("WriteBundle() wrote %s", got)
+	}
+
+	gz, err := gzip.NewReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(content)
+	}
+
+	for name, want := range map[string]string{
+		"info.json":       `"model": "gemini-2.5-pro"`,
+		"transcript.json": "why is web failing?",
+		"commands.sh":     "kubectl get secret web -o yaml\n",
+		"journal.yaml":    "rating: up",
+		"environment.txt": "KUBECTL_AI_TEST_HOME=/home/dev\n",
+	} {
+		if !strings.Contains(files[name], want) {
+			t.Errorf("expected %s to contain %q, got:\n%s", name, want, files[name])
+		}
+		if strings.Contains(files[name], token) {
+			t.Errorf("expected the token to be redacted in %s, got:\n%s", name, files[name])
+		}
+	}
+	if !strings.Contains(files["journal.yaml"], "action: http.request") || strings.Contains(files["journal.yaml"], "other session") {
+		t.Errorf("expected only the events of the session in the journal, got:\n%s", files["journal.yaml"])
+	}
+	if !strings.Contains(files["environment.txt"], "KUBECTL_AI_TEST_API_KEY=[REDACTED]\n") {
+		t.Errorf("expected the API key variable to be redacted, got:\n%s", files["environment.txt"])
+	}
+}
+
+func TestExportBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	a := &Agent{Session: &api.Session{ID: "20250807-510872", ChatMessageStore: sessions.NewInMemoryChatStore()}}
+
+	if answer, err := a.exportBundle(path); err != nil || !strings.Contains(answer, "Wrote the diagnostic bundle") {
+		t.Fatalf("exportBundle() = %q, %v", answer, err)
+	}
+	if err := os.WriteFile(path, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if answer, err := a.exportBundle(path); err != nil || !strings.Contains(answer, "already exists") {
+		t.Errorf("expected an existing file to be kept, got %q, %v", answer, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "keep" {
+		t.Errorf("expected an existing file not to be overwritten, got %q", b)
+	}
+
+	a.DisableLocalFiles = true
+	other := filepath.Join(filepath.Dir(path), "other.tar.gz")
+	if answer, handled, err := a.handleMetaQuery(context.Background(), "bundle "+other); err != nil || !handled || !strings.Contains(answer, "not available") {
+		t.Errorf("expected bundle to be disabled, got %q, %v", answer, err)
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Errorf("expected no bundle to be written, got %v", err)
+	}
+
+	for query, want := range map[string]bool{
+		"bundle":                         true,
+		"bundle /tmp/support.tar.gz":     true,
+		"bundle support.tgz":             true,
+		"bundle the manifests into helm": false,
+		"bundle everything":              false,
+	} {
+		if got := isBundleCommand(strings.Fields(query)); got != want {
+			t.Errorf("isBundleCommand(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
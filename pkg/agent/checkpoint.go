@@ -0,0 +1,159 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// checkpointer returns the session's store if it can persist tool loop
+// checkpoints, or nil.
+func (c *Agent) checkpointer() api.ToolLoopCheckpointer {
+	if c.Session == nil {
+		return nil
+	}
+	checkpointer, _ := c.Session.ChatMessageStore.(api.ToolLoopCheckpointer)
+	return checkpointer
+}
+
+// startCheckpoint records that calls are about to be dispatched, so that an
+// interruption can be recovered from when the session is resumed. It returns
+// nil if the session store doesn't support checkpoints.
+func (c *Agent) startCheckpoint(calls []ToolCallAnalysis) *api.ToolLoopCheckpoint {
+	checkpointer := c.checkpointer()
+	if checkpointer == nil {
+		return nil
+	}
+	checkpoint := &api.ToolLoopCheckpoint{
+		HistoryLength: len(c.Session.ChatMessageStore.ChatMessages()),
+		Iteration:     c.currIteration,
+	}
+	for _, call := range calls {
+		checkpoint.Calls = append(checkpoint.Calls, api.CheckpointedToolCall{
+			ID:               call.FunctionCall.ID,
+			Name:             call.FunctionCall.Name,
+			Description:      call.ParsedToolCall.Description(),
+			Arguments:        call.FunctionCall.Arguments,
+			ModifiesResource: call.ModifiesResourceStr,
+		})
+	}
+	if err := checkpointer.SaveCheckpoint(checkpoint); err != nil {
+		klog.Warningf("Failed to save tool loop checkpoint: %v", err)
+	}
+	return checkpoint
+}
+
+// checkpointResult records the result of the i-th call of checkpoint.
+func (c *Agent) checkpointResult(checkpoint *api.ToolLoopCheckpoint, i int, result *toolCallResult) {
+	if checkpoint == nil {
+		return
+	}
+	checkpoint.Calls[i].Done = true
+	checkpoint.Calls[i].Result = result.payload
+	checkpoint.Calls[i].KubeContext = result.kubeContext
+	if err := c.checkpointer().SaveCheckpoint(checkpoint); err != nil {
+		klog.Warningf("Failed to save tool loop checkpoint: %v", err)
+	}
+}
+
+// clearCheckpoint removes the checkpoint once the calls have all been dispatched.
+func (c *Agent) clearCheckpoint(checkpoint *api.ToolLoopCheckpoint) {
+	if checkpoint == nil {
+		return
+	}
+	if err := c.checkpointer().ClearCheckpoint(); err != nil {
+		klog.Warningf("Failed to clear tool loop checkpoint: %v", err)
+	}
+}
+
+// recoverToolLoop repairs the history of a session that was interrupted (e.g.
+// by a crash) while tool calls were running. The messages of the interrupted
+// iteration are rolled back and replaced with the calls that completed, and a
+// note telling the model which calls did not, so the conversation can safely
+// continue. The caller must hold sessionMu, or own the agent exclusively.
+func (c *Agent) recoverToolLoop() error {
+	checkpointer := c.checkpointer()
+	if checkpointer == nil {
+		return nil
+	}
+	checkpoint, err := checkpointer.LoadCheckpoint()
+	if err != nil {
+		return fmt.Errorf("loading tool loop checkpoint: %w", err)
+	}
+	if checkpoint == nil {
+		return nil
+	}
+
+	store := c.Session.ChatMessageStore
+	messages := store.ChatMessages()
+	if checkpoint.HistoryLength < len(messages) {
+		messages = messages[:checkpoint.HistoryLength]
+	}
+
+	var completed, interrupted []string
+	for _, call := range checkpoint.Calls {
+		if !call.Done {
+			note := fmt.Sprintf("- %s: did not complete, it is safe to run it again", call.Description)
+			if call.ModifiesResource != "no" {
+				note = fmt.Sprintf("- %s: did not complete and may modify resources, check whether it took effect before running it again", call.Description)
+			}
+			interrupted = append(interrupted, note)
+			continue
+		}
+		completed = append(completed, "- "+call.Description)
+		request := recoveredMessage(call.KubeContext, api.MessageSourceModel, api.MessageTypeToolCallRequest, call.Description)
+		response := recoveredMessage(call.KubeContext, api.MessageSourceAgent, api.MessageTypeToolCallResponse, call.Result)
+		response.ToolCallID = request.ID
+		messages = append(messages, request, response)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("The session was interrupted while running tools, and has been resumed.")
+	if len(completed) > 0 {
+		fmt.Fprintf(&sb, "\nThese calls completed, their results are above:\n%s", strings.Join(completed, "\n"))
+	}
+	if len(interrupted) > 0 {
+		fmt.Fprintf(&sb, "\nThese calls were interrupted:\n%s", strings.Join(interrupted, "\n"))
+	}
+	messages = append(messages, recoveredMessage("", api.MessageSourceAgent, api.MessageTypeText, sb.String()))
+
+	// Rewrite the history before removing the checkpoint: if this is
+	// interrupted too, the next resume repeats the recovery from the start.
+	if err := store.SetChatMessages(messages); err != nil {
+		return fmt.Errorf("rolling back interrupted tool calls: %w", err)
+	}
+	if err := checkpointer.ClearCheckpoint(); err != nil {
+		return fmt.Errorf("clearing tool loop checkpoint: %w", err)
+	}
+	klog.Infof("Recovered session %s from an interrupted tool loop: %d calls completed, %d interrupted", c.Session.ID, len(completed), len(interrupted))
+	return nil
+}
+
+func recoveredMessage(kubeContext string, source api.MessageSource, messageType api.MessageType, payload any) *api.Message {
+	return &api.Message{
+		ID:          uuid.New().String(),
+		Source:      source,
+		Type:        messageType,
+		Payload:     payload,
+		Timestamp:   time.Now(),
+		KubeContext: kubeContext,
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestRecoverToolLoop(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	store.AddChatMessage(&api.Message{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "scale web to 3"})
+	store.AddChatMessage(&api.Message{Source: api.MessageSourceModel, Type: api.MessageTypeText, Payload: "Let me check."})
+	// Messages of the interrupted iteration
+	store.AddChatMessage(&api.Message{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get deploy web"})
+	store.AddChatMessage(&api.Message{Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl scale deploy web --replicas=3"})
+	store.SaveCheckpoint(&api.ToolLoopCheckpoint{
+		HistoryLength: 2,
+		Calls: []api.CheckpointedToolCall{
+			{Name: "kubectl", Description: "kubectl get deploy web", ModifiesResource: "no", Done: true, Result: "web 1/1"},
+			{Name: "kubectl", Description: "kubectl scale deploy web --replicas=3", ModifiesResource: "yes"},
+			{Name: "kubectl", Description: "kubectl get pods", ModifiesResource: "no"},
+		},
+	})
+
+	a := &Agent{Session: &api.Session{ChatMessageStore: store}}
+	if err := a.recoverToolLoop(); err != nil {
+		t.Fatalf("recoverToolLoop() error = %v", err)
+	}
+
+	messages := store.ChatMessages()
+	if len(messages) != 5 {
+		t.Fatalf("expected the 2 earlier messages, the completed call and its result, and a note; got %d messages", len(messages))
+	}
+	if messages[2].Payload != "kubectl get deploy web" || messages[3].Payload != "web 1/1" {
+		t.Errorf("expected the completed call to be kept, got %v and %v", messages[2].Payload, messages[3].Payload)
+	}
+	note := messages[4].Payload.(string)
+	if !strings.Contains(note, "kubectl scale deploy web --replicas=3: did not complete and may modify resources") {
+		t.Errorf("expected the interrupted change to be flagged, got %q", note)
+	}
+	if !strings.Contains(note, "kubectl get pods: did not complete, it is safe to run it again") {
+		t.Errorf("expected the interrupted read to be safe to retry, got %q", note)
+	}
+	if checkpoint, _ := store.LoadCheckpoint(); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be cleared")
+	}
+
+	// Without a checkpoint, there's nothing to recover
+	if err := a.recoverToolLoop(); err != nil || len(store.ChatMessages()) != 5 {
+		t.Errorf("expected no changes without a checkpoint, got %v", err)
+	}
+}
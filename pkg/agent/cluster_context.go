@@ -0,0 +1,229 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// defaultClusterContextTTL is how long a cluster snapshot is considered fresh.
+const defaultClusterContextTTL = 10 * time.Minute
+
+// minClusterContextBackoff is how long to wait before collecting the
+// snapshot again after it failed, doubled on every failure up to the TTL.
+const minClusterContextBackoff = 30 * time.Second
+
+// maxSnapshotItems caps the number of namespaces and CRDs listed in the snapshot
+// to keep the system prompt compact.
+const maxSnapshotItems = 30
+
+// clusterSnapshot is a compact summary of the cluster the agent operates on.
+type clusterSnapshot struct {
+	ServerVersion string
+	NodeCount     int
+	Namespaces    []string
+	CRDs          []string
+	// Unknown lists the parts of the snapshot that couldn't be collected,
+	// e.g. "nodes", whose fields are left out of the system prompt.
+	Unknown []string
+
+	collectedAt time.Time
+}
+
+// The parts of the snapshot, collected by separate commands.
+const (
+	snapshotVersion    = "Kubernetes version"
+	snapshotNodes      = "nodes"
+	snapshotNamespaces = "namespaces"
+	snapshotCRDs       = "CRDs"
+)
+
+// isStale returns true if the snapshot is older than ttl.
+func (s *clusterSnapshot) isStale(ttl time.Duration) bool {
+	if s == nil {
+		return true
+	}
+	if ttl <= 0 {
+		ttl = defaultClusterContextTTL
+	}
+	return time.Since(s.collectedAt) > ttl
+}
+
+// updateClusterSnapshot collects the cluster snapshot if it is stale, and
+// reports whether it changed. After a failure, e.g. when the cluster is
+// unreachable, the snapshot isn't collected again until a backoff passes, so
+// that queries aren't slowed down by failing commands every time.
+func (c *Agent) updateClusterSnapshot(ctx context.Context) (bool, error) {
+	now := time.Now()
+	if !c.clusterSnapshot.isStale(c.ClusterContextTTL) || now.Before(c.clusterSnapshotRetryAt) {
+		return false, nil
+	}
+	snapshot, err := collectClusterSnapshot(ctx, c.executor, c.kubeconfig(), c.workDir)
+	if err != nil {
+		c.clusterSnapshotFailures++
+		c.clusterSnapshotRetryAt = now.Add(clusterContextBackoff(c.clusterSnapshotFailures, c.ClusterContextTTL))
+		return false, err
+	}
+	c.clusterSnapshot = snapshot
+	c.clusterSnapshotFailures = 0
+	c.clusterSnapshotRetryAt = time.Time{}
+	return true, nil
+}
+
+// clusterContextBackoff is how long to wait after the given number of
+// consecutive failures to collect the snapshot.
+func clusterContextBackoff(failures int, ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = defaultClusterContextTTL
+	}
+	backoff := minClusterContextBackoff
+	for i := 1; i < failures && backoff < ttl; i++ {
+		backoff *= 2
+	}
+	return min(backoff, ttl)
+}
+
+// String renders the snapshot for inclusion in the system prompt.
+func (s *clusterSnapshot) String() string {
+	if s == nil {
+		return ""
+	}
+	known := func(part string) bool { return !slices.Contains(s.Unknown, part) }
+	var sb strings.Builder
+	if s.ServerVersion != "" {
+		fmt.Fprintf(&sb, "- Kubernetes version: %s\n", s.ServerVersion)
+	}
+	if known(snapshotNodes) {
+		fmt.Fprintf(&sb, "- Nodes: %d\n", s.NodeCount)
+	}
+	if known(snapshotNamespaces) {
+		fmt.Fprintf(&sb, "- Namespaces (%d): %s\n", len(s.Namespaces), summarizeList(s.Namespaces))
+	}
+	if known(snapshotCRDs) {
+		fmt.Fprintf(&sb, "- CRDs (%d): %s\n", len(s.CRDs), summarizeList(s.CRDs))
+	}
+	if len(s.Unknown) > 0 {
+		fmt.Fprintf(&sb, "- Unknown, as the commands collecting them failed: %s\n", strings.Join(s.Unknown, ", "))
+	}
+	fmt.Fprintf(&sb, "- Collected at: %s\n", s.collectedAt.Format(time.RFC3339))
+	return sb.String()
+}
+
+func summarizeList(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	if len(items) <= maxSnapshotItems {
+		return strings.Join(items, ", ")
+	}
+	return strings.Join(items[:maxSnapshotItems], ", ") + fmt.Sprintf(", ... (%d more)", len(items)-maxSnapshotItems)
+}
+
+// collectClusterSnapshot gathers the cluster version, node count, namespaces and CRDs.
+// Individual failures are logged and recorded in Unknown, so a partially
+// accessible cluster still yields a useful snapshot without the failed parts
+// passing for empty ones.
+func collectClusterSnapshot(ctx context.Context, executor sandbox.Executor, kubeconfig string, workDir string) (*clusterSnapshot, error) {
+	env := os.Environ()
+	if kubeconfig != "" {
+		kubeconfig, err := tools.ExpandShellVar(kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, "KUBECONFIG="+kubeconfig)
+	}
+
+	run := func(command string) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+		result, err := executor.Execute(ctx, command, env, workDir)
+		if err != nil {
+			return "", err
+		}
+		if result.ExitCode != 0 || result.Error != "" {
+			return "", fmt.Errorf("command %q failed: %s%s", command, result.Error, result.Stderr)
+		}
+		return result.Stdout, nil
+	}
+
+	snapshot := &clusterSnapshot{collectedAt: time.Now()}
+	var errs []string
+	failed := func(part string, err error) {
+		errs = append(errs, err.Error())
+		snapshot.Unknown = append(snapshot.Unknown, part)
+	}
+
+	if out, err := run("kubectl version -o json"); err != nil {
+		failed(snapshotVersion, err)
+	} else {
+		var version struct {
+			ServerVersion struct {
+				GitVersion string `json:"gitVersion"`
+			} `json:"serverVersion"`
+		}
+		if err := json.Unmarshal([]byte(out), &version); err == nil {
+			snapshot.ServerVersion = version.ServerVersion.GitVersion
+		}
+	}
+
+	if out, err := run("kubectl get nodes -o name"); err != nil {
+		failed(snapshotNodes, err)
+	} else {
+		snapshot.NodeCount = len(splitNames(out, ""))
+	}
+
+	if out, err := run("kubectl get namespaces -o name"); err != nil {
+		failed(snapshotNamespaces, err)
+	} else {
+		snapshot.Namespaces = splitNames(out, "namespace/")
+	}
+
+	if out, err := run("kubectl get customresourcedefinitions -o name"); err != nil {
+		failed(snapshotCRDs, err)
+	} else {
+		snapshot.CRDs = splitNames(out, "customresourcedefinition.apiextensions.k8s.io/")
+	}
+
+	if len(errs) == 4 {
+		return nil, fmt.Errorf("collecting cluster snapshot: %s", strings.Join(errs, "; "))
+	}
+	for _, e := range errs {
+		klog.Warningf("partial cluster snapshot: %s", e)
+	}
+	return snapshot, nil
+}
+
+// splitNames splits `kubectl -o name` output into names, stripping prefix.
+func splitNames(out string, prefix string) []string {
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		names = append(names, strings.TrimPrefix(line, prefix))
+	}
+	return names
+}
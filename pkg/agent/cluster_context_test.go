@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// fakeExecutor answers the snapshot commands, or fails all of them, or
+// those in failCommands.
+type fakeExecutor struct {
+	fail         bool
+	failCommands []string
+	calls        int
+}
+
+func (e *fakeExecutor) Execute(ctx context.Context, command string, env []string, workDir string) (*sandbox.ExecResult, error) {
+	e.calls++
+	if e.fail {
+		return nil, errors.New("connection refused")
+	}
+	if slices.Contains(e.failCommands, command) {
+		return &sandbox.ExecResult{Command: command, ExitCode: 1, Stderr: "Error from server (Forbidden)"}, nil
+	}
+	stdout := map[string]string{
+		"kubectl version -o json":        `{"serverVersion": {"gitVersion": "v1.31.0"}}`,
+		"kubectl get nodes -o name":      "node/a\nnode/b\n",
+		"kubectl get namespaces -o name": "namespace/default\n",
+	}[command]
+	return &sandbox.ExecResult{Command: command, Stdout: stdout}, nil
+}
+
+func (e *fakeExecutor) Upload(ctx context.Context, localPath string, remotePath string) error {
+	return nil
+}
+
+func (e *fakeExecutor) Download(ctx context.Context, remotePath string, localPath string) error {
+	return nil
+}
+
+func (e *fakeExecutor) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestClusterSnapshotIsStale(t *testing.T) {
+	var nilSnapshot *clusterSnapshot
+	if !nilSnapshot.isStale(time.Minute) {
+		t.Errorf("expected a missing snapshot to be stale")
+	}
+	if (&clusterSnapshot{collectedAt: time.Now()}).isStale(time.Minute) {
+		t.Errorf("expected a new snapshot not to be stale")
+	}
+	if !(&clusterSnapshot{collectedAt: time.Now().Add(-2 * time.Minute)}).isStale(time.Minute) {
+		t.Errorf("expected an old snapshot to be stale")
+	}
+}
+
+func TestUpdateClusterSnapshot_Backoff(t *testing.T) {
+	ctx := context.Background()
+	executor := &fakeExecutor{fail: true}
+	a := &Agent{executor: executor, ClusterContextTTL: time.Hour}
+
+	if _, err := a.updateClusterSnapshot(ctx); err == nil {
+		t.Fatalf("expected an error when all commands fail")
+	}
+	calls := executor.calls
+	if updated, err := a.updateClusterSnapshot(ctx); updated || err != nil {
+		t.Errorf("updateClusterSnapshot() = %v, %v during the backoff, want false, nil", updated, err)
+	}
+	if executor.calls != calls {
+		t.Errorf("expected no commands to run during the backoff, got %d", executor.calls-calls)
+	}
+
+	// Once the backoff passed, the snapshot is collected again, and a
+	// success resets the backoff.
+	a.clusterSnapshotRetryAt = time.Now().Add(-time.Second)
+	executor.fail = false
+	updated, err := a.updateClusterSnapshot(ctx)
+	if !updated || err != nil {
+		t.Fatalf("updateClusterSnapshot() = %v, %v after the backoff, want true, nil", updated, err)
+	}
+	if a.clusterSnapshot.ServerVersion != "v1.31.0" || a.clusterSnapshot.NodeCount != 2 {
+		t.Errorf("unexpected snapshot %+v", a.clusterSnapshot)
+	}
+	if a.clusterSnapshotFailures != 0 || !a.clusterSnapshotRetryAt.IsZero() {
+		t.Errorf("expected the backoff to be reset, got %d failures, retry at %v", a.clusterSnapshotFailures, a.clusterSnapshotRetryAt)
+	}
+}
+
+func TestCollectClusterSnapshot_PartialFailure(t *testing.T) {
+	executor := &fakeExecutor{failCommands: []string{"kubectl get nodes -o name"}}
+	snapshot, err := collectClusterSnapshot(context.Background(), executor, "", "")
+	if err != nil {
+		t.Fatalf("collectClusterSnapshot() error = %v", err)
+	}
+	got := snapshot.String()
+	if strings.Contains(got, "Nodes:") {
+		t.Errorf("expected the node count not to be rendered when kubectl get nodes fails, got:\n%s", got)
+	}
+	for _, want := range []string{"- Namespaces (1): default\n", "- CRDs (0): none\n", "- Unknown, as the commands collecting them failed: nodes\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected the snapshot to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestClusterContextBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		failures int
+		ttl      time.Duration
+		want     time.Duration
+	}{
+		{failures: 1, ttl: time.Hour, want: 30 * time.Second},
+		{failures: 2, ttl: time.Hour, want: time.Minute},
+		{failures: 4, ttl: time.Hour, want: 4 * time.Minute},
+		{failures: 10, ttl: 10 * time.Minute, want: 10 * time.Minute},
+		{failures: 100, ttl: 0, want: defaultClusterContextTTL},
+	} {
+		if got := clusterContextBackoff(tc.failures, tc.ttl); got != tc.want {
+			t.Errorf("clusterContextBackoff(%d, %v) = %v, want %v", tc.failures, tc.ttl, got, tc.want)
+		}
+	}
+}
@@ -16,26 +16,37 @@ package agent
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"maps"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/compression"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/mcp"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/notify"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/retrieval"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 )
 
@@ -70,7 +81,19 @@ type Agent struct {
 	// currIteration tracks the current iteration of the agentic loop.
 	currIteration int
 
+	// malformedCallRetries counts the consecutive times the LLM was asked to
+	// resend tool calls whose arguments were not valid JSON.
+	malformedCallRetries int
+
 	LLM gollm.Client
+	// NewLLM creates a client for provider, to switch the provider of the
+	// session with the model meta-query.
+	NewLLM func(ctx context.Context, provider string) (gollm.Client, error)
+	// ModelRouter, if set, picks the model of each query.
+	ModelRouter *RoutingRules
+	// FallbackModel replaces a model the provider doesn't know. If empty, the
+	// available model whose name is the closest is used instead.
+	FallbackModel string
 
 	// PromptTemplateFile allows specifying a custom template file
 	PromptTemplateFile string
@@ -80,9 +103,23 @@ type Agent struct {
 	Model            string
 	Provider         string
 
+	// SystemPromptFile is a template that extends or replaces the system
+	// prompt. The built-in prompt is available in it as {{template "default" .}}.
+	// It is skipped if it doesn't exist.
+	SystemPromptFile string
+	// PromptVars are available to prompt templates as {{.Vars.<name>}}.
+	PromptVars map[string]string
+
 	RemoveWorkDir bool
 
 	MaxIterations int
+	// MaxContinuations is the number of times a response cut off at the
+	// output token limit of the model is continued. Zero disables it.
+	MaxContinuations int
+	// MaxQueryDuration bounds the wall-clock time spent on a query. Zero means no limit.
+	MaxQueryDuration time.Duration
+	// MaxQueryTokens bounds the LLM tokens used for a query. Zero means no limit.
+	MaxQueryTokens int64
 
 	// Kubeconfig is the path to the kubeconfig file.
 	Kubeconfig string
@@ -92,8 +129,17 @@ type Agent struct {
 	// SandboxImage is the container image to use for the sandbox
 	SandboxImage string
 
+	// SandboxUnrestricted runs the sandbox pod without the restricted security context.
+	SandboxUnrestricted bool
+
+	// SandboxRuntimeClass is the RuntimeClass of the sandbox pod (e.g. gvisor or kata).
+	SandboxRuntimeClass string
+
 	SkipPermissions bool
 
+	// ReadOnly rejects tool calls that could modify the cluster, see tools.CheckReadOnly.
+	ReadOnly bool
+
 	Tools tools.Tools
 
 	EnableToolUseShim bool
@@ -101,8 +147,135 @@ type Agent struct {
 	// MCPClientEnabled indicates whether MCP client mode is enabled
 	MCPClientEnabled bool
 
+	// Contexts lists the kubeconfig contexts the agent may switch between
+	// using the switch_context tool. Empty disables multi-cluster support.
+	Contexts []tools.KubeContext
+
+	// PrometheusURL is the Prometheus endpoint queried by the promql_query tool.
+	// Empty disables the tool.
+	PrometheusURL string
+
+	// EnableKyvernoTools registers the tools inspecting Kyverno policies and
+	// policy reports.
+	EnableKyvernoTools bool
+
+	// EnableSchemaLookup registers the schema_lookup tool, describing the
+	// fields of the resource types of the cluster, and lists the custom
+	// resources of the cluster in the system prompt.
+	EnableSchemaLookup bool
+	// SchemaCache caches the API resources and CRD schemas read by
+	// schema_lookup. It can be shared between agents; if nil, the agent uses
+	// its own.
+	SchemaCache *tools.SchemaCache
+
+	// EnableDelegation registers the delegate tool, which runs focused
+	// investigations in read-only sub-agents. It requires an AgentManager.
+	EnableDelegation bool
+	// EnableWebSearch registers the web_search tool, answering questions with
+	// completions grounded with web search results. The LLM client must be
+	// created with gollm.WithSearchGrounding, and the provider support it.
+	EnableWebSearch bool
+	// SubAgentIterations is the maximum number of iterations of a sub-agent.
+	SubAgentIterations int
+	// Focus narrows the system prompt of a sub-agent to one area of an investigation.
+	Focus string
+
+	// MaxParallelToolCalls is the maximum number of read-only tool calls from a
+	// single LLM turn that are executed concurrently. Values below 1 run them
+	// serially. Calls that may modify resources always run alone.
+	MaxParallelToolCalls int
+
+	// MaxToolOutputBytes is the size above which tool outputs are truncated before
+	// being sent to the LLM. The full output can be fetched with the fetch_full_output tool.
+	// Zero disables truncation.
+	MaxToolOutputBytes int
+
+	// PruneToolOutputsAfter is the number of queries after which large tool
+	// outputs are replaced in the history sent to the LLM with a one-line
+	// summary and a reference to the full output. The session keeps the full
+	// outputs. Zero disables pruning.
+	PruneToolOutputsAfter int
+	// PruneToolOutputBytes is the size above which tool outputs are pruned,
+	// 4 KiB if zero.
+	PruneToolOutputBytes int
+
+	// HistoryCompressor, if set, summarizes the large messages of the history
+	// replayed to the LLM when a chat starts, e.g. when a session is resumed
+	// or the model is switched. The session keeps the full messages.
+	HistoryCompressor *compression.Compressor
+
+	// ToolVerbosity is how much documentation of the tools is sent to the LLM
+	// with every request, see gollm.MinimizeFunctionDefinitions.
+	ToolVerbosity gollm.ToolVerbosity
+
+	// ToolTimeout bounds how long a tool call may run, zero means no limit.
+	ToolTimeout time.Duration
+	// ToolTimeouts overrides ToolTimeout for individual tools, keyed by tool name.
+	ToolTimeouts map[string]time.Duration
+	// MaxExecOutputBytes is the maximum size of stdout and stderr kept from a
+	// command; the rest is discarded as it is produced. Zero means no limit.
+	MaxExecOutputBytes int
+	// StreamToolOutput sends the output of running commands to the UI line by
+	// line, as MessageTypeToolOutputDelta messages, so that the progress of
+	// long running commands like `kubectl rollout status` can be shown.
+	StreamToolOutput bool
+	// DisableLocalFiles disables the commands that read or write files on the
+	// machine the agent runs on, such as `image <path>`, for user interfaces
+	// whose users may not be on that machine, like the web UI.
+	DisableLocalFiles bool
+
+	// RedactSecrets masks the data of Kubernetes Secrets, tokens and other
+	// credentials in tool outputs and user input, before they are added to the
+	// history and sent to the LLM.
+	RedactSecrets bool
+	// RedactPatterns are regular expressions of additional content to mask.
+	RedactPatterns []string
+	// ContentFilters transform tool outputs and user input after redaction,
+	// before they are added to the history and sent to the LLM. If a filter
+	// fails, the content is not sent.
+	ContentFilters []tools.ContentFilter
+
+	// EnableClusterContext injects a summary of the cluster (version, nodes,
+	// namespaces, CRDs) into the system prompt.
+	EnableClusterContext bool
+
+	// ClusterContextTTL is how long the cluster summary is reused before being refreshed.
+	ClusterContextTTL time.Duration
+
 	// Recorder captures events for diagnostics
 	Recorder journal.Recorder
+	// TracePath is the file the Recorder writes the trace to, if any, which
+	// diagnostic bundles include.
+	TracePath string
+	// Version is the version of kubectl-ai, reported in diagnostic bundles.
+	Version string
+
+	// Runbooks are the runbooks the user can run with `run <name>`, by name.
+	Runbooks map[string]*Runbook
+
+	// UserProfilePath is the YAML file of the user's long-term profile, facts
+	// included in the system prompt of every session, see UserProfile. Empty
+	// disables the profile.
+	UserProfilePath string
+
+	// Retriever, if set, retrieves the excerpts of the user's runbooks and
+	// docs relevant to each query, which are sent with it.
+	Retriever *retrieval.Retriever
+
+	// Notifier, if set, is told when a query is done and when tool calls
+	// wait for approval, for users of headless runs.
+	Notifier notify.Notifier
+	// UIBaseURL is the URL of the HTML UI, notifications link to the session
+	// in it if set.
+	UIBaseURL string
+
+	// AutoNameSessions asks the LLM for a concise name for the session after
+	// the first couple of exchanges, replacing the default name.
+	AutoNameSessions bool
+
+	// CostEstimator converts token usage into estimated costs.
+	// If nil, a CostEstimator with the default pricing table is used.
+	CostEstimator *gollm.CostEstimator
 
 	llmChat gollm.Chat
 
@@ -118,9 +291,44 @@ type Agent struct {
 	// protects session from concurrent access
 	sessionMu sync.Mutex
 
+	// pendingChoice is the last message of the session if it asks the user to
+	// make a choice, nil otherwise, see PendingApproval. It is protected by
+	// sessionMu.
+	pendingChoice *pendingChoice
+
 	// cached list of available models
 	availableModels []string
 
+	// clusterSnapshot is the last collected summary of the cluster
+	clusterSnapshot *clusterSnapshot
+	// clusterSnapshotFailures counts the consecutive failures to collect the
+	// snapshot, which isn't collected again before clusterSnapshotRetryAt.
+	clusterSnapshotFailures int
+	clusterSnapshotRetryAt  time.Time
+	// apiResources lists the custom resources of the cluster for the system
+	// prompt, empty if schema lookups are disabled.
+	apiResources string
+	// profile is the user's long-term profile, nil if disabled.
+	profile *UserProfile
+	// profileProposals are the facts proposed for the profile in the
+	// session, waiting for the user to accept or reject them.
+	profileProposals []string
+
+	// outputTruncator truncates long tool outputs, nil if disabled
+	outputTruncator *tools.OutputTruncator
+	// outputStore keeps the full tool outputs truncated or pruned, for the
+	// fetch_full_output tool. It is nil if both are disabled.
+	outputStore *tools.OutputStore
+	// toolOutputsPerQuery counts the tool outputs sent in the chat by the
+	// last queries, the last one being the current query, for pruning.
+	toolOutputsPerQuery []int
+
+	// redactor masks secrets in content sent to the LLM, nil if disabled
+	redactor *tools.Redactor
+
+	// contextSwitcher tracks the kubeconfig context tools target, nil if disabled
+	contextSwitcher *tools.ContextSwitcher
+
 	// mcpManager manages MCP client connections
 	mcpManager *mcp.Manager
 
@@ -130,11 +338,74 @@ type Agent struct {
 	// SessionBackend is the configured backend for session persistence (e.g., memory, filesystem).
 	SessionBackend string
 
+	// sessionModel and sessionProvider are the model of the session, used by
+	// the queries the routing rules don't route to another model
+	sessionModel    string
+	sessionProvider string
+
 	// lastErr is the most recent error run into, for use across the stack
 	lastErr error
 
 	// cancel is the function to cancel the agent's context
 	cancel context.CancelFunc
+
+	// protects cancelTurn and runningToolCalls
+	turnMu sync.Mutex
+	// cancelTurn cancels the in-flight LLM call and tool calls of the current
+	// iteration of the agentic loop
+	cancelTurn context.CancelFunc
+	// runningToolCalls cancel the running tool calls, by the ID of their
+	// request message
+	runningToolCalls map[string]context.CancelCauseFunc
+	// deferredInputs are the inputs received while tool calls ran, read by
+	// the agent loop before the Input channel. Only the agent loop uses it.
+	deferredInputs []any
+
+	// namedSessionID is the ID of the last session automatic naming was attempted for
+	namedSessionID string
+
+	// systemPromptHash is the hex SHA-256 of the system prompt of the chat,
+	// recorded with feedback.
+	systemPromptHash string
+
+	// interruptedToolResults holds the tool call results of an interrupted turn.
+	// They are sent with the next query so every tool call the LLM made has a result.
+	interruptedToolResults []any
+
+	// pendingAttachments holds the content of attached files, to be sent with the next query.
+	pendingAttachments []any
+
+	// toolCallIDs correlates the tool calls of the LLM with their results.
+	toolCallIDs toolCallIDs
+
+	// runbook is the runbook being run, if any.
+	runbook *runbookRun
+
+	// contextWarned is set once the user was warned the conversation nears
+	// the context window of the model, until it no longer does.
+	contextWarned bool
+
+	// queryNotificationPending is set while a query runs, until the Notifier
+	// is told it is done.
+	queryNotificationPending bool
+	// notifications tracks the notifications being sent.
+	notifications sync.WaitGroup
+
+	// queryStarted and queryStartTokens are the time and session token
+	// count when the current query started, see queryLimitReached.
+	queryStarted     time.Time
+	queryStartTokens int64
+	// limitChoicePending is set while the user is asked whether to continue
+	// a query that reached a limit.
+	limitChoicePending bool
+
+	// manager is the AgentManager that started the agent, if any.
+	manager *AgentManager
+	// loopDone is closed when the agent loop started by Run returns.
+	loopDone chan struct{}
+	// closeOutputOnce guards closing Output, which is done by the loop when
+	// the user exits, or by the AgentManager when it shuts the agent down.
+	closeOutputOnce sync.Once
 }
 
 // Assert InMemoryChatStore implements ChatMessageStore
@@ -154,25 +425,100 @@ func (s *Agent) GetSession() *api.Session {
 
 // addMessage creates a new message, adds it to the session, and sends it to the output channel
 func (c *Agent) addMessage(source api.MessageSource, messageType api.MessageType, payload any) *api.Message {
+	return c.addMessageForContext("", source, messageType, payload)
+}
+
+// addMessageForContext is like addMessage, but annotates the message with the
+// kubeconfig context it relates to.
+func (c *Agent) addMessageForContext(kubeContext string, source api.MessageSource, messageType api.MessageType, payload any) *api.Message {
+	return c.storeMessage(&api.Message{
+		ID:          uuid.New().String(),
+		Source:      source,
+		Type:        messageType,
+		Payload:     payload,
+		Timestamp:   time.Now(),
+		KubeContext: kubeContext,
+	})
+}
+
+// storeMessage adds message to the session and sends it to the output channel.
+func (c *Agent) storeMessage(message *api.Message) *api.Message {
 	c.sessionMu.Lock()
 	defer c.sessionMu.Unlock()
-	message := &api.Message{
-		ID:        uuid.New().String(),
-		Source:    source,
-		Type:      messageType,
-		Payload:   payload,
-		Timestamp: time.Now(),
-	}
 
 	// Don't store UI control signals - they're not part of the conversation
-	if messageType != api.MessageTypeUserInputRequest {
-		c.Session.ChatMessageStore.AddChatMessage(message)
+	if message.Type != api.MessageTypeUserInputRequest {
+		if err := c.Session.ChatMessageStore.AddChatMessage(message); err != nil {
+			klog.Errorf("error saving message to session %s: %v", c.Session.ID, err)
+		}
 		c.Session.LastModified = time.Now()
+		c.pendingChoice = nil
+		if request, ok := message.Payload.(*api.UserChoiceRequest); ok && message.Type == api.MessageTypeUserChoiceRequest {
+			c.pendingChoice = &pendingChoice{sessionID: c.Session.ID, message: message, request: request}
+		}
 	}
 	c.Output <- message
 	return message
 }
 
+// sendTextDelta sends a chunk of streamed text to the UI, so it can be shown
+// before the LLM response is complete. id is the ID of the text message that
+// will hold the complete response.
+func (c *Agent) sendTextDelta(id string, text string) {
+	c.Output <- &api.Message{
+		ID:        id,
+		Source:    api.MessageSourceModel,
+		Type:      api.MessageTypeTextDelta,
+		Payload:   text,
+		Timestamp: time.Now(),
+	}
+}
+
+// saveSession saves the metadata of the session once a query is done, so
+// that e.g. its usage survives a crash.
+func (c *Agent) saveSession(ctx context.Context) {
+	if c.Session == nil || c.SessionBackend == "" {
+		return
+	}
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "error creating session manager")
+		return
+	}
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if err := manager.UpdateSession(c.Session); err != nil {
+		klog.FromContext(ctx).Error(err, "error saving session")
+	}
+}
+
+// recordUsage accumulates the usage reported by the LLM for a single request
+// into the session totals.
+func (c *Agent) recordUsage(metadata any) {
+	usage, ok := gollm.NormalizeUsage(metadata)
+	if !ok {
+		return
+	}
+	if c.CostEstimator == nil {
+		c.CostEstimator = gollm.NewCostEstimator(nil)
+	}
+	cost, known := c.CostEstimator.Estimate(c.Model, *usage)
+
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	u := &c.Session.Usage
+	if u.Requests == 0 {
+		u.CostKnown = true
+	}
+	u.Requests++
+	u.InputTokens += usage.InputTokens
+	u.OutputTokens += usage.OutputTokens
+	u.TotalTokens += usage.TotalTokens
+	u.EstimatedCost += cost
+	u.LastRequestCost = cost
+	u.CostKnown = u.CostKnown && known
+}
+
 // setAgentState updates the agent state and ensures LastModified is updated
 func (c *Agent) setAgentState(newState api.AgentState) {
 	c.sessionMu.Lock()
@@ -181,7 +527,23 @@ func (c *Agent) setAgentState(newState api.AgentState) {
 	if currentState != newState {
 		klog.Infof("Agent state changing from %s to %s", currentState, newState)
 		c.Session.AgentState = newState
+		if newState == api.AgentStateRunning {
+			c.queryNotificationPending = true
+		}
 		c.Session.LastModified = time.Now()
+		if c.Recorder != nil {
+			ctx := context.Background()
+			if err := c.Recorder.Write(ctx, &journal.Event{
+				Action: journal.ActionStateChange,
+				Payload: &journal.StateChange{
+					SessionID: c.Session.ID,
+					From:      string(currentState),
+					To:        string(newState),
+				},
+			}); err != nil {
+				klog.Warningf("failed to record state change in the journal: %v", err)
+			}
+		}
 	}
 }
 func (c *Agent) AgentState() api.AgentState {
@@ -224,8 +586,13 @@ func (s *Agent) Init(ctx context.Context) error {
 		if s.Session.LastModified.IsZero() {
 			s.Session.LastModified = time.Now()
 		}
+		if err := s.recoverToolLoop(); err != nil {
+			log.Error(err, "Failed to recover interrupted tool calls")
+		}
 		s.Session.Messages = s.Session.ChatMessageStore.ChatMessages()
-
+		if err := s.restoreSessionModel(ctx); err != nil {
+			return err
+		}
 	} else {
 		return fmt.Errorf("agent requires a session to be provided")
 	}
@@ -243,23 +610,14 @@ func (s *Agent) Init(ctx context.Context) error {
 	case "k8s":
 		sandboxName := fmt.Sprintf("kubectl-ai-sandbox-%s", uuid.New().String()[:8])
 
-		// Use default image if not specified
-		sandboxImage := s.SandboxImage
-		if sandboxImage == "" {
-			sandboxImage = "bitnami/kubectl:latest"
-		}
-
 		// Create sandbox with kubeconfig
-		sb, err := sandbox.NewKubernetesSandbox(sandboxName,
-			sandbox.WithKubeconfig(s.Kubeconfig),
-			sandbox.WithImage(sandboxImage),
-		)
+		sb, err := sandbox.NewKubernetesSandbox(sandboxName, s.kubernetesSandboxOptions()...)
 		if err != nil {
 			return fmt.Errorf("failed to create sandbox: %w", err)
 		}
 
 		s.executor = sb
-		log.Info("Created sandbox", "name", sandboxName, "image", sandboxImage)
+		log.Info("Created sandbox", "name", sandboxName, "image", s.sandboxImage(), "restricted", !s.SandboxUnrestricted, "runtimeClass", s.SandboxRuntimeClass)
 
 	case "seatbelt":
 		if runtime.GOOS != "darwin" {
@@ -282,23 +640,162 @@ func (s *Agent) Init(ctx context.Context) error {
 	// We clone existing tools (e.g. custom tools) to ensure we have a fresh map
 	// This avoids polluting the global default tools and ensures thread safety.
 	s.Tools = s.Tools.CloneWithExecutor(s.executor)
+	s.registerExecutorTools()
+
+	if len(s.Contexts) > 0 {
+		s.contextSwitcher = tools.NewContextSwitcher(s.Contexts, workDir)
+		if s.Sandbox == "k8s" {
+			// Commands run in workDir in the sandbox pod too, but the pod
+			// doesn't see the files the agent writes there.
+			if err := s.contextSwitcher.SetSandbox(ctx, s.executor); err != nil {
+				return err
+			}
+		}
+		s.Tools.RegisterTool(tools.NewSwitchContextTool(s.contextSwitcher))
+	}
+
+	if s.PrometheusURL != "" {
+		s.Tools.RegisterTool(tools.NewPromQLQueryTool(s.PrometheusURL, nil))
+	}
+
+	if s.EnableDelegation && s.manager != nil {
+		s.Tools.RegisterTool(newDelegateTool(s))
+	}
+
+	if s.EnableWebSearch {
+		if slices.Contains(searchGroundingProviders, s.Provider) {
+			s.Tools.RegisterTool(newWebSearchTool(s))
+		} else {
+			log.Info("Search grounding is not supported by the provider, not registering web_search", "provider", s.Provider)
+		}
+	}
+
+	if s.UserProfilePath != "" {
+		profile, err := LoadUserProfile(s.UserProfilePath)
+		if err != nil {
+			return err
+		}
+		s.profile = profile
+		// Non-interactive sessions can't accept proposals
+		if !s.RunOnce {
+			s.Tools.RegisterTool(newProposeProfileFactTool(s))
+		}
+	}
+
+	s.sessionModel, s.sessionProvider = s.Model, s.Provider
+
+	if s.RedactSecrets {
+		redactor, err := tools.NewRedactor(s.RedactPatterns)
+		if err != nil {
+			return err
+		}
+		s.redactor = redactor
+	}
+
+	if s.MaxToolOutputBytes > 0 || s.PruneToolOutputsAfter > 0 {
+		s.outputStore = tools.NewOutputStore(filepath.Join(workDir, "tool-outputs"))
+		s.Tools.RegisterTool(tools.NewFetchFullOutputTool(s.outputStore))
+	}
+	if s.MaxToolOutputBytes > 0 {
+		s.outputTruncator = tools.NewOutputTruncator(s.outputStore, s.MaxToolOutputBytes)
+	}
+
+	if s.EnableClusterContext {
+		if _, err := s.updateClusterSnapshot(ctx); err != nil {
+			log.Error(err, "Failed to collect cluster context, continuing without it")
+		}
+	}
+
+	if s.EnableSchemaLookup {
+		toolCtx := context.WithValue(ctx, tools.KubeconfigKey, s.kubeconfig())
+		toolCtx = context.WithValue(toolCtx, tools.WorkDirKey, s.workDir)
+		summary, err := s.SchemaCache.Summary(toolCtx, s.executor)
+		if err != nil {
+			log.Error(err, "Failed to list the API resources, continuing without them")
+		}
+		s.apiResources = summary
+	}
+
+	if err := s.startChat(ctx); err != nil {
+		return err
+	}
+
+	if s.MCPClientEnabled {
+		if err := s.InitializeMCPClient(ctx); err != nil {
+			klog.Errorf("Failed to initialize MCP client: %v", err)
+			return fmt.Errorf("failed to initialize MCP client: %w", err)
+		}
+
+		// Update MCP status in session
+		if err := s.UpdateMCPStatus(ctx, s.MCPClientEnabled); err != nil {
+			klog.Warningf("Failed to update MCP status: %v", err)
+		}
+	}
+
+	return s.setFunctionDefinitions()
+}
 
+// registerExecutorTools registers the built-in tools that run commands with
+// the agent's executor.
+func (s *Agent) registerExecutorTools() {
 	s.Tools.RegisterTool(tools.NewBashTool(s.executor))
 	s.Tools.RegisterTool(tools.NewKubectlTool(s.executor))
+	s.Tools.RegisterTool(tools.NewApplyManifestTool(s.executor))
+	s.Tools.RegisterTool(tools.NewEditResourceTool(s.executor))
+	s.Tools.RegisterTool(tools.NewGetEventsTool(s.executor))
+	s.Tools.RegisterTool(tools.NewGetLogsTool(s.executor))
+	if s.EnableKyvernoTools {
+		s.Tools.RegisterTool(tools.NewKyvernoPoliciesTool(s.executor))
+		s.Tools.RegisterTool(tools.NewKyvernoPolicyReportsTool(s.executor))
+		s.Tools.RegisterTool(tools.NewKyvernoExplainPolicyTool(s.executor))
+	}
+	if s.EnableSchemaLookup {
+		if s.SchemaCache == nil {
+			s.SchemaCache = tools.NewSchemaCache(0)
+		}
+		s.Tools.RegisterTool(tools.NewSchemaLookupTool(s.executor, s.SchemaCache))
+	}
+}
+
+// startChat generates the system prompt and starts a new chat with the LLM,
+// replaying the messages already in the session.
+func (s *Agent) startChat(ctx context.Context) error {
+	return s.startChatWithHistory(ctx, s.Session.ChatMessageStore.ChatMessages())
+}
 
+// startChatWithHistory is like startChat, replaying history instead.
+func (s *Agent) startChatWithHistory(ctx context.Context, history []*api.Message) error {
 	systemPrompt, err := s.generatePrompt(ctx, defaultSystemPromptTemplate, PromptData{
 		Tools:             s.Tools,
 		EnableToolUseShim: s.EnableToolUseShim,
 		// RunOnce is a good proxy to indicate the agentic session is non-interactive mode.
 		SessionIsInteractive: !s.RunOnce,
+		ClusterContext:       s.clusterSnapshot.String(),
+		APIResources:         s.apiResources,
+		KubeContext:          s.currentKubeContext(),
+		Vars:                 s.PromptVars,
+		Focus:                s.Focus,
+		Memory:               slices.Clone(s.Session.Memory),
+		SessionVariables:     maps.Clone(s.Session.Variables),
+		UserProfile:          s.profile.factTexts(),
 	})
 	if err != nil {
 		return fmt.Errorf("generating system prompt: %w", err)
 	}
+	// The system prompt includes the cluster context, the user profile and
+	// the memory of the session
+	systemPrompt, err = s.filterContent(ctx, tools.ContentKindSystemPrompt, systemPrompt)
+	if err != nil {
+		return fmt.Errorf("filtering system prompt: %w", err)
+	}
+
+	s.systemPromptHash = fmt.Sprintf("%x", sha256.Sum256([]byte(systemPrompt)))
 
 	// Start a new chat session
+	chat := gollm.NewValidatingChat(s.LLM.StartChat(systemPrompt, s.Model), maxResponseRegenerations, s.validateToolNames)
+	s.toolOutputsPerQuery = nil
 	s.llmChat = gollm.NewRetryChat(
-		s.LLM.StartChat(systemPrompt, s.Model),
+		chat,
 		gollm.RetryConfig{
 			MaxAttempts:    3,
 			InitialBackoff: 10 * time.Second,
@@ -307,38 +804,65 @@ func (s *Agent) Init(ctx context.Context) error {
 			Jitter:         true,
 		},
 	)
-	err = s.llmChat.Initialize(s.Session.ChatMessageStore.ChatMessages())
+	if s.HistoryCompressor != nil {
+		compressed, err := s.HistoryCompressor.Compress(ctx, history)
+		if err != nil {
+			klog.Warningf("compressing the chat history, replaying it in full: %v", err)
+		} else {
+			history = compressed
+		}
+	}
+	err = s.llmChat.Initialize(history)
 	if err != nil {
 		return fmt.Errorf("initializing chat session: %w", err)
 	}
+	return nil
+}
 
-	if s.MCPClientEnabled {
-		if err := s.InitializeMCPClient(ctx); err != nil {
-			klog.Errorf("Failed to initialize MCP client: %v", err)
-			return fmt.Errorf("failed to initialize MCP client: %w", err)
-		}
+// setFunctionDefinitions registers the agent's tools with the LLM chat.
+func (s *Agent) setFunctionDefinitions() error {
+	if s.EnableToolUseShim {
+		return nil
+	}
+	var functionDefinitions []*gollm.FunctionDefinition
+	for _, tool := range s.Tools.AllTools() {
+		functionDefinitions = append(functionDefinitions, tool.FunctionDefinition())
+	}
+	// Sort function definitions to help KV cache reuse
+	sort.Slice(functionDefinitions, func(i, j int) bool {
+		return functionDefinitions[i].Name < functionDefinitions[j].Name
+	})
+	functionDefinitions = gollm.MinimizeFunctionDefinitions(functionDefinitions, s.ToolVerbosity)
+	if err := s.llmChat.SetFunctionDefinitions(functionDefinitions); err != nil {
+		return fmt.Errorf("setting function definitions: %w", err)
+	}
+	return nil
+}
 
-		// Update MCP status in session
-		if err := s.UpdateMCPStatus(ctx, s.MCPClientEnabled); err != nil {
-			klog.Warningf("Failed to update MCP status: %v", err)
-		}
+// refreshClusterContext re-collects the cluster snapshot if it is stale
+// and restarts the chat so the system prompt reflects the new snapshot.
+func (c *Agent) refreshClusterContext(ctx context.Context) {
+	if !c.EnableClusterContext {
+		return
 	}
+	log := klog.FromContext(ctx)
 
-	if !s.EnableToolUseShim {
-		var functionDefinitions []*gollm.FunctionDefinition
-		for _, tool := range s.Tools.AllTools() {
-			functionDefinitions = append(functionDefinitions, tool.FunctionDefinition())
-		}
-		// Sort function definitions to help KV cache reuse
-		sort.Slice(functionDefinitions, func(i, j int) bool {
-			return functionDefinitions[i].Name < functionDefinitions[j].Name
-		})
-		if err := s.llmChat.SetFunctionDefinitions(functionDefinitions); err != nil {
-			return fmt.Errorf("setting function definitions: %w", err)
-		}
+	updated, err := c.updateClusterSnapshot(ctx)
+	if err != nil {
+		log.Error(err, "Failed to refresh cluster context")
+		return
+	}
+	if !updated {
+		return
 	}
 
-	return nil
+	if err := c.startChat(ctx); err != nil {
+		log.Error(err, "Failed to restart chat with refreshed cluster context")
+		return
+	}
+	if err := c.setFunctionDefinitions(); err != nil {
+		log.Error(err, "Failed to set function definitions after refreshing cluster context")
+	}
 }
 
 func (c *Agent) Close() error {
@@ -378,6 +902,29 @@ func (c *Agent) Close() error {
 	return nil
 }
 
+// filterContent masks the secrets in text, if redaction is enabled, and
+// applies the content filters to it.
+func (c *Agent) filterContent(ctx context.Context, kind tools.ContentKind, text string) (string, error) {
+	if c.redactor != nil {
+		text = c.redactor.RedactString(text)
+	}
+	return tools.FilterContent(ctx, c.ContentFilters, kind, text)
+}
+
+// checkLocalFiles returns an error if command, which reads or writes files on
+// the machine the agent runs on, is disabled.
+func (c *Agent) checkLocalFiles(command string) error {
+	if c.DisableLocalFiles {
+		return fmt.Errorf("%s is not available in this user interface, as it uses files on the machine kubectl-ai runs on", command)
+	}
+	return nil
+}
+
+// closeOutput closes the output channel, if it isn't closed already.
+func (c *Agent) closeOutput() {
+	c.closeOutputOnce.Do(func() { close(c.Output) })
+}
+
 func (c *Agent) LastErr() error {
 	return c.lastErr
 }
@@ -391,12 +938,25 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 
 	// Save unexpected error and return it in for RunOnce mode
 	log.Info("Starting agent loop", "initialQuery", initialQuery, "runOnce", c.RunOnce)
+	c.loopDone = make(chan struct{})
 	go func() {
+		defer close(c.loopDone)
+		defer c.notifications.Wait()
+		defer c.notifyQueryDone()
 		// If initialQuery is empty, try to use the one from the struct
 		if initialQuery == "" {
 			initialQuery = c.InitialQuery
 		}
 
+		if initialQuery != "" {
+			filtered, err := c.filterContent(ctx, tools.ContentKindUserInput, initialQuery)
+			if err != nil {
+				log.Error(err, "error filtering the query")
+				c.setAgentState(api.AgentStateDone)
+				c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: the query was not sent: "+err.Error())
+			}
+			initialQuery = filtered
+		}
 		if initialQuery != "" {
 			c.addMessage(api.MessageSourceUser, api.MessageTypeText, initialQuery)
 			answer, handled, err := c.handleMetaQuery(ctx, initialQuery)
@@ -409,7 +969,7 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 				// initialQuery is the 'exit' or 'quit' metaquery
 				if c.AgentState() == api.AgentStateExited {
 					c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
-					close(c.Output)
+					c.closeOutput()
 					return
 				}
 				// we handled the meta query, so we don't need to run the agentic loop
@@ -418,9 +978,11 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 				c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
 			} else {
 				// Start the agentic loop with the initial query
+				initialQuery = c.substituteVariables(initialQuery)
+				c.routeQuery(ctx, initialQuery)
 				c.setAgentState(api.AgentStateRunning)
-				c.currIteration = 0
-				c.currChatContent = []any{initialQuery}
+				c.startQueryBudget()
+				c.currChatContent = append(c.retrieveDocs(ctx, initialQuery), initialQuery)
 				c.pendingFunctionCalls = []ToolCallAnalysis{}
 			}
 		}
@@ -430,6 +992,21 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 			log.Info("Agent loop iteration", "state", c.AgentState())
 			switch c.AgentState() {
 			case api.AgentStateIdle, api.AgentStateDone:
+				// Run the next step of the runbook instead of waiting for input
+				if prompt, ok := c.nextRunbookStep(ctx); ok {
+					prompt = c.substituteVariables(prompt)
+					c.addMessage(api.MessageSourceUser, api.MessageTypeText, prompt)
+					c.refreshClusterContext(ctx)
+					c.routeQuery(ctx, prompt)
+					c.setAgentState(api.AgentStateRunning)
+					c.startQueryBudget()
+					c.malformedCallRetries = 0
+					c.currChatContent = append(c.retrieveDocs(ctx, prompt), prompt)
+					c.pendingFunctionCalls = []ToolCallAnalysis{}
+					continue
+				}
+				c.notifyQueryDone()
+				c.saveSession(ctx)
 				// In RunOnce mode, we are done, so exit
 				if c.RunOnce {
 					log.Info("RunOnce mode, exiting agent loop")
@@ -438,78 +1015,147 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 				}
 				log.Info("initiating user input")
 				c.addMessage(api.MessageSourceAgent, api.MessageTypeUserInputRequest, ">>>")
-				select {
-				case <-ctx.Done():
+				var ok bool
+				if userInput, ok = c.readInput(ctx); !ok {
 					log.Info("Agent loop done")
 					return
-				case userInput = <-c.Input:
-					log.Info("Received input from channel", "userInput", userInput)
-					if userInput == io.EOF {
-						log.Info("Agent loop done, EOF received")
-						c.setAgentState(api.AgentStateExited)
-						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "It has been a pleasure assisting you. Have a great day!")
-						return
-					}
+				}
+				log.Info("Received input from channel", "userInput", userInput)
+				if userInput == io.EOF {
+					log.Info("Agent loop done, EOF received")
+					c.setAgentState(api.AgentStateExited)
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "It has been a pleasure assisting you. Have a great day!")
+					return
+				}
 
-					if sessionPickerResp, ok := userInput.(*api.SessionPickerResponse); ok {
-						if sessionPickerResp.Cancelled {
-							continue
-						}
-						if err := c.LoadSession(sessionPickerResp.SessionID); err != nil {
-							log.Error(err, "error loading session")
-							c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error loading session: "+err.Error())
-						} else {
-							c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Switched to session %s", sessionPickerResp.SessionID))
-						}
+				if cancel, ok := userInput.(*api.CancelToolCall); ok {
+					log.Info("Tool call to cancel is not running", "id", cancel.ID)
+					continue
+				}
+				if sessionPickerResp, ok := userInput.(*api.SessionPickerResponse); ok {
+					if sessionPickerResp.Cancelled {
 						continue
 					}
+					if err := c.LoadSession(sessionPickerResp.SessionID); err != nil {
+						log.Error(err, "error loading session")
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error loading session: "+err.Error())
+					} else {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Switched to session %s", sessionPickerResp.SessionID))
+					}
+					continue
+				}
 
-					query, ok := userInput.(*api.UserInputResponse)
-					if !ok {
-						log.Error(nil, "Received unexpected input from channel", "userInput", userInput)
-						return
+				query, ok := userInput.(*api.UserInputResponse)
+				if !ok {
+					log.Error(nil, "Received unexpected input from channel", "userInput", userInput)
+					return
+				}
+				if strings.TrimSpace(query.Query) == "" && len(query.Attachments) == 0 {
+					log.Info("No query provided, skipping agentic loop")
+					continue
+				}
+				filtered, err := c.filterContent(ctx, tools.ContentKindUserInput, query.Query)
+				if err != nil {
+					log.Error(err, "error filtering the query")
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: the query was not sent: "+err.Error())
+					continue
+				}
+				query.Query = filtered
+				images := query.Images
+				if path, question, ok := parseImageQuery(query.Query); ok {
+					if err := c.checkLocalFiles("image <path>"); err != nil {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error()+"; paste the image into the input instead")
+						continue
 					}
-					if strings.TrimSpace(query.Query) == "" {
-						log.Info("No query provided, skipping agentic loop")
+					image, err := loadImage(path)
+					if err != nil {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
 						continue
 					}
+					images = append(images, image)
+					query.Query = question
+					if query.Query == "" {
+						query.Query = defaultImageQuery
+					}
+				}
+				if err := validateImages(images); err != nil {
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+					continue
+				}
+				if path, ok := parseAttachQuery(query.Query); ok {
 					c.addMessage(api.MessageSourceUser, api.MessageTypeText, query.Query)
-					// we don't need the agentic loop for meta queries
-					// for ex. model, tools, etc.
-					answer, handled, err := c.handleMetaQuery(ctx, query.Query)
+					if err := c.checkLocalFiles("attach <path>"); err != nil {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error()+"; upload the file with the 📎 button instead")
+						continue
+					}
+					attachment, err := loadAttachment(path)
 					if err != nil {
-						log.Error(err, "error handling meta query")
-						c.setAgentState(api.AgentStateDone)
-						c.pendingFunctionCalls = []ToolCallAnalysis{}
 						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
 						continue
 					}
-					if handled {
-						// metaquery set the state to 'Exited', so we should exit
-						if c.AgentState() == api.AgentStateExited {
-							c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
-							close(c.Output)
-							return
-						}
-						// metaquery set up an interactive picker, wait for response
-						if c.AgentState() == api.AgentStateWaitingForInput {
-							continue
-						}
-						// we handled the meta query, so we don't need to run the agentic loop
-						c.setAgentState(api.AgentStateDone)
-						c.pendingFunctionCalls = []ToolCallAnalysis{}
-						if answer != "" {
-							c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
-						}
+					query.Attachments = append(query.Attachments, attachment)
+					query.Query = ""
+				}
+				if len(query.Attachments) > 0 {
+					if err := c.attachFiles(ctx, query.Attachments); err != nil {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
 						continue
 					}
-
-					c.setAgentState(api.AgentStateRunning)
-					c.currIteration = 0
-					c.currChatContent = []any{query.Query}
+					if strings.TrimSpace(query.Query) == "" {
+						continue
+					}
+				}
+				c.addMessage(api.MessageSourceUser, api.MessageTypeText, query.Query)
+				// we don't need the agentic loop for meta queries
+				// for ex. model, tools, etc.
+				answer, handled, err := c.handleMetaQuery(ctx, query.Query)
+				if err != nil {
+					log.Error(err, "error handling meta query")
+					c.setAgentState(api.AgentStateDone)
+					c.pendingFunctionCalls = []ToolCallAnalysis{}
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+					continue
+				}
+				if handled {
+					// metaquery set the state to 'Exited', so we should exit
+					if c.AgentState() == api.AgentStateExited {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
+						c.closeOutput()
+						return
+					}
+					// metaquery set up an interactive picker, wait for response
+					if c.AgentState() == api.AgentStateWaitingForInput {
+						continue
+					}
+					// we handled the meta query, so we don't need to run the agentic loop
+					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
-					log.Info("Set agent state to running, will process agentic loop", "currIteration", c.currIteration, "currChatContent", len(c.currChatContent))
+					if answer != "" {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, answer)
+					}
+					continue
 				}
+
+				query.Query = c.substituteVariables(query.Query)
+				// Refreshing the cluster context and switching the model replay
+				// the history, which can't pair the results of interrupted
+				// tool calls with their calls
+				if len(c.interruptedToolResults) == 0 {
+					c.refreshClusterContext(ctx)
+					c.routeQuery(ctx, query.Query)
+				}
+
+				c.setAgentState(api.AgentStateRunning)
+				c.startQueryBudget()
+				c.malformedCallRetries = 0
+				c.currChatContent = append(c.interruptedToolResults, c.attachImages(images)...)
+				c.currChatContent = append(c.currChatContent, c.pendingAttachments...)
+				c.currChatContent = append(c.currChatContent, c.retrieveDocs(ctx, query.Query)...)
+				c.currChatContent = append(c.currChatContent, query.Query)
+				c.interruptedToolResults = nil
+				c.pendingAttachments = nil
+				c.pendingFunctionCalls = []ToolCallAnalysis{}
+				log.Info("Set agent state to running, will process agentic loop", "currIteration", c.currIteration, "currChatContent", len(c.currChatContent))
 			case api.AgentStateWaitingForInput:
 				// In RunOnce mode, if we need user choice, exit with error
 				if c.RunOnce {
@@ -518,66 +1164,81 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: RunOnce mode cannot handle user choice requests")
 					return
 				}
-				select {
-				case <-ctx.Done():
+				var ok bool
+				if userInput, ok = c.readInput(ctx); !ok {
 					log.Info("Agent loop done")
 					return
-				case userInput = <-c.Input:
-					if userInput == io.EOF {
-						log.Info("Agent loop done, EOF received")
-						c.setAgentState(api.AgentStateExited)
-						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "It has been a pleasure assisting you. Have a great day!")
-						return
-					}
+				}
+				if userInput == io.EOF {
+					log.Info("Agent loop done, EOF received")
+					c.setAgentState(api.AgentStateExited)
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "It has been a pleasure assisting you. Have a great day!")
+					return
+				}
 
-					switch response := userInput.(type) {
-					case *api.SessionPickerResponse:
-						if response.Cancelled {
-							c.setAgentState(api.AgentStateDone)
-							continue
-						}
-						if err := c.LoadSession(response.SessionID); err != nil {
-							log.Error(err, "error loading session")
-							c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error loading session: "+err.Error())
-						} else {
-							c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Switched to session %s", response.SessionID))
-						}
+				switch response := userInput.(type) {
+				case *api.CancelToolCall:
+					log.Info("Tool call to cancel is not running", "id", response.ID)
+					continue
+
+				case *api.SessionPickerResponse:
+					if response.Cancelled {
 						c.setAgentState(api.AgentStateDone)
 						continue
+					}
+					if err := c.LoadSession(response.SessionID); err != nil {
+						log.Error(err, "error loading session")
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error loading session: "+err.Error())
+					} else {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Switched to session %s", response.SessionID))
+					}
+					c.setAgentState(api.AgentStateDone)
+					continue
 
-					case *api.UserChoiceResponse:
-						dispatchToolCalls := c.handleChoice(ctx, response)
-						if dispatchToolCalls {
-							if err := c.DispatchToolCalls(ctx); err != nil {
-								log.Error(err, "error dispatching tool calls")
-								c.setAgentState(api.AgentStateDone)
-								c.pendingFunctionCalls = []ToolCallAnalysis{}
-								c.Session.LastModified = time.Now()
-								c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
-								// In RunOnce mode, exit on tool execution error
-								if c.RunOnce {
-									c.setAgentState(api.AgentStateExited)
-									c.lastErr = err
-									return
-								}
-								continue
-							}
-							// Clear pending function calls after execution
-							c.pendingFunctionCalls = []ToolCallAnalysis{}
-							c.setAgentState(api.AgentStateRunning)
-							c.currIteration = c.currIteration + 1
-						} else {
-							// if user has declined, we are done with this iteration
-							c.currIteration = c.currIteration + 1
+				case *api.ContinueResponse:
+					if !c.limitChoicePending {
+						log.Info("No query to continue")
+						continue
+					}
+					c.handleLimitChoice(response)
+					continue
+
+				case *api.UserChoiceResponse:
+					if c.limitChoicePending {
+						log.Info("Ignoring a choice while asking whether to continue")
+						continue
+					}
+					dispatchToolCalls := c.handleChoice(ctx, response)
+					if dispatchToolCalls {
+						if err := c.DispatchToolCalls(ctx); err != nil {
+							log.Error(err, "error dispatching tool calls")
+							c.setAgentState(api.AgentStateDone)
 							c.pendingFunctionCalls = []ToolCallAnalysis{}
-							c.setAgentState(api.AgentStateRunning)
 							c.Session.LastModified = time.Now()
+							c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+							// In RunOnce mode, exit on tool execution error
+							if c.RunOnce {
+								c.setAgentState(api.AgentStateExited)
+								c.lastErr = err
+								return
+							}
+							continue
 						}
-
-					default:
-						log.Error(nil, "Received unexpected input from channel", "userInput", userInput)
-						return
+						// Clear pending function calls after execution
+						c.pendingFunctionCalls = []ToolCallAnalysis{}
+						c.setAgentState(api.AgentStateRunning)
+						c.currIteration = c.currIteration + 1
+					} else {
+						// if user has declined, we are done with this iteration
+						c.currIteration = c.currIteration + 1
+						c.pendingFunctionCalls = []ToolCallAnalysis{}
+						c.setAgentState(api.AgentStateRunning)
+						c.Session.LastModified = time.Now()
 					}
+
+				default:
+					log.Error(nil, "Received unexpected input from channel", "userInput", userInput)
+					return
 				}
 			case api.AgentStateRunning:
 				// Agent is running, don't wait for input, just continue to process the agentic loop
@@ -590,19 +1251,26 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 			if c.AgentState() == api.AgentStateRunning {
 				log.Info("Processing agentic loop", "currIteration", c.currIteration, "maxIterations", c.MaxIterations, "currChatContentLen", len(c.currChatContent))
 
-				if c.currIteration >= c.MaxIterations {
-					c.setAgentState(api.AgentStateDone)
-					c.pendingFunctionCalls = []ToolCallAnalysis{}
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "Maximum number of iterations reached.")
+				if reason := c.queryLimitReached(); reason != "" {
+					c.handleQueryLimit(reason)
 					continue
 				}
 
 				// we run the agentic loop for one iteration
-				stream, err := c.llmChat.SendStreaming(ctx, c.currChatContent...)
+				turnCtx := c.startTurn(ctx)
+				stream, err := c.sendStreaming(turnCtx, c.currChatContent)
+				if err == nil {
+					stream = c.continueTruncated(turnCtx, stream)
+				}
 				if err != nil {
+					if c.interrupted(turnCtx) {
+						c.endInterruptedTurn("")
+						continue
+					}
 					log.Error(err, "error sending streaming LLM response")
 					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, llmErrorMessage(err))
 					c.lastErr = err
 					continue
 				}
@@ -629,9 +1297,15 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 				// Process each part of the response
 				var functionCalls []gollm.FunctionCall
 
-				// accumulator for streamed text
+				// accumulator for streamed text, sent as deltas of the message streamID
 				var streamedText string
+				streamID := uuid.New().String()
 				var llmError error
+				// usage metadata is typically complete only in the last chunk of the stream
+				var usageMetadata any
+				// as is the reason the LLM stopped
+				var stopReason gollm.StopReason
+				var modelVersion string
 
 				for response, err := range stream {
 					if err != nil {
@@ -647,6 +1321,15 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						break
 					}
 					// klog.Infof("response: %+v", response)
+					if m := response.UsageMetadata(); m != nil {
+						usageMetadata = m
+					}
+					if reason := gollm.ResponseStopReason(response); reason != gollm.StopReasonUnknown {
+						stopReason = reason
+					}
+					if version := gollm.ResponseModelVersion(response); version != "" {
+						modelVersion = version
+					}
 
 					if len(response.Candidates()) == 0 {
 						llmError = fmt.Errorf("no candidates in response")
@@ -663,6 +1346,9 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						if text, ok := part.AsText(); ok {
 							log.Info("text response", "text", text)
 							streamedText += text
+							if text != "" {
+								c.sendTextDelta(streamID, text)
+							}
 						}
 
 						// Check if it's a function call
@@ -672,19 +1358,31 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						}
 					}
 				}
+				c.recordUsage(usageMetadata)
+				c.trackContext(usageMetadata)
+				if c.interrupted(turnCtx) {
+					c.endInterruptedTurn(streamedText)
+					continue
+				}
 				if llmError != nil {
 					log.Error(llmError, "error streaming LLM response")
 					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
-					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+llmError.Error())
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, llmErrorMessage(llmError))
 					c.lastErr = llmError
 					continue
 				}
 
-				log.Info("streamedText", "streamedText", streamedText)
+				log.Info("streamedText", "streamedText", streamedText, "stopReason", stopReason, "modelVersion", modelVersion)
 
 				if streamedText != "" {
-					c.addMessage(api.MessageSourceModel, api.MessageTypeText, streamedText)
+					c.storeMessage(&api.Message{
+						ID:        streamID,
+						Source:    api.MessageSourceModel,
+						Type:      api.MessageTypeText,
+						Payload:   streamedText,
+						Timestamp: time.Now(),
+					})
 				}
 				// If no function calls to be made, we're done
 				if len(functionCalls) == 0 {
@@ -694,6 +1392,9 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					c.currIteration = 0
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
 					log.Info("Agent task completed, transitioning to done state")
+					if stopReason == gollm.StopReasonMaxTokens {
+						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "The response was cut off at the maximum output length of the model.")
+					}
 					if streamedText == "" {
 						// If no tool calls to be made and we do not have a response from the LLM
 						// we should let the user know for better diagnostics.
@@ -701,10 +1402,33 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						log.Info("Empty response with no tool calls from LLM.")
 						c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "Empty response from LLM")
 					}
+					c.maybeNameSession(ctx)
+					continue
+				}
+
+				if !c.EnableToolUseShim {
+					functionCalls = c.toolCallIDs.register(functionCalls)
+				}
+				validCalls, err := c.retryMalformedCalls(functionCalls)
+				if err != nil {
+					log.Error(err, "giving up on malformed tool calls")
+					c.setAgentState(api.AgentStateDone)
+					c.pendingFunctionCalls = []ToolCallAnalysis{}
+					// Send the answers to the calls with the next query
+					c.interruptedToolResults = c.currChatContent
+					c.currChatContent = nil
+					c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "Error: "+err.Error())
+					c.lastErr = err
+					continue
+				}
+				if len(validCalls) == 0 {
+					// Only malformed calls, ask the LLM to send them again
+					c.pendingFunctionCalls = []ToolCallAnalysis{}
+					c.currIteration = c.currIteration + 1
 					continue
 				}
 
-				toolCallAnalysisResults, err := c.analyzeToolCalls(ctx, functionCalls)
+				toolCallAnalysisResults, err := c.analyzeToolCalls(ctx, validCalls)
 				if err != nil {
 					log.Error(err, "error analyzing tool calls")
 					c.setAgentState(api.AgentStateDone)
@@ -715,6 +1439,15 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					continue
 				}
 
+				if c.ReadOnly {
+					toolCallAnalysisResults = c.rejectReadOnlyViolations(toolCallAnalysisResults)
+					if len(toolCallAnalysisResults) == 0 {
+						c.pendingFunctionCalls = []ToolCallAnalysis{}
+						c.currIteration = c.currIteration + 1
+						continue
+					}
+				}
+
 				// mark the tools for dispatching
 				c.pendingFunctionCalls = toolCallAnalysisResults
 
@@ -776,6 +1509,7 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 						commandDescriptions = append(commandDescriptions, call.ParsedToolCall.Description())
 					}
 					confirmationPrompt := "The following commands require your approval to run:\n* " + strings.Join(commandDescriptions, "\n* ")
+					confirmationPrompt += c.toolCallPreviews(ctx)
 					confirmationPrompt += "\n\nDo you want to proceed ?"
 
 					choiceRequest := &api.UserChoiceRequest{
@@ -788,6 +1522,7 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 					}
 					c.setAgentState(api.AgentStateWaitingForInput)
 					c.addMessage(api.MessageSourceAgent, api.MessageTypeUserChoiceRequest, choiceRequest)
+					c.notifyApprovalRequired(commandDescriptions)
 					// Request input from the user by sending a message on the output channel.
 					// Remaining part of the loop will be now resumed when we receive a choice input
 					// from the user.
@@ -795,7 +1530,12 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 				}
 
 				// we are here means we are in the clear to dispatch the tool calls
-				if err := c.DispatchToolCalls(ctx); err != nil {
+				if err := c.DispatchToolCalls(turnCtx); err != nil {
+					if c.interrupted(turnCtx) {
+						c.interruptedToolResults = c.currChatContent
+						c.endInterruptedTurn("")
+						continue
+					}
 					log.Error(err, "error dispatching tool calls")
 					c.setAgentState(api.AgentStateDone)
 					c.pendingFunctionCalls = []ToolCallAnalysis{}
@@ -814,6 +1554,374 @@ func (c *Agent) Run(ctx context.Context, initialQuery string) error {
 	return nil
 }
 
+// CancelGeneration cancels the in-flight LLM call and tool calls, if any.
+// The agent keeps any partial output and goes back to waiting for user input.
+// It returns false if the agent was not running.
+func (c *Agent) CancelGeneration() bool {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	if c.cancelTurn == nil || c.AgentState() != api.AgentStateRunning {
+		return false
+	}
+	c.cancelTurn()
+	return true
+}
+
+// errToolCallCancelled is the cause of the cancellation of the tool calls
+// the user killed.
+var errToolCallCancelled = errors.New("cancelled by the user")
+
+// CancelToolCalls kills the running tool calls, if any. Unlike
+// CancelGeneration, the agent goes on: the LLM is told the calls were
+// cancelled by the user, with their output so far. It returns false if no
+// tool calls were running.
+func (c *Agent) CancelToolCalls() bool {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	for _, cancel := range c.runningToolCalls {
+		cancel(errToolCallCancelled)
+	}
+	return len(c.runningToolCalls) > 0
+}
+
+// CancelToolCall kills the running tool call whose request message has the
+// given ID, like CancelToolCalls. It returns false if the call isn't running.
+func (c *Agent) CancelToolCall(id string) bool {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	cancel, ok := c.runningToolCalls[id]
+	if ok {
+		cancel(errToolCallCancelled)
+	}
+	return ok
+}
+
+// watchToolCallCancellations reads the Input channel while tool calls run,
+// killing the calls named by api.CancelToolCall messages. Other input is
+// queued in deferredInputs, in order, once the returned function is called
+// when the calls are done, for the agent loop to read before the channel.
+func (c *Agent) watchToolCallCancellations(ctx context.Context) func() {
+	done := make(chan struct{})
+	deferred := make(chan []any)
+	go func() {
+		var inputs []any
+		for {
+			select {
+			case <-done:
+				deferred <- inputs
+				return
+			case input := <-c.Input:
+				if cancel, ok := input.(*api.CancelToolCall); ok {
+					if !c.CancelToolCall(cancel.ID) {
+						klog.FromContext(ctx).Info("Tool call to cancel is not running", "id", cancel.ID)
+					}
+					continue
+				}
+				inputs = append(inputs, input)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		c.deferredInputs = append(c.deferredInputs, <-deferred...)
+	}
+}
+
+// readInput returns the next input of the agent loop: the inputs deferred
+// while tool calls ran, then those of the Input channel. It returns false
+// once ctx is done.
+func (c *Agent) readInput(ctx context.Context) (any, bool) {
+	if len(c.deferredInputs) > 0 {
+		input := c.deferredInputs[0]
+		c.deferredInputs = c.deferredInputs[1:]
+		return input, true
+	}
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case input := <-c.Input:
+		return input, true
+	}
+}
+
+// RunningToolCalls returns the IDs of the request messages of the running
+// tool calls.
+func (c *Agent) RunningToolCalls() []string {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	return slices.Collect(maps.Keys(c.runningToolCalls))
+}
+
+// startToolCall returns the context of the tool call whose request message
+// has the given ID, cancelled by CancelToolCalls, and the function to call
+// once it is done.
+func (c *Agent) startToolCall(ctx context.Context, id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	c.turnMu.Lock()
+	if c.runningToolCalls == nil {
+		c.runningToolCalls = map[string]context.CancelCauseFunc{}
+	}
+	c.runningToolCalls[id] = cancel
+	c.turnMu.Unlock()
+	return ctx, func() {
+		c.turnMu.Lock()
+		delete(c.runningToolCalls, id)
+		c.turnMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelledToolOutput is the output of a tool call the user cancelled, with
+// the output of the command so far.
+func cancelledToolOutput(output any) any {
+	result, ok := output.(*sandbox.ExecResult)
+	if !ok || result == nil {
+		return &sandbox.ExecResult{Error: errToolCallCancelled.Error()}
+	}
+	cancelled := *result
+	cancelled.Error = errToolCallCancelled.Error()
+	return &cancelled
+}
+
+// startTurn returns the context for one iteration of the agentic loop.
+// It is cancelled by CancelGeneration.
+func (c *Agent) startTurn(ctx context.Context) context.Context {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	if c.cancelTurn != nil {
+		c.cancelTurn()
+	}
+	turnCtx, cancel := context.WithCancel(c.sessionContext(ctx))
+	c.cancelTurn = cancel
+	return turnCtx
+}
+
+// sessionContext attributes the journal events recorded in ctx, e.g. the LLM
+// requests and tool calls, to the current session.
+func (c *Agent) sessionContext(ctx context.Context) context.Context {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.Session == nil {
+		return ctx
+	}
+	return journal.ContextWithSession(ctx, c.Session.ID)
+}
+
+// interrupted reports whether the turn was cancelled, by the user or because
+// the agent is shutting down. Either way, the partial response is kept in the
+// session.
+func (c *Agent) interrupted(turnCtx context.Context) bool {
+	return turnCtx.Err() != nil
+}
+
+// endInterruptedTurn keeps the partial response of an interrupted turn and
+// returns to waiting for user input.
+func (c *Agent) endInterruptedTurn(partialText string) {
+	if partialText != "" {
+		c.addMessage(api.MessageSourceModel, api.MessageTypeText, partialText)
+	}
+	c.setAgentState(api.AgentStateDone)
+	c.currIteration = 0
+	c.currChatContent = []any{}
+	c.pendingFunctionCalls = []ToolCallAnalysis{}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, "Interrupted.")
+	c.stopRunbook()
+}
+
+// sendStreaming sends contents to the LLM, with the tool call results given
+// the IDs of the provider. While the request doesn't fit in the context window
+// of the model, the outputs of the oldest tool calls are dropped from the
+// history of the chat, twice as many each time, and the request is sent again.
+// Likewise, if the model isn't found, the request is sent once more to a
+// fallback model, unless it sends tool call results: the history is replayed
+// to the fallback model as text, without the tool calls the results answer,
+// so the fallback waits for the next query. Repairs the chat makes to its
+// history before sending it are shown to the user.
+func (c *Agent) sendStreaming(ctx context.Context, contents []any) (gollm.ChatResponseIterator, error) {
+	if !c.EnableToolUseShim {
+		contents = c.toolCallIDs.resolve(contents)
+	}
+	c.pruneToolOutputs(ctx, contents)
+	ctx = gollm.WithHistoryRepairReporter(ctx, func(provider string, repairs []string) {
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("The chat history was repaired before it was sent to %s: %s.", provider, strings.Join(repairs, "; ")))
+	})
+
+	dropCount := 1
+	fellBack := false
+	recoverFrom := func(err error) bool {
+		if c.dropToolOutputs(ctx, err, dropCount) {
+			dropCount *= 2
+			return true
+		}
+		if !fellBack && !slices.ContainsFunc(contents, isFunctionCallResult) && c.fallBackModel(ctx, err) {
+			fellBack = true
+			return true
+		}
+		return false
+	}
+	send := func() (gollm.ChatResponseIterator, error) {
+		for {
+			stream, err := c.llmChat.SendStreaming(ctx, contents...)
+			if err == nil || !recoverFrom(err) {
+				return stream, err
+			}
+		}
+	}
+
+	stream, err := send()
+	if err != nil {
+		return nil, err
+	}
+	c.toolCallIDs.sent()
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		received := false
+		for {
+			retry := false
+			for response, err := range stream {
+				// Only retry if nothing was received, the response would be
+				// sent twice otherwise
+				if err != nil && !received && recoverFrom(err) {
+					retry = true
+					break
+				}
+				received = true
+				if !yield(response, err) {
+					return
+				}
+			}
+			if !retry {
+				return
+			}
+			if stream, err = send(); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}, nil
+}
+
+// continuationPrompt asks the LLM to continue a response cut off at its
+// output token limit.
+const continuationPrompt = "Your previous response was cut off because it reached the maximum output length. Continue exactly where it stopped, without repeating anything or adding an introduction."
+
+// continueTruncated asks the LLM to continue the responses of stream cut off
+// at its output token limit, up to MaxContinuations times, and streams the
+// pieces as a single response. Responses calling tools aren't continued, their
+// truncated calls are sent again by retryMalformedCalls. The continuations go
+// through sendStreaming, to recover from context length errors too.
+func (c *Agent) continueTruncated(ctx context.Context, stream gollm.ChatResponseIterator) gollm.ChatResponseIterator {
+	if c.MaxContinuations <= 0 {
+		return stream
+	}
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		for continuation := 0; ; continuation++ {
+			stopReason := gollm.StopReasonUnknown
+			calledTools := false
+			var usageMetadata any
+			for response, err := range stream {
+				if response != nil {
+					if reason := gollm.ResponseStopReason(response); reason != gollm.StopReasonUnknown {
+						stopReason = reason
+					}
+					if m := response.UsageMetadata(); m != nil {
+						usageMetadata = m
+					}
+					calledTools = calledTools || hasFunctionCalls(response)
+				}
+				if !yield(response, err) || err != nil {
+					return
+				}
+			}
+			if stopReason != gollm.StopReasonMaxTokens || calledTools || continuation >= c.MaxContinuations || ctx.Err() != nil {
+				return
+			}
+			// The caller only records the usage of the last piece
+			c.recordUsage(usageMetadata)
+			klog.FromContext(ctx).Info("Continuing a response cut off at the output token limit", "continuation", continuation+1, "maxContinuations", c.MaxContinuations)
+			next, err := c.sendStreaming(ctx, []any{continuationPrompt})
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			stream = next
+		}
+	}
+}
+
+// isFunctionCallResult reports whether content is the result of a tool call.
+func isFunctionCallResult(content any) bool {
+	_, ok := content.(gollm.FunctionCallResult)
+	return ok
+}
+
+// hasFunctionCalls reports whether the first candidate of response calls
+// functions.
+func hasFunctionCalls(response gollm.ChatResponse) bool {
+	candidates := response.Candidates()
+	if len(candidates) == 0 {
+		return false
+	}
+	for _, part := range candidates[0].Parts() {
+		if calls, ok := part.AsFunctionCalls(); ok && len(calls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// dropToolOutputs drops the outputs of the oldest count tool calls from the
+// history of the chat if err is a ContextLengthError, so that the request can
+// be sent again. It reports whether any output was dropped.
+func (c *Agent) dropToolOutputs(ctx context.Context, err error, count int) bool {
+	var contextErr *gollm.ContextLengthError
+	if !errors.As(err, &contextErr) {
+		return false
+	}
+	dropped := gollm.DropToolOutputs(c.llmChat, count)
+	if dropped == 0 {
+		return false
+	}
+	klog.FromContext(ctx).Info("Dropped tool outputs from the history to fit in the context window", "dropped", dropped, "err", err)
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText,
+		fmt.Sprintf("The conversation no longer fit in the context window of the model, so the output of the %d oldest tool calls was dropped from the history sent to the model. Retrying.", dropped))
+	return true
+}
+
+// llmErrorMessage returns the message shown for an error of the LLM, with a
+// hint on what to do about it depending on its category.
+func llmErrorMessage(err error) string {
+	var (
+		authErr      *gollm.AuthError
+		quotaErr     *gollm.QuotaError
+		contextErr   *gollm.ContextLengthError
+		filteredErr  *gollm.ContentFilteredError
+		notFoundErr  *gollm.ModelNotFoundError
+		transientErr *gollm.TransientError
+	)
+	var hint string
+	switch {
+	case errors.As(err, &authErr):
+		hint = "Check the API key or credentials of the LLM provider."
+	case errors.As(err, &quotaErr):
+		hint = "Wait a moment before trying again, or switch to another model."
+	case errors.As(err, &contextErr):
+		hint = "Use `clear` to start over, or switch to a model with a larger context window."
+	case errors.As(err, &filteredErr):
+		hint = "Rephrase the query."
+	case errors.As(err, &notFoundErr):
+		hint = "Use `models` to list the available models and `model <name>` to switch to one of them."
+	case errors.As(err, &transientErr):
+		hint = "Try again in a moment."
+	case errors.Is(err, gollm.ErrStreamLineTooLong):
+		hint = "The LLM streamed a chunk larger than the limit, raise it with --max-stream-line-bytes. The Ollama SDK can't read chunks larger than 512 KB, whatever the limit."
+	}
+	message := "Error: " + err.Error()
+	if hint != "" {
+		message += "\n" + hint
+	}
+	return message
+}
+
 func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer string, handled bool, err error) {
 	switch query {
 	case "clear", "reset":
@@ -823,6 +1931,9 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 			return "Failed to clear the conversation", false, err
 		}
 		c.llmChat.Initialize(c.Session.ChatMessageStore.ChatMessages())
+		c.pendingAttachments = nil
+		c.Session.Usage.ContextTokens = 0
+		c.contextWarned = false
 		c.sessionMu.Unlock()
 		return "Cleared the conversation.", true, nil
 	case "exit", "quit":
@@ -836,11 +1947,14 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 			return "", false, fmt.Errorf("listing models: %w", err)
 		}
 		return "Available models:\n\n  - " + strings.Join(models, "\n  - ") + "\n\n", true, nil
+	case "runbooks":
+		return c.listRunbooks(), true, nil
 	case "tools":
 		return "Available tools:\n\n  - " + strings.Join(c.Tools.Names(), "\n  - ") + "\n\n", true, nil
 	case "session":
-		if c.SessionBackend != "filesystem" {
-			return "Ephemeral session (memory backed). No persistent info available.", true, nil
+		if c.SessionBackend != "filesystem" && !sessions.IsRemoteBackend(c.SessionBackend) {
+			return fmt.Sprintf("Ephemeral session (memory backed). No persistent info available.\n\nTokens: %d total (%d requests)\nEstimated Cost: %s",
+				c.Session.Usage.TotalTokens, c.Session.Usage.Requests, c.Session.Usage.CostString()), true, nil
 		}
 		return fmt.Sprintf("Current session:\n\n%s", c.Session.String()), true, nil
 
@@ -862,22 +1976,143 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 		// Add ```text so markdown doesn't wreck the format
 		availableSessions := "```text"
 		availableSessions += "Available sessions:\n\n"
-		availableSessions += "ID\t\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider\n"
-		availableSessions += "--\t\t\t-------\t\t\t-------------\t\t-----\t\t--------\n"
+		availableSessions += "ID\t\t\tCreated\t\t\tLast Accessed\t\tModel\t\tProvider\tTags\n"
+		availableSessions += "--\t\t\t-------\t\t\t-------------\t\t-----\t\t--------\t----\n"
 
 		for _, session := range sessions {
-			availableSessions += fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n",
+			availableSessions += fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\n",
 				session.ID,
 				session.CreatedAt.Format("2006-01-02 15:04"),
 				session.LastModified.Format("2006-01-02 15:04"),
 				session.ModelID,
-				session.ProviderID)
+				session.ProviderID,
+				strings.Join(session.Tags, ","))
 		}
 		// close the ```text box
 		availableSessions += "```"
 		return availableSessions, true, nil
 	}
 
+	if fields := strings.Fields(query); len(fields) > 0 && (fields[0] == "tag" || fields[0] == "untag") {
+		tags, err := c.updateSessionTags(fields[0] == "tag", fields[1:])
+		if err != nil {
+			return "", false, err
+		}
+		if len(tags) == 0 {
+			return "The session has no tags. Usage: tag <tag>... or untag <tag>...", true, nil
+		}
+		return "Session tags: " + strings.Join(tags, ", "), true, nil
+	}
+
+	if answer, handled, err := c.handleMemoryQuery(ctx, query); handled || err != nil {
+		return answer, handled, err
+	}
+
+	// Only handle the profile subcommands, so that queries like "profile the
+	// memory of web" go to the LLM
+	if fields := strings.Fields(query); len(fields) > 0 && fields[0] == "profile" &&
+		(len(fields) == 1 || slices.Contains([]string{"accept", "reject", "add", "forget"}, fields[1])) {
+		answer, err := c.handleProfileQuery(ctx, query)
+		if err != nil {
+			return "", false, err
+		}
+		return answer, true, nil
+	}
+
+	if fields := strings.Fields(query); c.isModelSwitch(ctx, fields) {
+		var provider string
+		if len(fields) == 3 {
+			provider = fields[2]
+		}
+		if err := c.SwitchModel(ctx, fields[1], provider); err != nil {
+			return "", false, fmt.Errorf("switching model: %w", err)
+		}
+		return fmt.Sprintf("Switched to model `%s` of provider `%s`.", c.Model, c.Provider), true, nil
+	}
+
+	// Only the exact name of a runbook is a command, e.g. "run a pod with
+	// nginx" is a query for the LLM
+	if fields := strings.Fields(query); len(fields) == 2 && fields[0] == "run" && c.Runbooks[fields[1]] != nil {
+		answer, err := c.startRunbook(fields[1])
+		if err != nil {
+			return "", false, err
+		}
+		return answer, true, nil
+	}
+
+	if fields := strings.Fields(query); len(fields) > 0 && fields[0] == "export-script" {
+		if len(fields) > 2 {
+			return "Invalid command. Usage: export-script [path]", true, nil
+		}
+		if err := c.checkLocalFiles("export-script"); err != nil {
+			return err.Error(), true, nil
+		}
+		var path string
+		if len(fields) == 2 {
+			path = fields[1]
+		}
+		answer, err := c.exportScript(path)
+		if err != nil {
+			return "", false, err
+		}
+		return answer, true, nil
+	}
+
+	if fields := strings.Fields(query); len(fields) > 0 && fields[0] == "export-overlay" {
+		if len(fields) > 2 {
+			return "Invalid command. Usage: export-overlay [directory]", true, nil
+		}
+		if err := c.checkLocalFiles("export-overlay"); err != nil {
+			return err.Error(), true, nil
+		}
+		var dir string
+		if len(fields) == 2 {
+			dir = fields[1]
+		}
+		answer, err := c.exportOverlay(dir)
+		if err != nil {
+			return "", false, err
+		}
+		return answer, true, nil
+	}
+
+	// Only "bundle" and "bundle <path>.tar.gz", other queries starting with
+	// bundle go to the LLM
+	if fields := strings.Fields(query); isBundleCommand(fields) {
+		if err := c.checkLocalFiles("bundle"); err != nil {
+			return err.Error(), true, nil
+		}
+		var path string
+		if len(fields) == 2 {
+			path = fields[1]
+		}
+		answer, err := c.exportBundle(path)
+		if err != nil {
+			return "", false, err
+		}
+		return answer, true, nil
+	}
+
+	if query == "fork" || strings.HasPrefix(query, "fork ") {
+		parts := strings.Fields(query)
+		if len(parts) == 1 {
+			return c.forkPoints(), true, nil
+		}
+		n, err := strconv.Atoi(parts[1])
+		if len(parts) != 2 || err != nil {
+			return "Invalid command. Usage: fork <message number>", true, nil
+		}
+		originalID := c.Session.ID
+		forkedID, err := c.ForkSession(n)
+		if err != nil {
+			return "", false, err
+		}
+		if forked := len(c.Session.AllMessages()); forked != n {
+			return fmt.Sprintf("Forked session %s at message %d into session %s, so that the tool calls up to there have their results. Use `resume-session %s` to return to the original conversation.", originalID, forked, forkedID, originalID), true, nil
+		}
+		return fmt.Sprintf("Forked session %s at message %d into session %s. Use `resume-session %s` to return to the original conversation.", originalID, n, forkedID, originalID), true, nil
+	}
+
 	if strings.HasPrefix(query, "resume-session") {
 		parts := strings.Split(query, " ")
 		if len(parts) != 2 {
@@ -893,6 +2128,218 @@ func (c *Agent) handleMetaQuery(ctx context.Context, query string) (answer strin
 	return "", false, nil
 }
 
+// ForkSession creates a new session with the first n messages of the current
+// session and switches to it. The current session is left unchanged.
+func (c *Agent) ForkSession(n int) (string, error) {
+	if _, err := c.SaveSession(); err != nil {
+		return "", fmt.Errorf("failed to save current session: %w", err)
+	}
+
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		return "", fmt.Errorf("failed to create session manager: %w", err)
+	}
+
+	c.sessionMu.Lock()
+	source := c.Session
+	c.sessionMu.Unlock()
+
+	forked, err := manager.ForkSession(source, n)
+	if err != nil {
+		return "", fmt.Errorf("failed to fork session: %w", err)
+	}
+
+	if err := c.LoadSession(forked.ID); err != nil {
+		return "", fmt.Errorf("failed to load forked session: %w", err)
+	}
+	return forked.ID, nil
+}
+
+// sessionNamingQueries is the number of user queries after which the session is named.
+const sessionNamingQueries = 2
+
+// maybeNameSession names the session using the LLM in the background, once
+// the user has made a couple of queries, if the session still has its default name.
+func (c *Agent) maybeNameSession(ctx context.Context) {
+	if !c.AutoNameSessions {
+		return
+	}
+
+	c.sessionMu.Lock()
+	session := c.Session
+	if c.namedSessionID == session.ID || (session.Name != "" && session.Name != sessions.DefaultSessionName(session.ID)) {
+		c.sessionMu.Unlock()
+		return
+	}
+	var transcript strings.Builder
+	queries := 0
+	for _, message := range session.ChatMessageStore.ChatMessages() {
+		text, ok := message.Payload.(string)
+		if !ok || message.Type != api.MessageTypeText {
+			continue
+		}
+		if message.Source == api.MessageSourceUser {
+			queries++
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", message.Source, truncateText(text, 500))
+	}
+	if queries < sessionNamingQueries {
+		c.sessionMu.Unlock()
+		return
+	}
+	c.namedSessionID = session.ID
+	c.sessionMu.Unlock()
+
+	go c.nameSession(ctx, session, transcript.String())
+}
+
+func (c *Agent) nameSession(ctx context.Context, session *api.Session, transcript string) {
+	log := klog.FromContext(ctx)
+
+	prompt := "Write a concise title of at most six words for the following conversation between a user and a Kubernetes assistant. Reply with the title only.\n\n" + transcript
+	response, err := c.LLM.GenerateCompletion(ctx, &gollm.CompletionRequest{Model: c.Model, Prompt: prompt})
+	if err != nil {
+		log.Error(err, "error generating session name")
+		return
+	}
+	c.recordUsage(response.UsageMetadata())
+
+	name, _, _ := strings.Cut(strings.TrimSpace(response.Response()), "\n")
+	name = strings.TrimRight(strings.Trim(name, "\"'*# "), ".")
+	name = truncateText(name, 60)
+	if name == "" {
+		return
+	}
+
+	c.sessionMu.Lock()
+	session.Name = name
+	c.sessionMu.Unlock()
+
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		log.Error(err, "error creating session manager")
+		return
+	}
+	if err := manager.UpdateLastAccessed(session); err != nil {
+		log.Error(err, "error saving session name")
+	}
+}
+
+// updateSessionTags adds (or removes, if add is false) tags to the current
+// session, persists them, and returns the resulting tags.
+func (c *Agent) updateSessionTags(add bool, tags []string) ([]string, error) {
+	c.sessionMu.Lock()
+	session := c.Session
+	for _, tag := range tags {
+		if add {
+			if !slices.Contains(session.Tags, tag) {
+				session.Tags = append(session.Tags, tag)
+			}
+		} else {
+			session.Tags = slices.DeleteFunc(session.Tags, func(t string) bool { return t == tag })
+		}
+	}
+	result := slices.Clone(session.Tags)
+	c.sessionMu.Unlock()
+
+	if len(tags) == 0 {
+		return result, nil
+	}
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session manager: %w", err)
+	}
+	if err := manager.UpdateLastAccessed(session); err != nil {
+		return nil, fmt.Errorf("failed to save session tags: %w", err)
+	}
+	return result, nil
+}
+
+// RecordFeedback rates the answer messageID, or the last answer if messageID
+// is empty, replacing an earlier rating. The rating is saved in the session
+// and recorded in the journal, with the model and prompt that produced it.
+func (c *Agent) RecordFeedback(ctx context.Context, messageID string, rating api.FeedbackRating) (*api.Feedback, error) {
+	if rating != api.FeedbackPositive && rating != api.FeedbackNegative {
+		return nil, fmt.Errorf("invalid rating %q, expected %q or %q", rating, api.FeedbackPositive, api.FeedbackNegative)
+	}
+
+	c.sessionMu.Lock()
+	session := c.Session
+	var answer *api.Message
+	for _, message := range slices.Backward(session.AllMessages()) {
+		isAnswer := message.Source == api.MessageSourceModel && message.Type == api.MessageTypeText
+		if (messageID == "" && isAnswer) || (messageID != "" && message.ID == messageID) {
+			if !isAnswer {
+				c.sessionMu.Unlock()
+				return nil, fmt.Errorf("message %s is not an answer", messageID)
+			}
+			answer = message
+			break
+		}
+	}
+	if answer == nil {
+		c.sessionMu.Unlock()
+		if messageID == "" {
+			return nil, fmt.Errorf("there is no answer to rate yet")
+		}
+		return nil, fmt.Errorf("message %s not found", messageID)
+	}
+	feedback := api.Feedback{
+		MessageID:  answer.ID,
+		Rating:     rating,
+		ProviderID: c.Provider,
+		ModelID:    c.Model,
+		PromptHash: c.systemPromptHash,
+		Timestamp:  time.Now(),
+	}
+	session.Feedback = slices.DeleteFunc(session.Feedback, func(f api.Feedback) bool { return f.MessageID == answer.ID })
+	session.Feedback = append(session.Feedback, feedback)
+	c.sessionMu.Unlock()
+
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session manager: %w", err)
+	}
+	if err := manager.UpdateLastAccessed(session); err != nil {
+		return nil, fmt.Errorf("failed to save feedback: %w", err)
+	}
+	if c.Recorder != nil {
+		if err := c.Recorder.Write(ctx, &journal.Event{
+			Action: journal.ActionFeedback,
+			Payload: &journal.Feedback{
+				SessionID:  session.ID,
+				MessageID:  feedback.MessageID,
+				Rating:     string(feedback.Rating),
+				Provider:   feedback.ProviderID,
+				Model:      feedback.ModelID,
+				PromptHash: feedback.PromptHash,
+			},
+		}); err != nil {
+			klog.Warningf("failed to record feedback in the journal: %v", err)
+		}
+	}
+	return &feedback, nil
+}
+
+// forkPoints lists the messages of the current session by number, for use with `fork <n>`.
+func (c *Agent) forkPoints() string {
+	messages := c.Session.AllMessages()
+	if len(messages) == 0 {
+		return "The session has no messages to fork from."
+	}
+	var sb strings.Builder
+	sb.WriteString("Messages in this session (use `fork <n>` to start a new session with messages 1 to n):\n\n")
+	for i, message := range messages {
+		preview := strings.TrimSpace(fmt.Sprint(message.Payload))
+		if line, _, found := strings.Cut(preview, "\n"); found {
+			preview = line + " ..."
+		}
+		preview = truncateText(preview, 80)
+		fmt.Fprintf(&sb, "  %d. [%s %s] %s\n", i+1, message.Source, message.Type, preview)
+	}
+	return sb.String()
+}
+
 func (c *Agent) NewSession() (string, error) {
 	if _, err := c.SaveSession(); err != nil {
 		return "", fmt.Errorf("failed to save current session: %w", err)
@@ -916,13 +2363,7 @@ func (c *Agent) NewSession() (string, error) {
 	// If we are using a sandbox, we should spin up a new one for the new session
 	if c.Sandbox == "k8s" {
 		sandboxName := fmt.Sprintf("kubectl-ai-sandbox-%s", uuid.New().String()[:8])
-		sandboxImage := c.SandboxImage
-
-		sb, err := sandbox.NewKubernetesSandbox(sandboxName,
-			sandbox.WithKubeconfig(c.Kubeconfig),
-			sandbox.WithImage(sandboxImage),
-		)
-
+		sb, err := sandbox.NewKubernetesSandbox(sandboxName, c.kubernetesSandboxOptions()...)
 		if err != nil {
 			return "", fmt.Errorf("failed to create new sandbox: %w", err)
 		}
@@ -941,9 +2382,14 @@ func (c *Agent) NewSession() (string, error) {
 
 		// Re-bind all tools to the new executor
 		c.Tools = c.Tools.CloneWithExecutor(c.executor)
-
-		c.Tools.RegisterTool(tools.NewBashTool(c.executor))
-		c.Tools.RegisterTool(tools.NewKubectlTool(c.executor))
+		c.registerExecutorTools()
+		if c.contextSwitcher != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := c.contextSwitcher.SetSandbox(ctx, c.executor); err != nil {
+				klog.Warningf("error copying the kubeconfig context to the new sandbox: %v", err)
+			}
+			cancel()
+		}
 		c.sessionMu.Unlock()
 	}
 
@@ -1032,6 +2478,9 @@ func (c *Agent) LoadSession(sessionID string) error {
 
 	c.Session = session
 	c.ChatMessageStore = session.ChatMessageStore
+	if err := c.recoverToolLoop(); err != nil {
+		klog.Errorf("Failed to recover interrupted tool calls: %v", err)
+	}
 	c.Session.Messages = session.ChatMessageStore.ChatMessages()
 	c.Session.LastModified = time.Now()
 
@@ -1039,6 +2488,9 @@ func (c *Agent) LoadSession(sessionID string) error {
 	if c.Session.AgentState == api.AgentStateRunning || c.Session.AgentState == api.AgentStateInitializing {
 		c.Session.AgentState = api.AgentStateIdle
 	}
+	c.interruptedToolResults = nil
+	c.pendingAttachments = nil
+	c.limitChoicePending = false
 
 	if err := manager.UpdateLastAccessed(session); err != nil {
 		return fmt.Errorf("failed to update session metadata: %w", err)
@@ -1079,11 +2531,58 @@ func (c *Agent) ListSessions() ([]api.SessionInfo, error) {
 			CreatedAt:    session.CreatedAt,
 			LastModified: session.LastModified,
 			MessageCount: msgCount,
+			Tags:         session.Tags,
 		}
 	}
 	return sessionInfos, nil
 }
 
+// RenameSession renames the session id, which can be the current session.
+func (c *Agent) RenameSession(id, name string) error {
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		return fmt.Errorf("failed to create session manager: %w", err)
+	}
+
+	c.sessionMu.Lock()
+	session := c.Session
+	if session.ID == id {
+		session.Name = name
+	}
+	c.sessionMu.Unlock()
+
+	if session.ID != id {
+		session, err = manager.FindSessionByID(id)
+		if err != nil {
+			return fmt.Errorf("failed to find session %s: %w", id, err)
+		}
+		session.Name = name
+	}
+	if err := manager.UpdateLastAccessed(session); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession deletes the session id, which can't be the current session.
+func (c *Agent) DeleteSession(id string) error {
+	c.sessionMu.Lock()
+	current := c.Session.ID
+	c.sessionMu.Unlock()
+	if id == current {
+		return fmt.Errorf("cannot delete the current session")
+	}
+
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		return fmt.Errorf("failed to create session manager: %w", err)
+	}
+	if err := manager.DeleteSession(id); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", id, err)
+	}
+	return nil
+}
+
 func (c *Agent) listModels(ctx context.Context) ([]string, error) {
 	if c.availableModels == nil {
 		modelNames, err := c.LLM.ListModels(ctx)
@@ -1095,57 +2594,316 @@ func (c *Agent) listModels(ctx context.Context) ([]string, error) {
 	return c.availableModels, nil
 }
 
+// DispatchToolCalls executes the pending tool calls, running up to
+// MaxParallelToolCalls of them concurrently. Only read-only calls run
+// concurrently: a call that may modify resources runs alone, after the calls
+// requested before it and before the calls requested after it, so that e.g.
+// an apply and the get checking its outcome don't race. Results are added to the
+// conversation in the order the LLM requested the calls, so each result stays
+// paired with its tool-use ID. If a call fails or ctx is cancelled (e.g. the
+// user interrupts), the remaining calls are cancelled. Calls the user kills
+// with CancelToolCalls or api.CancelToolCall don't fail: their results say
+// they were cancelled.
 func (c *Agent) DispatchToolCalls(ctx context.Context) error {
+	ctx = c.runbookContext(c.sessionContext(ctx))
+	stopWatching := c.watchToolCallCancellations(ctx)
+	defer stopWatching()
+	calls := c.pendingFunctionCalls
+	results := make([]*toolCallResult, len(calls))
+
+	// Checkpoint the calls, so they can be recovered from if we crash
+	checkpoint := c.startCheckpoint(calls)
+	defer c.clearCheckpoint(checkpoint)
+
+	var mu sync.Mutex
+	next := 0
+	// flush adds the results that are ready, in request order.
+	flush := func() {
+		for next < len(results) && results[next] != nil {
+			result := results[next]
+			c.currChatContent = append(c.currChatContent, result.content)
+			c.storeMessage(&api.Message{
+				ID:          uuid.New().String(),
+				Source:      api.MessageSourceAgent,
+				Type:        api.MessageTypeToolCallResponse,
+				Payload:     result.payload,
+				Timestamp:   time.Now(),
+				KubeContext: result.kubeContext,
+				ToolCallID:  result.requestID,
+			})
+			c.checkpointResult(checkpoint, next, result)
+			next++
+		}
+	}
+
+	for _, batch := range c.toolCallBatches(calls) {
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(max(c.MaxParallelToolCalls, 1))
+		for _, i := range batch {
+			call := calls[i]
+			g.Go(func() error {
+				result, err := c.dispatchToolCall(gctx, call)
+
+				mu.Lock()
+				defer mu.Unlock()
+				results[i] = result
+				flush()
+				return err
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toolCallResult is the outcome of a single dispatched tool call.
+type toolCallResult struct {
+	kubeContext string
+	// requestID is the ID of the tool call request message.
+	requestID string
+	// payload is shown to the user.
+	payload any
+	// content is sent to the LLM.
+	content any
+}
+
+// toolCallError returns the result for a tool call that failed with err.
+func (c *Agent) toolCallError(call ToolCallAnalysis, kubeContext string, err error) *toolCallResult {
+	if c.EnableToolUseShim {
+		observation := fmt.Sprintf("Result of running %q:\n%v", call.FunctionCall.Name, err)
+		return &toolCallResult{kubeContext: kubeContext, payload: err.Error(), content: observation}
+	}
+	return &toolCallResult{
+		kubeContext: kubeContext,
+		payload:     err.Error(),
+		content: gollm.FunctionCallResult{
+			ID:     call.FunctionCall.ID,
+			Name:   call.FunctionCall.Name,
+			Result: map[string]any{"error": err.Error()},
+		},
+	}
+}
+
+func (c *Agent) dispatchToolCall(ctx context.Context, call ToolCallAnalysis) (result *toolCallResult, err error) {
 	log := klog.FromContext(ctx)
-	// execute all pending function calls
-	for _, call := range c.pendingFunctionCalls {
-		// Only show "Running" message and proceed with execution for non-interactive commands
-		toolDescription := call.ParsedToolCall.Description()
+	if err := ctx.Err(); err != nil {
+		return c.toolCallError(call, "", fmt.Errorf("%s was cancelled: %w", call.FunctionCall.Name, err)), err
+	}
 
-		c.addMessage(api.MessageSourceModel, api.MessageTypeToolCallRequest, toolDescription)
+	toolDescription := call.ParsedToolCall.Description()
+	kubeContext := c.currentKubeContext()
 
-		output, err := call.ParsedToolCall.InvokeTool(ctx, tools.InvokeToolOptions{
-			Kubeconfig: c.Kubeconfig,
-			WorkDir:    c.workDir,
-			Executor:   c.executor,
-		})
+	request := c.addMessageForContext(kubeContext, api.MessageSourceModel, api.MessageTypeToolCallRequest, toolDescription)
+	defer func() {
+		if result != nil {
+			result.requestID = request.ID
+		}
+	}()
+	ctx, done := c.startToolCall(ctx, request.ID)
+	defer done()
+
+	opt := tools.InvokeToolOptions{
+		Kubeconfig:     c.kubeconfig(),
+		WorkDir:        c.workDir,
+		Executor:       c.executor,
+		Timeout:        c.toolTimeout(call.FunctionCall.Name),
+		MaxOutputBytes: c.MaxExecOutputBytes,
+	}
+	stream := c.newToolOutputStream(request.ID, toolDescription)
+	if stream != nil {
+		opt.OutputStream = stream.write
+	}
+	output, err := call.ParsedToolCall.InvokeTool(ctx, opt)
+	if stream != nil {
+		stream.flush()
+	}
+	if errors.Is(context.Cause(ctx), errToolCallCancelled) {
+		// The user killed the command: tell the LLM and go on
+		output, err = cancelledToolOutput(output), nil
+		ctx = context.WithoutCancel(ctx)
+	}
+	if err != nil {
+		log.Error(err, "error executing action", "output", output)
+		// The error may quote the output, e.g. of an MCP server
+		message, filterErr := c.filterContent(ctx, tools.ContentKindToolOutput, err.Error())
+		if filterErr != nil {
+			message = fmt.Sprintf("%s failed, and its error was not sent: %v", call.FunctionCall.Name, filterErr)
+		}
+		return c.toolCallError(call, kubeContext, errors.New(message)), err
+	}
 
+	// Handle timeout message using UI blocks
+	if execResult, ok := output.(*sandbox.ExecResult); ok && execResult != nil && execResult.StreamType == "timeout" {
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "\nTimeout reached after 7 seconds\n")
+	}
+	// Mask secrets and filter before truncating, so that the full output
+	// stored on disk is masked too
+	if c.redactor != nil {
+		output = c.redactor.Redact(output)
+	}
+	if len(c.ContentFilters) > 0 {
+		output, err = tools.FilterOutput(ctx, c.ContentFilters, output)
 		if err != nil {
-			log.Error(err, "error executing action", "output", output)
-			c.addMessage(api.MessageSourceAgent, api.MessageTypeToolCallResponse, err.Error())
-			return err
+			log.Error(err, "error filtering tool output")
+			return c.toolCallError(call, kubeContext, fmt.Errorf("the output was not sent: %w", err)), err
+		}
+	}
+	if c.outputTruncator != nil {
+		policy := tools.TruncateHeadAndTail
+		if p, ok := call.ParsedToolCall.GetTool().(tools.OutputPolicy); ok {
+			policy = p.TruncationPolicy()
+		}
+		output, err = c.outputTruncator.TruncateWithPolicy(output, policy)
+		if err != nil {
+			log.Error(err, "error truncating tool output")
+			return c.toolCallError(call, kubeContext, err), err
+		}
+	}
+
+	// Add the tool call result to maintain conversation flow
+	if c.EnableToolUseShim {
+		// Add the error as an observation
+		observation := fmt.Sprintf("Result of running %q:\n%v",
+			call.FunctionCall.Name,
+			output)
+		return &toolCallResult{kubeContext: kubeContext, payload: observation, content: observation}, nil
+	}
+
+	// If shim is disabled, convert the result to a map and append FunctionCallResult
+	resultMap, err := tools.ToolResultToMap(output)
+	if err != nil {
+		log.Error(err, "error converting tool result to map", "output", output)
+		return c.toolCallError(call, kubeContext, err), err
+	}
+	return &toolCallResult{
+		kubeContext: kubeContext,
+		payload:     resultMap,
+		content: gollm.FunctionCallResult{
+			ID:     call.FunctionCall.ID,
+			Name:   call.FunctionCall.Name,
+			Result: resultMap,
+		},
+	}, nil
+}
+
+// sandboxImage returns the container image of the sandbox pod.
+func (c *Agent) sandboxImage() string {
+	if c.SandboxImage == "" {
+		return "bitnami/kubectl:latest"
+	}
+	return c.SandboxImage
+}
+
+// kubernetesSandboxOptions returns the options for creating the sandbox pod.
+func (c *Agent) kubernetesSandboxOptions() []sandbox.Option {
+	return []sandbox.Option{
+		sandbox.WithKubeconfig(c.Kubeconfig),
+		sandbox.WithImage(c.sandboxImage()),
+		sandbox.WithRestricted(!c.SandboxUnrestricted),
+		sandbox.WithRuntimeClass(c.SandboxRuntimeClass),
+	}
+}
+
+// toolTimeout returns how long a call of the named tool may run.
+func (c *Agent) toolTimeout(name string) time.Duration {
+	if timeout, ok := c.ToolTimeouts[name]; ok {
+		return timeout
+	}
+	return c.ToolTimeout
+}
+
+// toolCallBatches splits calls, in order, into batches of indexes of calls
+// that may run concurrently: runs of read-only calls, and each call that may
+// modify resources on its own. This includes switch_context, as the other
+// calls would otherwise race with the switch.
+func (c *Agent) toolCallBatches(calls []ToolCallAnalysis) [][]int {
+	var batches [][]int
+	var readOnly []int
+	for i, call := range calls {
+		if call.ModifiesResourceStr == "no" && c.MaxParallelToolCalls > 1 {
+			readOnly = append(readOnly, i)
+			continue
+		}
+		if len(readOnly) > 0 {
+			batches = append(batches, readOnly)
+			readOnly = nil
 		}
+		batches = append(batches, []int{i})
+	}
+	if len(readOnly) > 0 {
+		batches = append(batches, readOnly)
+	}
+	return batches
+}
 
-		// Handle timeout message using UI blocks
-		if execResult, ok := output.(*sandbox.ExecResult); ok && execResult != nil && execResult.StreamType == "timeout" {
-			c.addMessage(api.MessageSourceAgent, api.MessageTypeError, "\nTimeout reached after 7 seconds\n")
+// toolCallPreviews renders previews (e.g. diffs) of the pending tool calls
+// for inclusion in the confirmation prompt.
+func (c *Agent) toolCallPreviews(ctx context.Context) string {
+	var sb strings.Builder
+	for _, call := range c.pendingFunctionCalls {
+		preview, err := call.ParsedToolCall.Preview(ctx, tools.InvokeToolOptions{
+			Kubeconfig: c.kubeconfig(),
+			WorkDir:    c.workDir,
+			Executor:   c.executor,
+		})
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "error previewing tool call", "tool", call.FunctionCall.Name)
+			fmt.Fprintf(&sb, "\n\nUnable to preview %s: %v", call.FunctionCall.Name, err)
+			continue
 		}
-		// Add the tool call result to maintain conversation flow
-		var payload any
-		if c.EnableToolUseShim {
-			// Add the error as an observation
-			observation := fmt.Sprintf("Result of running %q:\n%v",
-				call.FunctionCall.Name,
-				output)
-			c.currChatContent = append(c.currChatContent, observation)
-			payload = observation
-		} else {
-			// If shim is disabled, convert the result to a map and append FunctionCallResult
-			result, err := tools.ToolResultToMap(output)
-			if err != nil {
-				log.Error(err, "error converting tool result to map", "output", output)
-				return err
-			}
-			payload = result
-			c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
-				ID:     call.FunctionCall.ID,
-				Name:   call.FunctionCall.Name,
-				Result: result,
-			})
+		if preview != "" {
+			fmt.Fprintf(&sb, "\n\nPreview of %s:\n```diff\n%s\n```", call.FunctionCall.Name, strings.TrimRight(preview, "\n"))
 		}
-		c.addMessage(api.MessageSourceAgent, api.MessageTypeToolCallResponse, payload)
 	}
-	return nil
+	return sb.String()
+}
+
+// kubeconfig returns the kubeconfig tools should use, taking context switches into account.
+func (c *Agent) kubeconfig() string {
+	if c.contextSwitcher == nil {
+		return c.Kubeconfig
+	}
+	return c.contextSwitcher.Kubeconfig(c.Kubeconfig)
+}
+
+// currentKubeContext returns the context switched to with switch_context, if any.
+func (c *Agent) currentKubeContext() string {
+	if c.contextSwitcher == nil {
+		return ""
+	}
+	return c.contextSwitcher.Current()
+}
+
+// KubeTarget returns the kubeconfig context and namespace tools currently
+// target, taking context switches into account.
+func (c *Agent) KubeTarget() (kubeContext, namespace string, err error) {
+	clientConfig := c.kubeClientConfig()
+	raw, err := clientConfig.RawConfig()
+	if err != nil {
+		return "", "", fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	namespace, _, err = clientConfig.Namespace()
+	if err != nil {
+		return "", "", fmt.Errorf("getting namespace from kubeconfig: %w", err)
+	}
+	return raw.CurrentContext, namespace, nil
+}
+
+// kubeClientConfig loads the kubeconfig tools currently use.
+func (c *Agent) kubeClientConfig() clientcmd.ClientConfig {
+	return c.kubeClientConfigForContext("")
+}
+
+// kubeClientConfigForContext is kubeClientConfig with the given context, ""
+// for the current one.
+func (c *Agent) kubeClientConfigForContext(kubeContext string) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig := c.kubeconfig(); kubeconfig != "" {
+		rules.Precedence = filepath.SplitList(kubeconfig)
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
 }
 
 // The key idea is to treat all tool calls to be executed atomically or not
@@ -1165,6 +2923,7 @@ type ToolCallAnalysis struct {
 func (c *Agent) analyzeToolCalls(ctx context.Context, toolCalls []gollm.FunctionCall) ([]ToolCallAnalysis, error) {
 	toolCallAnalysis := make([]ToolCallAnalysis, len(toolCalls))
 	for i, call := range toolCalls {
+		call.Arguments = c.substituteArguments(call.Arguments)
 		toolCallAnalysis[i].FunctionCall = call
 		toolCall, err := c.Tools.ParseToolInvocation(ctx, call.Name, call.Arguments)
 		if err != nil {
@@ -1180,12 +2939,117 @@ func (c *Agent) analyzeToolCalls(ctx context.Context, toolCalls []gollm.Function
 	return toolCallAnalysis, nil
 }
 
+// maxResponseRegenerations is how many times the LLM is asked to regenerate
+// a response rejected by validateToolNames.
+const maxResponseRegenerations = 2
+
+// validateToolNames rejects responses calling tools that don't exist, so that
+// the LLM is asked to regenerate them instead of the query failing.
+func (c *Agent) validateToolNames(ctx context.Context, response *gollm.ValidatedResponse) error {
+	for _, call := range response.FunctionCalls {
+		if c.Tools.Lookup(call.Name) == nil {
+			return fmt.Errorf("there is no tool named %q, the available tools are %s", call.Name, strings.Join(c.Tools.Names(), ", "))
+		}
+	}
+	return nil
+}
+
+// maxMalformedCallRetries is how many times in a row the LLM is asked to
+// resend tool calls with invalid arguments before giving up.
+const maxMalformedCallRetries = 3
+
+// retryMalformedCalls answers the tool calls whose arguments could not be
+// parsed with a request to send them again as valid JSON, and returns the
+// remaining calls. Once the LLM has been asked maxMalformedCallRetries times
+// in a row, it answers all the calls as not run and returns an error.
+func (c *Agent) retryMalformedCalls(calls []gollm.FunctionCall) ([]gollm.FunctionCall, error) {
+	var valid, malformed []gollm.FunctionCall
+	for _, call := range calls {
+		if call.ParseError != "" {
+			malformed = append(malformed, call)
+		} else {
+			valid = append(valid, call)
+		}
+	}
+	if len(malformed) == 0 {
+		c.malformedCallRetries = 0
+		return valid, nil
+	}
+
+	if c.malformedCallRetries >= maxMalformedCallRetries {
+		c.malformedCallRetries = 0
+		for _, call := range calls {
+			c.answerUnrunCall(call, "invalid_arguments", "Not run, the turn was stopped because of repeated invalid tool call arguments.", false)
+		}
+		return nil, fmt.Errorf("the model sent invalid arguments for %s %d times in a row: %s", malformed[0].Name, maxMalformedCallRetries+1, malformed[0].ParseError)
+	}
+	c.malformedCallRetries++
+
+	for _, call := range malformed {
+		klog.Warningf("Asking the model to resend call to %s with invalid arguments (attempt %d of %d): %s", call.Name, c.malformedCallRetries, maxMalformedCallRetries, call.ParseError)
+		message := fmt.Sprintf("The arguments of this call to %q were not valid JSON (%s), so it was not run. Send the call again with the arguments as a single valid JSON object matching the tool's parameters.", call.Name, call.ParseError)
+		c.answerUnrunCall(call, "invalid_arguments", message, true)
+	}
+	return valid, nil
+}
+
+// answerUnrunCall tells the LLM why a tool call it made was not run.
+func (c *Agent) answerUnrunCall(call gollm.FunctionCall, status string, message string, retryable bool) {
+	if c.EnableToolUseShim {
+		c.currChatContent = append(c.currChatContent, fmt.Sprintf("Result of running %q:\n%s", call.Name, message))
+		return
+	}
+	c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
+		ID:   call.ID,
+		Name: call.Name,
+		Result: map[string]any{
+			"error":     message,
+			"status":    status,
+			"retryable": retryable,
+		},
+	})
+}
+
+// rejectReadOnlyViolations answers the tool calls that are not allowed in
+// read-only mode with an error for the model, and returns the remaining calls.
+func (c *Agent) rejectReadOnlyViolations(calls []ToolCallAnalysis) []ToolCallAnalysis {
+	var allowed []ToolCallAnalysis
+	for _, call := range calls {
+		err := tools.CheckReadOnly(call.ParsedToolCall.GetTool(), call.FunctionCall.Arguments)
+		if err == nil {
+			allowed = append(allowed, call)
+			continue
+		}
+
+		message := fmt.Sprintf("kubectl-ai is running in read-only mode, so %q was not run: %v. Only read-only operations are allowed; suggest the command to the user instead of running it.", call.ParsedToolCall.Description(), err)
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, message)
+		if c.EnableToolUseShim {
+			c.currChatContent = append(c.currChatContent, fmt.Sprintf("Result of running %q:\n%s", call.FunctionCall.Name, message))
+		} else {
+			c.currChatContent = append(c.currChatContent, gollm.FunctionCallResult{
+				ID:   call.FunctionCall.ID,
+				Name: call.FunctionCall.Name,
+				Result: map[string]any{
+					"error":     message,
+					"status":    "rejected",
+					"retryable": false,
+				},
+			})
+		}
+	}
+	return allowed
+}
+
 func (c *Agent) handleChoice(ctx context.Context, choice *api.UserChoiceResponse) (dispatchToolCalls bool) {
 	log := klog.FromContext(ctx)
 	// if user input is a choice and use has declined the operation,
 	// we need to abort all pending function calls.
 	// update the currChatContent with the choice and keep the agent loop running.
 
+	if choice.Choice >= 1 && choice.Choice <= 3 {
+		c.recordApproval(ctx, choice)
+	}
+
 	// Normalize the input
 	switch choice.Choice {
 	case 1:
@@ -1217,7 +3081,35 @@ func (c *Agent) handleChoice(ctx context.Context, choice *api.UserChoiceResponse
 	return dispatchToolCalls
 }
 
+// recordApproval records the user's decision on the pending tool calls, and
+// their justification, in the journal and in the session, for change management.
+func (c *Agent) recordApproval(ctx context.Context, choice *api.UserChoiceResponse) {
+	var commands []string
+	for _, call := range c.pendingFunctionCalls {
+		commands = append(commands, call.ParsedToolCall.Description())
+	}
+	payload := &journal.UserAction{
+		Approved:      choice.Choice != 3,
+		Commands:      commands,
+		Justification: choice.Justification,
+		Runbook:       journal.RunbookFromContext(c.runbookContext(ctx)),
+	}
+	if c.Session != nil {
+		payload.SessionID = c.Session.ID
+	}
+	if err := journal.RecorderFromContext(ctx).Write(ctx, &journal.Event{
+		Timestamp: time.Now(),
+		Action:    journal.ActionToolApproval,
+		Payload:   payload,
+	}); err != nil {
+		klog.Warningf("failed to record approval in the journal: %v", err)
+	}
+
+	c.addMessage(api.MessageSourceUser, api.MessageTypeUserChoiceResponse, choice)
+}
+
 // generateFromTemplate generates a prompt for LLM. It uses the prompt from the provides template file or default.
+// The SystemPromptFile, if it exists, is rendered instead, with the prompt available to it as the "default" template.
 func (a *Agent) generatePrompt(_ context.Context, defaultPromptTemplate string, data PromptData) (string, error) {
 	promptTemplate := defaultPromptTemplate
 	if a.PromptTemplateFile != "" {
@@ -1228,6 +3120,21 @@ func (a *Agent) generatePrompt(_ context.Context, defaultPromptTemplate string,
 		promptTemplate = string(content)
 	}
 
+	// Referencing a variable that is not set is an error, rather than "<no value>" in the prompt
+	tmpl := template.New("promptTemplate").Option("missingkey=error")
+	if a.SystemPromptFile != "" {
+		content, err := os.ReadFile(a.SystemPromptFile)
+		if err == nil {
+			if _, err := tmpl.New("default").Parse(promptTemplate); err != nil {
+				return "", fmt.Errorf("building template for prompt: %w", err)
+			}
+			klog.Infof("Using system prompt template %s", a.SystemPromptFile)
+			promptTemplate = string(content)
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("reading system prompt template: %w", err)
+		}
+	}
+
 	for _, extraPromptPath := range a.ExtraPromptPaths {
 		content, err := os.ReadFile(extraPromptPath)
 		if err != nil {
@@ -1236,13 +3143,12 @@ func (a *Agent) generatePrompt(_ context.Context, defaultPromptTemplate string,
 		promptTemplate += "\n" + string(content)
 	}
 
-	tmpl, err := template.New("promptTemplate").Parse(promptTemplate)
-	if err != nil {
+	if _, err := tmpl.Parse(promptTemplate); err != nil {
 		return "", fmt.Errorf("building template for prompt: %w", err)
 	}
 
 	var result strings.Builder
-	err = tmpl.Execute(&result, &data)
+	err := tmpl.Execute(&result, &data)
 	if err != nil {
 		return "", fmt.Errorf("evaluating template for prompt: %w", err)
 	}
@@ -1256,6 +3162,25 @@ type PromptData struct {
 
 	EnableToolUseShim    bool
 	SessionIsInteractive bool
+
+	// ClusterContext is a summary of the cluster state, empty if disabled.
+	ClusterContext string
+	// APIResources lists the custom resources of the cluster, empty if
+	// schema lookups are disabled.
+	APIResources string
+	// KubeContext is the kubeconfig context switched to, empty for the default.
+	KubeContext string
+	// Vars are user-defined variables, e.g. the conventions of the organization.
+	Vars map[string]string
+	// Focus is the area a sub-agent investigates, empty for the main agent.
+	Focus string
+	// UserProfile lists the facts of the user's long-term profile.
+	UserProfile []string
+	// Memory lists the facts the user asked to remember in the session.
+	Memory []string
+	// SessionVariables are the variables set by the user in the session,
+	// substituted for $name in their queries and ${name} in tool calls.
+	SessionVariables map[string]string
 }
 
 func (a *PromptData) ToolsAsJSON() string {
@@ -16,15 +16,22 @@ package agent
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"go.uber.org/mock/gomock"
 )
 
@@ -133,6 +140,68 @@ func TestHandleMetaQuery(t *testing.T) {
 				return a
 			},
 		},
+		{
+			name:   "model switch",
+			query:  "model gemini-2.5-pro",
+			expect: "Switched to model `gemini-2.5-pro` of provider `gemini`.",
+			expectations: func(t *testing.T) *Agent {
+				ctrl := gomock.NewController(t)
+				t.Cleanup(ctrl.Finish)
+
+				store := sessions.NewInMemoryChatStore()
+				_ = store.AddChatMessage(&api.Message{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "why is my pod failing?"})
+
+				chat := mocks.NewMockChat(ctrl)
+				chat.EXPECT().Initialize(store.ChatMessages()).Return(nil)
+				chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+				llm := mocks.NewMockClient(ctrl)
+				llm.EXPECT().ListModels(ctx).Return([]string{"gemini-2.5-flash", "gemini-2.5-pro"}, nil)
+				llm.EXPECT().StartChat(gomock.Any(), "gemini-2.5-pro").Return(chat)
+
+				a := &Agent{LLM: llm, Model: "gemini-2.5-flash", Provider: "gemini"}
+				a.Tools.Init()
+				a.Session = &api.Session{ChatMessageStore: store}
+				return a
+			},
+			verify: func(t *testing.T, a *Agent, _ string) {
+				if a.Session.ModelID != "gemini-2.5-pro" || a.Session.ProviderID != "gemini" {
+					t.Errorf("expected the session to record the new model, got %s/%s", a.Session.ProviderID, a.Session.ModelID)
+				}
+			},
+		},
+		{
+			name:   "model switch with provider",
+			query:  "model gpt-4.1 openai",
+			expect: "Switched to model `gpt-4.1` of provider `openai`.",
+			expectations: func(t *testing.T) *Agent {
+				ctrl := gomock.NewController(t)
+				t.Cleanup(ctrl.Finish)
+
+				chat := mocks.NewMockChat(ctrl)
+				chat.EXPECT().Initialize(gomock.Any()).Return(nil)
+				chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+				previous := mocks.NewMockClient(ctrl)
+				previous.EXPECT().Close().Return(nil)
+				llm := mocks.NewMockClient(ctrl)
+				llm.EXPECT().StartChat(gomock.Any(), "gpt-4.1").Return(chat)
+
+				a := &Agent{LLM: previous, Model: "gemini-2.5-flash", Provider: "gemini"}
+				a.NewLLM = func(ctx context.Context, provider string) (gollm.Client, error) {
+					if provider != "openai" {
+						t.Errorf("unexpected provider %q", provider)
+					}
+					return llm, nil
+				}
+				a.Tools.Init()
+				a.Session = &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}
+				return a
+			},
+			verify: func(t *testing.T, a *Agent, _ string) {
+				if a.Provider != "openai" || a.Session.ProviderID != "openai" {
+					t.Errorf("expected the provider to be switched, got %q", a.Provider)
+				}
+			},
+		},
 		{
 			name:   "models",
 			query:  "models",
@@ -249,6 +318,52 @@ func TestHandleMetaQuery(t *testing.T) {
 	}
 }
 
+func TestHandleMetaQuery_NotHandled(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	llm := mocks.NewMockClient(ctrl)
+	llm.EXPECT().ListModels(ctx).Return([]string{"gemini-2.5-flash", "gemini-2.5-pro"}, nil)
+
+	a := &Agent{LLM: llm, Model: "gemini-2.5-flash", Provider: "gemini"}
+	a.Tools.Init()
+	a.Session = &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}
+	for _, query := range []string{
+		"model the traffic between the frontend and the backend",
+		"model traffic",
+		"model the traffic",
+	} {
+		if answer, handled, err := a.handleMetaQuery(ctx, query); handled || err != nil {
+			t.Errorf("expected %q to be sent to the LLM, got %q, %v", query, answer, err)
+		}
+	}
+}
+
+func TestAgent_RestoreSessionModel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	previous := mocks.NewMockClient(ctrl)
+	previous.EXPECT().Close().Return(nil)
+	llm := mocks.NewMockClient(ctrl)
+
+	a := &Agent{
+		LLM:      previous,
+		Model:    "gemini-2.5-flash",
+		Provider: "gemini",
+		NewLLM: func(ctx context.Context, provider string) (gollm.Client, error) {
+			if provider != "openai" {
+				t.Errorf("unexpected provider %q", provider)
+			}
+			return llm, nil
+		},
+		Session: &api.Session{ModelID: "gpt-4.1", ProviderID: "openai"},
+	}
+	if err := a.restoreSessionModel(context.Background()); err != nil {
+		t.Fatalf("restoreSessionModel() error = %v", err)
+	}
+	if a.LLM != llm || a.Model != "gpt-4.1" || a.Provider != "openai" {
+		t.Errorf("expected the model of the session to be used, got %s/%s", a.Provider, a.Model)
+	}
+}
+
 func TestAgent_NewSession(t *testing.T) {
 	// Setup
 	manager, err := sessions.NewSessionManager("memory")
@@ -282,6 +397,45 @@ func TestAgent_NewSession(t *testing.T) {
 	}
 }
 
+func TestAgent_RenameAndDeleteSession(t *testing.T) {
+	manager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	current, err := manager.NewSession(sessions.Metadata{})
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	other, err := manager.NewSession(sessions.Metadata{})
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+
+	a := &Agent{SessionBackend: "memory", Session: current}
+	if err := a.RenameSession(current.ID, "debugging ingress"); err != nil {
+		t.Fatalf("RenameSession failed: %v", err)
+	}
+	if a.Session.Name != "debugging ingress" {
+		t.Errorf("expected the current session to be renamed, got %q", a.Session.Name)
+	}
+	if err := a.RenameSession(other.ID, "payments outage"); err != nil {
+		t.Fatalf("RenameSession failed: %v", err)
+	}
+	if found, err := manager.FindSessionByID(other.ID); err != nil || found.Name != "payments outage" {
+		t.Errorf("expected the other session to be renamed, got %v, %v", found, err)
+	}
+
+	if err := a.DeleteSession(current.ID); err == nil {
+		t.Errorf("expected an error deleting the current session")
+	}
+	if err := a.DeleteSession(other.ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if _, err := manager.FindSessionByID(other.ID); err == nil {
+		t.Errorf("expected the session to be deleted")
+	}
+}
+
 func TestAgent_LoadSession_ResetsState(t *testing.T) {
 	// Setup
 	manager, err := sessions.NewSessionManager("memory")
@@ -404,3 +558,688 @@ func TestAgent_NewSession_NoDeadlock(t *testing.T) {
 		t.Fatal("NewSession timed out (potential deadlock)")
 	}
 }
+
+func TestAgent_DispatchToolCalls_Parallel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var running, maxRunning atomic.Int32
+	tool := mocks.NewMockTool(ctrl)
+	tool.EXPECT().Name().Return("sleep").AnyTimes()
+	tool.EXPECT().Run(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, args map[string]any) (any, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(args["ms"].(float64)) * time.Millisecond)
+		return map[string]any{"slept": args["ms"]}, nil
+	}).Times(3)
+
+	var toolset tools.Tools
+	toolset.Init()
+	toolset.RegisterTool(tool)
+
+	var calls []ToolCallAnalysis
+	for i, ms := range []float64{60, 10, 30} {
+		args := map[string]any{"ms": ms}
+		parsed, err := toolset.ParseToolInvocation(context.Background(), "sleep", args)
+		if err != nil {
+			t.Fatalf("parsing tool call: %v", err)
+		}
+		calls = append(calls, ToolCallAnalysis{
+			FunctionCall:        gollm.FunctionCall{ID: fmt.Sprintf("call-%d", i), Name: "sleep", Arguments: args},
+			ParsedToolCall:      parsed,
+			ModifiesResourceStr: "no",
+		})
+	}
+
+	a := &Agent{
+		Output:               make(chan any, 10),
+		MaxParallelToolCalls: 2,
+		Session:              &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		pendingFunctionCalls: calls,
+	}
+	if err := a.DispatchToolCalls(context.Background()); err != nil {
+		t.Fatalf("DispatchToolCalls failed: %v", err)
+	}
+
+	if got := maxRunning.Load(); got != 2 {
+		t.Errorf("expected 2 tool calls to run concurrently, got %d", got)
+	}
+	if len(a.currChatContent) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(a.currChatContent))
+	}
+	for i, content := range a.currChatContent {
+		result := content.(gollm.FunctionCallResult)
+		if want := fmt.Sprintf("call-%d", i); result.ID != want {
+			t.Errorf("result %d: expected ID %q, got %q", i, want, result.ID)
+		}
+	}
+}
+
+func TestAgent_ToolCallBatches(t *testing.T) {
+	var calls []ToolCallAnalysis
+	for _, modifies := range []string{"no", "no", "yes", "no", "unknown", "unknown", "no"} {
+		calls = append(calls, ToolCallAnalysis{ModifiesResourceStr: modifies})
+	}
+
+	a := &Agent{MaxParallelToolCalls: 4}
+	want := [][]int{{0, 1}, {2}, {3}, {4}, {5}, {6}}
+	if got := a.toolCallBatches(calls); !reflect.DeepEqual(got, want) {
+		t.Errorf("toolCallBatches() = %v, want %v", got, want)
+	}
+
+	a.MaxParallelToolCalls = 1
+	want = [][]int{{0}, {1}, {2}, {3}, {4}, {5}, {6}}
+	if got := a.toolCallBatches(calls); !reflect.DeepEqual(got, want) {
+		t.Errorf("toolCallBatches() without parallelism = %v, want %v", got, want)
+	}
+}
+
+func TestAgent_CancelToolCalls(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tool := mocks.NewMockTool(ctrl)
+	tool.EXPECT().Name().Return("follow").AnyTimes()
+	tool.EXPECT().Run(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, args map[string]any) (any, error) {
+		<-ctx.Done()
+		return &sandbox.ExecResult{Stdout: "line 1\n", ExitCode: -1, Error: ctx.Err().Error()}, nil
+	})
+
+	var toolset tools.Tools
+	toolset.Init()
+	toolset.RegisterTool(tool)
+	parsed, err := toolset.ParseToolInvocation(context.Background(), "follow", map[string]any{})
+	if err != nil {
+		t.Fatalf("parsing tool call: %v", err)
+	}
+
+	a := &Agent{
+		Output:  make(chan any, 10),
+		Session: &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		pendingFunctionCalls: []ToolCallAnalysis{{
+			FunctionCall:   gollm.FunctionCall{ID: "call-0", Name: "follow"},
+			ParsedToolCall: parsed,
+		}},
+	}
+	if a.CancelToolCalls() {
+		t.Errorf("expected no tool calls to be cancelled before they run")
+	}
+	go func() {
+		for len(a.RunningToolCalls()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		a.CancelToolCalls()
+	}()
+	if err := a.DispatchToolCalls(context.Background()); err != nil {
+		t.Fatalf("expected the agent to go on after the call was cancelled, got %v", err)
+	}
+
+	if len(a.currChatContent) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(a.currChatContent))
+	}
+	result := a.currChatContent[0].(gollm.FunctionCallResult).Result
+	if result["error"] != "cancelled by the user" || result["stdout"] != "line 1\n" {
+		t.Errorf("expected the result to say the call was cancelled, with its output, got %v", result)
+	}
+	if len(a.RunningToolCalls()) != 0 {
+		t.Errorf("expected no running tool calls, got %v", a.RunningToolCalls())
+	}
+}
+
+func TestAgent_CancelToolCall_Input(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+	tool := mocks.NewMockTool(ctrl)
+	tool.EXPECT().Name().Return("follow").AnyTimes()
+	tool.EXPECT().Run(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, args map[string]any) (any, error) {
+		select {
+		case <-ctx.Done():
+		case <-release:
+		}
+		return &sandbox.ExecResult{Stdout: args["command"].(string) + "\n"}, nil
+	}).Times(2)
+
+	var toolset tools.Tools
+	toolset.Init()
+	toolset.RegisterTool(tool)
+	var calls []ToolCallAnalysis
+	for i, command := range []string{"kubectl logs -f web", "kubectl logs -f db"} {
+		args := map[string]any{"command": command}
+		parsed, err := toolset.ParseToolInvocation(context.Background(), "follow", args)
+		if err != nil {
+			t.Fatalf("parsing tool call: %v", err)
+		}
+		calls = append(calls, ToolCallAnalysis{
+			FunctionCall:        gollm.FunctionCall{ID: fmt.Sprintf("call-%d", i), Name: "follow", Arguments: args},
+			ParsedToolCall:      parsed,
+			ModifiesResourceStr: "no",
+		})
+	}
+
+	a := &Agent{
+		Input:                make(chan any),
+		Output:               make(chan any, 10),
+		MaxParallelToolCalls: 2,
+		Session:              &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		pendingFunctionCalls: calls,
+	}
+	go func() {
+		for len(a.RunningToolCalls()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		// The first request message is the one of the first call
+		var first string
+		for _, msg := range a.Session.AllMessages() {
+			if msg.Type == api.MessageTypeToolCallRequest && strings.Contains(msg.Payload.(string), "web") {
+				first = msg.ID
+			}
+		}
+		a.Input <- &api.CancelToolCall{ID: first}
+		// Other input waits for the calls to be done
+		a.Input <- &api.UserInputResponse{Query: "next"}
+		a.Input <- &api.UserInputResponse{Query: "then"}
+		close(release)
+	}()
+	if err := a.DispatchToolCalls(context.Background()); err != nil {
+		t.Fatalf("DispatchToolCalls failed: %v", err)
+	}
+
+	var results []map[string]any
+	for _, content := range a.currChatContent {
+		results = append(results, content.(gollm.FunctionCallResult).Result)
+	}
+	if len(results) != 2 || results[0]["error"] != "cancelled by the user" || results[1]["error"] != nil {
+		t.Errorf("expected only the first call to be cancelled, got %v", results)
+	}
+	// The agent loop reads the deferred input in order, before new input
+	go func() { a.Input <- &api.UserInputResponse{Query: "later"} }()
+	for _, want := range []string{"next", "then", "later"} {
+		input, ok := a.readInput(context.Background())
+		if query, isQuery := input.(*api.UserInputResponse); !ok || !isQuery || query.Query != want {
+			t.Errorf("readInput() = %v, want the query %q", input, want)
+		}
+	}
+}
+
+type memoryRecorder struct {
+	events []*journal.Event
+}
+
+func (r *memoryRecorder) Write(ctx context.Context, event *journal.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *memoryRecorder) Close() error { return nil }
+
+func TestAgent_HandleChoice_RecordsJustification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tool := mocks.NewMockTool(ctrl)
+	tool.EXPECT().Name().Return("kubectl").AnyTimes()
+
+	var toolset tools.Tools
+	toolset.Init()
+	toolset.RegisterTool(tool)
+
+	args := map[string]any{"command": "kubectl delete pod web-0"}
+	parsed, err := toolset.ParseToolInvocation(context.Background(), "kubectl", args)
+	if err != nil {
+		t.Fatalf("parsing tool call: %v", err)
+	}
+
+	store := sessions.NewInMemoryChatStore()
+	a := &Agent{
+		Output:  make(chan any, 10),
+		Session: &api.Session{ID: "test-session", ChatMessageStore: store},
+		pendingFunctionCalls: []ToolCallAnalysis{{
+			FunctionCall:   gollm.FunctionCall{ID: "call-0", Name: "kubectl", Arguments: args},
+			ParsedToolCall: parsed,
+		}},
+	}
+
+	recorder := &memoryRecorder{}
+	ctx := journal.ContextWithRecorder(context.Background(), recorder)
+	if !a.handleChoice(ctx, &api.UserChoiceResponse{Choice: 1, Justification: "CHG-1234: restart stuck pod"}) {
+		t.Fatalf("expected the tool calls to be dispatched")
+	}
+
+	if len(recorder.events) != 1 || recorder.events[0].Action != journal.ActionToolApproval {
+		t.Fatalf("expected one approval event in the journal, got %+v", recorder.events)
+	}
+	if got, _ := recorder.events[0].GetString("justification"); got != "CHG-1234: restart stuck pod" {
+		t.Errorf("unexpected justification in the journal: %q", got)
+	}
+
+	messages := store.ChatMessages()
+	if len(messages) != 1 || messages[0].Type != api.MessageTypeUserChoiceResponse {
+		t.Fatalf("expected the approval to be stored in the session, got %+v", messages)
+	}
+	if choice := messages[0].Payload.(*api.UserChoiceResponse); choice.Justification != "CHG-1234: restart stuck pod" {
+		t.Errorf("unexpected justification in the session: %q", choice.Justification)
+	}
+}
+
+func TestAgent_RetryMalformedCalls(t *testing.T) {
+	a := &Agent{}
+	calls := []gollm.FunctionCall{
+		{ID: "1", Name: "kubectl", Arguments: map[string]any{"command": "kubectl get pods"}},
+		{ID: "2", Name: "kubectl", Arguments: map[string]any{}, ParseError: "unexpected end of JSON input"},
+	}
+
+	for i := 1; i <= maxMalformedCallRetries; i++ {
+		a.currChatContent = nil
+		valid, err := a.retryMalformedCalls(calls)
+		if err != nil {
+			t.Fatalf("attempt %d: unexpected error: %v", i, err)
+		}
+		if len(valid) != 1 || valid[0].ID != "1" {
+			t.Fatalf("attempt %d: expected only the valid call to be returned, got %v", i, valid)
+		}
+		if len(a.currChatContent) != 1 {
+			t.Fatalf("attempt %d: expected a reply to the malformed call, got %v", i, a.currChatContent)
+		}
+		result := a.currChatContent[0].(gollm.FunctionCallResult)
+		if result.ID != "2" || result.Result["retryable"] != true {
+			t.Errorf("attempt %d: unexpected reply %+v", i, result)
+		}
+	}
+
+	a.currChatContent = nil
+	if _, err := a.retryMalformedCalls(calls); err == nil {
+		t.Fatalf("expected an error after %d retries", maxMalformedCallRetries)
+	}
+	if len(a.currChatContent) != len(calls) {
+		t.Errorf("expected every call to be answered when giving up, got %v", a.currChatContent)
+	}
+
+	// A response without malformed calls resets the count
+	a.malformedCallRetries = 2
+	if _, err := a.retryMalformedCalls(calls[:1]); err != nil || a.malformedCallRetries != 0 {
+		t.Errorf("expected the retry count to be reset, got %d (err %v)", a.malformedCallRetries, err)
+	}
+}
+
+func TestAgent_ValidateToolNames(t *testing.T) {
+	a := &Agent{}
+	a.Tools.Init()
+	a.Tools.RegisterTool(tools.NewBashTool(nil))
+
+	if err := a.validateToolNames(context.Background(), &gollm.ValidatedResponse{FunctionCalls: []gollm.FunctionCall{{Name: "bash"}}}); err != nil {
+		t.Errorf("expected a call to a known tool to be accepted, got %v", err)
+	}
+	err := a.validateToolNames(context.Background(), &gollm.ValidatedResponse{FunctionCalls: []gollm.FunctionCall{{Name: "bash"}, {Name: "helm"}}})
+	if err == nil || !strings.Contains(err.Error(), `"helm"`) || !strings.Contains(err.Error(), "bash") {
+		t.Errorf("expected the call to an unknown tool to be rejected with the available tools, got %v", err)
+	}
+}
+
+func TestAgent_ContinueTruncated(t *testing.T) {
+	fixture := &gollm.MockFixture{Responses: []gollm.MockResponse{
+		{Text: "The pods are ", StopReason: gollm.StopReasonMaxTokens, Usage: &gollm.Usage{InputTokens: 10, OutputTokens: 5}},
+		{Expect: "cut off", Text: "failing because ", StopReason: gollm.StopReasonMaxTokens},
+		{Expect: "cut off", Text: "of a missing image."},
+	}}
+	read := func(maxContinuations int) (string, gollm.StopReason, *Agent) {
+		a := &Agent{
+			llmChat:          gollm.NewMockClient(fixture).StartChat("", "mock"),
+			MaxContinuations: maxContinuations,
+			Session:          &api.Session{},
+		}
+		stream, err := a.llmChat.SendStreaming(context.Background(), "why are my pods failing?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text string
+		var stopReason gollm.StopReason
+		for response, err := range a.continueTruncated(context.Background(), stream) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			text += response.Candidates()[0].String()
+			stopReason = gollm.ResponseStopReason(response)
+		}
+		return text, stopReason, a
+	}
+
+	text, stopReason, a := read(3)
+	if text != "The pods are failing because of a missing image." || stopReason != gollm.StopReasonEnd {
+		t.Errorf("expected the pieces of the response stitched together, got %q (%s)", text, stopReason)
+	}
+	if a.Session.Usage.Requests != 1 || a.Session.Usage.InputTokens != 10 {
+		t.Errorf("expected the usage of the first piece to be recorded, got %+v", a.Session.Usage)
+	}
+
+	text, stopReason, _ = read(1)
+	if text != "The pods are failing because " || stopReason != gollm.StopReasonMaxTokens {
+		t.Errorf("expected a single continuation, got %q (%s)", text, stopReason)
+	}
+	if text, _, _ := read(0); text != "The pods are " {
+		t.Errorf("expected no continuation, got %q", text)
+	}
+
+	// Continuations recover from context length errors like other requests
+	chat := &contextLimitedChat{toolOutputs: 1, limit: 0}
+	a = &Agent{llmChat: chat, MaxContinuations: 1, Output: make(chan any, 10), Session: &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()}}
+	for _, err := range a.continueTruncated(context.Background(), func(yield func(gollm.ChatResponse, error) bool) {
+		yield(&truncatedResponse{}, nil)
+	}) {
+		if err != nil {
+			t.Fatalf("expected the continuation to be retried without the tool output, got %v", err)
+		}
+	}
+	if chat.sent != 2 || chat.toolOutputs != 0 {
+		t.Errorf("expected 2 requests dropping the tool output, got %d keeping %d", chat.sent, chat.toolOutputs)
+	}
+}
+
+// truncatedResponse is a response cut off at the output token limit.
+type truncatedResponse struct{ gollm.ChatResponse }
+
+func (r *truncatedResponse) UsageMetadata() any { return nil }
+
+func (r *truncatedResponse) Candidates() []gollm.Candidate { return nil }
+
+func (r *truncatedResponse) StopReason() gollm.StopReason { return gollm.StopReasonMaxTokens }
+
+func (r *truncatedResponse) ModelVersion() string { return "" }
+
+func TestGeneratePrompt_SystemPromptFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate := func(name, content string) string {
+		path := dir + "/" + name
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	const defaultTemplate = "You are a Kubernetes assistant."
+	tests := []struct {
+		name             string
+		systemPromptFile string
+		vars             map[string]string
+		want             string
+		wantErr          bool
+	}{
+		{
+			name:             "missing file uses the default",
+			systemPromptFile: dir + "/missing.tmpl",
+			want:             defaultTemplate,
+		},
+		{
+			name:             "extends the default",
+			systemPromptFile: writeTemplate("extend.tmpl", `{{template "default" .}} Namespaces are named {{.Vars.team}}-<env>.`),
+			vars:             map[string]string{"team": "payments"},
+			want:             defaultTemplate + " Namespaces are named payments-<env>.",
+		},
+		{
+			name:             "replaces the default",
+			systemPromptFile: writeTemplate("replace.tmpl", "Only answer questions about {{.Vars.cluster}}."),
+			vars:             map[string]string{"cluster": "prod-eu"},
+			want:             "Only answer questions about prod-eu.",
+		},
+		{
+			name:             "unset variable",
+			systemPromptFile: writeTemplate("unset.tmpl", "Team {{.Vars.team}}"),
+			wantErr:          true,
+		},
+		{
+			name:             "invalid template",
+			systemPromptFile: writeTemplate("invalid.tmpl", "{{if .KubeContext}}"),
+			wantErr:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Agent{SystemPromptFile: tt.systemPromptFile}
+			got, err := a.generatePrompt(context.Background(), defaultTemplate, PromptData{Vars: tt.vars})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got prompt %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got prompt %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLLMErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{gollm.ClassifyError(&gollm.APIError{StatusCode: 401, Message: "invalid api key"}), "API key"},
+		{gollm.ClassifyError(&gollm.APIError{StatusCode: 400, Message: "maximum context length exceeded"}), "larger context window"},
+		{gollm.ClassifyError(&gollm.APIError{StatusCode: 429, Message: "slow down"}), "another model"},
+		{gollm.ClassifyError(fmt.Errorf("reading stream: %w", gollm.ErrStreamLineTooLong)), "--max-stream-line-bytes"},
+		{fmt.Errorf("something went wrong"), "Error: something went wrong"},
+	}
+	for _, tt := range tests {
+		if got := llmErrorMessage(tt.err); !strings.Contains(got, tt.want) {
+			t.Errorf("llmErrorMessage(%v) = %q, expected it to contain %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// contextLimitedChat fails with a context length error in the stream while
+// it has more than limit tool outputs.
+type contextLimitedChat struct {
+	gollm.Chat
+	toolOutputs int
+	limit       int
+	sent        int
+}
+
+func (c *contextLimitedChat) SendStreaming(ctx context.Context, contents ...any) (gollm.ChatResponseIterator, error) {
+	c.sent++
+	return func(yield func(gollm.ChatResponse, error) bool) {
+		if c.toolOutputs > c.limit {
+			yield(nil, gollm.ClassifyError(&gollm.APIError{StatusCode: 400, Message: "maximum context length exceeded"}))
+			return
+		}
+		yield(nil, nil)
+	}, nil
+}
+
+func (c *contextLimitedChat) DropToolOutputs(count int) int {
+	dropped := min(count, c.toolOutputs)
+	c.toolOutputs -= dropped
+	return dropped
+}
+
+func TestSendStreaming_DropsToolOutputsOnContextLengthError(t *testing.T) {
+	newAgent := func(chat gollm.Chat) *Agent {
+		return &Agent{
+			llmChat: chat,
+			Output:  make(chan any, 10),
+			Session: &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		}
+	}
+	send := func(a *Agent) error {
+		stream, err := a.sendStreaming(context.Background(), []any{"why is my pod failing?"})
+		if err != nil {
+			return err
+		}
+		for _, err := range stream {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The oldest outputs are dropped first, twice as many at each retry
+	chat := &contextLimitedChat{toolOutputs: 6, limit: 3}
+	a := newAgent(chat)
+	if err := send(a); err != nil {
+		t.Fatalf("expected the request to be retried without the oldest tool outputs, got %v", err)
+	}
+	if chat.sent != 3 || chat.toolOutputs != 3 {
+		t.Errorf("expected 3 requests keeping 3 tool outputs, got %d requests keeping %d", chat.sent, chat.toolOutputs)
+	}
+	messages := a.Session.ChatMessageStore.ChatMessages()
+	if len(messages) != 2 || !strings.Contains(messages[1].Payload.(string), "output of the 2 oldest tool calls") {
+		t.Errorf("expected notices about the dropped outputs, got %+v", messages)
+	}
+
+	// The request is retried until no output is left to drop
+	chat = &contextLimitedChat{toolOutputs: 3, limit: -1}
+	if err := send(newAgent(chat)); err == nil || chat.sent != 3 || chat.toolOutputs != 0 {
+		t.Errorf("expected the error once all outputs were dropped, got %v after %d requests", err, chat.sent)
+	}
+	chat = &contextLimitedChat{toolOutputs: 0, limit: -1}
+	if err := send(newAgent(chat)); err == nil || chat.sent != 1 {
+		t.Errorf("expected the error without retry, got %v after %d requests", err, chat.sent)
+	}
+}
+
+func TestAgent_KubeTarget(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: staging
+contexts:
+- name: staging
+  context: {cluster: staging, namespace: payments}
+- name: prod
+  context: {cluster: prod}
+clusters:
+- name: staging
+  cluster: {server: https://staging.example.com}
+- name: prod
+  cluster: {server: https://prod.example.com}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Agent{Kubeconfig: kubeconfig}
+	if kubeContext, namespace, err := a.KubeTarget(); err != nil || kubeContext != "staging" || namespace != "payments" {
+		t.Errorf("KubeTarget() = %q, %q, %v, want staging, payments", kubeContext, namespace, err)
+	}
+
+	a.contextSwitcher = tools.NewContextSwitcher([]tools.KubeContext{{Name: "production", Context: "prod"}}, dir)
+	if err := a.contextSwitcher.Switch(context.Background(), "production"); err != nil {
+		t.Fatal(err)
+	}
+	if kubeContext, namespace, err := a.KubeTarget(); err != nil || kubeContext != "prod" || namespace != "default" {
+		t.Errorf("KubeTarget() after switching = %q, %q, %v, want prod, default", kubeContext, namespace, err)
+	}
+}
+
+func TestAgent_RecordFeedback(t *testing.T) {
+	manager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	session, err := manager.NewSession(sessions.Metadata{})
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	recorder := &memoryRecorder{}
+	a := &Agent{
+		SessionBackend:   "memory",
+		Session:          session,
+		Recorder:         recorder,
+		Provider:         "gemini",
+		Model:            "gemini-2.5-pro",
+		Output:           make(chan any, 10),
+		systemPromptHash: "abc123",
+	}
+	if _, err := a.RecordFeedback(context.Background(), "", api.FeedbackPositive); err == nil {
+		t.Errorf("expected an error without answers")
+	}
+
+	question := a.addMessage(api.MessageSourceUser, api.MessageTypeText, "why is web crashing?")
+	first := a.addMessage(api.MessageSourceModel, api.MessageTypeText, "It runs out of memory.")
+	a.addMessage(api.MessageSourceModel, api.MessageTypeText, "Raise its memory limit.")
+
+	if _, err := a.RecordFeedback(context.Background(), question.ID, api.FeedbackPositive); err == nil {
+		t.Errorf("expected an error rating a question")
+	}
+	if _, err := a.RecordFeedback(context.Background(), "", api.FeedbackNegative); err != nil {
+		t.Fatalf("RecordFeedback failed: %v", err)
+	}
+	if _, err := a.RecordFeedback(context.Background(), first.ID, api.FeedbackNegative); err != nil {
+		t.Fatalf("RecordFeedback failed: %v", err)
+	}
+	feedback, err := a.RecordFeedback(context.Background(), first.ID, api.FeedbackPositive)
+	if err != nil {
+		t.Fatalf("RecordFeedback failed: %v", err)
+	}
+	if feedback.ModelID != "gemini-2.5-pro" || feedback.PromptHash != "abc123" {
+		t.Errorf("unexpected feedback %+v", feedback)
+	}
+
+	// Rating again replaces the earlier rating of the answer
+	saved, err := manager.FindSessionByID(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Feedback) != 2 || saved.Feedback[1].MessageID != first.ID || saved.Feedback[1].Rating != api.FeedbackPositive {
+		t.Errorf("unexpected feedback in the session %+v", saved.Feedback)
+	}
+	if len(recorder.events) != 3 || recorder.events[2].Action != journal.ActionFeedback {
+		t.Fatalf("expected 3 feedback events in the journal, got %+v", recorder.events)
+	}
+	if got, _ := recorder.events[2].GetString("provider"); got != "gemini" {
+		t.Errorf("expected the provider in the journal, got %q", got)
+	}
+}
+
+// podIPFilter replaces a pod IP in the system prompt.
+type podIPFilter struct{}
+
+func (podIPFilter) Filter(ctx context.Context, kind tools.ContentKind, content string) (string, error) {
+	if kind != tools.ContentKindSystemPrompt {
+		return content, nil
+	}
+	return strings.ReplaceAll(content, "10.0.0.7", "<pod-ip>"), nil
+}
+
+func TestAgent_StartChat_FiltersSystemPrompt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockClient := mocks.NewMockClient(ctrl)
+	mockChat := mocks.NewMockChat(ctrl)
+
+	var systemPrompt string
+	mockClient.EXPECT().StartChat(gomock.Any(), gomock.Any()).DoAndReturn(func(prompt, model string) gollm.Chat {
+		systemPrompt = prompt
+		return mockChat
+	})
+	mockChat.EXPECT().Initialize(gomock.Any()).Return(nil)
+
+	a := &Agent{
+		LLM:            mockClient,
+		ContentFilters: []tools.ContentFilter{podIPFilter{}},
+		Session: &api.Session{
+			Memory:           []string{"the database runs at 10.0.0.7"},
+			ChatMessageStore: sessions.NewInMemoryChatStore(),
+		},
+	}
+	if err := a.startChatWithHistory(context.Background(), nil); err != nil {
+		t.Fatalf("startChatWithHistory() error = %v", err)
+	}
+	if !strings.Contains(systemPrompt, "the database runs at <pod-ip>") {
+		t.Errorf("expected the memory in the system prompt to be filtered, got %q", systemPrompt)
+	}
+}
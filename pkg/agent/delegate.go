@@ -0,0 +1,155 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// defaultSubAgentMaxIterations bounds the tool loop of a sub-agent when
+// SubAgentIterations is not set.
+const defaultSubAgentMaxIterations = 10
+
+// DelegateTool lets the agent hand a focused investigation to a sub-agent.
+// The sub-agent runs its own read-only tool loop in a child session, with a
+// system prompt narrowed to the focus, and returns a summary of its findings.
+// Several delegate calls in one response run in parallel.
+type DelegateTool struct {
+	parent *Agent
+}
+
+func newDelegateTool(parent *Agent) *DelegateTool {
+	return &DelegateTool{parent: parent}
+}
+
+func (t *DelegateTool) Name() string {
+	return "delegate"
+}
+
+func (t *DelegateTool) Description() string {
+	return "Delegates an investigation to a sub-agent focused on one area, e.g. networking or storage. " +
+		"The sub-agent runs its own read-only tool calls and returns a summary of its findings. " +
+		"Call it several times in one response to investigate independent areas in parallel."
+}
+
+func (t *DelegateTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"focus": {
+					Type:        gollm.TypeString,
+					Description: `The area the sub-agent investigates, e.g. "networking" or "storage".`,
+				},
+				"task": {
+					Type:        gollm.TypeString,
+					Description: `What the sub-agent should find out, with the context it needs: the symptoms, the resources and namespaces involved, and what was already ruled out.`,
+				},
+			},
+			Required: []string{"focus", "task"},
+		},
+	}
+}
+
+func (t *DelegateTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	focus, _ := args["focus"].(string)
+	task, _ := args["task"].(string)
+	if focus == "" || task == "" {
+		return &sandbox.ExecResult{Error: "focus and task are required"}, nil
+	}
+	if t.parent.manager == nil {
+		return &sandbox.ExecResult{Error: "delegation is not available"}, nil
+	}
+
+	child, err := t.parent.manager.startSubAgent(ctx, t.parent, focus, task)
+	if err != nil {
+		return &sandbox.ExecResult{Error: fmt.Sprintf("starting sub-agent: %v", err)}, nil
+	}
+	defer t.parent.manager.endSubAgent(child)
+
+	summary, err := child.runToCompletion(ctx)
+	t.parent.addChildUsage(child.Session.Usage)
+	result := map[string]any{
+		"focus":   focus,
+		"summary": summary,
+	}
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	return result, nil
+}
+
+func (t *DelegateTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *DelegateTool) CheckModifiesResource(args map[string]any) string {
+	// Sub-agents run in read-only mode
+	return "no"
+}
+
+// runToCompletion runs the agent's initial query in RunOnce mode, and returns
+// the last response of the model.
+func (c *Agent) runToCompletion(ctx context.Context) (string, error) {
+	if err := c.Run(ctx, ""); err != nil {
+		return "", err
+	}
+	// Discard the output, the result is read from the session
+	for done := false; !done; {
+		select {
+		case <-c.Output:
+		case <-c.loopDone:
+			done = true
+		}
+	}
+
+	var summary string
+	for _, message := range c.Session.ChatMessageStore.ChatMessages() {
+		if text, ok := message.Payload.(string); ok && message.Source == api.MessageSourceModel && message.Type == api.MessageTypeText {
+			summary = text
+		}
+	}
+	if err := c.LastErr(); err != nil {
+		return summary, err
+	}
+	if summary == "" {
+		return "", fmt.Errorf("the sub-agent finished without a summary")
+	}
+	return summary, nil
+}
+
+// addChildUsage adds the usage of a sub-agent to the session, so that it
+// counts towards the cost of the session and the token budget of the query.
+func (c *Agent) addChildUsage(usage api.SessionUsage) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	u := &c.Session.Usage
+	if u.Requests == 0 {
+		u.CostKnown = true
+	}
+	u.Requests += usage.Requests
+	u.InputTokens += usage.InputTokens
+	u.OutputTokens += usage.OutputTokens
+	u.TotalTokens += usage.TotalTokens
+	u.EstimatedCost += usage.EstimatedCost
+	u.CostKnown = u.CostKnown && (usage.CostKnown || usage.Requests == 0)
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDelegateTool(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := mocks.NewMockClient(ctrl)
+	chat := mocks.NewMockChat(ctrl)
+	var systemPrompt string
+	client.EXPECT().StartChat(gomock.Any(), "test-model").DoAndReturn(func(prompt, model string) gollm.Chat {
+		systemPrompt = prompt
+		return chat
+	})
+	client.EXPECT().Close().Return(nil)
+	chat.EXPECT().Initialize(gomock.Any()).Return(nil)
+	chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+	chat.EXPECT().SendStreaming(gomock.Any(), gomock.Any()).Return(gollm.ChatResponseIterator(func(yield func(gollm.ChatResponse, error) bool) {
+		yield(chatWith(fText("The service has no endpoints.")), nil)
+	}), nil)
+
+	sessionManager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	manager := NewAgentManager(func(ctx context.Context) (*Agent, error) {
+		var toolset tools.Tools
+		toolset.Init()
+		return &Agent{LLM: client, Model: "test-model", Tools: toolset, MaxIterations: 20}, nil
+	}, sessionManager)
+
+	parent := &Agent{
+		Model:   "test-model",
+		Session: &api.Session{ID: "parent"},
+		manager: manager,
+	}
+	before, err := sessionManager.ListSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := newDelegateTool(parent).Run(ctx, map[string]any{
+		"focus": "networking",
+		"task":  "Find out why web can't reach api in namespace prod",
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	got := result.(map[string]any)
+	if got["summary"] != "The service has no endpoints." {
+		t.Errorf("expected the sub-agent's answer as the summary, got %v", got)
+	}
+	if _, ok := got["error"]; ok {
+		t.Errorf("unexpected error: %v", got["error"])
+	}
+	if !strings.Contains(systemPrompt, "delegated the networking part") {
+		t.Errorf("expected the system prompt to be narrowed to the focus, got %q", systemPrompt)
+	}
+	if after, err := sessionManager.ListSessions(); err != nil || len(after) != len(before) {
+		t.Errorf("expected the child session to be deleted, got %d sessions instead of %d (err %v)", len(after), len(before), err)
+	}
+}
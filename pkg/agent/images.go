@@ -0,0 +1,107 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// maxImageBytes is the largest image that can be attached to a query.
+const maxImageBytes = 5 * 1024 * 1024
+
+// defaultImageQuery is sent with an image attached without a question.
+const defaultImageQuery = "Explain what this image shows, and point out anything that looks wrong."
+
+// imageExtensions are the file extensions of the images that can be attached.
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// parseImageQuery parses queries of the form "image <path> [question]",
+// returning the path and the question. The path must have an image
+// extension, so that questions such as "image pull errors on node-1" are
+// sent to the LLM as they are.
+func parseImageQuery(query string) (path string, question string, ok bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "image ")
+	if !ok {
+		return "", "", false
+	}
+	path, question, _ = strings.Cut(strings.TrimSpace(rest), " ")
+	if !imageExtensions[strings.ToLower(filepath.Ext(path))] {
+		return "", "", false
+	}
+	return path, strings.TrimSpace(question), true
+}
+
+// loadImage reads the image at path.
+func loadImage(path string) (api.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return api.Image{}, fmt.Errorf("reading image: %w", err)
+	}
+	image := api.Image{
+		Name:     filepath.Base(path),
+		MIMEType: http.DetectContentType(data),
+		Data:     data,
+	}
+	return image, validateImage(image)
+}
+
+// validateImages checks that images can be sent to the LLM.
+func validateImages(images []api.Image) error {
+	for _, image := range images {
+		if err := validateImage(image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateImage checks that image is an image and not too large to send.
+func validateImage(image api.Image) error {
+	if !strings.HasPrefix(image.MIMEType, "image/") {
+		return fmt.Errorf("%s is not an image (%s)", image.Name, image.MIMEType)
+	}
+	if len(image.Data) > maxImageBytes {
+		return fmt.Errorf("%s is too large (%d bytes), images can be at most %d bytes", image.Name, len(image.Data), maxImageBytes)
+	}
+	return nil
+}
+
+// attachImages records the images in the session, without their data, and
+// returns them as content for the LLM.
+func (c *Agent) attachImages(images []api.Image) []any {
+	var contents []any
+	for _, image := range images {
+		c.addMessage(api.MessageSourceUser, api.MessageTypeImage, api.Image{
+			Name:     image.Name,
+			MIMEType: image.MIMEType,
+			Size:     len(image.Data),
+		})
+		contents = append(contents, gollm.ImageContent{MIMEType: image.MIMEType, Data: image.Data})
+	}
+	return contents
+}
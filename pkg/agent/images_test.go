@@ -0,0 +1,78 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestParseImageQuery(t *testing.T) {
+	tests := []struct {
+		query        string
+		wantPath     string
+		wantQuestion string
+		wantOK       bool
+	}{
+		{query: "image panel.png why is latency high?", wantPath: "panel.png", wantQuestion: "why is latency high?", wantOK: true},
+		{query: "image panel.png", wantPath: "panel.png", wantOK: true},
+		{query: "  image   panel.png   ", wantPath: "panel.png", wantOK: true},
+		{query: "image screenshots/Panel.JPG", wantPath: "screenshots/Panel.JPG", wantOK: true},
+		{query: "images of pods", wantOK: false},
+		{query: "image pull errors on node-1", wantOK: false},
+		{query: "image registry.example.com/web:v2 fails to pull", wantOK: false},
+		{query: "show me the image", wantOK: false},
+	}
+	for _, tt := range tests {
+		path, question, ok := parseImageQuery(tt.query)
+		if ok != tt.wantOK || path != tt.wantPath || question != tt.wantQuestion {
+			t.Errorf("parseImageQuery(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.query, path, question, ok, tt.wantPath, tt.wantQuestion, tt.wantOK)
+		}
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	dir := t.TempDir()
+
+	png := filepath.Join(dir, "panel.png")
+	if err := os.WriteFile(png, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	image, err := loadImage(png)
+	if err != nil {
+		t.Fatalf("loadImage(%q) returned error: %v", png, err)
+	}
+	if image.Name != "panel.png" || image.MIMEType != "image/png" {
+		t.Errorf("loadImage(%q) = %q (%s), want panel.png (image/png)", png, image.Name, image.MIMEType)
+	}
+
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadImage(text); err == nil {
+		t.Errorf("loadImage(%q) returned no error for a text file", text)
+	}
+}
+
+func TestValidateImage_TooLarge(t *testing.T) {
+	image := api.Image{Name: "big.png", MIMEType: "image/png", Data: make([]byte, maxImageBytes+1)}
+	if err := validateImage(image); err == nil {
+		t.Errorf("validateImage accepted an image of %d bytes", len(image.Data))
+	}
+}
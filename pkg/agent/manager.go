@@ -17,7 +17,9 @@ package agent
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
@@ -31,20 +33,40 @@ type Factory func(context.Context) (*Agent, error)
 type AgentManager struct {
 	factory        Factory
 	sessionManager *sessions.SessionManager
-	agents         map[string]*Agent // sessionID -> agent
+	agents         map[string]*Agent    // sessionID -> agent
+	lastUsed       map[string]time.Time // sessionID -> when GetAgent last returned its agent
 	mu             sync.RWMutex
 	onAgentCreated func(*Agent)
+
+	// maxAgents is the maximum number of agents kept alive, zero means no limit.
+	maxAgents int
+	// idleTTL is how long an agent may stay idle before it is shut down, zero means forever.
+	idleTTL time.Duration
 }
 
+// agentShutdownTimeout bounds how long shutting down an agent waits for its loop to exit.
+const agentShutdownTimeout = 5 * time.Second
+
 // NewAgentManager creates a new Manager.
 func NewAgentManager(factory Factory, sessionManager *sessions.SessionManager) *AgentManager {
 	return &AgentManager{
 		factory:        factory,
 		sessionManager: sessionManager,
 		agents:         make(map[string]*Agent),
+		lastUsed:       make(map[string]time.Time),
 	}
 }
 
+// SetLimits bounds the number of agents kept alive, and how long they may stay
+// idle, see EvictIdleAgents. Agents shut down are started again, from their
+// saved session, the next time they are needed.
+func (sm *AgentManager) SetLimits(maxAgents int, idleTTL time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.maxAgents = maxAgents
+	sm.idleTTL = idleTTL
+}
+
 // SetAgentCreatedCallback sets the callback to be called when a new agent is created.
 // It also calls the callback immediately for all currently active agents.
 func (sm *AgentManager) SetAgentCreatedCallback(cb func(*Agent)) {
@@ -58,9 +80,12 @@ func (sm *AgentManager) SetAgentCreatedCallback(cb func(*Agent)) {
 
 // GetAgent returns the agent for the given session ID, loading it if necessary.
 func (sm *AgentManager) GetAgent(ctx context.Context, sessionID string) (*Agent, error) {
-	sm.mu.RLock()
+	sm.mu.Lock()
 	agent, ok := sm.agents[sessionID]
-	sm.mu.RUnlock()
+	if ok {
+		sm.lastUsed[sessionID] = time.Now()
+	}
+	sm.mu.Unlock()
 
 	if ok {
 		return agent, nil
@@ -71,6 +96,10 @@ func (sm *AgentManager) GetAgent(ctx context.Context, sessionID string) (*Agent,
 		return nil, fmt.Errorf("session not found: %w", err)
 	}
 
+	if err := sm.makeRoom(); err != nil {
+		return nil, err
+	}
+
 	newAgent, err := sm.factory(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("creating agent: %w", err)
@@ -79,22 +108,149 @@ func (sm *AgentManager) GetAgent(ctx context.Context, sessionID string) (*Agent,
 	return sm.startAgent(ctx, session, newAgent)
 }
 
-// Close closes all active agents.
+// Close shuts down all active agents.
 func (sm *AgentManager) Close() error {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	agents := sm.agents
+	// Clear the maps
+	sm.agents = make(map[string]*Agent)
+	sm.lastUsed = make(map[string]time.Time)
+	sm.mu.Unlock()
 
-	for id, agent := range sm.agents {
+	for id, agent := range agents {
 		klog.Infof("Closing agent for session %s", id)
-		if err := agent.Close(); err != nil {
-			klog.Errorf("Error closing agent %s: %v", id, err)
+		sm.shutdownAgent(agent)
+	}
+	return nil
+}
+
+// EvictIdleAgents shuts down the agents that have been idle for longer than
+// the idle TTL set with SetLimits, until ctx is done. It returns immediately
+// if there is no idle TTL.
+func (sm *AgentManager) EvictIdleAgents(ctx context.Context) {
+	sm.mu.RLock()
+	idleTTL := sm.idleTTL
+	sm.mu.RUnlock()
+	if idleTTL <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(min(idleTTL/2, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sm.evictIdle(now.Add(-idleTTL))
 		}
 	}
-	// Clear the map
-	sm.agents = make(map[string]*Agent)
+}
+
+// evictIdle shuts down the idle agents last active before cutoff.
+func (sm *AgentManager) evictIdle(cutoff time.Time) {
+	var evicted []*Agent
+	sm.mu.Lock()
+	idleTTL := sm.idleTTL
+	for id, agent := range sm.agents {
+		if isIdle(agent) && sm.lastActive(id, agent).Before(cutoff) {
+			evicted = append(evicted, agent)
+			sm.remove(id)
+		}
+	}
+	sm.mu.Unlock()
+
+	for _, agent := range evicted {
+		klog.Infof("Shutting down agent for session %s, idle for more than %s", agent.Session.ID, idleTTL)
+		sm.shutdownAgent(agent)
+	}
+}
+
+// makeRoom shuts down the least recently active idle agent if the maximum
+// number of agents is reached. It fails if all the agents are busy.
+func (sm *AgentManager) makeRoom() error {
+	sm.mu.Lock()
+	if sm.maxAgents <= 0 || len(sm.agents) < sm.maxAgents {
+		sm.mu.Unlock()
+		return nil
+	}
+	var oldest *Agent
+	for id, agent := range sm.agents {
+		if isIdle(agent) && (oldest == nil || sm.lastActive(id, agent).Before(sm.lastActive(oldest.Session.ID, oldest))) {
+			oldest = agent
+		}
+	}
+	if oldest == nil {
+		sm.mu.Unlock()
+		return fmt.Errorf("all %d agents are busy, try again later", sm.maxAgents)
+	}
+	sm.remove(oldest.Session.ID)
+	sm.mu.Unlock()
+
+	klog.Infof("Shutting down agent for session %s to stay within %d agents", oldest.Session.ID, sm.maxAgents)
+	sm.shutdownAgent(oldest)
 	return nil
 }
 
+// isIdle reports whether the agent can be shut down without interrupting it.
+func isIdle(agent *Agent) bool {
+	switch agent.AgentState() {
+	case api.AgentStateRunning, api.AgentStateInitializing:
+		return false
+	}
+	return true
+}
+
+// lastActive returns when the agent was last used, or last changed its session.
+func (sm *AgentManager) lastActive(id string, agent *Agent) time.Time {
+	agent.sessionMu.Lock()
+	lastModified := agent.Session.LastModified
+	agent.sessionMu.Unlock()
+	if lastUsed := sm.lastUsed[id]; lastUsed.After(lastModified) {
+		return lastUsed
+	}
+	return lastModified
+}
+
+// remove forgets the agent of a session. The caller must hold mu.
+func (sm *AgentManager) remove(id string) {
+	delete(sm.agents, id)
+	delete(sm.lastUsed, id)
+}
+
+// shutdownAgent stops the loop of an agent that was removed from the
+// manager, saves its session and releases its resources: its sandbox, MCP
+// connections and LLM client. An in-flight turn is cancelled, and its partial
+// response kept. Its output channel is closed, which ends the listeners of the UI.
+func (sm *AgentManager) shutdownAgent(agent *Agent) {
+	if agent.cancel != nil {
+		agent.cancel()
+	}
+	loopExited := true
+	if agent.loopDone != nil {
+		select {
+		case <-agent.loopDone:
+		case <-time.After(agentShutdownTimeout):
+			klog.Warningf("Agent loop for session %s did not exit within %s", agent.Session.ID, agentShutdownTimeout)
+			loopExited = false
+		}
+	}
+	if err := agent.Close(); err != nil {
+		klog.Errorf("Error closing agent %s: %v", agent.Session.ID, err)
+	}
+
+	agent.sessionMu.Lock()
+	err := sm.sessionManager.UpdateSession(agent.Session)
+	agent.sessionMu.Unlock()
+	if err != nil {
+		klog.Errorf("Error saving session %s: %v", agent.Session.ID, err)
+	}
+	// The loop may still be sending output
+	if loopExited {
+		agent.closeOutput()
+	}
+}
+
 // ListSessions delegates to the underlying store.
 func (sm *AgentManager) ListSessions() ([]*api.Session, error) {
 	return sm.sessionManager.ListSessions()
@@ -110,7 +266,7 @@ func (sm *AgentManager) DeleteSession(id string) error {
 	sm.mu.Lock()
 	if agent, ok := sm.agents[id]; ok {
 		agent.Close()
-		delete(sm.agents, id)
+		sm.remove(id)
 	}
 	sm.mu.Unlock()
 	return sm.sessionManager.DeleteSession(id)
@@ -123,6 +279,7 @@ func (sm *AgentManager) UpdateLastAccessed(session *api.Session) error {
 
 func (sm *AgentManager) startAgent(ctx context.Context, session *api.Session, agent *Agent) (*Agent, error) {
 	agent.Session = session
+	agent.manager = sm
 
 	if err := agent.Init(ctx); err != nil {
 		return nil, fmt.Errorf("initializing agent: %w", err)
@@ -138,6 +295,7 @@ func (sm *AgentManager) startAgent(ctx context.Context, session *api.Session, ag
 
 	sm.mu.Lock()
 	sm.agents[session.ID] = agent
+	sm.lastUsed[session.ID] = time.Now()
 	if sm.onAgentCreated != nil {
 		sm.onAgentCreated(agent)
 	}
@@ -145,3 +303,142 @@ func (sm *AgentManager) startAgent(ctx context.Context, session *api.Session, ag
 
 	return agent, nil
 }
+
+// startSubAgent creates and initializes a sub-agent of parent, in a new child
+// session, to investigate task within focus. The sub-agent runs the task once
+// in read-only mode, with the permission mode of parent; the caller runs it
+// and ends it with endSubAgent.
+func (sm *AgentManager) startSubAgent(ctx context.Context, parent *Agent, focus, task string) (*Agent, error) {
+	session, err := sm.sessionManager.NewSession(sessions.Metadata{
+		ProviderID: parent.Provider,
+		ModelID:    parent.Model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating session: %w", err)
+	}
+	child, err := sm.factory(ctx)
+	if err != nil {
+		sm.deleteSubAgentSession(session.ID)
+		return nil, fmt.Errorf("creating agent: %w", err)
+	}
+	child.Session = session
+	child.Focus = focus
+	child.InitialQuery = task
+	child.RunOnce = true
+	child.ReadOnly = true
+	child.SkipPermissions = parent.SkipPermissions
+	child.EnableDelegation = false
+	child.AutoNameSessions = false
+	child.Notifier = nil
+	child.MaxIterations = parent.SubAgentIterations
+	if child.MaxIterations <= 0 {
+		child.MaxIterations = defaultSubAgentMaxIterations
+	}
+	if err := child.Init(ctx); err != nil {
+		sm.endSubAgent(child)
+		return nil, fmt.Errorf("initializing agent: %w", err)
+	}
+
+	klog.Infof("Started sub-agent in session %s for session %s, focus %q", session.ID, parent.Session.ID, focus)
+	return child, nil
+}
+
+// endSubAgent closes a sub-agent and deletes its session, whose findings the
+// parent has in the result of the delegate call.
+func (sm *AgentManager) endSubAgent(child *Agent) {
+	if err := child.Close(); err != nil {
+		klog.Warningf("error closing sub-agent %s: %v", child.Session.ID, err)
+	}
+	sm.deleteSubAgentSession(child.Session.ID)
+}
+
+func (sm *AgentManager) deleteSubAgentSession(id string) {
+	if err := sm.sessionManager.DeleteSession(id); err != nil {
+		klog.Warningf("error deleting the session %s of a sub-agent: %v", id, err)
+	}
+}
+
+// PendingApproval is a choice an agent waits for the user to make, e.g. the
+// approval of tool calls that modify resources.
+type PendingApproval struct {
+	SessionID   string                 `json:"sessionId"`
+	SessionName string                 `json:"sessionName,omitempty"`
+	MessageID   string                 `json:"messageId"`
+	Prompt      string                 `json:"prompt"`
+	Options     []api.UserChoiceOption `json:"options"`
+	// Since is when the agent asked for the choice.
+	Since time.Time `json:"since"`
+}
+
+// PendingApprovals lists the choices the active agents wait for, oldest
+// first, so that they can be made without opening each session.
+func (sm *AgentManager) PendingApprovals() []PendingApproval {
+	sm.mu.RLock()
+	agents := make([]*Agent, 0, len(sm.agents))
+	for _, agent := range sm.agents {
+		agents = append(agents, agent)
+	}
+	sm.mu.RUnlock()
+
+	var approvals []PendingApproval
+	for _, agent := range agents {
+		if approval, ok := agent.PendingApproval(); ok {
+			approvals = append(approvals, approval)
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].Since.Before(approvals[j].Since)
+	})
+	return approvals
+}
+
+// pendingChoice is a choice request message of a session, kept in memory so
+// that the pending approvals are listed without reading the sessions.
+type pendingChoice struct {
+	sessionID string
+	message   *api.Message
+	request   *api.UserChoiceRequest
+}
+
+// PendingApproval returns the choice the agent waits for the user to make,
+// if any.
+func (c *Agent) PendingApproval() (PendingApproval, bool) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.pendingApproval()
+}
+
+// pendingApproval is PendingApproval. The caller must hold sessionMu.
+func (c *Agent) pendingApproval() (PendingApproval, bool) {
+	pending, session := c.pendingChoice, c.Session
+	if pending == nil || session == nil || pending.sessionID != session.ID || c.agentState() != api.AgentStateWaitingForInput {
+		return PendingApproval{}, false
+	}
+	return PendingApproval{
+		SessionID:   session.ID,
+		SessionName: session.Name,
+		MessageID:   pending.message.ID,
+		Prompt:      pending.request.Prompt,
+		Options:     pending.request.Options,
+		Since:       pending.message.Timestamp,
+	}, true
+}
+
+// AnswerChoice sends the user's choice to the agent. If messageID is set, the
+// choice answers that choice request message, and is only sent if the agent
+// still waits for it: it returns false if the choice was already made, e.g.
+// in another tab. A choice is only sent once for a request.
+func (c *Agent) AnswerChoice(messageID string, choice *api.UserChoiceResponse) bool {
+	c.sessionMu.Lock()
+	if messageID != "" {
+		if pending, ok := c.pendingApproval(); !ok || pending.MessageID != messageID {
+			c.sessionMu.Unlock()
+			return false
+		}
+	}
+	c.pendingChoice = nil
+	c.sessionMu.Unlock()
+
+	c.Input <- choice
+	return true
+}
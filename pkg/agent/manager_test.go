@@ -0,0 +1,224 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"go.uber.org/mock/gomock"
+)
+
+// addTestAgent adds an agent for a new session, last active at lastActive.
+func addTestAgent(t *testing.T, sm *AgentManager, state api.AgentState, lastActive time.Time) *Agent {
+	t.Helper()
+	session, err := sm.sessionManager.NewSession(sessions.Metadata{})
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	session.AgentState = state
+	session.LastModified = lastActive
+	agent := &Agent{Session: session, Output: make(chan any, 10)}
+	sm.agents[session.ID] = agent
+	sm.lastUsed[session.ID] = lastActive
+	return agent
+}
+
+func TestAgentManager_EvictIdle(t *testing.T) {
+	sessionManager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	sm := NewAgentManager(nil, sessionManager)
+	now := time.Now()
+	stale := addTestAgent(t, sm, api.AgentStateDone, now.Add(-time.Hour))
+	busy := addTestAgent(t, sm, api.AgentStateRunning, now.Add(-time.Hour))
+	recent := addTestAgent(t, sm, api.AgentStateDone, now)
+
+	sm.evictIdle(now.Add(-30 * time.Minute))
+
+	if _, ok := sm.agents[stale.Session.ID]; ok {
+		t.Errorf("expected the stale agent to be evicted")
+	}
+	if _, ok := <-stale.Output; ok {
+		t.Errorf("expected the output of the evicted agent to be closed")
+	}
+	if _, ok := sm.agents[busy.Session.ID]; !ok {
+		t.Errorf("expected the running agent to be kept")
+	}
+	if _, ok := sm.agents[recent.Session.ID]; !ok {
+		t.Errorf("expected the recently used agent to be kept")
+	}
+}
+
+func TestAgentManager_MakeRoom(t *testing.T) {
+	sessionManager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	sm := NewAgentManager(nil, sessionManager)
+	sm.SetLimits(2, 0)
+	now := time.Now()
+	busy := addTestAgent(t, sm, api.AgentStateRunning, now.Add(-time.Hour))
+	idle := addTestAgent(t, sm, api.AgentStateWaitingForInput, now.Add(-time.Minute))
+
+	if err := sm.makeRoom(); err != nil {
+		t.Fatalf("makeRoom() error = %v", err)
+	}
+	if _, ok := sm.agents[idle.Session.ID]; ok {
+		t.Errorf("expected the idle agent to be shut down to make room")
+	}
+	if _, ok := sm.agents[busy.Session.ID]; !ok {
+		t.Errorf("expected the running agent to be kept")
+	}
+
+	addTestAgent(t, sm, api.AgentStateRunning, now)
+	if err := sm.makeRoom(); err == nil {
+		t.Errorf("expected an error when all agents are busy")
+	}
+}
+
+func TestAgentManager_CloseKeepsPartialResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := mocks.NewMockClient(ctrl)
+	chat := mocks.NewMockChat(ctrl)
+	client.EXPECT().StartChat(gomock.Any(), "test-model").Return(chat)
+	client.EXPECT().Close().Return(nil)
+	chat.EXPECT().Initialize(gomock.Any()).Return(nil)
+	chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+	// The response is cut off by the shutdown
+	chat.EXPECT().SendStreaming(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, contents ...any) (gollm.ChatResponseIterator, error) {
+		return func(yield func(gollm.ChatResponse, error) bool) {
+			if !yield(chatWith(fText("Checking the pods")), nil) {
+				return
+			}
+			<-ctx.Done()
+			yield(nil, ctx.Err())
+		}, nil
+	})
+
+	sessionManager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	sm := NewAgentManager(func(ctx context.Context) (*Agent, error) {
+		var toolset tools.Tools
+		toolset.Init()
+		return &Agent{LLM: client, Model: "test-model", Tools: toolset, MaxIterations: 5, InitialQuery: "why is web down?"}, nil
+	}, sessionManager)
+	session, err := sessionManager.NewSession(sessions.Metadata{})
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	agent, err := sm.GetAgent(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("GetAgent() error = %v", err)
+	}
+	recvUntil(t, ctx, agent.Output, func(m *api.Message) bool {
+		return m.Type == api.MessageTypeTextDelta
+	})
+
+	sm.Close()
+
+	var partial bool
+	for _, message := range agent.Session.ChatMessageStore.ChatMessages() {
+		if message.Source == api.MessageSourceModel && message.Payload == "Checking the pods" {
+			partial = true
+		}
+	}
+	if !partial {
+		t.Errorf("expected the partial response to be kept in the session")
+	}
+	if _, err := sm.sessionManager.FindSessionByID(session.ID); err != nil {
+		t.Errorf("expected the session to be saved: %v", err)
+	}
+}
+
+func TestAgentManager_PendingApprovals(t *testing.T) {
+	sessionManager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	sm := NewAgentManager(nil, sessionManager)
+	now := time.Now()
+	request := &api.UserChoiceRequest{
+		Prompt:  "The following commands require your approval to run:\n* kubectl delete pod web-0",
+		Options: []api.UserChoiceOption{{Value: "yes", Label: "Yes"}, {Value: "no", Label: "No"}},
+	}
+	var waiting []*Agent
+	for i := range 2 {
+		agent := addTestAgent(t, sm, api.AgentStateWaitingForInput, now)
+		agent.storeMessage(&api.Message{
+			ID:        fmt.Sprintf("choice-%d", i),
+			Source:    api.MessageSourceAgent,
+			Type:      api.MessageTypeUserChoiceRequest,
+			Payload:   request,
+			Timestamp: now.Add(-time.Duration(i) * time.Minute),
+		})
+		waiting = append(waiting, agent)
+	}
+	// Waiting for a query, not a choice
+	addTestAgent(t, sm, api.AgentStateWaitingForInput, now)
+	addTestAgent(t, sm, api.AgentStateRunning, now)
+
+	approvals := sm.PendingApprovals()
+	if len(approvals) != 2 {
+		t.Fatalf("PendingApprovals() = %+v, want 2 approvals", approvals)
+	}
+	// Oldest first
+	if approvals[0].SessionID != waiting[1].Session.ID || approvals[0].MessageID != "choice-1" || approvals[1].SessionID != waiting[0].Session.ID {
+		t.Errorf("PendingApprovals() = %+v, want the approvals of the waiting sessions, oldest first", approvals)
+	}
+	if approvals[0].Prompt != request.Prompt || len(approvals[0].Options) != 2 {
+		t.Errorf("PendingApprovals()[0] = %+v, want the choice request", approvals[0])
+	}
+
+	// A choice request is answered once
+	agent := waiting[0]
+	agent.Input = make(chan any, 10)
+	if agent.AnswerChoice("choice-1", &api.UserChoiceResponse{Choice: 1}) {
+		t.Errorf("expected the choice for another request to be refused")
+	}
+	if !agent.AnswerChoice("choice-0", &api.UserChoiceResponse{Choice: 1}) {
+		t.Errorf("expected the choice to be sent")
+	}
+	if agent.AnswerChoice("choice-0", &api.UserChoiceResponse{Choice: 3}) {
+		t.Errorf("expected the second choice for the request to be refused")
+	}
+	if len(agent.Input) != 1 {
+		t.Errorf("expected a single choice to be sent, got %d", len(agent.Input))
+	}
+	if approvals := sm.PendingApprovals(); len(approvals) != 1 || approvals[0].SessionID != waiting[1].Session.ID {
+		t.Errorf("PendingApprovals() = %+v, want the approval that wasn't made", approvals)
+	}
+
+	// Any message after the request means it was answered
+	waiting[1].storeMessage(&api.Message{ID: "text", Type: api.MessageTypeText, Payload: "Interrupted."})
+	if approvals := sm.PendingApprovals(); len(approvals) != 0 {
+		t.Errorf("PendingApprovals() = %+v, want none", approvals)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"k8s.io/klog/v2"
+)
+
+// SwitchModel switches the session to model, and to provider if it is not
+// empty, e.g. to escalate from a cheap model to a stronger one. The
+// conversation so far is replayed in a new chat with the model. Queries no
+// routing rule matches use the model from then on.
+func (c *Agent) SwitchModel(ctx context.Context, model, provider string) error {
+	if err := c.switchModel(ctx, model, provider, c.Session.ChatMessageStore.ChatMessages()); err != nil {
+		return err
+	}
+	c.sessionModel, c.sessionProvider = c.Model, c.Provider
+	return nil
+}
+
+// restoreSessionModel uses the model and provider saved in the session, e.g.
+// by a model switch before the session was resumed, instead of the ones the
+// agent was created with.
+func (c *Agent) restoreSessionModel(ctx context.Context) error {
+	model, provider := c.Session.ModelID, c.Session.ProviderID
+	if model == "" {
+		return nil
+	}
+	if provider != "" && provider != c.Provider {
+		if c.NewLLM == nil {
+			return fmt.Errorf("restoring provider %s of the session is not supported", provider)
+		}
+		client, err := c.NewLLM(ctx, provider)
+		if err != nil {
+			return fmt.Errorf("creating %s client: %w", provider, err)
+		}
+		if c.LLM != nil {
+			closeLLM(c.LLM)
+		}
+		c.LLM, c.Provider = client, provider
+	}
+	if model != c.Model {
+		klog.FromContext(ctx).Info("Restoring the model of the session", "model", model, "provider", c.Provider)
+		c.Model = model
+	}
+	return nil
+}
+
+// isModelSwitch reports whether the fields of a query are a switch to another
+// model: "model <name>" where name is one of the available models, or "model
+// <name> <provider>" where provider is a known provider. Other queries
+// starting with "model", e.g. "model the traffic between services", are sent
+// to the LLM. The available models aren't checked if they can't be listed.
+func (c *Agent) isModelSwitch(ctx context.Context, fields []string) bool {
+	if len(fields) < 2 || len(fields) > 3 || fields[0] != "model" {
+		return false
+	}
+	if len(fields) == 3 {
+		return slices.Contains(gollm.ListProviders(), fields[2])
+	}
+	models, err := c.listModels(ctx)
+	if err != nil {
+		klog.FromContext(ctx).Info("Cannot list the models to check a model switch", "err", err)
+		return true
+	}
+	return slices.Contains(models, fields[1])
+}
+
+// switchModel switches the chat to model and provider, replaying history,
+// see SwitchModel.
+func (c *Agent) switchModel(ctx context.Context, model, provider string, history []*api.Message) error {
+	previousLLM, previousModel, previousProvider, previousChat := c.LLM, c.Model, c.Provider, c.llmChat
+
+	llm := c.LLM
+	if provider == "" {
+		provider = c.Provider
+	}
+	if provider != c.Provider {
+		if c.NewLLM == nil {
+			return fmt.Errorf("switching the provider is not supported")
+		}
+		client, err := c.NewLLM(ctx, provider)
+		if err != nil {
+			return fmt.Errorf("creating %s client: %w", provider, err)
+		}
+		llm = client
+	}
+
+	c.LLM, c.Model, c.Provider = llm, model, provider
+	err := c.startChatWithHistory(ctx, history)
+	if err == nil {
+		err = c.setFunctionDefinitions()
+	}
+	if err != nil {
+		if llm != previousLLM {
+			closeLLM(llm)
+		}
+		c.LLM, c.Model, c.Provider, c.llmChat = previousLLM, previousModel, previousProvider, previousChat
+		return err
+	}
+	if llm != previousLLM {
+		closeLLM(previousLLM)
+		c.availableModels = nil
+	}
+
+	c.sessionMu.Lock()
+	session := c.Session
+	session.ModelID = c.Model
+	session.ProviderID = c.Provider
+	c.sessionMu.Unlock()
+
+	if c.SessionBackend != "filesystem" && !sessions.IsRemoteBackend(c.SessionBackend) {
+		return nil
+	}
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		return fmt.Errorf("failed to create session manager: %w", err)
+	}
+	if err := manager.UpdateLastAccessed(session); err != nil {
+		return fmt.Errorf("failed to save the model of the session: %w", err)
+	}
+	return nil
+}
+
+// fallBackModel switches the chat to FallbackModel, or to the available model
+// closest to the current one, if err is a ModelNotFoundError, so that the
+// request can be sent again. It reports whether the model was switched.
+func (c *Agent) fallBackModel(ctx context.Context, err error) bool {
+	var notFoundErr *gollm.ModelNotFoundError
+	if !errors.As(err, &notFoundErr) {
+		return false
+	}
+	log := klog.FromContext(ctx)
+
+	model := c.FallbackModel
+	if model == "" || model == c.Model {
+		models, err := c.listModels(ctx)
+		if err != nil {
+			log.Error(err, "error listing models to replace a missing model", "model", c.Model)
+			return false
+		}
+		model = gollm.ClosestModel(c.Model, slices.DeleteFunc(slices.Clone(models), func(m string) bool { return m == c.Model }))
+		if model == "" {
+			return false
+		}
+	}
+
+	// The contents being sent follow the last response of the model, they are
+	// sent again once the chat is switched
+	history := c.Session.ChatMessageStore.ChatMessages()
+	i := len(history)
+	for i > 0 && history[i-1].Source != api.MessageSourceModel {
+		i--
+	}
+	history = history[:i]
+
+	missing := c.Model
+	if err := c.switchModel(ctx, model, "", history); err != nil {
+		log.Error(err, "error switching to the fallback model", "model", model)
+		return false
+	}
+	if c.sessionModel == missing && c.sessionProvider == c.Provider {
+		c.sessionModel = model
+	}
+	log.Info("Switched to a fallback model", "missing", missing, "model", model, "err", err)
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText,
+		fmt.Sprintf("Warning: model `%s` was not found, using `%s` instead. Retrying.", missing, model))
+	return true
+}
+
+func closeLLM(client gollm.Client) {
+	if err := client.Close(); err != nil {
+		klog.Warningf("error closing LLM client: %v", err)
+	}
+}
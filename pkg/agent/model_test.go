@@ -0,0 +1,98 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"go.uber.org/mock/gomock"
+)
+
+// missingModelChat fails every request as if the model didn't exist.
+type missingModelChat struct {
+	gollm.Chat
+	sent int
+}
+
+func (c *missingModelChat) SendStreaming(ctx context.Context, contents ...any) (gollm.ChatResponseIterator, error) {
+	c.sent++
+	return nil, gollm.ClassifyError(&gollm.APIError{StatusCode: 404, Message: "The model `gemini-9` does not exist or you do not have access to it."})
+}
+
+func TestSendStreaming_FallsBackOnModelNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := sessions.NewInMemoryChatStore()
+	earlier := []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "hello"},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeText, Payload: "Hi!"},
+	}
+	_ = store.SetChatMessages(append(earlier, &api.Message{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "why is my pod failing?"}))
+
+	chat := mocks.NewMockChat(ctrl)
+	// The query is not replayed, it is sent again to the fallback model
+	chat.EXPECT().Initialize(earlier).Return(nil)
+	chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil)
+	chat.EXPECT().SendStreaming(gomock.Any(), "why is my pod failing?").Return(func(yield func(gollm.ChatResponse, error) bool) {}, nil)
+	llm := mocks.NewMockClient(ctrl)
+	llm.EXPECT().ListModels(gomock.Any()).Return([]string{"gemini-9", "gemini-2.5-flash", "gpt-4.1"}, nil)
+	llm.EXPECT().StartChat(gomock.Any(), "gemini-2.5-flash").Return(chat)
+
+	missing := &missingModelChat{}
+	a := &Agent{
+		LLM:             llm,
+		Model:           "gemini-9",
+		Provider:        "gemini",
+		llmChat:         missing,
+		Output:          make(chan any, 10),
+		Session:         &api.Session{ChatMessageStore: store},
+		sessionModel:    "gemini-9",
+		sessionProvider: "gemini",
+	}
+	a.Tools.Init()
+
+	if _, err := a.sendStreaming(context.Background(), []any{"why is my pod failing?"}); err != nil {
+		t.Fatalf("expected the request to be sent to the fallback model, got %v", err)
+	}
+	if a.Model != "gemini-2.5-flash" || a.sessionModel != "gemini-2.5-flash" {
+		t.Errorf("expected the session to use gemini-2.5-flash, got %s (session %s)", a.Model, a.sessionModel)
+	}
+	messages := store.ChatMessages()
+	if warning, ok := messages[len(messages)-1].Payload.(string); !ok || !strings.Contains(warning, "`gemini-9` was not found") {
+		t.Errorf("expected a warning about the missing model, got %+v", messages[len(messages)-1])
+	}
+
+	// Tool call results are not sent to the fallback model, the fallback waits
+	// for the next query
+	a.llmChat, a.Model, a.availableModels = missing, "gemini-9", []string{"gemini-9", "gemini-2.5-flash"}
+	a.toolCallIDs.register([]gollm.FunctionCall{{ID: "call-1", Name: "kubectl"}})
+	result := gollm.FunctionCallResult{ID: "call-1", Name: "kubectl", Result: map[string]any{"stdout": "web 0/1"}}
+	if _, err := a.sendStreaming(context.Background(), []any{result}); err == nil || a.Model != "gemini-9" {
+		t.Errorf("expected the error without falling back while sending tool call results, got %v with model %s", err, a.Model)
+	}
+
+	// The error is returned if no other model is available
+	a.llmChat, a.Model, a.availableModels = missing, "gemini-9", []string{"gemini-9"}
+	if _, err := a.sendStreaming(context.Background(), []any{"why is my pod failing?"}); err == nil {
+		t.Error("expected the error without any other model available")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/notify"
+	"k8s.io/klog/v2"
+)
+
+// maxNotificationText bounds the answer sent in notifications, chat webhooks
+// reject long messages.
+const maxNotificationText = 3000
+
+// notificationTimeout bounds the delivery of a notification.
+const notificationTimeout = 30 * time.Second
+
+// notifyQueryDone tells the Notifier that the query that was running is done,
+// with its answer or its errors.
+func (c *Agent) notifyQueryDone() {
+	c.sessionMu.Lock()
+	pending := c.queryNotificationPending
+	c.queryNotificationPending = false
+	var messages []*api.Message
+	if pending && c.Session != nil && c.Session.ChatMessageStore != nil {
+		messages = c.Session.ChatMessageStore.ChatMessages()
+	}
+	c.sessionMu.Unlock()
+	if !pending || c.Notifier == nil {
+		return
+	}
+
+	// The answer is the last text of the model since the query
+	var query, answer string
+	var errs []string
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		text, _ := msg.Payload.(string)
+		if msg.Source == api.MessageSourceUser && msg.Type == api.MessageTypeText {
+			query = text
+			break
+		}
+		switch {
+		case msg.Type == api.MessageTypeError:
+			errs = append(errs, text)
+		case msg.Source == api.MessageSourceModel && msg.Type == api.MessageTypeText && answer == "":
+			answer = text
+		}
+	}
+
+	n := &notify.Notification{
+		Event:   notify.EventRunCompleted,
+		Title:   "kubectl-ai is done with: " + truncateText(query, 80),
+		Message: answer,
+		Details: map[string]string{"query": query},
+	}
+	if len(errs) > 0 {
+		n.Title = "kubectl-ai failed: " + truncateText(query, 80)
+		n.Message = strings.Join(errs, "\n")
+	}
+	c.sendNotification(n)
+}
+
+// notifyApprovalRequired tells the Notifier that commands wait for the user's
+// approval.
+func (c *Agent) notifyApprovalRequired(commands []string) {
+	if c.Notifier == nil {
+		return
+	}
+	c.sendNotification(&notify.Notification{
+		Event:   notify.EventApprovalRequired,
+		Title:   "kubectl-ai is waiting for your approval",
+		Message: "The following commands require your approval to run:\n* " + strings.Join(commands, "\n* "),
+	})
+}
+
+// sendNotification delivers n in the background, the agent loop waits for it
+// before exiting.
+func (c *Agent) sendNotification(n *notify.Notification) {
+	n.Time = time.Now()
+	n.Message = truncateText(n.Message, maxNotificationText)
+	if c.Session != nil {
+		n.SessionID = c.Session.ID
+		if c.UIBaseURL != "" {
+			n.URL = strings.TrimSuffix(c.UIBaseURL, "/") + "/?session=" + url.QueryEscape(c.Session.ID)
+		}
+	}
+
+	c.notifications.Add(1)
+	go func() {
+		defer c.notifications.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+		if err := c.Notifier.Notify(ctx, n); err != nil {
+			klog.Warningf("error sending %s notification: %v", n.Event, err)
+		}
+	}()
+}
+
+// truncateText trims text and cuts it to at most max bytes, plus an ellipsis.
+func truncateText(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= max {
+		return text
+	}
+	// Don't cut a multi-byte character in half
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
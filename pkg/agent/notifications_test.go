@@ -0,0 +1,96 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/notify"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+type memoryNotifier struct {
+	mu            sync.Mutex
+	notifications []*notify.Notification
+}
+
+func (n *memoryNotifier) Notify(ctx context.Context, notification *notify.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func TestAgent_NotifyQueryDone(t *testing.T) {
+	notifier := &memoryNotifier{}
+	a := &Agent{
+		Output:    make(chan any, 10),
+		Session:   &api.Session{ID: "test-session", ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Notifier:  notifier,
+		UIBaseURL: "http://localhost:8888/",
+	}
+
+	// Nothing to tell before a query ran
+	a.notifyQueryDone()
+
+	a.addMessage(api.MessageSourceUser, api.MessageTypeText, "why is web-0 pending?")
+	a.setAgentState(api.AgentStateRunning)
+	a.addMessage(api.MessageSourceModel, api.MessageTypeText, "Let me check.")
+	a.addMessage(api.MessageSourceModel, api.MessageTypeText, "The node is out of memory.")
+	a.setAgentState(api.AgentStateDone)
+	a.notifyQueryDone()
+	a.notifyQueryDone()
+	a.notifications.Wait()
+
+	a.notifyApprovalRequired([]string{"kubectl delete pod web-0"})
+	a.notifications.Wait()
+
+	if len(notifier.notifications) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(notifier.notifications))
+	}
+	done := notifier.notifications[0]
+	if done.Event != notify.EventRunCompleted || done.Message != "The node is out of memory." || !strings.Contains(done.Title, "why is web-0 pending?") {
+		t.Errorf("unexpected notification %+v", done)
+	}
+	if done.URL != "http://localhost:8888/?session=test-session" {
+		t.Errorf("unexpected session link %q", done.URL)
+	}
+	approval := notifier.notifications[1]
+	if approval.Event != notify.EventApprovalRequired || !strings.Contains(approval.Message, "kubectl delete pod web-0") {
+		t.Errorf("unexpected notification %+v", approval)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		max  int
+		want string
+	}{
+		{text: "  short  ", max: 10, want: "short"},
+		{text: "Pods pending", max: 4, want: "Pods…"},
+		// "é" is two bytes, it isn't cut in half.
+		{text: "Déploiement", max: 2, want: "D…"},
+		{text: "Pods en échec", max: 9, want: "Pods en …"},
+	} {
+		if got := truncateText(tc.text, tc.max); got != tc.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tc.text, tc.max, got, tc.want)
+		}
+	}
+}
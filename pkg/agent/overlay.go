@@ -0,0 +1,544 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog/v2"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+	"sigs.k8s.io/yaml"
+)
+
+// KindResolver returns the kind of a resource type as given to kubectl, e.g.
+// deploy or deployments.apps, in a kubeconfig context ("" for the current
+// one).
+type KindResolver func(kubeContext, resource string) (schema.GroupVersionKind, error)
+
+// OverlaySummary describes what WriteKustomizeOverlay captured.
+type OverlaySummary struct {
+	Resources int
+	Patches   int
+	// Skipped are the commands that changed the cluster but can't be
+	// expressed in the overlay, e.g. kubectl delete or scale.
+	Skipped []string
+}
+
+// overlayReadOnlyVerbs are the kubectl verbs that don't change the cluster.
+var overlayReadOnlyVerbs = map[string]bool{
+	"get": true, "describe": true, "logs": true, "top": true, "explain": true,
+	"events": true, "diff": true, "wait": true, "version": true, "auth": true,
+	"api-resources": true, "api-versions": true, "cluster-info": true,
+	"config": true, "port-forward": true, "proxy": true, "kustomize": true,
+}
+
+// kubectlValueFlags are the kubectl flags that take a value, so that it is
+// not mistaken for a positional argument.
+var kubectlValueFlags = map[string]string{
+	"-n": "namespace", "--namespace": "namespace",
+	"-f": "filename", "--filename": "filename",
+	"-p": "patch", "--patch": "patch", "--patch-file": "patch-file",
+	"--type": "type", "--context": "context", "--kubeconfig": "kubeconfig",
+	"-o": "output", "--output": "output", "-l": "selector", "--selector": "selector",
+	"--field-manager": "field-manager", "--dry-run": "dry-run",
+}
+
+type kustomization struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Resources  []string         `json:"resources,omitempty"`
+	Patches    []kustomizePatch `json:"patches,omitempty"`
+}
+
+type kustomizePatch struct {
+	Path   string           `json:"path"`
+	Target *kustomizeTarget `json:"target,omitempty"`
+}
+
+type kustomizeTarget struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// overlayBuilder collects the files of an overlay.
+type overlayBuilder struct {
+	resolveKind KindResolver
+	// files are the contents of the files of the overlay, by path.
+	files         map[string][]byte
+	kustomization kustomization
+	notes         []string
+	summary       OverlaySummary
+}
+
+// WriteKustomizeOverlay writes the manifests applied and the patches made in
+// session to dir as a kustomize overlay, so that the changes can be committed
+// to a GitOps repository instead of drifting from it. Applied manifests
+// become resources, kubectl patch and edit_resource calls become patches.
+// Other changes, e.g. deletions, are listed in kustomization.yaml as not
+// captured. dir must not exist or be empty, otherwise an error wrapping
+// fs.ErrExist is returned.
+func WriteKustomizeOverlay(dir string, session *api.Session, resolveKind KindResolver) (*OverlaySummary, error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("directory %s is not empty: %w", dir, fs.ErrExist)
+	}
+
+	b := &overlayBuilder{
+		resolveKind:   resolveKind,
+		files:         map[string][]byte{},
+		kustomization: kustomization{APIVersion: "kustomize.config.k8s.io/v1beta1", Kind: "Kustomization"},
+	}
+	contexts := map[string]bool{}
+	for _, command := range sessionCommands(session) {
+		if !command.done || command.failure != "" || toolCallNotationRegexp.MatchString(command.command) {
+			continue
+		}
+		if b.addCommand(command) && command.kubeContext != "" {
+			contexts[command.kubeContext] = true
+		}
+	}
+
+	var header strings.Builder
+	fmt.Fprintf(&header, "# Changes made by kubectl-ai in session %s.\n", session.ID)
+	header.WriteString("# Add the base this overlay applies to, e.g. ../base, to resources.\n")
+	if len(contexts) > 0 {
+		names := make([]string, 0, len(contexts))
+		for name := range contexts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&header, "# The changes were made in the kube-contexts: %s.\n", strings.Join(names, ", "))
+	}
+	for _, note := range b.notes {
+		header.WriteString(commentLines(note) + "\n")
+	}
+	if len(b.summary.Skipped) > 0 {
+		header.WriteString("#\n# These commands changed the cluster but are not captured by the overlay:\n")
+		for _, command := range b.summary.Skipped {
+			header.WriteString(commentLines("  "+command) + "\n")
+		}
+	}
+	body, err := yaml.Marshal(b.kustomization)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling kustomization: %w", err)
+	}
+	b.files["kustomization.yaml"] = append([]byte(header.String()), body...)
+
+	for name, content := range b.files {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("overlay file %q is outside of the overlay directory", name)
+		}
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return nil, fmt.Errorf("creating overlay directory: %w", err)
+		}
+		if err := writeNewFile(file, content); err != nil {
+			return nil, fmt.Errorf("writing overlay: %w", err)
+		}
+	}
+	return &b.summary, nil
+}
+
+// writeNewFile writes content to file, which must not exist yet, e.g. if it
+// was created since the overlay directory was checked.
+func writeNewFile(file string, content []byte) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// addCommand adds the changes made by a command to the overlay, and reports
+// whether it changed the cluster.
+func (b *overlayBuilder) addCommand(command *sessionCommand) bool {
+	calls, err := kubectlCalls(command.command)
+	if err != nil {
+		// It may have changed the cluster, better list it
+		klog.V(2).Infof("not exporting %q: %v", command.command, err)
+		b.summary.Skipped = append(b.summary.Skipped, command.command)
+		return true
+	}
+	changed := false
+	for _, call := range calls {
+		verb, flags, positional := parseKubectlCall(call.args)
+		if verb == "" || overlayReadOnlyVerbs[verb] || (verb == "rollout" && len(positional) > 0 && (positional[0] == "status" || positional[0] == "history")) {
+			continue
+		}
+		if dryRun, ok := flags["dry-run"]; ok && dryRun != "none" {
+			continue
+		}
+		changed = true
+
+		var err error
+		switch {
+		case (verb == "apply" || verb == "create" || verb == "replace") && flags["filename"] == "-" && call.stdin != "":
+			err = b.addManifests(call.stdin, flags["namespace"])
+		case verb == "patch" && flags["patch"] != "":
+			err = b.addPatch(command.kubeContext, positional, flags)
+		default:
+			err = errors.New("not expressible")
+		}
+		if err != nil {
+			klog.V(2).Infof("not exporting %q: %v", command.command, err)
+			b.summary.Skipped = append(b.summary.Skipped, command.command)
+			// The rest of the command is skipped with it
+			break
+		}
+	}
+	return changed
+}
+
+// addManifests adds the objects of manifests as resources. An object applied
+// again replaces the earlier version.
+func (b *overlayBuilder) addManifests(manifests string, namespace string) error {
+	var objects []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(manifests), 4096)
+	for {
+		var object map[string]any
+		if err := decoder.Decode(&object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("parsing manifests: %w", err)
+		}
+		if len(object) == 0 {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: object}
+		if u.IsList() {
+			if err := u.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		objects = append(objects, u)
+	}
+
+	for _, object := range objects {
+		if object.GetKind() == "" || object.GetName() == "" {
+			return fmt.Errorf("manifest without kind or name")
+		}
+		if object.GetNamespace() == "" && namespace != "" {
+			object.SetNamespace(namespace)
+		}
+		name, err := overlayFileName(object.GetNamespace(), object.GetKind(), object.GetName())
+		if err != nil {
+			return err
+		}
+		content, err := yaml.Marshal(object.Object)
+		if err != nil {
+			return fmt.Errorf("marshalling %s %s: %w", object.GetKind(), object.GetName(), err)
+		}
+		file := path.Join("resources", name+".yaml")
+		if _, ok := b.files[file]; !ok {
+			b.kustomization.Resources = append(b.kustomization.Resources, file)
+			b.summary.Resources++
+		}
+		b.files[file] = content
+	}
+	return nil
+}
+
+// addPatch adds a kubectl patch of a resource, given as type name or
+// type/name.
+func (b *overlayBuilder) addPatch(kubeContext string, positional []string, flags map[string]string) error {
+	var resource, name string
+	switch {
+	case len(positional) == 1 && strings.Contains(positional[0], "/"):
+		resource, name, _ = strings.Cut(positional[0], "/")
+	case len(positional) == 2:
+		resource, name = positional[0], positional[1]
+	default:
+		return fmt.Errorf("expected a single resource")
+	}
+	gvk, err := b.resolveKind(kubeContext, resource)
+	if err != nil {
+		return fmt.Errorf("resolving the kind of %s: %w", resource, err)
+	}
+	target := &kustomizeTarget{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Name:      name,
+		Namespace: flags["namespace"],
+	}
+
+	var patch any
+	if err := json.Unmarshal([]byte(flags["patch"]), &patch); err != nil {
+		if err := yaml.Unmarshal([]byte(flags["patch"]), &patch); err != nil {
+			return fmt.Errorf("parsing patch: %w", err)
+		}
+	}
+	fileName, err := overlayFileName(target.Namespace, target.Kind, target.Name)
+	if err != nil {
+		return err
+	}
+	file := path.Join("patches", fmt.Sprintf("%02d-%s.yaml", len(b.kustomization.Patches)+1, fileName))
+	switch patchType := flags["type"]; patchType {
+	case "json":
+		if _, ok := patch.([]any); !ok {
+			return fmt.Errorf("a json patch must be a list of operations")
+		}
+	case "", "strategic", "merge":
+		fields, ok := patch.(map[string]any)
+		if !ok {
+			return fmt.Errorf("a %s patch must be an object", patchType)
+		}
+		// Strategic merge patches name the object they patch
+		metadata, _ := fields["metadata"].(map[string]any)
+		if metadata == nil {
+			metadata = map[string]any{}
+		}
+		metadata["name"] = name
+		if target.Namespace != "" {
+			metadata["namespace"] = target.Namespace
+		}
+		fields["apiVersion"] = gvk.GroupVersion().String()
+		fields["kind"] = gvk.Kind
+		fields["metadata"] = metadata
+		if patchType == "merge" {
+			b.notes = append(b.notes, file+" was applied as a JSON merge patch, kustomize applies it as a strategic merge patch: lists with merge keys are merged rather than replaced.")
+		}
+	default:
+		return fmt.Errorf("unknown patch type %q", patchType)
+	}
+
+	content, err := yaml.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("marshalling patch: %w", err)
+	}
+	b.files[file] = content
+	b.kustomization.Patches = append(b.kustomization.Patches, kustomizePatch{Path: file, Target: target})
+	b.summary.Patches++
+	return nil
+}
+
+// kindRegexp matches valid kinds, e.g. Deployment.
+var kindRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// overlayFileName names the file of an object, e.g. default-deployment-web.
+// Names that are not valid in Kubernetes are rejected, so that they can't
+// point outside of the overlay, e.g. ../../x.
+func overlayFileName(namespace, kind, name string) (string, error) {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid name %q: %s", name, strings.Join(errs, ", "))
+	}
+	if !kindRegexp.MatchString(kind) {
+		return "", fmt.Errorf("invalid kind %q", kind)
+	}
+	parts := []string{strings.ToLower(kind), name}
+	if namespace != "" {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return "", fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+		parts = append([]string{namespace}, parts...)
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// kubectlCall is a kubectl invocation in a shell command.
+type kubectlCall struct {
+	args []string
+	// stdin is the here-document given to kubectl, directly or piped from
+	// cat, if any.
+	stdin string
+}
+
+// kubectlCalls returns the kubectl invocations of a shell command.
+func kubectlCalls(command string) ([]kubectlCall, error) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the command: %w", err)
+	}
+
+	var calls []kubectlCall
+	var walkErr error
+	piped := map[*syntax.Stmt]string{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if walkErr != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.BinaryCmd:
+			// cat <<EOF | kubectl apply -f -
+			if n.Op == syntax.Pipe {
+				if args, doc, err := stmtCall(n.X); err == nil && len(args) == 1 && args[0] == "cat" && doc != "" {
+					piped[n.Y] = doc
+				}
+			}
+		case *syntax.Stmt:
+			if _, ok := n.Cmd.(*syntax.CallExpr); !ok {
+				return true
+			}
+			args, doc, err := stmtCall(n)
+			if err != nil {
+				walkErr = err
+				return false
+			}
+			if len(args) > 0 && strings.Contains(path.Base(args[0]), "kubectl") {
+				if doc == "" {
+					doc = piped[n]
+				}
+				calls = append(calls, kubectlCall{args: args[1:], stdin: doc})
+			}
+		}
+		return true
+	})
+	return calls, walkErr
+}
+
+// stmtCall returns the arguments of a simple command, with its here-document
+// if it has one.
+func stmtCall(stmt *syntax.Stmt) ([]string, string, error) {
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return nil, "", nil
+	}
+	var args []string
+	for _, word := range call.Args {
+		arg, err := expand.Literal(nil, word)
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, arg)
+	}
+	var doc string
+	for _, redirect := range stmt.Redirs {
+		if (redirect.Op == syntax.Hdoc || redirect.Op == syntax.DashHdoc) && redirect.Hdoc != nil {
+			var err error
+			if doc, err = expand.Document(nil, redirect.Hdoc); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	return args, doc, nil
+}
+
+// parseKubectlCall splits kubectl arguments into the verb, the flags with a
+// value, by name, and the other positional arguments.
+func parseKubectlCall(args []string) (verb string, flags map[string]string, positional []string) {
+	flags = map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if verb == "" {
+				verb = arg
+			} else {
+				positional = append(positional, arg)
+			}
+			continue
+		}
+		flag, value, hasValue := strings.Cut(arg, "=")
+		name, takesValue := kubectlValueFlags[flag]
+		if !takesValue {
+			continue
+		}
+		if !hasValue && flag != "--dry-run" && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		flags[name] = value
+	}
+	return verb, flags, positional
+}
+
+// kindResolver resolves kinds with the discovery API of the cluster tools
+// use.
+func (c *Agent) kindResolver() KindResolver {
+	mappers := map[string]func(resource string) (schema.GroupVersionKind, error){}
+	return func(kubeContext, resource string) (schema.GroupVersionKind, error) {
+		kindFor, ok := mappers[kubeContext]
+		if !ok {
+			kindFor = c.newKindFor(kubeContext)
+			mappers[kubeContext] = kindFor
+		}
+		return kindFor(resource)
+	}
+}
+
+func (c *Agent) newKindFor(kubeContext string) func(resource string) (schema.GroupVersionKind, error) {
+	config, err := c.kubeClientConfigForContext(kubeContext).ClientConfig()
+	if err != nil {
+		return func(string) (schema.GroupVersionKind, error) {
+			return schema.GroupVersionKind{}, fmt.Errorf("loading kubeconfig: %w", err)
+		}
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return func(string) (schema.GroupVersionKind, error) {
+			return schema.GroupVersionKind{}, fmt.Errorf("creating discovery client: %w", err)
+		}
+	}
+	cached := memory.NewMemCacheClient(discoveryClient)
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, nil)
+	return func(resource string) (schema.GroupVersionKind, error) {
+		return mapper.KindFor(schema.ParseGroupResource(resource).WithVersion(""))
+	}
+}
+
+// exportOverlay writes the changes made in the current session to dir as a
+// kustomize overlay, by default kubectl-ai-<session ID>-overlay in the
+// working directory.
+func (c *Agent) exportOverlay(dir string) (string, error) {
+	c.sessionMu.Lock()
+	session := c.Session
+	c.sessionMu.Unlock()
+	if dir == "" {
+		dir = "kubectl-ai-" + session.ID + "-overlay"
+	}
+
+	summary, err := WriteKustomizeOverlay(dir, session, c.kindResolver())
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Sprintf("%s already exists and is not empty, choose another directory: export-overlay <directory>", dir), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	answer := fmt.Sprintf("Wrote %d resources and %d patches to %s.", summary.Resources, summary.Patches, dir)
+	if len(summary.Skipped) > 0 {
+		answer += fmt.Sprintf(" %d commands could not be captured, they are listed in %s.", len(summary.Skipped), filepath.Join(dir, "kustomization.yaml"))
+	}
+	return answer, nil
+}
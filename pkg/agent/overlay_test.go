@@ -0,0 +1,153 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWriteKustomizeOverlay(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	session := &api.Session{ID: "20250807-510872", ChatMessageStore: store}
+	ok := &sandbox.ExecResult{Stdout: "done"}
+	for _, call := range []struct {
+		command string
+		result  any
+	}{
+		{"kubectl get deploy web -n shop", ok},
+		{"kubectl apply -f - --namespace shop <<'EOF'\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web-config\ndata:\n  LOG_LEVEL: debug\nEOF", ok},
+		{"kubectl patch deployment web --namespace shop --type strategic --patch '{\"spec\":{\"replicas\":3}}'", ok},
+		{"kubectl patch deploy/web -n shop --type=json -p '[{\"op\":\"remove\",\"path\":\"/spec/template/spec/nodeSelector\"}]'", ok},
+		{"kubectl patch deploy web -n shop -p '{\"spec\":{\"paused\":true}}'", &sandbox.ExecResult{Stderr: "forbidden", ExitCode: 1}},
+		{"kubectl delete pod web-1 -n shop", ok},
+		{"kubectl apply --dry-run=server -f - <<'EOF'\napiVersion: v1\nkind: Secret\nmetadata:\n  name: dry\nEOF", ok},
+	} {
+		store.AddChatMessage(&api.Message{Type: api.MessageTypeToolCallRequest, Payload: call.command})
+		store.AddChatMessage(&api.Message{Type: api.MessageTypeToolCallResponse, Payload: call.result})
+	}
+
+	resolveKind := func(kubeContext, resource string) (schema.GroupVersionKind, error) {
+		if resource == "deployment" || resource == "deploy" {
+			return schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, nil
+		}
+		return schema.GroupVersionKind{}, fmt.Errorf("unknown resource %s", resource)
+	}
+	dir := t.TempDir()
+	summary, err := WriteKustomizeOverlay(dir, session, resolveKind)
+	if err != nil {
+		t.Fatalf("WriteKustomizeOverlay() error = %v", err)
+	}
+	if summary.Resources != 1 || summary.Patches != 2 || len(summary.Skipped) != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	for file, want := range map[string][]string{
+		"kustomization.yaml": {
+			"#   kubectl delete pod web-1 -n shop\n",
+			"resources:\n- resources/shop-configmap-web-config.yaml\n",
+			"- path: patches/01-shop-deployment-web.yaml\n  target:\n    group: apps\n    kind: Deployment\n    name: web\n    namespace: shop\n    version: v1\n",
+			"- path: patches/02-shop-deployment-web.yaml\n",
+		},
+		"resources/shop-configmap-web-config.yaml": {"name: web-config\n  namespace: shop\n", "LOG_LEVEL: debug"},
+		"patches/01-shop-deployment-web.yaml":      {"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: shop\nspec:\n  replicas: 3\n"},
+		"patches/02-shop-deployment-web.yaml":      {"- op: remove\n  path: /spec/template/spec/nodeSelector\n"},
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(string(content), w) {
+				t.Errorf("expected %s to contain %q, got:\n%s", file, w, content)
+			}
+		}
+	}
+
+	if _, err := WriteKustomizeOverlay(dir, session, resolveKind); err == nil {
+		t.Errorf("expected an error writing to a non-empty directory")
+	}
+}
+
+func TestExportOverlay(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	a := &Agent{Session: &api.Session{ID: "20250807-510872", ChatMessageStore: store}}
+	ok := &sandbox.ExecResult{Stdout: "done"}
+	for _, command := range []string{
+		"kubectl apply -f - <<'EOF'\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: ../../../escaped\nEOF",
+		"kubectl apply -f - --namespace ../.. <<'EOF'\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\nEOF",
+		"kubectl patch configmap x/../../escaped -p '{\"data\":{}}'",
+	} {
+		store.AddChatMessage(&api.Message{Type: api.MessageTypeToolCallRequest, Payload: command})
+		store.AddChatMessage(&api.Message{Type: api.MessageTypeToolCallResponse, Payload: ok})
+	}
+	resolveKind := func(kubeContext, resource string) (schema.GroupVersionKind, error) {
+		return schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, nil
+	}
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "a", "b", "overlay")
+	summary, err := WriteKustomizeOverlay(dir, a.Session, resolveKind)
+	if err != nil {
+		t.Fatalf("WriteKustomizeOverlay() error = %v", err)
+	}
+	if len(summary.Skipped) != 3 {
+		t.Errorf("expected the objects with invalid names to be skipped, got %+v", summary)
+	}
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Base(path) != "kustomization.yaml" {
+			t.Errorf("expected objects with invalid names not to be written, got %s", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if answer, err := a.exportOverlay(dir); err != nil || !strings.Contains(answer, "already exists") {
+		t.Errorf("expected a non-empty directory to be refused, got %q, %v", answer, err)
+	}
+
+	a.DisableLocalFiles = true
+	other := filepath.Join(root, "other")
+	if answer, handled, err := a.handleMetaQuery(context.Background(), "export-overlay "+other); err != nil || !handled || !strings.Contains(answer, "not available") {
+		t.Errorf("expected export-overlay to be disabled, got %q, %v", answer, err)
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Errorf("expected no overlay to be written, got %v", err)
+	}
+}
+
+func TestKubectlCalls(t *testing.T) {
+	calls, err := kubectlCalls("cat <<EOF | kubectl apply -f -\nkind: Namespace\nEOF\nkubectl get ns && kubectl label ns shop team=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 kubectl calls, got %+v", calls)
+	}
+	if calls[0].stdin != "kind: Namespace\n" || strings.Join(calls[2].args, " ") != "label ns shop team=web" {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// UserProfile is the long-term profile of the user: durable facts about their
+// environment, e.g. "prod deploys only via ArgoCD", included in the system
+// prompt of every session. The agent proposes facts with the
+// propose_profile_fact tool, and only those the user accepts are saved.
+type UserProfile struct {
+	Facts []UserProfileFact `json:"facts,omitempty"`
+}
+
+// UserProfileFact is a fact of the profile.
+type UserProfileFact struct {
+	Fact string `json:"fact"`
+	// Added is when the user accepted the fact.
+	Added time.Time `json:"added,omitempty"`
+}
+
+// LoadUserProfile reads the profile at path. A missing file is an empty profile.
+func LoadUserProfile(path string) (*UserProfile, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &UserProfile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading profile: %w", err)
+	}
+	var profile UserProfile
+	if err := yaml.UnmarshalStrict(b, &profile); err != nil {
+		return nil, fmt.Errorf("parsing profile %s: %w", path, err)
+	}
+	return &profile, nil
+}
+
+// Save writes the profile to path, creating its directory if needed.
+func (p *UserProfile) Save(path string) error {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encoding profile: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating profile directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("writing profile: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Contains reports whether fact is in the profile, ignoring case.
+func (p *UserProfile) Contains(fact string) bool {
+	return slices.ContainsFunc(p.Facts, func(f UserProfileFact) bool { return strings.EqualFold(f.Fact, fact) })
+}
+
+// factTexts lists the facts of the profile, for the system prompt.
+func (p *UserProfile) factTexts() []string {
+	if p == nil {
+		return nil
+	}
+	facts := make([]string, len(p.Facts))
+	for i, f := range p.Facts {
+		facts[i] = f.Fact
+	}
+	return facts
+}
+
+// ProposeProfileFactTool lets the agent propose a durable fact for the
+// user's profile. The fact is only saved if the user accepts it with
+// `profile accept`.
+type ProposeProfileFactTool struct {
+	agent *Agent
+}
+
+func newProposeProfileFactTool(agent *Agent) *ProposeProfileFactTool {
+	return &ProposeProfileFactTool{agent: agent}
+}
+
+func (t *ProposeProfileFactTool) Name() string {
+	return "propose_profile_fact"
+}
+
+func (t *ProposeProfileFactTool) Description() string {
+	return "Proposes remembering a durable fact about the user's environment or practices in their profile, " +
+		`e.g. "the staging cluster is eks-stg-1" or "prod is only deployed to via ArgoCD". ` +
+		"Accepted facts are included in the instructions of future sessions. " +
+		"Only propose facts the user stated or confirmed and that stay true beyond the current task, not transient state such as the status of a pod. " +
+		"The user accepts or rejects the proposal later, do not ask them about it."
+}
+
+func (t *ProposeProfileFactTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"fact": {
+					Type:        gollm.TypeString,
+					Description: "The fact, as a short self-contained sentence.",
+				},
+			},
+			Required: []string{"fact"},
+		},
+	}
+}
+
+func (t *ProposeProfileFactTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	fact, _ := args["fact"].(string)
+	fact = strings.TrimSpace(fact)
+	if fact == "" {
+		return &sandbox.ExecResult{Error: "fact is required"}, nil
+	}
+
+	c := t.agent
+	c.sessionMu.Lock()
+	known := c.profile.Contains(fact) || slices.ContainsFunc(c.profileProposals, func(p string) bool { return strings.EqualFold(p, fact) })
+	if !known {
+		c.profileProposals = append(c.profileProposals, fact)
+	}
+	c.sessionMu.Unlock()
+	if known {
+		return map[string]any{"status": "the fact is already in the profile or proposed"}, nil
+	}
+
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText,
+		fmt.Sprintf("Proposed for your profile: %s\nRun `profile accept` to remember it in future sessions, or `profile reject` to dismiss it.", fact))
+	return map[string]any{"status": "proposed, the user will accept or reject it"}, nil
+}
+
+func (t *ProposeProfileFactTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *ProposeProfileFactTool) CheckModifiesResource(args map[string]any) string {
+	// Nothing is saved until the user accepts the proposal
+	return "no"
+}
+
+// handleProfileQuery handles the meta-queries managing the profile:
+//   - profile: list the facts of the profile and the proposed ones
+//   - profile accept [n|all]: save the proposed facts, all by default
+//   - profile reject [n|all]: dismiss the proposed facts, all by default
+//   - profile add <fact>: save a fact
+//   - profile forget <n>: remove the n-th fact of the profile
+func (c *Agent) handleProfileQuery(ctx context.Context, query string) (string, error) {
+	if c.UserProfilePath == "" {
+		return "The profile is disabled, set --user-profile-path to enable it.", nil
+	}
+	fields := strings.Fields(query)
+	if len(fields) == 1 {
+		return c.formatProfile(), nil
+	}
+
+	switch fields[1] {
+	case "accept", "reject":
+		if len(fields) > 3 {
+			break
+		}
+		c.sessionMu.Lock()
+		proposals := slices.Clone(c.profileProposals)
+		c.sessionMu.Unlock()
+		if len(proposals) == 0 {
+			return "No facts are proposed for the profile.", nil
+		}
+		selected := proposals
+		if len(fields) == 3 && fields[2] != "all" {
+			n, err := strconv.Atoi(fields[2])
+			if err != nil || n < 1 || n > len(proposals) {
+				return fmt.Sprintf("Invalid proposal number %q, see `profile` for the proposed facts.", fields[2]), nil
+			}
+			selected = proposals[n-1 : n]
+		}
+		if fields[1] == "accept" {
+			if err := c.addProfileFacts(ctx, selected); err != nil {
+				return "", err
+			}
+		}
+		c.sessionMu.Lock()
+		c.profileProposals = slices.DeleteFunc(c.profileProposals, func(p string) bool { return slices.Contains(selected, p) })
+		c.sessionMu.Unlock()
+		if fields[1] == "accept" {
+			return "Added to your profile:\n- " + strings.Join(selected, "\n- "), nil
+		}
+		return "Dismissed:\n- " + strings.Join(selected, "\n- "), nil
+
+	case "add":
+		fact := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(query, "profile")), "add"))
+		if fact == "" {
+			break
+		}
+		if err := c.addProfileFacts(ctx, []string{fact}); err != nil {
+			return "", err
+		}
+		return "Added to your profile: " + fact, nil
+
+	case "forget":
+		if len(fields) != 3 {
+			break
+		}
+		n, err := strconv.Atoi(fields[2])
+		if err != nil || n < 1 {
+			return fmt.Sprintf("Invalid fact number %q, see `profile` for the facts.", fields[2]), nil
+		}
+		var forgotten string
+		if err := c.updateProfile(ctx, func(profile *UserProfile) error {
+			if n > len(profile.Facts) {
+				return fmt.Errorf("the profile has %d facts", len(profile.Facts))
+			}
+			forgotten = profile.Facts[n-1].Fact
+			profile.Facts = slices.Delete(profile.Facts, n-1, n)
+			return nil
+		}); err != nil {
+			return "", err
+		}
+		return "Removed from your profile: " + forgotten, nil
+	}
+	return "Invalid command. Usage: profile [accept [n|all] | reject [n|all] | add <fact> | forget <n>]", nil
+}
+
+// addProfileFacts saves facts to the profile, skipping those already in it.
+func (c *Agent) addProfileFacts(ctx context.Context, facts []string) error {
+	return c.updateProfile(ctx, func(profile *UserProfile) error {
+		for _, fact := range facts {
+			if !profile.Contains(fact) {
+				profile.Facts = append(profile.Facts, UserProfileFact{Fact: fact, Added: time.Now()})
+			}
+		}
+		return nil
+	})
+}
+
+// updateProfile applies update to the profile and saves it. The profile is
+// read again first, as other sessions may have changed it. The chat is
+// restarted, so that the system prompt shows the updated profile.
+func (c *Agent) updateProfile(ctx context.Context, update func(*UserProfile) error) error {
+	profile, err := LoadUserProfile(c.UserProfilePath)
+	if err != nil {
+		return err
+	}
+	if err := update(profile); err != nil {
+		return err
+	}
+	if err := profile.Save(c.UserProfilePath); err != nil {
+		return err
+	}
+
+	c.sessionMu.Lock()
+	c.profile = profile
+	c.sessionMu.Unlock()
+
+	if c.llmChat != nil {
+		if err := c.startChat(ctx); err != nil {
+			return fmt.Errorf("restarting chat: %w", err)
+		}
+		if err := c.setFunctionDefinitions(); err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to set function definitions after updating the profile")
+		}
+	}
+	return nil
+}
+
+// formatProfile lists the facts of the profile and the proposed ones, for
+// the profile meta-query.
+func (c *Agent) formatProfile() string {
+	c.sessionMu.Lock()
+	facts := c.profile.factTexts()
+	proposals := slices.Clone(c.profileProposals)
+	c.sessionMu.Unlock()
+
+	var sb strings.Builder
+	if len(facts) == 0 {
+		fmt.Fprintf(&sb, "Your profile (%s) has no facts.\n", c.UserProfilePath)
+	} else {
+		fmt.Fprintf(&sb, "Your profile (%s):\n", c.UserProfilePath)
+		for i, fact := range facts {
+			fmt.Fprintf(&sb, "%d. %s\n", i+1, fact)
+		}
+	}
+	if len(proposals) > 0 {
+		sb.WriteString("\nProposed facts, accept them with `profile accept [n]` or dismiss them with `profile reject [n]`:\n")
+		for i, fact := range proposals {
+			fmt.Fprintf(&sb, "%d. %s\n", i+1, fact)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestProfile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "kubectl-ai", "profile.yaml")
+	profile, err := LoadUserProfile(path)
+	if err != nil {
+		t.Fatalf("LoadUserProfile() of a missing file error = %v", err)
+	}
+	a := &Agent{
+		UserProfilePath: path,
+		Session:         &api.Session{ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Output:          make(chan any, 10),
+		profile:         profile,
+	}
+	tool := newProposeProfileFactTool(a)
+
+	for _, fact := range []string{"The staging cluster is eks-stg-1", "prod is only deployed to via ArgoCD", "the staging cluster is EKS-STG-1"} {
+		if _, err := tool.Run(ctx, map[string]any{"fact": fact}); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+	if want := []string{"The staging cluster is eks-stg-1", "prod is only deployed to via ArgoCD"}; !reflect.DeepEqual(a.profileProposals, want) {
+		t.Errorf("proposals = %q, want %q", a.profileProposals, want)
+	}
+	if len(a.Output) != 2 {
+		t.Errorf("expected the user to be told about 2 proposals, got %d messages", len(a.Output))
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{query: "profile", want: "has no facts.\n\nProposed facts"},
+		{query: "profile accept 2", want: "Added to your profile:\n- prod is only deployed to via ArgoCD"},
+		{query: "profile reject", want: "Dismissed:\n- The staging cluster is eks-stg-1"},
+		{query: "profile accept", want: "No facts are proposed"},
+		{query: "profile add payments owns ns payments-prod", want: "Added to your profile: payments owns ns payments-prod"},
+		{query: "profile", want: "1. prod is only deployed to via ArgoCD\n2. payments owns ns payments-prod\n"},
+		{query: "profile forget 1", want: "Removed from your profile: prod is only deployed to via ArgoCD"},
+	} {
+		got, err := a.handleProfileQuery(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("handleProfileQuery(%q) error = %v", tt.query, err)
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("handleProfileQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	saved, err := LoadUserProfile(path)
+	if err != nil {
+		t.Fatalf("LoadUserProfile() error = %v", err)
+	}
+	if got := saved.factTexts(); !reflect.DeepEqual(got, []string{"payments owns ns payments-prod"}) {
+		t.Errorf("saved facts = %q", got)
+	}
+	if saved.Facts[0].Added.IsZero() {
+		t.Errorf("expected the time the fact was added to be saved")
+	}
+}
+
+func TestSystemPrompt_Profile(t *testing.T) {
+	a := &Agent{}
+	got, err := a.generatePrompt(context.Background(), defaultSystemPromptTemplate, PromptData{
+		UserProfile: []string{"prod is only deployed to via ArgoCD"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "## User profile") || !strings.Contains(got, "- prod is only deployed to via ArgoCD\n") {
+		t.Errorf("expected the system prompt to include the profile, got:\n%s", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"k8s.io/klog/v2"
+)
+
+// defaultPruneToolOutputBytes is the size above which tool outputs are pruned
+// from the history once they are old enough, see PruneToolOutputsAfter.
+const defaultPruneToolOutputBytes = 4096
+
+// pruneToolOutputs records the tool outputs in contents, about to be sent to
+// the LLM, and replaces the large outputs of the queries before the last
+// PruneToolOutputsAfter ones in the history of the chat with a one-line
+// summary. The full outputs are saved for the fetch_full_output tool, and the
+// session keeps them for the user to review.
+func (c *Agent) pruneToolOutputs(ctx context.Context, contents []any) {
+	// The tool use shim sends tool outputs as text
+	if c.PruneToolOutputsAfter <= 0 || c.EnableToolUseShim {
+		return
+	}
+
+	results, query := 0, false
+	for _, content := range contents {
+		if _, ok := content.(gollm.FunctionCallResult); ok {
+			results++
+		} else {
+			query = true
+		}
+	}
+	// Results sent with a query answer the calls of the previous one, e.g.
+	// after an interruption
+	if n := len(c.toolOutputsPerQuery); n > 0 {
+		c.toolOutputsPerQuery[n-1] += results
+	} else if results > 0 {
+		c.toolOutputsPerQuery = []int{results}
+	}
+	if query {
+		c.toolOutputsPerQuery = append(c.toolOutputsPerQuery, 0)
+	}
+
+	queries := c.toolOutputsPerQuery
+	if len(queries) <= c.PruneToolOutputsAfter {
+		return
+	}
+	// The results in contents are not in the history yet
+	keep := -results
+	for _, n := range queries[len(queries)-c.PruneToolOutputsAfter:] {
+		keep += n
+	}
+	c.toolOutputsPerQuery = queries[len(queries)-c.PruneToolOutputsAfter:]
+
+	if pruned := gollm.PruneToolOutputs(c.llmChat, keep, c.pruneToolOutput); pruned > 0 {
+		klog.FromContext(ctx).Info("Pruned large tool outputs from the history", "pruned", pruned, "afterQueries", c.PruneToolOutputsAfter)
+	}
+}
+
+// pruneToolOutput returns the summary replacing output in the history, and
+// false if output is small enough to be kept.
+func (c *Agent) pruneToolOutput(output string) (string, bool) {
+	maxBytes := c.PruneToolOutputBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultPruneToolOutputBytes
+	}
+	if len(output) <= maxBytes {
+		return "", false
+	}
+
+	text, command := prunedToolOutputText(output)
+	id, err := c.outputStore.Save(text)
+	if err != nil {
+		klog.Warningf("error saving pruned tool output, keeping it: %v", err)
+		return "", false
+	}
+	of := "a tool call"
+	if command != "" {
+		of = fmt.Sprintf("%q", command)
+	}
+	return fmt.Sprintf("[output of %s pruned from the history: %d bytes, %d lines. Full output saved with output_id %q; use the fetch_full_output tool to read it]",
+		of, len(text), strings.Count(text, "\n")+1, id), true
+}
+
+// prunedToolOutputText returns the text of a tool output sent as JSON, e.g.
+// the stdout and stderr of a command, for fetch_full_output to page through
+// its lines, and the command that produced it, if any.
+func prunedToolOutputText(output string) (text, command string) {
+	var result map[string]any
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return output, ""
+	}
+	command, _ = result["command"].(string)
+	stdout, _ := result["stdout"].(string)
+	stderr, _ := result["stderr"].(string)
+	if content, ok := result["content"].(string); ok && stdout == "" {
+		stdout = content
+	}
+	if stdout == "" && stderr == "" {
+		indented, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return output, command
+		}
+		return string(indented), command
+	}
+	if stderr != "" {
+		return strings.TrimSuffix(stdout, "\n") + "\n" + stderr, command
+	}
+	return stdout, command
+}
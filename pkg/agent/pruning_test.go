@@ -0,0 +1,99 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+// historyChat keeps the tool outputs sent to it as JSON, like the providers.
+type historyChat struct {
+	gollm.Chat
+	outputs []string
+}
+
+func (c *historyChat) SendStreaming(ctx context.Context, contents ...any) (gollm.ChatResponseIterator, error) {
+	for _, content := range contents {
+		if result, ok := content.(gollm.FunctionCallResult); ok {
+			output, _ := json.Marshal(result.Result)
+			c.outputs = append(c.outputs, string(output))
+		}
+	}
+	return func(yield func(gollm.ChatResponse, error) bool) {}, nil
+}
+
+func (c *historyChat) PruneToolOutputs(keep int, prune func(output string) (string, bool)) int {
+	pruned := 0
+	for i, output := range c.outputs[:max(len(c.outputs)-keep, 0)] {
+		if replacement, ok := prune(output); ok {
+			c.outputs[i] = replacement
+			pruned++
+		}
+	}
+	return pruned
+}
+
+func TestPruneToolOutputs(t *testing.T) {
+	chat := &historyChat{}
+	store := tools.NewOutputStore(t.TempDir())
+	a := &Agent{
+		llmChat:               chat,
+		outputStore:           store,
+		PruneToolOutputsAfter: 2,
+		PruneToolOutputBytes:  100,
+	}
+	large := strings.Repeat("pod-1 Running\n", 20)
+	result := func(stdout string) gollm.FunctionCallResult {
+		return gollm.FunctionCallResult{Name: "kubectl", Result: map[string]any{"command": "kubectl get pods", "stdout": stdout}}
+	}
+	send := func(contents ...any) {
+		t.Helper()
+		a.pruneToolOutputs(context.Background(), contents)
+		if _, err := chat.SendStreaming(context.Background(), contents...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("list the pods")
+	send(result(large), result("small"))
+	send("and the nodes?")
+	send(result(large))
+	if strings.HasPrefix(chat.outputs[0], "[output of") {
+		t.Fatalf("expected the outputs of the last 2 queries to be kept, got %q", chat.outputs[0])
+	}
+
+	send("thanks!")
+	summary := chat.outputs[0]
+	if !strings.HasPrefix(summary, `[output of "kubectl get pods" pruned from the history: 280 bytes, 21 lines.`) {
+		t.Fatalf("expected the large output of the first query to be pruned, got %q", summary)
+	}
+	if !strings.Contains(chat.outputs[1], "small") || !strings.Contains(chat.outputs[2], "pod-1") {
+		t.Errorf("expected the small output and the outputs of the second query to be kept, got %q", chat.outputs[1:])
+	}
+	id := regexp.MustCompile(`output_id "([0-9a-f-]+)"`).FindStringSubmatch(summary)
+	if id == nil {
+		t.Fatalf("expected a reference to the full output, got %q", summary)
+	}
+	if full, err := store.Load(id[1]); err != nil || full != large {
+		t.Errorf("expected the full output to be saved, got %q, %v", full, err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/retrieval"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// retrieveDocs returns the excerpts of the indexed docs relevant to query, to
+// be sent before it, or nothing if there is no retriever or no excerpt is
+// relevant. Retrieval is best effort: errors are logged and the query is sent
+// without excerpts.
+func (c *Agent) retrieveDocs(ctx context.Context, query string) []any {
+	if c.Retriever == nil {
+		return nil
+	}
+	log := klog.FromContext(ctx)
+	results, err := c.Retriever.Retrieve(ctx, query)
+	if err != nil {
+		log.Error(err, "error retrieving docs for the query")
+		return nil
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	sources := make([]string, len(results))
+	for i, result := range results {
+		sources[i] = result.Chunk.Source
+	}
+	log.Info("Retrieved docs for the query", "sources", sources)
+	// The docs are the user's, but may still hold secrets or instructions
+	// that the content filters must see, like attachments
+	filtered, err := c.filterContent(ctx, tools.ContentKindAttachment, retrieval.FormatContext(results))
+	if err != nil {
+		log.Error(err, "the retrieved docs were not sent")
+		return nil
+	}
+	return []any{filtered}
+}
@@ -0,0 +1,54 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/retrieval"
+)
+
+func TestRetrieveDocs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	doc := "# Node pressure\n\nCordon the node and evict the largest pods.\n"
+	if err := os.WriteFile(filepath.Join(dir, "nodes.md"), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := gollm.NewMockClient(&gollm.MockFixture{})
+	index, err := (&retrieval.Indexer{Client: client}).Index(ctx, dir, nil)
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+
+	a := &Agent{}
+	if content := a.retrieveDocs(ctx, "node pressure"); content != nil {
+		t.Errorf("retrieveDocs() without a retriever = %v, want nil", content)
+	}
+
+	a.Retriever = &retrieval.Retriever{Client: client, Index: index, MinScore: 0.3}
+	content := a.retrieveDocs(ctx, "what to do about node pressure?")
+	if len(content) != 1 || !strings.Contains(content[0].(string), `source="nodes.md > Node pressure"`) {
+		t.Errorf("retrieveDocs() = %v, want the node pressure excerpt", content)
+	}
+	if content := a.retrieveDocs(ctx, "rotate etcd encryption keys"); content != nil {
+		t.Errorf("retrieveDocs() for an unrelated query = %v, want nil", content)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Query intents, as guessed by queryIntent.
+const (
+	IntentExplain   = "explain"
+	IntentSummarize = "summarize"
+	IntentFix       = "fix"
+)
+
+// intentKeywords are the words that give away the intent of a query. Fixing
+// wins over the other intents, as it needs the most capable model.
+var intentKeywords = []struct {
+	intent   string
+	keywords []string
+}{
+	{IntentFix, []string{"fix", "repair", "remediate", "resolve", "troubleshoot", "debug", "restart", "rollback", "roll back", "scale", "patch", "apply", "deploy", "create", "delete", "update", "upgrade", "migrate"}},
+	{IntentSummarize, []string{"summarize", "summarise", "summary", "overview", "list", "show", "count"}},
+	{IntentExplain, []string{"explain", "why", "what", "how", "describe", "tell me"}},
+}
+
+// queryIntent guesses the intent of query from its keywords, "" if none
+// matches.
+func queryIntent(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || r == '-')
+	})
+	text := " " + strings.Join(words, " ") + " "
+	for _, intent := range intentKeywords {
+		for _, keyword := range intent.keywords {
+			if strings.Contains(text, " "+keyword+" ") {
+				return intent.intent
+			}
+		}
+	}
+	return ""
+}
+
+// RoutingRules pick the model of each query, e.g. a small model for summaries
+// and a large one for remediation planning. The first matching rule wins;
+// queries matching no rule use the model of the session.
+type RoutingRules struct {
+	Rules []RoutingRule `json:"rules"`
+}
+
+// RoutingRule routes the queries matching all of its conditions to Model.
+// Conditions left empty match all queries.
+type RoutingRule struct {
+	// Name identifies the rule in logs, it defaults to its position, e.g. #1.
+	Name string `json:"name,omitempty"`
+	// Intents are the intents the query must have: explain, summarize or fix.
+	Intents []string `json:"intents,omitempty"`
+	// MinLength and MaxLength bound the length of the query, in characters.
+	MinLength int `json:"minLength,omitempty"`
+	MaxLength int `json:"maxLength,omitempty"`
+	// ToolLoop, if set, requires the conversation to have (true) or not to
+	// have (false) run tools already.
+	ToolLoop *bool `json:"toolLoop,omitempty"`
+
+	Model string `json:"model"`
+	// Provider defaults to the provider of the session.
+	Provider string `json:"provider,omitempty"`
+}
+
+// LoadRoutingRules reads routing rules from a YAML or JSON file.
+func LoadRoutingRules(path string) (*RoutingRules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading routing rules: %w", err)
+	}
+	var rules RoutingRules
+	if err := yaml.UnmarshalStrict(b, &rules); err != nil {
+		return nil, fmt.Errorf("parsing routing rules %s: %w", path, err)
+	}
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Model == "" {
+			return nil, fmt.Errorf("routing rule %d has no model", i+1)
+		}
+		for _, intent := range rule.Intents {
+			if intent != IntentExplain && intent != IntentSummarize && intent != IntentFix {
+				return nil, fmt.Errorf("routing rule %d has an unknown intent %q, expected explain, summarize or fix", i+1, intent)
+			}
+		}
+	}
+	return &rules, nil
+}
+
+// Route returns the rule matching query, nil if none does. ranTools tells
+// whether the conversation ran tools already.
+func (r *RoutingRules) Route(query string, ranTools bool) *RoutingRule {
+	intent := queryIntent(query)
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if len(rule.Intents) > 0 && !slices.Contains(rule.Intents, intent) {
+			continue
+		}
+		if rule.MinLength > 0 && len(query) < rule.MinLength {
+			continue
+		}
+		if rule.MaxLength > 0 && len(query) > rule.MaxLength {
+			continue
+		}
+		if rule.ToolLoop != nil && *rule.ToolLoop != ranTools {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// routeQuery switches to the model the routing rules pick for query, or back
+// to the model of the session if no rule matches.
+func (c *Agent) routeQuery(ctx context.Context, query string) {
+	if c.ModelRouter == nil {
+		return
+	}
+	log := klog.FromContext(ctx)
+
+	// The query is the last message of the session, it is sent once the chat
+	// is switched
+	history := c.Session.ChatMessageStore.ChatMessages()
+	if n := len(history); n > 0 && history[n-1].Source == api.MessageSourceUser && history[n-1].Payload == query {
+		history = history[:n-1]
+	}
+
+	model, provider := c.sessionModel, c.sessionProvider
+	reason := "no routing rule matched"
+	if rule := c.ModelRouter.Route(query, ranTools(history)); rule != nil {
+		model, provider = rule.Model, rule.Provider
+		reason = "routing rule " + rule.Name
+	}
+	if provider == "" {
+		provider = c.sessionProvider
+	}
+	if model == "" || model == c.Model && provider == c.Provider {
+		return
+	}
+
+	log.Info("Routing query", "model", model, "provider", provider, "reason", reason)
+	if err := c.switchModel(ctx, model, provider, history); err != nil {
+		log.Error(err, "error routing query, keeping the current model", "model", model)
+		return
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Using model `%s` (%s).", model, reason))
+}
+
+// ranTools reports whether the conversation in history ran tools.
+func ranTools(history []*api.Message) bool {
+	for _, message := range history {
+		if message.Type == api.MessageTypeToolCallRequest {
+			return true
+		}
+	}
+	return false
+}
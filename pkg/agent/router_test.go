@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"go.uber.org/mock/gomock"
+)
+
+const testRoutingRules = `
+rules:
+- name: remediation
+  intents: [fix]
+  model: gemini-2.5-pro
+- name: follow-up
+  toolLoop: true
+  maxLength: 40
+  model: gemini-2.5-flash
+- intents: [explain, summarize]
+  maxLength: 100
+  model: gemini-2.5-flash-lite
+`
+
+func loadTestRoutingRules(t *testing.T, content string) (*RoutingRules, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "routing.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadRoutingRules(path)
+}
+
+func TestRoutingRules_Route(t *testing.T) {
+	rules, err := loadTestRoutingRules(t, testRoutingRules)
+	if err != nil {
+		t.Fatalf("LoadRoutingRules() error = %v", err)
+	}
+
+	tests := []struct {
+		query    string
+		ranTools bool
+		want     string
+	}{
+		{query: "Fix the crashing pods in the web namespace", want: "remediation"},
+		{query: "Why is my pod pending?", want: "#3"},
+		{query: "list the nodes", want: "#3"},
+		{query: "and in kube-system?", ranTools: true, want: "follow-up"},
+		{query: "and in kube-system?", want: ""},
+		{query: "Explain " + strings.Repeat("the failing rollout ", 10), want: ""},
+	}
+	for _, tt := range tests {
+		rule := rules.Route(tt.query, tt.ranTools)
+		var got string
+		if rule != nil {
+			got = rule.Name
+		}
+		if got != tt.want {
+			t.Errorf("Route(%q, %v) = %q, want %q", tt.query, tt.ranTools, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRoutingRules_Invalid(t *testing.T) {
+	for content, want := range map[string]string{
+		"rules:\n- intents: [fix]\n":                "has no model",
+		"rules:\n- intents: [plan]\n  model: pro\n": `unknown intent "plan"`,
+		"rules:\n- model: pro\n  unknownField: 1\n": "unknown field",
+		"rules:\n- model: pro\n  maxLength: long\n": "parsing routing rules",
+	} {
+		if _, err := loadTestRoutingRules(t, content); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error containing %q for %q, got %v", want, content, err)
+		}
+	}
+}
+
+func TestRouteQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	rules, err := loadTestRoutingRules(t, testRoutingRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := sessions.NewInMemoryChatStore()
+	earlier := &api.Message{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "hello"}
+	query := "fix the crashing pods"
+	_ = store.AddChatMessage(earlier)
+	_ = store.AddChatMessage(&api.Message{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: query})
+
+	chat := mocks.NewMockChat(ctrl)
+	// The query itself is not replayed, it is sent once the model is switched
+	chat.EXPECT().Initialize([]*api.Message{earlier}).Return(nil).Times(2)
+	chat.EXPECT().SetFunctionDefinitions(gomock.Any()).Return(nil).Times(2)
+	llm := mocks.NewMockClient(ctrl)
+	llm.EXPECT().StartChat(gomock.Any(), "gemini-2.5-pro").Return(chat)
+	llm.EXPECT().StartChat(gomock.Any(), "gemini-2.5-flash").Return(chat)
+
+	a := &Agent{
+		LLM:             llm,
+		Model:           "gemini-2.5-flash",
+		Provider:        "gemini",
+		ModelRouter:     rules,
+		Output:          make(chan any, 10),
+		Session:         &api.Session{ChatMessageStore: store},
+		sessionModel:    "gemini-2.5-flash",
+		sessionProvider: "gemini",
+	}
+	a.Tools.Init()
+
+	a.routeQuery(context.Background(), query)
+	if a.Model != "gemini-2.5-pro" {
+		t.Errorf("expected the query to be routed to gemini-2.5-pro, got %s", a.Model)
+	}
+
+	// Back to the model of the session once no rule matches
+	_ = store.SetChatMessages([]*api.Message{earlier, {Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "thanks!"}})
+	a.routeQuery(context.Background(), "thanks!")
+	if a.Model != "gemini-2.5-flash" {
+		t.Errorf("expected the model of the session, got %s", a.Model)
+	}
+}
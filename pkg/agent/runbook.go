@@ -0,0 +1,212 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// Runbook is a named sequence of prompts, e.g. the steps to triage node
+// pressure, run with `run <name>`. Each step is a query of its own, with the
+// usual approvals of its tool calls.
+type Runbook struct {
+	// Name defaults to the file name without extension.
+	Name        string        `json:"name,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Steps       []RunbookStep `json:"steps"`
+}
+
+// RunbookStep is a step of a runbook.
+type RunbookStep struct {
+	// Name identifies the step in messages and the journal, it defaults to
+	// its position, e.g. #1.
+	Name   string `json:"name,omitempty"`
+	Prompt string `json:"prompt"`
+}
+
+// LoadRunbooks reads the runbooks of the YAML or JSON files in dir, by name.
+// A missing dir has no runbooks.
+func LoadRunbooks(dir string) (map[string]*Runbook, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading runbooks: %w", err)
+	}
+	runbooks := make(map[string]*Runbook)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		runbook, err := loadRunbook(path)
+		if err != nil {
+			return nil, err
+		}
+		if runbook.Name == "" {
+			runbook.Name = strings.TrimSuffix(entry.Name(), ext)
+		}
+		if _, ok := runbooks[runbook.Name]; ok {
+			return nil, fmt.Errorf("runbook %q is defined more than once, see %s", runbook.Name, path)
+		}
+		runbooks[runbook.Name] = runbook
+	}
+	return runbooks, nil
+}
+
+func loadRunbook(path string) (*Runbook, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading runbook: %w", err)
+	}
+	var runbook Runbook
+	if err := yaml.UnmarshalStrict(b, &runbook); err != nil {
+		return nil, fmt.Errorf("parsing runbook %s: %w", path, err)
+	}
+	if strings.ContainsAny(runbook.Name, " \t\n") {
+		return nil, fmt.Errorf("runbook %s has a name with spaces %q", path, runbook.Name)
+	}
+	if len(runbook.Steps) == 0 {
+		return nil, fmt.Errorf("runbook %s has no steps", path)
+	}
+	for i := range runbook.Steps {
+		step := &runbook.Steps[i]
+		if strings.TrimSpace(step.Prompt) == "" {
+			return nil, fmt.Errorf("step %d of runbook %s has no prompt", i+1, path)
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("#%d", i+1)
+		}
+	}
+	return &runbook, nil
+}
+
+// runbookRun tracks the progress of the runbook being run.
+type runbookRun struct {
+	runbook *Runbook
+	// step is the index of the step being run, -1 before the first one.
+	step int
+}
+
+// listRunbooks describes the available runbooks.
+func (c *Agent) listRunbooks() string {
+	if len(c.Runbooks) == 0 {
+		return "No runbooks found."
+	}
+	names := make([]string, 0, len(c.Runbooks))
+	for name := range c.Runbooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString("Available runbooks:\n\n")
+	for _, name := range names {
+		runbook := c.Runbooks[name]
+		fmt.Fprintf(&sb, "  - %s (%d steps)", name, len(runbook.Steps))
+		if runbook.Description != "" {
+			sb.WriteString(": " + runbook.Description)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nUsage: run <runbook>")
+	return sb.String()
+}
+
+// startRunbook queues the steps of the named runbook, the agent loop runs
+// them once the current query is done, see nextRunbookStep.
+func (c *Agent) startRunbook(name string) (string, error) {
+	runbook, ok := c.Runbooks[name]
+	if !ok {
+		return "", fmt.Errorf("runbook %q not found, use `runbooks` to list the available runbooks", name)
+	}
+	c.runbook = &runbookRun{runbook: runbook, step: -1}
+	c.lastErr = nil
+	return fmt.Sprintf("Running runbook %s (%d steps).", name, len(runbook.Steps)), nil
+}
+
+// nextRunbookStep moves the runbook being run, if any, to its next step and
+// returns its prompt. The runbook stops after its last step, or when a step
+// failed.
+func (c *Agent) nextRunbookStep(ctx context.Context) (string, bool) {
+	run := c.runbook
+	if run == nil {
+		return "", false
+	}
+	name := run.runbook.Name
+	if c.lastErr != nil {
+		c.runbook = nil
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeError, fmt.Sprintf("Runbook %s stopped at step %s: %v", name, run.runbook.Steps[run.step].Name, c.lastErr))
+		return "", false
+	}
+	run.step++
+	if run.step >= len(run.runbook.Steps) {
+		c.runbook = nil
+		c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Runbook %s completed.", name))
+		return "", false
+	}
+
+	step := run.runbook.Steps[run.step]
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Runbook %s, step %d/%d: %s", name, run.step+1, len(run.runbook.Steps), step.Name))
+	if c.Recorder != nil {
+		payload := &journal.RunbookStep{
+			Runbook: name,
+			Step:    step.Name,
+			Index:   run.step + 1,
+			Prompt:  step.Prompt,
+		}
+		if c.Session != nil {
+			payload.SessionID = c.Session.ID
+		}
+		if err := c.Recorder.Write(ctx, &journal.Event{Action: journal.ActionRunbookStep, Payload: payload}); err != nil {
+			klog.Warningf("failed to record runbook step in the journal: %v", err)
+		}
+	}
+	return step.Prompt, true
+}
+
+// stopRunbook stops the runbook being run, e.g. when the user interrupts it.
+func (c *Agent) stopRunbook() {
+	if c.runbook == nil {
+		return
+	}
+	c.addMessage(api.MessageSourceAgent, api.MessageTypeText, fmt.Sprintf("Runbook %s stopped.", c.runbook.runbook.Name))
+	c.runbook = nil
+}
+
+// runbookContext attributes the journal events of the actions taken in ctx,
+// e.g. tool calls and approvals, to the step of the runbook being run.
+func (c *Agent) runbookContext(ctx context.Context) context.Context {
+	run := c.runbook
+	if run == nil || run.step < 0 {
+		return ctx
+	}
+	return journal.ContextWithRunbook(ctx, &journal.RunbookRef{
+		Runbook: run.runbook.Name,
+		Step:    run.runbook.Steps[run.step].Name,
+	})
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+const testRunbook = `
+description: Triage nodes under pressure
+steps:
+- name: find-nodes
+  prompt: List the nodes with a MemoryPressure condition.
+- prompt: Show the pods using the most memory on these nodes.
+`
+
+func writeRunbooks(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadRunbooks(t *testing.T) {
+	dir := writeRunbooks(t, map[string]string{
+		"node-pressure.yaml": testRunbook,
+		"notes.txt":          "not a runbook",
+	})
+	runbooks, err := LoadRunbooks(dir)
+	if err != nil {
+		t.Fatalf("LoadRunbooks() error = %v", err)
+	}
+	runbook, ok := runbooks["node-pressure"]
+	if len(runbooks) != 1 || !ok {
+		t.Fatalf("expected the node-pressure runbook, got %v", runbooks)
+	}
+	if len(runbook.Steps) != 2 || runbook.Steps[0].Name != "find-nodes" || runbook.Steps[1].Name != "#2" {
+		t.Errorf("unexpected steps %+v", runbook.Steps)
+	}
+
+	if runbooks, err := LoadRunbooks(filepath.Join(dir, "missing")); err != nil || len(runbooks) != 0 {
+		t.Errorf("expected no runbooks in a missing directory, got %v, %v", runbooks, err)
+	}
+}
+
+func TestLoadRunbooks_Invalid(t *testing.T) {
+	for content, want := range map[string]string{
+		"name: triage\n":                                "has no steps",
+		"steps:\n- name: first\n":                       "has no prompt",
+		"name: node pressure\nsteps:\n- prompt: list\n": "name with spaces",
+		"steps:\n- prompt: list\n  approve: always\n":   "unknown field",
+	} {
+		dir := writeRunbooks(t, map[string]string{"runbook.yaml": content})
+		if _, err := LoadRunbooks(dir); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadRunbooks(%q) error = %v, want %q", content, err, want)
+		}
+	}
+
+	dir := writeRunbooks(t, map[string]string{
+		"a.yaml": "name: triage\nsteps:\n- prompt: list\n",
+		"b.yaml": "name: triage\nsteps:\n- prompt: list\n",
+	})
+	if _, err := LoadRunbooks(dir); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("expected an error for a duplicate runbook, got %v", err)
+	}
+}
+
+func TestAgent_RunRunbook(t *testing.T) {
+	dir := writeRunbooks(t, map[string]string{"node-pressure.yaml": testRunbook})
+	runbooks, err := LoadRunbooks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &memoryRecorder{}
+	a := &Agent{
+		Output:   make(chan any, 20),
+		Session:  &api.Session{ID: "test-session", ChatMessageStore: sessions.NewInMemoryChatStore()},
+		Runbooks: runbooks,
+		Recorder: recorder,
+	}
+	ctx := context.Background()
+
+	if answer, handled, err := a.handleMetaQuery(ctx, "runbooks"); err != nil || !handled || !strings.Contains(answer, "node-pressure (2 steps): Triage nodes under pressure") {
+		t.Errorf("unexpected runbooks answer %q, %v", answer, err)
+	}
+	for _, query := range []string{"run missing", "run a pod with nginx"} {
+		if _, handled, err := a.handleMetaQuery(ctx, query); handled || err != nil {
+			t.Errorf("expected %q to be sent to the LLM, handled = %v, err = %v", query, handled, err)
+		}
+	}
+	if _, handled, err := a.handleMetaQuery(ctx, "run node-pressure"); err != nil || !handled {
+		t.Fatalf("run node-pressure: handled = %v, err = %v", handled, err)
+	}
+
+	for i, want := range runbooks["node-pressure"].Steps {
+		prompt, ok := a.nextRunbookStep(ctx)
+		if !ok || prompt != want.Prompt {
+			t.Fatalf("step %d: got %q, %v, want %q", i+1, prompt, ok, want.Prompt)
+		}
+		ref := journal.RunbookFromContext(a.runbookContext(ctx))
+		if ref == nil || ref.Runbook != "node-pressure" || ref.Step != want.Name {
+			t.Errorf("step %d: unexpected runbook of the actions %+v", i+1, ref)
+		}
+	}
+	if _, ok := a.nextRunbookStep(ctx); ok || a.runbook != nil {
+		t.Errorf("expected the runbook to be completed")
+	}
+	if journal.RunbookFromContext(a.runbookContext(ctx)) != nil {
+		t.Errorf("expected no runbook once completed")
+	}
+
+	if len(recorder.events) != 2 {
+		t.Fatalf("expected 2 runbook-step events, got %d", len(recorder.events))
+	}
+	if step := recorder.events[1].Payload.(*journal.RunbookStep); step.Runbook != "node-pressure" || step.Index != 2 || step.SessionID != "test-session" {
+		t.Errorf("unexpected runbook step event %+v", step)
+	}
+
+	// A failing step stops the runbook
+	a.handleMetaQuery(ctx, "run node-pressure")
+	a.nextRunbookStep(ctx)
+	a.lastErr = errors.New("quota exceeded")
+	if _, ok := a.nextRunbookStep(ctx); ok || a.runbook != nil {
+		t.Errorf("expected the runbook to stop after a failed step")
+	}
+}
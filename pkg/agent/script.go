@@ -0,0 +1,194 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// toolCallNotationRegexp matches the descriptions of tool calls that are not
+// local shell commands, e.g. promql_query(query=up) or the calls of MCP tools,
+// which run on their server.
+var toolCallNotationRegexp = regexp.MustCompile(`(?s)^(\[MCP: [^\]]*\] .*|[A-Za-z0-9_-]+\(.*\))$`)
+
+// sessionCommand is a command run in a session.
+type sessionCommand struct {
+	query       string
+	time        time.Time
+	command     string
+	kubeContext string
+	// id is the ID of the tool call request message.
+	id string
+	// failure is why the command failed, if it did.
+	failure string
+	// done is set once the result of the command is known.
+	done bool
+}
+
+// sessionCommands returns the commands run in session, in the order they were
+// run, with the query they were run for and their outcome.
+func sessionCommands(session *api.Session) []*sessionCommand {
+	var commands []*sessionCommand
+	var query string
+	for _, msg := range session.AllMessages() {
+		switch msg.Type {
+		case api.MessageTypeText:
+			if text, ok := msg.Payload.(string); ok && msg.Source == api.MessageSourceUser && text != "" {
+				query = text
+			}
+		case api.MessageTypeToolCallRequest:
+			commands = append(commands, &sessionCommand{
+				query:       query,
+				time:        msg.Timestamp,
+				command:     fmt.Sprint(msg.Payload),
+				kubeContext: msg.KubeContext,
+				id:          msg.ID,
+			})
+		case api.MessageTypeToolCallResponse:
+			// Results saved without the ID of their request come in the
+			// order the commands were requested
+			for _, command := range commands {
+				if !command.done && (msg.ToolCallID == "" || msg.ToolCallID == command.id) {
+					command.done = true
+					command.failure = toolCallFailure(msg.Payload)
+					break
+				}
+			}
+		}
+	}
+	return commands
+}
+
+// WriteShellScript writes the commands run in session, in the order they were
+// run, as a shell script, so that a fix can be codified into a runbook or a CI
+// job. Each command is preceded by the query it was run for; commands that
+// failed, and tool calls that are not shell commands, are commented out.
+// It returns the number of commands written.
+func WriteShellScript(w io.Writer, session *api.Session) (int, error) {
+	var sb strings.Builder
+	sb.WriteString("#!/usr/bin/env bash\n")
+	fmt.Fprintf(&sb, "# Commands run by kubectl-ai in session %s", session.ID)
+	if session.Name != "" {
+		fmt.Fprintf(&sb, " (%s)", session.Name)
+	}
+	sb.WriteString("\n# Review them before running this script, the cluster may have changed since.\n")
+	sb.WriteString("set -euo pipefail\n")
+
+	count := 0
+	query := ""
+	for _, command := range sessionCommands(session) {
+		if command.query != query {
+			query = command.query
+			sb.WriteString("\n" + commentLines("Query: "+query) + "\n")
+		}
+		sb.WriteString("\n")
+		if !command.time.IsZero() {
+			fmt.Fprintf(&sb, "# %s\n", command.time.Format(time.RFC3339))
+		}
+
+		line := command.command
+		if command.kubeContext != "" {
+			quoted, err := syntax.Quote(command.kubeContext, syntax.LangBash)
+			if rest, ok := strings.CutPrefix(line, "kubectl "); ok && err == nil {
+				line = "kubectl --context " + quoted + " " + rest
+			} else {
+				fmt.Fprintf(&sb, "# Run against the %s context\n", command.kubeContext)
+			}
+		}
+		switch {
+		case toolCallNotationRegexp.MatchString(line):
+			sb.WriteString("# Not a shell command:\n" + commentLines(line) + "\n")
+		case !command.done:
+			sb.WriteString("# Interrupted:\n" + commentLines(line) + "\n")
+		case command.failure != "":
+			sb.WriteString(commentLines("Failed: "+command.failure) + "\n" + commentLines(line) + "\n")
+		default:
+			sb.WriteString(line + "\n")
+			count++
+		}
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// toolCallFailure returns why a tool call failed, "" if it succeeded.
+func toolCallFailure(payload any) string {
+	if message, ok := payload.(string); ok {
+		// Tool calls that failed to run have their error as result
+		return message
+	}
+	result, err := tools.ToolResultToMap(payload)
+	if err != nil {
+		return ""
+	}
+	if message, _ := result["error"].(string); message != "" {
+		return message
+	}
+	if code, ok := result["exit_code"].(float64); ok && code != 0 {
+		return fmt.Sprintf("exit code %d", int(code))
+	}
+	return ""
+}
+
+// commentLines turns text into shell comment lines.
+func commentLines(text string) string {
+	return "# " + strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n# ")
+}
+
+// exportScript writes the commands of the current session to path, by
+// default kubectl-ai-<session ID>.sh in the working directory. It doesn't
+// overwrite existing files.
+func (c *Agent) exportScript(path string) (string, error) {
+	c.sessionMu.Lock()
+	session := c.Session
+	c.sessionMu.Unlock()
+	if path == "" {
+		path = "kubectl-ai-" + session.ID + ".sh"
+	}
+
+	var sb strings.Builder
+	count, err := WriteShellScript(&sb, session)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o755)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Sprintf("%s already exists, choose another path: export-script <path>", path), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("writing script: %w", err)
+	}
+	if _, err := f.WriteString(sb.String()); err != nil {
+		f.Close()
+		return "", fmt.Errorf("writing script: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("writing script: %w", err)
+	}
+	return fmt.Sprintf("Wrote %d commands to %s.", count, path), nil
+}
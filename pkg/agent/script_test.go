@@ -0,0 +1,129 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func TestWriteShellScript(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	session := &api.Session{ID: "20250807-510872", ChatMessageStore: store}
+	for _, msg := range []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "scale web to 3 replicas"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get deploy web"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "kubectl scale deploy web --replicas=3", KubeContext: "prod cluster"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stdout: "web 1/1"}},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stderr: "forbidden", ExitCode: 1}},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeText, Payload: "Retrying."},
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "check the metrics"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "promql_query(query=up)"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: map[string]any{"result": "1"}},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "[MCP: ops] restart web"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: map[string]any{"result": "ok"}},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "kubectl rollout status deploy web"},
+	} {
+		if err := store.AddChatMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sb strings.Builder
+	count, err := WriteShellScript(&sb, session)
+	if err != nil {
+		t.Fatalf("WriteShellScript() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 command written, got %d", count)
+	}
+	script := sb.String()
+	for _, want := range []string{
+		"#!/usr/bin/env bash\n",
+		"set -euo pipefail\n",
+		"# Query: scale web to 3 replicas\n\nkubectl get deploy web\n",
+		"# Failed: exit code 1\n# kubectl --context 'prod cluster' scale deploy web --replicas=3\n",
+		"# Query: check the metrics\n\n# Not a shell command:\n# promql_query(query=up)\n",
+		"# Not a shell command:\n# [MCP: ops] restart web\n",
+		"# Interrupted:\n# kubectl rollout status deploy web\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected the script to contain %q, got:\n%s", want, script)
+		}
+	}
+}
+
+func TestExportScript(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "existing.sh")
+	if err := os.WriteFile(path, []byte("echo keep me\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := &Agent{Session: &api.Session{ID: "test-session", ChatMessageStore: sessions.NewInMemoryChatStore()}}
+
+	if answer, handled, err := a.handleMetaQuery(ctx, "export-script "+path); err != nil || !handled || !strings.Contains(answer, "already exists") {
+		t.Errorf("expected the existing file not to be overwritten, got %q, %v", answer, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "echo keep me\n" {
+		t.Errorf("expected the existing file to be kept, got %q", b)
+	}
+
+	a.DisableLocalFiles = true
+	other := filepath.Join(filepath.Dir(path), "other.sh")
+	if answer, handled, err := a.handleMetaQuery(ctx, "export-script "+other); err != nil || !handled || !strings.Contains(answer, "not available") {
+		t.Errorf("expected export-script to be disabled, got %q, %v", answer, err)
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Errorf("expected no script to be written, got %v", err)
+	}
+}
+
+func TestWriteShellScript_ParallelCalls(t *testing.T) {
+	store := sessions.NewInMemoryChatStore()
+	session := &api.Session{ID: "20250807-510872", ChatMessageStore: store}
+	for _, msg := range []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "restart web and api"},
+		{ID: "web", Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "kubectl rollout restart deploy web"},
+		{ID: "api", Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallRequest, Payload: "kubectl rollout restart deploy api"},
+		// The calls ran in parallel, the second one finished first.
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stderr: "not found", ExitCode: 1}, ToolCallID: "api"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stdout: "restarted"}, ToolCallID: "web"},
+	} {
+		if err := store.AddChatMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sb strings.Builder
+	if _, err := WriteShellScript(&sb, session); err != nil {
+		t.Fatalf("WriteShellScript() error = %v", err)
+	}
+	script := sb.String()
+	for _, want := range []string{
+		"\nkubectl rollout restart deploy web\n",
+		"# Failed: exit code 1\n# kubectl rollout restart deploy api\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected the script to contain %q, got:\n%s", want, script)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// searchGroundingProviders are the providers grounding completions with web
+// search results, see gollm.WithSearchGrounding.
+var searchGroundingProviders = []string{"gemini", "vertexai"}
+
+// WebSearchTool answers a question with a completion of the model grounded
+// with web search results. Gemini can't combine search grounding with function
+// calling, so the chat of the agent, which always defines tools, can't be
+// grounded itself.
+type WebSearchTool struct {
+	agent *Agent
+}
+
+func newWebSearchTool(agent *Agent) *WebSearchTool {
+	return &WebSearchTool{agent: agent}
+}
+
+func (t *WebSearchTool) Name() string {
+	return "web_search"
+}
+
+func (t *WebSearchTool) Description() string {
+	return "Answers a question using web search results, e.g. the release notes of a Kubernetes version, " +
+		"the documentation of an operator or a known issue behind an error message. " +
+		"Do not include secrets or other sensitive data from the cluster in the question."
+}
+
+func (t *WebSearchTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"question": {
+					Type:        gollm.TypeString,
+					Description: "The question, self-contained.",
+				},
+			},
+			Required: []string{"question"},
+		},
+	}
+}
+
+func (t *WebSearchTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	question, _ := args["question"].(string)
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return &sandbox.ExecResult{Error: "question is required"}, nil
+	}
+
+	c := t.agent
+	// The model may have been switched to a provider without grounding
+	if !slices.Contains(searchGroundingProviders, c.Provider) {
+		return &sandbox.ExecResult{Error: fmt.Sprintf("web search is not supported by provider %s", c.Provider)}, nil
+	}
+	response, err := c.LLM.GenerateCompletion(ctx, &gollm.CompletionRequest{Model: c.Model, Prompt: question})
+	if err != nil {
+		return nil, fmt.Errorf("searching the web: %w", err)
+	}
+	c.recordUsage(response.UsageMetadata())
+	return map[string]any{"answer": response.Response()}, nil
+}
+
+func (t *WebSearchTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *WebSearchTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
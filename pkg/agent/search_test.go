@@ -0,0 +1,56 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/internal/mocks"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"go.uber.org/mock/gomock"
+)
+
+// textCompletion is a completion response with no usage metadata.
+type textCompletion string
+
+func (c textCompletion) Response() string   { return string(c) }
+func (c textCompletion) UsageMetadata() any { return nil }
+
+func TestWebSearchTool(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	llm := mocks.NewMockClient(ctrl)
+	response := textCompletion("Kubernetes 1.33 removed the gitRepo volume.")
+	llm.EXPECT().GenerateCompletion(gomock.Any(), &gollm.CompletionRequest{Model: "gemini-2.5-flash", Prompt: "what did kubernetes 1.33 remove?"}).Return(response, nil)
+
+	a := &Agent{LLM: llm, Model: "gemini-2.5-flash", Provider: "gemini", Session: &api.Session{}}
+	tool := newWebSearchTool(a)
+	out, err := tool.Run(context.Background(), map[string]any{"question": "what did kubernetes 1.33 remove?"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := out.(map[string]any)["answer"]; got != "Kubernetes 1.33 removed the gitRepo volume." {
+		t.Errorf("unexpected answer %v", got)
+	}
+
+	a.Provider = "openai"
+	out, err = tool.Run(context.Background(), map[string]any{"question": "what did kubernetes 1.33 remove?"})
+	if result, ok := out.(*sandbox.ExecResult); err != nil || !ok || !strings.Contains(result.Error, "not supported") {
+		t.Errorf("expected an error with a provider without grounding, got %v, %v", out, err)
+	}
+}
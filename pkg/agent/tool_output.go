@@ -0,0 +1,125 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// maxToolOutputLine is the size after which an incomplete line of output is
+// sent anyway, e.g. for progress bars that never end their line.
+const maxToolOutputLine = 4096
+
+// streamedRedactedCommands are the commands whose output is streamed when
+// secrets are redacted: they don't print Secrets, whose masking depends on
+// the whole document, and run for long.
+var streamedRedactedCommands = [][]string{
+	{"kubectl", "rollout", "status"},
+	{"kubectl", "logs"},
+	{"kubectl", "events"},
+	{"kubectl", "wait"},
+	{"kubectl", "top"},
+}
+
+// toolOutputStream sends the output of a running tool call to the UI, a line
+// at a time, so that secrets can be masked line by line.
+type toolOutputStream struct {
+	agent *Agent
+	// id is the ID of the tool call request message.
+	id string
+
+	mu sync.Mutex
+	// partial holds the incomplete last line of each stream.
+	partial map[string][]byte
+}
+
+// newToolOutputStream returns the stream of the output of the tool call
+// described by description, whose request message has the given ID. It
+// returns nil if the output isn't streamed: when StreamToolOutput is off, and
+// when content filters are set, as they only see the complete output. The
+// masking of Secrets depends on the whole document, so when secrets are
+// redacted, only the output of streamedRedactedCommands is streamed.
+func (c *Agent) newToolOutputStream(id, description string) *toolOutputStream {
+	if !c.StreamToolOutput || len(c.ContentFilters) > 0 {
+		return nil
+	}
+	if c.redactor != nil && !isStreamedRedactedCommand(description) {
+		return nil
+	}
+	return &toolOutputStream{agent: c, id: id, partial: map[string][]byte{}}
+}
+
+// isStreamedRedactedCommand reports whether command is one of
+// streamedRedactedCommands, alone: commands chaining or substituting others
+// aren't.
+func isStreamedRedactedCommand(command string) bool {
+	if strings.ContainsAny(command, "|;&`$()<>\\\n") {
+		return false
+	}
+	fields := strings.Fields(command)
+	return slices.ContainsFunc(streamedRedactedCommands, func(prefix []string) bool {
+		return len(fields) >= len(prefix) && slices.Equal(fields[:len(prefix)], prefix)
+	})
+}
+
+// write is the sandbox.OutputStream of the tool call.
+func (s *toolOutputStream) write(name string, p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf := append(s.partial[name], p...)
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		s.send(name, buf[:i+1])
+		buf = buf[i+1:]
+	}
+	if len(buf) > maxToolOutputLine {
+		s.send(name, buf)
+		buf = nil
+	}
+	s.partial[name] = bytes.Clone(buf)
+}
+
+// flush sends the incomplete last lines, once the tool call is done.
+func (s *toolOutputStream) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, buf := range s.partial {
+		if len(buf) > 0 {
+			s.send(name, buf)
+		}
+		delete(s.partial, name)
+	}
+}
+
+func (s *toolOutputStream) send(name string, p []byte) {
+	text := string(p)
+	if s.agent.redactor != nil {
+		text = s.agent.redactor.RedactString(text)
+	}
+	s.agent.Output <- &api.Message{
+		ID:        s.id,
+		Source:    api.MessageSourceAgent,
+		Type:      api.MessageTypeToolOutputDelta,
+		Payload:   &api.ToolOutputDelta{Stream: name, Text: text},
+		Timestamp: time.Now(),
+	}
+}
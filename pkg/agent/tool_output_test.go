@@ -0,0 +1,79 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+func TestToolOutputStream(t *testing.T) {
+	const token = "ghp_" + "abcdefghijklmnopqrstuvwxyz0123456789"
+	redactor, err := tools.NewRedactor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Agent{StreamToolOutput: true, Output: make(chan any, 10), redactor: redactor}
+
+	for _, command := range []string{
+		"kubectl get secret web -o yaml",
+		"kubectl get deployment web -o yaml",
+		"kubectl describe pod web-0",
+		"kubectl logs web-0; kubectl get secrets -o yaml",
+		"kubectl logs $(kubectl get secret web -o name)",
+		"helm get values web",
+	} {
+		if a.newToolOutputStream("call-1", command) != nil {
+			t.Errorf("expected the output of %q not to be streamed with secrets redacted", command)
+		}
+	}
+	if a.newToolOutputStream("call-1", "kubectl logs -f web-0 -n shop") == nil {
+		t.Errorf("expected the output of kubectl logs to be streamed")
+	}
+
+	stream := a.newToolOutputStream("call-1", "kubectl rollout status deployment/web")
+	stream.write("stdout", []byte("Waiting for rollout: 0 of 2 updated"))
+	stream.write("stdout", []byte(" replicas...\nWaiting"))
+	stream.write("stderr", []byte("token "+token+"\n"))
+	stream.write("stdout", []byte(" for rollout: 1 of 2"))
+	stream.flush()
+	close(a.Output)
+
+	var got []api.ToolOutputDelta
+	for output := range a.Output {
+		msg := output.(*api.Message)
+		if msg.ID != "call-1" || msg.Type != api.MessageTypeToolOutputDelta {
+			t.Errorf("unexpected message %+v", msg)
+		}
+		got = append(got, *msg.Payload.(*api.ToolOutputDelta))
+	}
+	want := []api.ToolOutputDelta{
+		{Stream: "stdout", Text: "Waiting for rollout: 0 of 2 updated replicas...\n"},
+		{Stream: "stderr", Text: "token [REDACTED]\n"},
+		{Stream: "stdout", Text: "Waiting for rollout: 1 of 2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("streamed %+v, want %+v", got, want)
+	}
+	for _, delta := range got {
+		if strings.Contains(delta.Text, token) {
+			t.Errorf("expected the token to be redacted, got %q", delta.Text)
+		}
+	}
+}
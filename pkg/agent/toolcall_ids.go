@@ -0,0 +1,144 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"k8s.io/klog/v2"
+)
+
+// toolCallIDs correlates the tool calls of the LLM with their results.
+// Providers use different ID formats, some none at all or the same ID for
+// every call, so calls are given stable internal IDs when they are received.
+// Results are checked against the calls still waiting for one before they are
+// sent, and given back the ID of the provider. It is only used by the agent
+// loop.
+type toolCallIDs struct {
+	next int
+	// toProvider and fromProvider map the internal IDs of outstanding calls
+	// to provider IDs and back. Provider IDs used by several calls map to "".
+	toProvider   map[string]string
+	fromProvider map[string]string
+	// outstanding are the internal IDs of the calls without a result, in
+	// the order they were received.
+	outstanding []outstandingCall
+}
+
+type outstandingCall struct {
+	id   string
+	name string
+}
+
+// register gives calls received from the LLM internal IDs, and returns them
+// with these IDs.
+func (t *toolCallIDs) register(calls []gollm.FunctionCall) []gollm.FunctionCall {
+	if t.toProvider == nil {
+		t.toProvider = map[string]string{}
+		t.fromProvider = map[string]string{}
+	}
+	registered := make([]gollm.FunctionCall, len(calls))
+	for i, call := range calls {
+		t.next++
+		id := fmt.Sprintf("call-%d", t.next)
+		t.toProvider[id] = call.ID
+		if call.ID != "" {
+			if _, ok := t.fromProvider[call.ID]; ok {
+				klog.Warningf("tool call ID %q was used by several calls", call.ID)
+				t.fromProvider[call.ID] = ""
+			} else {
+				t.fromProvider[call.ID] = id
+			}
+		}
+		t.outstanding = append(t.outstanding, outstandingCall{id: id, name: call.Name})
+		call.ID = id
+		registered[i] = call
+	}
+	return registered
+}
+
+// resolve returns contents with the tool call results given the IDs of the
+// provider. Results that answer no outstanding call, e.g. a second result for
+// a call, are dropped: providers reject them. A result whose ID is unknown is
+// matched with the only outstanding call of its tool, if there is one.
+func (t *toolCallIDs) resolve(contents []any) []any {
+	answered := map[string]bool{}
+	resolved := make([]any, 0, len(contents))
+	for _, content := range contents {
+		result, ok := content.(gollm.FunctionCallResult)
+		if !ok {
+			resolved = append(resolved, content)
+			continue
+		}
+		call, err := t.match(result, answered)
+		if err != nil {
+			klog.Warningf("dropping tool call result: %v", err)
+			continue
+		}
+		answered[call.id] = true
+		if result.Name != call.name {
+			klog.Warningf("result of tool call %s is for %q, but the call was to %q", call.id, result.Name, call.name)
+		}
+		result.ID = t.toProvider[call.id]
+		result.Name = call.name
+		resolved = append(resolved, result)
+	}
+	for _, call := range t.outstanding {
+		if !answered[call.id] {
+			klog.Warningf("tool call %s to %q has no result", call.id, call.name)
+		}
+	}
+	return resolved
+}
+
+// match returns the outstanding call a result answers.
+func (t *toolCallIDs) match(result gollm.FunctionCallResult, answered map[string]bool) (outstandingCall, error) {
+	id := result.ID
+	if internal := t.fromProvider[id]; internal != "" {
+		// A provider ID, the result was not built from a registered call
+		id = internal
+	}
+	for _, call := range t.outstanding {
+		if call.id == id {
+			if answered[id] {
+				return outstandingCall{}, fmt.Errorf("tool call %s to %q already has a result", id, call.name)
+			}
+			return call, nil
+		}
+	}
+
+	var candidates []outstandingCall
+	for _, call := range t.outstanding {
+		if call.name == result.Name && !answered[call.id] {
+			candidates = append(candidates, call)
+		}
+	}
+	if len(candidates) == 1 {
+		klog.Warningf("tool call ID %q is unknown, matched the result with call %s to %q", result.ID, candidates[0].id, result.Name)
+		return candidates[0], nil
+	}
+	return outstandingCall{}, fmt.Errorf("tool call ID %q of %q matches no outstanding call", result.ID, result.Name)
+}
+
+// sent is called once contents resolved by resolve were sent: the calls can't
+// be answered anymore, and their provider IDs may be used again.
+func (t *toolCallIDs) sent() {
+	for _, call := range t.outstanding {
+		delete(t.fromProvider, t.toProvider[call.id])
+		delete(t.toProvider, call.id)
+	}
+	t.outstanding = nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+func TestToolCallIDs(t *testing.T) {
+	var ids toolCallIDs
+	calls := ids.register([]gollm.FunctionCall{
+		{ID: "toolu_01", Name: "kubectl"},
+		{ID: "toolu_02", Name: "kubectl"},
+		{ID: "", Name: "bash"},
+	})
+	if calls[0].ID != "call-1" || calls[1].ID != "call-2" || calls[2].ID != "call-3" || calls[0].Name != "kubectl" {
+		t.Fatalf("unexpected registered calls %+v", calls)
+	}
+
+	got := ids.resolve([]any{
+		"a query",
+		gollm.FunctionCallResult{ID: "call-2", Name: "kubectl", Result: map[string]any{"n": 2}},
+		// A provider ID
+		gollm.FunctionCallResult{ID: "toolu_01", Name: "kubectl", Result: map[string]any{"n": 1}},
+		// A second result
+		gollm.FunctionCallResult{ID: "call-2", Name: "kubectl"},
+		// An unknown ID, matched by tool
+		gollm.FunctionCallResult{ID: "call_abc", Name: "bash", Result: map[string]any{"n": 3}},
+		// An unknown call
+		gollm.FunctionCallResult{ID: "call-9", Name: "promql_query"},
+	})
+	want := []any{
+		"a query",
+		gollm.FunctionCallResult{ID: "toolu_02", Name: "kubectl", Result: map[string]any{"n": 2}},
+		gollm.FunctionCallResult{ID: "toolu_01", Name: "kubectl", Result: map[string]any{"n": 1}},
+		gollm.FunctionCallResult{ID: "", Name: "bash", Result: map[string]any{"n": 3}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolve() = %+v, want %+v", got, want)
+	}
+
+	// Provider IDs can be used again once the results were sent
+	ids.sent()
+	calls = ids.register([]gollm.FunctionCall{{ID: "toolu_01", Name: "kubectl"}})
+	got = ids.resolve([]any{gollm.FunctionCallResult{ID: "toolu_01", Name: "kubectl"}})
+	want = []any{gollm.FunctionCallResult{ID: "toolu_01", Name: "kubectl"}}
+	if calls[0].ID != "call-4" || !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected calls %+v and results %+v after sending", calls, got)
+	}
+}
+
+func TestToolCallIDs_DuplicateProviderIDs(t *testing.T) {
+	var ids toolCallIDs
+	ids.register([]gollm.FunctionCall{{ID: "0", Name: "kubectl"}, {ID: "0", Name: "bash"}})
+	got := ids.resolve([]any{
+		gollm.FunctionCallResult{ID: "call-2", Name: "bash"},
+		gollm.FunctionCallResult{ID: "call-1", Name: "kubectl"},
+	})
+	want := []any{
+		gollm.FunctionCallResult{ID: "0", Name: "bash"},
+		gollm.FunctionCallResult{ID: "0", Name: "kubectl"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolve() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// variableNameRegex matches the valid names of session variables.
+	variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// variableRefRegex matches the references to variables, $name or ${name}.
+	variableRefRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+	// bracedVariableRefRegex matches the references to variables in tool
+	// calls, ${name} only.
+	bracedVariableRefRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// substituteVariables replaces the references to the session variables in
+// the user's text, $name or ${name}, with their values. References to
+// variables that are not set, e.g. to shell variables in commands, are left as
+// they are.
+func (c *Agent) substituteVariables(text string) string {
+	return expandVariables(text, c.sessionVariables(), variableRefRegex)
+}
+
+// substituteArguments returns a copy of the arguments of a tool call, with
+// the references to the session variables in their string values replaced.
+// Only ${name} is substituted: $name is left to the shell in the commands
+// written by the LLM, e.g. the loop variable of a for loop.
+func (c *Agent) substituteArguments(args map[string]any) map[string]any {
+	variables := c.sessionVariables()
+	if len(variables) == 0 {
+		return args
+	}
+	return expandArgument(args, variables).(map[string]any)
+}
+
+func expandArgument(v any, variables map[string]string) any {
+	switch v := v.(type) {
+	case string:
+		return expandVariables(v, variables, bracedVariableRefRegex)
+	case map[string]any:
+		expanded := make(map[string]any, len(v))
+		for key, value := range v {
+			expanded[key] = expandArgument(value, variables)
+		}
+		return expanded
+	case []any:
+		expanded := make([]any, len(v))
+		for i, value := range v {
+			expanded[i] = expandArgument(value, variables)
+		}
+		return expanded
+	default:
+		return v
+	}
+}
+
+// sessionVariables returns a copy of the variables of the session.
+func (c *Agent) sessionVariables() map[string]string {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.Session == nil {
+		return nil
+	}
+	return maps.Clone(c.Session.Variables)
+}
+
+// expandVariables replaces the references to variables matched by refRegex
+// in text.
+func expandVariables(text string, variables map[string]string, refRegex *regexp.Regexp) string {
+	if len(variables) == 0 || !strings.Contains(text, "$") {
+		return text
+	}
+	return refRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		name := strings.Trim(ref, "${}")
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// setVariable sets the session variable name to value, or unsets it if
+// value is empty, and returns the variables of the session.
+func (c *Agent) setVariable(ctx context.Context, name, value string) (map[string]string, error) {
+	if !variableNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid variable name %q, expected letters, digits and underscores", name)
+	}
+	return c.updateSessionMemory(ctx, func(session *sessionState) {
+		if value == "" {
+			delete(session.variables, name)
+			return
+		}
+		session.variables[name] = value
+	})
+}
+
+// sessionState is the part of the session updated by the variable and
+// memory meta-queries.
+type sessionState struct {
+	variables map[string]string
+	memory    []string
+}
+
+// updateSessionMemory applies update to the variables and memory of the
+// session, saves the session and restarts the chat, so that the system
+// prompt shows them. It returns the variables of the session.
+func (c *Agent) updateSessionMemory(ctx context.Context, update func(*sessionState)) (map[string]string, error) {
+	c.sessionMu.Lock()
+	session := c.Session
+	state := &sessionState{
+		variables: maps.Clone(session.Variables),
+		memory:    slices.Clone(session.Memory),
+	}
+	if state.variables == nil {
+		state.variables = map[string]string{}
+	}
+	update(state)
+	session.Variables = state.variables
+	session.Memory = state.memory
+	c.sessionMu.Unlock()
+
+	manager, err := sessions.NewSessionManager(c.SessionBackend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session manager: %w", err)
+	}
+	if err := manager.UpdateLastAccessed(session); err != nil {
+		return nil, fmt.Errorf("failed to save session memory: %w", err)
+	}
+
+	if c.llmChat != nil {
+		if err := c.startChat(ctx); err != nil {
+			return nil, fmt.Errorf("restarting chat: %w", err)
+		}
+		if err := c.setFunctionDefinitions(); err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to set function definitions after updating the session memory")
+		}
+	}
+	return state.variables, nil
+}
+
+// handleMemoryQuery handles the meta-queries managing the session variables
+// and memory:
+//   - set, vars: list the variables
+//   - set <name>=<value>: set a variable
+//   - unset <name>: unset a variable
+//   - memory: list the remembered facts
+//   - memory add <fact>: remember a fact
+//   - memory forget <n>|all: forget the n-th fact, or all of them
+//
+// Queries that don't have the exact form of a meta-query, e.g. "unset the
+// labels of web" or "memory usage of the pods", go to the LLM.
+func (c *Agent) handleMemoryQuery(ctx context.Context, query string) (answer string, handled bool, err error) {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "", false, nil
+	}
+
+	switch fields[0] {
+	case "vars":
+		if len(fields) != 1 {
+			return "", false, nil
+		}
+		return c.formatVariables(), true, nil
+
+	case "set":
+		if len(fields) == 1 {
+			return c.formatVariables(), true, nil
+		}
+		// Only handle "set name=value", so that queries like "set the
+		// replicas of web to 3" go to the LLM
+		name, value, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(query, "set")), "=")
+		if !ok || !variableNameRegex.MatchString(strings.TrimSpace(name)) {
+			return "", false, nil
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if value == "" {
+			return "Invalid command. Usage: set <name>=<value>", true, nil
+		}
+		if _, err := c.setVariable(ctx, name, value); err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("Set `%s` to `%s`, substituted for `$%s` in your queries and `${%s}` in tool calls.", name, value, name, name), true, nil
+
+	case "unset":
+		if len(fields) != 2 || !variableNameRegex.MatchString(fields[1]) {
+			return "", false, nil
+		}
+		c.sessionMu.Lock()
+		_, ok := c.Session.Variables[fields[1]]
+		c.sessionMu.Unlock()
+		if !ok {
+			return fmt.Sprintf("The variable `%s` is not set.", fields[1]), true, nil
+		}
+		if _, err := c.setVariable(ctx, fields[1], ""); err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("Unset `%s`.", fields[1]), true, nil
+
+	case "memory":
+		switch {
+		case len(fields) == 1:
+			return c.formatMemory(), true, nil
+		case len(fields) >= 3 && fields[1] == "add":
+			return c.rememberFact(ctx, strings.Join(fields[2:], " "))
+		case len(fields) == 3 && fields[1] == "forget":
+			return c.forgetFact(ctx, fields[2])
+		}
+	}
+	return "", false, nil
+}
+
+// rememberFact adds fact to the session memory, for memory add.
+func (c *Agent) rememberFact(ctx context.Context, fact string) (string, bool, error) {
+	if _, err := c.updateSessionMemory(ctx, func(state *sessionState) {
+		if !slices.Contains(state.memory, fact) {
+			state.memory = append(state.memory, fact)
+		}
+	}); err != nil {
+		return "", false, err
+	}
+	return "Remembered: " + fact, true, nil
+}
+
+// forgetFact removes the fact number arg of the session memory, or all of
+// them, for memory forget.
+func (c *Agent) forgetFact(ctx context.Context, arg string) (string, bool, error) {
+	c.sessionMu.Lock()
+	count := len(c.Session.Memory)
+	c.sessionMu.Unlock()
+	if arg == "all" {
+		if _, err := c.updateSessionMemory(ctx, func(state *sessionState) { state.memory = nil }); err != nil {
+			return "", false, err
+		}
+		return "Forgot all the remembered facts.", true, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > count {
+		return fmt.Sprintf("Invalid fact number %q, see `memory` for the remembered facts.", arg), true, nil
+	}
+	var forgotten string
+	if _, err := c.updateSessionMemory(ctx, func(state *sessionState) {
+		forgotten = state.memory[n-1]
+		state.memory = slices.Delete(state.memory, n-1, n)
+	}); err != nil {
+		return "", false, err
+	}
+	return "Forgot: " + forgotten, true, nil
+}
+
+// formatVariables lists the session variables for the vars meta-query.
+func (c *Agent) formatVariables() string {
+	c.sessionMu.Lock()
+	variables := maps.Clone(c.Session.Variables)
+	c.sessionMu.Unlock()
+	if len(variables) == 0 {
+		return "No variables are set. Usage: set <name>=<value>"
+	}
+	var sb strings.Builder
+	sb.WriteString("Session variables:\n")
+	for _, name := range slices.Sorted(maps.Keys(variables)) {
+		fmt.Fprintf(&sb, "- `%s` = `%s`\n", name, variables[name])
+	}
+	return sb.String()
+}
+
+// formatMemory lists the remembered facts for the memory meta-query.
+func (c *Agent) formatMemory() string {
+	c.sessionMu.Lock()
+	memory := slices.Clone(c.Session.Memory)
+	c.sessionMu.Unlock()
+	if len(memory) == 0 {
+		return "No facts are remembered. Usage: memory add <fact>"
+	}
+	var sb strings.Builder
+	sb.WriteString("Remembered facts:\n")
+	for i, fact := range memory {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, fact)
+	}
+	return sb.String()
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+)
+
+func TestExpandVariables(t *testing.T) {
+	variables := map[string]string{"ns": "payments-prod", "app": "web"}
+	for text, want := range map[string]string{
+		"pods in $ns":                     "pods in payments-prod",
+		"logs of ${app}-0 in ${ns}":       "logs of web-0 in payments-prod",
+		"for p in $PODS; do echo $p":      "for p in $PODS; do echo $p",
+		"costs $5":                        "costs $5",
+		"$nsx is not $ns":                 "$nsx is not payments-prod",
+		"no variables in this query":      "no variables in this query",
+		"kubectl get pods -n $ns -o wide": "kubectl get pods -n payments-prod -o wide",
+	} {
+		if got := expandVariables(text, variables, variableRefRegex); got != want {
+			t.Errorf("expandVariables(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestSubstituteArguments(t *testing.T) {
+	a := &Agent{Session: &api.Session{Variables: map[string]string{"ns": "payments-prod"}}}
+	args := map[string]any{
+		"command":  "kubectl get pods -n ${ns}",
+		"modifies": "no",
+		"timeout":  30,
+		"patches":  []any{map[string]any{"path": "/metadata/namespace", "value": "${ns}"}},
+	}
+	got := a.substituteArguments(args)
+	want := map[string]any{
+		"command":  "kubectl get pods -n payments-prod",
+		"modifies": "no",
+		"timeout":  30,
+		"patches":  []any{map[string]any{"path": "/metadata/namespace", "value": "payments-prod"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("substituteArguments() = %v, want %v", got, want)
+	}
+	if args["command"] != "kubectl get pods -n ${ns}" {
+		t.Errorf("expected the arguments not to be modified, got %v", args)
+	}
+}
+
+func TestAnalyzeToolCalls_KeepsShellVariables(t *testing.T) {
+	a := &Agent{Session: &api.Session{Variables: map[string]string{"ns": "payments-prod"}}}
+	a.Tools.Init()
+	a.Tools.RegisterTool(tools.NewBashTool(nil))
+
+	command := "kubectl get pods -n ${ns}; for ns in $(kubectl get ns -o name); do echo $ns; done"
+	analysis, err := a.analyzeToolCalls(context.Background(), []gollm.FunctionCall{{Name: "bash", Arguments: map[string]any{"command": command}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "kubectl get pods -n payments-prod; for ns in $(kubectl get ns -o name); do echo $ns; done"
+	if got := analysis[0].FunctionCall.Arguments["command"]; got != want {
+		t.Errorf("expected ${ns} to be substituted and the shell variable $ns to be kept, got %q", got)
+	}
+}
+
+func TestHandleMemoryQuery(t *testing.T) {
+	ctx := context.Background()
+	manager, err := sessions.NewSessionManager("memory")
+	if err != nil {
+		t.Fatalf("creating session manager: %v", err)
+	}
+	session, err := manager.NewSession(sessions.Metadata{})
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	a := &Agent{SessionBackend: "memory", Session: session}
+
+	for _, tt := range []struct {
+		query   string
+		handled bool
+		want    string
+	}{
+		{query: "set ns=payments-prod", handled: true, want: "Set `ns` to `payments-prod`"},
+		{query: "set app = web", handled: true, want: "Set `app` to `web`"},
+		{query: "set the replicas of web to 3", handled: false},
+		{query: "vars", handled: true, want: "- `app` = `web`\n- `ns` = `payments-prod`\n"},
+		{query: "unset app", handled: true, want: "Unset `app`."},
+		{query: "unset app", handled: true, want: "The variable `app` is not set."},
+		{query: "unset the labels of web", handled: false},
+		{query: "unset app ns", handled: false},
+		{query: "memory add the payments team owns $ns", handled: true, want: "Remembered: the payments team owns $ns"},
+		{query: "memory add nodes are drained on Fridays", handled: true},
+		{query: "remember to check the ingress", handled: false},
+		{query: "memory", handled: true, want: "1. the payments team owns $ns\n2. nodes are drained on Fridays\n"},
+		{query: "memory forget 3", handled: true, want: "Invalid fact number"},
+		{query: "memory forget 1", handled: true, want: "Forgot: the payments team owns $ns"},
+		{query: "memory forget the old pods", handled: false},
+		{query: "forget the pods of web", handled: false},
+		{query: "memory of the pods", handled: false},
+	} {
+		answer, handled, err := a.handleMemoryQuery(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("handleMemoryQuery(%q) error = %v", tt.query, err)
+		}
+		if handled != tt.handled || !strings.Contains(answer, tt.want) {
+			t.Errorf("handleMemoryQuery(%q) = %q, %t, want %q, %t", tt.query, answer, handled, tt.want, tt.handled)
+		}
+	}
+
+	saved, err := manager.FindSessionByID(session.ID)
+	if err != nil {
+		t.Fatalf("finding session: %v", err)
+	}
+	if !reflect.DeepEqual(saved.Variables, map[string]string{"ns": "payments-prod"}) {
+		t.Errorf("expected the variables to be saved, got %v", saved.Variables)
+	}
+	if !reflect.DeepEqual(saved.Memory, []string{"nodes are drained on Fridays"}) {
+		t.Errorf("expected the memory to be saved, got %v", saved.Memory)
+	}
+}
+
+func TestSystemPrompt_SessionMemory(t *testing.T) {
+	a := &Agent{}
+	got, err := a.generatePrompt(context.Background(), defaultSystemPromptTemplate, PromptData{
+		Memory:           []string{"nodes are drained on Fridays"},
+		SessionVariables: map[string]string{"ns": "payments-prod"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"## Session memory",
+		"- nodes are drained on Fridays\n",
+		"- $ns is set to payments-prod,",
+		"In your tool calls, ${ns} is substituted too, while $ns is left to the shell.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected the system prompt to contain %q", want)
+		}
+	}
+}
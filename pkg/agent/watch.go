@@ -0,0 +1,102 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog/v2"
+)
+
+// watchRetryInterval is how long to wait before watching a resource again
+// after the watch failed.
+const watchRetryInterval = 10 * time.Second
+
+// WatchResources watches the given resources, e.g. pods or
+// deployments.apps, in all namespaces of the cluster tools use, and calls
+// onEvent with the resource of each change until ctx is done. The initial
+// state of the resources is not reported.
+func (c *Agent) WatchResources(ctx context.Context, resources []string, onEvent func(resource string)) error {
+	config, err := c.kubeClientConfig().ClientConfig()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return fmt.Errorf("creating discovery client: %w", err)
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("creating dynamic client: %w", err)
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	for _, resource := range resources {
+		gvr, err := mapper.ResourceFor(schema.ParseGroupResource(resource).WithVersion(""))
+		if err != nil {
+			return fmt.Errorf("resolving resource %q: %w", resource, err)
+		}
+		go watchResource(ctx, client, gvr, func() { onEvent(resource) })
+	}
+	return nil
+}
+
+// watchResource calls onEvent on each change of the resource, watching it
+// again when the watch ends, e.g. after the server timed it out.
+func watchResource(ctx context.Context, client dynamic.Interface, gvr schema.GroupVersionResource, onEvent func()) {
+	for ctx.Err() == nil {
+		err := watchResourceOnce(ctx, client.Resource(gvr), onEvent)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		klog.Warningf("error watching %s: %v", gvr.GroupResource(), err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+func watchResourceOnce(ctx context.Context, resource dynamic.NamespaceableResourceInterface, onEvent func()) error {
+	// Start from the current state, so that existing objects aren't
+	// reported as added
+	list, err := resource.List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+	watcher, err := resource.Watch(ctx, metav1.ListOptions{ResourceVersion: list.GetResourceVersion()})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+	for event := range watcher.ResultChan() {
+		if event.Type == watch.Error {
+			return fmt.Errorf("watch failed: %v", event.Object)
+		}
+		if event.Type != watch.Bookmark {
+			onEvent()
+		}
+	}
+	return nil
+}
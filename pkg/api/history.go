@@ -0,0 +1,171 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HistoryRole is the author of a turn of a chat history.
+type HistoryRole string
+
+const (
+	HistoryRoleUser  HistoryRole = "user"
+	HistoryRoleModel HistoryRole = "model"
+)
+
+// HistoryTurn is a turn of a chat history, as sent to an LLM.
+type HistoryTurn struct {
+	Role HistoryRole
+	Text string
+}
+
+// NormalizeHistory converts the messages of a session into a chat history
+// every LLM provider accepts: turns alternate between the user and the model,
+// start with the user and are never empty. Tool calls and their results are
+// written as text, as messages don't record the IDs and arguments providers
+// need to replay them. Messages that only drive the UI, e.g. prompts for
+// input, are left out.
+func NormalizeHistory(messages []*Message) []HistoryTurn {
+	var turns []HistoryTurn
+	add := func(role HistoryRole, text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		if len(turns) == 0 && role != HistoryRoleUser {
+			// The model speaks first, e.g. a greeting: keep it as context
+			turns = append(turns, HistoryTurn{Role: HistoryRoleUser, Text: "(The conversation starts with a message of the assistant.)"})
+		}
+		if n := len(turns); n > 0 && turns[n-1].Role == role {
+			turns[n-1].Text += "\n\n" + text
+			return
+		}
+		turns = append(turns, HistoryTurn{Role: role, Text: text})
+	}
+
+	for _, msg := range messages {
+		if msg == nil || msg.Type.DrivesUIOnly() {
+			continue
+		}
+		switch msg.Type {
+		case MessageTypeText:
+			role := HistoryRoleUser
+			if msg.Source == MessageSourceModel {
+				role = HistoryRoleModel
+			}
+			add(role, payloadText(msg.Payload))
+		case MessageTypeError:
+			text := payloadText(msg.Payload)
+			if !strings.HasPrefix(text, "Error") {
+				text = "Error: " + text
+			}
+			add(HistoryRoleUser, text)
+		case MessageTypeToolCallRequest:
+			add(HistoryRoleModel, "Tool call: "+payloadText(msg.Payload))
+		case MessageTypeToolCallResponse:
+			add(HistoryRoleUser, "Tool result: "+payloadText(msg.Payload))
+		case MessageTypeImage:
+			var image Image
+			if decodePayload(msg.Payload, &image) == nil {
+				add(HistoryRoleUser, fmt.Sprintf("[Attached image %s, no longer available]", image.Name))
+			}
+		case MessageTypeAttachment:
+			var attachment Attachment
+			if decodePayload(msg.Payload, &attachment) == nil {
+				add(HistoryRoleUser, fmt.Sprintf("[Attached file %s, no longer available]", attachment.Name))
+			}
+		default:
+			add(HistoryRoleUser, payloadText(msg.Payload))
+		}
+	}
+	return turns
+}
+
+// DrivesUIOnly reports whether the messages of type t only drive the UI, e.g.
+// prompts for input, and are left out of the histories sent to the LLM. The
+// outcome of these messages is in other messages.
+func (t MessageType) DrivesUIOnly() bool {
+	switch t {
+	case MessageTypeUserInputRequest, MessageTypeUserInputResponse,
+		MessageTypeUserChoiceRequest, MessageTypeUserChoiceResponse,
+		MessageTypeContinueRequest,
+		MessageTypeSessionPickerRequest, MessageTypeSessionPickerResponse,
+		MessageTypeTextDelta, MessageTypeToolOutputDelta:
+		return true
+	}
+	return false
+}
+
+// payloadText returns a payload as text, as JSON unless it is a string.
+func payloadText(payload any) string {
+	switch p := payload.(type) {
+	case nil:
+		return ""
+	case string:
+		return p
+	case error:
+		return p.Error()
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Sprint(payload)
+	}
+	return string(b)
+}
+
+// decodePayload decodes payload into v, whether it is a value of the type of
+// v or its JSON form, as found in sessions loaded from disk.
+func decodePayload(payload any, v any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ValidateToolCallPairs checks that the tool calls of messages are paired with
+// their results the way LLM providers require: a run of tool calls is followed
+// by their results, one per call, before any other message sent to the LLM.
+// Messages that only drive the UI, e.g. the approval of the calls, may come in
+// between. Code that drops
+// or rewrites messages of a history uses it to check it kept the pairs whole.
+func ValidateToolCallPairs(messages []*Message) error {
+	pending := 0
+	for i, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		switch msg.Type {
+		case MessageTypeToolCallRequest:
+			pending++
+		case MessageTypeToolCallResponse:
+			if pending == 0 {
+				return fmt.Errorf("message %d (%s) is the result of no tool call", i, msg.ID)
+			}
+			pending--
+		default:
+			if pending > 0 && !msg.Type.DrivesUIOnly() {
+				return fmt.Errorf("message %d (%s) follows %d tool calls without a result", i, msg.ID, pending)
+			}
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("the history ends with %d tool calls without a result", pending)
+	}
+	return nil
+}
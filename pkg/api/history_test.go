@@ -0,0 +1,171 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestNormalizeHistory(t *testing.T) {
+	messages := []*Message{
+		{Source: MessageSourceUser, Type: MessageTypeText, Payload: "why is web crashing?"},
+		{Source: MessageSourceModel, Type: MessageTypeText, Payload: "Let me check."},
+		{Source: MessageSourceModel, Type: MessageTypeToolCallRequest, Payload: "kubectl get pods"},
+		{Source: MessageSourceAgent, Type: MessageTypeUserChoiceRequest, Payload: &UserChoiceRequest{Prompt: "Run it?"}},
+		{Source: MessageSourceUser, Type: MessageTypeUserChoiceResponse, Payload: &UserChoiceResponse{Choice: 1}},
+		{Source: MessageSourceAgent, Type: MessageTypeToolCallResponse, Payload: map[string]any{"stdout": "web 0/1 CrashLoopBackOff"}},
+		{Source: MessageSourceAgent, Type: MessageTypeError, Payload: "permission denied"},
+		{Source: MessageSourceAgent, Type: MessageTypeUserInputRequest, Payload: ">>>"},
+		{Source: MessageSourceUser, Type: MessageTypeImage, Payload: map[string]any{"name": "dashboard.png", "mimeType": "image/png"}},
+		{Source: MessageSourceUser, Type: MessageTypeAttachment, Payload: Attachment{Name: "web.yaml"}},
+		{Source: MessageSourceUser, Type: MessageTypeText, Payload: "and now?"},
+		{Source: MessageSourceModel, Type: MessageTypeText, Payload: "  "},
+		{Source: MessageSourceModel, Type: MessageTypeTextDelta, Payload: "It"},
+		{Source: MessageSourceModel, Type: MessageTypeText, Payload: "It is fixed."},
+	}
+	want := []HistoryTurn{
+		{Role: HistoryRoleUser, Text: "why is web crashing?"},
+		{Role: HistoryRoleModel, Text: "Let me check.\n\nTool call: kubectl get pods"},
+		{Role: HistoryRoleUser, Text: "Tool result: {\"stdout\":\"web 0/1 CrashLoopBackOff\"}\n\nError: permission denied\n\n[Attached image dashboard.png, no longer available]\n\n[Attached file web.yaml, no longer available]\n\nand now?"},
+		{Role: HistoryRoleModel, Text: "It is fixed."},
+	}
+	if got := NormalizeHistory(messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeHistory() = %#v, want %#v", got, want)
+	}
+}
+
+func TestNormalizeHistory_ModelFirst(t *testing.T) {
+	got := NormalizeHistory([]*Message{
+		{Source: MessageSourceModel, Type: MessageTypeText, Payload: "Hi, how can I help?"},
+		{Source: MessageSourceUser, Type: MessageTypeText, Payload: errors.New("oops")},
+	})
+	if len(got) != 3 || got[0].Role != HistoryRoleUser || got[1].Text != "Hi, how can I help?" || got[2].Text != "oops" {
+		t.Errorf("unexpected history %#v", got)
+	}
+}
+
+// randomMessages are arbitrary session messages, for property tests.
+type randomMessages []*Message
+
+func (randomMessages) Generate(r *rand.Rand, size int) reflect.Value {
+	sources := []MessageSource{MessageSourceUser, MessageSourceModel, MessageSourceAgent}
+	types := []MessageType{
+		MessageTypeText, MessageTypeError, MessageTypeToolCallRequest, MessageTypeToolCallResponse,
+		MessageTypeUserInputRequest, MessageTypeUserInputResponse, MessageTypeUserChoiceRequest,
+		MessageTypeUserChoiceResponse, MessageTypeSessionPickerRequest, MessageTypeSessionPickerResponse,
+		MessageTypeTextDelta, MessageTypeImage, MessageTypeAttachment, MessageType("unknown"),
+	}
+	texts := []string{"", " ", "get pods", "Error: boom", "multi\nline", "ünïcode ✓"}
+	payloads := []func() any{
+		func() any { return texts[r.Intn(len(texts))] },
+		func() any { return nil },
+		func() any { return map[string]any{"stdout": texts[r.Intn(len(texts))], "exit_code": r.Intn(3)} },
+		func() any { return Image{Name: "screenshot.png"} },
+		func() any { return &UserChoiceResponse{Choice: r.Intn(3)} },
+	}
+	messages := make(randomMessages, r.Intn(size+1))
+	for i := range messages {
+		messages[i] = &Message{
+			Source:  sources[r.Intn(len(sources))],
+			Type:    types[r.Intn(len(types))],
+			Payload: payloads[r.Intn(len(payloads))](),
+		}
+	}
+	return reflect.ValueOf(messages)
+}
+
+func TestNormalizeHistory_Properties(t *testing.T) {
+	valid := func(messages randomMessages) bool {
+		turns := NormalizeHistory(messages)
+		for i, turn := range turns {
+			if strings.TrimSpace(turn.Text) == "" {
+				return false
+			}
+			if i == 0 && turn.Role != HistoryRoleUser {
+				return false
+			}
+			if i > 0 && turn.Role == turns[i-1].Role {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(valid, nil); err != nil {
+		t.Errorf("turns don't alternate starting with the user: %v", err)
+	}
+
+	keepsText := func(messages randomMessages) bool {
+		var all strings.Builder
+		for _, turn := range NormalizeHistory(messages) {
+			all.WriteString(turn.Text + "\n")
+		}
+		for _, msg := range messages {
+			text, ok := msg.Payload.(string)
+			if ok && msg.Type == MessageTypeText && !strings.Contains(all.String(), strings.TrimSpace(text)) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(keepsText, nil); err != nil {
+		t.Errorf("text is lost: %v", err)
+	}
+
+	stable := func(messages randomMessages) bool {
+		turns := NormalizeHistory(messages)
+		var again []*Message
+		for _, turn := range turns {
+			source := MessageSourceUser
+			if turn.Role == HistoryRoleModel {
+				source = MessageSourceModel
+			}
+			again = append(again, &Message{Source: source, Type: MessageTypeText, Payload: turn.Text})
+		}
+		return reflect.DeepEqual(NormalizeHistory(again), turns)
+	}
+	if err := quick.Check(stable, nil); err != nil {
+		t.Errorf("normalizing a normalized history changes it: %v", err)
+	}
+}
+
+func TestValidateToolCallPairs(t *testing.T) {
+	call := &Message{Type: MessageTypeToolCallRequest, Payload: "kubectl get pods"}
+	result := &Message{Type: MessageTypeToolCallResponse, Payload: "web 1/1 Running"}
+	text := &Message{Type: MessageTypeText, Payload: "web is running"}
+	approval := &Message{Type: MessageTypeUserChoiceRequest, Payload: &UserChoiceRequest{Prompt: "Run it?"}}
+	for _, tc := range []struct {
+		name     string
+		messages []*Message
+		wantErr  bool
+	}{
+		{name: "paired", messages: []*Message{text, call, result, text}},
+		{name: "parallel", messages: []*Message{call, call, result, call, result, result, text}},
+		{name: "approval in between", messages: []*Message{call, approval, result}},
+		{name: "missing result", messages: []*Message{call, text, result}, wantErr: true},
+		{name: "result without call", messages: []*Message{text, result, call, result}, wantErr: true},
+		{name: "unanswered at the end", messages: []*Message{text, call}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidateToolCallPairs(tc.messages); (err != nil) != tc.wantErr {
+				t.Errorf("ValidateToolCallPairs() = %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
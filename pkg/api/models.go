@@ -31,6 +31,83 @@ type Session struct {
 	ChatMessageStore ChatMessageStore
 	// MCP status information
 	MCPStatus *MCPStatus
+	// Usage tracks token usage and estimated cost for the session.
+	Usage SessionUsage
+	// Tags organize sessions, e.g. by cluster, incident ticket or team.
+	Tags []string
+	// Feedback are the ratings of the answers of the session.
+	Feedback []Feedback
+	// Variables are set with `set name=value` and substituted for $name in
+	// the queries and tool arguments of the session.
+	Variables map[string]string
+	// Memory are the facts the user asked to remember with `remember`, sent
+	// in the system prompt of the session.
+	Memory []string
+}
+
+// FeedbackRating is a thumbs-up or thumbs-down on an answer.
+type FeedbackRating string
+
+const (
+	FeedbackPositive FeedbackRating = "up"
+	FeedbackNegative FeedbackRating = "down"
+)
+
+// Feedback is a rating of an answer, with what produced the answer, to
+// collect data for prompt and model tuning.
+type Feedback struct {
+	MessageID  string         `json:"messageID"`
+	Rating     FeedbackRating `json:"rating"`
+	ProviderID string         `json:"providerID,omitempty"`
+	ModelID    string         `json:"modelID,omitempty"`
+	// PromptHash identifies the system prompt the answer was generated with.
+	PromptHash string    `json:"promptHash,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// SessionUsage holds the accumulated token usage and estimated cost of a session.
+type SessionUsage struct {
+	Requests      int     `json:"requests"`
+	InputTokens   int64   `json:"inputTokens"`
+	OutputTokens  int64   `json:"outputTokens"`
+	TotalTokens   int64   `json:"totalTokens"`
+	EstimatedCost float64 `json:"estimatedCost"`
+	// CostKnown is false if pricing was unavailable for any request.
+	CostKnown bool `json:"costKnown"`
+	// LastRequestCost is the estimated cost of the most recent request.
+	LastRequestCost float64 `json:"lastRequestCost"`
+	// ContextTokens estimates the tokens of the next request: the system
+	// prompt, history and tool definitions sent with the last request, plus its
+	// response.
+	ContextTokens int64 `json:"contextTokens"`
+	// ContextWindow is the advertised context window of the model, zero if
+	// unknown.
+	ContextWindow int64 `json:"contextWindow"`
+}
+
+// ContextWarningThreshold is the fraction of the context window of the model
+// above which the user is warned that earlier tool outputs will soon be
+// dropped from the history.
+const ContextWarningThreshold = 0.8
+
+// ContextUsed returns the fraction of the context window of the model used by
+// the conversation, and false if the context window is unknown.
+func (u SessionUsage) ContextUsed() (float64, bool) {
+	if u.ContextWindow <= 0 {
+		return 0, false
+	}
+	return float64(u.ContextTokens) / float64(u.ContextWindow), true
+}
+
+// CostString returns a short human readable summary of the estimated cost.
+func (u SessionUsage) CostString() string {
+	if u.Requests == 0 {
+		return "$0.00"
+	}
+	if !u.CostKnown {
+		return "n/a"
+	}
+	return fmt.Sprintf("$%.4f", u.EstimatedCost)
 }
 
 type AgentState string
@@ -57,6 +134,25 @@ const (
 	MessageTypeUserChoiceResponse    MessageType = "user-choice-response"
 	MessageTypeSessionPickerRequest  MessageType = "session-picker-request"
 	MessageTypeSessionPickerResponse MessageType = "session-picker-response"
+	// MessageTypeTextDelta is a chunk of text streamed from the LLM. Deltas are
+	// only sent to the UI, not stored; the complete text follows as a
+	// MessageTypeText message with the same ID.
+	MessageTypeTextDelta MessageType = "text-delta"
+	// MessageTypeToolOutputDelta is output of a running tool call, sent as it
+	// is produced. The payload is a ToolOutputDelta and the ID is the one of
+	// the tool call request. Deltas are only sent to the UI, not stored; the
+	// complete output follows as a MessageTypeToolCallResponse message.
+	MessageTypeToolOutputDelta MessageType = "tool-output-delta"
+	// MessageTypeImage is an image the user attached to a query. The payload
+	// is an Image without its Data, to keep the session small.
+	MessageTypeImage MessageType = "image"
+	// MessageTypeAttachment is a file the user attached as context. The
+	// payload is an Attachment, without its content.
+	MessageTypeAttachment MessageType = "attachment"
+	// MessageTypeContinueRequest asks the user whether to continue a query
+	// that reached its iteration, time or token limit. The payload is a
+	// ContinueRequest, and the UI answers with a ContinueResponse.
+	MessageTypeContinueRequest MessageType = "continue-request"
 )
 
 type Message struct {
@@ -65,6 +161,12 @@ type Message struct {
 	Type      MessageType
 	Payload   any
 	Timestamp time.Time
+	// KubeContext is the kubeconfig context a tool call targeted, if it was switched.
+	KubeContext string `json:",omitempty"`
+	// ToolCallID is the ID of the tool call request message that a tool call
+	// response answers. Responses don't follow their requests in order when
+	// tool calls run in parallel.
+	ToolCallID string `json:",omitempty"`
 }
 
 type MessageSource string
@@ -75,6 +177,21 @@ const (
 	MessageSourceModel MessageSource = "model"
 )
 
+// ToolOutputDelta is output of a running tool call.
+type ToolOutputDelta struct {
+	// Stream is stdout or stderr.
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// CancelToolCall is sent on the Input channel of the agent to kill a running
+// tool call. The LLM is told the call was cancelled by the user, with its
+// output so far, and the agent goes on.
+type CancelToolCall struct {
+	// ID is the ID of the request message of the tool call.
+	ID string `json:"id"`
+}
+
 type UserChoiceRequest struct {
 	Prompt  string
 	Options []UserChoiceOption
@@ -87,10 +204,50 @@ type UserChoiceOption struct {
 
 type UserChoiceResponse struct {
 	Choice int `json:"choice"`
+	// Justification is an optional note from the user on why they made the
+	// choice, recorded with approvals of modifying commands.
+	Justification string `json:"justification,omitempty"`
+}
+
+// ContinueRequest is the payload of a MessageTypeContinueRequest message.
+type ContinueRequest struct {
+	// Reason is the limit that was reached.
+	Reason string `json:"reason"`
+	// Summary is the progress of the query so far, in markdown.
+	Summary string `json:"summary,omitempty"`
+}
+
+// ContinueResponse answers a ContinueRequest.
+type ContinueResponse struct {
+	Continue bool `json:"continue"`
 }
 
 type UserInputResponse struct {
 	Query string `json:"query"`
+	// Images are sent to the LLM along with the query, for providers that support them.
+	Images []Image `json:"images,omitempty"`
+	// Attachments are files added to the context of the conversation. They
+	// are sent to the LLM with the next query.
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Image is an image attached to a query, e.g. a screenshot of a dashboard.
+type Image struct {
+	Name     string `json:"name,omitempty"`
+	MIMEType string `json:"mimeType"`
+	Size     int    `json:"size,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+// Attachment is a file attached as context, e.g. a manifest or a log file.
+type Attachment struct {
+	Name string `json:"name,omitempty"`
+	// Source is where the file came from, e.g. its path on disk.
+	Source string `json:"source,omitempty"`
+	Size   int    `json:"size,omitempty"`
+	// Chunks is the number of parts the content was split into.
+	Chunks  int    `json:"chunks,omitempty"`
+	Content string `json:"content,omitempty"`
 }
 
 // SessionPickerRequest is sent to show an interactive session picker
@@ -107,6 +264,7 @@ type SessionInfo struct {
 	CreatedAt    time.Time `json:"createdAt"`
 	LastModified time.Time `json:"lastModified"`
 	MessageCount int       `json:"messageCount"`
+	Tags         []string  `json:"tags,omitempty"`
 }
 
 // SessionPickerResponse is sent when user selects a session
@@ -149,6 +307,38 @@ type ChatMessageStore interface {
 	ClearChatMessages() error
 }
 
+// ToolLoopCheckpoint is the state of the tool calls of an agent loop iteration
+// in progress. It lets a session interrupted by a crash be resumed without
+// losing track of the calls that ran.
+type ToolLoopCheckpoint struct {
+	// HistoryLength is the number of messages in the history before the calls started.
+	HistoryLength int                    `json:"historyLength"`
+	Iteration     int                    `json:"iteration"`
+	Calls         []CheckpointedToolCall `json:"calls"`
+}
+
+// CheckpointedToolCall is a tool call of a ToolLoopCheckpoint.
+type CheckpointedToolCall struct {
+	ID               string         `json:"id,omitempty"`
+	Name             string         `json:"name"`
+	Description      string         `json:"description"`
+	Arguments        map[string]any `json:"arguments,omitempty"`
+	ModifiesResource string         `json:"modifiesResource,omitempty"`
+	KubeContext      string         `json:"kubeContext,omitempty"`
+	// Done is set once the call finished, with its Result.
+	Done   bool `json:"done"`
+	Result any  `json:"result,omitempty"`
+}
+
+// ToolLoopCheckpointer is implemented by ChatMessageStores that can persist a
+// ToolLoopCheckpoint alongside the history.
+type ToolLoopCheckpointer interface {
+	SaveCheckpoint(checkpoint *ToolLoopCheckpoint) error
+	// LoadCheckpoint returns the saved checkpoint, or nil if there is none.
+	LoadCheckpoint() (*ToolLoopCheckpoint, error)
+	ClearCheckpoint() error
+}
+
 func (s *Session) AllMessages() []*Message {
 	if s.ChatMessageStore == nil {
 		return nil
@@ -157,6 +347,7 @@ func (s *Session) AllMessages() []*Message {
 }
 
 func (s *Session) String() string {
-	return fmt.Sprintf("Session ID: %s\nProvider: %s\nModel: %s\nCreated At: %s\nLast Modified: %s\nAgent State: %s",
-		s.ID, s.ProviderID, s.ModelID, s.CreatedAt.Format(time.RFC3339), s.LastModified.Format(time.RFC3339), s.AgentState)
+	return fmt.Sprintf("Session ID: %s\nProvider: %s\nModel: %s\nCreated At: %s\nLast Modified: %s\nAgent State: %s\nTokens: %d input, %d output, %d total (%d requests)\nEstimated Cost: %s",
+		s.ID, s.ProviderID, s.ModelID, s.CreatedAt.Format(time.RFC3339), s.LastModified.Format(time.RFC3339), s.AgentState,
+		s.Usage.InputTokens, s.Usage.OutputTokens, s.Usage.TotalTokens, s.Usage.Requests, s.Usage.CostString())
 }
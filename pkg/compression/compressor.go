@@ -0,0 +1,300 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// Kinds of summarizers.
+const (
+	// KindLLM summarizes with an LLM, falling back to KindExtractive.
+	KindLLM = "llm"
+	// KindExtractive keeps the first, last and important lines.
+	KindExtractive = "extractive"
+	// KindNone keeps messages as they are.
+	KindNone = "none"
+)
+
+// DefaultType configures the summarizer of the message types without one of
+// their own.
+const DefaultType = "default"
+
+// Defaults of Compressor.
+const (
+	defaultMaxMessageBytes = 4096
+	defaultKeepRecent      = 10
+)
+
+// summarizableTypes are the types of the messages a Compressor summarizes.
+// The payloads of the others, e.g. images, must keep their structure.
+var summarizableTypes = []api.MessageType{
+	api.MessageTypeText,
+	api.MessageTypeError,
+	api.MessageTypeToolCallRequest,
+	api.MessageTypeToolCallResponse,
+}
+
+// instructions describe the messages of each type to LLM summarizers.
+var instructions = map[api.MessageType]string{
+	api.MessageTypeText:             defaultInstructions,
+	api.MessageTypeError:            "Summarize the following error for an assistant troubleshooting a Kubernetes cluster. Keep what failed and why.",
+	api.MessageTypeToolCallRequest:  "Summarize the following tool call of an assistant troubleshooting a Kubernetes cluster. Keep the command and the resources it targets.",
+	api.MessageTypeToolCallResponse: "Summarize the following output of a command run by an assistant troubleshooting a Kubernetes cluster. Keep the names of resources, their states, the errors and the numbers that matter; drop repeated and routine lines.",
+}
+
+// Compressor shrinks chat histories by summarizing their large messages,
+// except the most recent ones, and dropping their oldest messages if they are
+// still too large. It never separates tool calls from their results: a run of
+// tool calls and their results is kept, summarized or dropped as a whole, so
+// the history passes api.ValidateToolCallPairs if the original did.
+type Compressor struct {
+	// Summarizers are the summarizers of each message type. Messages of
+	// other types are summarized by Default, or kept if it is nil.
+	Summarizers map[api.MessageType]Summarizer
+	Default     Summarizer
+	// MaxMessageBytes is the size above which messages are summarized, and
+	// the size of their summaries. Defaults to 4096.
+	MaxMessageBytes int
+	// KeepRecent is the number of most recent messages kept as they are.
+	// Defaults to 10.
+	KeepRecent int
+	// MaxHistoryBytes is the size above which the oldest messages are
+	// dropped, once the large ones are summarized. Zero means no limit.
+	MaxHistoryBytes int
+}
+
+// Config configures a Compressor, e.g. from the command line.
+type Config struct {
+	// Summarizers maps message types, or DefaultType, to the kind of their
+	// summarizer, e.g. {"tool-call-response": "llm", "default": "extractive"}.
+	Summarizers map[string]string `json:"summarizers,omitempty"`
+	// Model is the model of LLM summaries. Without one, LLM summarizers
+	// fall back to extractive summaries.
+	Model           string `json:"model,omitempty"`
+	MaxMessageBytes int    `json:"maxMessageBytes,omitempty"`
+	KeepRecent      int    `json:"keepRecent,omitempty"`
+	MaxHistoryBytes int    `json:"maxHistoryBytes,omitempty"`
+}
+
+// Validate checks the message types and summarizer kinds of the config.
+func (c *Config) Validate() error {
+	for messageType, kind := range c.Summarizers {
+		if messageType != DefaultType && !slices.Contains(summarizableTypes, api.MessageType(messageType)) {
+			return fmt.Errorf("cannot summarize messages of type %q, expected one of %v or %q", messageType, summarizableTypes, DefaultType)
+		}
+		switch kind {
+		case KindLLM, KindExtractive, KindNone:
+		default:
+			return fmt.Errorf("unknown summarizer %q for %s, expected %s, %s or %s", kind, messageType, KindLLM, KindExtractive, KindNone)
+		}
+	}
+	return nil
+}
+
+// NewCompressor creates the Compressor of config, summarizing with client.
+func NewCompressor(client gollm.Client, config Config) (*Compressor, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	newSummarizer := func(kind string, messageType api.MessageType) Summarizer {
+		switch kind {
+		case KindLLM:
+			if client == nil || config.Model == "" {
+				return &ExtractiveSummarizer{}
+			}
+			llm := &LLMSummarizer{Client: client, Model: config.Model, Instructions: instructions[messageType]}
+			return WithFallback(llm, &ExtractiveSummarizer{})
+		case KindExtractive:
+			return &ExtractiveSummarizer{}
+		}
+		return nil
+	}
+	c := &Compressor{
+		Summarizers:     make(map[api.MessageType]Summarizer),
+		MaxMessageBytes: config.MaxMessageBytes,
+		KeepRecent:      config.KeepRecent,
+		MaxHistoryBytes: config.MaxHistoryBytes,
+	}
+	for messageType, kind := range config.Summarizers {
+		if messageType == DefaultType {
+			c.Default = newSummarizer(kind, "")
+			continue
+		}
+		c.Summarizers[api.MessageType(messageType)] = newSummarizer(kind, api.MessageType(messageType))
+	}
+	return c, nil
+}
+
+// summarizerFor returns the summarizer of the messages of messageType, nil if
+// they are kept.
+func (c *Compressor) summarizerFor(messageType api.MessageType) Summarizer {
+	if !slices.Contains(summarizableTypes, messageType) {
+		return nil
+	}
+	if s, ok := c.Summarizers[messageType]; ok {
+		return s
+	}
+	return c.Default
+}
+
+// Compress returns messages with the large ones summarized and, if the
+// history is still larger than MaxHistoryBytes, the oldest dropped, except
+// the most recent. Summarized messages are copies, messages itself is left as
+// is.
+func (c *Compressor) Compress(ctx context.Context, messages []*api.Message) ([]*api.Message, error) {
+	maxBytes := c.MaxMessageBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxMessageBytes
+	}
+	keepRecent := c.KeepRecent
+	if keepRecent <= 0 {
+		keepRecent = defaultKeepRecent
+	}
+
+	// Only the units entirely before the most recent messages are
+	// compressed, so more than keepRecent messages may be kept
+	units := splitUnits(messages)
+	old := len(units)
+	for old > 0 && units[old-1].start >= len(messages)-keepRecent {
+		old--
+	}
+	if old > 0 && units[old-1].end > len(messages)-keepRecent {
+		old--
+	}
+
+	compressed := make([]*api.Message, len(messages))
+	copy(compressed, messages)
+	for _, u := range units[:old] {
+		for i := u.start; i < u.end; i++ {
+			msg := messages[i]
+			if msg == nil {
+				continue
+			}
+			summarizer := c.summarizerFor(msg.Type)
+			if summarizer == nil {
+				continue
+			}
+			content := payloadText(msg.Payload)
+			if len(content) <= maxBytes {
+				continue
+			}
+			summary, err := summarizer.Summarize(ctx, content, maxBytes)
+			if err != nil {
+				return nil, fmt.Errorf("summarizing message %s: %w", msg.ID, err)
+			}
+			summarized := *msg
+			summarized.Payload = summary
+			compressed[i] = &summarized
+		}
+	}
+
+	if c.MaxHistoryBytes <= 0 {
+		return compressed, nil
+	}
+	size := 0
+	for _, msg := range compressed {
+		size += messageSize(msg)
+	}
+	dropped := 0
+	for _, u := range units[:old] {
+		if size <= c.MaxHistoryBytes {
+			break
+		}
+		for _, msg := range compressed[u.start:u.end] {
+			size -= messageSize(msg)
+		}
+		dropped = u.end
+	}
+	if dropped == 0 {
+		return compressed, nil
+	}
+	note := &api.Message{
+		ID:        "compression-note",
+		Source:    api.MessageSourceAgent,
+		Type:      api.MessageTypeText,
+		Payload:   fmt.Sprintf("[%d earlier messages of the conversation were dropped to keep the history small.]", dropped),
+		Timestamp: compressed[dropped-1].Timestamp,
+	}
+	return append([]*api.Message{note}, compressed[dropped:]...), nil
+}
+
+// unit is a range of messages compressed as a whole: a run of tool calls and
+// their results, with the messages in between, or any other message.
+type unit struct {
+	start, end int
+}
+
+// splitUnits splits messages into units.
+func splitUnits(messages []*api.Message) []unit {
+	isToolCall := func(msg *api.Message) bool {
+		return msg != nil && (msg.Type == api.MessageTypeToolCallRequest || msg.Type == api.MessageTypeToolCallResponse)
+	}
+	var units []unit
+	for i := 0; i < len(messages); {
+		end := i + 1
+		if isToolCall(messages[i]) {
+			pending := 0
+			for end = i; end < len(messages); end++ {
+				msg := messages[end]
+				if !isToolCall(msg) && (pending == 0 || msg != nil && !msg.Type.DrivesUIOnly()) {
+					break
+				}
+				if msg == nil {
+					continue
+				}
+				if msg.Type == api.MessageTypeToolCallRequest {
+					pending++
+				} else if msg.Type == api.MessageTypeToolCallResponse && pending > 0 {
+					pending--
+				}
+			}
+		}
+		units = append(units, unit{start: i, end: end})
+		i = end
+	}
+	return units
+}
+
+// messageSize estimates the size of a message in the history.
+func messageSize(msg *api.Message) int {
+	if msg == nil {
+		return 0
+	}
+	return len(payloadText(msg.Payload))
+}
+
+// payloadText returns a payload as text, as JSON unless it is a string.
+func payloadText(payload any) string {
+	switch p := payload.(type) {
+	case nil:
+		return ""
+	case string:
+		return p
+	case error:
+		return p.Error()
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Sprint(payload)
+	}
+	return string(b)
+}
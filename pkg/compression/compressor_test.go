@@ -0,0 +1,169 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestCompressor(t *testing.T) {
+	client := &fakeClient{summary: "100 pods, pod-50 in CrashLoopBackOff"}
+	c, err := NewCompressor(client, Config{
+		Summarizers: map[string]string{
+			string(api.MessageTypeToolCallResponse): KindLLM,
+			DefaultType:                             KindExtractive,
+		},
+		Model:           "cheap",
+		MaxMessageBytes: 500,
+		KeepRecent:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := podLines(100)
+	messages := []*api.Message{
+		{ID: "1", Type: api.MessageTypeText, Source: api.MessageSourceUser, Payload: "why is pod-50 failing?"},
+		{ID: "2", Type: api.MessageTypeToolCallRequest, Source: api.MessageSourceModel, Payload: "kubectl get pods"},
+		{ID: "3", Type: api.MessageTypeToolCallResponse, Source: api.MessageSourceAgent, Payload: map[string]any{"stdout": large}},
+		{ID: "4", Type: api.MessageTypeText, Source: api.MessageSourceModel, Payload: large},
+		{ID: "5", Type: api.MessageTypeText, Source: api.MessageSourceModel, Payload: large},
+	}
+	compressed, err := c.Compress(context.Background(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := compressed[2].Payload; got != "100 pods, pod-50 in CrashLoopBackOff" {
+		t.Errorf("tool output wasn't summarized by the LLM: %v", got)
+	}
+	if got := compressed[3].Payload.(string); len(got) > 500 || !strings.Contains(got, "lines omitted") {
+		t.Errorf("text wasn't summarized by the default summarizer: %q", got)
+	}
+	if compressed[4].Payload != large {
+		t.Errorf("the most recent message was summarized")
+	}
+	if compressed[0] != messages[0] || compressed[1] != messages[1] {
+		t.Errorf("small messages were copied")
+	}
+	if _, ok := messages[2].Payload.(map[string]any); !ok {
+		t.Errorf("the original messages were modified")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		summarizers map[string]string
+		wantErr     bool
+	}{
+		{summarizers: map[string]string{"tool-call-response": "llm", "default": "none"}},
+		{summarizers: map[string]string{"text": "abstractive"}, wantErr: true},
+		{summarizers: map[string]string{"image": "extractive"}, wantErr: true},
+	} {
+		config := Config{Summarizers: tc.summarizers}
+		if err := config.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("Validate(%v) = %v, want error: %v", tc.summarizers, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCompressor_KeepsToolCallPairs(t *testing.T) {
+	large := podLines(100)
+	var messages []*api.Message
+	add := func(messageType api.MessageType, payload any) {
+		messages = append(messages, &api.Message{ID: fmt.Sprint(len(messages)), Type: messageType, Payload: payload})
+	}
+	for range 3 {
+		add(api.MessageTypeText, "why is pod-50 failing?")
+		add(api.MessageTypeToolCallRequest, "kubectl get pods")
+		add(api.MessageTypeToolCallRequest, "kubectl get events")
+		add(api.MessageTypeUserChoiceRequest, &api.UserChoiceRequest{Prompt: "Run them?"})
+		add(api.MessageTypeUserChoiceResponse, &api.UserChoiceResponse{Choice: 1})
+		add(api.MessageTypeToolCallResponse, large)
+		add(api.MessageTypeToolCallRequest, "kubectl describe pod pod-50")
+		add(api.MessageTypeToolCallResponse, large)
+		add(api.MessageTypeToolCallResponse, large)
+		add(api.MessageTypeText, large)
+	}
+	if err := api.ValidateToolCallPairs(messages); err != nil {
+		t.Fatalf("invalid test history: %v", err)
+	}
+
+	for keepRecent := 1; keepRecent <= len(messages); keepRecent++ {
+		for _, maxHistoryBytes := range []int{0, 1, 5000, 20000} {
+			c := &Compressor{
+				Default:         &ExtractiveSummarizer{},
+				MaxMessageBytes: 500,
+				KeepRecent:      keepRecent,
+				MaxHistoryBytes: maxHistoryBytes,
+			}
+			compressed, err := c.Compress(context.Background(), messages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := api.ValidateToolCallPairs(compressed); err != nil {
+				t.Errorf("keepRecent %d, maxHistoryBytes %d: %v", keepRecent, maxHistoryBytes, err)
+			}
+			// The tool results of a run are summarized together or not at all
+			for _, u := range splitUnits(compressed) {
+				summarized := 0
+				results := 0
+				for _, msg := range compressed[u.start:u.end] {
+					if msg.Type == api.MessageTypeToolCallResponse {
+						results++
+						if msg.Payload != large {
+							summarized++
+						}
+					}
+				}
+				if summarized != 0 && summarized != results {
+					t.Errorf("keepRecent %d: %d of the %d results of a run of tool calls were summarized", keepRecent, summarized, results)
+				}
+			}
+			if len(compressed) < min(keepRecent, len(messages)) {
+				t.Errorf("keepRecent %d, maxHistoryBytes %d: only %d messages were kept", keepRecent, maxHistoryBytes, len(compressed))
+			}
+		}
+	}
+}
+
+func TestCompressor_DropsOldestMessages(t *testing.T) {
+	messages := []*api.Message{
+		{ID: "1", Type: api.MessageTypeText, Payload: "list the pods"},
+		{ID: "2", Type: api.MessageTypeToolCallRequest, Payload: "kubectl get pods"},
+		{ID: "3", Type: api.MessageTypeToolCallResponse, Payload: strings.Repeat("x", 400)},
+		{ID: "4", Type: api.MessageTypeText, Payload: "and the nodes?"},
+		{ID: "5", Type: api.MessageTypeToolCallRequest, Payload: "kubectl get nodes"},
+		{ID: "6", Type: api.MessageTypeToolCallResponse, Payload: "node-1 Ready"},
+	}
+	c := &Compressor{KeepRecent: 2, MaxHistoryBytes: 100}
+	compressed, err := c.Compress(context.Background(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, msg := range compressed {
+		ids = append(ids, msg.ID)
+	}
+	if got, want := strings.Join(ids, ","), "compression-note,4,5,6"; got != want {
+		t.Errorf("kept messages %s, want %s", got, want)
+	}
+	if note := compressed[0].Payload.(string); !strings.Contains(note, "3 earlier messages") {
+		t.Errorf("unexpected note %q", note)
+	}
+}
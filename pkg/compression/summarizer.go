@@ -0,0 +1,176 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compression shrinks the chat histories replayed to LLMs, by
+// summarizing their large messages.
+package compression
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"k8s.io/klog/v2"
+)
+
+// Summarizer shortens the content of a message.
+type Summarizer interface {
+	// Summarize returns a summary of content of at most maxBytes bytes, or
+	// of any size if maxBytes is zero.
+	Summarize(ctx context.Context, content string, maxBytes int) (string, error)
+}
+
+// defaultInstructions tell the LLM what to keep of the content it summarizes.
+const defaultInstructions = "Summarize the following content for an assistant troubleshooting a Kubernetes cluster. Keep the names of resources, the commands, the errors and the numbers that matter; drop repeated and routine lines."
+
+// LLMSummarizer summarizes content with an LLM, preferably a cheap one.
+type LLMSummarizer struct {
+	Client gollm.Client
+	Model  string
+	// Instructions describe the content and what to keep of it. Defaults to
+	// generic instructions.
+	Instructions string
+}
+
+var _ Summarizer = &LLMSummarizer{}
+
+// Summarize asks the LLM for a summary of content. Summaries beyond maxBytes
+// are truncated in the middle.
+func (s *LLMSummarizer) Summarize(ctx context.Context, content string, maxBytes int) (string, error) {
+	instructions := s.Instructions
+	if instructions == "" {
+		instructions = defaultInstructions
+	}
+	if maxBytes > 0 {
+		instructions += fmt.Sprintf(" Answer with the summary only, in at most %d characters.", maxBytes)
+	} else {
+		instructions += " Answer with the summary only."
+	}
+	prompt := instructions + "\n\n<content>\n" + content + "\n</content>"
+	response, err := s.Client.GenerateCompletion(ctx, &gollm.CompletionRequest{Model: s.Model, Prompt: prompt})
+	if err != nil {
+		return "", fmt.Errorf("summarizing with %s: %w", s.Model, err)
+	}
+	summary := strings.TrimSpace(response.Response())
+	if summary == "" {
+		return "", fmt.Errorf("summarizing with %s: empty summary", s.Model)
+	}
+	return truncateMiddle(summary, maxBytes), nil
+}
+
+// Defaults of ExtractiveSummarizer.
+const (
+	defaultHeadLines = 10
+	defaultTailLines = 10
+)
+
+// DefaultImportantLines matches the lines ExtractiveSummarizer keeps by
+// default: errors, failures and the like.
+var DefaultImportantLines = regexp.MustCompile(`(?i)\b(error|errors|fail|failed|failure|fatal|panic|exception|denied|forbidden|refused|unauthorized|timeout|timed out|crashloopbackoff|imagepullbackoff|errimagepull|oomkilled|evicted|warning)\b`)
+
+// ExtractiveSummarizer summarizes content without an LLM, keeping its first
+// and last lines and the important lines in between. It never fails, so it
+// works as a fallback when no LLM is available.
+type ExtractiveSummarizer struct {
+	// HeadLines and TailLines are the numbers of first and last lines kept,
+	// 10 by default.
+	HeadLines int
+	TailLines int
+	// Important matches the other lines kept, DefaultImportantLines by
+	// default.
+	Important *regexp.Regexp
+}
+
+var _ Summarizer = &ExtractiveSummarizer{}
+
+// Summarize extracts the first, last and important lines of content, marking
+// the lines left out.
+func (s *ExtractiveSummarizer) Summarize(ctx context.Context, content string, maxBytes int) (string, error) {
+	if maxBytes > 0 && len(content) <= maxBytes {
+		return content, nil
+	}
+	head, tail := s.HeadLines, s.TailLines
+	if head <= 0 {
+		head = defaultHeadLines
+	}
+	if tail <= 0 {
+		tail = defaultTailLines
+	}
+	important := s.Important
+	if important == nil {
+		important = DefaultImportantLines
+	}
+
+	lines := strings.Split(content, "\n")
+	var b strings.Builder
+	omitted := 0
+	flushOmitted := func() {
+		if omitted > 0 {
+			fmt.Fprintf(&b, "[... %d lines omitted ...]\n", omitted)
+			omitted = 0
+		}
+	}
+	for i, line := range lines {
+		if i < head || i >= len(lines)-tail || important.MatchString(line) {
+			flushOmitted()
+			b.WriteString(line)
+			b.WriteString("\n")
+			continue
+		}
+		omitted++
+	}
+	flushOmitted()
+	return truncateMiddle(strings.TrimSuffix(b.String(), "\n"), maxBytes), nil
+}
+
+// WithFallback returns a summarizer that uses primary, and fallback when
+// primary fails, e.g. because the LLM is unavailable.
+func WithFallback(primary, fallback Summarizer) Summarizer {
+	return &fallbackSummarizer{primary: primary, fallback: fallback}
+}
+
+type fallbackSummarizer struct {
+	primary  Summarizer
+	fallback Summarizer
+}
+
+func (s *fallbackSummarizer) Summarize(ctx context.Context, content string, maxBytes int) (string, error) {
+	summary, err := s.primary.Summarize(ctx, content, maxBytes)
+	if err == nil {
+		return summary, nil
+	}
+	if ctx.Err() != nil {
+		return "", err
+	}
+	klog.Warningf("summarizer failed, using the fallback: %v", err)
+	return s.fallback.Summarize(ctx, content, maxBytes)
+}
+
+// truncateMiddle cuts the middle of s down to maxBytes bytes, if it is
+// longer and maxBytes isn't zero.
+func truncateMiddle(s string, maxBytes int) string {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return s
+	}
+	marker := fmt.Sprintf("\n[... %d bytes omitted ...]\n", len(s)-maxBytes)
+	keep := maxBytes - len(marker)
+	if keep <= 0 {
+		return strings.ToValidUTF8(s[:maxBytes], "")
+	}
+	head := keep / 2
+	tail := keep - head
+	return strings.ToValidUTF8(s[:head], "") + marker + strings.ToValidUTF8(s[len(s)-tail:], "")
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+// fakeClient answers completions with summary, or fails with err.
+type fakeClient struct {
+	gollm.Client
+	summary string
+	err     error
+	prompts []string
+}
+
+func (c *fakeClient) GenerateCompletion(ctx context.Context, req *gollm.CompletionRequest) (gollm.CompletionResponse, error) {
+	c.prompts = append(c.prompts, req.Prompt)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return completion(c.summary), nil
+}
+
+type completion string
+
+func (c completion) Response() string   { return string(c) }
+func (c completion) UsageMetadata() any { return nil }
+
+// podLines returns n lines of pods, the 50th of them failing.
+func podLines(n int) string {
+	var lines []string
+	for i := range n {
+		status := "Running"
+		if i == 50 {
+			status = "CrashLoopBackOff"
+		}
+		lines = append(lines, fmt.Sprintf("pod-%d 1/1 %s", i, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestExtractiveSummarizer(t *testing.T) {
+	s := &ExtractiveSummarizer{HeadLines: 2, TailLines: 2}
+	summary, err := s.Summarize(context.Background(), podLines(100), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"pod-0 1/1 Running",
+		"pod-1 1/1 Running",
+		"[... 48 lines omitted ...]",
+		"pod-50 1/1 CrashLoopBackOff",
+		"[... 47 lines omitted ...]",
+		"pod-98 1/1 Running",
+		"pod-99 1/1 Running",
+	}, "\n")
+	if summary != want {
+		t.Errorf("summary = %q, want %q", summary, want)
+	}
+
+	summary, _ = s.Summarize(context.Background(), "pod-0 1/1 Running", 100)
+	if summary != "pod-0 1/1 Running" {
+		t.Errorf("small content was summarized: %q", summary)
+	}
+
+	summary, _ = (&ExtractiveSummarizer{}).Summarize(context.Background(), podLines(100), 200)
+	if len(summary) > 200 || !strings.HasPrefix(summary, "pod-0 ") || !strings.Contains(summary, "bytes omitted") {
+		t.Errorf("summary beyond 200 bytes wasn't truncated: %q", summary)
+	}
+}
+
+func TestLLMSummarizer(t *testing.T) {
+	client := &fakeClient{summary: " 100 pods, pod-50 in CrashLoopBackOff \n"}
+	s := &LLMSummarizer{Client: client, Model: "cheap", Instructions: "Summarize the pods."}
+	summary, err := s.Summarize(context.Background(), podLines(100), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "100 pods, pod-50 in CrashLoopBackOff" {
+		t.Errorf("summary = %q", summary)
+	}
+	if prompt := client.prompts[0]; !strings.HasPrefix(prompt, "Summarize the pods.") || !strings.Contains(prompt, "at most 1000 characters") || !strings.Contains(prompt, "pod-99") {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	llm := &LLMSummarizer{Client: &fakeClient{err: errors.New("quota exceeded")}, Model: "cheap"}
+	s := WithFallback(llm, &ExtractiveSummarizer{HeadLines: 1, TailLines: 1})
+	summary, err := s.Summarize(context.Background(), podLines(100), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(summary, "pod-50 1/1 CrashLoopBackOff") || strings.Contains(summary, "pod-51") {
+		t.Errorf("expected an extractive summary, got %q", summary)
+	}
+}
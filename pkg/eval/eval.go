@@ -0,0 +1,211 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eval runs the tasks of k8s-ai-bench against LLM providers and
+// models with this kubectl-ai binary, and reports how the models compare.
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+// Model is an LLM provider and model to evaluate.
+type Model struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+func (m Model) String() string {
+	return m.Provider + "/" + m.Model
+}
+
+// ParseModel parses a model given as provider/model, e.g.
+// gemini/gemini-2.5-pro.
+func ParseModel(s string) (Model, error) {
+	provider, model, ok := strings.Cut(s, "/")
+	if !ok || provider == "" || model == "" {
+		return Model{}, fmt.Errorf("invalid model %q, expected provider/model, e.g. gemini/gemini-2.5-pro", s)
+	}
+	return Model{Provider: provider, Model: model}, nil
+}
+
+// Config configures an evaluation run.
+type Config struct {
+	// BenchBinary is the k8s-ai-bench binary running the tasks, built by
+	// dev/ci/periodics/build-k8s-ai-bench.sh.
+	BenchBinary string
+	// BenchArgs are extra arguments of k8s-ai-bench run.
+	BenchArgs []string
+	// TasksDir is the directory of the tasks of k8s-ai-bench, and TaskPattern
+	// selects the ones to run.
+	TasksDir    string
+	TaskPattern string
+
+	Models []Model
+	// AgentBinary is the kubectl-ai binary evaluated.
+	AgentBinary string
+
+	// Kubeconfig is the cluster the tasks run against. The agent runs with
+	// --skip-permissions, so it must be a dedicated kubeconfig, not the
+	// default one. It is ignored if KindCluster is set.
+	Kubeconfig string
+	// KindCluster, if set, is the name of a kind cluster the tasks run
+	// against. The cluster is created if it doesn't exist, and deleted at the
+	// end of the run unless KeepCluster is set.
+	KindCluster string
+	KeepCluster bool
+
+	// OutputDir receives the results of k8s-ai-bench and the report.
+	OutputDir string
+}
+
+// ReportFile is the name of the report comparing the models in
+// Config.OutputDir.
+const ReportFile = "report.md"
+
+// Run runs the tasks with each model, and writes the report comparing them to
+// the output directory. It returns the path of the report.
+func Run(ctx context.Context, cfg Config) (string, error) {
+	log := klog.FromContext(ctx)
+
+	if len(cfg.Models) == 0 {
+		return "", fmt.Errorf("no model to evaluate")
+	}
+	if _, err := os.Stat(cfg.BenchBinary); err != nil {
+		return "", fmt.Errorf("k8s-ai-bench not found, build it with dev/ci/periodics/build-k8s-ai-bench.sh: %w", err)
+	}
+	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+		return "", fmt.Errorf("creating output directory: %w", err)
+	}
+
+	kubeconfig := cfg.Kubeconfig
+	if cfg.KindCluster != "" {
+		kubeconfig = filepath.Join(cfg.OutputDir, "kubeconfig")
+		created, err := ensureKindCluster(ctx, cfg.KindCluster, kubeconfig)
+		if err != nil {
+			return "", err
+		}
+		if created && !cfg.KeepCluster {
+			defer func() {
+				if err := exec.Command("kind", "delete", "cluster", "--name", cfg.KindCluster).Run(); err != nil {
+					log.Error(err, "error deleting kind cluster", "name", cfg.KindCluster)
+				}
+			}()
+		}
+	} else if err := CheckKubeconfig(kubeconfig); err != nil {
+		return "", err
+	}
+
+	// k8s-ai-bench runs the models of a provider at once
+	var providers []string
+	models := map[string][]string{}
+	for _, model := range cfg.Models {
+		if _, ok := models[model.Provider]; !ok {
+			providers = append(providers, model.Provider)
+		}
+		models[model.Provider] = append(models[model.Provider], model.Model)
+	}
+	for _, provider := range providers {
+		args := []string{
+			"run",
+			"--agent-bin", cfg.AgentBinary,
+			"--kubeconfig", kubeconfig,
+			"--output-dir", cfg.OutputDir,
+			"--llm-provider", provider,
+			"--models", strings.Join(models[provider], ","),
+		}
+		if cfg.TasksDir != "" {
+			args = append(args, "--tasks-dir", cfg.TasksDir)
+		}
+		if cfg.TaskPattern != "" {
+			args = append(args, "--task-pattern", cfg.TaskPattern)
+		}
+		args = append(args, cfg.BenchArgs...)
+		log.Info("Running k8s-ai-bench", "provider", provider, "models", models[provider])
+		if err := runBench(ctx, cfg.BenchBinary, args...); err != nil {
+			return "", err
+		}
+	}
+
+	report := filepath.Join(cfg.OutputDir, ReportFile)
+	if err := runBench(ctx, cfg.BenchBinary, "analyze", "--input-dir", cfg.OutputDir, "--output-format", "markdown", "--results-filepath", report); err != nil {
+		return "", err
+	}
+	return report, nil
+}
+
+// runBench runs k8s-ai-bench with args, showing its output.
+func runBench(ctx context.Context, binary string, args ...string) error {
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("k8s-ai-bench %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// CheckKubeconfig returns an error if kubeconfig isn't a dedicated
+// kubeconfig: the agent runs the tasks with --skip-permissions, so they must
+// not run against the cluster kubectl uses by default.
+func CheckKubeconfig(kubeconfig string) error {
+	if kubeconfig == "" {
+		return fmt.Errorf("the tasks modify the cluster without asking, set --kubeconfig to the kubeconfig of a test cluster, or --kind-cluster")
+	}
+	path, err := filepath.Abs(kubeconfig)
+	if err != nil {
+		return err
+	}
+	defaults := filepath.SplitList(os.Getenv(clientcmd.RecommendedConfigPathEnvVar))
+	defaults = append(defaults, clientcmd.RecommendedHomeFile)
+	if slices.ContainsFunc(defaults, func(p string) bool {
+		abs, err := filepath.Abs(p)
+		return err == nil && abs == path
+	}) {
+		return fmt.Errorf("%s is the default kubeconfig, the tasks modify the cluster without asking: use the kubeconfig of a test cluster, or --kind-cluster", kubeconfig)
+	}
+	return nil
+}
+
+// ensureKindCluster creates the kind cluster name if it doesn't exist, and
+// writes its kubeconfig to kubeconfig. It returns whether it created it.
+func ensureKindCluster(ctx context.Context, name, kubeconfig string) (bool, error) {
+	out, err := exec.CommandContext(ctx, "kind", "get", "clusters").Output()
+	if err != nil {
+		return false, fmt.Errorf("listing kind clusters: %w", err)
+	}
+	if slices.Contains(strings.Fields(string(out)), name) {
+		cmd := exec.CommandContext(ctx, "kind", "export", "kubeconfig", "--name", name, "--kubeconfig", kubeconfig)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return false, fmt.Errorf("exporting kubeconfig of kind cluster %s: %w: %s", name, err, out)
+		}
+		return false, nil
+	}
+
+	klog.FromContext(ctx).Info("Creating kind cluster", "name", name)
+	cmd := exec.CommandContext(ctx, "kind", "create", "cluster", "--name", name, "--kubeconfig", kubeconfig, "--wait", "5m")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return false, fmt.Errorf("creating kind cluster %s: %w: %s", name, err, out)
+	}
+	return true, nil
+}
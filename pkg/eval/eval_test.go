@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBench records its arguments, one invocation per line, and writes the
+// report it is asked for.
+const fakeBench = `#!/usr/bin/env bash
+echo "$@" >> "$(dirname "$0")/calls"
+while [[ $# -gt 1 ]]; do
+  if [[ "$1" == "--results-filepath" ]]; then echo "report" > "$2"; fi
+  shift
+done
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	bench := filepath.Join(dir, "k8s-ai-bench")
+	if err := os.WriteFile(bench, []byte(fakeBench), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	outputDir := filepath.Join(dir, "output")
+
+	report, err := Run(context.Background(), Config{
+		BenchBinary: bench,
+		BenchArgs:   []string{"--quiet"},
+		TaskPattern: "^fix-",
+		Models:      []Model{{"gemini", "flash"}, {"openai", "gpt-4.1"}, {"gemini", "pro"}},
+		AgentBinary: "/bin/kubectl-ai",
+		Kubeconfig:  kubeconfig,
+		OutputDir:   outputDir,
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if b, err := os.ReadFile(report); err != nil || string(b) != "report\n" {
+		t.Errorf("unexpected report %q, %v", b, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"run --agent-bin /bin/kubectl-ai --kubeconfig " + kubeconfig + " --output-dir " + outputDir + " --llm-provider gemini --models flash,pro --task-pattern ^fix- --quiet",
+		"run --agent-bin /bin/kubectl-ai --kubeconfig " + kubeconfig + " --output-dir " + outputDir + " --llm-provider openai --models gpt-4.1 --task-pattern ^fix- --quiet",
+		"analyze --input-dir " + outputDir + " --output-format markdown --results-filepath " + report,
+	}
+	if got := strings.Split(strings.TrimSpace(string(b)), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected invocations of k8s-ai-bench:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "default")+string(os.PathListSeparator)+filepath.Join(dir, "other"))
+
+	if err := CheckKubeconfig(""); err == nil {
+		t.Error("expected an error without kubeconfig")
+	}
+	for _, kubeconfig := range []string{filepath.Join(dir, "default"), filepath.Join(dir, "other")} {
+		if err := CheckKubeconfig(kubeconfig); err == nil || !strings.Contains(err.Error(), "default kubeconfig") {
+			t.Errorf("expected %s to be refused, got %v", kubeconfig, err)
+		}
+	}
+	if err := CheckKubeconfig(filepath.Join(dir, "test-cluster")); err != nil {
+		t.Errorf("expected a dedicated kubeconfig to be accepted, got %v", err)
+	}
+}
@@ -20,7 +20,11 @@ import (
 
 type contextKey string
 
-const RecorderKey contextKey = "journal-recorder"
+const (
+	RecorderKey contextKey = "journal-recorder"
+	RunbookKey  contextKey = "journal-runbook"
+	SessionKey  contextKey = "journal-session"
+)
 
 // RecorderFromContext extracts the recorder from the given context
 func RecorderFromContext(ctx context.Context) Recorder {
@@ -35,3 +39,27 @@ func RecorderFromContext(ctx context.Context) Recorder {
 func ContextWithRecorder(ctx context.Context, recorder Recorder) context.Context {
 	return context.WithValue(ctx, RecorderKey, recorder)
 }
+
+// RunbookFromContext returns the runbook step the actions of the context are
+// taken for, nil if none.
+func RunbookFromContext(ctx context.Context) *RunbookRef {
+	ref, _ := ctx.Value(RunbookKey).(*RunbookRef)
+	return ref
+}
+
+// ContextWithRunbook attributes the actions taken in the context to a runbook step
+func ContextWithRunbook(ctx context.Context, ref *RunbookRef) context.Context {
+	return context.WithValue(ctx, RunbookKey, ref)
+}
+
+// SessionFromContext returns the ID of the session the events of the context
+// are recorded for, "" if none.
+func SessionFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(SessionKey).(string)
+	return id
+}
+
+// ContextWithSession attributes the events recorded in the context to a session
+func ContextWithSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, SessionKey, sessionID)
+}
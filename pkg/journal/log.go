@@ -25,6 +25,9 @@ type LogRecorder struct {
 
 func (r *LogRecorder) Write(ctx context.Context, event *Event) error {
 	log := klog.FromContext(ctx)
+	if err := event.prepare(ctx); err != nil {
+		return err
+	}
 
 	log.V(2).Info("Tracing event", "event", event)
 	return nil
@@ -0,0 +1,280 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// OTLPOptions configures an OTLPRecorder.
+type OTLPOptions struct {
+	// Endpoint is the URL of the OTLP/HTTP collector, e.g.
+	// http://localhost:4318. Logs are sent to its /v1/logs path unless the
+	// URL has a path.
+	Endpoint string
+	// Headers are added to the export requests, e.g. for authentication.
+	Headers map[string]string
+	// ServiceName is the service.name resource attribute, it defaults to
+	// kubectl-ai.
+	ServiceName string
+	// BatchSize is the number of events sent per request, it defaults to 100.
+	BatchSize int
+	// FlushInterval is the maximum time an event is buffered, it defaults to
+	// 5s.
+	FlushInterval time.Duration
+	// HTTPClient defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// otlpQueueSize bounds the events waiting to be exported; events are dropped
+// when the collector can't keep up rather than blocking the agent.
+const otlpQueueSize = 1024
+
+// OTLPRecorder exports events as OTLP log records over HTTP, using the JSON
+// encoding, so that they can be shipped to central logging. Events are
+// batched and exported in the background.
+type OTLPRecorder struct {
+	url     string
+	opts    OTLPOptions
+	events  chan *Event
+	done    chan struct{}
+	mu      sync.Mutex
+	closed  bool
+	dropped int
+}
+
+// NewOTLPRecorder creates an OTLPRecorder and starts exporting.
+func NewOTLPRecorder(opts OTLPOptions) (*OTLPRecorder, error) {
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expected a URL e.g. http://localhost:4318", opts.Endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/logs"
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = "kubectl-ai"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 5 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	r := &OTLPRecorder{
+		url:    u.String(),
+		opts:   opts,
+		events: make(chan *Event, otlpQueueSize),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+func (r *OTLPRecorder) Write(ctx context.Context, event *Event) error {
+	if err := event.prepare(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return fmt.Errorf("recorder is closed")
+	}
+	select {
+	case r.events <- event:
+	default:
+		r.dropped++
+		if r.dropped == 1 {
+			klog.Warningf("OTLP exporter can't keep up, dropping journal events")
+		}
+	}
+	return nil
+}
+
+// Close exports the buffered events and stops exporting.
+func (r *OTLPRecorder) Close() error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+
+	<-r.done
+	return nil
+}
+
+func (r *OTLPRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*Event
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.export(batch); err != nil {
+			klog.Warningf("error exporting %d journal events: %v", len(batch), err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= r.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// export sends events as an OTLP ExportLogsServiceRequest.
+func (r *OTLPRecorder) export(events []*Event) error {
+	records := make([]otlpLogRecord, 0, len(events))
+	for _, event := range events {
+		records = append(records, newOTLPLogRecord(event))
+	}
+	req := otlpExportRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: otlpResource{Attributes: []otlpKeyValue{stringAttribute("service.name", r.opts.ServiceName)}},
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: "github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"},
+				LogRecords: records,
+			}},
+		}},
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshalling logs: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range r.opts.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := r.opts.HTTPClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+// The types below are the subset of the OTLP logs protocol we send, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	// TimeUnixNano is a fixed64, encoded as a string in JSON.
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpSeverityInfo is the INFO severity number of the OTLP logs data model.
+const otlpSeverityInfo = 9
+
+func stringAttribute(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+// newOTLPLogRecord converts event to a log record named after its action,
+// with its payload as a JSON body.
+func newOTLPLogRecord(event *Event) otlpLogRecord {
+	body := ""
+	if event.Payload != nil {
+		b, err := json.Marshal(event.Payload)
+		if err != nil {
+			body = fmt.Sprintf("%v", event.Payload)
+		} else {
+			body = string(b)
+		}
+	}
+	return otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(event.Timestamp.UnixNano(), 10),
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+		Body:           otlpAnyValue{StringValue: body},
+		Attributes: []otlpKeyValue{
+			stringAttribute("event.name", event.Action),
+			stringAttribute("event.schema_version", strconv.Itoa(event.Version)),
+		},
+	}
+}
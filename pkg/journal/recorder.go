@@ -17,6 +17,8 @@ package journal
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -55,25 +57,37 @@ func (r *FileRecorder) Close() error {
 }
 
 func (r *FileRecorder) Write(ctx context.Context, event *Event) error {
-	if event.Timestamp.IsZero() {
-		event.Timestamp = time.Now()
+	_, err := r.write(ctx, event)
+	return err
+}
+
+// write writes event and returns the number of bytes written.
+func (r *FileRecorder) write(ctx context.Context, event *Event) (int, error) {
+	if err := event.prepare(ctx); err != nil {
+		return 0, err
 	}
 
 	yamlBytes, err := yaml.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("marshalling event: %w", err)
+		return 0, fmt.Errorf("marshalling event: %w", err)
 	}
 	var b bytes.Buffer
 	b.Write(yamlBytes)
 	b.Write([]byte("\n\n---\n\n"))
-	_, err = r.f.Write(b.Bytes())
-	return err
+	return r.f.Write(b.Bytes())
 }
 
+// Event is an entry of the journal. Its payload has the type the schema
+// defines for its action, e.g. ToolExec for ActionToolRequest.
 type Event struct {
 	Timestamp time.Time `json:"timestamp"`
-	Action    string    `json:"action"`
-	Payload   any       `json:"payload,omitempty"`
+	// Version is the SchemaVersion of the event, set by the recorder.
+	Version int    `json:"version,omitempty"`
+	Action  string `json:"action"`
+	// SessionID is the session the event was recorded for, set by the
+	// recorder from the context, see ContextWithSession.
+	SessionID string `json:"sessionID,omitempty"`
+	Payload   any    `json:"payload,omitempty"`
 }
 
 const (
@@ -85,14 +99,22 @@ const (
 // ActionUIRender is for an event that indicates we wrote output to the UI
 const ActionUIRender = "ui.render"
 
-// GetString is a helper to get a string value from the Payload
+// ActionToolApproval records the user approving or declining commands that
+// modify resources, along with their justification.
+const ActionToolApproval = "tool-approval"
+
+// GetString is a helper to get a string value from the Payload, by its JSON
+// field name for typed payloads.
 func (e *Event) GetString(key string) (string, bool) {
 	if e.Payload == nil {
 		return "", false
 	}
 	m, ok := e.Payload.(map[string]any)
 	if !ok {
-		return "", false
+		b, err := json.Marshal(e.Payload)
+		if err != nil || json.Unmarshal(b, &m) != nil {
+			return "", false
+		}
 	}
 	v, ok := m[key]
 	if !ok {
@@ -104,3 +126,28 @@ func (e *Event) GetString(key string) (string, bool) {
 	}
 	return s, true
 }
+
+// MultiRecorder writes events to several recorders, e.g. a file and a
+// central logging backend.
+type MultiRecorder []Recorder
+
+func (m MultiRecorder) Write(ctx context.Context, event *Event) error {
+	var errs []error
+	for _, r := range m {
+		if err := r.Write(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes all the recorders.
+func (m MultiRecorder) Close() error {
+	var errs []error
+	for _, r := range m {
+		if err := r.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
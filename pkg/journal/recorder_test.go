@@ -0,0 +1,219 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func toolRequest(id string) *Event {
+	return &Event{Action: ActionToolRequest, Payload: &ToolExec{CallID: id, Name: "kubectl"}}
+}
+
+func TestRotatingFileRecorder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "trace.yaml")
+	r, err := NewRotatingFileRecorder(path, RotationOptions{MaxSize: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// With a 1 byte limit, every event starts a new file
+	for _, id := range []string{"first", "second", "third", "fourth"} {
+		if err := r.Write(ctx, toolRequest(id)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string]string{path: "fourth", path + ".1": "third", path + ".2": "second"} {
+		events, err := ParseEventsFromFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Payload.(*ToolExec).CallID != want {
+			t.Errorf("expected %s to hold the %s event, got %+v", file, want, events)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, got %v", err)
+	}
+}
+
+func TestRotatingFileRecorderMaxAge(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "trace.yaml")
+	r, err := NewRotatingFileRecorder(path, RotationOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Write(ctx, toolRequest("old"))
+	r.Write(ctx, toolRequest("recent"))
+	r.created = time.Now().Add(-2 * time.Hour)
+	r.Write(ctx, toolRequest("new"))
+
+	events, err := ParseEventsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Payload.(*ToolExec).CallID != "new" {
+		t.Errorf("expected the file to be rotated after an hour, got %+v", events)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("expected no backup without MaxBackups, got %v", err)
+	}
+}
+
+func TestOTLPRecorder(t *testing.T) {
+	var mu sync.Mutex
+	var requests []otlpExportRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/logs" || req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected request %s %v", req.URL, req.Header)
+		}
+		var body otlpExportRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		requests = append(requests, body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	r, err := NewOTLPRecorder(OTLPOptions{
+		Endpoint:  server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	timestamp := time.Unix(1, 5)
+	for _, action := range []string{ActionToolRequest, ActionToolResponse, ActionToolRequest} {
+		if err := r.Write(ctx, &Event{Timestamp: timestamp, Action: action, Payload: &ToolExec{CallID: "1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close flushes the last, incomplete batch
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Write(ctx, toolRequest("late")); err == nil {
+		t.Errorf("expected an error writing to a closed recorder")
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(requests))
+	}
+	logs := requests[0].ResourceLogs[0]
+	if got := logs.Resource.Attributes[0]; got.Key != "service.name" || got.Value.StringValue != "kubectl-ai" {
+		t.Errorf("unexpected resource attribute %+v", got)
+	}
+	records := logs.ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records in the first batch, got %d", len(records))
+	}
+	record := records[1]
+	if record.TimeUnixNano != "1000000005" || record.Body.StringValue != `{"id":"1"}` || record.Attributes[0].Value.StringValue != "tool-response" {
+		t.Errorf("unexpected log record %+v", record)
+	}
+}
+
+func TestNewOTLPRecorderInvalidEndpoint(t *testing.T) {
+	if _, err := NewOTLPRecorder(OTLPOptions{Endpoint: "localhost:4318"}); err == nil {
+		t.Errorf("expected an error for an endpoint without scheme")
+	}
+}
+
+func TestEventSchema(t *testing.T) {
+	for _, event := range []*Event{
+		{Action: "unknown"},
+		{Action: ActionToolRequest, Payload: map[string]any{"id": "1"}},
+		{Action: ActionStateChange, Payload: &UserAction{}},
+	} {
+		if err := event.Validate(); err == nil {
+			t.Errorf("expected %s with a %T payload to be invalid", event.Action, event.Payload)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "trace.yaml")
+	r, err := NewFileRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, event := range []*Event{
+		{Action: ActionToolResponse, Payload: ToolExec{CallID: "1", Error: "forbidden"}},
+		{Action: ActionStateChange, Payload: &StateChange{From: "running", To: "done"}},
+		{Action: ActionUIRender},
+	} {
+		if event.Action == ActionUIRender {
+			ctx = ContextWithSession(ctx, "20250807-510872")
+		}
+		if err := r.Write(ctx, event); err != nil {
+			t.Fatalf("Write(%s) error = %v", event.Action, err)
+		}
+	}
+	if err := r.Write(ctx, &Event{Action: ActionToolRequest, Payload: "kubectl get pods"}); err == nil {
+		t.Errorf("expected the recorder to reject an invalid payload")
+	}
+	r.Close()
+
+	events, err := ParseEventsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if exec, ok := events[0].Payload.(*ToolExec); !ok || events[0].Version != SchemaVersion || exec.Error != "forbidden" {
+		t.Errorf("expected a typed tool response, got %+v", events[0])
+	}
+	if message, _ := events[0].GetString("error"); message != "forbidden" {
+		t.Errorf("GetString(error) = %q", message)
+	}
+	if change, ok := events[1].Payload.(*StateChange); !ok || change.To != "done" {
+		t.Errorf("expected a typed state change, got %+v", events[1])
+	}
+	if events[1].SessionID != "" || events[2].SessionID != "20250807-510872" {
+		t.Errorf("expected the session of the context to be recorded, got %q and %q", events[1].SessionID, events[2].SessionID)
+	}
+
+	// Events predating the schema keep free-form payloads
+	legacy, err := ParseEvents(strings.NewReader("action: tool-response\npayload:\n  error: forbidden\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := legacy[0].Payload.(map[string]any); !ok {
+		t.Errorf("expected a map payload for an unversioned event, got %T", legacy[0].Payload)
+	}
+	if _, err := ParseEvents(strings.NewReader("version: 2\naction: tool-response\n")); err == nil {
+		t.Errorf("expected an error for an unsupported schema version")
+	}
+}
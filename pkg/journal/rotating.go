@@ -0,0 +1,141 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RotationOptions configures when a RotatingFileRecorder starts a new file.
+type RotationOptions struct {
+	// MaxSize is the size in bytes after which the file is rotated, zero
+	// means no size limit.
+	MaxSize int64
+	// MaxAge is the age after which the file is rotated, zero means no age
+	// limit.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, named path.1 (the
+	// newest) to path.N. Older files are deleted.
+	MaxBackups int
+}
+
+// RotatingFileRecorder is a FileRecorder starting a new file when the current
+// one grows too big or too old, so that long-running servers don't grow
+// their journal unbounded.
+type RotatingFileRecorder struct {
+	path string
+	opts RotationOptions
+
+	mu      sync.Mutex
+	file    *FileRecorder
+	size    int64
+	created time.Time
+}
+
+// NewRotatingFileRecorder creates a RotatingFileRecorder writing to path.
+func NewRotatingFileRecorder(path string, opts RotationOptions) (*RotatingFileRecorder, error) {
+	r := &RotatingFileRecorder{path: path, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFileRecorder) open() error {
+	file, err := NewFileRecorder(r.path)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	r.size = 0
+	r.created = time.Now()
+	return nil
+}
+
+func (r *RotatingFileRecorder) Write(ctx context.Context, event *Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("recorder is closed")
+	}
+	if r.shouldRotate() {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.write(ctx, event)
+	r.size += int64(n)
+	return err
+}
+
+func (r *RotatingFileRecorder) shouldRotate() bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.opts.MaxSize > 0 && r.size >= r.opts.MaxSize {
+		return true
+	}
+	return r.opts.MaxAge > 0 && time.Since(r.created) >= r.opts.MaxAge
+}
+
+// rotate closes the current file, shifts the backups and opens a new file.
+func (r *RotatingFileRecorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("closing journal: %w", err)
+	}
+	r.file = nil
+
+	if r.opts.MaxBackups <= 0 {
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing journal: %w", err)
+		}
+		return r.open()
+	}
+	if err := os.Remove(r.backupPath(r.opts.MaxBackups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing oldest journal: %w", err)
+	}
+	for i := r.opts.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backupPath(i), r.backupPath(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("rotating journal: %w", err)
+		}
+	}
+	if err := os.Rename(r.path, r.backupPath(1)); err != nil {
+		return fmt.Errorf("rotating journal: %w", err)
+	}
+	return r.open()
+}
+
+func (r *RotatingFileRecorder) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+// Close closes the current file.
+func (r *RotatingFileRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,202 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// SchemaVersion is the version of the event schema written by the recorders.
+// Events without a version predate the schema and have free-form payloads.
+const SchemaVersion = 1
+
+const (
+	ActionToolRequest  = "tool-request"
+	ActionToolResponse = "tool-response"
+	// ActionStateChange records the agent moving between states, e.g. from
+	// running to waiting for input.
+	ActionStateChange = "state-change"
+	// ActionFeedback records the user rating an answer.
+	ActionFeedback = "feedback"
+	// ActionRunbookStep records the agent starting a step of a runbook.
+	ActionRunbookStep = "runbook-step"
+)
+
+// LLMRequest is the payload of ActionHTTPRequest events, an HTTP request
+// sent to the LLM provider with its credentials redacted.
+type LLMRequest struct {
+	Request string `json:"request"`
+}
+
+// LLMResponse is the payload of ActionHTTPResponse events, and of
+// ActionHTTPError events when the request failed before getting a response.
+type LLMResponse struct {
+	Status  string      `json:"status,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+
+	Error  string `json:"error,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ToolExec is the payload of ActionToolRequest and ActionToolResponse
+// events; both events of a tool call share its CallID.
+type ToolExec struct {
+	CallID string `json:"id,omitempty"`
+
+	// Name and Arguments are set on requests.
+	Name      string         `json:"name,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	// Runbook is the runbook step the call was made for, if any.
+	Runbook *RunbookRef `json:"runbook,omitempty"`
+
+	// Response and Error are set on responses.
+	Response any    `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// UserAction is the payload of ActionToolApproval events.
+type UserAction struct {
+	SessionID     string   `json:"sessionID,omitempty"`
+	Approved      bool     `json:"approved"`
+	Commands      []string `json:"commands,omitempty"`
+	Justification string   `json:"justification,omitempty"`
+	// Runbook is the runbook step the approval was asked for, if any.
+	Runbook *RunbookRef `json:"runbook,omitempty"`
+}
+
+// StateChange is the payload of ActionStateChange events.
+type StateChange struct {
+	SessionID string `json:"sessionID,omitempty"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// Feedback is the payload of ActionFeedback events.
+type Feedback struct {
+	SessionID  string `json:"sessionID,omitempty"`
+	MessageID  string `json:"messageID"`
+	Rating     string `json:"rating"`
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+	PromptHash string `json:"promptHash,omitempty"`
+}
+
+// RunbookStep is the payload of ActionRunbookStep events.
+type RunbookStep struct {
+	SessionID string `json:"sessionID,omitempty"`
+	Runbook   string `json:"runbook"`
+	Step      string `json:"step"`
+	// Index is the position of the step in the runbook, starting at 1.
+	Index  int    `json:"index"`
+	Prompt string `json:"prompt"`
+}
+
+// RunbookRef identifies the runbook step that led to an action.
+type RunbookRef struct {
+	Runbook string `json:"runbook"`
+	Step    string `json:"step"`
+}
+
+// payloadTypes are the payload types of the actions, nil for actions without
+// payload.
+var payloadTypes = map[string]reflect.Type{
+	ActionHTTPRequest:  reflect.TypeFor[LLMRequest](),
+	ActionHTTPResponse: reflect.TypeFor[LLMResponse](),
+	ActionHTTPError:    reflect.TypeFor[LLMResponse](),
+	ActionToolRequest:  reflect.TypeFor[ToolExec](),
+	ActionToolResponse: reflect.TypeFor[ToolExec](),
+	ActionToolApproval: reflect.TypeFor[UserAction](),
+	ActionStateChange:  reflect.TypeFor[StateChange](),
+	ActionFeedback:     reflect.TypeFor[Feedback](),
+	ActionRunbookStep:  reflect.TypeFor[RunbookStep](),
+	ActionUIRender:     nil,
+}
+
+// Validate checks that the action of the event is known and its payload has
+// the type of the action, as a value or a pointer.
+func (e *Event) Validate() error {
+	want, ok := payloadTypes[e.Action]
+	if !ok {
+		return fmt.Errorf("unknown journal action %q", e.Action)
+	}
+	if e.Payload == nil {
+		return nil
+	}
+	got := reflect.TypeOf(e.Payload)
+	if got.Kind() == reflect.Pointer {
+		got = got.Elem()
+	}
+	if got != want {
+		return fmt.Errorf("journal action %q expects a %v payload, got %T", e.Action, want, e.Payload)
+	}
+	return nil
+}
+
+// prepare validates event and stamps it with the time, schema version and
+// session of ctx before a recorder writes it.
+func (e *Event) prepare(ctx context.Context) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	if e.SessionID == "" {
+		e.SessionID = SessionFromContext(ctx)
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	if e.Version == 0 {
+		e.Version = SchemaVersion
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the payload of versioned events into the type of
+// their action, e.g. *ToolExec. The payloads of unversioned events and of
+// actions without a type are decoded as maps.
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Timestamp time.Time       `json:"timestamp"`
+		Version   int             `json:"version"`
+		Action    string          `json:"action"`
+		SessionID string          `json:"sessionID"`
+		Payload   json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if raw.Version > SchemaVersion {
+		return fmt.Errorf("journal event has schema version %d, only versions up to %d are supported", raw.Version, SchemaVersion)
+	}
+	*e = Event{Timestamp: raw.Timestamp, Version: raw.Version, Action: raw.Action, SessionID: raw.SessionID}
+	if len(raw.Payload) == 0 || string(raw.Payload) == "null" {
+		return nil
+	}
+
+	if t := payloadTypes[raw.Action]; raw.Version > 0 && t != nil {
+		payload := reflect.New(t)
+		if err := json.Unmarshal(raw.Payload, payload.Interface()); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", raw.Action, err)
+		}
+		e.Payload = payload.Interface()
+		return nil
+	}
+	return json.Unmarshal(raw.Payload, &e.Payload)
+}
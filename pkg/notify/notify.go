@@ -0,0 +1,202 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify tells users about events happening while they are not
+// watching the agent, e.g. the answer of a watched query changing.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events of notifications.
+const (
+	// EventWatchChanged is sent when the answer of a watched query changes.
+	EventWatchChanged = "watch-changed"
+	// EventRunCompleted is sent when the agent is done with a query.
+	EventRunCompleted = "run-completed"
+	// EventApprovalRequired is sent when tool calls wait for the user's
+	// approval.
+	EventApprovalRequired = "approval-required"
+)
+
+// Formats of webhook payloads.
+const (
+	// FormatJSON posts the Notification as is.
+	FormatJSON = "json"
+	// FormatSlack posts a Slack incoming webhook message.
+	FormatSlack = "slack"
+	// FormatTeams posts a Microsoft Teams incoming webhook message card.
+	FormatTeams = "teams"
+)
+
+// Notification is an event worth telling the user about.
+type Notification struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	// SessionID is the session the event happened in, if any.
+	SessionID string `json:"sessionID,omitempty"`
+	// URL links to the session in the HTML UI, if any.
+	URL string `json:"url,omitempty"`
+	// Details are event specific, e.g. the watched query.
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Notifier delivers notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Multi delivers notifications to all its notifiers.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, n *Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Webhook posts notifications as JSON to a URL.
+type Webhook struct {
+	URL string
+	// Format is the payload format, FormatJSON if empty.
+	Format string
+	// Client defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// ParseWebhook parses a webhook given as a URL, optionally prefixed with its
+// format, e.g. slack:https://hooks.slack.com/services/...
+func ParseWebhook(spec string) (*Webhook, error) {
+	w := &Webhook{URL: spec, Format: FormatJSON}
+	if format, rest, ok := strings.Cut(spec, ":"); ok && (format == FormatJSON || format == FormatSlack || format == FormatTeams) {
+		w.URL, w.Format = rest, format
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook %q, expected an http(s) URL optionally prefixed with json:, slack: or teams:", spec)
+	}
+	return w, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, n *Notification) error {
+	var payload any
+	switch w.Format {
+	case "", FormatJSON:
+		payload = n
+	case FormatSlack:
+		payload = slackMessage(n)
+	case FormatTeams:
+		payload = teamsMessage(n)
+	default:
+		return fmt.Errorf("unknown webhook format %q", w.Format)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshalling notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+// slackMessage formats n as a Slack message, see
+// https://api.slack.com/messaging/webhooks
+func slackMessage(n *Notification) map[string]any {
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+	text := "*" + escape(n.Title) + "*"
+	if n.Message != "" {
+		text += "\n" + escape(n.Message)
+	}
+	if n.URL != "" {
+		text += "\n<" + n.URL + "|Open the session>"
+	}
+	return map[string]any{"text": text}
+}
+
+// teamsMessage formats n as a Microsoft Teams message card, see
+// https://learn.microsoft.com/en-us/outlook/actionable-messages/message-card-reference
+func teamsMessage(n *Notification) map[string]any {
+	card := map[string]any{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  n.Title,
+		"title":    n.Title,
+		"text":     n.Message,
+	}
+	if n.URL != "" {
+		card["potentialAction"] = []map[string]any{{
+			"@type":   "OpenUri",
+			"name":    "Open the session",
+			"targets": []map[string]string{{"os": "default", "uri": n.URL}},
+		}}
+	}
+	return card
+}
+
+// Desktop shows notifications on the desktop, with notify-send on Linux and
+// osascript on macOS.
+type Desktop struct{}
+
+func (Desktop) Notify(ctx context.Context, n *Notification) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.CommandContext(ctx, "notify-send", n.Title, n.Message)
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(n.Message), strconv.Quote(n.Title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("showing desktop notification: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
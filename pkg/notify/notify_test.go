@@ -0,0 +1,106 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhook(t *testing.T) {
+	var got Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %v", req.Method, req.Header)
+		}
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	n := &Notification{Event: EventWatchChanged, Title: "Pods changed", Details: map[string]string{"query": "are any pods crashlooping?"}}
+	if err := (&Webhook{URL: server.URL}).Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got.Event != EventWatchChanged || got.Details["query"] != "are any pods crashlooping?" {
+		t.Errorf("unexpected notification %+v", got)
+	}
+}
+
+func TestWebhook_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "no such channel", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	notifiers := Multi{&Webhook{URL: server.URL}, &Webhook{URL: server.URL}}
+	err := notifiers.Notify(context.Background(), &Notification{Event: EventWatchChanged})
+	if err == nil || !strings.Contains(err.Error(), "no such channel") {
+		t.Errorf("expected the webhook error, got %v", err)
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	for spec, want := range map[string]Webhook{
+		"https://example.com/hook?token=1":             {URL: "https://example.com/hook?token=1", Format: FormatJSON},
+		"slack:https://hooks.slack.com/services/T/B/X": {URL: "https://hooks.slack.com/services/T/B/X", Format: FormatSlack},
+		"teams:https://example.webhook.office.com/x":   {URL: "https://example.webhook.office.com/x", Format: FormatTeams},
+	} {
+		got, err := ParseWebhook(spec)
+		if err != nil {
+			t.Fatalf("ParseWebhook(%q) error = %v", spec, err)
+		}
+		if got.URL != want.URL || got.Format != want.Format {
+			t.Errorf("ParseWebhook(%q) = %+v, want %+v", spec, got, want)
+		}
+	}
+	for _, spec := range []string{"discord:https://example.com", "hooks.slack.com/services", "ftp://example.com"} {
+		if _, err := ParseWebhook(spec); err == nil {
+			t.Errorf("expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestWebhook_Formats(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = nil
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	n := &Notification{Event: EventRunCompleted, Title: "Done", Message: "2 pods <pending>", URL: "http://localhost:8888/?session=1"}
+	if err := (&Webhook{URL: server.URL, Format: FormatSlack}).Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if want := "*Done*\n2 pods &lt;pending&gt;\n<http://localhost:8888/?session=1|Open the session>"; got["text"] != want {
+		t.Errorf("unexpected Slack message %q", got["text"])
+	}
+
+	if err := (&Webhook{URL: server.URL, Format: FormatTeams}).Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if got["@type"] != "MessageCard" || got["title"] != "Done" || !strings.Contains(fmt.Sprint(got["potentialAction"]), n.URL) {
+		t.Errorf("unexpected Teams message %v", got)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retrieval indexes directories of runbooks and docs into a local
+// vector store, and retrieves the excerpts relevant to the queries of the
+// user, so that the agent can ground its answers in them.
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"k8s.io/klog/v2"
+)
+
+// Chunk is an excerpt of a doc and its embedding.
+type Chunk struct {
+	// Source is the path of the doc, relative to the indexed directory.
+	Source string `json:"source"`
+	// Heading is the heading of the section of the excerpt, if any.
+	Heading   string    `json:"heading,omitempty"`
+	Text      string    `json:"text"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// Index is a local vector store of the chunks of the docs of a directory.
+type Index struct {
+	// Embedder identifies the provider and model of the embeddings. The
+	// chunks of an index with another embedder are embedded again.
+	Embedder string `json:"embedder"`
+	// Files are the hashes of the indexed docs, by source, so that only the
+	// docs that changed are embedded again.
+	Files  map[string]string `json:"files"`
+	Chunks []Chunk           `json:"chunks"`
+}
+
+// LoadIndex reads the index saved at path, or returns an empty index if there
+// is none.
+func LoadIndex(path string) (*Index, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Index{Files: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading docs index: %w", err)
+	}
+	var index Index
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("parsing docs index %s: %w", path, err)
+	}
+	if index.Files == nil {
+		index.Files = map[string]string{}
+	}
+	return &index, nil
+}
+
+// Save writes the index to path, creating its directory if needed.
+func (x *Index) Save(path string) error {
+	b, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating docs index directory: %w", err)
+	}
+	// Write a temporary file first, so that an interrupted save doesn't
+	// corrupt the index
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("writing docs index: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Result is a chunk matching a query.
+type Result struct {
+	Chunk *Chunk
+	// Score is the cosine similarity of the chunk and the query.
+	Score float64
+}
+
+// Search returns the k chunks most similar to the embedding of a query, with
+// a score of at least minScore, the most similar first.
+func (x *Index) Search(query []float32, k int, minScore float64) []Result {
+	var results []Result
+	for i := range x.Chunks {
+		score := gollm.CosineSimilarity(query, x.Chunks[i].Embedding)
+		if score < minScore || score == 0 {
+			continue
+		}
+		results = append(results, Result{Chunk: &x.Chunks[i], Score: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// docExtensions are the extensions of the files indexed.
+var docExtensions = []string{".md", ".markdown", ".txt"}
+
+// Defaults of Indexer.
+const (
+	defaultMaxChunkBytes = 1500
+	embeddingBatchSize   = 32
+)
+
+// Indexer embeds the docs of a directory into an Index.
+type Indexer struct {
+	Client gollm.Client
+	// Model is the embedding model, the default model of the provider if
+	// empty.
+	Model string
+	// Embedder identifies the provider and model of the embeddings, see
+	// Index.Embedder.
+	Embedder string
+	// MaxChunkBytes is the maximum size of the chunks. Defaults to 1500.
+	MaxChunkBytes int
+}
+
+// Index indexes the Markdown and text files of dir, reusing the chunks of
+// previous for the files that didn't change.
+func (ix *Indexer) Index(ctx context.Context, dir string, previous *Index) (*Index, error) {
+	maxBytes := ix.MaxChunkBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxChunkBytes
+	}
+	reusable := map[string][]Chunk{}
+	if previous != nil && previous.Embedder == ix.Embedder {
+		for _, chunk := range previous.Chunks {
+			reusable[chunk.Source] = append(reusable[chunk.Source], chunk)
+		}
+	}
+
+	index := &Index{Embedder: ix.Embedder, Files: map[string]string{}}
+	var pending []Chunk
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !slices.Contains(docExtensions, strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		source, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		source = filepath.ToSlash(source)
+		sum := sha256.Sum256(b)
+		hash := hex.EncodeToString(sum[:])
+		index.Files[source] = hash
+		if chunks, ok := reusable[source]; ok && previous.Files[source] == hash {
+			index.Chunks = append(index.Chunks, chunks...)
+			return nil
+		}
+		for _, section := range splitMarkdown(string(b), maxBytes) {
+			pending = append(pending, Chunk{Source: source, Heading: section.heading, Text: section.text})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading docs: %w", err)
+	}
+
+	if len(pending) > 0 {
+		klog.Infof("Embedding %d chunks of docs of %s", len(pending), dir)
+	}
+	for start := 0; start < len(pending); start += embeddingBatchSize {
+		batch := pending[start:min(start+embeddingBatchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, chunk := range batch {
+			texts[i] = embeddingText(&chunk)
+		}
+		response, err := gollm.Embed(ctx, ix.Client, &gollm.EmbeddingRequest{
+			Model: ix.Model,
+			Texts: texts,
+			Task:  gollm.EmbeddingTaskDocument,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("embedding docs: %w", err)
+		}
+		for i := range batch {
+			batch[i].Embedding = response.Embeddings[i]
+		}
+	}
+	index.Chunks = append(index.Chunks, pending...)
+	return index, nil
+}
+
+// embeddingText is the text embedded for chunk: its text, with the doc and
+// heading it comes from for context.
+func embeddingText(chunk *Chunk) string {
+	if chunk.Heading == "" {
+		return chunk.Source + "\n\n" + chunk.Text
+	}
+	return chunk.Source + " > " + chunk.Heading + "\n\n" + chunk.Text
+}
+
+// section is an excerpt of a Markdown doc.
+type section struct {
+	heading string
+	text    string
+}
+
+// splitMarkdown splits a doc into excerpts of at most maxBytes bytes, at its
+// headings and then at its paragraphs. Code blocks are not split at their
+// blank lines or comments.
+func splitMarkdown(doc string, maxBytes int) []section {
+	var sections []section
+	var heading string
+	var paragraphs []string
+	var paragraph []string
+	inCode := false
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			paragraphs = append(paragraphs, strings.Join(paragraph, "\n"))
+			paragraph = nil
+		}
+	}
+	flushSection := func() {
+		flushParagraph()
+		var text strings.Builder
+		add := func() {
+			if s := strings.TrimSpace(text.String()); s != "" {
+				sections = append(sections, section{heading: heading, text: s})
+			}
+			text.Reset()
+		}
+		for _, p := range paragraphs {
+			for len(p) > maxBytes {
+				// A single paragraph too large, cut at a line if possible
+				cut := strings.LastIndexByte(p[:maxBytes], '\n')
+				if cut <= 0 {
+					cut = maxBytes
+				}
+				add()
+				text.WriteString(p[:cut])
+				add()
+				p = strings.TrimLeft(p[cut:], "\n")
+			}
+			if text.Len() > 0 && text.Len()+2+len(p) > maxBytes {
+				add()
+			}
+			if text.Len() > 0 {
+				text.WriteString("\n\n")
+			}
+			text.WriteString(p)
+		}
+		add()
+		paragraphs = nil
+	}
+
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+		switch {
+		case !inCode && strings.HasPrefix(line, "#"):
+			flushSection()
+			heading = strings.TrimSpace(strings.TrimLeft(line, "#"))
+		case !inCode && trimmed == "":
+			flushParagraph()
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flushSection()
+	return sections
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+// countingClient counts the texts embedded by the mock client.
+type countingClient struct {
+	*gollm.MockClient
+	embedded int
+}
+
+func (c *countingClient) Embed(ctx context.Context, req *gollm.EmbeddingRequest) (*gollm.EmbeddingResponse, error) {
+	c.embedded += len(req.Texts)
+	return c.MockClient.Embed(ctx, req)
+}
+
+func writeDocs(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testDocs = `# Ingress
+
+## Certificate renewal
+
+Certificates of the ingress are renewed by cert-manager. When a certificate
+expires, delete the certificate secret to force a renewal.
+
+## Rate limits
+
+The ingress limits clients to 100 requests per second.
+`
+
+func TestIndexAndRetrieve(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeDocs(t, dir, map[string]string{
+		"ingress.md":          testDocs,
+		"storage/volumes.md":  "# Volumes\n\nResize persistent volumes by editing the claim.\n",
+		"config.yaml":         "not: a doc",
+		".git/description.md": "ignored",
+	})
+	client := &countingClient{MockClient: gollm.NewMockClient(&gollm.MockFixture{})}
+	indexer := &Indexer{Client: client, Embedder: "mock"}
+
+	index, err := indexer.Index(ctx, dir, nil)
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if len(index.Files) != 2 || len(index.Chunks) != 3 {
+		t.Fatalf("expected 3 chunks of 2 docs, got %d chunks of %v", len(index.Chunks), index.Files)
+	}
+
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := index.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	index, err = LoadIndex(path)
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+
+	retriever := &Retriever{Client: client, Index: index, TopK: 1}
+	results, err := retriever.Retrieve(ctx, "how do I renew an expired ingress certificate?")
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.Heading != "Certificate renewal" || results[0].Chunk.Source != "ingress.md" {
+		t.Fatalf("expected the certificate renewal section, got %+v", results)
+	}
+	formatted := FormatContext(results)
+	if !strings.Contains(formatted, `<excerpt id="1" source="ingress.md > Certificate renewal">`) {
+		t.Errorf("expected a numbered excerpt with its source, got %q", formatted)
+	}
+
+	// Only the doc that changed is embedded again
+	writeDocs(t, dir, map[string]string{"storage/volumes.md": "# Volumes\n\nVolumes can't be shrunk.\n"})
+	client.embedded = 0
+	updated, err := indexer.Index(ctx, dir, index)
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if client.embedded != 1 || len(updated.Chunks) != 3 {
+		t.Errorf("expected 1 chunk embedded again of 3, got %d of %d", client.embedded, len(updated.Chunks))
+	}
+
+	// All of them with another embedder
+	client.embedded = 0
+	indexer.Embedder = "other"
+	if _, err := indexer.Index(ctx, dir, updated); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if client.embedded != 3 {
+		t.Errorf("expected 3 chunks embedded again, got %d", client.embedded)
+	}
+}
+
+func TestSplitMarkdown(t *testing.T) {
+	doc := "intro\n\n# Steps\n\n```sh\n# not a heading\n\nkubectl get pods\n```\n\n" +
+		strings.Repeat("a long paragraph. ", 10) + "\n\n" + strings.Repeat("another one. ", 10)
+	sections := splitMarkdown(doc, 200)
+
+	var headings []string
+	for _, s := range sections {
+		if len(s.text) > 200 {
+			t.Errorf("section of %d bytes exceeds the maximum", len(s.text))
+		}
+		headings = append(headings, s.heading)
+	}
+	if strings.Join(headings, ",") != ",Steps,Steps,Steps" {
+		t.Fatalf("unexpected sections %+v", sections)
+	}
+	if !strings.Contains(sections[1].text, "# not a heading\n\nkubectl get pods") {
+		t.Errorf("expected the code block kept whole, got %q", sections[1].text)
+	}
+}
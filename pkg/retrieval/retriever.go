@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+)
+
+// DefaultTopK is the default number of excerpts retrieved for a query.
+const DefaultTopK = 3
+
+// Retriever retrieves the excerpts of an index relevant to queries.
+type Retriever struct {
+	Client gollm.Client
+	Index  *Index
+	// Model is the embedding model, the one the index was built with.
+	Model string
+	// TopK is the maximum number of excerpts retrieved. Defaults to
+	// DefaultTopK.
+	TopK int
+	// MinScore is the minimum similarity of the excerpts retrieved to the
+	// query, so that unrelated docs are not retrieved for every query.
+	MinScore float64
+}
+
+// Retrieve returns the excerpts most relevant to query, the most relevant
+// first.
+func (r *Retriever) Retrieve(ctx context.Context, query string) ([]Result, error) {
+	if r.Index == nil || len(r.Index.Chunks) == 0 || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	response, err := gollm.Embed(ctx, r.Client, &gollm.EmbeddingRequest{
+		Model: r.Model,
+		Texts: []string{query},
+		Task:  gollm.EmbeddingTaskQuery,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	topK := r.TopK
+	if topK <= 0 {
+		topK = DefaultTopK
+	}
+	return r.Index.Search(response.Embeddings[0], topK, r.MinScore), nil
+}
+
+// FormatContext formats the excerpts retrieved for a query for the prompt,
+// numbered so that the answer can cite them. It returns "" if there are none.
+func FormatContext(results []Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("The following excerpts of the user's runbooks and docs may be relevant to the next query. ")
+	sb.WriteString("Ignore the excerpts that are not relevant. ")
+	sb.WriteString("When your answer uses an excerpt, cite it as [n] and list the sources of the excerpts cited at the end of the answer.\n")
+	for i, result := range results {
+		source := result.Chunk.Source
+		if result.Chunk.Heading != "" {
+			source += " > " + result.Chunk.Heading
+		}
+		fmt.Fprintf(&sb, "\n<excerpt id=\"%d\" source=%q>\n%s\n</excerpt>\n", i+1, source, result.Chunk.Text)
+	}
+	return sb.String()
+}
@@ -16,7 +16,10 @@ package sandbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os/exec"
 )
 
 // Executor defines the interface for executing commands.
@@ -24,20 +27,94 @@ type Executor interface {
 	// Execute runs a command and returns the result.
 	Execute(ctx context.Context, command string, env []string, workDir string) (*ExecResult, error)
 
+	// Upload copies the file or directory at localPath on the agent host to
+	// remotePath in the execution environment.
+	Upload(ctx context.Context, localPath string, remotePath string) error
+
+	// Download copies the file or directory at remotePath in the execution
+	// environment to localPath on the agent host.
+	Download(ctx context.Context, remotePath string, localPath string) error
+
 	// Close cleans up any resources associated with the executor.
 	Close(ctx context.Context) error
 }
 
-// ExecResult represents the result of a command execution.
+// ExecResult represents the result of a command execution. All executors fill
+// in stdout and stderr separately, the exit code and the duration, so models
+// can tell a failing command from one that printed to stderr.
 type ExecResult struct {
-	Command    string `json:"command,omitempty"`
-	Error      string `json:"error,omitempty"`
-	Stdout     string `json:"stdout,omitempty"`
-	Stderr     string `json:"stderr,omitempty"`
-	ExitCode   int    `json:"exit_code,omitempty"`
+	Command string `json:"command,omitempty"`
+	// Error describes why the command failed, if it did.
+	Error  string `json:"error,omitempty"`
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// ExitCode is the exit code of the command, or -1 if it did not exit normally
+	// (e.g. it was killed or could not be started).
+	ExitCode int `json:"exit_code,omitempty"`
+	// DurationMs is how long the command ran, in milliseconds.
+	DurationMs int64  `json:"duration_ms,omitempty"`
 	StreamType string `json:"stream_type,omitempty"`
 }
 
 func (e *ExecResult) String() string {
-	return fmt.Sprintf("Command: %q\nError: %q\nStdout: %q\nStderr: %q\nExitCode: %d\nStreamType: %q}", e.Command, e.Error, e.Stdout, e.Stderr, e.ExitCode, e.StreamType)
+	return fmt.Sprintf("Command: %q\nError: %q\nStdout: %q\nStderr: %q\nExitCode: %d\nDurationMs: %d\nStreamType: %q}", e.Command, e.Error, e.Stdout, e.Stderr, e.ExitCode, e.DurationMs, e.StreamType)
+}
+
+type stdinKey struct{}
+
+// WithStdin returns a context that makes executors pass stdin to the command's
+// standard input, e.g. a manifest for `kubectl apply -f -`.
+func WithStdin(ctx context.Context, stdin io.Reader) context.Context {
+	return context.WithValue(ctx, stdinKey{}, stdin)
+}
+
+// stdinFromContext returns the standard input set with WithStdin, or nil.
+func stdinFromContext(ctx context.Context) io.Reader {
+	stdin, _ := ctx.Value(stdinKey{}).(io.Reader)
+	return stdin
+}
+
+type outputStreamKey struct{}
+
+// OutputStream receives the output of a command as it is produced. name is
+// "stdout" or "stderr". It is called from the goroutines copying the output
+// of the command, possibly concurrently, and must not retain p.
+type OutputStream func(name string, p []byte)
+
+// WithOutputStream returns a context that makes executors pass the output of
+// the command to stream as it is produced, e.g. to show the progress of long
+// running commands. The result of the command holds the whole output still.
+func WithOutputStream(ctx context.Context, stream OutputStream) context.Context {
+	return context.WithValue(ctx, outputStreamKey{}, stream)
+}
+
+// exitStatusError is implemented by the errors of commands that ran in a pod
+// and exited with a non-zero code.
+type exitStatusError interface {
+	ExitStatus() int
+}
+
+// setExitError records err, the error a command finished with, in the result.
+// It returns false if err does not come from the command exiting, e.g. when the
+// command could not be started.
+func (e *ExecResult) setExitError(err error) bool {
+	if err == nil || errors.Is(err, exec.ErrWaitDelay) {
+		// ErrWaitDelay means the command exited successfully, but left behind
+		// processes that still hold its output open.
+		return true
+	}
+	e.Error = err.Error()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+		return true
+	}
+	var statusErr exitStatusError
+	if errors.As(err, &statusErr) {
+		e.ExitCode = statusErr.ExitStatus()
+		return true
+	}
+	e.ExitCode = -1
+	return false
 }
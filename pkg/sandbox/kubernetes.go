@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -45,6 +46,11 @@ type KubernetesSandbox struct {
 	kubeconfig string
 	clientset  *kubernetes.Clientset
 	config     *rest.Config
+
+	// restricted runs the pod with a restricted security context, see applySecurity.
+	restricted bool
+	// runtimeClass is the RuntimeClass of the pod (e.g. gvisor or kata), empty for the cluster default.
+	runtimeClass string
 }
 
 // Execute executes the command in the sandbox.
@@ -62,21 +68,74 @@ func (s *KubernetesSandbox) Execute(ctx context.Context, command string, env []s
 		fullCommand = fmt.Sprintf("export %s; %s", envVar, fullCommand)
 	}
 
+	stdout, stderr := newOutputBuffer(ctx, "stdout"), newOutputBuffer(ctx, "stderr")
 	cmd := s.CommandContext(ctx, fullCommand)
-	output, err := cmd.CombinedOutput()
+	cmd.Stdin = stdinFromContext(ctx)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err := cmd.Run()
 
 	result := &ExecResult{
-		Command: command,
-		Stdout:  string(output),
-	}
-	if err != nil {
-		result.Error = err.Error()
-		result.ExitCode = 1
+		Command:    command,
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		DurationMs: time.Since(start).Milliseconds(),
 	}
+	result.setExitError(err)
 
 	return result, nil
 }
 
+// Upload copies localPath into the sandbox pod at remotePath, by streaming a
+// tar archive to tar running in the pod.
+func (s *KubernetesSandbox) Upload(ctx context.Context, localPath string, remotePath string) error {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(writeTar(pw, localPath, path.Base(remotePath)))
+	}()
+
+	dir := path.Dir(remotePath)
+	var stderr bytes.Buffer
+	cmd := s.CommandContext(ctx, fmt.Sprintf("mkdir -p %q && tar -xf - -C %q", dir, dir))
+	cmd.Stdin = pr
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("uploading %s to %s: %w: %s", localPath, remotePath, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Download copies remotePath in the sandbox pod to localPath, by reading a tar
+// archive created by tar running in the pod.
+func (s *KubernetesSandbox) Download(ctx context.Context, remotePath string, localPath string) error {
+	pr, pw := io.Pipe()
+	var stderr bytes.Buffer
+	cmd := s.CommandContext(ctx, fmt.Sprintf("tar -cf - -C %q %q", path.Dir(remotePath), path.Base(remotePath)))
+	cmd.Stdout = pw
+	cmd.Stderr = &stderr
+
+	runErr := make(chan error, 1)
+	go func() {
+		err := cmd.Run()
+		pw.CloseWithError(err)
+		runErr <- err
+	}()
+
+	extractErr := extractTar(pr, localPath, path.Base(remotePath))
+	pr.Close()
+	if err := <-runErr; err != nil {
+		return fmt.Errorf("downloading %s: %w: %s", remotePath, err, strings.TrimSpace(stderr.String()))
+	}
+	if extractErr != nil {
+		return fmt.Errorf("downloading %s: %w", remotePath, extractErr)
+	}
+	return nil
+}
+
 // Close cleans up the sandbox resources.
 func (s *KubernetesSandbox) Close(ctx context.Context) error {
 	return s.Delete(ctx)
@@ -101,8 +160,9 @@ type Option func(*KubernetesSandbox) error
 // NewKubernetesSandbox creates a new KubernetesSandbox instance with the given name and options
 func NewKubernetesSandbox(name string, opts ...Option) (*KubernetesSandbox, error) {
 	s := &KubernetesSandbox{
-		name:      name,
-		namespace: "computer", // default namespace
+		name:       name,
+		namespace:  "computer", // default namespace
+		restricted: true,
 	}
 
 	// Apply options
@@ -161,6 +221,25 @@ func WithImage(image string) Option {
 	}
 }
 
+// WithRestricted sets whether the pod runs with a restricted security context
+// (the default): non-root, read-only root filesystem, RuntimeDefault seccomp
+// profile and no capabilities.
+func WithRestricted(restricted bool) Option {
+	return func(s *KubernetesSandbox) error {
+		s.restricted = restricted
+		return nil
+	}
+}
+
+// WithRuntimeClass sets the RuntimeClass of the pod, e.g. "gvisor" or "kata",
+// to isolate commands from the node's kernel.
+func WithRuntimeClass(runtimeClass string) Option {
+	return func(s *KubernetesSandbox) error {
+		s.runtimeClass = runtimeClass
+		return nil
+	}
+}
+
 // Command creates a new Cmd to execute the given command in the sandbox
 // This follows the same interface as exec.Command
 func (s *KubernetesSandbox) Command(name string, arg ...string) *Cmd {
@@ -373,6 +452,8 @@ func (c *Cmd) createPod() error {
 		},
 	}
 
+	sandbox.applySecurity(pod)
+
 	_, podCreateErr := sandbox.clientset.CoreV1().Pods(sandbox.namespace).Create(c.ctx, pod, metav1.CreateOptions{})
 	if podCreateErr != nil {
 		// If pod creation fails, attempt to clean up the configmap we just created.
@@ -503,7 +584,7 @@ func (c *Cmd) executeInPod(stdout, stderr io.Writer) error {
 		Stderr: stderr,
 	})
 	if err != nil {
-		return fmt.Errorf("error executing command: %v", err)
+		return fmt.Errorf("error executing command: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,64 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+// sandboxUID is the user the restricted sandbox runs as. It matches the
+// non-root user of the default bitnami/kubectl image.
+const sandboxUID = 1001
+
+// applySecurity hardens the sandbox pod, as it runs commands generated by the model.
+func (s *KubernetesSandbox) applySecurity(pod *corev1.Pod) {
+	if s.runtimeClass != "" {
+		pod.Spec.RuntimeClassName = ptr.To(s.runtimeClass)
+	}
+	if !s.restricted {
+		return
+	}
+
+	pod.Spec.SecurityContext = &corev1.PodSecurityContext{
+		RunAsNonRoot: ptr.To(true),
+		RunAsUser:    ptr.To[int64](sandboxUID),
+		RunAsGroup:   ptr.To[int64](sandboxUID),
+		FSGroup:      ptr.To[int64](sandboxUID),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+
+	// The root filesystem is read-only, so give commands (and kubectl's
+	// cache in $HOME) a writable /tmp.
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name:         "tmp",
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		c.SecurityContext = &corev1.SecurityContext{
+			RunAsNonRoot:             ptr.To(true),
+			AllowPrivilegeEscalation: ptr.To(false),
+			ReadOnlyRootFilesystem:   ptr.To(true),
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+		}
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: "tmp", MountPath: "/tmp"})
+		c.Env = append(c.Env, corev1.EnvVar{Name: "HOME", Value: "/tmp"})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestApplySecurity(t *testing.T) {
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}}}}
+	}
+
+	t.Run("restricted", func(t *testing.T) {
+		pod := newPod()
+		(&KubernetesSandbox{restricted: true, runtimeClass: "gvisor"}).applySecurity(pod)
+
+		if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != "gvisor" {
+			t.Errorf("RuntimeClassName = %v, want gvisor", pod.Spec.RuntimeClassName)
+		}
+		psc := pod.Spec.SecurityContext
+		if psc == nil || psc.RunAsNonRoot == nil || !*psc.RunAsNonRoot {
+			t.Fatalf("expected the pod to run as non-root, got %+v", psc)
+		}
+		if psc.SeccompProfile == nil || psc.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault {
+			t.Errorf("SeccompProfile = %+v, want RuntimeDefault", psc.SeccompProfile)
+		}
+		sc := pod.Spec.Containers[0].SecurityContext
+		if sc == nil || sc.ReadOnlyRootFilesystem == nil || !*sc.ReadOnlyRootFilesystem {
+			t.Fatalf("expected a read-only root filesystem, got %+v", sc)
+		}
+		if sc.Capabilities == nil || len(sc.Capabilities.Drop) != 1 || sc.Capabilities.Drop[0] != "ALL" {
+			t.Errorf("Capabilities = %+v, want all dropped", sc.Capabilities)
+		}
+		if len(pod.Spec.Containers[0].VolumeMounts) != 1 || pod.Spec.Containers[0].VolumeMounts[0].MountPath != "/tmp" {
+			t.Errorf("expected a writable /tmp, got %+v", pod.Spec.Containers[0].VolumeMounts)
+		}
+	})
+
+	t.Run("unrestricted", func(t *testing.T) {
+		pod := newPod()
+		(&KubernetesSandbox{}).applySecurity(pod)
+
+		if pod.Spec.SecurityContext != nil || pod.Spec.Containers[0].SecurityContext != nil || pod.Spec.RuntimeClassName != nil {
+			t.Errorf("expected the pod to be unchanged, got %+v", pod.Spec)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+)
+
+type outputLimitKey struct{}
+
+// WithOutputLimit returns a context that limits how many bytes of stdout and
+// stderr executors keep from a command. Output beyond the limit is discarded
+// and replaced with a truncation marker. A limit <= 0 keeps all output.
+func WithOutputLimit(ctx context.Context, maxBytes int) context.Context {
+	return context.WithValue(ctx, outputLimitKey{}, maxBytes)
+}
+
+// limitedBuffer is an io.Writer that keeps the first max bytes written to it
+// and counts the rest. It passes everything written to the output stream of
+// the context, if any, limit or not.
+type limitedBuffer struct {
+	max       int
+	buf       []byte
+	discarded int
+
+	name   string
+	stream OutputStream
+}
+
+// newOutputBuffer returns the buffer of the output name, stdout or stderr,
+// of a command.
+func newOutputBuffer(ctx context.Context, name string) *limitedBuffer {
+	maxBytes, _ := ctx.Value(outputLimitKey{}).(int)
+	stream, _ := ctx.Value(outputStreamKey{}).(OutputStream)
+	return &limitedBuffer{max: maxBytes, name: name, stream: stream}
+}
+
+// Write never fails, so the command keeps running after the limit is reached.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.stream != nil {
+		b.stream(b.name, p)
+	}
+	if b.max > 0 {
+		if room := b.max - len(b.buf); room < len(p) {
+			b.discarded += len(p) - max(room, 0)
+			p = p[:max(room, 0)]
+		}
+	}
+	b.buf = append(b.buf, p...)
+	return n, nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.discarded == 0 {
+		return string(b.buf)
+	}
+	return fmt.Sprintf("%s\n[output truncated: %d more bytes were discarded]\n", b.buf, b.discarded)
+}
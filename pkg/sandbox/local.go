@@ -15,17 +15,20 @@
 package sandbox
 
 import (
-	"bytes"
 	"context"
 	"os"
 	"os/exec"
 	"runtime"
+	"time"
 
 	"k8s.io/klog/v2"
 )
 
 const (
 	defaultBashBin = "/bin/bash"
+
+	// waitDelay is how long to wait for the output of a killed command to be closed.
+	waitDelay = 2 * time.Second
 )
 
 // Local executes commands locally.
@@ -48,34 +51,42 @@ func (e *Local) Execute(ctx context.Context, command string, env []string, workD
 		cmd = exec.CommandContext(cmdCtx, lookupBashBin(), "-c", command)
 	}
 	cmd.Dir = workDir
+	// Don't wait for background processes that inherited stdout/stderr
+	// (e.g. `kubectl logs -f &`) once the command was killed.
+	cmd.WaitDelay = waitDelay
 	cmd.Env = env
+	cmd.Stdin = stdinFromContext(ctx)
 
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
+	stdoutBuf, stderrBuf := newOutputBuffer(ctx, "stdout"), newOutputBuffer(ctx, "stderr")
+	cmd.Stdout = stdoutBuf
+	cmd.Stderr = stderrBuf
 
+	start := time.Now()
 	err := cmd.Run()
 
 	result := &ExecResult{
-		Command: command,
-		Stdout:  stdoutBuf.String(),
-		Stderr:  stderrBuf.String(),
+		Command:    command,
+		Stdout:     stdoutBuf.String(),
+		Stderr:     stderrBuf.String(),
+		DurationMs: time.Since(start).Milliseconds(),
 	}
-
-	if err != nil {
-		// If it wasn't a timeout (or not a streaming command), it's a real error
-		if exitError, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitError.ExitCode()
-			result.Error = exitError.Error()
-			// Stderr is already captured in result.Stderr
-		} else {
-			return nil, err
-		}
+	if !result.setExitError(err) {
+		return nil, err
 	}
 
 	return result, nil
 }
 
+// Upload copies localPath to remotePath, both on the local filesystem.
+func (e *Local) Upload(ctx context.Context, localPath string, remotePath string) error {
+	return copyPath(localPath, remotePath)
+}
+
+// Download copies remotePath to localPath, both on the local filesystem.
+func (e *Local) Download(ctx context.Context, remotePath string, localPath string) error {
+	return copyPath(remotePath, localPath)
+}
+
 // Close is a no-op for Local executor.
 func (e *Local) Close(ctx context.Context) error {
 	return nil
@@ -0,0 +1,121 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestLocalExecute_SeparatesStreams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+
+	result, err := NewLocalExecutor().Execute(context.Background(), "echo out; echo err >&2; exit 3", nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Stdout != "out\n" {
+		t.Errorf("Stdout = %q, want %q", result.Stdout, "out\n")
+	}
+	if result.Stderr != "err\n" {
+		t.Errorf("Stderr = %q, want %q", result.Stderr, "err\n")
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", result.ExitCode)
+	}
+	if result.Error == "" {
+		t.Errorf("expected Error to be set for a failing command")
+	}
+}
+
+type fakeExitStatusError int
+
+func (e fakeExitStatusError) Error() string {
+	return fmt.Sprintf("command terminated with exit code %d", int(e))
+}
+func (e fakeExitStatusError) ExitStatus() int { return int(e) }
+
+func TestSetExitError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		exited   bool
+		exitCode int
+	}{
+		{"success", nil, true, 0},
+		{"pod exit status", fmt.Errorf("error executing command: %w", fakeExitStatusError(2)), true, 2},
+		{"not started", errors.New("pod creation failed"), false, -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := &ExecResult{}
+			if got := result.setExitError(tc.err); got != tc.exited {
+				t.Errorf("setExitError() = %v, want %v", got, tc.exited)
+			}
+			if result.ExitCode != tc.exitCode {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tc.exitCode)
+			}
+		})
+	}
+}
+
+func TestLocalExecute_OutputLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+
+	ctx := WithOutputLimit(context.Background(), 10)
+	result, err := NewLocalExecutor().Execute(ctx, "printf '0123456789abcdef'", nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := "0123456789\n[output truncated: 6 more bytes were discarded]\n"
+	if result.Stdout != want {
+		t.Errorf("Stdout = %q, want %q", result.Stdout, want)
+	}
+}
+
+func TestLocalExecute_OutputStream(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses bash")
+	}
+
+	var mu sync.Mutex
+	streamed := map[string]string{}
+	ctx := WithOutputLimit(context.Background(), 4)
+	ctx = WithOutputStream(ctx, func(name string, p []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed[name] += string(p)
+	})
+	result, err := NewLocalExecutor().Execute(ctx, "echo waiting; sleep 0.1; echo done; echo err >&2", nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	// The output is streamed whole, whatever the limit
+	if streamed["stdout"] != "waiting\ndone\n" || streamed["stderr"] != "err\n" {
+		t.Errorf("streamed %q, want the whole output", streamed)
+	}
+	if result.Stdout != "wait\n[output truncated: 9 more bytes were discarded]\n" {
+		t.Errorf("Stdout = %q", result.Stdout)
+	}
+}
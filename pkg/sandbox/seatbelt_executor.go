@@ -17,10 +17,10 @@
 package sandbox
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os/exec"
+	"time"
 )
 
 // Seatbelt executes commands in a seatbelt sandbox.
@@ -47,33 +47,40 @@ func (e *Seatbelt) Execute(ctx context.Context, command string, env []string, wo
 	wrappedCommand := fmt.Sprintf("sandbox-exec -p %q /bin/bash -c %q", "(version 1) (allow default)", command)
 	cmd := exec.CommandContext(cmdCtx, "/bin/bash", "-c", wrappedCommand)
 	cmd.Dir = workDir
+	// Don't wait for background processes that inherited stdout/stderr
+	// (e.g. `kubectl logs -f &`) once the command was killed.
+	cmd.WaitDelay = waitDelay
 	cmd.Env = env
+	cmd.Stdin = stdinFromContext(ctx)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout, stderr := newOutputBuffer(ctx, "stdout"), newOutputBuffer(ctx, "stderr")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
+	start := time.Now()
 	err := cmd.Run()
 
 	result := &ExecResult{
-		Command:  command,
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-		ExitCode: 0,
-	}
-
-	if err != nil {
-		result.Error = err.Error()
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-		} else {
-			result.ExitCode = 1
-		}
+		Command:    command,
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		DurationMs: time.Since(start).Milliseconds(),
 	}
+	result.setExitError(err)
 
 	return result, nil
 }
 
+// Upload copies localPath to remotePath, as the seatbelt sandbox shares the local filesystem.
+func (e *Seatbelt) Upload(ctx context.Context, localPath string, remotePath string) error {
+	return e.local.Upload(ctx, localPath, remotePath)
+}
+
+// Download copies remotePath to localPath, as the seatbelt sandbox shares the local filesystem.
+func (e *Seatbelt) Download(ctx context.Context, remotePath string, localPath string) error {
+	return e.local.Download(ctx, remotePath, localPath)
+}
+
 // Close is a no-op for Seatbelt executor.
 func (e *Seatbelt) Close(ctx context.Context) error {
 	return nil
@@ -34,6 +34,16 @@ func (e *Seatbelt) Execute(ctx context.Context, command string, env []string, wo
 	return nil, fmt.Errorf("seatbelt sandbox is only supported on macOS")
 }
 
+// Upload is not supported outside of macOS.
+func (e *Seatbelt) Upload(ctx context.Context, localPath string, remotePath string) error {
+	return fmt.Errorf("seatbelt sandbox is only supported on macOS")
+}
+
+// Download is not supported outside of macOS.
+func (e *Seatbelt) Download(ctx context.Context, remotePath string, localPath string) error {
+	return fmt.Errorf("seatbelt sandbox is only supported on macOS")
+}
+
 // Close is a no-op for Seatbelt executor.
 func (e *Seatbelt) Close(ctx context.Context) error {
 	return nil
@@ -0,0 +1,81 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalUploadDownload(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "manifests")
+	if err := os.MkdirAll(filepath.Join(src, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"deploy.yaml":        "kind: Deployment\n",
+		"nested/svc.yaml":    "kind: Service\n",
+		"nested/config.yaml": "kind: ConfigMap\n",
+		"v1..v2.patch":       "--- a\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	executor := NewLocalExecutor()
+	remote := filepath.Join(t.TempDir(), "staged")
+	if err := executor.Upload(context.Background(), src, remote); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	local := filepath.Join(t.TempDir(), "fetched")
+	if err := executor.Download(context.Background(), remote, local); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(local, name))
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExtractTar_RejectsEntriesOutsideName(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	content := []byte("pwned")
+	if err := tw.WriteHeader(&tar.Header{Name: "out/../../escape", Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := extractTar(&buf, t.TempDir(), "out"); err == nil {
+		t.Errorf("expected an error for an entry outside of the archive root")
+	}
+}
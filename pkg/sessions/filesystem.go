@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
 	"sigs.k8s.io/yaml"
 )
 
@@ -53,15 +54,22 @@ func (f *filesystemStore) GetSession(id string) (*api.Session, error) {
 	}
 
 	chatStore := NewFileChatMessageStore(sessionPath)
-	return &api.Session{
+	session := &api.Session{
 		ID:               id,
+		Name:             meta.Name,
 		ProviderID:       meta.ProviderID,
 		ModelID:          meta.ModelID,
 		AgentState:       api.AgentStateIdle,
 		CreatedAt:        meta.CreatedAt,
 		LastModified:     meta.LastAccessed,
+		Tags:             meta.Tags,
+		Feedback:         meta.Feedback,
+		Variables:        meta.Variables,
+		Memory:           meta.Memory,
 		ChatMessageStore: chatStore,
-	}, nil
+	}
+	setUsage(session, meta)
+	return session, nil
 }
 
 func (f *filesystemStore) CreateSession(session *api.Session) error {
@@ -74,10 +82,16 @@ func (f *filesystemStore) CreateSession(session *api.Session) error {
 	session.ChatMessageStore = chatStore
 
 	meta := Metadata{
+		Name:         session.Name,
 		ProviderID:   session.ProviderID,
 		ModelID:      session.ModelID,
 		CreatedAt:    session.CreatedAt,
 		LastAccessed: session.LastModified,
+		Tags:         session.Tags,
+		Feedback:     session.Feedback,
+		Variables:    session.Variables,
+		Memory:       session.Memory,
+		Usage:        usage(session),
 	}
 
 	data, err := yaml.Marshal(meta)
@@ -105,9 +119,15 @@ func (f *filesystemStore) UpdateSession(session *api.Session) error {
 		return err
 	}
 
+	meta.Name = session.Name
 	meta.ProviderID = session.ProviderID
 	meta.ModelID = session.ModelID
 	meta.LastAccessed = session.LastModified
+	meta.Tags = session.Tags
+	meta.Feedback = session.Feedback
+	meta.Variables = session.Variables
+	meta.Memory = session.Memory
+	meta.Usage = usage(session)
 
 	data, err := yaml.Marshal(meta)
 	if err != nil {
@@ -294,14 +314,21 @@ func (s *FileChatMessageStore) readMessages() ([]*api.Message, error) {
 	// JSONL format
 	scanner := bufio.NewScanner(f)
 
+	// A line that doesn't parse is only tolerated at the end of the file,
+	// where a crash while appending a message can leave it truncated.
+	var parseErr error
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		if len(line) == 0 {
 			continue
 		}
+		if parseErr != nil {
+			return nil, parseErr
+		}
 		var msg api.Message
 		if err := json.Unmarshal(line, &msg); err != nil {
-			return nil, err
+			parseErr = err
+			continue
 		}
 		messages = append(messages, &msg)
 	}
@@ -309,32 +336,100 @@ func (s *FileChatMessageStore) readMessages() ([]*api.Message, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
+	if parseErr != nil {
+		klog.Warningf("Ignoring truncated last message in %s: %v", path, parseErr)
+	}
 
 	return messages, nil
 }
 
+// writeMessages replaces the history with messages. The new history is
+// written to a temporary file that is renamed over the old one, so a crash
+// leaves either the old or the new history.
 func (s *FileChatMessageStore) writeMessages(messages []*api.Message) error {
+	var data []byte
+	for _, msg := range messages {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return s.writeFile(s.HistoryPath(), data)
+}
+
+// writeFile atomically replaces the file at path with data.
+func (s *FileChatMessageStore) writeFile(path string, data []byte) error {
 	if err := os.MkdirAll(s.Path, 0o755); err != nil {
 		return err
 	}
 
-	f, err := os.OpenFile(s.HistoryPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	f, err := os.CreateTemp(s.Path, filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer os.Remove(f.Name())
 
-	for _, msg := range messages {
-		data, err := json.Marshal(msg)
-		if err != nil {
-			return err
-		}
-		if _, err := f.Write(data); err != nil {
-			return err
-		}
-		if _, err := f.WriteString("\n"); err != nil {
-			return err
-		}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// checkpointPath returns the location of the tool loop checkpoint of this session.
+func (s *FileChatMessageStore) checkpointPath() string {
+	return filepath.Join(s.Path, "checkpoint.json")
+}
+
+// SaveCheckpoint persists the state of an in-flight tool loop.
+func (s *FileChatMessageStore) SaveCheckpoint(checkpoint *api.ToolLoopCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return s.writeFile(s.checkpointPath(), data)
+}
+
+// LoadCheckpoint returns the saved tool loop checkpoint, or nil if there is none.
+func (s *FileChatMessageStore) LoadCheckpoint() (*api.ToolLoopCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.checkpointPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint api.ToolLoopCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// ClearCheckpoint removes the tool loop checkpoint, if any.
+func (s *FileChatMessageStore) ClearCheckpoint() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.checkpointPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestFileChatMessageStore_TruncatedLastMessage(t *testing.T) {
+	store := NewFileChatMessageStore(t.TempDir())
+	for _, text := range []string{"first", "second"} {
+		if err := store.AddChatMessage(&api.Message{ID: text, Payload: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Simulate a crash while appending a message
+	f, err := os.OpenFile(store.HistoryPath(), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"third","payl`)
+	f.Close()
+
+	if got := store.ChatMessages(); len(got) != 2 || got[1].ID != "second" {
+		t.Errorf("expected the complete messages to be read, got %d messages", len(got))
+	}
+}
+
+func TestFileChatMessageStore_Checkpoint(t *testing.T) {
+	store := NewFileChatMessageStore(t.TempDir())
+	if checkpoint, err := store.LoadCheckpoint(); err != nil || checkpoint != nil {
+		t.Fatalf("LoadCheckpoint() = %v, %v; want no checkpoint", checkpoint, err)
+	}
+
+	saved := &api.ToolLoopCheckpoint{HistoryLength: 3, Calls: []api.CheckpointedToolCall{{Name: "kubectl", Done: true, Result: "ok"}}}
+	if err := store.SaveCheckpoint(saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.LoadCheckpoint()
+	if err != nil || loaded == nil || loaded.HistoryLength != 3 || !loaded.Calls[0].Done || loaded.Calls[0].Result != "ok" {
+		t.Fatalf("LoadCheckpoint() = %+v, %v; want the saved checkpoint", loaded, err)
+	}
+
+	if err := store.ClearCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint, _ := store.LoadCheckpoint(); checkpoint != nil {
+		t.Errorf("expected the checkpoint to be cleared")
+	}
+}
+
+func TestFilesystemStore_Usage(t *testing.T) {
+	store := newFilesystemStore(t.TempDir())
+	session := &api.Session{ID: "20250101-0001"}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	session.Usage = api.SessionUsage{Requests: 2, InputTokens: 1200, OutputTokens: 300, TotalTokens: 1500, EstimatedCost: 0.01, CostKnown: true}
+	if err := store.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+
+	resumed, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if resumed.Usage != session.Usage {
+		t.Errorf("expected the usage to be saved, got %+v", resumed.Usage)
+	}
+}
@@ -16,7 +16,9 @@ package sessions
 
 import (
 	"fmt"
+	"maps"
 	"math/rand"
+	"slices"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
@@ -54,7 +56,7 @@ func (sm *SessionManager) NewSession(meta Metadata) (*api.Session, error) {
 	now := time.Now()
 	session := &api.Session{
 		ID:           sessionID,
-		Name:         "Session " + sessionID,
+		Name:         DefaultSessionName(sessionID),
 		ProviderID:   meta.ProviderID,
 		ModelID:      meta.ModelID,
 		AgentState:   api.AgentStateIdle,
@@ -69,6 +71,63 @@ func (sm *SessionManager) NewSession(meta Metadata) (*api.Session, error) {
 	return session, nil
 }
 
+// DefaultSessionName is the name given to new sessions until they are renamed.
+func DefaultSessionName(id string) string {
+	return "Session " + id
+}
+
+// ForkSession creates a new session holding the first n messages of source,
+// so alternatives can be explored without losing the original conversation.
+// If message n is in the middle of tool calls and their results, the fork
+// point is moved to where all the calls have their results, as LLM providers
+// reject histories with unanswered tool calls.
+func (sm *SessionManager) ForkSession(source *api.Session, n int) (*api.Session, error) {
+	messages := source.AllMessages()
+	if n < 1 || n > len(messages) {
+		return nil, fmt.Errorf("message number must be between 1 and %d", len(messages))
+	}
+	n = forkPoint(messages, n)
+	if n == 0 {
+		return nil, fmt.Errorf("no message to fork at: the tool calls of the session have no results")
+	}
+
+	session, err := sm.NewSession(Metadata{
+		ProviderID: source.ProviderID,
+		ModelID:    source.ModelID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	session.Name = "Fork of " + source.Name
+	session.Variables = maps.Clone(source.Variables)
+	session.Memory = slices.Clone(source.Memory)
+	if err := sm.store.UpdateSession(session); err != nil {
+		return nil, err
+	}
+
+	if err := session.ChatMessageStore.SetChatMessages(slices.Clone(messages[:n])); err != nil {
+		return nil, fmt.Errorf("copying messages to forked session: %w", err)
+	}
+	return session, nil
+}
+
+// forkPoint returns the number of messages to fork with, the first at or
+// after n, or else the last before n, at which all the tool calls have their
+// results.
+func forkPoint(messages []*api.Message, n int) int {
+	for m := n; m <= len(messages); m++ {
+		if api.ValidateToolCallPairs(messages[:m]) == nil {
+			return m
+		}
+	}
+	for m := n - 1; m > 0; m-- {
+		if api.ValidateToolCallPairs(messages[:m]) == nil {
+			return m
+		}
+	}
+	return 0
+}
+
 func (sm *SessionManager) ListSessions() ([]*api.Session, error) {
 	return sm.store.ListSessions()
 }
@@ -101,6 +160,11 @@ func (sm *SessionManager) GetLatestSession() (*api.Session, error) {
 	return latest, nil
 }
 
+// UpdateSession saves the metadata of session, e.g. its state and usage.
+func (sm *SessionManager) UpdateSession(session *api.Session) error {
+	return sm.store.UpdateSession(session)
+}
+
 func (sm *SessionManager) UpdateLastAccessed(session *api.Session) error {
 	session.LastModified = time.Now()
 	return sm.store.UpdateSession(session)
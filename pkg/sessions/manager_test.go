@@ -0,0 +1,70 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+func TestSessionManager_ForkSession(t *testing.T) {
+	manager, err := NewSessionManager("memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := manager.NewSession(Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []*api.Message{
+		{ID: "1", Type: api.MessageTypeText, Payload: "why is web failing?"},
+		{ID: "2", Type: api.MessageTypeToolCallRequest, Payload: "kubectl get pods"},
+		{ID: "3", Type: api.MessageTypeToolCallRequest, Payload: "kubectl get events"},
+		{ID: "4", Type: api.MessageTypeToolCallResponse, Payload: "pods"},
+		{ID: "5", Type: api.MessageTypeToolCallResponse, Payload: "events"},
+		{ID: "6", Type: api.MessageTypeText, Payload: "the image can't be pulled"},
+		{ID: "7", Type: api.MessageTypeToolCallRequest, Payload: "kubectl describe pod web"},
+	} {
+		if err := source.ChatMessageStore.AddChatMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		n    int
+		want int
+	}{
+		{n: 1, want: 1},
+		// Forking between the calls and their results keeps the results.
+		{n: 2, want: 5},
+		{n: 4, want: 5},
+		{n: 6, want: 6},
+		// The last call has no result, so the fork is before it.
+		{n: 7, want: 6},
+	} {
+		forked, err := manager.ForkSession(source, tc.n)
+		if err != nil {
+			t.Fatalf("ForkSession(%d) failed: %v", tc.n, err)
+		}
+		if got := len(forked.AllMessages()); got != tc.want {
+			t.Errorf("ForkSession(%d) kept %d messages, want %d", tc.n, got, tc.want)
+		}
+	}
+
+	if _, err := manager.ForkSession(source, 8); err == nil {
+		t.Errorf("expected forking after the last message to fail")
+	}
+}
@@ -101,8 +101,9 @@ func (m *memoryStore) DeleteSession(id string) error {
 // InMemoryChatStore is an in-memory implementation of the api.ChatMessageStore interface.
 // It stores chat messages in a slice and is safe for concurrent use.
 type InMemoryChatStore struct {
-	mu       sync.RWMutex
-	messages []*api.Message
+	mu         sync.RWMutex
+	messages   []*api.Message
+	checkpoint *api.ToolLoopCheckpoint
 }
 
 // NewInMemoryChatStore creates a new InMemoryChatStore.
@@ -144,3 +145,26 @@ func (s *InMemoryChatStore) ClearChatMessages() error {
 	s.messages = make([]*api.Message, 0)
 	return nil
 }
+
+// SaveCheckpoint keeps the state of an in-flight tool loop.
+func (s *InMemoryChatStore) SaveCheckpoint(checkpoint *api.ToolLoopCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoint = checkpoint
+	return nil
+}
+
+// LoadCheckpoint returns the saved tool loop checkpoint, or nil if there is none.
+func (s *InMemoryChatStore) LoadCheckpoint() (*api.ToolLoopCheckpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkpoint, nil
+}
+
+// ClearCheckpoint removes the tool loop checkpoint, if any.
+func (s *InMemoryChatStore) ClearCheckpoint() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoint = nil
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	errObjectNotFound = errors.New("object not found")
+	// errVersionMismatch is returned by conditional writes if the object changed.
+	errVersionMismatch = errors.New("object version mismatch")
+)
+
+// versionAbsent is passed to objectStore.Put to only create the object if it does not exist.
+const versionAbsent = "absent"
+
+// objectStore is the subset of object storage operations needed by the remote session store.
+type objectStore interface {
+	// Get returns the content and version of the object at key.
+	Get(ctx context.Context, key string) ([]byte, string, error)
+	// Put writes the object at key and returns its new version. If ifVersion
+	// is not empty, the write only succeeds if the object is at that version
+	// (or does not exist, for versionAbsent); otherwise errVersionMismatch is returned.
+	Put(ctx context.Context, key string, data []byte, ifVersion string) (string, error)
+	// List returns the keys of the objects starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// IsRemoteBackend reports whether backend is an object storage URL (gs:// or s3://).
+func IsRemoteBackend(backend string) bool {
+	return strings.HasPrefix(backend, "gs://") || strings.HasPrefix(backend, "s3://")
+}
+
+// newObjectStore returns the objectStore for a gs://bucket/prefix or
+// s3://bucket/prefix URL, along with the key prefix.
+func newObjectStore(ctx context.Context, backend string) (objectStore, string, error) {
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing session backend URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("session backend URL %q has no bucket", backend)
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	switch u.Scheme {
+	case "gs":
+		store, err := newGCSObjectStore(ctx, u.Host)
+		return store, prefix, err
+	case "s3":
+		store, err := newS3ObjectStore(ctx, u.Host)
+		return store, prefix, err
+	default:
+		return nil, "", fmt.Errorf("unsupported session backend URL scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2/google"
+)
+
+const gcsBaseURL = "https://storage.googleapis.com"
+
+// gcsObjectStore stores objects in a Google Cloud Storage bucket using the
+// JSON API. Object generations are used as versions.
+type gcsObjectStore struct {
+	client *http.Client
+	bucket string
+}
+
+func newGCSObjectStore(ctx context.Context, bucket string) (*gcsObjectStore, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+	if err != nil {
+		return nil, fmt.Errorf("finding Google Cloud credentials: %w", err)
+	}
+	return &gcsObjectStore{client: client, bucket: bucket}, nil
+}
+
+func (s *gcsObjectStore) objectURL(key string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", gcsBaseURL, url.PathEscape(s.bucket), url.PathEscape(key))
+}
+
+func (s *gcsObjectStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key)+"?alt=media", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading gs://%s/%s: %w", s.bucket, key, err)
+	}
+	return data, resp.Header.Get("X-Goog-Generation"), nil
+}
+
+func (s *gcsObjectStore) Put(ctx context.Context, key string, data []byte, ifVersion string) (string, error) {
+	query := url.Values{"uploadType": {"media"}, "name": {key}}
+	switch ifVersion {
+	case "":
+	case versionAbsent:
+		query.Set("ifGenerationMatch", "0")
+	default:
+		query.Set("ifGenerationMatch", ifVersion)
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", gcsBaseURL, url.PathEscape(s.bucket), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var object struct {
+		Generation string `json:"generation"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+		return "", fmt.Errorf("decoding upload response for gs://%s/%s: %w", s.bucket, key, err)
+	}
+	return object.Generation, nil
+}
+
+func (s *gcsObjectStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	pageToken := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		u := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", gcsBaseURL, url.PathEscape(s.bucket), query.Encode())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding object list for gs://%s: %w", s.bucket, err)
+		}
+		for _, item := range page.Items {
+			keys = append(keys, item.Name)
+		}
+		if page.NextPageToken == "" {
+			return keys, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (s *gcsObjectStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends req and maps error statuses to errors.
+func (s *gcsObjectStore) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling Cloud Storage: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, errObjectNotFound
+	case http.StatusPreconditionFailed:
+		return nil, errVersionMismatch
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("cloud storage returned %s: %s", resp.Status, body)
+	}
+}
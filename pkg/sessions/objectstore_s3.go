@@ -0,0 +1,209 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// s3ObjectStore stores objects in an Amazon S3 (or S3 compatible) bucket.
+// ETags are used as versions, with conditional writes (If-Match / If-None-Match).
+// Credentials and region come from the standard AWS configuration; set
+// AWS_ENDPOINT_URL_S3 to use an S3 compatible service.
+type s3ObjectStore struct {
+	client   *http.Client
+	cfg      aws.Config
+	signer   *v4.Signer
+	bucket   string
+	endpoint string
+	// pathStyle addresses the bucket in the path rather than the host name
+	pathStyle bool
+}
+
+func newS3ObjectStore(ctx context.Context, bucket string) (*s3ObjectStore, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("no AWS region configured, set AWS_REGION")
+	}
+
+	s := &s3ObjectStore{
+		client:   http.DefaultClient,
+		cfg:      cfg,
+		signer:   v4.NewSigner(),
+		bucket:   bucket,
+		endpoint: fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, cfg.Region),
+	}
+	if cfg.BaseEndpoint != nil && *cfg.BaseEndpoint != "" {
+		s.endpoint = strings.TrimRight(*cfg.BaseEndpoint, "/")
+		s.pathStyle = true
+	}
+	return s, nil
+}
+
+func (s *s3ObjectStore) url(key string, query url.Values) string {
+	path := "/" + key
+	if s.pathStyle {
+		path = "/" + s.bucket + path
+	}
+	u := s.endpoint + (&url.URL{Path: path}).EscapedPath()
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (s *s3ObjectStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(key, nil), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (s *s3ObjectStore) Put(ctx context.Context, key string, data []byte, ifVersion string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(key, nil), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	switch ifVersion {
+	case "":
+	case versionAbsent:
+		req.Header.Set("If-None-Match", "*")
+	default:
+		req.Header.Set("If-Match", ifVersion)
+	}
+	resp, err := s.do(req, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *s3ObjectStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	continuationToken := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url("", query), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding object list for s3://%s: %w", s.bucket, err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !page.IsTruncated {
+			return keys, nil
+		}
+		continuationToken = page.NextContinuationToken
+	}
+}
+
+func (s *s3ObjectStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.url(key, nil), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do signs and sends req, mapping error statuses to errors.
+func (s *s3ObjectStore) do(req *http.Request, body []byte) (*http.Response, error) {
+	ctx := req.Context()
+	credentials, err := s.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err := s.signer.SignHTTP(ctx, credentials, req, payloadHash, "s3", s.cfg.Region, time.Now(), func(o *v4.SignerOptions) {
+		o.DisableURIPathEscaping = true
+	}); err != nil {
+		return nil, fmt.Errorf("signing S3 request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling S3: %w", err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, errObjectNotFound
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return nil, errVersionMismatch
+	default:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 returned %s: %s", resp.Status, message)
+	}
+}
@@ -0,0 +1,489 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"k8s.io/klog/v2"
+)
+
+// ErrConcurrentModification is returned when a session was written by another
+// agent since it was loaded, e.g. when the same session is open on two machines.
+var ErrConcurrentModification = errors.New("session was modified by another agent")
+
+// remoteTimeout bounds each object storage operation.
+const remoteTimeout = 30 * time.Second
+
+// remoteStore persists sessions in object storage, so they can be resumed from
+// other machines. Each session is stored as <prefix>/<id>/metadata.json and
+// <prefix>/<id>/history.json, with the messages added since the history was
+// last written in <prefix>/<id>/messages/. Writes are conditional on the
+// version that was read, so two agents can't overwrite each other's changes to
+// a session.
+type remoteStore struct {
+	objects objectStore
+	prefix  string
+}
+
+func newRemoteStore(backend string) (Store, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	objects, prefix, err := newObjectStore(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteStore{objects: objects, prefix: prefix}, nil
+}
+
+func (r *remoteStore) key(parts ...string) string {
+	return path.Join(append([]string{r.prefix}, parts...)...)
+}
+
+func (r *remoteStore) GetSession(id string) (*api.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	meta, version, err := r.getMetadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.session(id, meta, version), nil
+}
+
+func (r *remoteStore) CreateSession(session *api.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	version, err := r.putMetadata(ctx, session.ID, remoteMetadata(session), versionAbsent)
+	if err != nil {
+		if errors.Is(err, errVersionMismatch) {
+			return errors.New("session already exists")
+		}
+		return err
+	}
+	session.ChatMessageStore = r.chatMessageStore(session.ID, version)
+	return nil
+}
+
+// UpdateSession writes the metadata of session, if it wasn't written by
+// another agent since session was loaded.
+func (r *remoteStore) UpdateSession(session *api.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	store, ok := session.ChatMessageStore.(*remoteChatMessageStore)
+	if !ok {
+		return fmt.Errorf("session %q was not loaded from the remote store", session.ID)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	version, err := r.putMetadata(ctx, session.ID, remoteMetadata(session), store.metadataVersion)
+	if err != nil {
+		if errors.Is(err, errVersionMismatch) {
+			return ErrConcurrentModification
+		}
+		return err
+	}
+	store.metadataVersion = version
+	return nil
+}
+
+// remoteMetadata returns the metadata stored for session.
+func remoteMetadata(session *api.Session) Metadata {
+	return Metadata{
+		Name:         session.Name,
+		ProviderID:   session.ProviderID,
+		ModelID:      session.ModelID,
+		CreatedAt:    session.CreatedAt,
+		LastAccessed: session.LastModified,
+		Tags:         session.Tags,
+		Feedback:     session.Feedback,
+		Variables:    session.Variables,
+		Memory:       session.Memory,
+		Usage:        usage(session),
+	}
+}
+
+func (r *remoteStore) ListSessions() ([]*api.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	listPrefix := r.prefix
+	if listPrefix != "" {
+		listPrefix += "/"
+	}
+	keys, err := r.objects.List(ctx, listPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+
+	sessions := []*api.Session{}
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(key, listPrefix), "/metadata.json")
+		if !ok || strings.Contains(id, "/") {
+			continue
+		}
+		meta, version, err := r.getMetadata(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, r.session(id, meta, version))
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastModified.After(sessions[j].LastModified)
+	})
+	return sessions, nil
+}
+
+func (r *remoteStore) DeleteSession(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	keys, err := r.objects.List(ctx, r.key(id, "messages")+"/")
+	if err != nil {
+		return fmt.Errorf("deleting session %q: %w", id, err)
+	}
+	// The metadata goes last, so the session stays listed until it is gone.
+	keys = append(keys, r.key(id, "checkpoint.json"), r.key(id, "history.json"), r.key(id, "metadata.json"))
+	for _, key := range keys {
+		if err := r.objects.Delete(ctx, key); err != nil && !errors.Is(err, errObjectNotFound) {
+			return fmt.Errorf("deleting session %q: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// chatMessageStore returns the history of session id, whose metadata is at
+// metadataVersion.
+func (r *remoteStore) chatMessageStore(id string, metadataVersion string) *remoteChatMessageStore {
+	store := newRemoteChatMessageStore(r.objects, r.key(id, "history.json"))
+	store.metadataVersion = metadataVersion
+	return store
+}
+
+func (r *remoteStore) session(id string, meta Metadata, version string) *api.Session {
+	session := &api.Session{
+		ID:               id,
+		Name:             meta.Name,
+		ProviderID:       meta.ProviderID,
+		ModelID:          meta.ModelID,
+		AgentState:       api.AgentStateIdle,
+		CreatedAt:        meta.CreatedAt,
+		LastModified:     meta.LastAccessed,
+		Tags:             meta.Tags,
+		Feedback:         meta.Feedback,
+		Variables:        meta.Variables,
+		Memory:           meta.Memory,
+		ChatMessageStore: r.chatMessageStore(id, version),
+	}
+	setUsage(session, meta)
+	return session
+}
+
+func (r *remoteStore) getMetadata(ctx context.Context, id string) (Metadata, string, error) {
+	var meta Metadata
+	data, version, err := r.objects.Get(ctx, r.key(id, "metadata.json"))
+	if err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return meta, "", errors.New("session not found")
+		}
+		return meta, "", fmt.Errorf("reading session %q: %w", id, err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, "", fmt.Errorf("parsing metadata of session %q: %w", id, err)
+	}
+	return meta, version, nil
+}
+
+func (r *remoteStore) putMetadata(ctx context.Context, id string, meta Metadata, ifVersion string) (string, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	return r.objects.Put(ctx, r.key(id, "metadata.json"), data, ifVersion)
+}
+
+// remoteHistory is the content of history.json.
+type remoteHistory struct {
+	// Generation is incremented every time the history is written, so that
+	// the messages added to earlier generations are ignored.
+	Generation int            `json:"generation"`
+	Messages   []*api.Message `json:"messages"`
+}
+
+// remoteChatMessageStore implements api.ChatMessageStore on top of object storage.
+// The history is cached after it is first read. Messages are added as objects
+// of their own, so that adding one doesn't rewrite the whole history; the
+// object of message n of a generation can only be created once, so adding a
+// message fails with ErrConcurrentModification if another agent added one in
+// the meantime, or if history.json was written since it was cached. Replacing
+// the history writes history.json, conditional on the cached version, and
+// starts a new generation.
+type remoteChatMessageStore struct {
+	objects objectStore
+	key     string
+	// messagesPrefix is where the added messages are stored, next to the history.
+	messagesPrefix string
+	// checkpointKey is where the tool loop checkpoint is stored, next to the history.
+	checkpointKey string
+
+	mu         sync.Mutex
+	loaded     bool
+	messages   []*api.Message
+	version    string
+	generation int
+	// metadataVersion is the version of the session metadata that was read,
+	// which the next write of the metadata is conditional on.
+	metadataVersion string
+}
+
+func newRemoteChatMessageStore(objects objectStore, key string) *remoteChatMessageStore {
+	dir := path.Dir(key)
+	return &remoteChatMessageStore{
+		objects:        objects,
+		key:            key,
+		messagesPrefix: path.Join(dir, "messages") + "/",
+		checkpointKey:  path.Join(dir, "checkpoint.json"),
+	}
+}
+
+// generationPrefix is the prefix of the keys of the messages added to the
+// given generation of the history.
+func (s *remoteChatMessageStore) generationPrefix(generation int) string {
+	return fmt.Sprintf("%s%08d-", s.messagesPrefix, generation)
+}
+
+// AddChatMessage appends a message to the history.
+func (s *remoteChatMessageStore) AddChatMessage(record *api.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%08d.json", s.generationPrefix(s.generation), len(s.messages))
+	if _, err := s.objects.Put(ctx, key, data, versionAbsent); err != nil {
+		if errors.Is(err, errVersionMismatch) {
+			return ErrConcurrentModification
+		}
+		return fmt.Errorf("writing session history: %w", err)
+	}
+	// If another agent wrote the history in the meantime, it started a new
+	// generation and the message would be ignored.
+	if err := s.checkVersion(ctx); err != nil {
+		if err := s.objects.Delete(ctx, key); err != nil && !errors.Is(err, errObjectNotFound) {
+			klog.Warningf("Failed to delete %s: %v", key, err)
+		}
+		return err
+	}
+	s.messages = append(s.messages[:len(s.messages):len(s.messages)], record)
+	return nil
+}
+
+// SetChatMessages replaces the history with the provided messages.
+func (s *remoteChatMessageStore) SetChatMessages(newHistory []*api.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	return s.write(newHistory)
+}
+
+// ChatMessages returns the history.
+func (s *remoteChatMessageStore) ChatMessages() []*api.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return []*api.Message{}
+	}
+	return s.messages
+}
+
+// ClearChatMessages removes all messages from the history.
+func (s *remoteChatMessageStore) ClearChatMessages() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	return s.write([]*api.Message{})
+}
+
+// checkVersion returns ErrConcurrentModification if history.json is no longer
+// at the cached version. The caller must hold s.mu.
+func (s *remoteChatMessageStore) checkVersion(ctx context.Context) error {
+	_, version, err := s.objects.Get(ctx, s.key)
+	switch {
+	case errors.Is(err, errObjectNotFound):
+		version = versionAbsent
+	case err != nil:
+		return fmt.Errorf("reading session history: %w", err)
+	}
+	if version != s.version {
+		return ErrConcurrentModification
+	}
+	return nil
+}
+
+// load reads the history unless it is already cached. The caller must hold s.mu.
+func (s *remoteChatMessageStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	history := remoteHistory{Messages: []*api.Message{}}
+	data, version, err := s.objects.Get(ctx, s.key)
+	switch {
+	case errors.Is(err, errObjectNotFound):
+		version = versionAbsent
+	case err != nil:
+		return fmt.Errorf("reading session history: %w", err)
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")):
+		// Histories written before messages were added as objects of
+		// their own are a plain list of messages.
+		if err := json.Unmarshal(data, &history.Messages); err != nil {
+			return fmt.Errorf("parsing session history: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(data, &history); err != nil {
+			return fmt.Errorf("parsing session history: %w", err)
+		}
+	}
+
+	keys, err := s.objects.List(ctx, s.generationPrefix(history.Generation))
+	if err != nil {
+		return fmt.Errorf("listing session messages: %w", err)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data, _, err := s.objects.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("reading session message: %w", err)
+		}
+		var message api.Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			return fmt.Errorf("parsing session message %s: %w", key, err)
+		}
+		history.Messages = append(history.Messages, &message)
+	}
+	s.messages, s.version, s.generation, s.loaded = history.Messages, version, history.Generation, true
+	return nil
+}
+
+// write stores messages as the history, in a new generation. The caller must
+// hold s.mu.
+func (s *remoteChatMessageStore) write(messages []*api.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(remoteHistory{Generation: s.generation + 1, Messages: messages})
+	if err != nil {
+		return err
+	}
+	version, err := s.objects.Put(ctx, s.key, data, s.version)
+	if err != nil {
+		if errors.Is(err, errVersionMismatch) {
+			return ErrConcurrentModification
+		}
+		return fmt.Errorf("writing session history: %w", err)
+	}
+	previous := s.generation
+	s.messages, s.version, s.generation = messages, version, s.generation+1
+
+	// The messages added to the previous generation are now in the history.
+	keys, err := s.objects.List(ctx, s.generationPrefix(previous))
+	if err != nil {
+		klog.Warningf("Failed to list the messages of the previous session history: %v", err)
+		return nil
+	}
+	for _, key := range keys {
+		if err := s.objects.Delete(ctx, key); err != nil && !errors.Is(err, errObjectNotFound) {
+			klog.Warningf("Failed to delete %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// SaveCheckpoint persists the state of an in-flight tool loop.
+func (s *remoteChatMessageStore) SaveCheckpoint(checkpoint *api.ToolLoopCheckpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if _, err := s.objects.Put(ctx, s.checkpointKey, data, ""); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// LoadCheckpoint returns the saved tool loop checkpoint, or nil if there is none.
+func (s *remoteChatMessageStore) LoadCheckpoint() (*api.ToolLoopCheckpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	data, _, err := s.objects.Get(ctx, s.checkpointKey)
+	if errors.Is(err, errObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	var checkpoint api.ToolLoopCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// ClearCheckpoint removes the tool loop checkpoint, if any.
+func (s *remoteChatMessageStore) ClearCheckpoint() error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	if err := s.objects.Delete(ctx, s.checkpointKey); err != nil && !errors.Is(err, errObjectNotFound) {
+		return fmt.Errorf("removing checkpoint: %w", err)
+	}
+	return nil
+}
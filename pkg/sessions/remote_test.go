@@ -0,0 +1,264 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessions
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// fakeObjectStore is an in-memory objectStore with versioned conditional writes.
+type fakeObjectStore struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	versions map[string]int
+}
+
+func newFakeObjectStore() *fakeObjectStore {
+	return &fakeObjectStore{objects: map[string][]byte{}, versions: map[string]int{}}
+}
+
+func (f *fakeObjectStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	if !ok {
+		return nil, "", errObjectNotFound
+	}
+	return data, strconv.Itoa(f.versions[key]), nil
+}
+
+func (f *fakeObjectStore) Put(ctx context.Context, key string, data []byte, ifVersion string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, exists := f.objects[key]
+	switch {
+	case ifVersion == versionAbsent && exists:
+		return "", errVersionMismatch
+	case ifVersion != "" && ifVersion != versionAbsent && ifVersion != strconv.Itoa(f.versions[key]):
+		return "", errVersionMismatch
+	}
+	f.objects[key] = data
+	f.versions[key]++
+	return strconv.Itoa(f.versions[key]), nil
+}
+
+func (f *fakeObjectStore) List(ctx context.Context, prefix string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeObjectStore) Delete(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.objects[key]; !ok {
+		return errObjectNotFound
+	}
+	delete(f.objects, key)
+	return nil
+}
+
+func TestRemoteStore(t *testing.T) {
+	objects := newFakeObjectStore()
+	store := &remoteStore{objects: objects, prefix: "team/sessions"}
+
+	session := &api.Session{ID: "20250101-0001", ModelID: "gemini-2.5-pro", CreatedAt: time.Now(), LastModified: time.Now()}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := store.CreateSession(&api.Session{ID: session.ID}); err == nil {
+		t.Errorf("expected creating a duplicate session to fail")
+	}
+	if err := session.ChatMessageStore.AddChatMessage(&api.Message{ID: "1", Payload: "hello"}); err != nil {
+		t.Fatalf("AddChatMessage failed: %v", err)
+	}
+
+	// Resume the session as another agent would, e.g. on another machine.
+	sessions, err := store.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != session.ID || sessions[0].ModelID != "gemini-2.5-pro" {
+		t.Fatalf("unexpected sessions %+v", sessions)
+	}
+	resumed := sessions[0]
+	if messages := resumed.ChatMessageStore.ChatMessages(); len(messages) != 1 || messages[0].Payload != "hello" {
+		t.Fatalf("unexpected resumed messages %+v", messages)
+	}
+	if err := resumed.ChatMessageStore.AddChatMessage(&api.Message{ID: "2", Payload: "from the bastion"}); err != nil {
+		t.Fatalf("AddChatMessage on resumed session failed: %v", err)
+	}
+
+	// The first agent has a stale copy of the history and must not overwrite it.
+	err = session.ChatMessageStore.AddChatMessage(&api.Message{ID: "3", Payload: "from the laptop"})
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+
+	if err := store.DeleteSession(session.ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if _, err := store.GetSession(session.ID); err == nil {
+		t.Errorf("expected deleted session to be gone")
+	}
+}
+
+func TestRemoteStore_AppendsMessages(t *testing.T) {
+	objects := newFakeObjectStore()
+	store := &remoteStore{objects: objects}
+
+	session := &api.Session{ID: "20250101-0001"}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := session.ChatMessageStore.AddChatMessage(&api.Message{ID: id}); err != nil {
+			t.Fatalf("AddChatMessage failed: %v", err)
+		}
+	}
+	if _, ok := objects.objects["20250101-0001/history.json"]; ok {
+		t.Errorf("expected adding messages not to write the whole history")
+	}
+
+	if err := session.ChatMessageStore.SetChatMessages([]*api.Message{{ID: "summary"}}); err != nil {
+		t.Fatalf("SetChatMessages failed: %v", err)
+	}
+	if err := session.ChatMessageStore.AddChatMessage(&api.Message{ID: "4"}); err != nil {
+		t.Fatalf("AddChatMessage failed: %v", err)
+	}
+	keys, _ := objects.List(context.Background(), "20250101-0001/messages/")
+	if len(keys) != 1 {
+		t.Errorf("expected only the message added since the history was written to be kept, got %v", keys)
+	}
+
+	resumed, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	var ids []string
+	for _, message := range resumed.ChatMessageStore.ChatMessages() {
+		ids = append(ids, message.ID)
+	}
+	if strings.Join(ids, ",") != "summary,4" {
+		t.Errorf("expected the resumed history to be summary,4, got %v", ids)
+	}
+}
+
+func TestRemoteStore_AppendAfterHistoryRewrite(t *testing.T) {
+	objects := newFakeObjectStore()
+	store := &remoteStore{objects: objects}
+	session := &api.Session{ID: "20250101-0001"}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := session.ChatMessageStore.AddChatMessage(&api.Message{ID: "1"}); err != nil {
+		t.Fatalf("AddChatMessage failed: %v", err)
+	}
+	other, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+
+	// The other agent clears the history, which starts a new generation
+	// that the first agent's cache doesn't know about.
+	if err := other.ChatMessageStore.ClearChatMessages(); err != nil {
+		t.Fatalf("ClearChatMessages failed: %v", err)
+	}
+	if err := session.ChatMessageStore.AddChatMessage(&api.Message{ID: "2"}); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	keys, _ := objects.List(context.Background(), "20250101-0001/messages/")
+	if len(keys) != 0 {
+		t.Errorf("expected the rejected message not to be kept, got %v", keys)
+	}
+
+	// The other agent's history is up to date, so it can still add messages.
+	if err := other.ChatMessageStore.AddChatMessage(&api.Message{ID: "3"}); err != nil {
+		t.Fatalf("AddChatMessage failed: %v", err)
+	}
+	resumed, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if messages := resumed.ChatMessageStore.ChatMessages(); len(messages) != 1 || messages[0].ID != "3" {
+		t.Errorf("expected the resumed history to only hold message 3, got %v", messages)
+	}
+}
+
+func TestRemoteStore_LegacyHistory(t *testing.T) {
+	objects := newFakeObjectStore()
+	store := &remoteStore{objects: objects}
+	if err := store.CreateSession(&api.Session{ID: "20250101-0001"}); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	// Histories used to be stored as a plain list of messages.
+	objects.Put(context.Background(), "20250101-0001/history.json", []byte(`[{"id":"1"},{"id":"2"}]`), "")
+
+	session, err := store.GetSession("20250101-0001")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if err := session.ChatMessageStore.AddChatMessage(&api.Message{ID: "3"}); err != nil {
+		t.Fatalf("AddChatMessage failed: %v", err)
+	}
+	resumed, err := store.GetSession("20250101-0001")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if got := len(resumed.ChatMessageStore.ChatMessages()); got != 3 {
+		t.Errorf("expected 3 messages, got %d", got)
+	}
+}
+
+func TestRemoteStore_UpdateSessionConflict(t *testing.T) {
+	store := &remoteStore{objects: newFakeObjectStore()}
+	session := &api.Session{ID: "20250101-0001", Name: "original"}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	other, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+
+	other.Name = "renamed elsewhere"
+	if err := store.UpdateSession(other); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	// The first agent loaded the metadata before it was renamed.
+	session.Name = "renamed here"
+	if err := store.UpdateSession(session); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification, got %v", err)
+	}
+	// Updates of an up to date session go through.
+	other.Name = "renamed again"
+	if err := store.UpdateSession(other); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+}
@@ -18,7 +18,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
+	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
@@ -27,14 +27,61 @@ import (
 const sessionsDirName = "sessions"
 
 type Metadata struct {
+	Name         string    `json:"name,omitempty"`
 	ProviderID   string    `json:"providerID"`
 	ModelID      string    `json:"modelID"`
 	CreatedAt    time.Time `json:"createdAt"`
 	LastAccessed time.Time `json:"lastAccessed"`
+	Tags         []string  `json:"tags,omitempty"`
+
+	Feedback []api.Feedback `json:"feedback,omitempty"`
+
+	Variables map[string]string `json:"variables,omitempty"`
+	Memory    []string          `json:"memory,omitempty"`
+
+	// Usage is the token usage and estimated cost of the session so far.
+	Usage *api.SessionUsage `json:"usage,omitempty"`
+}
+
+// usage returns the usage of session to save in its metadata, nil if none.
+func usage(session *api.Session) *api.SessionUsage {
+	if session.Usage.Requests == 0 {
+		return nil
+	}
+	u := session.Usage
+	return &u
+}
+
+// setUsage restores the usage of a session from its metadata.
+func setUsage(session *api.Session, meta Metadata) {
+	if meta.Usage != nil {
+		session.Usage = *meta.Usage
+	}
 }
 
 var defaultMemoryStore Store = newMemoryStore()
 
+var (
+	remoteStoresMu sync.Mutex
+	// remoteStores caches remote stores by URL, so credentials are only looked up once.
+	remoteStores = map[string]Store{}
+)
+
+func remoteStoreFor(backend string) (Store, error) {
+	remoteStoresMu.Lock()
+	defer remoteStoresMu.Unlock()
+
+	if store, ok := remoteStores[backend]; ok {
+		return store, nil
+	}
+	store, err := newRemoteStore(backend)
+	if err != nil {
+		return nil, err
+	}
+	remoteStores[backend] = store
+	return store, nil
+}
+
 type Store interface {
 	GetSession(id string) (*api.Session, error)
 	CreateSession(session *api.Session) error
@@ -57,6 +104,9 @@ func NewStore(backend string) (Store, error) {
 		}
 		return newFilesystemStore(basePath), nil
 	default:
+		if IsRemoteBackend(backend) {
+			return remoteStoreFor(backend)
+		}
 		return nil, fmt.Errorf("unsupported sessions backend: %s", backend)
 	}
 }
@@ -0,0 +1,140 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// ApplyManifestTool applies a manifest generated by the LLM.
+// It implements Previewer, so the user is shown a `kubectl diff` of the
+// manifest against the cluster before approving the apply.
+type ApplyManifestTool struct {
+	executor sandbox.Executor
+}
+
+func NewApplyManifestTool(executor sandbox.Executor) *ApplyManifestTool {
+	return &ApplyManifestTool{executor: executor}
+}
+
+func (t *ApplyManifestTool) Name() string {
+	return "apply_manifest"
+}
+
+func (t *ApplyManifestTool) Description() string {
+	return `Applies a Kubernetes manifest (YAML) to the user's cluster. The user is shown a diff against the live state before the manifest is applied. Prefer this tool over writing manifests to files with bash and running kubectl apply.`
+}
+
+func (t *ApplyManifestTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"manifest": {
+					Type:        gollm.TypeString,
+					Description: `The complete YAML manifest to apply. Multiple resources can be separated with "---".`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `Optional namespace to apply namespaced resources to, if the manifest does not specify one.`,
+				},
+			},
+			Required: []string{"manifest"},
+		},
+	}
+}
+
+// buildCommand returns the kubectl apply command for the manifest, with the
+// manifest as a here-document, so that the user sees what is applied.
+func (t *ApplyManifestTool) buildCommand(args map[string]any) (string, error) {
+	manifest, _ := args["manifest"].(string)
+	if strings.TrimSpace(manifest) == "" {
+		return "", fmt.Errorf("manifest must not be empty")
+	}
+	command, err := kubectlManifestCommand("apply", args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s <<'EOF'\n%s\nEOF", command, strings.TrimRight(manifest, "\n")), nil
+}
+
+// kubectlManifestCommand returns the kubectl command running verb against a
+// manifest read from stdin, in the namespace of args if set.
+func kubectlManifestCommand(verb string, args map[string]any) (string, error) {
+	command := fmt.Sprintf("kubectl %s -f -", verb)
+	if namespace, _ := args["namespace"].(string); namespace != "" {
+		quoted, err := shellQuote(namespace)
+		if err != nil {
+			return "", err
+		}
+		command += " --namespace " + quoted
+	}
+	return command, nil
+}
+
+// Preview runs `kubectl diff` for the manifest and returns the diff.
+func (t *ApplyManifestTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	result, err := t.kubectl(ctx, "diff", args)
+	if err != nil {
+		return "", err
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("%s", result.Error)
+	}
+	// kubectl diff exits with 1 if there are differences, and >1 on errors.
+	switch result.ExitCode {
+	case 0:
+		return "No changes: the manifest matches the live state of the cluster.", nil
+	case 1:
+		return result.Stdout, nil
+	default:
+		return "", fmt.Errorf("kubectl diff failed: %s", strings.TrimSpace(result.Stderr))
+	}
+}
+
+func (t *ApplyManifestTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	return t.kubectl(ctx, "apply", args)
+}
+
+// kubectl runs the given kubectl verb against the manifest.
+func (t *ApplyManifestTool) kubectl(ctx context.Context, verb string, args map[string]any) (*sandbox.ExecResult, error) {
+	manifest, _ := args["manifest"].(string)
+	if strings.TrimSpace(manifest) == "" {
+		return &sandbox.ExecResult{Error: "manifest must not be empty"}, nil
+	}
+
+	// Pass the manifest on stdin, so it doesn't have to be quoted in the
+	// command or staged as a file where the command runs.
+	command, err := kubectlManifestCommand(verb, args)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	return runKubectl(sandbox.WithStdin(ctx, strings.NewReader(manifest)), t.executor, command)
+}
+
+func (t *ApplyManifestTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *ApplyManifestTool) CheckModifiesResource(args map[string]any) string {
+	return "yes"
+}
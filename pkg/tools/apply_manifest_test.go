@@ -0,0 +1,46 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestApplyManifestTool_QuotesNamespace(t *testing.T) {
+	args := map[string]any{
+		"manifest":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n",
+		"namespace": "prod$(kubectl delete ns prod)`id`",
+	}
+	wantCommand := `kubectl apply -f - --namespace 'prod$(kubectl delete ns prod)` + "`id`'"
+
+	executor := &MockExecutor{}
+	tool := NewApplyManifestTool(executor)
+	if _, err := tool.Run(context.Background(), args); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if executor.CapturedCommand != wantCommand {
+		t.Errorf("expected the command %q to be executed, got %q", wantCommand, executor.CapturedCommand)
+	}
+
+	approved, err := tool.buildCommand(args)
+	if err != nil {
+		t.Fatalf("buildCommand() error = %v", err)
+	}
+	if !strings.HasPrefix(approved, wantCommand+" <<'EOF'\n") {
+		t.Errorf("expected the approved command to start with %q, got %q", wantCommand, approved)
+	}
+}
@@ -71,6 +71,7 @@ func (t *BashTool) FunctionDefinition() *gollm.FunctionDefinition {
 					Type:        gollm.TypeString,
 					Description: `The bash command to execute.`,
 				},
+				"stdin": stdinSchema,
 				"modifies_resource": {
 					Type: gollm.TypeString,
 					Description: `Whether the command modifies a kubernetes resource.
@@ -104,6 +105,7 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (any, error) {
 		env = append(env, "KUBECONFIG="+kubeconfig)
 	}
 
+	ctx = withStdinArg(ctx, args)
 	return ExecuteWithStreamingHandling(ctx, t.executor, command, workDir, env, DetectKubectlStreaming)
 }
 
@@ -0,0 +1,238 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// contentFilterTimeout bounds how long a content filter may take.
+const contentFilterTimeout = 30 * time.Second
+
+// ContentKind identifies where content sent to the LLM comes from.
+type ContentKind string
+
+const (
+	ContentKindUserInput  ContentKind = "user_input"
+	ContentKindAttachment ContentKind = "attachment"
+	ContentKindToolOutput ContentKind = "tool_output"
+	// ContentKindSystemPrompt is the system prompt, with the cluster context,
+	// the user profile and the memory of the session.
+	ContentKindSystemPrompt ContentKind = "system_prompt"
+)
+
+// ContentFilter transforms content before it is sent to the LLM, e.g. to
+// remove pod IPs or customer identifiers that must not leave the organization.
+// If a filter fails, the content is not sent.
+type ContentFilter interface {
+	Filter(ctx context.Context, kind ContentKind, content string) (string, error)
+}
+
+// NewContentFilter creates the filter for spec: a webhook if spec is an
+// http(s) URL, otherwise a shell command.
+func NewContentFilter(spec string) (ContentFilter, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "":
+		return nil, fmt.Errorf("content filter is empty")
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return &WebhookFilter{URL: spec}, nil
+	default:
+		return &CommandFilter{Command: spec}, nil
+	}
+}
+
+// NewContentFilters creates the filters for specs, see NewContentFilter.
+func NewContentFilters(specs []string) ([]ContentFilter, error) {
+	var filters []ContentFilter
+	for _, spec := range specs {
+		filter, err := NewContentFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// WebhookFilter filters content with an HTTP endpoint. The content is POSTed
+// as {"kind": ..., "content": ...}, and the endpoint responds with
+// {"content": ...}, the content to send instead.
+type WebhookFilter struct {
+	URL string
+	// Client is the HTTP client used, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+type webhookFilterRequest struct {
+	Kind    ContentKind `json:"kind"`
+	Content string      `json:"content"`
+}
+
+type webhookFilterResponse struct {
+	Content *string `json:"content"`
+}
+
+func (f *WebhookFilter) Filter(ctx context.Context, kind ContentKind, content string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, contentFilterTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(webhookFilterRequest{Kind: kind, Content: content})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("creating content filter request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("calling content filter %s: %w", f.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("content filter %s returned %s: %s", f.URL, resp.Status, strings.TrimSpace(string(b)))
+	}
+	var result webhookFilterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding response of content filter %s: %w", f.URL, err)
+	}
+	if result.Content == nil {
+		return "", fmt.Errorf("response of content filter %s has no content", f.URL)
+	}
+	return *result.Content, nil
+}
+
+// CommandFilter filters content with a shell command, which reads the content
+// on stdin and writes the content to send instead on stdout. The kind of
+// content is in the KUBECTL_AI_CONTENT_KIND environment variable.
+type CommandFilter struct {
+	Command string
+}
+
+func (f *CommandFilter) Filter(ctx context.Context, kind ContentKind, content string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, contentFilterTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, os.Getenv("COMSPEC"), "/c", f.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", f.Command)
+	}
+	cmd.Env = append(os.Environ(), "KUBECTL_AI_CONTENT_KIND="+string(kind))
+	cmd.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running content filter %q: %w: %s", f.Command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// FilterOutput applies filters to the text of a tool output: the stdout,
+// stderr and error of an *sandbox.ExecResult, a string, or the strings in
+// maps and slices. Other outputs, e.g. structs, are converted to their JSON
+// form first.
+func FilterOutput(ctx context.Context, filters []ContentFilter, output any) (any, error) {
+	switch v := output.(type) {
+	case *sandbox.ExecResult:
+		if v == nil {
+			return output, nil
+		}
+		result := *v
+		var err error
+		if result.Stdout, err = FilterContent(ctx, filters, ContentKindToolOutput, v.Stdout); err != nil {
+			return nil, err
+		}
+		if result.Stderr, err = FilterContent(ctx, filters, ContentKindToolOutput, v.Stderr); err != nil {
+			return nil, err
+		}
+		if result.Error, err = FilterContent(ctx, filters, ContentKindToolOutput, v.Error); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	case string:
+		return FilterContent(ctx, filters, ContentKindToolOutput, v)
+	case map[string]any:
+		filtered := make(map[string]any, len(v))
+		for key, value := range v {
+			f, err := FilterOutput(ctx, filters, value)
+			if err != nil {
+				return nil, err
+			}
+			filtered[key] = f
+		}
+		return filtered, nil
+	case []any:
+		filtered := make([]any, len(v))
+		for i, value := range v {
+			f, err := FilterOutput(ctx, filters, value)
+			if err != nil {
+				return nil, err
+			}
+			filtered[i] = f
+		}
+		return filtered, nil
+	case nil, bool, int, int64, float64:
+		return output, nil
+	default:
+		b, err := json.Marshal(output)
+		if err != nil {
+			return nil, fmt.Errorf("converting the output to JSON: %w", err)
+		}
+		var decoded any
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			return nil, fmt.Errorf("converting the output to JSON: %w", err)
+		}
+		return FilterOutput(ctx, filters, decoded)
+	}
+}
+
+// FilterContent applies filters in order to content. Empty content is not
+// filtered.
+func FilterContent(ctx context.Context, filters []ContentFilter, kind ContentKind, content string) (string, error) {
+	if content == "" {
+		return content, nil
+	}
+	for _, filter := range filters {
+		filtered, err := filter.Filter(ctx, kind, content)
+		if err != nil {
+			return "", err
+		}
+		content = filtered
+	}
+	return content, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+func TestNewContentFilter(t *testing.T) {
+	if f, err := NewContentFilter("https://dlp.example.com/filter"); err != nil {
+		t.Fatalf("NewContentFilter() error = %v", err)
+	} else if _, ok := f.(*WebhookFilter); !ok {
+		t.Errorf("expected a webhook filter for a URL, got %T", f)
+	}
+	if f, err := NewContentFilter("sed s/a/b/"); err != nil {
+		t.Fatalf("NewContentFilter() error = %v", err)
+	} else if _, ok := f.(*CommandFilter); !ok {
+		t.Errorf("expected a command filter, got %T", f)
+	}
+	if _, err := NewContentFilter(" "); err == nil {
+		t.Error("expected an error for an empty filter")
+	}
+}
+
+func TestWebhookFilter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req webhookFilterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Content == "fail" {
+			http.Error(w, "rejected", http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"content": string(req.Kind) + ": " + strings.ReplaceAll(req.Content, "10.0.0.7", "<pod-ip>"),
+		})
+	}))
+	defer server.Close()
+
+	filter := &WebhookFilter{URL: server.URL}
+	got, err := filter.Filter(context.Background(), ContentKindToolOutput, "pod web at 10.0.0.7")
+	if err != nil {
+		t.Fatalf("Filter() error = %v", err)
+	}
+	if want := "tool_output: pod web at <pod-ip>"; got != want {
+		t.Errorf("Filter() = %q, want %q", got, want)
+	}
+
+	if _, err := filter.Filter(context.Background(), ContentKindUserInput, "fail"); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("expected the error of the webhook, got %v", err)
+	}
+}
+
+func TestCommandFilter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	filter := &CommandFilter{Command: `sed "s/customer-[0-9]*/<customer>/g; s/^/$KUBECTL_AI_CONTENT_KIND: /"`}
+	got, err := filter.Filter(context.Background(), ContentKindUserInput, "why is customer-42 failing?")
+	if err != nil {
+		t.Fatalf("Filter() error = %v", err)
+	}
+	if want := "user_input: why is <customer> failing?"; got != want {
+		t.Errorf("Filter() = %q, want %q", got, want)
+	}
+
+	failing := &CommandFilter{Command: "echo denied >&2; exit 1"}
+	if _, err := failing.Filter(context.Background(), ContentKindUserInput, "text"); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("expected the error of the command, got %v", err)
+	}
+}
+
+type replaceFilter struct{ old, new string }
+
+func (f replaceFilter) Filter(ctx context.Context, kind ContentKind, content string) (string, error) {
+	return strings.ReplaceAll(content, f.old, f.new), nil
+}
+
+func TestFilterOutput(t *testing.T) {
+	filters := []ContentFilter{replaceFilter{"10.0.0.7", "<pod-ip>"}, replaceFilter{"<pod-ip>", "<ip>"}}
+
+	result := &sandbox.ExecResult{Command: "kubectl get pods -o wide", Stdout: "web 10.0.0.7", Stderr: "warning for 10.0.0.7", Error: "exit status 1 from 10.0.0.7"}
+	out, err := FilterOutput(context.Background(), filters, result)
+	if err != nil {
+		t.Fatalf("FilterOutput() error = %v", err)
+	}
+	got := out.(*sandbox.ExecResult)
+	if got.Stdout != "web <ip>" || got.Stderr != "warning for <ip>" || got.Error != "exit status 1 from <ip>" {
+		t.Errorf("expected the filters to be applied in order, got %+v", got)
+	}
+	if got.Command != result.Command || result.Stdout != "web 10.0.0.7" {
+		t.Errorf("expected only a copy of the output to be filtered, got %+v", got)
+	}
+
+	out, err = FilterOutput(context.Background(), filters, map[string]any{"pods": []any{"10.0.0.7"}, "count": 1})
+	if err != nil {
+		t.Fatalf("FilterOutput() error = %v", err)
+	}
+	m := out.(map[string]any)
+	if m["pods"].([]any)[0] != "<ip>" || m["count"] != 1 {
+		t.Errorf("expected the strings of the map to be filtered, got %v", m)
+	}
+
+	type podList struct {
+		Pods []string `json:"pods"`
+	}
+	out, err = FilterOutput(context.Background(), filters, &podList{Pods: []string{"10.0.0.7"}})
+	if err != nil {
+		t.Fatalf("FilterOutput() error = %v", err)
+	}
+	if m, ok := out.(map[string]any); !ok || m["pods"].([]any)[0] != "<ip>" {
+		t.Errorf("expected the strings of the struct to be filtered, got %v", out)
+	}
+}
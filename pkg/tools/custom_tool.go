@@ -18,7 +18,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
@@ -32,11 +34,30 @@ type CustomToolConfig struct {
 	Command       string `yaml:"command"`
 	CommandDesc   string `yaml:"command_desc"`
 	IsInteractive bool   `yaml:"is_interactive"`
+
+	// Parameters and Template define a tool that runs a fixed shell command,
+	// instead of a command written by the LLM. The LLM fills in the
+	// parameters, which are quoted and substituted into the template, e.g.
+	// "flux reconcile kustomization {{.name}} -n {{.namespace}}".
+	Parameters []CustomToolParameter `yaml:"parameters"`
+	Template   string                `yaml:"template"`
+	// ModifiesResource is "yes", "no" or "unknown" (the default), whether
+	// running the tool modifies resources.
+	ModifiesResource string `yaml:"modifies_resource"`
+}
+
+// CustomToolParameter is a parameter of a templated custom tool.
+type CustomToolParameter struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"` // string (the default), integer, number or boolean
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
 }
 
 // CustomTool implements the Tool interface for external commands.
 type CustomTool struct {
 	config   CustomToolConfig
+	template *template.Template
 	executor sandbox.Executor
 }
 
@@ -45,11 +66,45 @@ func NewCustomTool(config CustomToolConfig) (*CustomTool, error) {
 	if config.Name == "" {
 		return nil, fmt.Errorf("custom tool name cannot be empty")
 	}
-	if len(config.Command) == 0 {
+	if len(config.Command) == 0 && config.Template == "" {
 		return nil, fmt.Errorf("custom tool command cannot be empty for tool %q", config.Name)
 	}
+	switch config.ModifiesResource {
+	case "":
+		config.ModifiesResource = "unknown"
+	case "yes", "no", "unknown":
+	default:
+		return nil, fmt.Errorf("invalid modifies_resource %q for tool %q, expected yes, no or unknown", config.ModifiesResource, config.Name)
+	}
 
-	return &CustomTool{config: config}, nil
+	tool := &CustomTool{config: config}
+	if config.Template == "" {
+		if len(config.Parameters) > 0 {
+			return nil, fmt.Errorf("custom tool %q has parameters but no template", config.Name)
+		}
+		return tool, nil
+	}
+
+	for _, param := range config.Parameters {
+		if param.Name == "" {
+			return nil, fmt.Errorf("custom tool %q has a parameter without a name", config.Name)
+		}
+		switch param.Type {
+		case "", "string", "integer", "number", "boolean":
+		default:
+			return nil, fmt.Errorf("invalid type %q for parameter %q of tool %q", param.Type, param.Name, config.Name)
+		}
+	}
+	tmpl, err := template.New(config.Name).Option("missingkey=error").Parse(config.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template of tool %q: %w", config.Name, err)
+	}
+	tool.template = tmpl
+	// Catch references to parameters that don't exist now, rather than when the tool is called
+	if _, err := tool.renderCommand(map[string]any{}); err != nil {
+		return nil, fmt.Errorf("checking template of tool %q: %w", config.Name, err)
+	}
+	return tool, nil
 }
 
 // Name returns the tool's name.
@@ -64,6 +119,31 @@ func (t *CustomTool) Description() string {
 
 // FunctionDefinition returns the tool's function definition.
 func (t *CustomTool) FunctionDefinition() *gollm.FunctionDefinition {
+	if t.template != nil {
+		schema := &gollm.Schema{
+			Type:       gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{},
+		}
+		for _, param := range t.config.Parameters {
+			paramType := gollm.TypeString
+			if param.Type != "" {
+				paramType = gollm.SchemaType(param.Type)
+			}
+			schema.Properties[param.Name] = &gollm.Schema{
+				Type:        paramType,
+				Description: param.Description,
+			}
+			if param.Required {
+				schema.Required = append(schema.Required, param.Name)
+			}
+		}
+		return &gollm.FunctionDefinition{
+			Name:        t.Name(),
+			Description: t.Description(),
+			Parameters:  schema,
+		}
+	}
+
 	return &gollm.FunctionDefinition{
 		Name:        t.Name(),
 		Description: t.Description(),
@@ -123,18 +203,105 @@ func (t *CustomTool) addCommandPrefix(inputCmd string) (string, error) {
 	return t.config.Command + " " + inputCmd, nil
 }
 
+// quotedValue is a string parameter value, which is shell-quoted when it is
+// printed by a template, so it can't change the structure of the command.
+// Tests like {{if .name}} still see the value itself, so they are false for
+// an empty string.
+type quotedValue string
+
+func (v quotedValue) String() string {
+	quoted, _ := shellQuote(string(v)) // Checked by templateValue
+	return quoted
+}
+
+// numberValue is a number parameter value, printed without an exponent.
+type numberValue float64
+
+func (v numberValue) String() string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 64)
+}
+
+// templateValue returns the value of a parameter passed to the template. It
+// keeps the type of the value, so that {{if}} is false for false, 0 and "".
+func templateValue(param CustomToolParameter, v any) (any, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return numberValue(v), nil
+	case string:
+		if param.Type == "boolean" {
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+		if _, err := shellQuote(v); err != nil {
+			return nil, err
+		}
+		return quotedValue(v), nil
+	default:
+		return templateValue(param, fmt.Sprint(v))
+	}
+}
+
+// renderCommand substitutes args into the tool's template. String values are
+// shell-quoted when they are printed, so they can't change the structure of
+// the command; parameters that are not set are empty, so the template can
+// test them with {{if}}.
+func (t *CustomTool) renderCommand(args map[string]any) (string, error) {
+	values := make(map[string]any, len(t.config.Parameters))
+	for _, param := range t.config.Parameters {
+		v, ok := args[param.Name]
+		if !ok || v == nil {
+			values[param.Name] = ""
+			continue
+		}
+		value, err := templateValue(param, v)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for parameter %q: %w", param.Name, err)
+		}
+		values[param.Name] = value
+	}
+
+	var sb strings.Builder
+	if err := t.template.Execute(&sb, values); err != nil {
+		return "", fmt.Errorf("rendering command: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// buildCommand returns the command a templated tool runs for args.
+func (t *CustomTool) buildCommand(args map[string]any) (string, error) {
+	if t.template == nil {
+		return "", fmt.Errorf("tool %q has no template", t.Name())
+	}
+	return t.renderCommand(args)
+}
+
 // Run executes the external command defined for the custom tool.
 func (t *CustomTool) Run(ctx context.Context, args map[string]any) (any, error) {
 	var command string
-	cmdVal, ok := args["command"]
-	if !ok {
-		return nil, fmt.Errorf("command not found in args")
-	}
-	command = cmdVal.(string)
-
-	command, err := t.addCommandPrefix(command)
-	if err != nil {
-		return nil, fmt.Errorf("failed to process command: %w", err)
+	if t.template != nil {
+		for _, param := range t.config.Parameters {
+			if param.Required && args[param.Name] == nil {
+				return nil, fmt.Errorf("missing required parameter %q", param.Name)
+			}
+		}
+		rendered, err := t.renderCommand(args)
+		if err != nil {
+			return nil, err
+		}
+		command = rendered
+	} else {
+		cmdVal, ok := args["command"]
+		if !ok {
+			return nil, fmt.Errorf("command not found in args")
+		}
+		prefixed, err := t.addCommandPrefix(cmdVal.(string))
+		if err != nil {
+			return nil, fmt.Errorf("failed to process command: %w", err)
+		}
+		command = prefixed
 	}
 
 	workDir := ctx.Value(WorkDirKey).(string)
@@ -152,11 +319,10 @@ func (t *CustomTool) Run(ctx context.Context, args map[string]any) (any, error)
 
 // CheckModifiesResource determines if the command modifies resources
 // For custom tools, we'll conservatively assume they might modify resources
-// unless we have specific knowledge otherwise
+// unless the tool's config says otherwise
 // Returns "yes", "no", or "unknown"
 func (t *CustomTool) CheckModifiesResource(args map[string]any) string {
-	// For custom tools, we'll conservatively use "unknown" since we can't
-	return "unknown"
+	return t.config.ModifiesResource
 }
 
 // CloneWithExecutor creates a copy of the CustomTool with the given executor.
@@ -164,6 +330,7 @@ func (t *CustomTool) CheckModifiesResource(args map[string]any) string {
 func (t *CustomTool) CloneWithExecutor(executor sandbox.Executor) *CustomTool {
 	return &CustomTool{
 		config:   t.config,
+		template: t.template,
 		executor: executor,
 	}
 }
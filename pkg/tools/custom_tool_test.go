@@ -16,6 +16,7 @@ package tools
 
 import (
 	"context"
+	"os"
 	"strings"
 	"testing"
 
@@ -121,6 +122,8 @@ type MockExecutor struct {
 	CapturedCommand string
 	CapturedEnv     []string
 	CapturedWorkDir string
+	// Uploaded maps the remote paths of uploaded files to their content.
+	Uploaded map[string]string
 }
 
 func (m *MockExecutor) Execute(ctx context.Context, command string, env []string, workDir string) (*sandbox.ExecResult, error) {
@@ -130,6 +133,22 @@ func (m *MockExecutor) Execute(ctx context.Context, command string, env []string
 	return &sandbox.ExecResult{Stdout: "executed"}, nil
 }
 
+func (m *MockExecutor) Upload(ctx context.Context, localPath string, remotePath string) error {
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	if m.Uploaded == nil {
+		m.Uploaded = map[string]string{}
+	}
+	m.Uploaded[remotePath] = string(content)
+	return nil
+}
+
+func (m *MockExecutor) Download(ctx context.Context, remotePath string, localPath string) error {
+	return nil
+}
+
 func (m *MockExecutor) Close(ctx context.Context) error {
 	return nil
 }
@@ -176,3 +195,163 @@ func TestCustomTool_CloneWithExecutor(t *testing.T) {
 		t.Errorf("expected workdir '/tmp', got %q", mockExec.CapturedWorkDir)
 	}
 }
+
+func TestCustomTool_Template(t *testing.T) {
+	config := CustomToolConfig{
+		Name:        "flux_reconcile",
+		Description: "Reconcile a Flux kustomization",
+		Parameters: []CustomToolParameter{
+			{Name: "name", Description: "Name of the kustomization", Required: true},
+			{Name: "namespace", Description: "Namespace of the kustomization"},
+		},
+		Template:         "flux reconcile kustomization {{.name}}{{if .namespace}} -n {{.namespace}}{{end}}",
+		ModifiesResource: "yes",
+	}
+	tool, err := NewCustomTool(config)
+	if err != nil {
+		t.Fatalf("failed to create tool: %v", err)
+	}
+
+	def := tool.FunctionDefinition()
+	if _, ok := def.Parameters.Properties["name"]; !ok || len(def.Parameters.Required) != 1 || def.Parameters.Required[0] != "name" {
+		t.Errorf("unexpected parameters %+v", def.Parameters)
+	}
+	if got := tool.CheckModifiesResource(nil); got != "yes" {
+		t.Errorf("expected CheckModifiesResource to return yes, got %q", got)
+	}
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "all parameters",
+			args: map[string]any{"name": "podinfo", "namespace": "flux-system"},
+			want: "flux reconcile kustomization podinfo -n flux-system",
+		},
+		{
+			name: "optional parameter not set",
+			args: map[string]any{"name": "podinfo"},
+			want: "flux reconcile kustomization podinfo",
+		},
+		{
+			name: "values are quoted",
+			args: map[string]any{"name": "podinfo; rm -rf /"},
+			want: "flux reconcile kustomization 'podinfo; rm -rf /'",
+		},
+		{
+			name:    "required parameter not set",
+			args:    map[string]any{"namespace": "flux-system"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExec := &MockExecutor{}
+			ctx := context.WithValue(context.Background(), WorkDirKey, "/tmp")
+			_, err := tool.CloneWithExecutor(mockExec).Run(ctx, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tool run failed: %v", err)
+			}
+			if mockExec.CapturedCommand != tt.want {
+				t.Errorf("expected command %q, got %q", tt.want, mockExec.CapturedCommand)
+			}
+		})
+	}
+}
+
+func TestCustomTool_TemplateEmptyValues(t *testing.T) {
+	config := CustomToolConfig{
+		Name: "kubectl_apply",
+		Parameters: []CustomToolParameter{
+			{Name: "file", Required: true},
+			{Name: "namespace"},
+			{Name: "dry_run", Type: "boolean"},
+			{Name: "timeout", Type: "integer"},
+		},
+		Template: "kubectl apply -f {{.file}}{{if .namespace}} -n {{.namespace}}{{end}}{{if .dry_run}} --dry-run=server{{end}}{{if .timeout}} --timeout={{.timeout}}s{{end}}",
+	}
+	tool, err := NewCustomTool(config)
+	if err != nil {
+		t.Fatalf("failed to create tool: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{
+			name: "false boolean and empty string",
+			args: map[string]any{"file": "web.yaml", "namespace": "", "dry_run": false},
+			want: "kubectl apply -f web.yaml",
+		},
+		{
+			name: "boolean given as a string",
+			args: map[string]any{"file": "web.yaml", "dry_run": "false"},
+			want: "kubectl apply -f web.yaml",
+		},
+		{
+			name: "zero number",
+			args: map[string]any{"file": "web.yaml", "timeout": float64(0)},
+			want: "kubectl apply -f web.yaml",
+		},
+		{
+			name: "all set",
+			args: map[string]any{"file": "web app.yaml", "namespace": "prod", "dry_run": true, "timeout": float64(1e6)},
+			want: "kubectl apply -f 'web app.yaml' -n prod --dry-run=server --timeout=1000000s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tool.buildCommand(tt.args)
+			if err != nil {
+				t.Fatalf("buildCommand failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected command %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewCustomTool_InvalidTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config CustomToolConfig
+	}{
+		{
+			name:   "unknown parameter",
+			config: CustomToolConfig{Name: "t", Template: "velero backup create {{.backup}}"},
+		},
+		{
+			name:   "invalid syntax",
+			config: CustomToolConfig{Name: "t", Template: "velero backup create {{.name"},
+		},
+		{
+			name: "invalid parameter type",
+			config: CustomToolConfig{Name: "t", Template: "echo {{.n}}", Parameters: []CustomToolParameter{
+				{Name: "n", Type: "list"},
+			}},
+		},
+		{
+			name:   "invalid modifies_resource",
+			config: CustomToolConfig{Name: "t", Command: "velero", ModifiesResource: "maybe"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCustomTool(tt.config); err == nil {
+				t.Errorf("expected error but got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// EditResourceTool patches a single resource. It implements Previewer, so the
+// user is shown the diff of a server-side dry-run of the patch before
+// approving it, and its result records the patch, the diff and the outcome.
+type EditResourceTool struct {
+	executor sandbox.Executor
+}
+
+func NewEditResourceTool(executor sandbox.Executor) *EditResourceTool {
+	return &EditResourceTool{executor: executor}
+}
+
+func (t *EditResourceTool) Name() string {
+	return "edit_resource"
+}
+
+func (t *EditResourceTool) Description() string {
+	return `Edits an existing Kubernetes resource with a patch. The user is shown a diff of the change, validated by the API server with a dry-run, before it is applied. Prefer this tool over kubectl patch, edit, set or scale for changing a few fields of a resource.`
+}
+
+func (t *EditResourceTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"resource": {
+					Type:        gollm.TypeString,
+					Description: `The type of the resource, e.g. deployment or configmap.`,
+				},
+				"name": {
+					Type:        gollm.TypeString,
+					Description: `The name of the resource.`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the resource. Omit for cluster-scoped resources or the current namespace.`,
+				},
+				"patch": {
+					Type:        gollm.TypeString,
+					Description: `The patch, as JSON. For example {"spec":{"replicas":3}} for a strategic or merge patch, or [{"op":"replace","path":"/spec/replicas","value":3}] for a JSON patch.`,
+				},
+				"patch_type": {
+					Type:        gollm.TypeString,
+					Description: `The type of patch: strategic (the default, merges lists like containers by name), merge or json.`,
+				},
+			},
+			Required: []string{"resource", "name", "patch"},
+		},
+	}
+}
+
+// buildCommand returns the kubectl patch command for the edit.
+func (t *EditResourceTool) buildCommand(args map[string]any) (string, error) {
+	resource, _ := args["resource"].(string)
+	name, _ := args["name"].(string)
+	patch, _ := args["patch"].(string)
+	if resource == "" || name == "" {
+		return "", fmt.Errorf("resource and name are required")
+	}
+
+	patchType, _ := args["patch_type"].(string)
+	switch patchType {
+	case "":
+		patchType = "strategic"
+	case "strategic", "merge", "json":
+	default:
+		return "", fmt.Errorf("invalid patch_type %q, expected strategic, merge or json", patchType)
+	}
+	if err := validatePatch(patch, patchType); err != nil {
+		return "", err
+	}
+
+	command := "kubectl patch"
+	for _, word := range []string{resource, name} {
+		quoted, err := shellQuote(word)
+		if err != nil {
+			return "", err
+		}
+		command += " " + quoted
+	}
+	if namespace, _ := args["namespace"].(string); namespace != "" {
+		quoted, err := shellQuote(namespace)
+		if err != nil {
+			return "", err
+		}
+		command += " --namespace " + quoted
+	}
+	quoted, err := shellQuote(patch)
+	if err != nil {
+		return "", err
+	}
+	return command + " --type " + patchType + " --patch " + quoted, nil
+}
+
+// validatePatch checks that patch is JSON of the right shape for patchType.
+func validatePatch(patch string, patchType string) error {
+	var v any
+	if err := json.Unmarshal([]byte(patch), &v); err != nil {
+		return fmt.Errorf("patch is not valid JSON: %w", err)
+	}
+	switch v.(type) {
+	case []any:
+		if patchType != "json" {
+			return fmt.Errorf("a %s patch must be a JSON object, JSON patches (lists of operations) need patch_type json", patchType)
+		}
+	case map[string]any:
+		if patchType == "json" {
+			return fmt.Errorf("a json patch must be a list of operations")
+		}
+	default:
+		return fmt.Errorf("patch must be a JSON object or list of operations")
+	}
+	return nil
+}
+
+// Preview returns the diff of a server-side dry-run of the patch.
+func (t *EditResourceTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	command, err := t.buildCommand(args)
+	if err != nil {
+		return "", err
+	}
+	return t.diff(ctx, command)
+}
+
+// diff runs the patch command as a server-side dry-run, and diffs the result
+// against the live resource.
+func (t *EditResourceTool) diff(ctx context.Context, command string) (string, error) {
+	patched, err := runKubectl(ctx, t.executor, command+" --dry-run=server -o yaml")
+	if err != nil {
+		return "", err
+	}
+	if patched.ExitCode != 0 || patched.Error != "" {
+		return "", fmt.Errorf("dry-run of the patch failed: %s", strings.TrimSpace(patched.Stderr+" "+patched.Error))
+	}
+
+	result, err := runKubectl(sandbox.WithStdin(ctx, strings.NewReader(patched.Stdout)), t.executor, "kubectl diff -f -")
+	if err != nil {
+		return "", err
+	}
+	// kubectl diff exits with 1 if there are differences, and >1 on errors.
+	switch result.ExitCode {
+	case 0:
+		return "No changes: the patch doesn't change the resource.", nil
+	case 1:
+		return result.Stdout, nil
+	default:
+		return "", fmt.Errorf("kubectl diff failed: %s", strings.TrimSpace(result.Stderr))
+	}
+}
+
+func (t *EditResourceTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	command, err := t.buildCommand(args)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	patchType, _ := args["patch_type"].(string)
+	if patchType == "" {
+		patchType = "strategic"
+	}
+	record := map[string]any{
+		"command":    command,
+		"patch":      args["patch"],
+		"patch_type": patchType,
+	}
+
+	// Diff again, the resource may have changed since the preview
+	diff, err := t.diff(ctx, command)
+	if err != nil {
+		record["error"] = err.Error()
+		record["outcome"] = "not applied"
+		return record, nil
+	}
+	record["diff"] = diff
+
+	result, err := runKubectl(ctx, t.executor, command)
+	if err != nil {
+		return nil, err
+	}
+	record["stdout"] = result.Stdout
+	record["stderr"] = result.Stderr
+	if result.ExitCode != 0 || result.Error != "" {
+		record["error"] = strings.TrimSpace(result.Error + " " + result.Stderr)
+		record["outcome"] = "failed"
+	} else {
+		record["outcome"] = "applied"
+	}
+	return record, nil
+}
+
+func (t *EditResourceTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *EditResourceTool) CheckModifiesResource(args map[string]any) string {
+	return "yes"
+}
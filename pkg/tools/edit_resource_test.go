@@ -0,0 +1,138 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+func TestEditResourceTool_BuildCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        map[string]any
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "strategic patch by default",
+			args:     map[string]any{"resource": "deployment", "name": "web", "namespace": "prod", "patch": `{"spec":{"replicas":3}}`},
+			expected: `kubectl patch deployment web --namespace prod --type strategic --patch '{"spec":{"replicas":3}}'`,
+		},
+		{
+			name:     "json patch",
+			args:     map[string]any{"resource": "deployment", "name": "web", "patch": `[{"op":"replace","path":"/spec/replicas","value":3}]`, "patch_type": "json"},
+			expected: `kubectl patch deployment web --type json --patch '[{"op":"replace","path":"/spec/replicas","value":3}]'`,
+		},
+		{
+			name:        "invalid JSON",
+			args:        map[string]any{"resource": "deployment", "name": "web", "patch": `{"spec":`},
+			expectError: true,
+		},
+		{
+			name:        "operations without json type",
+			args:        map[string]any{"resource": "deployment", "name": "web", "patch": `[{"op":"remove","path":"/spec/paused"}]`},
+			expectError: true,
+		},
+		{
+			name:        "unknown patch type",
+			args:        map[string]any{"resource": "deployment", "name": "web", "patch": `{}`, "patch_type": "apply"},
+			expectError: true,
+		},
+	}
+
+	tool := NewEditResourceTool(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tool.buildCommand(tt.args)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error, got command %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// scriptedExecutor answers commands with the result of run, recording them.
+type scriptedExecutor struct {
+	MockExecutor
+	commands []string
+	run      func(command string) *sandbox.ExecResult
+}
+
+func (e *scriptedExecutor) Execute(ctx context.Context, command string, env []string, workDir string) (*sandbox.ExecResult, error) {
+	e.commands = append(e.commands, command)
+	return e.run(command), nil
+}
+
+func TestEditResourceTool_Run(t *testing.T) {
+	executor := &scriptedExecutor{run: func(command string) *sandbox.ExecResult {
+		switch {
+		case strings.HasSuffix(command, "--dry-run=server -o yaml"):
+			return &sandbox.ExecResult{Stdout: "kind: Deployment\nspec:\n  replicas: 3\n"}
+		case command == "kubectl diff -f -":
+			return &sandbox.ExecResult{Stdout: "-  replicas: 1\n+  replicas: 3\n", ExitCode: 1}
+		default:
+			return &sandbox.ExecResult{Stdout: "deployment.apps/web patched\n"}
+		}
+	}}
+	tool := NewEditResourceTool(executor)
+	args := map[string]any{"resource": "deployment", "name": "web", "patch": `{"spec":{"replicas":3}}`}
+
+	preview, err := tool.Preview(context.Background(), args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(preview, "+  replicas: 3") {
+		t.Errorf("expected the diff as preview, got %q", preview)
+	}
+
+	res, err := tool.Run(context.Background(), args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	record := res.(map[string]any)
+	if record["outcome"] != "applied" || record["diff"] != preview || record["patch"] != args["patch"] {
+		t.Errorf("expected the patch, diff and outcome to be recorded, got %v", record)
+	}
+	if last := executor.commands[len(executor.commands)-1]; strings.Contains(last, "--dry-run") {
+		t.Errorf("expected the patch to be applied, last command was %q", last)
+	}
+
+	// A patch rejected by the dry-run is not applied
+	executor.commands = nil
+	executor.run = func(command string) *sandbox.ExecResult {
+		return &sandbox.ExecResult{Stderr: "admission webhook denied the request", ExitCode: 1}
+	}
+	res, _ = tool.Run(context.Background(), args)
+	record = res.(map[string]any)
+	if record["outcome"] != "not applied" || !strings.Contains(record["error"].(string), "admission webhook denied") {
+		t.Errorf("expected the rejection to be recorded, got %v", record)
+	}
+	if len(executor.commands) != 1 {
+		t.Errorf("expected only the dry-run to be run, got %v", executor.commands)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// GetEventsTool lists Kubernetes events, most recent last.
+type GetEventsTool struct {
+	executor sandbox.Executor
+}
+
+func NewGetEventsTool(executor sandbox.Executor) *GetEventsTool {
+	return &GetEventsTool{executor: executor}
+}
+
+func (t *GetEventsTool) Name() string {
+	return "get_events"
+}
+
+func (t *GetEventsTool) Description() string {
+	return `Lists Kubernetes events, sorted with the most recent last. Use it to find out why a resource is failing, e.g. scheduling failures, image pull errors or failing probes. Prefer this tool over "kubectl get events".`
+}
+
+func (t *GetEventsTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `Namespace to list events in. Defaults to the current namespace.`,
+				},
+				"all_namespaces": {
+					Type:        gollm.TypeBoolean,
+					Description: `List events in all namespaces.`,
+				},
+				"kind": {
+					Type:        gollm.TypeString,
+					Description: `Only list events about resources of this kind, e.g. Pod or Deployment.`,
+				},
+				"name": {
+					Type:        gollm.TypeString,
+					Description: `Only list events about the resource with this name.`,
+				},
+				"warnings_only": {
+					Type:        gollm.TypeBoolean,
+					Description: `Only list Warning events.`,
+				},
+			},
+		},
+	}
+}
+
+// buildCommand returns the kubectl command listing the events asked for.
+func (t *GetEventsTool) buildCommand(args map[string]any) (string, error) {
+	command := "kubectl get events --sort-by=.lastTimestamp"
+	if allNamespaces, _ := args["all_namespaces"].(bool); allNamespaces {
+		command += " --all-namespaces"
+	} else if namespace, _ := args["namespace"].(string); namespace != "" {
+		quoted, err := shellQuote(namespace)
+		if err != nil {
+			return "", err
+		}
+		command += " --namespace " + quoted
+	}
+
+	var selectors []string
+	if kind, _ := args["kind"].(string); kind != "" {
+		selectors = append(selectors, "involvedObject.kind="+kind)
+	}
+	if name, _ := args["name"].(string); name != "" {
+		selectors = append(selectors, "involvedObject.name="+name)
+	}
+	if warningsOnly, _ := args["warnings_only"].(bool); warningsOnly {
+		selectors = append(selectors, "type=Warning")
+	}
+	if len(selectors) > 0 {
+		quoted, err := shellQuote(strings.Join(selectors, ","))
+		if err != nil {
+			return "", err
+		}
+		command += " --field-selector " + quoted
+	}
+	return command, nil
+}
+
+func (t *GetEventsTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	command, err := t.buildCommand(args)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	return runKubectl(ctx, t.executor, command)
+}
+
+func (t *GetEventsTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *GetEventsTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// TruncationPolicy keeps the most recent events.
+func (t *GetEventsTool) TruncationPolicy() TruncationPolicy {
+	return TruncateTail
+}
+
+// runKubectl runs a kubectl command built by a tool, with the kubeconfig and
+// working directory of the tool call.
+func runKubectl(ctx context.Context, executor sandbox.Executor, command string) (*sandbox.ExecResult, error) {
+	kubeconfig, _ := ctx.Value(KubeconfigKey).(string)
+	workDir, _ := ctx.Value(WorkDirKey).(string)
+	env, err := kubectlEnv(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return executor.Execute(ctx, command, env, workDir)
+}
+
+// shellQuote quotes s to be used as a single word in a shell command.
+func shellQuote(s string) (string, error) {
+	quoted, err := syntax.Quote(s, syntax.LangBash)
+	if err != nil {
+		return "", fmt.Errorf("invalid value %q: %w", s, err)
+	}
+	return quoted, nil
+}
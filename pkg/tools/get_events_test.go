@@ -0,0 +1,61 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"testing"
+)
+
+func TestGetEventsTool_BuildCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]any
+		expected string
+	}{
+		{
+			name:     "no filters",
+			args:     map[string]any{},
+			expected: "kubectl get events --sort-by=.lastTimestamp",
+		},
+		{
+			name:     "all namespaces wins over namespace",
+			args:     map[string]any{"namespace": "prod", "all_namespaces": true},
+			expected: "kubectl get events --sort-by=.lastTimestamp --all-namespaces",
+		},
+		{
+			name:     "warnings for an object",
+			args:     map[string]any{"namespace": "prod", "kind": "Pod", "name": "web-0", "warnings_only": true},
+			expected: "kubectl get events --sort-by=.lastTimestamp --namespace prod --field-selector 'involvedObject.kind=Pod,involvedObject.name=web-0,type=Warning'",
+		},
+		{
+			name:     "values can't inject commands",
+			args:     map[string]any{"name": "web-0 && kubectl delete ns prod"},
+			expected: "kubectl get events --sort-by=.lastTimestamp --field-selector 'involvedObject.name=web-0 && kubectl delete ns prod'",
+		},
+	}
+
+	tool := NewGetEventsTool(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tool.buildCommand(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// defaultLogTailLines is the number of log lines returned if no tail is given.
+const defaultLogTailLines = 200
+
+// sincePattern matches the durations accepted by kubectl logs --since, e.g. 1h30m.
+var sincePattern = regexp.MustCompile(`^([0-9]+[smh])+$`)
+
+// GetLogsTool fetches the logs of a pod, or of the pods matching a selector.
+type GetLogsTool struct {
+	executor sandbox.Executor
+}
+
+func NewGetLogsTool(executor sandbox.Executor) *GetLogsTool {
+	return &GetLogsTool{executor: executor}
+}
+
+func (t *GetLogsTool) Name() string {
+	return "get_logs"
+}
+
+func (t *GetLogsTool) Description() string {
+	return `Fetches the logs of a pod, or of all the pods matching a label selector. Use "previous" to see why a crashing container exited. Prefer this tool over "kubectl logs".`
+}
+
+func (t *GetLogsTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"pod": {
+					Type:        gollm.TypeString,
+					Description: `Name of the pod. Either pod or selector is required.`,
+				},
+				"selector": {
+					Type:        gollm.TypeString,
+					Description: `Label selector of the pods, e.g. app=nginx. Either pod or selector is required.`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `Namespace of the pods. Defaults to the current namespace.`,
+				},
+				"container": {
+					Type:        gollm.TypeString,
+					Description: `Container to fetch the logs of. Defaults to all containers.`,
+				},
+				"previous": {
+					Type:        gollm.TypeBoolean,
+					Description: `Fetch the logs of the previous instance of the container, e.g. before it crashed.`,
+				},
+				"since": {
+					Type:        gollm.TypeString,
+					Description: `Only return logs newer than this duration, e.g. 10m or 1h.`,
+				},
+				"tail": {
+					Type:        gollm.TypeInteger,
+					Description: fmt.Sprintf(`Number of most recent lines to return. Defaults to %d.`, defaultLogTailLines),
+				},
+			},
+		},
+	}
+}
+
+// buildCommand returns the kubectl command fetching the logs asked for.
+func (t *GetLogsTool) buildCommand(args map[string]any) (string, error) {
+	command := "kubectl logs"
+
+	pod, _ := args["pod"].(string)
+	selector, _ := args["selector"].(string)
+	switch {
+	case pod != "" && selector != "":
+		return "", fmt.Errorf("pod and selector can't both be set")
+	case pod != "":
+		quoted, err := shellQuote(pod)
+		if err != nil {
+			return "", err
+		}
+		command += " " + quoted
+	case selector != "":
+		quoted, err := shellQuote(selector)
+		if err != nil {
+			return "", err
+		}
+		// Prefix lines with the pod they come from
+		command += " --selector " + quoted + " --prefix"
+	default:
+		return "", fmt.Errorf("either pod or selector is required")
+	}
+
+	if namespace, _ := args["namespace"].(string); namespace != "" {
+		quoted, err := shellQuote(namespace)
+		if err != nil {
+			return "", err
+		}
+		command += " --namespace " + quoted
+	}
+	if container, _ := args["container"].(string); container != "" {
+		quoted, err := shellQuote(container)
+		if err != nil {
+			return "", err
+		}
+		command += " --container " + quoted
+	} else {
+		command += " --all-containers"
+	}
+	if previous, _ := args["previous"].(bool); previous {
+		command += " --previous"
+	}
+	if since, _ := args["since"].(string); since != "" {
+		if !sincePattern.MatchString(since) {
+			return "", fmt.Errorf("invalid since %q, expected a duration like 10m or 1h", since)
+		}
+		command += " --since=" + since
+	}
+
+	tail := defaultLogTailLines
+	if v, ok := args["tail"].(float64); ok && v > 0 {
+		tail = int(v)
+	}
+	command += fmt.Sprintf(" --tail=%d", tail)
+	return command, nil
+}
+
+func (t *GetLogsTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	command, err := t.buildCommand(args)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	return runKubectl(ctx, t.executor, command)
+}
+
+func (t *GetLogsTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *GetLogsTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// TruncationPolicy keeps the most recent log lines.
+func (t *GetLogsTool) TruncationPolicy() TruncationPolicy {
+	return TruncateTail
+}
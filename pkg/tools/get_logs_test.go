@@ -0,0 +1,83 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"testing"
+)
+
+func TestGetLogsTool_BuildCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        map[string]any
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "pod with defaults",
+			args:     map[string]any{"pod": "web-0"},
+			expected: "kubectl logs web-0 --all-containers --tail=200",
+		},
+		{
+			name:     "pod with all options",
+			args:     map[string]any{"pod": "web-0", "namespace": "prod", "container": "app", "previous": true, "since": "1h30m", "tail": float64(50)},
+			expected: "kubectl logs web-0 --namespace prod --container app --previous --since=1h30m --tail=50",
+		},
+		{
+			name:     "selector is quoted and prefixed",
+			args:     map[string]any{"selector": "app=web,tier!=cache"},
+			expected: "kubectl logs --selector 'app=web,tier!=cache' --prefix --all-containers --tail=200",
+		},
+		{
+			name:     "values can't inject commands",
+			args:     map[string]any{"pod": "web-0; rm -rf /"},
+			expected: "kubectl logs 'web-0; rm -rf /' --all-containers --tail=200",
+		},
+		{
+			name:        "pod and selector",
+			args:        map[string]any{"pod": "web-0", "selector": "app=web"},
+			expectError: true,
+		},
+		{
+			name:        "neither pod nor selector",
+			args:        map[string]any{},
+			expectError: true,
+		},
+		{
+			name:        "invalid since",
+			args:        map[string]any{"pod": "web-0", "since": "yesterday"},
+			expectError: true,
+		},
+	}
+
+	tool := NewGetLogsTool(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tool.buildCommand(tt.args)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error, got command %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
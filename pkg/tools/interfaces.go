@@ -46,3 +46,28 @@ type Tool interface {
 	// Returns "yes", "no", or "unknown"
 	CheckModifiesResource(args map[string]any) string
 }
+
+// Previewer is implemented by tools that can describe the effect of an
+// invocation before it runs, e.g. with a diff. The preview is shown to the
+// user when asking for permission to run the tool.
+type Previewer interface {
+	Preview(ctx context.Context, args map[string]any) (string, error)
+}
+
+// TruncationPolicy is how long outputs of a tool are cut down before they
+// are sent to the LLM, see OutputTruncator.
+type TruncationPolicy int
+
+const (
+	// TruncateHeadAndTail keeps the first and last lines. This is the default.
+	TruncateHeadAndTail TruncationPolicy = iota
+	// TruncateTail keeps the last lines, for outputs where the most recent
+	// entries matter most, such as logs.
+	TruncateTail
+)
+
+// OutputPolicy is implemented by tools whose long outputs are truncated with
+// a policy other than TruncateHeadAndTail.
+type OutputPolicy interface {
+	TruncationPolicy() TruncationPolicy
+}
@@ -50,6 +50,18 @@ var (
 		},
 	}
 
+	// kubectlValueFlags are the flags whose value can follow them as a separate
+	// argument, which mustn't be taken for the verb.
+	kubectlValueFlags = map[string]bool{
+		"-n": true, "--namespace": true, "--context": true, "--kubeconfig": true,
+		"--cluster": true, "--user": true, "-s": true, "--server": true,
+		"--token": true, "--as": true, "--as-group": true, "--as-uid": true,
+		"--request-timeout": true, "--cache-dir": true, "--certificate-authority": true,
+		"--client-certificate": true, "--client-key": true, "--tls-server-name": true,
+		"-v": true, "--v": true, "--vmodule": true, "--log-file": true,
+		"-l": true, "--selector": true, "--field-selector": true, "-o": true, "--output": true,
+	}
+
 	writeSubOps = map[string]map[string]bool{
 		"rollout": {
 			"pause":   true,
@@ -126,18 +138,7 @@ func analyzeCall(call *syntax.CallExpr) string {
 	}
 
 	// Extract command and arguments
-	var args []string
-	for _, arg := range call.Args {
-		lit := arg.Lit()
-		if lit == "" {
-			var sb strings.Builder
-			syntax.NewPrinter().Print(&sb, arg)
-			lit = strings.Trim(sb.String(), "'\"")
-		}
-		if lit != "" {
-			args = append(args, lit)
-		}
-	}
+	args := callArgs(call)
 
 	if len(args) == 0 {
 		klog.Warning("analyzeCall: no arguments extracted from call")
@@ -198,10 +199,15 @@ func analyzeCall(call *syntax.CallExpr) string {
 
 // parseKubectlArgs extracts verb, subverb, and dry-run flag from kubectl arguments
 func parseKubectlArgs(args []string) (verb, subVerb string, hasDryRun bool) {
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		if strings.HasPrefix(arg, "--dry-run") {
 			hasDryRun = true
 		}
+		if kubectlValueFlags[arg] {
+			i++ // Skip the value of the flag
+			continue
+		}
 		if !strings.HasPrefix(arg, "-") {
 			if verb == "" {
 				verb = arg
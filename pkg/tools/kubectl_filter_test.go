@@ -172,6 +172,9 @@ func TestKubectlAnalyzerComponents(t *testing.T) {
 			{"kubectl get pods --dry", "get", "pods", false}, // Not a valid dry-run flag
 			{"echo --dry-run", "", "", true},                 // The current implementation doesn't check if it's kubectl
 			{"kubectl rollout status deployment nginx", "rollout", "status", false},
+			{"kubectl -n kube-system get pods", "get", "pods", false},
+			{"kubectl --context prod rollout -n web restart deployment", "rollout", "restart", false},
+			{"kubectl -n get delete pod x", "delete", "pod", false},
 		}
 
 		for _, tt := range tests {
@@ -85,6 +85,7 @@ kubectl apply -f pod.yaml
 user: I need to execute a command in the pod
 assistant: kubectl exec my-pod -- /bin/sh -c "your command here"`,
 				},
+				"stdin": stdinSchema,
 				"modifies_resource": {
 					Type: gollm.TypeString,
 					Description: `Whether the command modifies a kubernetes resource.
@@ -117,7 +118,17 @@ func (t *Kubectl) Run(ctx context.Context, args map[string]any) (any, error) {
 		return &sandbox.ExecResult{Command: command, Error: err.Error()}, nil
 	}
 
-	// Prepare environment
+	env, err := kubectlEnv(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = withStdinArg(ctx, args)
+	return ExecuteWithStreamingHandling(ctx, t.executor, command, workDir, env, DetectKubectlStreaming)
+}
+
+// kubectlEnv returns the environment to run kubectl with, using kubeconfig if set.
+func kubectlEnv(kubeconfig string) ([]string, error) {
 	env := os.Environ()
 	if kubeconfig != "" {
 		kubeconfig, err := ExpandShellVar(kubeconfig)
@@ -126,8 +137,7 @@ func (t *Kubectl) Run(ctx context.Context, args map[string]any) (any, error) {
 		}
 		env = append(env, "KUBECONFIG="+kubeconfig)
 	}
-
-	return ExecuteWithStreamingHandling(ctx, t.executor, command, workDir, env, DetectKubectlStreaming)
+	return env, nil
 }
 
 // DetectKubectlStreaming checks if a kubectl command is a streaming command
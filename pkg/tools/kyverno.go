@@ -0,0 +1,564 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"sigs.k8s.io/yaml"
+)
+
+// kyvernoPolicy is the part of a Kyverno ClusterPolicy or Policy the tools report on.
+type kyvernoPolicy struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		ValidationFailureAction string        `json:"validationFailureAction"`
+		Background              *bool         `json:"background"`
+		Rules                   []kyvernoRule `json:"rules"`
+	} `json:"spec"`
+	Status struct {
+		Ready      *bool `json:"ready"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+type kyvernoRule struct {
+	Name         string         `json:"name"`
+	Match        map[string]any `json:"match"`
+	Exclude      map[string]any `json:"exclude"`
+	Validate     map[string]any `json:"validate"`
+	Mutate       map[string]any `json:"mutate"`
+	Generate     map[string]any `json:"generate"`
+	VerifyImages []any          `json:"verifyImages"`
+}
+
+// policyReport is a wgpolicyk8s.io PolicyReport or ClusterPolicyReport.
+type policyReport struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	// Scope is the resource the report is about, for per-resource reports.
+	Scope   *reportResource `json:"scope"`
+	Results []struct {
+		Policy    string           `json:"policy"`
+		Rule      string           `json:"rule"`
+		Result    string           `json:"result"`
+		Message   string           `json:"message"`
+		Severity  string           `json:"severity"`
+		Resources []reportResource `json:"resources"`
+	} `json:"results"`
+}
+
+type reportResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+func (r reportResource) String() string {
+	if r.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+// KyvernoPoliciesTool lists the Kyverno policies installed in the cluster.
+type KyvernoPoliciesTool struct {
+	executor sandbox.Executor
+}
+
+func NewKyvernoPoliciesTool(executor sandbox.Executor) *KyvernoPoliciesTool {
+	return &KyvernoPoliciesTool{executor: executor}
+}
+
+func (t *KyvernoPoliciesTool) Name() string {
+	return "kyverno_list_policies"
+}
+
+func (t *KyvernoPoliciesTool) Description() string {
+	return "Lists Kyverno ClusterPolicies (and Policies of a namespace), with whether they block (Enforce) or only report (Audit) violations, and the kinds each rule applies to."
+}
+
+func (t *KyvernoPoliciesTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `Also list the namespaced Policies of this namespace.`,
+				},
+			},
+		},
+	}
+}
+
+func (t *KyvernoPoliciesTool) buildCommand(args map[string]any) (string, error) {
+	namespace, _ := args["namespace"].(string)
+	if namespace == "" {
+		return "kubectl get clusterpolicies -o json", nil
+	}
+	quoted, err := shellQuote(namespace)
+	if err != nil {
+		return "", err
+	}
+	return "kubectl get clusterpolicies,policies --namespace " + quoted + " -o json", nil
+}
+
+func (t *KyvernoPoliciesTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	command, err := t.buildCommand(args)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	var list struct {
+		Items []kyvernoPolicy `json:"items"`
+	}
+	result, err := getKyvernoJSON(ctx, t.executor, command, &list)
+	if err != nil || result.Error != "" {
+		return result, err
+	}
+
+	if len(list.Items) == 0 {
+		result.Stdout = "No Kyverno policies found.\n"
+		return result, nil
+	}
+	var sb strings.Builder
+	for _, policy := range list.Items {
+		name := policy.Metadata.Name
+		if policy.Metadata.Namespace != "" {
+			name = policy.Metadata.Namespace + "/" + name
+		}
+		fmt.Fprintf(&sb, "%s %s: action=%s background=%t ready=%s\n", policy.Kind, name, policy.failureAction(nil), policy.Spec.Background == nil || *policy.Spec.Background, policy.readiness())
+		for _, rule := range policy.Spec.Rules {
+			fmt.Fprintf(&sb, "  - rule %s (%s) matching %s\n", rule.Name, rule.ruleType(), kindsOrAny(rule.Match))
+		}
+	}
+	result.Stdout = sb.String()
+	return result, nil
+}
+
+func (t *KyvernoPoliciesTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *KyvernoPoliciesTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// KyvernoPolicyReportsTool reports the results of Kyverno policies for the
+// resources of a namespace, or a single resource.
+type KyvernoPolicyReportsTool struct {
+	executor sandbox.Executor
+}
+
+func NewKyvernoPolicyReportsTool(executor sandbox.Executor) *KyvernoPolicyReportsTool {
+	return &KyvernoPolicyReportsTool{executor: executor}
+}
+
+func (t *KyvernoPolicyReportsTool) Name() string {
+	return "kyverno_policy_reports"
+}
+
+func (t *KyvernoPolicyReportsTool) Description() string {
+	return "Fetches Kyverno PolicyReports and lists the policy violations (fail, warn and error results) of existing resources in a namespace, or of one resource. " +
+		"Without a namespace, reports on cluster-scoped resources. Resources rejected at admission don't exist, so they have no reports."
+}
+
+func (t *KyvernoPolicyReportsTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace to report on. Omit for cluster-scoped resources.`,
+				},
+				"kind": {
+					Type:        gollm.TypeString,
+					Description: `Only report on resources of this kind, e.g. Deployment.`,
+				},
+				"name": {
+					Type:        gollm.TypeString,
+					Description: `Only report on the resource with this name.`,
+				},
+				"include_passing": {
+					Type:        gollm.TypeBoolean,
+					Description: `Also list the rules that passed.`,
+				},
+			},
+		},
+	}
+}
+
+func (t *KyvernoPolicyReportsTool) buildCommand(args map[string]any) (string, error) {
+	namespace, _ := args["namespace"].(string)
+	if namespace == "" {
+		return "kubectl get clusterpolicyreports -o json", nil
+	}
+	quoted, err := shellQuote(namespace)
+	if err != nil {
+		return "", err
+	}
+	return "kubectl get policyreports --namespace " + quoted + " -o json", nil
+}
+
+func (t *KyvernoPolicyReportsTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	command, err := t.buildCommand(args)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	var list struct {
+		Items []policyReport `json:"items"`
+	}
+	result, err := getKyvernoJSON(ctx, t.executor, command, &list)
+	if err != nil || result.Error != "" {
+		return result, err
+	}
+
+	kind, _ := args["kind"].(string)
+	name, _ := args["name"].(string)
+	includePassing, _ := args["include_passing"].(bool)
+	result.Stdout = summarizePolicyReports(list.Items, kind, name, includePassing)
+	return result, nil
+}
+
+// summarizePolicyReports lists the results of reports for resources matching
+// kind and name (empty matches any), with counts per outcome.
+func summarizePolicyReports(reports []policyReport, kind, name string, includePassing bool) string {
+	counts := map[string]int{}
+	var lines []string
+	for _, report := range reports {
+		for _, r := range report.Results {
+			resources := r.Resources
+			if len(resources) == 0 && report.Scope != nil {
+				resources = []reportResource{*report.Scope}
+			}
+			for _, resource := range resources {
+				if (kind != "" && !strings.EqualFold(resource.Kind, kind)) || (name != "" && resource.Name != name) {
+					continue
+				}
+				counts[r.Result]++
+				if (r.Result == "pass" && !includePassing) || r.Result == "skip" {
+					continue
+				}
+				line := fmt.Sprintf("%s: %s %s/%s", strings.ToUpper(r.Result), resource, r.Policy, r.Rule)
+				if r.Severity != "" {
+					line += " [" + r.Severity + "]"
+				}
+				if r.Message != "" {
+					line += ": " + r.Message
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(counts) == 0 {
+		return "No policy results found for the matching resources.\n"
+	}
+
+	var outcomes []string
+	for _, outcome := range []string{"fail", "warn", "error", "pass", "skip"} {
+		outcomes = append(outcomes, fmt.Sprintf("%d %s", counts[outcome], outcome))
+	}
+	sort.Strings(lines)
+	return fmt.Sprintf("Results: %s\n", strings.Join(outcomes, ", ")) + strings.Join(lines, "\n") + "\n"
+}
+
+func (t *KyvernoPolicyReportsTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *KyvernoPolicyReportsTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// KyvernoExplainPolicyTool shows what the rules of a Kyverno policy check, to
+// explain why a resource violates it.
+type KyvernoExplainPolicyTool struct {
+	executor sandbox.Executor
+}
+
+func NewKyvernoExplainPolicyTool(executor sandbox.Executor) *KyvernoExplainPolicyTool {
+	return &KyvernoExplainPolicyTool{executor: executor}
+}
+
+func (t *KyvernoExplainPolicyTool) Name() string {
+	return "kyverno_explain_policy"
+}
+
+func (t *KyvernoExplainPolicyTool) Description() string {
+	return "Explains a Kyverno policy violation: shows whether the policy blocks resources, which resources each rule matches and excludes, and what it requires (the validation message and pattern). " +
+		"Use it with the policy and rule named in an admission error, event or policy report."
+}
+
+func (t *KyvernoExplainPolicyTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"policy": {
+					Type:        gollm.TypeString,
+					Description: `The name of the policy.`,
+				},
+				"namespace": {
+					Type:        gollm.TypeString,
+					Description: `The namespace of the policy, for a namespaced Policy. Omit for a ClusterPolicy.`,
+				},
+				"rule": {
+					Type:        gollm.TypeString,
+					Description: `Only explain this rule of the policy.`,
+				},
+			},
+			Required: []string{"policy"},
+		},
+	}
+}
+
+func (t *KyvernoExplainPolicyTool) buildCommand(args map[string]any) (string, error) {
+	policy, _ := args["policy"].(string)
+	if policy == "" {
+		return "", fmt.Errorf("policy is required")
+	}
+	quotedPolicy, err := shellQuote(policy)
+	if err != nil {
+		return "", err
+	}
+	namespace, _ := args["namespace"].(string)
+	if namespace == "" {
+		return "kubectl get clusterpolicy " + quotedPolicy + " -o json", nil
+	}
+	quoted, err := shellQuote(namespace)
+	if err != nil {
+		return "", err
+	}
+	return "kubectl get policy " + quotedPolicy + " --namespace " + quoted + " -o json", nil
+}
+
+func (t *KyvernoExplainPolicyTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	command, err := t.buildCommand(args)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	var policy kyvernoPolicy
+	result, err := getKyvernoJSON(ctx, t.executor, command, &policy)
+	if err != nil || result.Error != "" {
+		return result, err
+	}
+
+	ruleName, _ := args["rule"].(string)
+	explanation, err := policy.explain(ruleName)
+	if err != nil {
+		return &sandbox.ExecResult{Command: command, Error: err.Error()}, nil
+	}
+	result.Stdout = explanation
+	return result, nil
+}
+
+func (t *KyvernoExplainPolicyTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *KyvernoExplainPolicyTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// explain describes the rules of the policy, or only the rule named ruleName.
+func (p *kyvernoPolicy) explain(ruleName string) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s (ready=%s)\n", p.Kind, p.Metadata.Name, p.readiness())
+	found := false
+	for _, rule := range p.Spec.Rules {
+		if ruleName != "" && rule.Name != ruleName {
+			continue
+		}
+		found = true
+		fmt.Fprintf(&sb, "\nRule %s (%s)\n", rule.Name, rule.ruleType())
+		if rule.Validate != nil {
+			action := p.failureAction(&rule)
+			effect := "violations are only reported"
+			if strings.EqualFold(action, "Enforce") {
+				effect = "resources that violate it are rejected at admission"
+			}
+			fmt.Fprintf(&sb, "Action: %s, %s\n", action, effect)
+		}
+		fmt.Fprintf(&sb, "Matches: %s\n", kindsOrAny(rule.Match))
+		if rule.Exclude != nil {
+			fmt.Fprintf(&sb, "Excludes:\n%s", indentYAML(rule.Exclude))
+		}
+		if message, _ := rule.Validate["message"].(string); message != "" {
+			fmt.Fprintf(&sb, "Message: %s\n", message)
+		}
+		requirements := map[string]any{}
+		for key, value := range rule.Validate {
+			if key != "message" && key != "failureAction" && key != "failureActionOverrides" {
+				requirements[key] = value
+			}
+		}
+		if len(requirements) > 0 {
+			fmt.Fprintf(&sb, "Requires:\n%s", indentYAML(requirements))
+		}
+	}
+	if !found {
+		var names []string
+		for _, rule := range p.Spec.Rules {
+			names = append(names, rule.Name)
+		}
+		return "", fmt.Errorf("policy %s has no rule %q, its rules are: %s", p.Metadata.Name, ruleName, strings.Join(names, ", "))
+	}
+	return sb.String(), nil
+}
+
+// failureAction returns what happens when rule (or, if nil, the policy's
+// rules) are violated: Enforce or Audit.
+func (p *kyvernoPolicy) failureAction(rule *kyvernoRule) string {
+	if rule != nil {
+		if action, _ := rule.Validate["failureAction"].(string); action != "" {
+			return action
+		}
+	}
+	if p.Spec.ValidationFailureAction != "" {
+		return p.Spec.ValidationFailureAction
+	}
+	if rule == nil {
+		// Newer policies set the action on each rule
+		actions := map[string]bool{}
+		for _, r := range p.Spec.Rules {
+			if action, _ := r.Validate["failureAction"].(string); action != "" {
+				actions[action] = true
+			}
+		}
+		if len(actions) > 0 {
+			return strings.Join(sortedKeys(actions), ",")
+		}
+	}
+	return "Audit"
+}
+
+// readiness returns whether the policy is ready, with the reason if it isn't.
+func (p *kyvernoPolicy) readiness() string {
+	for _, c := range p.Status.Conditions {
+		if c.Type == "Ready" {
+			if c.Status == "True" || c.Message == "" {
+				return strings.ToLower(c.Status)
+			}
+			return fmt.Sprintf("false (%s)", c.Message)
+		}
+	}
+	if p.Status.Ready != nil {
+		return fmt.Sprint(*p.Status.Ready)
+	}
+	return "unknown"
+}
+
+func (r *kyvernoRule) ruleType() string {
+	switch {
+	case r.Validate != nil:
+		return "validate"
+	case r.Mutate != nil:
+		return "mutate"
+	case r.Generate != nil:
+		return "generate"
+	case r.VerifyImages != nil:
+		return "verifyImages"
+	}
+	return "unknown"
+}
+
+// kindsOrAny returns the kinds listed anywhere in a match or exclude block.
+func kindsOrAny(block map[string]any) string {
+	kinds := map[string]bool{}
+	var collect func(v any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				if key == "kinds" {
+					if list, ok := value.([]any); ok {
+						for _, kind := range list {
+							kinds[fmt.Sprint(kind)] = true
+						}
+					}
+					continue
+				}
+				collect(value)
+			}
+		case []any:
+			for _, value := range v {
+				collect(value)
+			}
+		}
+	}
+	collect(block)
+	if len(kinds) == 0 {
+		return "any resource"
+	}
+	return strings.Join(sortedKeys(kinds), ", ")
+}
+
+// indentYAML renders v as YAML, indented by two spaces.
+func indentYAML(v any) string {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("  %v\n", v)
+	}
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	return "  " + strings.Join(lines, "\n  ") + "\n"
+}
+
+// getKyvernoJSON runs a kubectl command printing JSON and decodes its output
+// into v. Failures are returned in the Error of the result, pointing out when
+// Kyverno is not installed.
+func getKyvernoJSON(ctx context.Context, executor sandbox.Executor, command string, v any) (*sandbox.ExecResult, error) {
+	result, err := runKubectl(ctx, executor, command)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != "" || result.ExitCode != 0 {
+		if result.Error == "" {
+			result.Error = strings.TrimSpace(result.Stderr)
+		}
+		if strings.Contains(result.Stderr, "the server doesn't have a resource type") {
+			result.Error += " (is Kyverno installed in this cluster?)"
+		}
+		return result, nil
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), v); err != nil {
+		result.Error = fmt.Sprintf("parsing kubectl output: %v", err)
+		return result, nil
+	}
+	result.Stdout = ""
+	return result, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testClusterPolicy = `{
+  "kind": "ClusterPolicy",
+  "metadata": {"name": "require-labels"},
+  "spec": {
+    "validationFailureAction": "Enforce",
+    "rules": [
+      {
+        "name": "check-team",
+        "match": {"any": [{"resources": {"kinds": ["Deployment", "StatefulSet"]}}]},
+        "validate": {"message": "label team is required", "pattern": {"metadata": {"labels": {"team": "?*"}}}}
+      },
+      {
+        "name": "add-defaults",
+        "match": {"resources": {"kinds": ["Pod"]}},
+        "mutate": {"patchStrategicMerge": {}}
+      }
+    ]
+  },
+  "status": {"conditions": [{"type": "Ready", "status": "True"}]}
+}`
+
+func TestKyvernoPolicy_Explain(t *testing.T) {
+	var policy kyvernoPolicy
+	if err := json.Unmarshal([]byte(testClusterPolicy), &policy); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := policy.explain("check-team")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"ClusterPolicy require-labels (ready=true)",
+		"Rule check-team (validate)",
+		"Action: Enforce, resources that violate it are rejected at admission",
+		"Matches: Deployment, StatefulSet",
+		"Message: label team is required",
+		"team: ?*",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "add-defaults") {
+		t.Errorf("expected only the requested rule, got:\n%s", got)
+	}
+
+	if _, err := policy.explain("missing"); err == nil || !strings.Contains(err.Error(), "check-team, add-defaults") {
+		t.Errorf("expected an error listing the rules, got %v", err)
+	}
+}
+
+func TestSummarizePolicyReports(t *testing.T) {
+	var reports []policyReport
+	if err := json.Unmarshal([]byte(`[
+	  {
+	    "scope": {"kind": "Deployment", "name": "web", "namespace": "prod"},
+	    "results": [
+	      {"policy": "require-labels", "rule": "check-team", "result": "fail", "message": "label team is required", "severity": "medium"},
+	      {"policy": "disallow-latest", "rule": "check-tag", "result": "pass"}
+	    ]
+	  },
+	  {
+	    "results": [
+	      {"policy": "require-labels", "rule": "check-team", "result": "fail", "resources": [{"kind": "Deployment", "name": "api", "namespace": "prod"}]}
+	    ]
+	  }
+	]`), &reports); err != nil {
+		t.Fatal(err)
+	}
+
+	got := summarizePolicyReports(reports, "deployment", "web", false)
+	if !strings.HasPrefix(got, "Results: 1 fail, 0 warn, 0 error, 1 pass, 0 skip\n") {
+		t.Errorf("unexpected counts in:\n%s", got)
+	}
+	if !strings.Contains(got, "FAIL: Deployment prod/web require-labels/check-team [medium]: label team is required") {
+		t.Errorf("expected the failure of web, got:\n%s", got)
+	}
+	if strings.Contains(got, "api") || strings.Contains(got, "disallow-latest") {
+		t.Errorf("expected other resources and passing rules to be left out, got:\n%s", got)
+	}
+
+	if got := summarizePolicyReports(reports, "", "", true); !strings.Contains(got, "PASS: Deployment prod/web disallow-latest/check-tag") || !strings.Contains(got, "Deployment prod/api") {
+		t.Errorf("expected all results, got:\n%s", got)
+	}
+}
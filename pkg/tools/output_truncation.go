@@ -0,0 +1,309 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"github.com/google/uuid"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultMaxOutputBytes is the size above which tool output is truncated
+	// before being sent to the LLM.
+	DefaultMaxOutputBytes = 16 * 1024
+
+	// defaultFetchLines is the number of lines returned by fetch_full_output if no limit is given.
+	defaultFetchLines = 200
+)
+
+// OutputTruncator truncates long tool outputs before they are sent to the LLM.
+// The full output is kept on disk so the LLM can page through it using the
+// fetch_full_output tool.
+type OutputTruncator struct {
+	// MaxBytes is the maximum size of the output sent to the LLM.
+	MaxBytes int
+
+	store *OutputStore
+}
+
+// NewOutputTruncator creates an OutputTruncator that stores full outputs in store.
+func NewOutputTruncator(store *OutputStore, maxBytes int) *OutputTruncator {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxOutputBytes
+	}
+	return &OutputTruncator{MaxBytes: maxBytes, store: store}
+}
+
+// Truncate returns output with long stdout elided. Outputs other than
+// *sandbox.ExecResult and strings are returned unchanged.
+func (t *OutputTruncator) Truncate(output any) (any, error) {
+	return t.TruncateWithPolicy(output, TruncateHeadAndTail)
+}
+
+// TruncateWithPolicy is like Truncate, choosing which lines are kept with policy.
+func (t *OutputTruncator) TruncateWithPolicy(output any, policy TruncationPolicy) (any, error) {
+	switch v := output.(type) {
+	case *sandbox.ExecResult:
+		if v == nil || len(v.Stdout) <= t.MaxBytes {
+			return output, nil
+		}
+		truncated, err := t.truncate(v.Stdout, policy)
+		if err != nil {
+			return nil, err
+		}
+		result := *v
+		result.Stdout = truncated
+		return &result, nil
+	case string:
+		if len(v) <= t.MaxBytes {
+			return output, nil
+		}
+		return t.truncate(v, policy)
+	default:
+		return output, nil
+	}
+}
+
+func (t *OutputTruncator) truncate(content string, policy TruncationPolicy) (string, error) {
+	id, err := t.store.Save(content)
+	if err != nil {
+		return "", fmt.Errorf("saving full output: %w", err)
+	}
+	totalLines := strings.Count(content, "\n") + 1
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[output truncated: %d bytes, %d lines. Full output saved with output_id %q; use the fetch_full_output tool to read more]\n", len(content), totalLines, id)
+	if policy == TruncateTail {
+		sb.WriteString(tail(content, t.MaxBytes))
+		return sb.String(), nil
+	}
+	if summary := summarizeResources(content); summary != "" {
+		sb.WriteString(summary)
+		return sb.String(), nil
+	}
+	sb.WriteString(headAndTail(content, t.MaxBytes))
+	return sb.String(), nil
+}
+
+// headAndTail keeps the first and last lines of content within roughly maxBytes.
+func headAndTail(content string, maxBytes int) string {
+	lines := strings.Split(content, "\n")
+	budget := maxBytes / 2
+
+	var head []string
+	size := 0
+	for _, line := range lines {
+		if size+len(line)+1 > budget {
+			break
+		}
+		head = append(head, line)
+		size += len(line) + 1
+	}
+
+	var tail []string
+	size = 0
+	for i := len(lines) - 1; i >= len(head); i-- {
+		if size+len(lines[i])+1 > budget {
+			break
+		}
+		tail = append([]string{lines[i]}, tail...)
+		size += len(lines[i]) + 1
+	}
+
+	elided := len(lines) - len(head) - len(tail)
+	return strings.Join(head, "\n") +
+		fmt.Sprintf("\n... [%d lines elided] ...\n", elided) +
+		strings.Join(tail, "\n")
+}
+
+// tail keeps the last lines of content within roughly maxBytes.
+func tail(content string, maxBytes int) string {
+	lines := strings.Split(content, "\n")
+	start := len(lines)
+	size := 0
+	for start > 0 && size+len(lines[start-1])+1 <= maxBytes {
+		start--
+		size += len(lines[start]) + 1
+	}
+	return fmt.Sprintf("... [%d lines elided] ...\n", start) + strings.Join(lines[start:], "\n")
+}
+
+// summarizeResources renders a one line summary per resource if content is
+// a kubernetes List in YAML or JSON (e.g. the output of `kubectl get -o yaml`).
+func summarizeResources(content string) string {
+	var list struct {
+		Kind  string `json:"kind"`
+		Items []struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &list); err != nil || !strings.HasSuffix(list.Kind, "List") || len(list.Items) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s with %d items:\n", list.Kind, len(list.Items))
+	for _, item := range list.Items {
+		name := item.Metadata.Name
+		if item.Metadata.Namespace != "" {
+			name = item.Metadata.Namespace + "/" + name
+		}
+		fmt.Fprintf(&sb, "- %s %s\n", item.Kind, name)
+	}
+	return sb.String()
+}
+
+// OutputStore persists full tool outputs on disk.
+type OutputStore struct {
+	dir string
+}
+
+// NewOutputStore creates an OutputStore that writes to dir.
+func NewOutputStore(dir string) *OutputStore {
+	return &OutputStore{dir: dir}
+}
+
+// Save writes content to the store and returns its id.
+func (s *OutputStore) Save(content string) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", err
+	}
+	id := uuid.NewString()[:8]
+	if err := os.WriteFile(filepath.Join(s.dir, id+".txt"), []byte(content), 0o600); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+var outputIDPattern = regexp.MustCompile(`^[0-9a-f-]+$`)
+
+// Load returns the content saved under id.
+func (s *OutputStore) Load(id string) (string, error) {
+	if !outputIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid output_id %q", id)
+	}
+	b, err := os.ReadFile(filepath.Join(s.dir, id+".txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("output %q not found", id)
+		}
+		return "", err
+	}
+	return string(b), nil
+}
+
+// FetchFullOutputTool lets the LLM page through outputs elided by OutputTruncator.
+type FetchFullOutputTool struct {
+	store *OutputStore
+}
+
+func NewFetchFullOutputTool(store *OutputStore) *FetchFullOutputTool {
+	return &FetchFullOutputTool{store: store}
+}
+
+func (t *FetchFullOutputTool) Name() string {
+	return "fetch_full_output"
+}
+
+func (t *FetchFullOutputTool) Description() string {
+	return "Fetches a range of lines from a tool output that was truncated. Use this tool only when the truncated output does not contain the information you need."
+}
+
+func (t *FetchFullOutputTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"output_id": {
+					Type:        gollm.TypeString,
+					Description: `The output_id reported in the truncated output.`,
+				},
+				"offset": {
+					Type:        gollm.TypeInteger,
+					Description: `The line number to start reading from (0-based). Defaults to 0.`,
+				},
+				"limit": {
+					Type:        gollm.TypeInteger,
+					Description: fmt.Sprintf(`The maximum number of lines to return. Defaults to %d, which is also used if the limit is 0 or less.`, defaultFetchLines),
+				},
+			},
+			Required: []string{"output_id"},
+		},
+	}
+}
+
+func (t *FetchFullOutputTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	id, _ := args["output_id"].(string)
+	content, err := t.store.Load(id)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+
+	offset := intArg(args, "offset", 0)
+	limit := intArg(args, "limit", defaultFetchLines)
+	if limit <= 0 {
+		// LLMs often send 0 to mean "no limit"; return the default page
+		// rather than nothing.
+		limit = defaultFetchLines
+	}
+	lines := strings.Split(content, "\n")
+	if offset < 0 || offset >= len(lines) {
+		return &sandbox.ExecResult{Error: fmt.Sprintf("offset %d out of range, output has %d lines", offset, len(lines))}, nil
+	}
+	end := min(offset+limit, len(lines))
+
+	return map[string]any{
+		"content":     strings.Join(lines[offset:end], "\n"),
+		"offset":      offset,
+		"next_offset": end,
+		"total_lines": len(lines),
+	}, nil
+}
+
+// intArg reads an integer argument; LLMs send numbers as float64 after JSON decoding.
+func intArg(args map[string]any, key string, def int) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	}
+	return def
+}
+
+func (t *FetchFullOutputTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *FetchFullOutputTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+func TestOutputTruncator(t *testing.T) {
+	store := NewOutputStore(t.TempDir())
+	truncator := NewOutputTruncator(store, 200)
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	full := strings.Join(lines, "\n")
+
+	out, err := truncator.Truncate(&sandbox.ExecResult{Command: "kubectl logs foo", Stdout: full})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stdout := out.(*sandbox.ExecResult).Stdout
+	if !strings.Contains(stdout, "line 0\n") || !strings.Contains(stdout, "line 99") {
+		t.Errorf("expected head and tail to be kept, got %q", stdout)
+	}
+	if !strings.Contains(stdout, "lines elided") {
+		t.Errorf("expected elision marker, got %q", stdout)
+	}
+
+	id := regexp.MustCompile(`output_id "([^"]+)"`).FindStringSubmatch(stdout)
+	if id == nil {
+		t.Fatalf("expected output_id in %q", stdout)
+	}
+	fetch := NewFetchFullOutputTool(store)
+	res, err := fetch.Run(context.Background(), map[string]any{"output_id": id[1], "offset": float64(50), "limit": float64(2)})
+	if err != nil {
+		t.Fatalf("fetch_full_output failed: %v", err)
+	}
+	if got := res.(map[string]any)["content"]; got != "line 50\nline 51" {
+		t.Errorf("expected lines 50-51, got %q", got)
+	}
+
+	res, err = fetch.Run(context.Background(), map[string]any{"output_id": id[1], "offset": float64(98), "limit": float64(0)})
+	if err != nil {
+		t.Fatalf("fetch_full_output failed: %v", err)
+	}
+	if got := res.(map[string]any)["content"]; got != "line 98\nline 99" {
+		t.Errorf("expected a limit of 0 to return the remaining lines, got %q", got)
+	}
+
+	small := &sandbox.ExecResult{Stdout: "short"}
+	if out, _ := truncator.Truncate(small); out != small {
+		t.Errorf("expected short output to be returned unchanged")
+	}
+}
+
+func TestOutputTruncator_SummarizesLists(t *testing.T) {
+	truncator := NewOutputTruncator(NewOutputStore(t.TempDir()), 100)
+
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\nkind: List\nitems:\n")
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&sb, "- apiVersion: v1\n  kind: Pod\n  metadata:\n    name: pod-%d\n    namespace: default\n", i)
+	}
+
+	out, err := truncator.Truncate(sb.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.(string), "- Pod default/pod-9") {
+		t.Errorf("expected per-resource summary, got %q", out)
+	}
+}
+
+func TestOutputTruncator_TruncateTail(t *testing.T) {
+	truncator := NewOutputTruncator(NewOutputStore(t.TempDir()), 100)
+
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+
+	out, err := truncator.TruncateWithPolicy(strings.Join(lines, "\n"), TruncateTail)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out.(string), "line 0\n") {
+		t.Errorf("expected the head to be dropped, got %q", out)
+	}
+	if !strings.HasSuffix(out.(string), "line 98\nline 99") {
+		t.Errorf("expected the tail to be kept, got %q", out)
+	}
+}
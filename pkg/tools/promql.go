@@ -0,0 +1,366 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+const (
+	// maxPromQLRows is the number of series included in a summarized result.
+	maxPromQLRows = 50
+	// promQLRangePoints is the number of points a range query asks for when no step is given.
+	promQLRangePoints = 60
+	// maxPromQLResponseBytes bounds the size of a response read from Prometheus.
+	maxPromQLResponseBytes = 8 * 1024 * 1024
+)
+
+// PromQLQueryTool runs PromQL queries against a Prometheus compatible
+// endpoint, so that metrics can be correlated with kubectl findings.
+type PromQLQueryTool struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewPromQLQueryTool creates a PromQLQueryTool querying the Prometheus server at
+// endpoint, e.g. http://prometheus.monitoring:9090. A nil client uses http.DefaultClient.
+func NewPromQLQueryTool(endpoint string, client *http.Client) *PromQLQueryTool {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &PromQLQueryTool{endpoint: strings.TrimRight(endpoint, "/"), client: client}
+}
+
+func (t *PromQLQueryTool) Name() string {
+	return "promql_query"
+}
+
+func (t *PromQLQueryTool) Description() string {
+	return "Queries cluster metrics from Prometheus with PromQL, e.g. CPU throttling or container restarts. " +
+		"Use it to correlate what kubectl shows with resource usage over time. " +
+		"Results are summarized as a table, sorted by value, with labels shared by all series listed once."
+}
+
+func (t *PromQLQueryTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"query": {
+					Type:        gollm.TypeString,
+					Description: `The PromQL expression, e.g. sum by (pod) (rate(container_cpu_cfs_throttled_periods_total{namespace="prod"}[5m])).`,
+				},
+				"range": {
+					Type:        gollm.TypeString,
+					Description: `Evaluate the query over this long a period ending now (e.g. 1h), reporting the min, max and last value of each series. Omit for the current value only.`,
+				},
+				"step": {
+					Type:        gollm.TypeString,
+					Description: fmt.Sprintf(`The resolution of a range query (e.g. 1m). Defaults to the range divided into %d points.`, promQLRangePoints),
+				},
+			},
+			Required: []string{"query"},
+		},
+	}
+}
+
+func (t *PromQLQueryTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	query, _ := args["query"].(string)
+	if query == "" {
+		return &sandbox.ExecResult{Error: "query is required"}, nil
+	}
+
+	params := url.Values{"query": {query}}
+	path := "/api/v1/query"
+	if r, _ := args["range"].(string); r != "" {
+		window, err := time.ParseDuration(r)
+		if err != nil || window <= 0 {
+			return &sandbox.ExecResult{Error: fmt.Sprintf("invalid range %q, expected a duration like 30m or 2h", r)}, nil
+		}
+		step := window / promQLRangePoints
+		if s, _ := args["step"].(string); s != "" {
+			if step, err = time.ParseDuration(s); err != nil || step <= 0 {
+				return &sandbox.ExecResult{Error: fmt.Sprintf("invalid step %q, expected a duration like 1m", s)}, nil
+			}
+		}
+		step = max(step, time.Second)
+		end := time.Now()
+		path = "/api/v1/query_range"
+		params.Set("start", formatPromTime(end.Add(-window)))
+		params.Set("end", formatPromTime(end))
+		params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+	}
+
+	data, err := t.query(ctx, path, params)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	return map[string]any{
+		"query":   query,
+		"content": summarizePromResult(data),
+	}, nil
+}
+
+// promResponse is the envelope of Prometheus HTTP API responses.
+type promResponse struct {
+	Status    string   `json:"status"`
+	Data      promData `json:"data"`
+	ErrorType string   `json:"errorType"`
+	Error     string   `json:"error"`
+	Warnings  []string `json:"warnings"`
+}
+
+type promData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+	// Warnings are copied from the envelope
+	Warnings []string `json:"-"`
+}
+
+// promSeries is one series of a vector or matrix result.
+type promSeries struct {
+	Metric map[string]string `json:"metric"`
+	Value  []any             `json:"value"`
+	Values [][]any           `json:"values"`
+}
+
+func (t *PromQLQueryTool) query(ctx context.Context, path string, params url.Values) (*promData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+path, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPromQLResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading prometheus response: %w", err)
+	}
+	var result promResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("prometheus returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("query failed (%s): %s", result.ErrorType, result.Error)
+	}
+	result.Data.Warnings = result.Warnings
+	return &result.Data, nil
+}
+
+// summarizePromResult renders a query result as a text table.
+func summarizePromResult(data *promData) string {
+	var sb strings.Builder
+	for _, w := range data.Warnings {
+		fmt.Fprintf(&sb, "warning: %s\n", w)
+	}
+
+	switch data.ResultType {
+	case "scalar", "string":
+		var sample []any
+		if err := json.Unmarshal(data.Result, &sample); err != nil || len(sample) != 2 {
+			return sb.String() + string(data.Result)
+		}
+		fmt.Fprintf(&sb, "%s: %v\n", data.ResultType, sample[1])
+		return sb.String()
+	case "vector", "matrix":
+	default:
+		return sb.String() + fmt.Sprintf("unsupported result type %q: %s", data.ResultType, data.Result)
+	}
+
+	var series []promSeries
+	if err := json.Unmarshal(data.Result, &series); err != nil {
+		return sb.String() + string(data.Result)
+	}
+	if len(series) == 0 {
+		sb.WriteString("No series matched the query.\n")
+		return sb.String()
+	}
+
+	type row struct {
+		labels map[string]string
+		values []float64 // the value, or min, max and last for a range query
+	}
+	var rows []row
+	for _, s := range series {
+		r := row{labels: s.Metric}
+		if data.ResultType == "vector" {
+			r.values = []float64{sampleValue(s.Value)}
+		} else {
+			minV, maxV, last := math.Inf(1), math.Inf(-1), math.NaN()
+			for _, v := range s.Values {
+				f := sampleValue(v)
+				minV, maxV, last = math.Min(minV, f), math.Max(maxV, f), f
+			}
+			r.values = []float64{minV, maxV, last}
+		}
+		rows = append(rows, r)
+	}
+	// Sort by the value, or the max for ranges, largest first
+	key := 0
+	if data.ResultType == "matrix" {
+		key = 1
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i].values[key], rows[j].values[key]
+		return a > b || (!math.IsNaN(a) && math.IsNaN(b))
+	})
+
+	common, varying := splitLabels(series)
+	if len(common) > 0 {
+		var pairs []string
+		for _, name := range sortedKeys(common) {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", name, common[name]))
+		}
+		fmt.Fprintf(&sb, "Labels shared by all %d series: %s\n", len(series), strings.Join(pairs, ", "))
+	}
+
+	header := append([]string{}, varying...)
+	if data.ResultType == "vector" {
+		header = append(header, "VALUE")
+	} else {
+		header = append(header, "MIN", "MAX", "LAST")
+	}
+	table := [][]string{header}
+	for i, r := range rows {
+		if i == maxPromQLRows {
+			break
+		}
+		var cells []string
+		for _, name := range varying {
+			cells = append(cells, r.labels[name])
+		}
+		for _, v := range r.values {
+			cells = append(cells, strconv.FormatFloat(v, 'g', 6, 64))
+		}
+		table = append(table, cells)
+	}
+	writeTable(&sb, table)
+	if len(rows) > maxPromQLRows {
+		fmt.Fprintf(&sb, "... %d more series not shown; aggregate the query (e.g. topk or sum by) to narrow it down\n", len(rows)-maxPromQLRows)
+	}
+	return sb.String()
+}
+
+// splitLabels returns the labels with the same value in every series, and the
+// names of the others.
+func splitLabels(series []promSeries) (map[string]string, []string) {
+	common := map[string]string{}
+	for name, value := range series[0].Metric {
+		common[name] = value
+	}
+	all := map[string]bool{}
+	for _, s := range series {
+		for name := range s.Metric {
+			all[name] = true
+		}
+		for name, value := range common {
+			if v, ok := s.Metric[name]; !ok || v != value {
+				delete(common, name)
+			}
+		}
+	}
+	if len(series) == 1 {
+		// Show the labels of a single series in the table
+		common = map[string]string{}
+	}
+	var varying []string
+	for name := range all {
+		if _, ok := common[name]; !ok {
+			varying = append(varying, name)
+		}
+	}
+	sort.Slice(varying, func(i, j int) bool {
+		// The metric name comes first
+		if varying[i] == "__name__" || varying[j] == "__name__" {
+			return varying[i] == "__name__"
+		}
+		return varying[i] < varying[j]
+	})
+	return common, varying
+}
+
+// writeTable writes rows as space aligned columns.
+func writeTable(sb *strings.Builder, rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(row)-1 {
+				sb.WriteString(cell)
+			} else {
+				fmt.Fprintf(sb, "%-*s  ", widths[i], cell)
+			}
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// sampleValue returns the value of a [timestamp, "value"] sample.
+func sampleValue(sample []any) float64 {
+	if len(sample) != 2 {
+		return math.NaN()
+	}
+	s, _ := sample[1].(string)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func formatPromTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', 3, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (t *PromQLQueryTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *PromQLQueryTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+func TestPromQLQueryTool(t *testing.T) {
+	var gotPath, gotQuery, gotStep string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotStep = r.URL.Path, r.FormValue("query"), r.FormValue("step")
+		switch r.FormValue("query") {
+		case "bad(":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		case "restarts":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"namespace":"prod","pod":"web-0"},"value":[1700000000,"1"]},
+				{"metric":{"namespace":"prod","pod":"web-1"},"value":[1700000000,"7"]}]}}`)
+		default:
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"pod":"web-0"},"values":[[1700000000,"0.5"],[1700000060,"2"],[1700000120,"1"]]}]}}`)
+		}
+	}))
+	defer server.Close()
+	tool := NewPromQLQueryTool(server.URL+"/", nil)
+	ctx := context.Background()
+
+	res, err := tool.Run(ctx, map[string]any{"query": "restarts"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content := res.(map[string]any)["content"].(string)
+	if gotPath != "/api/v1/query" || gotQuery != "restarts" {
+		t.Errorf("unexpected request %s query=%q", gotPath, gotQuery)
+	}
+	if !strings.Contains(content, `Labels shared by all 2 series: namespace="prod"`) {
+		t.Errorf("expected common labels to be listed once, got:\n%s", content)
+	}
+	if strings.Index(content, "web-1") > strings.Index(content, "web-0") {
+		t.Errorf("expected rows sorted by value, got:\n%s", content)
+	}
+
+	res, err = tool.Run(ctx, map[string]any{"query": "throttling", "range": "1h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/api/v1/query_range" || gotStep != "60" {
+		t.Errorf("unexpected range request %s step=%q", gotPath, gotStep)
+	}
+	content = res.(map[string]any)["content"].(string)
+	if !strings.Contains(content, "MIN  MAX  LAST") || !strings.Contains(content, "0.5  2    1") {
+		t.Errorf("expected min, max and last values, got:\n%s", content)
+	}
+
+	res, _ = tool.Run(ctx, map[string]any{"query": "bad("})
+	if got := res.(*sandbox.ExecResult).Error; !strings.Contains(got, "parse error") {
+		t.Errorf("expected the query error to be returned, got %q", got)
+	}
+
+	res, _ = tool.Run(ctx, map[string]any{"query": "up", "range": "yesterday"})
+	if got := res.(*sandbox.ExecResult).Error; !strings.Contains(got, "invalid range") {
+		t.Errorf("expected invalid range error, got %q", got)
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var (
+	// readOnlyKubectlVerbs are the only kubectl verbs allowed in read-only mode.
+	readOnlyKubectlVerbs = map[string]bool{
+		"get": true, "describe": true, "logs": true, "top": true,
+	}
+
+	// mutatingCommands change files, processes or the system, and are not
+	// allowed in read-only mode.
+	mutatingCommands = map[string]bool{
+		"rm": true, "rmdir": true, "mv": true, "cp": true, "dd": true,
+		"tee": true, "truncate": true, "shred": true, "ln": true,
+		"mkdir": true, "touch": true, "install": true, "chmod": true,
+		"chown": true, "chgrp": true, "kill": true, "pkill": true,
+		"killall": true, "reboot": true, "shutdown": true, "sudo": true,
+		"su": true, "scp": true, "rsync": true, "ssh": true,
+		"apt": true, "apt-get": true, "yum": true, "dnf": true, "apk": true,
+		"pip": true, "npm": true,
+	}
+
+	// nestingCommands run other commands that can't be screened.
+	nestingCommands = map[string]bool{
+		"sh": true, "bash": true, "zsh": true, "eval": true, "exec": true,
+		"source": true, ".": true, "xargs": true, "nohup": true, "env": true,
+		"timeout": true, "watch": true,
+	}
+
+	// interpreters run the code given with their -c or -e flags, which can't
+	// be screened.
+	interpreters = map[string]bool{
+		"python": true, "python2": true, "python3": true, "node": true,
+		"ruby": true, "perl": true, "php": true, "lua": true,
+	}
+
+	// findActionFlags make find delete files or run commands.
+	findActionFlags = map[string]bool{
+		"-delete": true, "-exec": true, "-execdir": true, "-ok": true, "-okdir": true,
+		"-fprint": true, "-fprint0": true, "-fprintf": true, "-fls": true,
+	}
+
+	// cliVerbCommands are CLIs whose subcommands are screened against mutatingVerbs.
+	cliVerbCommands = map[string]bool{
+		"helm": true, "gcloud": true, "aws": true, "az": true, "eksctl": true,
+		"terraform": true, "git": true, "docker": true, "crictl": true,
+		"istioctl": true, "flux": true, "argocd": true, "kustomize": true,
+	}
+
+	mutatingVerbs = map[string]bool{
+		"create": true, "delete": true, "update": true, "patch": true,
+		"set": true, "apply": true, "deploy": true, "destroy": true,
+		"remove": true, "rm": true, "add": true, "start": true, "stop": true,
+		"restart": true, "reset": true, "resize": true, "scale": true,
+		"upgrade": true, "install": true, "uninstall": true, "rollback": true,
+		"import": true, "attach": true, "detach": true, "put": true,
+		"cp": true, "mv": true, "sync": true, "enable": true, "disable": true,
+		"push": true, "commit": true, "run": true, "exec": true, "kill": true,
+		"edit": true, "build": true, "reconcile": true, "suspend": true,
+		"resume": true, "edit-config": true,
+	}
+)
+
+// CheckReadOnly returns an error explaining why a call of tool with args is
+// not allowed in read-only mode, or nil if it is. kubectl is limited to
+// get, describe, logs and top; bash commands are screened for anything that
+// could modify the cluster or the machine; other tools are rejected unless
+// they report that they don't modify resources, so that calls whose effect is
+// unknown are rejected too.
+func CheckReadOnly(tool Tool, args map[string]any) error {
+	switch tool.Name() {
+	case "kubectl", "bash":
+		command, ok := args["command"].(string)
+		if !ok {
+			return fmt.Errorf("no command given")
+		}
+		return checkReadOnlyCommand(command)
+	}
+
+	switch tool.CheckModifiesResource(args) {
+	case "no":
+		return nil
+	case "yes":
+		return fmt.Errorf("tool %q modifies resources", tool.Name())
+	default:
+		return fmt.Errorf("tool %q may modify resources", tool.Name())
+	}
+}
+
+// checkReadOnlyCommand screens a shell command for operations that are not
+// allowed in read-only mode.
+func checkReadOnlyCommand(command string) error {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return fmt.Errorf("could not parse the command: %w", err)
+	}
+
+	var violation error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if violation != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.Redirect:
+			violation = checkReadOnlyRedirect(n)
+		case *syntax.CallExpr:
+			violation = checkReadOnlyCall(callArgs(n))
+		}
+		return violation == nil
+	})
+	return violation
+}
+
+func checkReadOnlyRedirect(redirect *syntax.Redirect) error {
+	switch redirect.Op {
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll, syntax.ClbOut, syntax.RdrInOut:
+		if redirect.Word != nil && redirect.Word.Lit() == "/dev/null" {
+			return nil
+		}
+		return fmt.Errorf("writing to files with %q is not allowed", redirect.Op.String())
+	}
+	return nil
+}
+
+func checkReadOnlyCall(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	name := path.Base(args[0])
+
+	switch {
+	case strings.Contains(name, "kubectl"):
+		verb, _, _ := parseKubectlArgs(args[1:])
+		if !readOnlyKubectlVerbs[verb] {
+			return fmt.Errorf("kubectl %s is not allowed, only get, describe, logs and top are", verb)
+		}
+	case mutatingCommands[name]:
+		return fmt.Errorf("%s is not allowed", name)
+	case nestingCommands[name]:
+		return fmt.Errorf("%s runs commands that can't be checked", name)
+	case name == "sed" || name == "perl":
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-i") || arg == "--in-place" {
+				return fmt.Errorf("editing files in place with %s is not allowed", name)
+			}
+		}
+		if name == "perl" {
+			return checkReadOnlyInterpreter(name, args[1:])
+		}
+	case interpreters[strings.TrimRight(name, "0123456789.")]:
+		return checkReadOnlyInterpreter(name, args[1:])
+	case name == "find":
+		for _, arg := range args[1:] {
+			if findActionFlags[arg] {
+				return fmt.Errorf("find %s is not allowed", arg)
+			}
+		}
+	case name == "curl":
+		return checkReadOnlyCurl(args[1:])
+	case name == "wget":
+		return checkReadOnlyWget(args[1:])
+	case cliVerbCommands[name]:
+		for _, arg := range args[1:] {
+			if !strings.HasPrefix(arg, "-") && mutatingVerbs[arg] {
+				return fmt.Errorf("%s %s is not allowed", name, arg)
+			}
+		}
+	}
+	return nil
+}
+
+// checkReadOnlyInterpreter rejects code given to an interpreter on the
+// command line, e.g. python3 -c.
+func checkReadOnlyInterpreter(name string, args []string) error {
+	for _, arg := range args {
+		switch {
+		case arg == "-c" || arg == "-e" || arg == "-E" || arg == "-r" || arg == "--eval":
+			return fmt.Errorf("%s %s runs code that can't be checked", name, arg)
+		case len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.ContainsAny(arg[1:], "ceE"):
+			// Combined short flags, e.g. perl -ne
+			return fmt.Errorf("%s %s runs code that can't be checked", name, arg)
+		}
+	}
+	return nil
+}
+
+var (
+	// curlWriteFlags send data or write to files.
+	curlWriteFlags = map[string]bool{
+		"-d": true, "--data": true, "--data-raw": true, "--data-binary": true, "--data-urlencode": true,
+		"--json": true, "-F": true, "--form": true, "--form-string": true, "-T": true, "--upload-file": true,
+		"-o": true, "--output": true, "-O": true, "--remote-name": true, "--remote-name-all": true,
+		"-D": true, "--dump-header": true, "-c": true, "--cookie-jar": true, "--trace": true,
+		"--trace-ascii": true, "--libcurl": true, "--output-dir": true, "--create-dirs": true,
+	}
+
+	// curlShortValueFlags are the short curl flags that take a value, which
+	// ends a group of combined short flags such as -sXPOST.
+	curlShortValueFlags = "AbcCdDeEFHKmortTuUwxXyYzPQ"
+)
+
+// checkReadOnlyCurl rejects curl commands that send data, use a method other
+// than GET, or write to files.
+func checkReadOnlyCurl(args []string) error {
+	checkMethod := func(method string) error {
+		if m := strings.ToUpper(method); m != "GET" && m != "HEAD" {
+			return fmt.Errorf("curl with method %s is not allowed", method)
+		}
+		return nil
+	}
+	for i, arg := range args {
+		next := ""
+		if i+1 < len(args) {
+			next = args[i+1]
+		}
+		long, value, _ := strings.Cut(arg, "=")
+		switch {
+		case arg == "-X" || arg == "--request":
+			if err := checkMethod(next); err != nil {
+				return err
+			}
+		case long == "--request":
+			if err := checkMethod(value); err != nil {
+				return err
+			}
+		case curlWriteFlags[long]:
+			return fmt.Errorf("curl %s is not allowed", long)
+		case len(arg) > 1 && arg[0] == '-' && arg[1] != '-':
+			// A group of combined short flags, e.g. -sXPOST or -sd@body.json
+			for j := 1; j < len(arg); j++ {
+				flag := "-" + arg[j:j+1]
+				if flag == "-X" {
+					method := arg[j+1:]
+					if method == "" {
+						method = next
+					}
+					if err := checkMethod(method); err != nil {
+						return err
+					}
+					break
+				}
+				if curlWriteFlags[flag] {
+					return fmt.Errorf("curl %s is not allowed", flag)
+				}
+				if strings.Contains(curlShortValueFlags, arg[j:j+1]) {
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// wgetWriteFlags send data or write to files other than the output.
+var wgetWriteFlags = map[string]bool{
+	"--post-data": true, "--post-file": true, "--body-data": true, "--body-file": true,
+	"-o": true, "--output-file": true, "-a": true, "--append-output": true,
+	"-x": true, "--force-directories": true, "-r": true, "--recursive": true,
+	"-m": true, "--mirror": true, "-p": true, "--page-requisites": true,
+}
+
+// checkReadOnlyWget only allows wget to GET documents to stdout, since it
+// saves them to files by default.
+func checkReadOnlyWget(args []string) error {
+	toStdout := false
+	for i, arg := range args {
+		next := ""
+		if i+1 < len(args) {
+			next = args[i+1]
+		}
+		long, value, _ := strings.Cut(arg, "=")
+		switch {
+		case long == "--method":
+			if m := strings.ToUpper(value); m != "GET" && m != "HEAD" {
+				return fmt.Errorf("wget with method %s is not allowed", value)
+			}
+		case wgetWriteFlags[long]:
+			return fmt.Errorf("wget %s is not allowed", long)
+		case arg == "--output-document":
+			toStdout = next == "-"
+		case long == "--output-document":
+			toStdout = value == "-"
+		case arg == "-O" || strings.HasSuffix(arg, "O") && arg[0] == '-' && arg[1] != '-':
+			// -O -, or combined with other short flags, e.g. -qO -
+			toStdout = next == "-"
+		case strings.HasSuffix(arg, "O-") && arg[0] == '-' && arg[1] != '-':
+			// -O-, or combined with other short flags, e.g. -qO-
+			toStdout = true
+		}
+	}
+	if !toStdout {
+		return fmt.Errorf("wget is only allowed with -O - to write to stdout")
+	}
+	return nil
+}
+
+// callArgs returns the arguments of a call as strings, unquoting them where possible.
+func callArgs(call *syntax.CallExpr) []string {
+	var args []string
+	for _, arg := range call.Args {
+		lit := arg.Lit()
+		if lit == "" {
+			var sb strings.Builder
+			syntax.NewPrinter().Print(&sb, arg)
+			lit = strings.Trim(sb.String(), "'\"")
+		}
+		if lit != "" {
+			args = append(args, lit)
+		}
+	}
+	return args
+}
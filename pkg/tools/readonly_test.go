@@ -0,0 +1,109 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import "testing"
+
+func TestCheckReadOnly(t *testing.T) {
+	testCases := []struct {
+		tool    Tool
+		command string
+		allowed bool
+	}{
+		{&Kubectl{}, "kubectl get pods -A", true},
+		{&Kubectl{}, "kubectl describe deployment nginx", true},
+		{&Kubectl{}, "kubectl logs nginx --tail=100", true},
+		{&Kubectl{}, "kubectl top nodes", true},
+		{&Kubectl{}, "kubectl get pods | grep Crash", true},
+		{&Kubectl{}, "kubectl get pods 2>/dev/null", true},
+		{&Kubectl{}, "kubectl delete pod nginx", false},
+		{&Kubectl{}, "kubectl apply -f deploy.yaml", false},
+		{&Kubectl{}, "kubectl rollout restart deployment nginx", false},
+		{&Kubectl{}, "kubectl exec nginx -- ls", false},
+		{&Kubectl{}, "kubectl get pods > pods.txt", false},
+		{&Kubectl{}, "kubectl get pods; kubectl delete pod nginx", false},
+		{&Kubectl{}, "kubectl -n kube-system get pods", true},
+		{&Kubectl{}, "kubectl --context prod get pods", true},
+		{&Kubectl{}, "kubectl --kubeconfig ~/.kube/prod -l app=web get pods", true},
+		{&Kubectl{}, "kubectl -n get delete pod x", false},
+		{&Kubectl{}, "kubectl --context get delete pod x", false},
+		{&BashTool{}, "cat /etc/hosts | grep localhost", true},
+		{&BashTool{}, "helm list -A", true},
+		{&BashTool{}, "curl -s https://example.com/healthz", true},
+		{&BashTool{}, "echo $(kubectl delete ns prod)", false},
+		{&BashTool{}, "rm -rf /tmp/x", false},
+		{&BashTool{}, "sed -i s/a/b/ config.yaml", false},
+		{&BashTool{}, "helm upgrade web ./chart", false},
+		{&BashTool{}, "curl -X POST https://example.com/api", false},
+		{&BashTool{}, "bash -c 'kubectl delete pod nginx'", false},
+		{&BashTool{}, "echo hi >> notes.txt", false},
+		{&BashTool{}, "find /var/log -name '*.log'", true},
+		{&BashTool{}, "find /tmp -name '*.yaml' -delete", false},
+		{&BashTool{}, "find . -exec rm {} ;", false},
+		{&BashTool{}, "python3 script.py", true},
+		{&BashTool{}, "python3 -c 'import os; os.remove(\"x\")'", false},
+		{&BashTool{}, "node -e 'require(\"fs\").rmSync(\"x\")'", false},
+		{&BashTool{}, "perl -ne 'unlink $_'", false},
+		{&BashTool{}, "ruby -e 'File.delete(\"x\")'", false},
+		{&BashTool{}, "curl -sX GET https://example.com/api", true},
+		{&BashTool{}, "curl -sH 'X-Foo: 1' https://example.com/api", true},
+		{&BashTool{}, "curl -sXPOST https://example.com/api", false},
+		{&BashTool{}, "curl --request=DELETE https://example.com/api", false},
+		{&BashTool{}, "curl -sd@body.json https://example.com/api", false},
+		{&BashTool{}, "curl -so out.html https://example.com", false},
+		{&BashTool{}, "wget -qO- https://example.com", true},
+		{&BashTool{}, "wget -O - https://example.com", true},
+		{&BashTool{}, "wget --output-document - https://example.com", true},
+		{&BashTool{}, "wget https://example.com/file.tar.gz", false},
+		{&BashTool{}, "wget -qO- --post-data 'a=b' https://example.com/api", false},
+		{&BashTool{}, "wget -qO- --method=DELETE https://example.com/api", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			err := CheckReadOnly(tc.tool, map[string]any{"command": tc.command})
+			if tc.allowed && err != nil {
+				t.Errorf("expected %q to be allowed, got %v", tc.command, err)
+			}
+			if !tc.allowed && err == nil {
+				t.Errorf("expected %q to be rejected", tc.command)
+			}
+		})
+	}
+}
+
+func TestCheckReadOnly_OtherTools(t *testing.T) {
+	testCases := []struct {
+		modifiesResource string
+		allowed          bool
+	}{
+		{"no", true},
+		{"yes", false},
+		{"unknown", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.modifiesResource, func(t *testing.T) {
+			tool := &CustomTool{config: CustomToolConfig{Name: "custom", ModifiesResource: tc.modifiesResource}}
+			err := CheckReadOnly(tool, map[string]any{})
+			if tc.allowed && err != nil {
+				t.Errorf("expected a tool reporting %q to be allowed, got %v", tc.modifiesResource, err)
+			}
+			if !tc.allowed && err == nil {
+				t.Errorf("expected a tool reporting %q to be rejected", tc.modifiesResource)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// RedactedValue replaces masked secrets.
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactionPatterns match common credentials. If a pattern has a
+// capture group, only the first group is masked, so the model keeps the
+// context of the secret (e.g. the name of the variable holding it).
+var DefaultRedactionPatterns = []string{
+	// Bearer tokens, e.g. in Authorization headers
+	`(?i)\bbearer[ \t]+([A-Za-z0-9\-._~+/]{16,}=*)`,
+	// JSON Web Tokens, e.g. service account tokens
+	`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`,
+	// PEM private keys
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	// Client keys in kubeconfigs
+	`(?i)\bclient-key-data[ \t]*:[ \t]*(\S+)`,
+	// Passwords, tokens and keys assigned in configuration, environment variables or command lines
+	`(?i)(?:\b|_)(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|secret[_-]?key|client[_-]?secret)["']?[ \t]*[:=][ \t]*["']?([^\s"',;]{4,})`,
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// GitHub tokens
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	// Slack tokens
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	// Google API keys
+	`\bAIza[0-9A-Za-z_-]{35}\b`,
+}
+
+var (
+	secretKindRegex   = regexp.MustCompile(`(?m)^[ \t-]*kind:[ \t]*["']?Secret["']?[ \t]*$`)
+	secretDataRegex   = regexp.MustCompile(`^[ \t-]*(?:data|stringData):[ \t]*$`)
+	yamlKeyValueRegex = regexp.MustCompile(`^([ \t-]*[^\s:]+:)[ \t]*(\S.*)$`)
+	// lastAppliedDataRegex matches the data of a Secret in the JSON of the
+	// kubectl.kubernetes.io/last-applied-configuration annotation.
+	lastAppliedDataRegex = regexp.MustCompile(`("(?:data|stringData)"[ \t]*:[ \t]*)\{[^{}]*\}`)
+)
+
+// Redactor masks secrets in content sent to the LLM: the data of Kubernetes
+// Secrets, and strings matching credential patterns.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor creates a Redactor for the default patterns and extraPatterns.
+func NewRedactor(extraPatterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, pattern := range append(append([]string{}, DefaultRedactionPatterns...), extraPatterns...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact returns output with secrets masked. Outputs other than
+// *sandbox.ExecResult, strings, maps and slices are returned unchanged.
+func (r *Redactor) Redact(output any) any {
+	switch v := output.(type) {
+	case *sandbox.ExecResult:
+		if v == nil {
+			return output
+		}
+		result := *v
+		result.Stdout = r.RedactString(v.Stdout)
+		result.Stderr = r.RedactString(v.Stderr)
+		result.Error = r.RedactString(v.Error)
+		return &result
+	case string:
+		return r.RedactString(v)
+	case map[string]any, []any:
+		masked, _ := maskSecretObjects(v)
+		return r.redactStrings(masked)
+	default:
+		return output
+	}
+}
+
+// RedactString returns s with secrets masked.
+func (r *Redactor) RedactString(s string) string {
+	s = maskSecretData(s)
+	for _, re := range r.patterns {
+		s = maskMatches(re, s)
+	}
+	return s
+}
+
+func (r *Redactor) redactStrings(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = r.redactStrings(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = r.redactStrings(value)
+		}
+	case string:
+		return r.RedactString(v)
+	}
+	return v
+}
+
+// maskMatches replaces the matches of re in s, or their first capture group.
+func maskMatches(re *regexp.Regexp, s string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(s, RedactedValue)
+	}
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m[2] < 0 {
+			continue
+		}
+		sb.WriteString(s[last:m[2]])
+		sb.WriteString(RedactedValue)
+		last = m[3]
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// maskSecretData masks the data of the Secrets in s, which may be the JSON or
+// YAML output of kubectl.
+func maskSecretData(s string) string {
+	if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "{") {
+		var v any
+		if err := json.Unmarshal([]byte(trimmed), &v); err == nil {
+			masked, found := maskSecretObjects(v)
+			if !found {
+				return s
+			}
+			b, err := json.MarshalIndent(masked, "", "    ")
+			if err != nil {
+				return s
+			}
+			return string(b) + "\n"
+		}
+	}
+	if !secretKindRegex.MatchString(s) {
+		return s
+	}
+	return maskSecretYAML(s)
+}
+
+// maskSecretYAML masks the values under the data and stringData fields of s,
+// a YAML document containing Secrets. Other objects in the same document,
+// like ConfigMaps in a list, are masked too.
+func maskSecretYAML(s string) string {
+	lines := strings.Split(s, "\n")
+	dataIndent := -1
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " \t-"))
+		if dataIndent >= 0 {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if indent > dataIndent {
+				if m := yamlKeyValueRegex.FindStringSubmatch(line); m != nil {
+					// Keep the indicator of a multi-line value, its lines are masked below
+					if !strings.HasPrefix(m[2], "|") && !strings.HasPrefix(m[2], ">") {
+						lines[i] = m[1] + " " + RedactedValue
+					}
+				} else {
+					// A line of a multi-line value
+					lines[i] = line[:indent] + RedactedValue
+				}
+				continue
+			}
+			dataIndent = -1
+		}
+		if secretDataRegex.MatchString(line) {
+			dataIndent = indent
+			continue
+		}
+		lines[i] = lastAppliedDataRegex.ReplaceAllString(line, `${1}"`+RedactedValue+`"`)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maskSecretObjects returns v with the data of the Secret objects in it
+// masked, and whether any Secret was found. Maps and slices are copied.
+func maskSecretObjects(v any) (any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		isSecret := v["kind"] == "Secret"
+		found := isSecret
+		masked := make(map[string]any, len(v))
+		for key, value := range v {
+			if isSecret && (key == "data" || key == "stringData") {
+				if data, ok := value.(map[string]any); ok {
+					maskedData := make(map[string]any, len(data))
+					for name := range data {
+						maskedData[name] = RedactedValue
+					}
+					masked[key] = maskedData
+					continue
+				}
+			}
+			if isSecret && key == "metadata" {
+				value = maskLastApplied(value)
+			}
+			maskedValue, foundInValue := maskSecretObjects(value)
+			masked[key] = maskedValue
+			found = found || foundInValue
+		}
+		return masked, found
+	case []any:
+		found := false
+		masked := make([]any, len(v))
+		for i, value := range v {
+			maskedValue, foundInValue := maskSecretObjects(value)
+			masked[i] = maskedValue
+			found = found || foundInValue
+		}
+		return masked, found
+	default:
+		return v, false
+	}
+}
+
+// maskLastApplied masks the data in the last-applied-configuration annotation
+// of the metadata of a Secret.
+func maskLastApplied(metadata any) any {
+	m, ok := metadata.(map[string]any)
+	if !ok {
+		return metadata
+	}
+	annotations, ok := m["annotations"].(map[string]any)
+	if !ok {
+		return metadata
+	}
+	const key = "kubectl.kubernetes.io/last-applied-configuration"
+	lastApplied, ok := annotations[key].(string)
+	if !ok {
+		return metadata
+	}
+	maskedAnnotations := make(map[string]any, len(annotations))
+	for k, v := range annotations {
+		maskedAnnotations[k] = v
+	}
+	maskedAnnotations[key] = lastAppliedDataRegex.ReplaceAllString(lastApplied, `${1}"`+RedactedValue+`"`)
+	maskedMetadata := make(map[string]any, len(m))
+	for k, v := range m {
+		maskedMetadata[k] = v
+	}
+	maskedMetadata["annotations"] = maskedAnnotations
+	return maskedMetadata
+}
@@ -0,0 +1,658 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// DefaultSchemaCacheTTL is how long the API resources and schemas of a
+// cluster are reused before they are fetched again.
+const DefaultSchemaCacheTTL = time.Hour
+
+const (
+	// maxSummaryKinds caps the number of custom resource kinds listed in the
+	// system prompt.
+	maxSummaryKinds = 100
+	// maxFieldDescriptionBytes caps the descriptions of the fields listed by
+	// schema_lookup, the description of the field looked up is kept whole.
+	maxFieldDescriptionBytes = 300
+	// maxRecursiveLines caps the fields listed by a recursive lookup.
+	maxRecursiveLines = 500
+)
+
+// APIResource is a resource type served by a cluster, as listed by
+// `kubectl api-resources`.
+type APIResource struct {
+	// Name is the plural name of the resource, e.g. deployments.
+	Name       string
+	ShortNames []string
+	Group      string
+	Version    string
+	Kind       string
+	Namespaced bool
+}
+
+// QualifiedName is the name of the resource with its group, e.g.
+// deployments.apps.
+func (r *APIResource) QualifiedName() string {
+	if r.Group == "" {
+		return r.Name
+	}
+	return r.Name + "." + r.Group
+}
+
+// APIVersion is the group and version of the resource, e.g. apps/v1.
+func (r *APIResource) APIVersion() string {
+	if r.Group == "" {
+		return r.Version
+	}
+	return r.Group + "/" + r.Version
+}
+
+// jsonSchema is the part of the OpenAPI v3 schema of a CRD that schema_lookup
+// reports.
+type jsonSchema struct {
+	Type        string                 `json:"type"`
+	Format      string                 `json:"format"`
+	Description string                 `json:"description"`
+	Required    []string               `json:"required"`
+	Properties  map[string]*jsonSchema `json:"properties"`
+	Items       *jsonSchema            `json:"items"`
+	Enum        []any                  `json:"enum"`
+	// AdditionalProperties is either a boolean or the schema of the values of
+	// a map.
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	IntOrString          bool            `json:"x-kubernetes-int-or-string"`
+	PreserveUnknown      bool            `json:"x-kubernetes-preserve-unknown-fields"`
+}
+
+// mapValues returns the schema of the values of a map, or nil if s is not a
+// map.
+func (s *jsonSchema) mapValues() *jsonSchema {
+	if len(s.AdditionalProperties) == 0 || s.AdditionalProperties[0] != '{' {
+		return nil
+	}
+	var values jsonSchema
+	if err := json.Unmarshal(s.AdditionalProperties, &values); err != nil {
+		return nil
+	}
+	return &values
+}
+
+// fields returns the schema holding the fields of s: s itself for objects,
+// the schema of the items of arrays and of the values of maps.
+func (s *jsonSchema) fields() *jsonSchema {
+	for s != nil && len(s.Properties) == 0 {
+		switch {
+		case s.Items != nil:
+			s = s.Items
+		case s.mapValues() != nil:
+			s = s.mapValues()
+		default:
+			return s
+		}
+	}
+	return s
+}
+
+// typeName renders the type of s the way kubectl explain does.
+func (s *jsonSchema) typeName() string {
+	switch {
+	case s.IntOrString:
+		return "IntOrString"
+	case s.Type == "array" && s.Items != nil:
+		return "[]" + s.Items.typeName()
+	case s.Type == "array":
+		return "[]Object"
+	case s.mapValues() != nil:
+		return "map[string]" + s.mapValues().typeName()
+	case s.Type == "object" || s.Type == "" && (len(s.Properties) > 0 || s.PreserveUnknown):
+		return "Object"
+	case s.Type == "":
+		return "any"
+	}
+	return s.Type
+}
+
+// crdSchema is the schema of the versions of a custom resource.
+type crdSchema struct {
+	Kind     string
+	Group    string
+	Versions map[string]*jsonSchema
+	// StorageVersion is the version the resources are stored as.
+	StorageVersion string
+}
+
+// customResourceDefinition is the part of a CRD the schemas are read from.
+type customResourceDefinition struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name    string `json:"name"`
+			Served  bool   `json:"served"`
+			Storage bool   `json:"storage"`
+			Schema  struct {
+				OpenAPIV3Schema *jsonSchema `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// clusterSchemas are the API resources and schemas of a cluster.
+type clusterSchemas struct {
+	resources []APIResource
+	// crds are the schemas of the custom resources, by qualified name.
+	crds map[string]*crdSchema
+	// explanations are the outputs of kubectl explain for the other resources,
+	// by command.
+	explanations map[string]string
+	collectedAt  time.Time
+}
+
+// SchemaCache caches the API resources and CRD schemas of the clusters, by
+// kubeconfig, so that the schema_lookup tool and the system prompt don't
+// query the cluster again for every lookup. It is safe for concurrent use and
+// can be shared between agents.
+type SchemaCache struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	clusters map[string]*clusterSchemas
+}
+
+// NewSchemaCache returns a cache reusing the schemas of a cluster for ttl,
+// DefaultSchemaCacheTTL if zero.
+func NewSchemaCache(ttl time.Duration) *SchemaCache {
+	if ttl <= 0 {
+		ttl = DefaultSchemaCacheTTL
+	}
+	return &SchemaCache{ttl: ttl, clusters: map[string]*clusterSchemas{}}
+}
+
+// load returns the schemas of the cluster of the kubeconfig of ctx, fetching
+// them if they are not cached or stale.
+func (c *SchemaCache) load(ctx context.Context, executor sandbox.Executor) (*clusterSchemas, error) {
+	kubeconfig, _ := ctx.Value(KubeconfigKey).(string)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cluster, ok := c.clusters[kubeconfig]; ok && time.Since(cluster.collectedAt) < c.ttl {
+		return cluster, nil
+	}
+
+	output, err := runKubectlOutput(ctx, executor, "kubectl api-resources --no-headers")
+	if err != nil {
+		return nil, fmt.Errorf("listing API resources: %w", err)
+	}
+	cluster := &clusterSchemas{
+		resources:    parseAPIResources(output),
+		crds:         map[string]*crdSchema{},
+		explanations: map[string]string{},
+		collectedAt:  time.Now(),
+	}
+	// Without access to the CRDs, their schemas are read with kubectl explain
+	// like the built-in resources
+	if output, err := runKubectlOutput(ctx, executor, "kubectl get customresourcedefinitions -o json"); err == nil {
+		cluster.crds = parseCRDSchemas(output)
+	}
+	c.clusters[kubeconfig] = cluster
+	return cluster, nil
+}
+
+// explain returns the output of the kubectl explain command, cached with the
+// other schemas of the cluster.
+func (c *SchemaCache) explain(ctx context.Context, executor sandbox.Executor, cluster *clusterSchemas, command string) (string, error) {
+	c.mu.Lock()
+	output, ok := cluster.explanations[command]
+	c.mu.Unlock()
+	if ok {
+		return output, nil
+	}
+	output, err := runKubectlOutput(ctx, executor, command)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	cluster.explanations[command] = output
+	c.mu.Unlock()
+	return output, nil
+}
+
+// Summary lists the custom resources of the cluster of the kubeconfig of ctx
+// by API version, for the system prompt.
+func (c *SchemaCache) Summary(ctx context.Context, executor sandbox.Executor) (string, error) {
+	cluster, err := c.load(ctx, executor)
+	if err != nil {
+		return "", err
+	}
+	kinds := map[string][]string{}
+	count := 0
+	for _, resource := range cluster.resources {
+		if _, ok := cluster.crds[resource.QualifiedName()]; !ok || count >= maxSummaryKinds {
+			continue
+		}
+		kind := resource.Kind
+		if !resource.Namespaced {
+			kind += " (cluster-scoped)"
+		}
+		kinds[resource.APIVersion()] = append(kinds[resource.APIVersion()], kind)
+		count++
+	}
+	if count == 0 {
+		return "", nil
+	}
+	versions := make([]string, 0, len(kinds))
+	for version := range kinds {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	var sb strings.Builder
+	for _, version := range versions {
+		fmt.Fprintf(&sb, "- %s: %s\n", version, strings.Join(kinds[version], ", "))
+	}
+	if total := len(cluster.crds); total > count {
+		fmt.Fprintf(&sb, "- ... (%d more)\n", total-count)
+	}
+	return sb.String(), nil
+}
+
+// parseAPIResources parses the output of `kubectl api-resources --no-headers`,
+// whose columns are NAME, SHORTNAMES (possibly empty), APIVERSION, NAMESPACED
+// and KIND.
+func parseAPIResources(output string) []APIResource {
+	var resources []APIResource
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields) > 5 {
+			continue
+		}
+		n := len(fields)
+		resource := APIResource{
+			Name:       fields[0],
+			Kind:       fields[n-1],
+			Namespaced: fields[n-2] == "true",
+		}
+		if n == 5 {
+			resource.ShortNames = strings.Split(fields[1], ",")
+		}
+		resource.Version = fields[n-3]
+		if group, version, ok := strings.Cut(fields[n-3], "/"); ok {
+			resource.Group, resource.Version = group, version
+		}
+		resources = append(resources, resource)
+	}
+	return resources
+}
+
+// parseCRDSchemas parses the schemas of the served versions of a list of CRDs,
+// by qualified name.
+func parseCRDSchemas(output string) map[string]*crdSchema {
+	var list struct {
+		Items []customResourceDefinition `json:"items"`
+	}
+	schemas := map[string]*crdSchema{}
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return schemas
+	}
+	for _, crd := range list.Items {
+		schema := &crdSchema{
+			Kind:     crd.Spec.Names.Kind,
+			Group:    crd.Spec.Group,
+			Versions: map[string]*jsonSchema{},
+		}
+		for _, version := range crd.Spec.Versions {
+			if !version.Served || version.Schema.OpenAPIV3Schema == nil {
+				continue
+			}
+			schema.Versions[version.Name] = version.Schema.OpenAPIV3Schema
+			if version.Storage {
+				schema.StorageVersion = version.Name
+			}
+		}
+		if len(schema.Versions) > 0 {
+			schemas[crd.Metadata.Name] = schema
+		}
+	}
+	return schemas
+}
+
+// runKubectlOutput runs a kubectl command and returns its output, or an
+// error with its stderr if it fails.
+func runKubectlOutput(ctx context.Context, executor sandbox.Executor, command string) (string, error) {
+	result, err := runKubectl(ctx, executor, command)
+	if err != nil {
+		return "", err
+	}
+	if result.Error != "" || result.ExitCode != 0 {
+		message := strings.TrimSpace(result.Stderr)
+		if message == "" {
+			message = result.Error
+		}
+		return "", fmt.Errorf("%s", message)
+	}
+	return result.Stdout, nil
+}
+
+// SchemaLookupTool describes the fields of the resource types of the cluster,
+// from the OpenAPI schemas of the CRDs and kubectl explain.
+type SchemaLookupTool struct {
+	executor sandbox.Executor
+	cache    *SchemaCache
+}
+
+func NewSchemaLookupTool(executor sandbox.Executor, cache *SchemaCache) *SchemaLookupTool {
+	return &SchemaLookupTool{executor: executor, cache: cache}
+}
+
+func (t *SchemaLookupTool) Name() string {
+	return "schema_lookup"
+}
+
+func (t *SchemaLookupTool) Description() string {
+	return `Describes the fields of a resource type of the cluster, including custom resources such as Kyverno policies, from the schemas served by the cluster. Use it before writing or patching a manifest whose exact field names you are not sure of, instead of guessing them.`
+}
+
+func (t *SchemaLookupTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"resource": {
+					Type:        gollm.TypeString,
+					Description: `Resource type: a kind, plural name or short name, optionally with its group, e.g. ClusterPolicy, clusterpolicies.kyverno.io or deploy.`,
+				},
+				"field": {
+					Type:        gollm.TypeString,
+					Description: `Dotted path of the field to describe, e.g. spec.rules.validate. Defaults to the whole resource.`,
+				},
+				"api_version": {
+					Type:        gollm.TypeString,
+					Description: `API version of the schema, e.g. kyverno.io/v2beta1. Defaults to the preferred version of the cluster.`,
+				},
+				"recursive": {
+					Type:        gollm.TypeBoolean,
+					Description: `List all the nested fields with their types, without descriptions.`,
+				},
+			},
+			Required: []string{"resource"},
+		},
+	}
+}
+
+func (t *SchemaLookupTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	resourceArg, _ := args["resource"].(string)
+	field, _ := args["field"].(string)
+	apiVersion, _ := args["api_version"].(string)
+	recursive, _ := args["recursive"].(bool)
+	resourceArg = strings.TrimSpace(resourceArg)
+	if resourceArg == "" {
+		return &sandbox.ExecResult{Error: "resource is required"}, nil
+	}
+
+	cluster, err := t.cache.load(ctx, t.executor)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	resource, err := findAPIResource(cluster.resources, resourceArg)
+	if err != nil {
+		// Accept kubectl explain style paths, e.g. clusterpolicy.spec.rules
+		name, path, ok := strings.Cut(resourceArg, ".")
+		if !ok {
+			return &sandbox.ExecResult{Error: err.Error()}, nil
+		}
+		resource, err = findAPIResource(cluster.resources, name)
+		if err != nil {
+			return &sandbox.ExecResult{Error: err.Error()}, nil
+		}
+		field = strings.Trim(path+"."+field, ".")
+	}
+	field = strings.Trim(strings.TrimSpace(field), ".")
+
+	version := resource.Version
+	if apiVersion != "" {
+		group, v, ok := strings.Cut(apiVersion, "/")
+		if !ok {
+			group, v = resource.Group, apiVersion
+		}
+		if group != resource.Group {
+			return &sandbox.ExecResult{Error: fmt.Sprintf("%s is in the API group %q, not %q", resource.Kind, resource.Group, group)}, nil
+		}
+		version = v
+	}
+
+	if crd, ok := cluster.crds[resource.QualifiedName()]; ok {
+		output, err := describeCRDField(crd, version, field, recursive)
+		if err != nil {
+			return &sandbox.ExecResult{Error: err.Error()}, nil
+		}
+		return &sandbox.ExecResult{Stdout: output}, nil
+	}
+
+	target := resource.QualifiedName()
+	if field != "" {
+		target += "." + field
+	}
+	quoted, err := shellQuote(target)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	command := "kubectl explain " + quoted
+	if apiVersion != "" {
+		explained := APIResource{Group: resource.Group, Version: version}
+		quotedVersion, err := shellQuote(explained.APIVersion())
+		if err != nil {
+			return &sandbox.ExecResult{Error: err.Error()}, nil
+		}
+		command += " --api-version " + quotedVersion
+	}
+	if recursive {
+		command += " --recursive"
+	}
+	output, err := t.cache.explain(ctx, t.executor, cluster, command)
+	if err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	return &sandbox.ExecResult{Stdout: output}, nil
+}
+
+func (t *SchemaLookupTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+func (t *SchemaLookupTool) CheckModifiesResource(args map[string]any) string {
+	return "no"
+}
+
+// findAPIResource finds the resource type name refers to: a kind, plural,
+// singular or short name, optionally qualified with a group.
+func findAPIResource(resources []APIResource, name string) (*APIResource, error) {
+	lower := strings.ToLower(name)
+	var matches []*APIResource
+	for i := range resources {
+		r := &resources[i]
+		if lower == r.QualifiedName() || lower == strings.ToLower(r.Kind)+"."+r.Group {
+			return r, nil
+		}
+		if lower == r.Name || lower == strings.ToLower(r.Kind) || slices.Contains(r.ShortNames, lower) {
+			matches = append(matches, r)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("the server doesn't have a resource type %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	// Like kubectl, prefer the built-in resources, e.g. events over
+	// events.events.k8s.io
+	for _, match := range matches {
+		if match.Group == "" {
+			return match, nil
+		}
+	}
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = match.QualifiedName()
+	}
+	return nil, fmt.Errorf("%q is ambiguous, use one of %s", name, strings.Join(names, ", "))
+}
+
+// describeCRDField renders the schema of the field at path of a custom
+// resource, in the format of kubectl explain.
+func describeCRDField(crd *crdSchema, version string, path string, recursive bool) (string, error) {
+	schema, ok := crd.Versions[version]
+	if !ok {
+		schema, ok = crd.Versions[crd.StorageVersion]
+		version = crd.StorageVersion
+	}
+	if !ok {
+		var versions []string
+		for v := range crd.Versions {
+			versions = append(versions, v)
+		}
+		sort.Strings(versions)
+		return "", fmt.Errorf("%s has no schema for version %s, its versions are %s", crd.Kind, version, strings.Join(versions, ", "))
+	}
+
+	node := schema
+	var parent *jsonSchema
+	var name string
+	var parents []string
+	if path != "" {
+		for _, segment := range strings.Split(path, ".") {
+			fields := node.fields()
+			child, ok := fields.Properties[segment]
+			if !ok {
+				return "", fmt.Errorf("field %q of %s does not exist, the fields of %s are: %s", path, crd.Kind, strings.Join(append([]string{crd.Kind}, parents...), "."), strings.Join(sortedFieldNames(fields), ", "))
+			}
+			parent, node, name = fields, child, segment
+			parents = append(parents, segment)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "GROUP:      %s\nKIND:       %s\nVERSION:    %s\n\n", crd.Group, crd.Kind, version)
+	if path != "" {
+		required := ""
+		if parent != nil && slices.Contains(parent.Required, name) {
+			required = " -required-"
+		}
+		fmt.Fprintf(&sb, "FIELD: %s <%s>%s\n\n", path, node.typeName(), required)
+	}
+	if node.Description != "" {
+		fmt.Fprintf(&sb, "DESCRIPTION:\n%s\n\n", indent(node.Description, "    "))
+	}
+	if len(node.Enum) > 0 {
+		values := make([]string, len(node.Enum))
+		for i, v := range node.Enum {
+			values[i] = fmt.Sprint(v)
+		}
+		fmt.Fprintf(&sb, "ENUM:\n    %s\n\n", strings.Join(values, ", "))
+	}
+
+	fields := node.fields()
+	if len(fields.Properties) == 0 {
+		if fields.PreserveUnknown {
+			sb.WriteString("FIELDS:\n    (any fields are accepted)\n")
+		}
+		return sb.String(), nil
+	}
+	sb.WriteString("FIELDS:\n")
+	if recursive {
+		lines := 0
+		writeFieldTree(&sb, fields, "    ", &lines)
+		if lines >= maxRecursiveLines {
+			fmt.Fprintf(&sb, "    ... (more fields, look up a nested field)\n")
+		}
+		return sb.String(), nil
+	}
+	for _, fieldName := range sortedFieldNames(fields) {
+		child := fields.Properties[fieldName]
+		required := ""
+		if slices.Contains(fields.Required, fieldName) {
+			required = " -required-"
+		}
+		fmt.Fprintf(&sb, "  %s\t<%s>%s\n", fieldName, child.typeName(), required)
+		if description := truncateDescription(child.Description); description != "" {
+			fmt.Fprintf(&sb, "%s\n", indent(description, "    "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// writeFieldTree lists the nested fields of s with their types.
+func writeFieldTree(sb *strings.Builder, s *jsonSchema, prefix string, lines *int) {
+	for _, name := range sortedFieldNames(s) {
+		if *lines >= maxRecursiveLines {
+			return
+		}
+		child := s.Properties[name]
+		fmt.Fprintf(sb, "%s%s\t<%s>\n", prefix, name, child.typeName())
+		*lines++
+		if fields := child.fields(); fields != nil && len(fields.Properties) > 0 {
+			writeFieldTree(sb, fields, prefix+"  ", lines)
+		}
+	}
+}
+
+func sortedFieldNames(s *jsonSchema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// truncateDescription shortens the descriptions of the fields listed, to
+// their first paragraph of at most maxFieldDescriptionBytes.
+func truncateDescription(description string) string {
+	description, _, _ = strings.Cut(strings.TrimSpace(description), "\n\n")
+	if len(description) <= maxFieldDescriptionBytes {
+		return description
+	}
+	cut := strings.LastIndexByte(description[:maxFieldDescriptionBytes], ' ')
+	if cut <= 0 {
+		cut = maxFieldDescriptionBytes
+	}
+	return description[:cut] + "..."
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,192 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+const testAPIResources = `pods                      po           v1                   true         Pod
+deployments               deploy       apps/v1              true         Deployment
+events                    ev           v1                   true         Event
+events                    ev           events.k8s.io/v1     true         Event
+clusterpolicies           cpol         kyverno.io/v1        false        ClusterPolicy
+policies                  pol          kyverno.io/v1        true         Policy
+`
+
+const testCRDs = `{"items": [{
+  "metadata": {"name": "clusterpolicies.kyverno.io"},
+  "spec": {
+    "group": "kyverno.io",
+    "names": {"kind": "ClusterPolicy"},
+    "versions": [{
+      "name": "v1", "served": true, "storage": true,
+      "schema": {"openAPIV3Schema": {
+        "type": "object",
+        "properties": {
+          "spec": {
+            "type": "object",
+            "description": "Spec declares policy behaviors.",
+            "required": ["rules"],
+            "properties": {
+              "validationFailureAction": {"type": "string", "enum": ["Audit", "Enforce"], "description": "Action on violations."},
+              "rules": {
+                "type": "array",
+                "description": "Rules of the policy.",
+                "items": {
+                  "type": "object",
+                  "properties": {
+                    "name": {"type": "string", "description": "Name of the rule."},
+                    "validate": {"type": "object", "x-kubernetes-preserve-unknown-fields": true}
+                  }
+                }
+              }
+            }
+          }
+        }
+      }}
+    }]
+  }
+}]}`
+
+func newTestSchemaLookup() (*SchemaLookupTool, *scriptedExecutor) {
+	outputs := map[string]string{
+		"kubectl api-resources --no-headers":             testAPIResources,
+		"kubectl get customresourcedefinitions -o json":  testCRDs,
+		"kubectl explain deployments.apps.spec.replicas": "FIELD: replicas <integer>\n",
+	}
+	executor := &scriptedExecutor{run: func(command string) *sandbox.ExecResult {
+		output, ok := outputs[command]
+		if !ok {
+			return &sandbox.ExecResult{ExitCode: 1, Stderr: "unexpected command " + command}
+		}
+		return &sandbox.ExecResult{Stdout: output}
+	}}
+	return NewSchemaLookupTool(executor, NewSchemaCache(0)), executor
+}
+
+func TestSchemaLookupTool_CRD(t *testing.T) {
+	tool, executor := newTestSchemaLookup()
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want []string
+	}{
+		{
+			name: "field of a kind",
+			args: map[string]any{"resource": "ClusterPolicy", "field": "spec"},
+			want: []string{"KIND:       ClusterPolicy", "FIELD: spec <Object>", "rules\t<[]Object> -required-", "validationFailureAction\t<string>"},
+		},
+		{
+			name: "kubectl explain style path",
+			args: map[string]any{"resource": "cpol.spec.rules"},
+			want: []string{"FIELD: spec.rules <[]Object> -required-", "name\t<string>", "Name of the rule."},
+		},
+		{
+			name: "recursive",
+			args: map[string]any{"resource": "clusterpolicies.kyverno.io", "recursive": true},
+			want: []string{"    spec\t<Object>\n      rules\t<[]Object>\n        name\t<string>\n        validate\t<Object>\n      validationFailureAction\t<string>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tool.Run(ctx, tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := result.(*sandbox.ExecResult)
+			if got.Error != "" {
+				t.Fatalf("unexpected error: %s", got.Error)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got.Stdout, want) {
+					t.Errorf("expected output to contain %q, got:\n%s", want, got.Stdout)
+				}
+			}
+		})
+	}
+
+	// The resources and schemas are only fetched once
+	if len(executor.commands) != 2 {
+		t.Errorf("expected the schemas to be cached, ran %v", executor.commands)
+	}
+}
+
+func TestSchemaLookupTool_Errors(t *testing.T) {
+	tool, _ := newTestSchemaLookup()
+	ctx := context.Background()
+
+	for args, want := range map[string]string{
+		"Gateway":           `the server doesn't have a resource type "Gateway"`,
+		"cpol.spec.rulez":   `field "spec.rulez" of ClusterPolicy does not exist, the fields of ClusterPolicy.spec are: rules, validationFailureAction`,
+		"clusterpolicy.foo": `field "foo" of ClusterPolicy does not exist, the fields of ClusterPolicy are: spec`,
+	} {
+		result, err := tool.Run(ctx, map[string]any{"resource": args})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := result.(*sandbox.ExecResult).Error; got != want {
+			t.Errorf("Run(%q) error = %q, want %q", args, got, want)
+		}
+	}
+}
+
+func TestSchemaLookupTool_BuiltIn(t *testing.T) {
+	tool, _ := newTestSchemaLookup()
+	result, err := tool.Run(context.Background(), map[string]any{"resource": "deploy", "field": "spec.replicas"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := result.(*sandbox.ExecResult); got.Stdout != "FIELD: replicas <integer>\n" {
+		t.Errorf("expected the output of kubectl explain, got %+v", got)
+	}
+}
+
+func TestSchemaCache_Summary(t *testing.T) {
+	tool, executor := newTestSchemaLookup()
+	summary, err := tool.cache.Summary(context.Background(), executor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "- kyverno.io/v1: ClusterPolicy (cluster-scoped)\n"; summary != want {
+		t.Errorf("Summary() = %q, want %q", summary, want)
+	}
+}
+
+func TestFindAPIResource(t *testing.T) {
+	resources := parseAPIResources(testAPIResources)
+	for name, want := range map[string]string{
+		"po":                   "pods",
+		"Deployment":           "deployments.apps",
+		"events":               "events",
+		"events.events.k8s.io": "events.events.k8s.io",
+		"policy.kyverno.io":    "policies.kyverno.io",
+	} {
+		got, err := findAPIResource(resources, name)
+		if err != nil {
+			t.Errorf("findAPIResource(%q) error = %v", name, err)
+			continue
+		}
+		if got.QualifiedName() != want {
+			t.Errorf("findAPIResource(%q) = %s, want %s", name, got.QualifiedName(), want)
+		}
+	}
+}
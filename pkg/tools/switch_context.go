@@ -0,0 +1,211 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+)
+
+// KubeContext is a named kubeconfig context the agent is allowed to operate on.
+type KubeContext struct {
+	// Name is the name the LLM and user refer to the cluster by.
+	Name string `json:"name"`
+	// Context is the kubeconfig context name. Defaults to Name.
+	Context string `json:"context,omitempty"`
+	// Description helps the LLM pick the right cluster, e.g. "production in us-east1".
+	Description string `json:"description,omitempty"`
+}
+
+func (c KubeContext) contextName() string {
+	if c.Context != "" {
+		return c.Context
+	}
+	return c.Name
+}
+
+// ContextSwitcher tracks the kubeconfig context commands should target.
+// Switching never modifies the user's kubeconfig; instead a small kubeconfig
+// setting only current-context is written and placed first in KUBECONFIG,
+// which takes precedence when kubectl merges the files.
+type ContextSwitcher struct {
+	mu       sync.Mutex
+	contexts []KubeContext
+	current  *KubeContext
+	dir      string
+	// sandbox is the executor the override kubeconfig is uploaded to, if
+	// commands don't run on the agent's filesystem.
+	sandbox sandbox.Executor
+}
+
+// NewContextSwitcher creates a ContextSwitcher for contexts, writing its
+// override kubeconfig to dir.
+func NewContextSwitcher(contexts []KubeContext, dir string) *ContextSwitcher {
+	return &ContextSwitcher{contexts: contexts, dir: dir}
+}
+
+// Current returns the name of the context switched to, or "" if commands
+// target the kubeconfig's own current-context.
+func (s *ContextSwitcher) Current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return ""
+	}
+	return s.current.Name
+}
+
+// Kubeconfig returns the KUBECONFIG value commands should use given the base kubeconfig.
+func (s *ContextSwitcher) Kubeconfig(base string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return base
+	}
+	override := s.overridePath()
+	if base == "" {
+		return override
+	}
+	return override + string(os.PathListSeparator) + base
+}
+
+// SetSandbox makes the switcher upload its override kubeconfig to the same
+// path in executor, for sandboxes that don't share the agent's filesystem
+// (e.g. the k8s sandbox). The override of the current context, if any, is
+// uploaded right away, as executor may be a new sandbox.
+func (s *ContextSwitcher) SetSandbox(ctx context.Context, executor sandbox.Executor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandbox = executor
+	if s.current == nil {
+		return nil
+	}
+	return s.upload(ctx)
+}
+
+// Switch makes name the current context.
+func (s *ContextSwitcher) Switch(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.contexts {
+		if s.contexts[i].Name != name {
+			continue
+		}
+		if err := os.MkdirAll(s.dir, 0o700); err != nil {
+			return err
+		}
+		content := fmt.Sprintf("apiVersion: v1\nkind: Config\ncurrent-context: %q\n", s.contexts[i].contextName())
+		if err := os.WriteFile(s.overridePath(), []byte(content), 0o600); err != nil {
+			return fmt.Errorf("writing kubeconfig override: %w", err)
+		}
+		if err := s.upload(ctx); err != nil {
+			return err
+		}
+		s.current = &s.contexts[i]
+		return nil
+	}
+	return fmt.Errorf("unknown context %q, available contexts: %s", name, strings.Join(s.names(), ", "))
+}
+
+// upload copies the override kubeconfig to the sandbox, if there is one.
+func (s *ContextSwitcher) upload(ctx context.Context) error {
+	if s.sandbox == nil {
+		return nil
+	}
+	if err := s.sandbox.Upload(ctx, s.overridePath(), s.overridePath()); err != nil {
+		return fmt.Errorf("copying kubeconfig override to the sandbox: %w", err)
+	}
+	return nil
+}
+
+func (s *ContextSwitcher) names() []string {
+	var names []string
+	for _, c := range s.contexts {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func (s *ContextSwitcher) overridePath() string {
+	return filepath.Join(s.dir, "kubeconfig-context")
+}
+
+// SwitchContextTool lets the LLM change which cluster subsequent commands target.
+type SwitchContextTool struct {
+	switcher *ContextSwitcher
+}
+
+func NewSwitchContextTool(switcher *ContextSwitcher) *SwitchContextTool {
+	return &SwitchContextTool{switcher: switcher}
+}
+
+func (t *SwitchContextTool) Name() string {
+	return "switch_context"
+}
+
+func (t *SwitchContextTool) Description() string {
+	var sb strings.Builder
+	sb.WriteString("Switches the Kubernetes cluster (kubeconfig context) that subsequent kubectl and bash commands target. Available contexts:\n")
+	for _, c := range t.switcher.contexts {
+		sb.WriteString("- " + c.Name)
+		if c.Description != "" {
+			sb.WriteString(": " + c.Description)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (t *SwitchContextTool) FunctionDefinition() *gollm.FunctionDefinition {
+	return &gollm.FunctionDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: &gollm.Schema{
+			Type: gollm.TypeObject,
+			Properties: map[string]*gollm.Schema{
+				"context": {
+					Type:        gollm.TypeString,
+					Description: `The name of the context to switch to.`,
+				},
+			},
+			Required: []string{"context"},
+		},
+	}
+}
+
+func (t *SwitchContextTool) Run(ctx context.Context, args map[string]any) (any, error) {
+	name, _ := args["context"].(string)
+	if err := t.switcher.Switch(ctx, name); err != nil {
+		return &sandbox.ExecResult{Error: err.Error()}, nil
+	}
+	return fmt.Sprintf("Switched to context %q. Subsequent commands will target this cluster.", name), nil
+}
+
+func (t *SwitchContextTool) IsInteractive(args map[string]any) (bool, error) {
+	return false, nil
+}
+
+// CheckModifiesResource reports "yes" so that the user is asked to confirm
+// before the agent changes which cluster it operates on.
+func (t *SwitchContextTool) CheckModifiesResource(args map[string]any) string {
+	return "yes"
+}
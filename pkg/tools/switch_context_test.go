@@ -0,0 +1,82 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContextSwitcher(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	switcher := NewContextSwitcher([]KubeContext{{Name: "production", Context: "prod"}, {Name: "staging"}}, dir)
+
+	if got := switcher.Kubeconfig("/home/user/.kube/config"); got != "/home/user/.kube/config" {
+		t.Errorf("Kubeconfig() before switching = %q, want the base kubeconfig", got)
+	}
+	if err := switcher.Switch(ctx, "dev"); err == nil {
+		t.Errorf("expected switching to an unknown context to fail")
+	}
+
+	if err := switcher.Switch(ctx, "production"); err != nil {
+		t.Fatal(err)
+	}
+	override := filepath.Join(dir, "kubeconfig-context")
+	if got, want := switcher.Kubeconfig("/home/user/.kube/config"), override+string(os.PathListSeparator)+"/home/user/.kube/config"; got != want {
+		t.Errorf("Kubeconfig() = %q, want %q", got, want)
+	}
+	content, err := os.ReadFile(override)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `current-context: "prod"`) {
+		t.Errorf("unexpected override kubeconfig %q", content)
+	}
+}
+
+func TestContextSwitcher_Sandbox(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	override := filepath.Join(dir, "kubeconfig-context")
+	switcher := NewContextSwitcher([]KubeContext{{Name: "production", Context: "prod"}, {Name: "staging"}}, dir)
+
+	executor := &MockExecutor{}
+	if err := switcher.SetSandbox(ctx, executor); err != nil {
+		t.Fatal(err)
+	}
+	if len(executor.Uploaded) != 0 {
+		t.Errorf("expected nothing to be uploaded before switching, got %v", executor.Uploaded)
+	}
+
+	if err := switcher.Switch(ctx, "staging"); err != nil {
+		t.Fatal(err)
+	}
+	if got := executor.Uploaded[override]; !strings.Contains(got, `current-context: "staging"`) {
+		t.Errorf("expected the override kubeconfig to be uploaded to the sandbox, got %q", got)
+	}
+
+	// A new sandbox gets the override of the current context right away.
+	executor = &MockExecutor{}
+	if err := switcher.SetSandbox(ctx, executor); err != nil {
+		t.Fatal(err)
+	}
+	if got := executor.Uploaded[override]; !strings.Contains(got, `current-context: "staging"`) {
+		t.Errorf("expected the override kubeconfig to be uploaded to the new sandbox, got %q", got)
+	}
+}
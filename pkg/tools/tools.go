@@ -17,6 +17,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"os"
@@ -27,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/GoogleCloudPlatform/kubectl-ai/gollm"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
 	"github.com/google/uuid"
@@ -142,8 +144,19 @@ func (t *ToolCall) Description() string {
 		return fmt.Sprintf("[MCP: %s] %s(%s)", mcpTool.serverName, t.name, strings.Join(args, ", "))
 	}
 
+	// Tools that build a command from their arguments show the command
+	if builder, ok := t.tool.(commandBuilder); ok {
+		if command, err := builder.buildCommand(t.arguments); err == nil {
+			return command
+		}
+	}
+
 	// Default formatting for non-MCP tools
 	if command, ok := t.arguments["command"]; ok {
+		if stdin, ok := t.arguments["stdin"].(string); ok && stdin != "" {
+			// Show the content piped to the command, as the user approves it too
+			return fmt.Sprintf("%s <<'EOF'\n%s\nEOF", command.(string), strings.TrimRight(stdin, "\n"))
+		}
 		return command.(string)
 	}
 	var args []string
@@ -154,6 +167,12 @@ func (t *ToolCall) Description() string {
 	return fmt.Sprintf("%s(%s)", t.name, strings.Join(args, ", "))
 }
 
+// commandBuilder is implemented by tools that run a command built from their
+// arguments, rather than one written by the LLM.
+type commandBuilder interface {
+	buildCommand(args map[string]any) (string, error)
+}
+
 // ParseToolInvocation parses a request from the LLM into a tool call.
 func (t *Tools) ParseToolInvocation(ctx context.Context, name string, arguments map[string]any) (*ToolCall, error) {
 	tool := t.Lookup(name)
@@ -176,18 +195,17 @@ type InvokeToolOptions struct {
 
 	// Executor is the executor for tool execution
 	Executor sandbox.Executor
-}
 
-type ToolRequestEvent struct {
-	CallID    string         `json:"id,omitempty"`
-	Name      string         `json:"name,omitempty"`
-	Arguments map[string]any `json:"arguments,omitempty"`
-}
+	// Timeout bounds how long the tool may run, zero means no limit.
+	Timeout time.Duration
+
+	// MaxOutputBytes is the maximum size of stdout and stderr kept from each
+	// command the tool runs, zero means no limit.
+	MaxOutputBytes int
 
-type ToolResponseEvent struct {
-	CallID   string `json:"id,omitempty"`
-	Response any    `json:"response,omitempty"`
-	Error    string `json:"error,omitempty"`
+	// OutputStream, if set, receives the output of the commands the tool runs
+	// as it is produced.
+	OutputStream sandbox.OutputStream
 }
 
 // InvokeTool handles the execution of a single action
@@ -197,11 +215,12 @@ func (t *ToolCall) InvokeTool(ctx context.Context, opt InvokeToolOptions) (any,
 	callID := uuid.NewString()
 	recorder.Write(ctx, &journal.Event{
 		Timestamp: time.Now(),
-		Action:    "tool-request",
-		Payload: ToolRequestEvent{
+		Action:    journal.ActionToolRequest,
+		Payload: &journal.ToolExec{
 			CallID:    callID,
 			Name:      t.name,
 			Arguments: t.arguments,
+			Runbook:   journal.RunbookFromContext(ctx),
 		},
 	})
 
@@ -210,11 +229,30 @@ func (t *ToolCall) InvokeTool(ctx context.Context, opt InvokeToolOptions) (any,
 	if opt.Executor != nil {
 		ctx = context.WithValue(ctx, ExecutorKey, opt.Executor)
 	}
+	if opt.MaxOutputBytes > 0 {
+		ctx = sandbox.WithOutputLimit(ctx, opt.MaxOutputBytes)
+	}
+	if opt.OutputStream != nil {
+		ctx = sandbox.WithOutputStream(ctx, opt.OutputStream)
+	}
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
+	}
 
 	response, err := t.tool.Run(ctx, t.arguments)
+	if opt.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		message := fmt.Sprintf("%s timed out after %s", t.name, opt.Timeout)
+		if result, ok := response.(*sandbox.ExecResult); ok && result != nil {
+			result.Error = message
+		} else if err != nil {
+			err = fmt.Errorf("%s: %w", message, err)
+		}
+	}
 
 	{
-		ev := ToolResponseEvent{
+		ev := &journal.ToolExec{
 			CallID:   callID,
 			Response: response,
 		}
@@ -223,7 +261,7 @@ func (t *ToolCall) InvokeTool(ctx context.Context, opt InvokeToolOptions) (any,
 		}
 		recorder.Write(ctx, &journal.Event{
 			Timestamp: time.Now(),
-			Action:    "tool-response",
+			Action:    journal.ActionToolResponse,
 			Payload:   ev,
 		})
 	}
@@ -231,6 +269,35 @@ func (t *ToolCall) InvokeTool(ctx context.Context, opt InvokeToolOptions) (any,
 	return response, err
 }
 
+// Preview returns a preview of the tool call's effect if the tool implements Previewer.
+// It returns "" if the tool does not support previews.
+func (t *ToolCall) Preview(ctx context.Context, opt InvokeToolOptions) (string, error) {
+	previewer, ok := t.tool.(Previewer)
+	if !ok {
+		return "", nil
+	}
+	ctx = context.WithValue(ctx, KubeconfigKey, opt.Kubeconfig)
+	ctx = context.WithValue(ctx, WorkDirKey, opt.WorkDir)
+	if opt.Executor != nil {
+		ctx = context.WithValue(ctx, ExecutorKey, opt.Executor)
+	}
+	return previewer.Preview(ctx, t.arguments)
+}
+
+// stdinSchema describes the optional stdin argument of tools that run commands.
+var stdinSchema = &gollm.Schema{
+	Type:        gollm.TypeString,
+	Description: `Optional content passed to the command's standard input, e.g. a manifest for "kubectl apply -f -". Use this instead of embedding large content in the command with heredocs or echo.`,
+}
+
+// withStdinArg returns a context that passes the stdin argument, if any, to the command.
+func withStdinArg(ctx context.Context, args map[string]any) context.Context {
+	if stdin, ok := args["stdin"].(string); ok && stdin != "" {
+		return sandbox.WithStdin(ctx, strings.NewReader(stdin))
+	}
+	return ctx
+}
+
 // ToolResultToMap converts an arbitrary result to a map[string]any
 func ToolResultToMap(result any) (map[string]any, error) {
 	// Handle simple string results (common with MCP tools)
@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/atotto/clipboard"
+)
+
+// fencedBlockRegex matches fenced code blocks in markdown, capturing their content.
+var fencedBlockRegex = regexp.MustCompile("(?ms)^[ \t]*```[^\n]*\n(.*?)^[ \t]*```")
+
+// copyableBlocks returns the code blocks in the agent's responses and the
+// commands it ran, in the order they appear in the conversation.
+func copyableBlocks(messages []*api.Message) []string {
+	var blocks []string
+	for _, msg := range messages {
+		payload, ok := msg.Payload.(string)
+		if !ok {
+			continue
+		}
+		switch {
+		case msg.Type == api.MessageTypeToolCallRequest:
+			blocks = append(blocks, payload)
+		case msg.Type == api.MessageTypeText && (msg.Source == api.MessageSourceModel || msg.Source == api.MessageSourceAgent):
+			for _, match := range fencedBlockRegex.FindAllStringSubmatch(payload, -1) {
+				if block := strings.TrimRight(match[1], "\n"); strings.TrimSpace(block) != "" {
+					blocks = append(blocks, block)
+				}
+			}
+		}
+	}
+	return blocks
+}
+
+// handleCopyCommand handles "copy" (list the blocks that can be copied) and
+// "copy <n>" (copy block n to the clipboard).
+func (m *model) handleCopyCommand(value string) {
+	blocks := copyableBlocks(m.messages)
+	arg := strings.TrimSpace(strings.TrimPrefix(value, "copy"))
+	if arg == "" {
+		if len(blocks) == 0 {
+			m.notify("There are no code blocks or commands to copy.")
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("Code blocks and commands (use `copy <n>` to copy one):\n\n")
+		for i, block := range blocks {
+			preview, _, _ := strings.Cut(block, "\n")
+			if len(preview) > 60 {
+				preview = preview[:60] + "..."
+			}
+			fmt.Fprintf(&sb, "%d. `%s`\n", i+1, preview)
+		}
+		m.notify(sb.String())
+		return
+	}
+
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(blocks) {
+		m.notify(fmt.Sprintf("Invalid block %q, expected a number between 1 and %d.", arg, len(blocks)))
+		return
+	}
+	m.copyBlock(blocks, n)
+}
+
+// copyLastBlock copies the most recent code block or command to the clipboard.
+func (m *model) copyLastBlock() {
+	blocks := copyableBlocks(m.messages)
+	if len(blocks) == 0 {
+		m.notify("There are no code blocks or commands to copy.")
+		return
+	}
+	m.copyBlock(blocks, len(blocks))
+}
+
+// copyBlock copies block n (1-based) to the system clipboard.
+func (m *model) copyBlock(blocks []string, n int) {
+	if err := clipboard.WriteAll(blocks[n-1]); err != nil {
+		m.notify(fmt.Sprintf("Could not copy to the clipboard: %v", err))
+		return
+	}
+	m.notify(fmt.Sprintf("Copied block %d to the clipboard.", n))
+}
+
+// notify shows a message from the UI itself in the conversation.
+func (m *model) notify(text string) {
+	m.addNotice(&api.Message{
+		Source:    api.MessageSourceAgent,
+		Type:      api.MessageTypeText,
+		Payload:   text,
+		Timestamp: time.Now(),
+	})
+	m.dirty = true
+	m.refresh()
+	m.viewport.GotoBottom()
+}
+
+// addNotice adds a message of the UI itself to the conversation, where it
+// stays when the messages are reloaded from the session.
+func (m *model) addNotice(msg *api.Message) {
+	if sessionID := m.agent.GetSession().ID; sessionID != m.noticesSessionID {
+		m.notices, m.noticesSessionID = nil, sessionID
+	}
+	m.notices = append(m.notices, msg)
+	m.messages = append(m.messages, msg)
+}
+
+// withNotices returns messages with notices inserted in time order.
+func withNotices(messages, notices []*api.Message) []*api.Message {
+	if len(notices) == 0 {
+		return messages
+	}
+	merged := make([]*api.Message, 0, len(messages)+len(notices))
+	i := 0
+	for _, msg := range messages {
+		for i < len(notices) && notices[i].Timestamp.Before(msg.Timestamp) {
+			merged = append(merged, notices[i])
+			i++
+		}
+		merged = append(merged, msg)
+	}
+	return append(merged, notices[i:]...)
+}
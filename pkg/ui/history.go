@@ -0,0 +1,69 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+)
+
+// promptHistory returns the prompts submitted in a session, oldest first.
+// Prompts are part of the session history, so they can be recalled after the
+// session is resumed.
+func promptHistory(messages []*api.Message) []string {
+	var prompts []string
+	for _, msg := range messages {
+		if msg.Source != api.MessageSourceUser || msg.Type != api.MessageTypeText {
+			continue
+		}
+		prompt, ok := msg.Payload.(string)
+		if !ok || prompt == "" {
+			continue
+		}
+		// Skip repeated prompts, like shells do
+		if len(prompts) > 0 && prompts[len(prompts)-1] == prompt {
+			continue
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts
+}
+
+// recallHistory replaces the input with an earlier (delta < 0) or later
+// (delta > 0) prompt. Moving past the most recent prompt restores the text
+// that was being typed before browsing the history.
+func (m *model) recallHistory(delta int) {
+	prompts := promptHistory(m.agent.GetSession().AllMessages())
+	if len(prompts) == 0 {
+		return
+	}
+
+	switch {
+	case m.historyIndex < 0 && delta > 0:
+		return
+	case m.historyIndex < 0:
+		m.historyDraft = m.input.Value()
+		m.historyIndex = len(prompts) - 1
+	default:
+		m.historyIndex = max(m.historyIndex+delta, 0)
+	}
+
+	if m.historyIndex >= len(prompts) {
+		m.historyIndex = -1
+		m.input.SetValue(m.historyDraft)
+	} else {
+		m.input.SetValue(prompts[m.historyIndex])
+	}
+	m.input.CursorEnd()
+}
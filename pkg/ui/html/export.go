@@ -0,0 +1,260 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package html
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// transcriptEntry is a single step of an exported session transcript.
+type transcriptEntry struct {
+	// Kind is one of "user", "model", "command", "approval" or "error".
+	Kind        string
+	Time        time.Time
+	Text        string
+	KubeContext string
+	// Output is the output of a command, if it was run.
+	Output string
+
+	// id is the ID of the tool call request message of a command.
+	id string
+	// answered is set once the output of a command is known.
+	answered bool
+}
+
+// transcript is an exported session, for attaching to incident reports.
+type transcript struct {
+	Session    *api.Session
+	Title      string
+	ExportedAt time.Time
+	Entries    []transcriptEntry
+}
+
+func newTranscript(session *api.Session) *transcript {
+	t := &transcript{
+		Session:    session,
+		Title:      session.Name,
+		ExportedAt: time.Now(),
+	}
+	if t.Title == "" {
+		t.Title = session.ID
+	}
+
+	for _, msg := range session.AllMessages() {
+		switch msg.Type {
+		case api.MessageTypeText:
+			text, ok := msg.Payload.(string)
+			if !ok || text == "" {
+				continue
+			}
+			kind := "model"
+			if msg.Source == api.MessageSourceUser {
+				kind = "user"
+			}
+			t.Entries = append(t.Entries, transcriptEntry{Kind: kind, Time: msg.Timestamp, Text: text})
+		case api.MessageTypeToolCallRequest:
+			t.Entries = append(t.Entries, transcriptEntry{Kind: "command", Time: msg.Timestamp, Text: fmt.Sprint(msg.Payload), KubeContext: msg.KubeContext, id: msg.ID})
+		case api.MessageTypeToolCallResponse:
+			// Attach the output to the command it belongs to. Responses
+			// saved without the ID of their request come in the order the
+			// commands were requested.
+			for i := range t.Entries {
+				e := &t.Entries[i]
+				if e.Kind != "command" || e.answered || (msg.ToolCallID != "" && msg.ToolCallID != e.id) {
+					continue
+				}
+				e.Output, e.answered = toolOutputText(msg.Payload), true
+				break
+			}
+		case api.MessageTypeError:
+			t.Entries = append(t.Entries, transcriptEntry{Kind: "error", Time: msg.Timestamp, Text: fmt.Sprint(msg.Payload)})
+		case api.MessageTypeImage:
+			var image api.Image
+			if b, err := json.Marshal(msg.Payload); err != nil || json.Unmarshal(b, &image) != nil {
+				continue
+			}
+			t.Entries = append(t.Entries, transcriptEntry{Kind: "user", Time: msg.Timestamp, Text: fmt.Sprintf("[Attached image %s]", image.Name)})
+		case api.MessageTypeAttachment:
+			var attachment api.Attachment
+			if b, err := json.Marshal(msg.Payload); err != nil || json.Unmarshal(b, &attachment) != nil {
+				continue
+			}
+			t.Entries = append(t.Entries, transcriptEntry{Kind: "user", Time: msg.Timestamp, Text: fmt.Sprintf("[Attached file %s]", attachment.Name)})
+		case api.MessageTypeUserChoiceResponse:
+			var choice api.UserChoiceResponse
+			if b, err := json.Marshal(msg.Payload); err != nil || json.Unmarshal(b, &choice) != nil {
+				continue
+			}
+			text := "Approved"
+			if choice.Choice == 3 {
+				text = "Declined"
+			}
+			if choice.Justification != "" {
+				text += ": " + choice.Justification
+			}
+			t.Entries = append(t.Entries, transcriptEntry{Kind: "approval", Time: msg.Timestamp, Text: text})
+		}
+	}
+	return t
+}
+
+// toolOutputText returns the output of a tool call as text.
+func toolOutputText(payload any) string {
+	result, err := tools.ToolResultToMap(payload)
+	if err != nil {
+		return fmt.Sprint(payload)
+	}
+	var parts []string
+	for _, key := range []string{"content", "stdout", "stderr", "error"} {
+		if v, ok := result[key]; ok && v != nil && fmt.Sprint(v) != "" {
+			parts = append(parts, strings.TrimRight(fmt.Sprint(v), "\n"))
+		}
+	}
+	if len(parts) > 0 {
+		return strings.Join(parts, "\n")
+	}
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Sprint(result)
+	}
+	return string(b)
+}
+
+const transcriptTimeFormat = "2006-01-02 15:04:05 MST"
+
+// writeMarkdown writes the transcript as a Markdown document.
+func (t *transcript) writeMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", t.Title)
+	fmt.Fprintf(&sb, "- Session: `%s`\n", t.Session.ID)
+	if t.Session.ModelID != "" {
+		fmt.Fprintf(&sb, "- Model: `%s`\n", t.Session.ModelID)
+	}
+	if !t.Session.CreatedAt.IsZero() {
+		fmt.Fprintf(&sb, "- Started: %s\n", t.Session.CreatedAt.Format(transcriptTimeFormat))
+	}
+	fmt.Fprintf(&sb, "- Exported: %s\n", t.ExportedAt.Format(transcriptTimeFormat))
+
+	for _, e := range t.Entries {
+		ts := e.Time.Format(transcriptTimeFormat)
+		switch e.Kind {
+		case "user":
+			fmt.Fprintf(&sb, "\n## You (%s)\n\n%s\n", ts, e.Text)
+		case "model":
+			fmt.Fprintf(&sb, "\n## kubectl-ai (%s)\n\n%s\n", ts, e.Text)
+		case "command":
+			on := ""
+			if e.KubeContext != "" {
+				on = fmt.Sprintf(" on `%s`", e.KubeContext)
+			}
+			fmt.Fprintf(&sb, "\n**Ran%s** (%s):\n\n```shell\n%s\n```\n", on, ts, e.Text)
+			if e.Output != "" {
+				fmt.Fprintf(&sb, "\nOutput:\n\n```\n%s\n```\n", e.Output)
+			}
+		case "approval":
+			fmt.Fprintf(&sb, "\n**%s** (%s)\n", e.Text, ts)
+		case "error":
+			fmt.Fprintf(&sb, "\n> **Error** (%s): %s\n", ts, e.Text)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"timestamp": func(t time.Time) string { return t.Format(transcriptTimeFormat) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #202124; }
+.meta { color: #5f6368; font-size: 0.9em; }
+.entry { margin: 1.5em 0; }
+.author { font-weight: 600; }
+.time { color: #5f6368; font-size: 0.85em; margin-left: 0.5em; }
+.text { white-space: pre-wrap; margin-top: 0.3em; }
+pre { background: #f1f3f4; padding: 0.8em; overflow-x: auto; border-radius: 4px; }
+.error { color: #c5221f; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">
+Session <code>{{.Session.ID}}</code>{{if .Session.ModelID}} &middot; Model <code>{{.Session.ModelID}}</code>{{end}}
+{{if not .Session.CreatedAt.IsZero}} &middot; Started {{timestamp .Session.CreatedAt}}{{end}} &middot; Exported {{timestamp .ExportedAt}}
+</div>
+{{range .Entries}}<div class="entry {{.Kind}}">
+{{if eq .Kind "user"}}<span class="author">You</span>{{else if eq .Kind "model"}}<span class="author">kubectl-ai</span>{{else if eq .Kind "command"}}<span class="author">Ran{{if .KubeContext}} on <code>{{.KubeContext}}</code>{{end}}</span>{{else if eq .Kind "approval"}}<span class="author">{{.Text}}</span>{{else}}<span class="author">Error</span>{{end}}<span class="time">{{timestamp .Time}}</span>
+{{if eq .Kind "command"}}<pre>{{.Text}}</pre>{{if .Output}}<pre>{{.Output}}</pre>{{end}}{{else if eq .Kind "approval"}}{{else}}<div class="text">{{.Text}}</div>{{end}}
+</div>
+{{end}}</body>
+</html>
+`))
+
+// writeHTML writes the transcript as a standalone HTML page.
+func (t *transcript) writeHTML(w io.Writer) error {
+	return transcriptTemplate.Execute(w, t)
+}
+
+// handleExportSession serves the transcript of a session as a download, in
+// Markdown (the default) or HTML (?format=html).
+func (u *HTMLUserInterface) handleExportSession(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	log := klog.FromContext(ctx)
+
+	id := req.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	session, err := u.manager.FindSessionByID(id)
+	if err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	t := newTranscript(session)
+
+	var write func(io.Writer) error
+	switch format := req.URL.Query().Get("format"); format {
+	case "", "markdown", "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "kubectl-ai-"+session.ID+".md"))
+		write = t.writeMarkdown
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "kubectl-ai-"+session.ID+".html"))
+		write = t.writeHTML
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q, expected markdown or html", format), http.StatusBadRequest)
+		return
+	}
+
+	if err := write(w); err != nil {
+		log.Error(err, "writing session transcript")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package html
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sandbox"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
+)
+
+func testTranscript(t *testing.T) *transcript {
+	t.Helper()
+	store := sessions.NewInMemoryChatStore()
+	session := &api.Session{ID: "20250807-510872", Name: "web outage", ModelID: "gemini-2.5-pro", ChatMessageStore: store}
+	at := time.Date(2025, 8, 7, 10, 0, 0, 0, time.UTC)
+	for _, msg := range []*api.Message{
+		{Source: api.MessageSourceUser, Type: api.MessageTypeText, Payload: "why is web down?"},
+		// The calls ran in parallel, the second one finished first.
+		{ID: "get-pods", Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get pods", KubeContext: "prod"},
+		{ID: "get-events", Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl get events"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stdout: "Back-off pulling image"}, ToolCallID: "get-events"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stdout: "web-0 ImagePullBackOff"}, ToolCallID: "get-pods"},
+		// Sessions saved before responses had the ID of their request
+		{ID: "describe", Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl describe pod web-0"},
+		{ID: "logs", Source: api.MessageSourceModel, Type: api.MessageTypeToolCallRequest, Payload: "kubectl logs web-0"},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stdout: "Image: web:v2"}},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeToolCallResponse, Payload: &sandbox.ExecResult{Stderr: "container is waiting to start"}},
+		{Source: api.MessageSourceAgent, Type: api.MessageTypeUserChoiceResponse, Payload: &api.UserChoiceResponse{Choice: 3, Justification: "not during the freeze"}},
+		{Source: api.MessageSourceModel, Type: api.MessageTypeText, Payload: "The image `web:v2` <does not exist>."},
+	} {
+		msg.Timestamp = at
+		if err := store.AddChatMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr := newTranscript(session)
+	tr.ExportedAt = at
+	return tr
+}
+
+func TestTranscript_PairsOutputs(t *testing.T) {
+	tr := testTranscript(t)
+	want := map[string]string{
+		"kubectl get pods":           "web-0 ImagePullBackOff",
+		"kubectl get events":         "Back-off pulling image",
+		"kubectl describe pod web-0": "Image: web:v2",
+		"kubectl logs web-0":         "container is waiting to start",
+	}
+	commands := 0
+	for _, e := range tr.Entries {
+		if e.Kind != "command" {
+			continue
+		}
+		commands++
+		if e.Output != want[e.Text] {
+			t.Errorf("output of %q = %q, want %q", e.Text, e.Output, want[e.Text])
+		}
+	}
+	if commands != len(want) {
+		t.Errorf("expected %d commands, got %d", len(want), commands)
+	}
+}
+
+func TestTranscript_WriteMarkdown(t *testing.T) {
+	var sb strings.Builder
+	if err := testTranscript(t).writeMarkdown(&sb); err != nil {
+		t.Fatal(err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		"# web outage\n",
+		"- Session: `20250807-510872`\n- Model: `gemini-2.5-pro`\n",
+		"## You (2025-08-07 10:00:00 UTC)\n\nwhy is web down?\n",
+		"**Ran on `prod`** (2025-08-07 10:00:00 UTC):\n\n```shell\nkubectl get pods\n```\n\nOutput:\n\n```\nweb-0 ImagePullBackOff\n```\n",
+		"**Declined: not during the freeze**",
+		"## kubectl-ai (2025-08-07 10:00:00 UTC)\n\nThe image `web:v2` <does not exist>.\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected the Markdown transcript to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestTranscript_WriteHTML(t *testing.T) {
+	var sb strings.Builder
+	if err := testTranscript(t).writeHTML(&sb); err != nil {
+		t.Fatal(err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		"<title>web outage</title>",
+		"<span class=\"author\">Ran on <code>prod</code></span>",
+		"<pre>kubectl get events</pre><pre>Back-off pulling image</pre>",
+		"<span class=\"author\">Declined: not during the freeze</span>",
+		// Text is escaped
+		"The image `web:v2` &lt;does not exist&gt;.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected the HTML transcript to contain %q, got:\n%s", want, got)
+		}
+	}
+}
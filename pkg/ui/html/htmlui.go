@@ -15,15 +15,19 @@
 package html
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -107,6 +111,11 @@ type HTMLUserInterface struct {
 
 	broadcasterCancels map[string]context.CancelFunc
 	baseCtx            context.Context
+
+	// approvals sends the pending approvals of all the sessions to the
+	// clients of the approval queue whenever an agent's state changes.
+	approvals     *Broadcaster
+	approvalsOnce sync.Once
 }
 
 var _ ui.UI = &HTMLUserInterface{}
@@ -138,10 +147,18 @@ func NewHTMLUserInterface(manager *agent.AgentManager, sessionManager *sessions.
 	mux.HandleFunc("GET /api/sessions", u.handleListSessions)
 	mux.HandleFunc("POST /api/sessions", u.handleCreateSession)
 	mux.HandleFunc("POST /api/sessions/{id}/rename", u.handleRenameSession)
+	mux.HandleFunc("POST /api/sessions/{id}/fork", u.handleForkSession)
 	mux.HandleFunc("DELETE /api/sessions/{id}", u.handleDeleteSession)
 	mux.HandleFunc("GET /api/sessions/{id}/stream", u.handleSessionStream)
+	mux.HandleFunc("GET /api/sessions/{id}/export", u.handleExportSession)
 	mux.HandleFunc("POST /api/sessions/{id}/send-message", u.handlePOSTSendMessage)
+	mux.HandleFunc("POST /api/sessions/{id}/attachments", u.handlePOSTAttachment)
 	mux.HandleFunc("POST /api/sessions/{id}/choose-option", u.handlePOSTChooseOption)
+	mux.HandleFunc("POST /api/sessions/{id}/continue", u.handlePOSTContinue)
+	mux.HandleFunc("POST /api/sessions/{id}/cancel-tool-call", u.handlePOSTCancelToolCall)
+	mux.HandleFunc("POST /api/sessions/{id}/feedback", u.handlePOSTFeedback)
+	mux.HandleFunc("GET /api/approvals", u.handleListApprovals)
+	mux.HandleFunc("GET /api/approvals/stream", u.handleApprovalsStream)
 
 	httpServerListener, err := net.Listen("tcp", listenAddress)
 	if err != nil {
@@ -178,6 +195,11 @@ func (u *HTMLUserInterface) Run(ctx context.Context) error {
 		return nil
 	})
 
+	g.Go(func() error {
+		u.manager.EvictIdleAgents(gctx)
+		return nil
+	})
+
 	g.Go(func() error {
 		<-gctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -239,7 +261,7 @@ func (u *HTMLUserInterface) handleSessionStream(w http.ResponseWriter, req *http
 	if err != nil {
 		log.Error(err, "getting initial state for SSE client")
 	} else {
-		fmt.Fprintf(w, "data: %s\n\n", initialData)
+		w.Write(sseEvent("", initialData))
 		flusher.Flush()
 	}
 
@@ -248,8 +270,8 @@ func (u *HTMLUserInterface) handleSessionStream(w http.ResponseWriter, req *http
 		case <-ctx.Done():
 			log.Info("SSE client disconnected")
 			return
-		case msg := <-clientChan:
-			fmt.Fprintf(w, "data: %s\n\n", msg)
+		case event := <-clientChan:
+			w.Write(event)
 			flusher.Flush()
 		}
 	}
@@ -335,13 +357,56 @@ func (u *HTMLUserInterface) handleRenameSession(w http.ResponseWriter, req *http
 		agent.Session.Name = newName
 		// Broadcast update
 		if data, err := u.getSessionStateJSON(agent.Session); err == nil {
-			u.getBroadcaster(id).Broadcast(data)
+			u.getBroadcaster(id).Broadcast(sseEvent("", data))
 		}
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleForkSession creates a new session with the messages of the session up to
+// and including the (1-based) message number given in the "message" form value.
+func (u *HTMLUserInterface) handleForkSession(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	log := klog.FromContext(ctx)
+
+	id := req.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := strconv.Atoi(req.FormValue("message"))
+	if err != nil {
+		http.Error(w, "invalid message number", http.StatusBadRequest)
+		return
+	}
+
+	source, err := u.manager.FindSessionByID(id)
+	if err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	session, err := u.sessionManager.ForkSession(source, n)
+	if err != nil {
+		log.Error(err, "forking session")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := u.manager.GetAgent(ctx, session.ID); err != nil {
+		log.Error(err, "starting agent for forked session")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"id": session.ID})
+}
+
 func (u *HTMLUserInterface) handleDeleteSession(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	log := klog.FromContext(ctx)
@@ -401,8 +466,69 @@ func (u *HTMLUserInterface) handlePOSTSendMessage(w http.ResponseWriter, req *ht
 		return
 	}
 
+	// Images pasted into the input, as a JSON array of api.Image
+	var images []api.Image
+	if v := req.FormValue("images"); v != "" {
+		if err := json.Unmarshal([]byte(v), &images); err != nil {
+			http.Error(w, fmt.Sprintf("invalid images: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Send the message to the agent
-	agent.Input <- &api.UserInputResponse{Query: q}
+	agent.Input <- &api.UserInputResponse{Query: q, Images: images}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// maxUploadBytes bounds the size of attachment uploads. The agent checks the
+// size of the file itself, this only stops oversized requests early.
+const maxUploadBytes = 2 * 1024 * 1024
+
+// handlePOSTAttachment attaches an uploaded file (the "file" field of a
+// multipart form) to the conversation as context.
+func (u *HTMLUserInterface) handlePOSTAttachment(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	log := klog.FromContext(ctx)
+
+	id := req.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxUploadBytes)
+	file, header, err := req.FormFile("file")
+	if err != nil {
+		log.Error(err, "reading uploaded file")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Error(err, "reading uploaded file")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	agent, err := u.manager.GetAgent(ctx, id)
+	if err != nil {
+		log.Error(err, "getting agent")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	agent.Input <- &api.UserInputResponse{
+		Query: req.FormValue("q"),
+		Attachments: []api.Attachment{{
+			Name:    header.Filename,
+			Source:  "upload",
+			Size:    len(data),
+			Content: string(data),
+		}},
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -442,12 +568,208 @@ func (u *HTMLUserInterface) handlePOSTChooseOption(w http.ResponseWriter, req *h
 		return
 	}
 
-	// Send the choice to the agent
-	agent.Input <- &api.UserChoiceResponse{Choice: choiceIndex}
+	// The approval queue names the request it answers, which may have been
+	// answered in the meantime, e.g. in another tab
+	choiceResponse := &api.UserChoiceResponse{Choice: choiceIndex, Justification: strings.TrimSpace(req.FormValue("justification"))}
+	if !agent.AnswerChoice(req.FormValue("message"), choiceResponse) {
+		http.Error(w, "the choice was already made", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePOSTContinue answers whether to continue a query that reached a
+// limit, with the "continue" form value.
+func (u *HTMLUserInterface) handlePOSTContinue(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := req.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	cont, err := strconv.ParseBool(req.FormValue("continue"))
+	if err != nil {
+		http.Error(w, "invalid continue value", http.StatusBadRequest)
+		return
+	}
+
+	agent, err := u.manager.GetAgent(ctx, id)
+	if err != nil {
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return
+	}
+
+	agent.Input <- &api.ContinueResponse{Continue: cont}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePOSTCancelToolCall kills the running tool call whose request is the
+// "message" form value. The agent goes on, told the call was cancelled.
+func (u *HTMLUserInterface) handlePOSTCancelToolCall(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := req.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	messageID := req.FormValue("message")
+	if messageID == "" {
+		http.Error(w, "missing message", http.StatusBadRequest)
+		return
+	}
+
+	agent, err := u.manager.GetAgent(ctx, id)
+	if err != nil {
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	if !slices.Contains(agent.RunningToolCalls(), messageID) {
+		http.Error(w, "the tool call is not running", http.StatusConflict)
+		return
+	}
+
+	select {
+	case agent.Input <- &api.CancelToolCall{ID: messageID}:
+	case <-ctx.Done():
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePOSTFeedback rates the answer given in the "message" form value with
+// the "rating" form value, up or down.
+func (u *HTMLUserInterface) handlePOSTFeedback(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id := req.PathValue("id")
+	if id == "" {
+		http.Error(w, "missing session id", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	messageID := req.FormValue("message")
+	if messageID == "" {
+		http.Error(w, "missing message", http.StatusBadRequest)
+		return
+	}
+
+	agent, err := u.manager.GetAgent(ctx, id)
+	if err != nil {
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	if _, err := agent.RecordFeedback(ctx, messageID, api.FeedbackRating(req.FormValue("rating"))); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	// Broadcast the rating to the clients of the session
+	if data, err := u.getSessionStateJSON(agent.Session); err == nil {
+		u.getBroadcaster(id).Broadcast(sseEvent("", data))
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleListApprovals lists the choices the agents of all the sessions wait
+// for, oldest first.
+func (u *HTMLUserInterface) handleListApprovals(w http.ResponseWriter, req *http.Request) {
+	data, err := u.getApprovalsJSON()
+	if err != nil {
+		klog.FromContext(req.Context()).Error(err, "encoding approvals")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// handleApprovalsStream sends the pending approvals of all the sessions as
+// server-sent events, first when the client connects and then whenever they
+// may have changed.
+func (u *HTMLUserInterface) handleApprovalsStream(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	log := klog.FromContext(ctx)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	clientChan := make(chan []byte, 10)
+	broadcaster := u.getApprovalsBroadcaster()
+	broadcaster.newClient <- clientChan
+	defer func() {
+		broadcaster.delClient <- clientChan
+	}()
+
+	if data, err := u.getApprovalsJSON(); err != nil {
+		log.Error(err, "encoding approvals")
+	} else {
+		w.Write(sseEvent("", data))
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-clientChan:
+			w.Write(event)
+			flusher.Flush()
+		}
+	}
+}
+
+func (u *HTMLUserInterface) getApprovalsJSON() ([]byte, error) {
+	approvals := u.manager.PendingApprovals()
+	if approvals == nil {
+		approvals = []agent.PendingApproval{}
+	}
+	return json.Marshal(approvals)
+}
+
+func (u *HTMLUserInterface) getApprovalsBroadcaster() *Broadcaster {
+	u.approvalsOnce.Do(func() {
+		u.approvals = NewBroadcaster()
+		ctx := u.baseCtx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		go u.approvals.Run(ctx)
+	})
+	return u.approvals
+}
+
+// broadcastApprovals sends the pending approvals to the clients of the
+// approval queue.
+func (u *HTMLUserInterface) broadcastApprovals() {
+	data, err := u.getApprovalsJSON()
+	if err != nil {
+		klog.Errorf("Error marshaling approvals for broadcast: %v", err)
+		return
+	}
+	u.getApprovalsBroadcaster().Broadcast(sseEvent("", data))
+}
+
 func (u *HTMLUserInterface) Close() error {
 	var errs []error
 	if u.httpServerListener != nil {
@@ -486,10 +808,17 @@ func (u *HTMLUserInterface) getSessionStateJSON(session *api.Session) ([]byte, e
 
 	agentState := session.AgentState
 
+	feedback := map[string]api.FeedbackRating{}
+	for _, f := range session.Feedback {
+		feedback[f.MessageID] = f.Rating
+	}
+
 	data := map[string]interface{}{
 		"messages":   messages,
 		"agentState": agentState,
 		"sessionId":  session.ID,
+		"usage":      session.Usage,
+		"feedback":   feedback,
 	}
 	return json.Marshal(data)
 }
@@ -518,23 +847,66 @@ func (u *HTMLUserInterface) getBroadcaster(sessionID string) *Broadcaster {
 	return b
 }
 
+// sseEvent formats data as a server-sent event. The unnamed event carries the
+// whole session state; "delta" events carry streamed text of a single message.
+func sseEvent(name string, data []byte) []byte {
+	var buf bytes.Buffer
+	if name != "" {
+		fmt.Fprintf(&buf, "event: %s\n", name)
+	}
+	fmt.Fprintf(&buf, "data: %s\n\n", data)
+	return buf.Bytes()
+}
+
 func (u *HTMLUserInterface) ensureAgentListener(a *agent.Agent) {
 	// Start a goroutine to listen to this agent's output
 	go func() {
-		for range a.Output {
-			// Broadcast state
+		for output := range a.Output {
 			if a.Session == nil {
 				continue
 			}
+			b := u.getBroadcaster(a.Session.ID)
+
+			// Stream text deltas, rather than the whole session on every chunk
+			if msg, ok := output.(*api.Message); ok && msg.Type == api.MessageTypeTextDelta {
+				data, err := json.Marshal(map[string]any{
+					"id":   msg.ID,
+					"text": msg.Payload,
+				})
+				if err != nil {
+					klog.Errorf("Error marshaling text delta for broadcast: %v", err)
+					continue
+				}
+				b.Broadcast(sseEvent("delta", data))
+				continue
+			}
+			// Stream the output of running tool calls the same way
+			if msg, ok := output.(*api.Message); ok && msg.Type == api.MessageTypeToolOutputDelta {
+				delta, _ := msg.Payload.(*api.ToolOutputDelta)
+				if delta == nil {
+					continue
+				}
+				data, err := json.Marshal(map[string]any{
+					"id":     msg.ID,
+					"stream": delta.Stream,
+					"text":   delta.Text,
+				})
+				if err != nil {
+					klog.Errorf("Error marshaling tool output for broadcast: %v", err)
+					continue
+				}
+				b.Broadcast(sseEvent("tool-output", data))
+				continue
+			}
 
+			// Broadcast state
 			data, err := u.getSessionStateJSON(a.Session)
 			if err != nil {
 				klog.Errorf("Error marshaling state for broadcast: %v", err)
 				continue
 			}
-
-			b := u.getBroadcaster(a.Session.ID)
-			b.Broadcast(data)
+			b.Broadcast(sseEvent("", data))
+			u.broadcastApprovals()
 		}
 	}()
 }
@@ -36,12 +36,14 @@ const (
 	UITypeTerminal Type = "terminal"
 	UITypeWeb      Type = "web"
 	UITypeTUI      Type = "tui"
+	// UITypePlain is a line based UI without colors or cursor movement.
+	UITypePlain Type = "plain"
 )
 
 // Implement pflag.Value for UIType
 func (u *Type) Set(s string) error {
 	switch s {
-	case "terminal", "web", "tui":
+	case "terminal", "web", "tui", "plain":
 		*u = Type(s)
 		return nil
 	default:
@@ -0,0 +1,252 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
+	"k8s.io/klog/v2"
+)
+
+// PlainUI is a line based user interface. It prints messages as plain text,
+// without colors, markdown rendering or cursor movement, and reads input line
+// by line. This keeps it usable over slow SSH links and in dumb terminals.
+type PlainUI struct {
+	agent *agent.Agent
+	out   io.Writer
+	in    *bufio.Reader
+
+	// useTTYForInput reads input from /dev/tty, for when stdin was used to
+	// provide the initial query.
+	useTTYForInput bool
+	// showToolOutput prints the output of tool calls.
+	showToolOutput bool
+	// streamingID is the ID of the response whose text is being printed as it streams.
+	streamingID string
+}
+
+var _ UI = &PlainUI{}
+
+func NewPlainUI(agent *agent.Agent, useTTYForInput bool, showToolOutput bool) *PlainUI {
+	return &PlainUI{
+		agent:          agent,
+		out:            os.Stdout,
+		useTTYForInput: useTTYForInput,
+		showToolOutput: showToolOutput,
+	}
+}
+
+func (u *PlainUI) Run(ctx context.Context) error {
+	if len(u.agent.GetSession().Messages) > 0 {
+		fmt.Fprintln(u.out, "Welcome back. What can I help you with today? (Use --new-session to start over)")
+	}
+
+	agentExited := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-u.agent.Output:
+				if !ok {
+					return
+				}
+				u.handleMessage(msg.(*api.Message))
+
+				if u.agent.GetSession().AgentState == api.AgentStateExited {
+					close(agentExited)
+					return
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-agentExited:
+		return u.agent.LastErr()
+	}
+}
+
+// ClearScreen is a no-op, the plain UI only ever appends output.
+func (u *PlainUI) ClearScreen() {}
+
+func (u *PlainUI) handleMessage(msg *api.Message) {
+	switch msg.Type {
+	case api.MessageTypeText:
+		if msg.Source == api.MessageSourceUser {
+			// Already on screen, as the user typed it
+			return
+		}
+		if msg.ID != "" && msg.ID == u.streamingID {
+			// Already on screen, as it was streamed
+			u.streamingID = ""
+			fmt.Fprintln(u.out)
+			return
+		}
+		fmt.Fprintf(u.out, "\n%s\n", strings.TrimSpace(msg.Payload.(string)))
+	case api.MessageTypeTextDelta:
+		if msg.ID != u.streamingID {
+			u.streamingID = msg.ID
+			fmt.Fprintln(u.out)
+		}
+		fmt.Fprint(u.out, msg.Payload.(string))
+	case api.MessageTypeToolOutputDelta:
+		// The complete output is printed once the tool call is done
+		return
+	case api.MessageTypeError:
+		fmt.Fprintf(u.out, "\nError: %s\n", msg.Payload.(string))
+	case api.MessageTypeToolCallRequest:
+		if msg.KubeContext != "" {
+			fmt.Fprintf(u.out, "\nRunning [%s]: %s\n", msg.KubeContext, msg.Payload.(string))
+		} else {
+			fmt.Fprintf(u.out, "\nRunning: %s\n", msg.Payload.(string))
+		}
+	case api.MessageTypeToolCallResponse:
+		if !u.showToolOutput {
+			return
+		}
+		output, err := tools.ToolResultToMap(msg.Payload)
+		if err != nil {
+			klog.Errorf("Error converting tool result to map: %v", err)
+			return
+		}
+		fmt.Fprintln(u.out, formatToolCallResponse(output))
+	case api.MessageTypeUserInputRequest:
+		for {
+			query, err := u.readLine("\n>>> ")
+			if err != nil {
+				u.agent.Input <- err
+				return
+			}
+			if query != "" {
+				u.agent.Input <- &api.UserInputResponse{Query: query}
+				return
+			}
+		}
+	case api.MessageTypeUserChoiceRequest:
+		u.handleChoiceRequest(msg.Payload.(*api.UserChoiceRequest))
+	case api.MessageTypeSessionPickerRequest:
+		u.handleSessionPicker(msg.Payload.(*api.SessionPickerRequest))
+	case api.MessageTypeUserChoiceResponse:
+		// The user's own choice, already on screen
+	case api.MessageTypeContinueRequest:
+		u.handleContinueRequest(msg.Payload.(*api.ContinueRequest))
+	case api.MessageTypeImage:
+		fmt.Fprintf(u.out, "Attached %s\n", imageLabel(msg.Payload))
+	case api.MessageTypeAttachment:
+		fmt.Fprintf(u.out, "Attached %s\n", attachmentLabel(msg.Payload))
+	default:
+		klog.Warningf("unsupported message type: %v", msg.Type)
+	}
+}
+
+func (u *PlainUI) handleChoiceRequest(req *api.UserChoiceRequest) {
+	fmt.Fprintf(u.out, "\n%s\n", req.Prompt)
+	for i, option := range req.Options {
+		fmt.Fprintf(u.out, "  %d. %s\n", i+1, option.Label)
+	}
+
+	for {
+		line, err := u.readLine("Enter your choice (y/n or a number): ")
+		if err != nil {
+			u.agent.Input <- err
+			return
+		}
+		switch strings.ToLower(line) {
+		case "y", "yes":
+			line = "1"
+		case "n", "no":
+			line = strconv.Itoa(len(req.Options))
+		}
+		if choice, err := strconv.Atoi(line); err == nil && choice > 0 && choice <= len(req.Options) {
+			justification, err := u.readLine("Justification (optional, Enter to skip): ")
+			if err != nil {
+				klog.Infof("Error reading justification: %v", err)
+			}
+			u.agent.Input <- &api.UserChoiceResponse{Choice: choice, Justification: justification}
+			return
+		}
+		fmt.Fprintln(u.out, "Invalid choice. Please try again.")
+	}
+}
+
+func (u *PlainUI) handleContinueRequest(req *api.ContinueRequest) {
+	fmt.Fprintf(u.out, "\nStopped: %s.\n%s\n", req.Reason, req.Summary)
+	line, err := u.readLine("Do you want to continue? (y/N): ")
+	if err != nil {
+		u.agent.Input <- err
+		return
+	}
+	line = strings.ToLower(line)
+	u.agent.Input <- &api.ContinueResponse{Continue: line == "y" || line == "yes"}
+}
+
+func (u *PlainUI) handleSessionPicker(req *api.SessionPickerRequest) {
+	fmt.Fprintln(u.out, "\nSelect a session to resume:")
+	for i, s := range req.Sessions {
+		fmt.Fprintf(u.out, "  %d. %s\n", i+1, sessionLabel(s))
+	}
+
+	for {
+		line, err := u.readLine("Enter a number (empty to cancel): ")
+		if err != nil {
+			u.agent.Input <- err
+			return
+		}
+		if line == "" {
+			u.agent.Input <- &api.SessionPickerResponse{Cancelled: true}
+			return
+		}
+		if n, err := strconv.Atoi(line); err == nil && n > 0 && n <= len(req.Sessions) {
+			u.agent.Input <- &api.SessionPickerResponse{SessionID: req.Sessions[n-1].ID}
+			return
+		}
+		fmt.Fprintln(u.out, "Invalid choice. Please try again.")
+	}
+}
+
+// readLine prints prompt and returns the next line of input, trimmed.
+// It returns io.EOF when the input is closed (e.g. Ctrl+D).
+func (u *PlainUI) readLine(prompt string) (string, error) {
+	if u.in == nil {
+		var r io.Reader = os.Stdin
+		if u.useTTYForInput {
+			tty, err := os.Open("/dev/tty")
+			if err != nil {
+				return "", fmt.Errorf("opening tty for input: %w", err)
+			}
+			r = tty
+		}
+		u.in = bufio.NewReader(r)
+	}
+
+	fmt.Fprint(u.out, prompt)
+	line, err := u.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	sessionActionRename = "rename"
+	sessionActionDelete = "delete"
+)
+
+// sessionActionMsg reports the outcome of renaming or deleting a session from
+// the picker.
+type sessionActionMsg struct {
+	text string
+	err  error
+}
+
+// showSessionPicker lists sessions in the picker.
+func (m *model) showSessionPicker(sessions []api.SessionInfo, optionID string) {
+	items := make([]list.Item, len(sessions))
+	for i, s := range sessions {
+		items[i] = item(sessionLabel(s))
+	}
+	m.list.SetItems(items)
+	m.list.Select(0)
+	m.inChoiceMode = true
+	m.choicePrompt = "Select a session to resume"
+	m.choiceOptionID = optionID
+	m.choiceType = "session"
+	m.sessions = sessions
+	m.sessionAction = ""
+}
+
+// selectedSession returns the session highlighted in the picker.
+func (m *model) selectedSession() (api.SessionInfo, bool) {
+	idx := m.list.Index()
+	if idx < 0 || idx >= len(m.sessions) {
+		return api.SessionInfo{}, false
+	}
+	return m.sessions[idx], true
+}
+
+// handleSessionPickerKey handles the keys renaming (r) and deleting (d) the
+// session highlighted in the picker. It returns false for keys it doesn't
+// handle.
+func (m *model) handleSessionPickerKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch {
+	case m.sessionAction == sessionActionRename:
+		if msg.Type != tea.KeyEsc {
+			return false, nil
+		}
+		// Back to the picker
+		m.sessionAction = ""
+		m.input.Placeholder = inputPlaceholder
+		m.resetInput()
+		return true, m.fetchSessions
+
+	case m.sessionAction == sessionActionDelete:
+		session, ok := m.selectedSession()
+		m.sessionAction = ""
+		m.choicePrompt = "Select a session to resume"
+		m.dirty = true
+		m.refresh()
+		if !ok || (msg.String() != "y" && msg.String() != "Y") {
+			return true, nil
+		}
+		return true, func() tea.Msg {
+			if err := m.agent.DeleteSession(session.ID); err != nil {
+				return sessionActionMsg{err: err}
+			}
+			return sessionActionMsg{text: fmt.Sprintf("Deleted session %s.", session.ID)}
+		}
+
+	case !m.inChoiceMode || m.choiceType != "session":
+		return false, nil
+	}
+
+	session, ok := m.selectedSession()
+	if !ok {
+		return false, nil
+	}
+	switch msg.String() {
+	case "r":
+		m.sessionAction = sessionActionRename
+		m.inChoiceMode = false
+		m.input.Placeholder = fmt.Sprintf("New name for session %s (Esc to go back)", session.ID)
+		m.input.SetValue(session.Name)
+		m.input.CursorEnd()
+	case "d":
+		m.sessionAction = sessionActionDelete
+		m.choicePrompt = fmt.Sprintf("Delete session %s? (y/n)", sessionLabel(session))
+	default:
+		return false, nil
+	}
+	m.dirty = true
+	m.refresh()
+	return true, nil
+}
+
+// renameSelectedSession renames the session picked for renaming to the
+// entered name.
+func (m *model) renameSelectedSession() tea.Cmd {
+	session, _ := m.selectedSession()
+	name := strings.TrimSpace(m.inputValue())
+	m.sessionAction = ""
+	m.input.Placeholder = inputPlaceholder
+	m.resetInput()
+	if name == "" {
+		return m.fetchSessions
+	}
+	return func() tea.Msg {
+		if err := m.agent.RenameSession(session.ID, name); err != nil {
+			return sessionActionMsg{err: err}
+		}
+		return sessionActionMsg{text: fmt.Sprintf("Renamed session %s to %q.", session.ID, name)}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/journal"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/sessions"
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/tools"
 	"github.com/charmbracelet/glamour"
 	"github.com/chzyer/readline"
@@ -145,7 +146,7 @@ func (u *TerminalUI) Run(ctx context.Context) error {
 	if len(session.Messages) > 0 {
 		greeting := "Welcome back. What can I help you with today?\n (Don't want to continue your last session? Use --new-session)"
 		// If it's a persistent session (not memory), print metadata
-		if u.agent.SessionBackend == "filesystem" {
+		if u.agent.SessionBackend == "filesystem" || sessions.IsRemoteBackend(u.agent.SessionBackend) {
 			greeting = fmt.Sprintf("%s\n\n%s", greeting, session.String())
 		}
 		out, _ := u.markdownRenderer.Render(greeting)
@@ -226,6 +227,33 @@ func (u *TerminalUI) readlineInstance() (*readline.Instance, error) {
 	return u.rlInstance, nil
 }
 
+// readLine prompts for a single line of input, from the TTY or readline.
+func (u *TerminalUI) readLine(prompt string) (string, error) {
+	if u.useTTYForInput {
+		tReader, err := u.ttyReader()
+		if err != nil {
+			return "", err
+		}
+		fmt.Print(prompt)
+		line, err := tReader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	rlInstance, err := u.readlineInstance()
+	if err != nil {
+		return "", err
+	}
+	rlInstance.SetPrompt(prompt)
+	line, err := rlInstance.Readline()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
 func (u *TerminalUI) Close() error {
 	var errs []error
 
@@ -260,12 +288,22 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 		case api.MessageSourceModel:
 			styleOptions = append(styleOptions, renderMarkdown())
 		}
+	case api.MessageTypeTextDelta:
+		// The complete text is rendered as markdown once it has been received
+		return
+	case api.MessageTypeToolOutputDelta:
+		// The complete output is shown once the tool call is done
+		return
 	case api.MessageTypeError:
 		styleOptions = append(styleOptions, foreground(colorRed))
 		text = msg.Payload.(string)
 	case api.MessageTypeToolCallRequest:
 		styleOptions = append(styleOptions, foreground(colorGreen))
-		text = fmt.Sprintf("\n  Running: %s\n", msg.Payload.(string))
+		if msg.KubeContext != "" {
+			text = fmt.Sprintf("\n  Running [%s]: %s\n", msg.KubeContext, msg.Payload.(string))
+		} else {
+			text = fmt.Sprintf("\n  Running: %s\n", msg.Payload.(string))
+		}
 	case api.MessageTypeToolCallResponse:
 		if !u.showToolOutput {
 			return
@@ -425,7 +463,35 @@ func (u *TerminalUI) handleMessage(msg *api.Message) {
 
 			fmt.Println("Invalid choice. Please try again.")
 		}
-		u.agent.Input <- &api.UserChoiceResponse{Choice: choice}
+		justification, err := u.readLine("Justification (optional, Enter to skip): ")
+		if err != nil {
+			klog.Infof("Error reading justification: %v", err)
+		}
+		u.agent.Input <- &api.UserChoiceResponse{Choice: choice, Justification: justification}
+		return
+	case api.MessageTypeUserChoiceResponse:
+		// The user's own choice, already on screen
+		return
+	case api.MessageTypeContinueRequest:
+		req := msg.Payload.(*api.ContinueRequest)
+		text, _ := u.markdownRenderer.Render(fmt.Sprintf("Stopped: %s.\n\n%s", req.Reason, req.Summary))
+		fmt.Printf("\n%s\n", text)
+		answer, err := u.readLine("Do you want to continue? (y/N): ")
+		if err == io.EOF || err == readline.ErrInterrupt {
+			u.agent.Input <- io.EOF
+			return
+		}
+		if err != nil {
+			klog.Infof("Error reading the answer: %v", err)
+		}
+		answer = strings.ToLower(answer)
+		u.agent.Input <- &api.ContinueResponse{Continue: answer == "y" || answer == "yes"}
+		return
+	case api.MessageTypeImage:
+		fmt.Printf("\n  📎 Attached %s\n", imageLabel(msg.Payload))
+		return
+	case api.MessageTypeAttachment:
+		fmt.Printf("\n  📎 Attached %s\n", attachmentLabel(msg.Payload))
 		return
 	default:
 		klog.Warningf("unsupported message type: %v", msg.Type)
@@ -471,6 +537,34 @@ func (u *TerminalUI) ClearScreen() {
 	fmt.Print("\033[H\033[2J")
 }
 
+// imageLabel describes an attached image, e.g. "dashboard.png (image/png, 120 KB)".
+// payload is an api.Image, or its JSON form in sessions that were loaded from disk.
+func imageLabel(payload any) string {
+	var image api.Image
+	if b, err := json.Marshal(payload); err == nil {
+		_ = json.Unmarshal(b, &image)
+	}
+	name := image.Name
+	if name == "" {
+		name = "image"
+	}
+	return fmt.Sprintf("%s (%s, %d KB)", name, image.MIMEType, (image.Size+1023)/1024)
+}
+
+// attachmentLabel describes an attached file, e.g. "app.log (48 KB, 2 parts)".
+// payload is an api.Attachment, or its JSON form in sessions that were loaded from disk.
+func attachmentLabel(payload any) string {
+	var attachment api.Attachment
+	if b, err := json.Marshal(payload); err == nil {
+		_ = json.Unmarshal(b, &attachment)
+	}
+	label := fmt.Sprintf("%s (%d KB", attachment.Name, (attachment.Size+1023)/1024)
+	if attachment.Chunks > 1 {
+		label += fmt.Sprintf(", %d parts", attachment.Chunks)
+	}
+	return label + ")"
+}
+
 func formatToolCallResponse(payload map[string]any) string {
 	if payload == nil {
 		return ""
@@ -27,14 +27,19 @@ import (
 	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/lipgloss"
 	"k8s.io/klog/v2"
 )
 
+const inputPlaceholder = "Ask kubectl-ai anything..."
+
 const logo = `
  _          _               _   _             _
 | | ___   _| |__   ___  ___| |_| |       __ _(_)
@@ -86,6 +91,13 @@ var (
 	codeStyle   = lipgloss.NewStyle().Foreground(colorText).Background(colorBgCode).Padding(0, 1)
 )
 
+// multilineHeight is the height of the input editor in multiline mode.
+const multilineHeight = 6
+
+// toolOutputLines is how many of the last lines of the output of a running
+// command its tool box shows.
+const toolOutputLines = 10
+
 // List item for choice selection
 type item string
 
@@ -164,6 +176,50 @@ func (m *model) fetchSessions() tea.Msg {
 	return sessionListMsg(sessions)
 }
 
+// sessionLabel describes a session in the session picker.
+func sessionLabel(s api.SessionInfo) string {
+	label := fmt.Sprintf("%s (%s) • %d msgs", s.ID, s.ModelID, s.MessageCount)
+	if s.Name != "" {
+		label = fmt.Sprintf("%s (%s) • %s • %d msgs", s.Name, s.ModelID, s.ID, s.MessageCount)
+	}
+	if len(s.Tags) > 0 {
+		label += " • #" + strings.Join(s.Tags, " #")
+	}
+	return label
+}
+
+// kubeTargetMsg is the kubeconfig context and namespace tools target.
+type kubeTargetMsg struct {
+	context, namespace string
+}
+
+func (m *model) fetchKubeTarget() tea.Msg {
+	kubeContext, namespace, err := m.agent.KubeTarget()
+	if err != nil {
+		klog.Warningf("failed to read the current kubeconfig context: %v", err)
+	}
+	return kubeTargetMsg{context: kubeContext, namespace: namespace}
+}
+
+// feedbackMsg reports the outcome of rating the last answer.
+type feedbackMsg struct {
+	text string
+	err  error
+}
+
+// rateLastAnswer records the rating of the last answer of the agent.
+func (m *model) rateLastAnswer(rating api.FeedbackRating) tea.Cmd {
+	return func() tea.Msg {
+		if _, err := m.agent.RecordFeedback(context.Background(), "", rating); err != nil {
+			return feedbackMsg{err: err}
+		}
+		if rating == api.FeedbackPositive {
+			return feedbackMsg{text: "👍 Thanks for the feedback on the last answer."}
+		}
+		return feedbackMsg{text: "👎 Thanks for the feedback on the last answer."}
+	}
+}
+
 type tickMsg time.Time
 
 // Render cache for markdown
@@ -201,7 +257,7 @@ func (rc *renderCache) getRenderer(width int) (*glamour.TermRenderer, error) {
 		rc.renderer = nil
 	}
 	if rc.renderer == nil {
-		r, err := glamour.NewTermRenderer(glamour.WithStylePath("dark"), glamour.WithWordWrap(width))
+		r, err := glamour.NewTermRenderer(glamour.WithStyles(markdownStyle()), glamour.WithWordWrap(width))
 		if err != nil {
 			return nil, err
 		}
@@ -210,11 +266,61 @@ func (rc *renderCache) getRenderer(width int) (*glamour.TermRenderer, error) {
 	return rc.renderer, nil
 }
 
+// markdownStyle is glamour's dark style with syntax highlighting of fenced code
+// blocks (yaml, json, bash, ...) in the colors of our palette.
+func markdownStyle() ansi.StyleConfig {
+	color := func(c lipgloss.Color) ansi.StylePrimitive {
+		s := string(c)
+		return ansi.StylePrimitive{Color: &s}
+	}
+	bold := func(c lipgloss.Color) ansi.StylePrimitive {
+		p := color(c)
+		b := true
+		p.Bold = &b
+		return p
+	}
+
+	background := string(colorBgCode)
+	style := styles.DarkStyleConfig
+	style.CodeBlock.Chroma = &ansi.Chroma{
+		Text:                color(colorText),
+		Error:               color(colorError),
+		Comment:             color(colorMuted),
+		CommentPreproc:      color(colorMuted),
+		Keyword:             bold(colorPrimary),
+		KeywordReserved:     bold(colorPrimary),
+		KeywordNamespace:    bold(colorPrimary),
+		KeywordType:         color(colorPrimary),
+		Operator:            color(colorText),
+		Punctuation:         color(colorMuted),
+		Name:                color(colorText),
+		NameBuiltin:         color(colorPrimary),
+		NameTag:             color(colorPrimary),
+		NameAttribute:       color(colorPrimary),
+		NameClass:           bold(colorWarning),
+		NameConstant:        color(colorWarning),
+		NameDecorator:       color(colorWarning),
+		NameFunction:        color(colorPrimary),
+		Literal:             color(colorWarning),
+		LiteralNumber:       color(colorWarning),
+		LiteralDate:         color(colorWarning),
+		LiteralString:       color(colorSecondary),
+		LiteralStringEscape: color(colorWarning),
+		GenericDeleted:      color(colorError),
+		GenericInserted:     color(colorSecondary),
+		GenericSubheading:   color(colorMuted),
+		Background:          ansi.StylePrimitive{BackgroundColor: &background},
+	}
+	return style
+}
+
 // Model state
 type model struct {
 	agent      *agent.Agent
 	viewport   viewport.Model
 	input      textinput.Model
+	textarea   textarea.Model
+	multiline  bool // the multiline editor is used for input
 	spinner    spinner.Model
 	list       list.Model
 	cache      *renderCache
@@ -224,17 +330,38 @@ type model struct {
 	dirty      bool
 	quitting   bool
 	thinkStart time.Time
+	// notices are the messages of the UI itself, e.g. the outcome of
+	// "copy", which are kept when messages is reloaded from the session
+	// noticesSessionID is in.
+	notices          []*api.Message
+	noticesSessionID string
+	// Prompt history browsing; historyIndex is -1 when not browsing
+	historyIndex int
+	historyDraft string
 	// Choice mode tracking
 	inChoiceMode   bool
 	choicePrompt   string
 	choiceOptionID string // Track which choice request we initialized for
-	choiceType     string // "confirm" or "session"
-	sessionIDs     []string
+	choiceType     string // "confirm", "continue" or "session"
+	sessions       []api.SessionInfo
+	// sessionAction is sessionActionRename or sessionActionDelete while the
+	// session picked is renamed or its deletion confirmed.
+	sessionAction string
+	// pendingChoice is the choice waiting for an optional justification, 0 if none
+	pendingChoice int
+	// kubeContext and kubeNamespace are what tools target, shown in the
+	// status bar to avoid running commands against the wrong cluster.
+	kubeContext   string
+	kubeNamespace string
+	// runningToolCalls are the IDs of the requests of the running tool calls,
+	// and toolOutputs the last lines of their output so far.
+	runningToolCalls map[string]bool
+	toolOutputs      map[string]string
 }
 
 func newModel(agent *agent.Agent) model {
 	ti := textinput.New()
-	ti.Placeholder = "Ask kubectl-ai anything..."
+	ti.Placeholder = inputPlaceholder
 	ti.Focus()
 	ti.Prompt = ""
 	ti.CharLimit = 4096
@@ -243,6 +370,17 @@ func newModel(agent *agent.Agent) model {
 	ti.PlaceholderStyle = dimStyle
 	ti.Cursor.Style = primaryText
 
+	ta := textarea.New()
+	ta.Placeholder = "Ask kubectl-ai anything... (Ctrl+Enter to send)"
+	ta.Prompt = ""
+	ta.ShowLineNumbers = false
+	ta.CharLimit = 0
+	ta.SetHeight(multilineHeight)
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	ta.FocusedStyle.Text = textStyle
+	ta.FocusedStyle.Placeholder = dimStyle
+	ta.Cursor.Style = primaryText
+
 	sp := spinner.New()
 	sp.Spinner = spinner.MiniDot
 	sp.Style = primaryText
@@ -260,16 +398,22 @@ func newModel(agent *agent.Agent) model {
 	return model{
 		agent:    agent,
 		input:    ti,
+		textarea: ta,
 		viewport: vp,
 		spinner:  sp,
 		list:     l,
 		cache:    newRenderCache(),
 		dirty:    true,
+
+		runningToolCalls: map[string]bool{},
+		toolOutputs:      map[string]string{},
+
+		historyIndex: -1,
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(textinput.Blink, m.spinner.Tick, m.tick())
+	return tea.Batch(textinput.Blink, m.spinner.Tick, m.tick(), m.fetchKubeTarget)
 }
 
 func (m model) tick() tea.Cmd {
@@ -302,46 +446,51 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
+		// Animate the spinners and elapsed times of the running commands
+		if len(m.runningToolCalls) > 0 {
+			m.dirty = true
+			m.refresh()
+		}
 		return m, cmd
 
 	case tickMsg:
 		return m, m.tick()
 
+	case feedbackMsg:
+		if msg.err != nil {
+			m.notify("Error: " + msg.err.Error())
+		} else {
+			m.notify(msg.text)
+		}
+		return m, nil
+
+	case kubeTargetMsg:
+		if msg.context != m.kubeContext || msg.namespace != m.kubeNamespace {
+			m.kubeContext, m.kubeNamespace = msg.context, msg.namespace
+			m.dirty = true
+		}
+		return m, nil
+
 	case sessionListMsg:
 		if len(msg) == 0 {
-			m.messages = append(m.messages, &api.Message{
-				Source:    api.MessageSourceAgent,
-				Type:      api.MessageTypeText,
-				Payload:   "No sessions found.",
-				Timestamp: time.Now(),
-			})
-			m.dirty = true
-			m.refresh()
-			m.viewport.GotoBottom()
+			m.notify("No sessions found.")
 			return m, nil
 		}
 
-		items := make([]list.Item, len(msg))
-		ids := make([]string, len(msg))
-		for i, s := range msg {
-			label := fmt.Sprintf("%s (%s) • %d msgs", s.ID, s.ModelID, s.MessageCount)
-			if s.Name != "" {
-				label = fmt.Sprintf("%s (%s) • %s • %d msgs", s.Name, s.ModelID, s.ID, s.MessageCount)
-			}
-			items[i] = item(label)
-			ids[i] = s.ID
-		}
-		m.list.SetItems(items)
-		m.list.Select(0)
-		m.inChoiceMode = true
-		m.choicePrompt = "Select a session to resume"
-		m.choiceOptionID = "manual-session-picker"
-		m.choiceType = "session"
-		m.sessionIDs = ids
+		m.showSessionPicker(msg, "manual-session-picker")
 		m.dirty = true
 		m.refresh()
 		m.viewport.GotoBottom()
 		return m, nil
+
+	case sessionActionMsg:
+		if msg.err != nil {
+			m.notify("Error: " + msg.err.Error())
+		} else {
+			m.notify(msg.text)
+		}
+		// Back to the picker, with the updated sessions
+		return m, m.fetchSessions
 	}
 	return m, nil
 }
@@ -349,6 +498,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m *model) resize() {
 	m.viewport.Width = m.width - 2
 	m.input.Width = m.width - 6
+	m.textarea.SetWidth(m.width - 6)
 	m.list.SetWidth(m.width - 4)
 	m.updateViewportHeight()
 	m.refresh()
@@ -358,6 +508,9 @@ func (m *model) resize() {
 func (m *model) updateViewportHeight() {
 	// Layout: status(1) + 2 dividers(2) + input(3) + help(1) + bottom padding(1) = 8
 	contentH := m.height - 8
+	if m.multiline {
+		contentH -= multilineHeight - 1
+	}
 
 	contentH = max(contentH, 5)
 	m.viewport.Height = contentH
@@ -372,25 +525,61 @@ func (m *model) navigateList(keyType tea.KeyType) tea.Cmd {
 }
 
 func (m *model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if handled, cmd := m.handleSessionPickerKey(msg); handled {
+		return m, cmd
+	}
+
+	// Kill the running commands, the agent goes on; otherwise Ctrl+K edits the input
+	if msg.Type == tea.KeyCtrlK && m.agent.CancelToolCalls() {
+		return m, nil
+	}
+
 	switch msg.Type {
 	case tea.KeyCtrlC, tea.KeyCtrlD:
 		m.quitting = true
 		return m, tea.Quit
-	case tea.KeyEsc:
-		m.input.Reset()
+	case tea.KeyEsc, tea.KeyCtrlX:
+		// Cancel the in-flight LLM call if the agent is running, otherwise clear the input
+		if m.agent.CancelGeneration() {
+			return m, nil
+		}
+		m.resetInput()
+		return m, nil
+	case tea.KeyCtrlT:
+		return m, m.toggleMultiline()
+	case tea.KeyCtrlY:
+		m.copyLastBlock()
 		return m, nil
+	case tea.KeyCtrlG:
+		return m, m.rateLastAnswer(api.FeedbackPositive)
+	case tea.KeyCtrlR:
+		return m, m.rateLastAnswer(api.FeedbackNegative)
+	}
+
+	if m.multiline && !m.inChoiceMode {
+		return m.handleMultilineKey(msg)
+	}
+
+	// Switch to the multiline editor when multi-line text is pasted
+	if msg.Paste && strings.ContainsAny(string(msg.Runes), "\r\n") {
+		cmd := m.toggleMultiline()
+		m.textarea.InsertString(string(msg.Runes))
+		return m, cmd
+	}
+
+	switch msg.Type {
 	case tea.KeyEnter:
 		return m.handleEnter()
 	case tea.KeyUp:
 		if m.inChoiceMode {
 			return m, m.navigateList(tea.KeyUp)
 		}
-		m.viewport.ScrollUp(1)
+		m.recallHistory(-1)
 	case tea.KeyDown:
 		if m.inChoiceMode {
 			return m, m.navigateList(tea.KeyDown)
 		}
-		m.viewport.ScrollDown(1)
+		m.recallHistory(1)
 	case tea.KeyPgUp:
 		m.viewport.ScrollUp(m.viewport.Height / 2)
 	case tea.KeyPgDown:
@@ -418,18 +607,78 @@ func (m *model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handleMultilineKey handles keys while the multiline editor is active.
+// Enter inserts a newline; Ctrl+Enter (or Alt+Enter) sends the input.
+func (m *model) handleMultilineKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyCtrlJ, msg.String() == "ctrl+enter", msg.Alt && msg.Type == tea.KeyEnter:
+		// Most terminals send Ctrl+Enter as a line feed (Ctrl+J)
+		return m.handleEnter()
+	case msg.Type == tea.KeyPgUp:
+		m.viewport.ScrollUp(m.viewport.Height / 2)
+		return m, nil
+	case msg.Type == tea.KeyPgDown:
+		m.viewport.ScrollDown(m.viewport.Height / 2)
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+	return m, cmd
+}
+
+// toggleMultiline switches between the single line input and the multiline
+// editor, carrying over any text that was entered. The editor stays open while
+// its text has several lines, as the single line input can't keep newlines.
+func (m *model) toggleMultiline() tea.Cmd {
+	if m.multiline && strings.Contains(m.textarea.Value(), "\n") {
+		return nil
+	}
+	m.multiline = !m.multiline
+	m.dirty = true
+	var cmd tea.Cmd
+	if m.multiline {
+		m.textarea.SetValue(m.input.Value())
+		m.input.Reset()
+		m.input.Blur()
+		cmd = m.textarea.Focus()
+	} else {
+		m.input.SetValue(m.textarea.Value())
+		m.textarea.Reset()
+		m.textarea.Blur()
+		cmd = m.input.Focus()
+	}
+	m.updateViewportHeight()
+	m.refresh()
+	m.viewport.GotoBottom()
+	return cmd
+}
+
+// inputValue returns the text entered in the active input.
+func (m *model) inputValue() string {
+	if m.multiline {
+		return m.textarea.Value()
+	}
+	return m.input.Value()
+}
+
+func (m *model) resetInput() {
+	m.input.Reset()
+	m.textarea.Reset()
+	m.historyIndex = -1
+	m.historyDraft = ""
+}
+
 func (m *model) handleEnter() (tea.Model, tea.Cmd) {
 	// Handle choice selection
 	if m.inChoiceMode {
 		if _, ok := m.list.SelectedItem().(item); ok {
 			if m.choiceType == "session" {
-				idx := m.list.Index()
-				if idx >= 0 && idx < len(m.sessionIDs) {
-					selectedID := m.sessionIDs[idx]
+				if session, ok := m.selectedSession(); ok {
+					selectedID := session.ID
 					m.inChoiceMode = false
 					m.choicePrompt = ""
 					m.choiceOptionID = ""
-					// Don't reset choiceType/sessionIDs yet or it might race, but actually we are done.
+					// Don't reset choiceType/sessions yet or it might race, but actually we are done.
 					m.dirty = true
 					m.refresh()
 					return m, func() tea.Msg {
@@ -437,35 +686,71 @@ func (m *model) handleEnter() (tea.Model, tea.Cmd) {
 						return nil
 					}
 				}
-			} else {
-				choice := m.list.Index() + 1
+			} else if m.choiceType == "continue" {
+				response := &api.ContinueResponse{Continue: m.list.Index() == 0}
 				m.inChoiceMode = false
 				m.choicePrompt = ""
 				m.choiceOptionID = ""
 				m.dirty = true
 				m.refresh()
 				return m, func() tea.Msg {
-					m.agent.Input <- &api.UserChoiceResponse{Choice: choice}
+					m.agent.Input <- response
 					return nil
 				}
+			} else {
+				// Ask for an optional justification before sending the choice
+				m.pendingChoice = m.list.Index() + 1
+				m.inChoiceMode = false
+				m.choicePrompt = ""
+				m.choiceOptionID = ""
+				m.input.Placeholder = "Justification for this decision (optional)"
+				m.dirty = true
+				m.refresh()
+				return m, nil
 			}
 		}
 		return m, nil
 	}
 
-	value := strings.TrimSpace(m.input.Value())
+	if m.sessionAction == sessionActionRename {
+		return m, m.renameSelectedSession()
+	}
+
+	if m.pendingChoice != 0 {
+		response := &api.UserChoiceResponse{
+			Choice:        m.pendingChoice,
+			Justification: strings.TrimSpace(m.inputValue()),
+		}
+		m.pendingChoice = 0
+		m.input.Placeholder = inputPlaceholder
+		m.resetInput()
+		m.dirty = true
+		m.refresh()
+		return m, func() tea.Msg {
+			m.agent.Input <- response
+			return nil
+		}
+	}
+
+	value := strings.TrimSpace(m.inputValue())
 	if value == "" {
 		return m, nil
 	}
 
-	// Add user message
-	m.messages = append(m.messages, &api.Message{
+	// Add user message. The commands handled by the UI aren't added to the
+	// session, so they are kept as notices.
+	userMessage := &api.Message{
 		Source:    api.MessageSourceUser,
 		Type:      api.MessageTypeText,
 		Payload:   value,
 		Timestamp: time.Now(),
-	})
-	m.input.Reset()
+	}
+	if value == "sessions" || value == "copy" || strings.HasPrefix(value, "copy ") {
+		m.addNotice(userMessage)
+	} else {
+		m.messages = append(m.messages, userMessage)
+	}
+	m.resetInput()
 	m.dirty = true
 	m.refresh()
 	m.viewport.GotoBottom()
@@ -474,6 +759,11 @@ func (m *model) handleEnter() (tea.Model, tea.Cmd) {
 	if value == "sessions" {
 		return m, m.fetchSessions
 	}
+	// Intercept "copy" and "copy <n>", which are handled by the UI
+	if value == "copy" || strings.HasPrefix(value, "copy ") {
+		m.handleCopyCommand(value)
+		return m, nil
+	}
 
 	m.thinkStart = time.Now()
 
@@ -484,9 +774,22 @@ func (m *model) handleEnter() (tea.Model, tea.Cmd) {
 }
 
 func (m *model) handleAgentMsg(msg *api.Message) (tea.Model, tea.Cmd) {
+	// Responses are rendered once complete, as markdown
+	if msg.Type == api.MessageTypeTextDelta {
+		return m, nil
+	}
+	if msg.Type == api.MessageTypeToolOutputDelta {
+		m.appendToolOutput(msg)
+		return m, nil
+	}
+
 	session := m.agent.GetSession()
-	m.messages = session.AllMessages()
+	if session.ID != m.noticesSessionID {
+		m.notices = nil
+	}
+	m.messages = withNotices(session.AllMessages(), m.notices)
 	m.dirty = true
+	m.updateRunningToolCalls(msg)
 
 	// Check if we're entering choice mode - use the incoming message directly
 	// to avoid race conditions where the message isn't yet in AllMessages()
@@ -503,25 +806,18 @@ func (m *model) handleAgentMsg(msg *api.Message) (tea.Model, tea.Cmd) {
 			m.choiceOptionID = msg.ID
 			m.choiceType = "confirm"
 		}
-	} else if msg.Type == api.MessageTypeSessionPickerRequest {
-		if req, ok := msg.Payload.(*api.SessionPickerRequest); ok {
-			items := make([]list.Item, len(req.Sessions))
-			ids := make([]string, len(req.Sessions))
-			for i, s := range req.Sessions {
-				label := fmt.Sprintf("%s (%s) • %d msgs", s.ID, s.ModelID, s.MessageCount)
-				if s.Name != "" {
-					label = fmt.Sprintf("%s (%s) • %s • %d msgs", s.Name, s.ModelID, s.ID, s.MessageCount)
-				}
-				items[i] = item(label)
-				ids[i] = s.ID
-			}
-			m.list.SetItems(items)
+	} else if msg.Type == api.MessageTypeContinueRequest {
+		if req, ok := msg.Payload.(*api.ContinueRequest); ok {
+			m.list.SetItems([]list.Item{item("Yes, continue"), item("No, stop here")})
 			m.list.Select(0)
 			m.inChoiceMode = true
-			m.choicePrompt = "Select a session to resume"
+			m.choicePrompt = fmt.Sprintf("Stopped: %s. Do you want to continue?", req.Reason)
 			m.choiceOptionID = msg.ID
-			m.choiceType = "session"
-			m.sessionIDs = ids
+			m.choiceType = "continue"
+		}
+	} else if msg.Type == api.MessageTypeSessionPickerRequest {
+		if req, ok := msg.Payload.(*api.SessionPickerRequest); ok {
+			m.showSessionPicker(req.Sessions, msg.ID)
 		}
 	} else if session.AgentState == api.AgentStateDone || session.AgentState == api.AgentStateExited {
 		// Clear choice mode if we're done or exited
@@ -533,10 +829,53 @@ func (m *model) handleAgentMsg(msg *api.Message) (tea.Model, tea.Cmd) {
 	m.refresh()
 	m.viewport.GotoBottom()
 
+	// Tool calls may change the context or namespace, e.g. switch_context or
+	// kubectl config set-context
+	var cmd tea.Cmd
+	if msg.Type == api.MessageTypeToolCallResponse {
+		cmd = m.fetchKubeTarget
+	}
 	if session.AgentState == api.AgentStateRunning || session.AgentState == api.AgentStateInitializing {
-		return m, m.spinner.Tick
+		return m, tea.Batch(m.spinner.Tick, cmd)
 	}
-	return m, nil
+	return m, cmd
+}
+
+// updateRunningToolCalls updates the running tool calls on a message of the
+// agent, dropping the output of those that are done.
+func (m *model) updateRunningToolCalls(msg *api.Message) {
+	clear(m.runningToolCalls)
+	for _, id := range m.agent.RunningToolCalls() {
+		m.runningToolCalls[id] = true
+	}
+	// The request is sent just before the call starts
+	if msg.Type == api.MessageTypeToolCallRequest {
+		m.runningToolCalls[msg.ID] = true
+	}
+	for id := range m.toolOutputs {
+		if !m.runningToolCalls[id] {
+			delete(m.toolOutputs, id)
+		}
+	}
+}
+
+// appendToolOutput adds output of a running tool call to its tool box,
+// keeping the last toolOutputLines lines.
+func (m *model) appendToolOutput(msg *api.Message) {
+	delta, ok := msg.Payload.(*api.ToolOutputDelta)
+	if !ok {
+		return
+	}
+	m.runningToolCalls[msg.ID] = true
+	output := m.toolOutputs[msg.ID] + strings.ReplaceAll(delta.Text, "\r", "")
+	if lines := strings.Split(output, "\n"); len(lines) > toolOutputLines+1 {
+		output = strings.Join(lines[len(lines)-toolOutputLines-1:], "\n")
+	}
+	m.toolOutputs[msg.ID] = output
+
+	m.dirty = true
+	m.refresh()
+	m.viewport.GotoBottom()
 }
 
 func (m *model) refresh() {
@@ -613,6 +952,14 @@ func (m model) renderMessage(msg *api.Message, r *glamour.TermRenderer, w int) s
 		result = m.renderToolCall(msg, w)
 	case api.MessageTypeError:
 		result = m.renderError(msg, w)
+	case api.MessageTypeImage:
+		result = mutedStyle.Render("📎 Attached "+imageLabel(msg.Payload)) + "\n"
+	case api.MessageTypeAttachment:
+		result = mutedStyle.Render("📎 Attached "+attachmentLabel(msg.Payload)) + "\n"
+	case api.MessageTypeContinueRequest:
+		if req, ok := msg.Payload.(*api.ContinueRequest); ok {
+			result = m.renderTextMsg(&api.Message{Source: msg.Source, Timestamp: msg.Timestamp, Payload: req.Summary}, r, w)
+		}
 	default:
 		result = m.renderTextMsg(msg, r, w)
 	}
@@ -653,7 +1000,21 @@ func (m model) renderToolCall(msg *api.Message, w int) string {
 	if !ok {
 		return ""
 	}
-	content := successText.Render("⚡ Running") + "\n" + codeStyle.Render(payload)
+	running := m.runningToolCalls[msg.ID]
+	header := successText.Render("⚡ Running")
+	if running {
+		header = successText.Render(m.spinner.View() + " Running")
+	}
+	if msg.KubeContext != "" {
+		header += mutedStyle.Render(" on " + msg.KubeContext)
+	}
+	if running {
+		header += mutedStyle.Render(" " + formatDuration(time.Since(msg.Timestamp)))
+	}
+	content := header + "\n" + codeStyle.Render(payload)
+	if output := strings.TrimRight(m.toolOutputs[msg.ID], "\n"); running && output != "" {
+		content += "\n" + mutedStyle.Render(output)
+	}
 	return toolBox.Width(w).Render(content) + "\n"
 }
 
@@ -696,6 +1057,15 @@ func (m model) viewStatus(session *api.Session) string {
 		model = "unknown"
 	}
 	right := lipgloss.NewStyle().Foreground(colorSecondary).Render(model)
+	if session.Usage.Requests > 0 {
+		right = mutedStyle.Render(session.Usage.CostString()) + sep + right
+	}
+	if meter := viewContextMeter(session.Usage); meter != "" {
+		right = meter + sep + right
+	}
+	if m.kubeContext != "" {
+		right = warnText.Render("⎈ "+m.kubeContext) + mutedStyle.Render("/"+m.kubeNamespace) + sep + right
+	}
 
 	gap := m.width - lipgloss.Width(left) - lipgloss.Width(right) - 2
 	if gap < 0 {
@@ -704,6 +1074,26 @@ func (m model) viewStatus(session *api.Session) string {
 	return statusBar.Width(m.width).Render(" " + left + strings.Repeat(" ", gap) + right + " ")
 }
 
+// viewContextMeter renders how much of the context window of the model the
+// conversation uses, e.g. "ctx ███░░░░░ 38%", or "" if the window is unknown.
+func viewContextMeter(usage api.SessionUsage) string {
+	used, ok := usage.ContextUsed()
+	if !ok {
+		return ""
+	}
+	const width = 8
+	filled := min(int(used*width+0.5), width)
+	style := mutedStyle
+	switch {
+	case used >= 1:
+		style = errorText
+	case used >= api.ContextWarningThreshold:
+		style = warnText
+	}
+	bar := style.Render(strings.Repeat("█", filled)) + dimStyle.Render(strings.Repeat("░", width-filled))
+	return mutedStyle.Render("ctx ") + bar + style.Render(fmt.Sprintf(" %.0f%%", used*100))
+}
+
 func (m model) viewState(state api.AgentState) string {
 	states := map[api.AgentState]struct {
 		icon, text string
@@ -748,19 +1138,37 @@ func (m model) viewInput(state api.AgentState) string {
 		return lipgloss.NewStyle().Padding(0, 1).Render(inputBoxDim.Width(m.width - 4).Render(content))
 	}
 
+	if m.multiline {
+		return lipgloss.NewStyle().Padding(0, 1).Render(inputBox.Width(m.width - 4).Render(m.textarea.View()))
+	}
 	return lipgloss.NewStyle().Padding(0, 1).Render(inputBox.Width(m.width - 4).Render(m.input.View()))
 }
 
 func (m model) viewHelp(state api.AgentState) string {
 	var hints []string
-	if m.inChoiceMode {
+	if m.inChoiceMode && m.sessionAction == sessionActionDelete {
+		hints = []string{"y: delete", "n: keep", "Ctrl+C: quit"}
+	} else if m.inChoiceMode && m.choiceType == "session" {
+		hints = []string{"↑/↓: navigate", "Enter: resume", "r: rename", "d: delete", "Ctrl+C: quit"}
+	} else if m.inChoiceMode {
 		hints = []string{"↑/↓: navigate", "Enter: select", "Ctrl+C: quit"}
+	} else if m.sessionAction == sessionActionRename {
+		hints = []string{"Enter: rename", "Esc: back to sessions", "Ctrl+C: quit"}
+	} else if m.pendingChoice != 0 {
+		hints = []string{"Type a justification (optional)", "Enter: confirm", "Ctrl+C: quit"}
+	} else if state == api.AgentStateRunning && len(m.runningToolCalls) > 0 {
+		hints = []string{"Ctrl+K: kill command", "Esc: cancel", "Ctrl+C: quit"}
 	} else if state == api.AgentStateRunning {
-		hints = []string{"Ctrl+C: cancel"}
+		hints = []string{"Esc: cancel", "Ctrl+C: quit"}
 	} else {
-		hints = []string{"Enter: send", "Esc: clear", "Ctrl+C: quit"}
+		hints = []string{"Enter: send", "↑/↓: history", "Esc: clear", "Ctrl+T: multiline", "Ctrl+Y: copy", "Ctrl+G/Ctrl+R: 👍/👎", "Ctrl+C: quit"}
+		if m.multiline && strings.Contains(m.textarea.Value(), "\n") {
+			hints = []string{"Ctrl+Enter: send", "Enter: newline", "Esc: clear", "Ctrl+C: quit"}
+		} else if m.multiline {
+			hints = []string{"Ctrl+Enter: send", "Enter: newline", "Esc: clear", "Ctrl+T: single line", "Ctrl+C: quit"}
+		}
 		if m.viewport.TotalLineCount() > m.viewport.Height {
-			hints = append(hints, "↑/↓: scroll")
+			hints = append(hints, "PgUp/PgDn: scroll")
 		}
 	}
 	return dimStyle.Padding(0, 2, 1, 2).Render(strings.Join(hints, " • "))
@@ -0,0 +1,266 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/agent"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/api"
+	"github.com/GoogleCloudPlatform/kubectl-ai/pkg/notify"
+	"k8s.io/klog/v2"
+)
+
+// ErrWatchChanged is returned by WatchUI.Run when the answer changed and
+// ExitOnChange is set.
+var ErrWatchChanged = errors.New("the answer of the watched query changed")
+
+// watchDebounce is how long resource changes are collected before the query
+// is evaluated again, so that a rollout triggers one evaluation, not one per
+// pod.
+const watchDebounce = 10 * time.Second
+
+// watchInstructions are appended to the watched query, so that answers can be
+// compared without being thrown off by their wording.
+const watchInstructions = `
+
+This question is asked repeatedly to detect changes. Only read the state of the cluster, don't change it. End your answer with a single line "STATUS: OK" if nothing needs attention, or "STATUS: ALERT <affected resources, sorted by name>" otherwise.`
+
+var watchStatusRegexp = regexp.MustCompile(`(?im)^[\s*_>` + "`" + `]*status:\s*(.+)$`)
+
+// WatchOptions configure a WatchUI.
+type WatchOptions struct {
+	// Query is the question evaluated, e.g. "are any pods crashlooping?".
+	Query string
+	// Interval is the time between evaluations, zero to only evaluate on
+	// changes of Resources.
+	Interval time.Duration
+	// Resources are the resources whose changes trigger an evaluation, e.g.
+	// pods or deployments.apps.
+	Resources []string
+	// Notifier is told when the answer changes, if set.
+	Notifier notify.Notifier
+	// ExitOnChange stops watching when the answer changes, with
+	// ErrWatchChanged.
+	ExitOnChange bool
+}
+
+// WatchUI evaluates a query over and over, on an interval or when resources
+// change, and notifies when the answer changes. Tool calls needing approval
+// are declined, as nobody is there to approve them.
+type WatchUI struct {
+	agent *agent.Agent
+	opts  WatchOptions
+	out   io.Writer
+
+	// waitingForInput is set when the agent asked for input.
+	waitingForInput bool
+}
+
+var _ UI = &WatchUI{}
+
+func NewWatchUI(agent *agent.Agent, opts WatchOptions) *WatchUI {
+	return &WatchUI{agent: agent, opts: opts, out: os.Stdout}
+}
+
+// ClearScreen is a no-op, the watch UI only ever appends output.
+func (u *WatchUI) ClearScreen() {}
+
+func (u *WatchUI) Run(ctx context.Context) error {
+	triggers := make(chan string, 1)
+	trigger := func(reason string) {
+		select {
+		case triggers <- reason:
+		default:
+			// An evaluation is pending already
+		}
+	}
+
+	if len(u.opts.Resources) > 0 {
+		var mu sync.Mutex
+		var timer *time.Timer
+		err := u.agent.WatchResources(ctx, u.opts.Resources, func(resource string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if timer != nil {
+				return
+			}
+			timer = time.AfterFunc(watchDebounce, func() {
+				mu.Lock()
+				timer = nil
+				mu.Unlock()
+				trigger("change of " + resource)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	var ticks <-chan time.Time
+	if u.opts.Interval > 0 {
+		ticker := time.NewTicker(u.opts.Interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var previous string
+	trigger("start")
+	for {
+		var reason string
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticks:
+			reason = "interval"
+		case reason = <-triggers:
+		}
+
+		answer, err := u.evaluate(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, errAgentExited) {
+				return u.agent.LastErr()
+			}
+			fmt.Fprintf(u.out, "[%s] Error: %v\n", time.Now().Format(time.TimeOnly), err)
+			continue
+		}
+
+		status := watchStatus(answer)
+		switch {
+		case previous == "":
+			fmt.Fprintf(u.out, "[%s] %s\n\n%s\n\n", time.Now().Format(time.TimeOnly), status, strings.TrimSpace(answer))
+		case strings.EqualFold(status, previous):
+			fmt.Fprintf(u.out, "[%s] %s (unchanged, %s)\n", time.Now().Format(time.TimeOnly), status, reason)
+		default:
+			fmt.Fprintf(u.out, "[%s] %s (changed, %s)\n\n%s\n\n", time.Now().Format(time.TimeOnly), status, reason, strings.TrimSpace(answer))
+			u.notify(ctx, previous, status, answer)
+			if u.opts.ExitOnChange {
+				return ErrWatchChanged
+			}
+		}
+		previous = status
+	}
+}
+
+var errAgentExited = errors.New("agent exited")
+
+// evaluate asks the query in a cleared conversation, so that earlier answers
+// don't sway the new one, and returns the answer.
+func (u *WatchUI) evaluate(ctx context.Context) (string, error) {
+	if _, err := u.ask(ctx, "clear"); err != nil {
+		return "", err
+	}
+	return u.ask(ctx, u.opts.Query+watchInstructions)
+}
+
+// ask sends query to the agent once it waits for input, and returns the last
+// text of the model before it waits for input again.
+func (u *WatchUI) ask(ctx context.Context, query string) (string, error) {
+	if !u.waitingForInput {
+		if _, err := u.waitForInput(ctx); err != nil {
+			return "", err
+		}
+	}
+	u.agent.Input <- &api.UserInputResponse{Query: query}
+	u.waitingForInput = false
+	return u.waitForInput(ctx)
+}
+
+// waitForInput reads the messages of the agent until it asks for input, and
+// returns the last text of the model.
+func (u *WatchUI) waitForInput(ctx context.Context) (string, error) {
+	var answer string
+	var errs []string
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case m, ok := <-u.agent.Output:
+			if !ok {
+				return "", errAgentExited
+			}
+			msg := m.(*api.Message)
+			switch msg.Type {
+			case api.MessageTypeUserInputRequest:
+				u.waitingForInput = true
+				if len(errs) > 0 {
+					return "", errors.New(strings.Join(errs, "; "))
+				}
+				return answer, nil
+			case api.MessageTypeText:
+				if msg.Source == api.MessageSourceModel {
+					answer = msg.Payload.(string)
+				}
+			case api.MessageTypeError:
+				errs = append(errs, msg.Payload.(string))
+			case api.MessageTypeToolCallRequest:
+				klog.Infof("watch: running %s", msg.Payload)
+			case api.MessageTypeUserChoiceRequest:
+				req := msg.Payload.(*api.UserChoiceRequest)
+				fmt.Fprintf(u.out, "[%s] Declined: %s\n", time.Now().Format(time.TimeOnly), req.Prompt)
+				u.agent.Input <- &api.UserChoiceResponse{Choice: len(req.Options), Justification: "declined by watch mode"}
+			case api.MessageTypeContinueRequest:
+				req := msg.Payload.(*api.ContinueRequest)
+				fmt.Fprintf(u.out, "[%s] Stopped: %s\n", time.Now().Format(time.TimeOnly), req.Reason)
+				u.agent.Input <- &api.ContinueResponse{Continue: false}
+			}
+			if u.agent.GetSession().AgentState == api.AgentStateExited {
+				return "", errAgentExited
+			}
+		}
+	}
+}
+
+// watchStatus returns the status line the answer ends with, without markup,
+// or the whole answer if the model didn't give a status.
+func watchStatus(answer string) string {
+	matches := watchStatusRegexp.FindAllStringSubmatch(answer, -1)
+	if len(matches) == 0 {
+		return strings.Join(strings.Fields(answer), " ")
+	}
+	status := strings.Trim(matches[len(matches)-1][1], " *_`.")
+	return "STATUS: " + strings.Join(strings.Fields(status), " ")
+}
+
+func (u *WatchUI) notify(ctx context.Context, previous, status, answer string) {
+	if u.opts.Notifier == nil {
+		return
+	}
+	n := &notify.Notification{
+		Event:   notify.EventWatchChanged,
+		Time:    time.Now(),
+		Title:   "kubectl-ai: the answer changed",
+		Message: status,
+		Details: map[string]string{
+			"query":    u.opts.Query,
+			"previous": previous,
+			"answer":   strings.TrimSpace(answer),
+		},
+	}
+	if err := u.opts.Notifier.Notify(ctx, n); err != nil {
+		klog.Warningf("error sending notification: %v", err)
+		fmt.Fprintf(u.out, "[%s] Error sending notification: %v\n", time.Now().Format(time.TimeOnly), err)
+	}
+}